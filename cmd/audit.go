@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/semonte/sisu/internal/bulk"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareProfiles []string
+	compareServices []string
+	compareIgnore   []string
+	compareFormat   string
+	compareOutput   string
+	compareParallel int
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check resources across profiles",
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Report how services differ between profiles, e.g. for DR parity",
+	Long: `compare reads the same services in several profiles and reports how each
+differs from the first: files it lacks (missing), files only it has (extra)
+and files whose content differs (drifted), with the fields that differ for
+JSON documents. It reads AWS directly, so no mount needs to be running:
+
+  sisu audit compare --profiles prod,dr --services iam,ssm,lambda
+  sisu audit compare --profiles prod,dr@us-west-2 --services ssm --format html -o dr.html
+
+Regional services are compared in --region (us-east-1 by default), or in the
+region after a profile's @. ARNs are compared without their region and
+account. Fields named by --ignore, by default creation dates, modification
+times and generated IDs, are left out of JSON documents. Paths that can't be
+read are listed as not compared rather than missing, and files in truncated
+or partial listings aren't reported missing either. Listing markers like
+_more_results.txt, files that change on every read (IAM last-accessed.json)
+and files whose reads have side effects (SQS peek.json) aren't compared.
+
+The command fails if anything differs, so it can gate a pipeline.`,
+	Args: cobra.NoArgs,
+	RunE: runCompare,
+}
+
+func init() {
+	compareCmd.Flags().StringSliceVar(&compareProfiles, "profiles", nil, "Profiles to compare, the first being the baseline; profile@region sets a profile's region")
+	compareCmd.Flags().StringSliceVar(&compareServices, "services", nil, "Services to compare, e.g. iam,ssm,lambda")
+	compareCmd.Flags().StringSliceVar(&compareIgnore, "ignore", []string{"CreateDate", "LastModified", "LastModifiedDate", "RevisionId", "RoleId", "UserId", "GroupId", "PolicyId"}, "JSON fields left out of the comparison")
+	compareCmd.Flags().StringVar(&compareFormat, "format", "json", "Report format: json or html")
+	compareCmd.Flags().StringVarP(&compareOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	compareCmd.Flags().IntVarP(&compareParallel, "parallel", "p", 8, "Number of listings and reads run at once")
+	compareCmd.MarkFlagRequired("profiles")
+	compareCmd.MarkFlagRequired("services")
+	auditCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	if compareFormat != "json" && compareFormat != "html" {
+		return fmt.Errorf("unknown format %q (use json or html)", compareFormat)
+	}
+	defaultRegion := region
+	if defaultRegion == "" {
+		defaultRegion = "us-east-1"
+	}
+	var profiles []string
+	regions := make(map[string]string)
+	for _, p := range compareProfiles {
+		name, r, ok := strings.Cut(p, "@")
+		if !ok {
+			r = defaultRegion
+		}
+		profiles = append(profiles, name)
+		regions[name] = r
+	}
+
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	cfg.RoundTrip = true
+	tree, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	ignore := make(map[string]bool, len(compareIgnore))
+	for _, field := range compareIgnore {
+		ignore[field] = true
+	}
+	c := bulk.Compare{
+		Tree:     tree,
+		Profiles: profiles,
+		Services: compareServices,
+		Dir: func(profile, service string) string {
+			if fs.IsGlobalService(service) {
+				return profile + "/global/" + service
+			}
+			return profile + "/" + regions[profile] + "/" + service
+		},
+		Parallel: compareParallel,
+		Ignore:   ignore,
+	}
+	report, err := c.Run(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if compareOutput != "" {
+		file, err := os.Create(compareOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if compareFormat == "html" {
+		err = report.WriteHTML(out)
+	} else {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if err != nil {
+		return err
+	}
+	if n := len(report.Differences); n > 0 {
+		return fmt.Errorf("%d differences from %s", n, profiles[0])
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/semonte/sisu/internal/browse"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse [path]",
+	Short: "Browse resources in an interactive terminal UI",
+	Long: `browse opens an interactive browser over the same tree the mount shows,
+without mounting it. Type / to fuzzy-search the current directory; the
+preview pane shows the selected file, with JSON indented.
+
+Keys: arrows or hjkl to move, enter to open, / to search, y to copy the
+path, c to copy the contents, d to delete, q to quit.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBrowse,
+}
+
+func init() {
+	rootCmd.AddCommand(browseCmd)
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	sisuFS, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	start := ""
+	if len(args) > 0 {
+		start = args[0]
+	} else if profile != "" {
+		start = path.Join(profile, region)
+	}
+	return browse.Run(browse.New(fsSource{sisuFS}, start))
+}
+
+// fsSource browses a SisuFS directly, so listings, reads and deletes go
+// through the same caching and writability rules as the mount
+type fsSource struct {
+	fs *fs.SisuFS
+}
+
+func (s fsSource) List(dir string) ([]browse.Item, error) {
+	entries, err := s.fs.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]browse.Item, len(entries))
+	for i, e := range entries {
+		items[i] = browse.Item{Name: e.Name, IsDir: e.IsDir}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (s fsSource) Read(file string, limit int) ([]byte, error) {
+	return s.fs.ReadFile(file, limit)
+}
+
+func (s fsSource) Delete(file string) error {
+	return s.fs.Remove(file)
+}
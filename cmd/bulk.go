@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/semonte/sisu/internal/bulk"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bulkDryRun   bool
+	bulkParallel int
+	bulkNoLimit  bool
+)
+
+var bulkCmd = &cobra.Command{
+	Use:   "bulk",
+	Short: "Remove, copy or tag every file matching a glob",
+	Long: `bulk expands a glob against AWS listings directly instead of through the
+mount, which is much faster than letting the shell expand it. Quote the
+glob so the shell leaves it alone. Paths are relative to the mount root:
+
+  sisu bulk rm --dry-run 'prod/*/ssm/app/staging-*'
+  sisu bulk cp 'prod/us-east-1/ssm/app/*' dev/us-east-1/ssm/app
+  sisu bulk tag 'prod/*/ssm/app/*' team=platform owner=me
+  sisu bulk tag 'prod/*/ec2/i-*/tags.json' team=platform
+
+*, ? and [...] match within a single path segment. rm in a profile under
+protected_profiles needs sisu unlock first, as in the mount.`,
+}
+
+var bulkRmCmd = &cobra.Command{
+	Use:   "rm <glob>",
+	Short: "Delete every matching file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBulk(args[0], func(tree bulk.Tree) bulk.Op {
+			return bulk.Remove{Tree: tree}
+		})
+	},
+}
+
+var bulkCpCmd = &cobra.Command{
+	Use:   "cp <glob> <dest>",
+	Short: "Copy every matching file below dest, keeping paths below the glob's first wildcard",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBulk(args[0], func(tree bulk.Tree) bulk.Op {
+			return bulk.Copy{Tree: tree, Base: bulk.StaticPrefix(args[0]), Dest: args[1]}
+		})
+	},
+}
+
+var bulkTagCmd = &cobra.Command{
+	Use:   "tag <glob> <key=value>...",
+	Short: "Add tags to the resource behind every matching file (SSM, EC2, Lambda, DynamoDB)",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tags, err := bulk.ParseTags(args[1:])
+		if err != nil {
+			return err
+		}
+		return runBulk(args[0], func(tree bulk.Tree) bulk.Op {
+			return bulk.Tag{Tree: tree, Tags: tags}
+		})
+	},
+}
+
+func init() {
+	bulkCmd.PersistentFlags().BoolVarP(&bulkDryRun, "dry-run", "n", false, "Show what would be done without changing anything")
+	bulkCmd.PersistentFlags().IntVarP(&bulkParallel, "parallel", "p", 8, "Number of listings and operations run at once")
+	bulkCpCmd.Flags().BoolVar(&bulkNoLimit, "no-limit", false, "Copy files over the read limit (max_read_mb), holding each in memory")
+	bulkCmd.AddCommand(bulkRmCmd, bulkCpCmd, bulkTagCmd)
+	rootCmd.AddCommand(bulkCmd)
+}
+
+// runBulk expands pattern and applies the operation built by newOp to every match
+func runBulk(pattern string, newOp func(bulk.Tree) bulk.Op) error {
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	if bulkNoLimit {
+		cfg.MaxReadSize = -1
+	}
+	tree, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	if err := armFromMount(tree); err != nil {
+		return err
+	}
+
+	op := newOp(tree)
+	names, err := bulk.Expand(tree, pattern, bulkParallel)
+	if err != nil {
+		return fmt.Errorf("failed to expand %s: %w", pattern, err)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no files match %s", pattern)
+	}
+
+	if failed := bulk.Run(names, op, bulkParallel, bulkDryRun, os.Stdout, os.Stderr); failed > 0 {
+		return fmt.Errorf("%d of %d operations failed", failed, len(names))
+	}
+	return nil
+}
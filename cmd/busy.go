@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mountUser is a process keeping a mount busy
+type mountUser struct {
+	PID     int
+	Command string
+	// Uses are what it holds inside the mount, e.g. "cwd" or "fd 3:
+	// <path>", as reported by the platform's scan
+	Uses []string
+}
+
+// inMount reports whether path is mp or below it
+func inMount(path, mp string) bool {
+	mp = filepath.Clean(mp)
+	return path == mp || strings.HasPrefix(path, mp+"/")
+}
+
+// printMountUsers lists the processes keeping mp busy
+func printMountUsers(w io.Writer, mp string, users []mountUser) {
+	sort.Slice(users, func(i, j int) bool { return users[i].PID < users[j].PID })
+	fmt.Fprintf(w, "%s is in use by:\n", mp)
+	for _, u := range users {
+		fmt.Fprintf(w, "  %d %s: %s\n", u.PID, u.Command, strings.Join(u.Uses, ", "))
+	}
+}
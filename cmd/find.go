@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/semonte/sisu/internal/index"
+	"github.com/spf13/cobra"
+)
+
+var (
+	findType string
+	findTags []string
+	findLive bool
+	findARNs bool
+)
+
+// findLiveRate is how many listings and reads per second a live find makes
+const findLiveRate = 20
+
+var findCmd = &cobra.Command{
+	Use:   "find [query]...",
+	Short: "Find resources by name, type, region and tags",
+	Long: `find prints the paths and ARNs of matching resources, one per line:
+
+  sisu find --type ec2 --tag Environment=prod --region all 'name~web*'
+  sisu find --arn --type lambda payments
+
+Query terms are the same as in .sisu/search: name~glob, type:service,
+profile:name, region:name, tag:key=value (or key=value) and plain words
+matched against the path and ARN. --profile and --region narrow the search;
+a region of "all" (or none) searches every region.
+
+Results come from the background index (see index: in the config). With
+--live, or if nothing has been indexed yet, the service given by --type is
+walked now instead.`,
+	RunE: runFind,
+}
+
+func init() {
+	findCmd.Flags().StringVar(&findType, "type", "", "Only resources of this service, e.g. ec2")
+	findCmd.Flags().StringArrayVar(&findTags, "tag", nil, "Only resources with this tag, as key=value or key (repeatable)")
+	findCmd.Flags().BoolVar(&findLive, "live", false, "Walk the service now instead of searching the index")
+	findCmd.Flags().BoolVar(&findARNs, "arn", false, "Print only ARNs")
+	rootCmd.AddCommand(findCmd)
+}
+
+func runFind(cmd *cobra.Command, args []string) error {
+	q, err := index.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	if findType != "" {
+		q.Service = findType
+	}
+	if profile != "" {
+		q.Profile = profile
+	}
+	if region != "" {
+		q.Region = region
+	}
+	for _, tag := range findTags {
+		key, value, _ := strings.Cut(tag, "=")
+		q.AddTag(key, value)
+	}
+
+	idx, err := index.Load(index.DefaultPath())
+	if err != nil {
+		return err
+	}
+	if findLive || idx.Len() == 0 {
+		if idx, err = findLiveIndex(cmd.Context(), q); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range idx.Search(q) {
+		switch {
+		case findARNs && r.ARN != "":
+			fmt.Println(r.ARN)
+		case findARNs:
+		case r.ARN != "":
+			fmt.Printf("%s\t%s\n", r.Path, r.ARN)
+		default:
+			fmt.Println(r.Path)
+		}
+	}
+	return nil
+}
+
+// findLiveIndex walks the service q asks for in every profile and region
+// it allows and returns the records in a throwaway index
+func findLiveIndex(ctx context.Context, q index.Query) (*index.Index, error) {
+	if q.Service == "" {
+		return nil, fmt.Errorf("nothing is indexed yet; pass --type to search a service live")
+	}
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Index = nil
+	sisuFS, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	ix := &index.Indexer{Tree: sisuFS, Index: index.New(), Rate: findLiveRate}
+	for _, p := range findDirs(sisuFS, "", q.Profile) {
+		for _, r := range findDirs(sisuFS, p, q.Region) {
+			// Services missing from a region, like iam outside global, fail
+			// to list and are skipped
+			if err := ix.Walk(ctx, p+"/"+r+"/"+q.Service); err != nil && debug {
+				log.Printf("find: %v", err)
+			}
+		}
+	}
+	return ix.Index, nil
+}
+
+// findDirs returns want, or if it is empty or "all", every subdirectory of dir
+func findDirs(sisuFS *fs.SisuFS, dir, want string) []string {
+	if want != "" && want != "all" {
+		return []string{want}
+	}
+	entries, err := sisuFS.List(dir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir && !strings.HasPrefix(e.Name, ".") {
+			dirs = append(dirs, e.Name)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/semonte/sisu/internal/bulk"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mirrorParallel int
+	mirrorNoCommit bool
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <path> <git-dir>",
+	Short: "Export a subtree to a git repository and commit what changed",
+	Long: `mirror copies every file below a path in the tree into a git repository,
+at the same path, and commits the differences since the last mirror. It
+reads AWS directly, so no mount needs to be running; run it from cron to
+keep the configuration as a git history:
+
+  sisu mirror prod/global/iam ~/aws-config
+  sisu mirror prod/us-east-1/ssm/app ~/aws-config
+
+JSON documents are written in canonical form (see round_trip), so only real
+changes show up as diffs. Each commit lists the files added, modified and
+removed. The repository is created if it doesn't exist. Files that can't be
+read, or are past a truncated listing, keep their previous copy. Listing
+markers like _more_results.txt, files that change on every read (IAM
+last-accessed.json) and files whose reads have side effects (SQS peek.json)
+aren't mirrored.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMirror,
+}
+
+func init() {
+	mirrorCmd.Flags().IntVarP(&mirrorParallel, "parallel", "p", 8, "Number of listings and reads run at once")
+	mirrorCmd.Flags().BoolVar(&mirrorNoCommit, "no-commit", false, "Update the files without committing them")
+	rootCmd.AddCommand(mirrorCmd)
+}
+
+func runMirror(cmd *cobra.Command, args []string) error {
+	root := strings.Trim(mountRelative(args[0]), "/")
+	if root == "" || root == fs.ControlDir || strings.HasPrefix(root, fs.ControlDir+"/") {
+		return fmt.Errorf("mirror needs a path in the tree like <profile>/global/iam")
+	}
+	dir := args[1]
+
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	cfg.RoundTrip = true
+	tree, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := git(dir, "init", "-q"); err != nil {
+			return err
+		}
+	}
+
+	m := bulk.Mirror{
+		Tree:     tree,
+		Root:     root,
+		Dir:      dir,
+		Parallel: mirrorParallel,
+		Exclude: func(name string) bool {
+			// Each profile's changes.log changes with every listing
+			profile, rest, _ := strings.Cut(name, "/")
+			return profile != "" && rest == fs.ChangesFile
+		},
+	}
+	changes, failed, err := m.Run(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to mirror %s: %w", root, err)
+	}
+	for _, c := range changes {
+		fmt.Printf("%-8s %s\n", c.Kind, c.Name)
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+	} else if !mirrorNoCommit {
+		subject, body := mirrorMessage(root, changes)
+		if err := git(dir, "add", "-A", "--", filepath.FromSlash(root)); err != nil {
+			return err
+		}
+		if err := git(dir, "commit", "-q", "-m", subject, "-m", body); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d paths could not be mirrored", failed)
+	}
+	return nil
+}
+
+// mirrorMessage describes the drift a mirror found as a commit subject
+// counting the changes and a body listing them
+func mirrorMessage(root string, changes []bulk.MirrorChange) (subject, body string) {
+	counts := make(map[provider.ChangeKind]int)
+	var b strings.Builder
+	for _, c := range changes {
+		counts[c.Kind]++
+		fmt.Fprintf(&b, "%s %s\n", c.Kind, c.Name)
+	}
+	var parts []string
+	for _, kind := range []provider.ChangeKind{provider.ChangeAdded, provider.ChangeModified, provider.ChangeRemoved} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return fmt.Sprintf("%s: %s", root, strings.Join(parts, ", ")), b.String()
+}
+
+// git runs a git command in dir
+func git(dir string, args ...string) error {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+//go:build darwin
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountTable lists the mounts in the mount table
+func mountTable() ([]mountEntry, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, err
+	}
+	// Leave room for mounts made between the two calls
+	stats := make([]unix.Statfs_t, n+8)
+	n, err = unix.Getfsstat(stats, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, err
+	}
+	mounts := make([]mountEntry, 0, n)
+	for i := range stats[:n] {
+		st := &stats[i]
+		mounts = append(mounts, mountEntry{Point: cString(st.Mntonname[:]), FSType: cString(st.Fstypename[:]), Source: cString(st.Mntfromname[:])})
+	}
+	return mounts, nil
+}
+
+// cString returns the NUL-terminated string at the start of b
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// unmountCommand returns the command that unmounts path; force also
+// detaches it while busy or if the server is hung
+func unmountCommand(path string, force bool) *exec.Cmd {
+	if force {
+		return exec.Command("umount", "-f", path)
+	}
+	return exec.Command("umount", path)
+}
+
+// mountUsers lists the processes with their working directory or an open
+// file inside mp, using lsof. -b keeps lsof from calls that would block
+// on a hung mount.
+func mountUsers(mp string) ([]mountUser, error) {
+	out, err := exec.Command("lsof", "-b", "-w", "-F", "pcfn", "--", mp).Output()
+	if err != nil && len(out) == 0 {
+		// lsof exits 1 when nothing has files open in mp
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseLsof(out, mp), nil
+}
+
+// parseLsof reads the processes of lsof -F pcfn output: a p<pid> line
+// starts each process, followed by c<command> and f<fd>, n<name> pairs
+func parseLsof(out []byte, mp string) []mountUser {
+	var users []mountUser
+	var fd string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		field, value := line[0], line[1:]
+		switch field {
+		case 'p':
+			pid, err := strconv.Atoi(value)
+			if err != nil || pid == os.Getpid() {
+				pid = 0
+			}
+			users = append(users, mountUser{PID: pid})
+		case 'c':
+			if len(users) > 0 {
+				users[len(users)-1].Command = value
+			}
+		case 'f':
+			fd = value
+		case 'n':
+			if len(users) == 0 || !inMount(value, mp) {
+				continue
+			}
+			u := &users[len(users)-1]
+			if fd == "cwd" || fd == "rtd" {
+				u.Uses = append(u.Uses, fd)
+			} else {
+				u.Uses = append(u.Uses, "fd "+fd+": "+value)
+			}
+		}
+	}
+	kept := users[:0]
+	for _, u := range users {
+		if u.PID != 0 && len(u.Uses) > 0 {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
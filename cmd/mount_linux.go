@@ -0,0 +1,119 @@
+//go:build linux
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// mountTable lists the mounts of /proc/self/mountinfo
+func mountTable() ([]mountEntry, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	return parseMountInfo(string(data)), nil
+}
+
+// parseMountInfo returns the mounts of a mountinfo file, whose lines are
+//
+//	<id> <parent> <dev> <root> <mount point> <options> [<tag>...] - <type> <source> <super options>
+//
+// The separator is looked for after the six fixed fields, as the root or
+// mount point may be "-" too.
+func parseMountInfo(data string) []mountEntry {
+	var mounts []mountEntry
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		sep := slices.Index(fields[6:], "-") + 6
+		if sep < 6 || sep+2 >= len(fields) {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			Point:  unescapeMountField(fields[4]),
+			FSType: fields[sep+1],
+			Source: unescapeMountField(fields[sep+2]),
+		})
+	}
+	return mounts
+}
+
+// unescapeMountField decodes the octal escapes (e.g. \040 for a space) the
+// kernel writes in mount table fields
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool { return c >= '0' && c <= '7' }
+
+// unmountCommand returns the command that unmounts path; force also
+// detaches it while busy or if the server is hung
+func unmountCommand(path string, force bool) *exec.Cmd {
+	if force {
+		return exec.Command("fusermount", "-uz", path)
+	}
+	return exec.Command("fusermount", "-u", path)
+}
+
+// mountUsers lists the processes with their working or root directory,
+// or an open file, inside mp, from /proc. It only reads symlinks, so it
+// doesn't touch the mount and works while the mount is hung. Processes of
+// other users whose fds can't be read are only checked for their cwd.
+func mountUsers(mp string) ([]mountUser, error) {
+	return scanProc("/proc", mp, os.Getpid())
+}
+
+// scanProc lists the processes in the proc filesystem at proc using mp,
+// except self
+func scanProc(proc, mp string, self int) ([]mountUser, error) {
+	procs, err := os.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+	var users []mountUser
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join(proc, p.Name())
+		var uses []string
+		for _, link := range []string{"cwd", "root"} {
+			if target, err := os.Readlink(filepath.Join(dir, link)); err == nil && inMount(target, mp) {
+				uses = append(uses, link)
+			}
+		}
+		fds, _ := os.ReadDir(filepath.Join(dir, "fd"))
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err == nil && inMount(target, mp) {
+				uses = append(uses, "fd "+fd.Name()+": "+target)
+			}
+		}
+		if len(uses) == 0 {
+			continue
+		}
+		comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+		users = append(users, mountUser{PID: pid, Command: strings.TrimSpace(string(comm)), Uses: uses})
+	}
+	return users, nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	data := `22 1 0:21 / /proc rw,nosuid shared:12 - proc proc rw
+98 29 0:52 / /home/me/aws\040mount rw,nosuid,nodev,relatime shared:51 master:1 - fuse.sisu sisu rw,user_id=1000
+99 29 0:53 / /mnt/- rw,relatime - fuse.sisu sisu\040prod rw
+100 29 0:54 / /mnt/tab\011dir rw,relatime - fuse.sisu sisu rw
+truncated line
+101 29 0:55 / /mnt/short rw -
+`
+	want := []mountEntry{
+		{Point: "/proc", FSType: "proc", Source: "proc"},
+		{Point: "/home/me/aws mount", FSType: "fuse.sisu", Source: "sisu"},
+		{Point: "/mnt/-", FSType: "fuse.sisu", Source: "sisu prod"},
+		{Point: "/mnt/tab\tdir", FSType: "fuse.sisu", Source: "sisu"},
+	}
+	if got := parseMountInfo(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMountInfo = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnescapeMountField(t *testing.T) {
+	for in, want := range map[string]string{
+		`/plain`:           "/plain",
+		`/a\040b`:          "/a b",
+		`/back\134slash`:   `/back\slash`,
+		`/not\08escape`:    `/not\08escape`,
+		`/trailing\04`:     `/trailing\04`,
+		`/new\012line\040`: "/new\nline ",
+	} {
+		if got := unescapeMountField(in); got != want {
+			t.Errorf("unescapeMountField(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// fakeProc builds a proc tree of the given symlinks and files, relative to
+// its root
+func fakeProc(t *testing.T, links, files map[string]string) string {
+	t.Helper()
+	proc := t.TempDir()
+	for name, target := range links {
+		path := filepath.Join(proc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(proc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return proc
+}
+
+func TestScanProc(t *testing.T) {
+	proc := fakeProc(t, map[string]string{
+		"100/cwd":  "/mnt/aws/prod",
+		"100/root": "/",
+		"100/fd/0": "/dev/pts/0",
+		"100/fd/3": "/mnt/aws/prod/us-east-1/ssm/app/db-url",
+		"200/cwd":  "/mnt/aws-other", // shares the prefix only
+		"300/cwd":  "/mnt/aws",       // the sisu process itself
+		"self/cwd": "/mnt/aws",
+	}, map[string]string{
+		"100/comm":    "vim\n",
+		"200/comm":    "bash\n",
+		"300/comm":    "sisu\n",
+		"uptime":      "1.0 1.0\n",
+		"self/comm":   "sisu\n",
+		"400/comm":    "gone\n", // no links readable
+		"100/environ": "",
+	})
+
+	users, err := scanProc(proc, "/mnt/aws/", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mountUser{{PID: 100, Command: "vim", Uses: []string{"cwd", "fd 3: /mnt/aws/prod/us-east-1/ssm/app/db-url"}}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("scanProc = %+v, want %+v", users, want)
+	}
+
+	if _, err := scanProc(filepath.Join(proc, "missing"), "/mnt/aws", 0); err == nil {
+		t.Error("scanning a missing proc succeeded")
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var mountBucketCmd = &cobra.Command{
+	Use:   "mount-bucket s3://<bucket>[/<prefix>] <dir>",
+	Short: "Mount a single S3 bucket or prefix as a folder",
+	Long: `mount-bucket mounts one bucket, or a prefix in it, at dir and nothing else
+of the account, read-write:
+
+  sisu mount-bucket s3://my-bucket ./bucket
+  sisu mount-bucket --profile prod s3://my-bucket/reports/2026 ./reports
+
+Objects are read in ranges as programs ask for them, so large files stream
+rather than download whole, and files written are uploaded when closed.
+It's the same as sisu --root <profile>/global/s3/<bucket>/<prefix>
+--mountpoint dir, using the default profile unless --profile is given.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMountBucket,
+}
+
+func init() {
+	// The mount flags of the root command it shares, with the same defaults
+	mountBucketCmd.Flags().BoolVar(&noShell, "no-shell", false, "Don't open a shell; serve the mount until interrupted or sent SIGTERM")
+	mountBucketCmd.Flags().BoolVar(&foreground, "foreground", false, "Run as a sidecar: no shell, logs on stdout and a /healthz endpoint")
+	mountBucketCmd.Flags().StringVar(&healthAddr, "health-addr", ":9180", "Address of the /healthz endpoint with --foreground (empty = none)")
+	mountBucketCmd.Flags().DurationVar(&watchdog, "watchdog", 30*time.Second, "How often to check the mount responds and remount it if wedged (0 = never)")
+	rootCmd.AddCommand(mountBucketCmd)
+}
+
+func runMountBucket(cmd *cobra.Command, args []string) error {
+	bucket, prefix, err := parseS3URL(args[0])
+	if err != nil {
+		return err
+	}
+	p := profile
+	if p == "" {
+		p = "default"
+	}
+	if region != "" {
+		return fmt.Errorf("mount-bucket takes no --region; buckets are found in their own region")
+	}
+	mountRoot = strings.Join([]string{p, "global", "s3", bucket}, "/")
+	if prefix != "" {
+		mountRoot += "/" + prefix
+	}
+	mountpoint = args[1]
+	profile = ""
+	return runSisu(cmd, nil)
+}
+
+// parseS3URL splits s3://bucket/prefix into its bucket and prefix
+func parseS3URL(url string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(url, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%s is not an S3 URL like s3://my-bucket/prefix", url)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("%s names no bucket", url)
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import "testing"
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		url, bucket, prefix string
+	}{
+		{"s3://my-bucket", "my-bucket", ""},
+		{"s3://my-bucket/", "my-bucket", ""},
+		{"s3://my-bucket/reports/2026", "my-bucket", "reports/2026"},
+		{"s3://my-bucket/reports/2026/", "my-bucket", "reports/2026"},
+	}
+	for _, tt := range tests {
+		bucket, prefix, err := parseS3URL(tt.url)
+		if err != nil || bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("parseS3URL(%q) = %q, %q, %v; want %q, %q", tt.url, bucket, prefix, err, tt.bucket, tt.prefix)
+		}
+	}
+	for _, url := range []string{"my-bucket", "s3:/my-bucket", "s3://", "s3:///prefix"} {
+		if _, _, err := parseS3URL(url); err == nil {
+			t.Errorf("parseS3URL(%q) succeeded", url)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var unpin bool
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [path]...",
+	Short: "Keep a snapshot of a subtree for when AWS is unreachable",
+	Long: `pin fetches every file below a path into ~/.sisu/pins and keeps the copy
+refreshed while sisu is mounted. If AWS can't be reached, the mount serves
+pinned paths from their snapshot instead of failing:
+
+  sisu pin prod/global/iam/policies prod/us-east-1/ssm/app
+  sisu pin                      # list pinned paths
+  sisu pin --remove prod/us-east-1/ssm/app
+
+Files over 1 MB are listed but not kept.`,
+	RunE: runPin,
+}
+
+func init() {
+	pinCmd.Flags().BoolVar(&unpin, "remove", false, "Stop pinning the paths and delete their snapshots")
+	rootCmd.AddCommand(pinCmd)
+}
+
+// pinDir returns where pinned paths and their snapshots are kept
+func pinDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sisu", "pins")
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		pins, err := fs.ReadPins(pinDir())
+		if err != nil {
+			return err
+		}
+		for _, pin := range pins {
+			fmt.Println(pin)
+		}
+		return nil
+	}
+
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	if cfg.PinDir == "" {
+		return fmt.Errorf("no home directory to keep pins in")
+	}
+	tree, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+
+	for _, path := range args {
+		if unpin {
+			if err := tree.Unpin(path); err != nil {
+				return err
+			}
+			fmt.Println("Unpinned", path)
+			continue
+		}
+		s, err := tree.Pin(context.Background(), path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pinned %s (%d directories, %d files)\n", path, len(s.Dirs), len(s.Files))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var portForwardCmd = &cobra.Command{
+	Use:   "port-forward <profile>/<region>/ec2/<instance>/port-forward/<port>",
+	Short: "Forward a local port to an instance through Session Manager",
+	Long: `port-forward starts the Session Manager session that the port-forward file
+of an instance shows, forwarding a local port to the port on the instance
+until interrupted:
+
+  sisu port-forward prod/us-east-1/ec2/i-0abc123def4567890/port-forward/5432
+  psql -h localhost -p 5432 ...
+
+Privileged ports are forwarded from 10000 above them, e.g. 22 from 10022.
+It runs the AWS CLI, which needs the Session Manager plugin, with the
+profile of the path; profiles configured only in sisu's credentials aren't
+known to it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPortForward,
+}
+
+func init() {
+	rootCmd.AddCommand(portForwardCmd)
+}
+
+func runPortForward(cmd *cobra.Command, args []string) error {
+	parts := strings.Split(strings.Trim(mountRelative(args[0]), "/"), "/")
+	if len(parts) != 6 || parts[2] != "ec2" || parts[4] != provider.EC2PortForwardDir {
+		return fmt.Errorf("%s is not a path like <profile>/<region>/ec2/<instance>/%s/<port>", args[0], provider.EC2PortForwardDir)
+	}
+	profile, region, instanceID := parts[0], parts[1], parts[3]
+	port, err := strconv.Atoi(parts[5])
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%s is not a port", parts[5])
+	}
+	if profile == "default" {
+		profile = ""
+	}
+
+	fmt.Fprintf(os.Stderr, "Forwarding localhost:%d to port %d of %s; press Ctrl-C to stop\n",
+		provider.PortForwardLocalPort(port), port, instanceID)
+	aws := exec.Command("aws", provider.PortForwardArgs(profile, region, instanceID, port)...)
+	aws.Stdin = os.Stdin
+	aws.Stdout = os.Stdout
+	aws.Stderr = os.Stderr
+	return aws.Run()
+}
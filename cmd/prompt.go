@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/semonte/sisu/internal/config"
+)
+
+// promptColors maps the color names allowed in the config to ANSI SGR codes
+var promptColors = map[string]string{
+	"red":     "1;31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+}
+
+// shellCommand returns the interactive shell spawned inside the mount. Its
+// prompt shows the profile and region of the current directory, e.g.
+// "sisu[prod:us-east-1] ~/s3/bucket $", colored and annotated per profile.
+// The returned cleanup removes the generated rc file once the shell exits.
+func shellCommand(shell, mp, startDir string, cfg *config.Config) (*exec.Cmd, func(), error) {
+	script, err := promptScript(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "sisu-shell-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	var cmd *exec.Cmd
+	env := append(os.Environ(), "SISU_MOUNT="+mp, "SISU_ROOT="+mountRoot)
+	if strings.Contains(shell, "zsh") {
+		// zsh reads .zshrc from ZDOTDIR; the user's own is sourced first so
+		// the sisu prompt isn't overridden by it
+		rc := `[ -f "$HOME/.zshrc" ] && ZDOTDIR="$HOME" source "$HOME/.zshrc"` + "\n" + script + `
+setopt PROMPT_SUBST
+precmd_functions+=(__sisu_prompt)
+PROMPT='` + zshPrompt + `'
+`
+		err = os.WriteFile(filepath.Join(dir, ".zshrc"), []byte(rc), 0600)
+		cmd = exec.Command(shell, "-i")
+		env = append(env, "ZDOTDIR="+dir)
+	} else {
+		rc := script + `
+PROMPT_COMMAND=__sisu_prompt
+PS1='${__sisu_emoji}\[${__sisu_color}\]sisu${__sisu_seg}\[${__sisu_reset}\] ${__sisu_dir} \$ '
+`
+		err = os.WriteFile(filepath.Join(dir, "bashrc"), []byte(rc), 0600)
+		cmd = exec.Command(shell, "--rcfile", filepath.Join(dir, "bashrc"), "-i")
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	cmd.Dir = startDir
+	cmd.Env = env
+	return cmd, cleanup, nil
+}
+
+// zshPrompt is the zsh PROMPT showing the variables set by __sisu_prompt.
+// zsh expands prompt escapes after substituting them, so the '%' of
+// profile names, emoji and paths is doubled to show up as itself.
+const zshPrompt = `${__sisu_emoji//\%/%%}%{${__sisu_color}%}sisu${__sisu_seg//\%/%%}%{${__sisu_reset}%} ${__sisu_dir//\%/%%} $ `
+
+// promptScript returns shell code, valid in bash and zsh, defining
+// __sisu_prompt. Run before each prompt, it splits $PWD below $SISU_MOUNT
+// into profile and region and sets the variables the prompt is built from:
+// __sisu_seg ("[prod:us-east-1]"), __sisu_dir ("~/s3/bucket"), and
+// __sisu_color and __sisu_emoji from the profile's style.
+func promptScript(cfg *config.Config) (string, error) {
+	styles, err := profileStyles(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(`__sisu_esc=$(printf '\033')
+__sisu_reset="${__sisu_esc}[0m"
+__sisu_prompt() {
+	__sisu_seg= __sisu_color= __sisu_emoji=
+	case "$PWD" in
+	"$SISU_MOUNT"|"$SISU_MOUNT"/*) ;;
+	*) __sisu_dir=$PWD; return ;;
+	esac
+	__sisu_rest=${SISU_ROOT}${PWD#"$SISU_MOUNT"}
+	__sisu_rest=${__sisu_rest#/}
+	__sisu_profile=${__sisu_rest%%/*}
+	__sisu_rest=${__sisu_rest#"$__sisu_profile"}
+	__sisu_rest=${__sisu_rest#/}
+	__sisu_region=${__sisu_rest%%/*}
+	__sisu_rest=${__sisu_rest#"$__sisu_region"}
+	__sisu_dir="~$__sisu_rest"
+	if [ -n "$__sisu_region" ]; then
+		__sisu_seg="[$__sisu_profile:$__sisu_region]"
+	elif [ -n "$__sisu_profile" ]; then
+		__sisu_seg="[$__sisu_profile]"
+	else
+		return
+	fi
+	case "$__sisu_profile" in
+`)
+	for _, s := range styles {
+		fmt.Fprintf(&b, "\t%s) __sisu_color=%s __sisu_emoji=%s ;;\n", s.pattern, s.color, s.emoji)
+	}
+	b.WriteString("\tesac\n}\n")
+	return b.String(), nil
+}
+
+// promptStyle is a case branch of __sisu_prompt, already shell-quoted
+type promptStyle struct {
+	pattern string
+	color   string
+	emoji   string
+}
+
+// profileStyles turns the profiles and production_profiles config into case
+// branches. Explicitly styled profiles come first; those without a color
+// of their own are still red when they match a production pattern.
+func profileStyles(cfg *config.Config) ([]promptStyle, error) {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var styles []promptStyle
+	for _, name := range names {
+		style := cfg.Profiles[name]
+		color := style.Color
+		if color == "" && isProduction(cfg.ProductionProfiles, name) {
+			color = "red"
+		}
+		code, err := colorCode(color)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		emoji := ""
+		if style.Emoji != "" {
+			emoji = style.Emoji + " "
+		}
+		styles = append(styles, promptStyle{pattern: shellQuote(name), color: code, emoji: shellQuote(emoji)})
+	}
+
+	red, _ := colorCode("red")
+	for _, glob := range cfg.ProductionProfiles {
+		styles = append(styles, promptStyle{pattern: shellGlob(glob), color: red, emoji: "''"})
+	}
+	return styles, nil
+}
+
+// isProduction reports whether profile matches one of the production globs
+func isProduction(globs []string, profile string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, profile); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// colorCode returns the shell expression for a color's escape sequence
+func colorCode(color string) (string, error) {
+	if color == "" {
+		return "''", nil
+	}
+	code, ok := promptColors[color]
+	if !ok {
+		return "", fmt.Errorf("unknown color %q", color)
+	}
+	return `"${__sisu_esc}[` + code + `m"`, nil
+}
+
+// shellQuote single-quotes s for the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellGlob quotes a glob for a case pattern, leaving * and ? active
+func shellGlob(glob string) string {
+	var b strings.Builder
+	literal := ""
+	flush := func() {
+		if literal != "" {
+			b.WriteString(shellQuote(literal))
+			literal = ""
+		}
+	}
+	for _, r := range glob {
+		if r == '*' || r == '?' {
+			flush()
+			b.WriteRune(r)
+			continue
+		}
+		literal += string(r)
+	}
+	flush()
+	if b.Len() == 0 {
+		return "''"
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"github.com/semonte/sisu/internal/config"
+)
+
+func TestZshPromptEscapesPercent(t *testing.T) {
+	// Colors are inside %{...%} and hold escape sequences only
+	for _, m := range regexp.MustCompile(`\$\{(__sisu_\w+)([^}]*)\}`).FindAllStringSubmatch(zshPrompt, -1) {
+		if m[1] == "__sisu_color" || m[1] == "__sisu_reset" {
+			continue
+		}
+		if m[2] != `//\%/%%` {
+			t.Errorf("%s is substituted into the zsh prompt without doubling %%", m[1])
+		}
+	}
+}
+
+func TestPromptScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	cfg := &config.Config{
+		Profiles:           map[string]config.ProfileStyle{"100%dev": {Color: "green", Emoji: "🧪"}},
+		ProductionProfiles: []string{"prod-*"},
+	}
+	script, err := promptScript(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pwd, want string
+	}{
+		{"/home/me", "||/home/me|"},
+		{"/mnt/aws", "||~|"},
+		{"/mnt/aws/100%dev", "[100%dev]|🧪 |~|32"},
+		{"/mnt/aws/100%dev/us-east-1/s3/my-bucket", "[100%dev:us-east-1]|🧪 |~/s3/my-bucket|32"},
+		{"/mnt/aws/prod-eu/global", "[prod-eu:global]||~|1;31"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(bash, "-c", script+`
+PWD=$1 __sisu_prompt
+code=${__sisu_color#"${__sisu_esc}["}
+printf '%s|%s|%s|%s' "$__sisu_seg" "$__sisu_emoji" "$__sisu_dir" "${code%m}"`, "sh", tt.pwd)
+		cmd.Env = []string{"SISU_MOUNT=/mnt/aws", "SISU_ROOT="}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v: %s", tt.pwd, err, out)
+		}
+		if got := string(out); got != tt.want {
+			t.Errorf("prompt in %s = %q, want %q", tt.pwd, got, tt.want)
+		}
+	}
+}
+
+func TestShellGlob(t *testing.T) {
+	for glob, want := range map[string]string{
+		"prod*":  `'prod'*`,
+		"*-prd":  `*'-prd'`,
+		"a?b":    `'a'?'b'`,
+		"it's*":  `'it'\''s'*`,
+		"":       `''`,
+		"$(x)*":  `'$(x)'*`,
+		"100%":   `'100%'`,
+		"prod-?": `'prod-'?`,
+	} {
+		if got := shellGlob(glob); got != want {
+			t.Errorf("shellGlob(%q) = %s, want %s", glob, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh <path>",
+	Short: "Drop the cache of a subtree, list it again and print what changed",
+	Long: `Drop everything the running mount cached below path, list it again, along
+with up to 99 of the directories below it listed before, shallowest first,
+and print the entries that changed as JSON:
+
+  sisu refresh prod/us-east-1/ssm/app
+  sisu refresh ~/.sisu/mnt/prod/global/s3/my-bucket/incoming
+
+The first refresh of a directory nobody listed yet only records it, so a
+script polling for a new object or parameter refreshes once up front and
+then in a loop until the output isn't [].
+
+This writes to .sisu/refresh in the running mount and reads the result back.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRefresh,
+}
+
+func init() {
+	rootCmd.AddCommand(refreshCmd)
+}
+
+func runRefresh(cmd *cobra.Command, args []string) error {
+	mp := mountpoint
+	if mp == "" {
+		mp = defaultMountpoint()
+	}
+	if !isMounted(mp) {
+		return fmt.Errorf("not mounted at %s", mp)
+	}
+	path := strings.Trim(mountRelative(args[0]), "/")
+
+	// The result is read back from the same open file, so concurrent
+	// refreshes don't see each other's
+	file, err := os.OpenFile(filepath.Join(mp, fs.ControlDir, fs.RefreshFile), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(path + "\n"); err != nil {
+		return fmt.Errorf("refreshing %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("refreshing %s (a path inside a service, e.g. prod/us-east-1/ssm/app): %w", path, err)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -1,14 +1,26 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/semonte/sisu/internal/cache"
+	"github.com/semonte/sisu/internal/config"
 	"github.com/semonte/sisu/internal/fs"
+	"github.com/semonte/sisu/internal/index"
 	"github.com/semonte/sisu/internal/provider"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +29,22 @@ var (
 	profile    string
 	region     string
 	mountpoint string
+	mountRoot  string
 	debug      bool
+	timeouts   []string
+	rateLimit  float64
+	configPath string
+	maxEntries int
+	caseFold   bool
+	recordPath string
+	replayPath string
+	watchdog   time.Duration
+	foreground bool
+	noShell    bool
+	healthAddr string
+	idleAfter  time.Duration
+	forceStop  bool
+	redact     bool
 )
 
 func defaultMountpoint() string {
@@ -42,7 +69,10 @@ Type 'exit' to unmount and return to your original shell.`,
 var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Unmount sisu",
-	RunE:  runStop,
+	Long: `Unmount sisu. If processes have their working directory or open files
+inside the mount, they are listed and the mount is left alone; --force
+detaches it anyway, and they lose access once they're done with it.`,
+	RunE: runStop,
 }
 
 func init() {
@@ -50,8 +80,26 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&region, "region", "", "Start in this region directory")
 	rootCmd.PersistentFlags().StringVar(&mountpoint, "mountpoint", "", "Custom mount point (default: ~/.sisu/mnt)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", config.DefaultPath(), "Path to the sisu config file")
+	rootCmd.PersistentFlags().BoolVar(&redact, "redact", false, "Mask secret values and access keys in file contents and exports, for screen sharing (makes the mount read-only)")
+	rootCmd.Flags().StringVar(&mountRoot, "root", "", "Mount only this subtree, e.g. prod/eu-west-1 or prod/global/s3")
+	rootCmd.Flags().Float64Var(&rateLimit, "rate-limit", 0, "Max AWS API calls per second per profile and service, across regions (0 = unlimited)")
+	rootCmd.Flags().IntVar(&maxEntries, "max-entries", 0, "Max entries per directory listing (default 1000)")
+	rootCmd.Flags().BoolVar(&caseFold, "case-insensitive", runtime.GOOS == "darwin", "Rename entries whose names differ only by case")
+	rootCmd.Flags().StringVar(&recordPath, "record", "", "Record every AWS call and response to this file")
+	rootCmd.Flags().StringVar(&replayPath, "replay", "", "Serve the mount from a recording instead of AWS (no credentials needed)")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	rootCmd.Flags().DurationVar(&watchdog, "watchdog", 30*time.Second, "How often to check the mount responds and remount it if wedged (0 = never)")
+	rootCmd.Flags().BoolVar(&noShell, "no-shell", false, "Don't open a shell; serve the mount until interrupted or sent SIGTERM")
+	rootCmd.Flags().BoolVar(&foreground, "foreground", false, "Run as a sidecar: no shell, logs on stdout and a /healthz endpoint")
+	rootCmd.Flags().StringVar(&healthAddr, "health-addr", ":9180", "Address of the /healthz endpoint with --foreground (empty = none)")
+	rootCmd.Flags().DurationVar(&idleAfter, "idle-timeout", 0, "Unmount (with --no-shell or --foreground) or warn (in the shell) after this long without file access (0 = never)")
+	rootCmd.Flags().StringSliceVar(&timeouts, "timeout", nil, "Operation timeout as [service.]op=duration, e.g. readdir=10s or s3.read=2m")
+
+	stopCmd.Flags().BoolVar(&forceStop, "force", false, "Unmount lazily even while processes use the mount")
 
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(statusCmd)
 }
 
 func Execute() {
@@ -76,6 +124,11 @@ func runSisu(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("already mounted at %s, run 'sisu stop' first", mp)
 	}
 
+	if foreground {
+		noShell = true
+		log.SetOutput(os.Stdout)
+	}
+
 	fmt.Println("Mounting AWS resources to", mp+"...")
 	if debug {
 		fmt.Println("Debug mode: enabled")
@@ -84,19 +137,46 @@ func runSisu(cmd *cobra.Command, args []string) error {
 		provider.Debug = true
 	}
 
-	// Create and mount the filesystem
-	sisuFS, err := fs.NewSisuFS(fs.Config{})
+	userCfg, err := config.Load(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize: %w", err)
+		return err
 	}
-
-	server, err := sisuFS.Mount(mp)
+	cfg, err := fsConfig(userCfg)
 	if err != nil {
-		return fmt.Errorf("failed to mount: %w", err)
+		return err
+	}
+
+	if debug {
+		cfg.Trace, err = provider.NewTracer(traceDir())
+		if err != nil {
+			return fmt.Errorf("failed to create trace directory: %w", err)
+		}
+		fmt.Println("Tracing to", traceDir())
+	}
+	if replayPath != "" {
+		cfg.Replay, err = provider.LoadSession(replayPath)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Replaying", replayPath)
+	}
+	if recordPath != "" {
+		cfg.Record, err = provider.NewSessionRecorder(recordPath)
+		if err != nil {
+			return err
+		}
+		defer cfg.Record.Close()
+		fmt.Println("Recording to", recordPath)
 	}
 
-	fmt.Println("\nMounted! Opening new shell. Type 'exit' to unmount.")
-	fmt.Println()
+	mountRoot = strings.Trim(mountRoot, "/")
+	if mountRoot != "" && (profile != "" || region != "") {
+		return fmt.Errorf("--root can't be combined with --profile or --region")
+	}
+	cfg.Root = mountRoot
+	if profile != "" && len(cfg.WarmUp.Profiles) == 0 {
+		cfg.WarmUp.Profiles = []string{profile}
+	}
 
 	// Determine starting directory
 	startDir := mp
@@ -107,44 +187,324 @@ func runSisu(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Spawn a new shell
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/sh"
+	// Prepare the shell before mounting so config errors leave nothing behind
+	var shellCmd *exec.Cmd
+	if !noShell {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		var cleanup func()
+		shellCmd, cleanup, err = shellCommand(shell, mp, startDir, userCfg)
+		if err != nil {
+			return fmt.Errorf("invalid prompt settings in %s: %w", configPath, err)
+		}
+		defer cleanup()
 	}
 
-	// Determine shell type for prompt customization
-	var shellCmd *exec.Cmd
-	if strings.Contains(shell, "zsh") {
-		// For zsh, use ZDOTDIR trick or pass prompt via -c
-		shellCmd = exec.Command(shell, "-c", fmt.Sprintf(`
-			PROMPT='sisu:%%~ $ '
-			cd %q
-			exec %s -i
-		`, startDir, shell))
-	} else {
-		// For bash/sh, use --rcfile or PS1
-		shellCmd = exec.Command(shell, "--rcfile", "/dev/null", "-i")
-		shellCmd.Dir = startDir
-		shellCmd.Env = append(os.Environ(),
-			"SISU_MOUNT="+mp,
-			`PS1=sisu:\w $ `,
-		)
+	// Create and mount the filesystem
+	sisuFS, err := fs.NewSisuFS(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
 	}
 
-	shellCmd.Stdin = os.Stdin
-	shellCmd.Stdout = os.Stdout
-	shellCmd.Stderr = os.Stderr
+	server, err := sisuFS.Mount(mp)
+	if err != nil {
+		return fmt.Errorf("failed to mount: %w", err)
+	}
 
-	shellCmd.Run() // ignore exit status - it's just the shell's last command status
+	var serverMu sync.Mutex
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if watchdog > 0 {
+		w := &fs.Watchdog{
+			Mountpoint:  mp,
+			Interval:    watchdog,
+			Timeout:     10 * time.Second,
+			Diagnostics: openWatchdogLog(),
+			Remount: func() error {
+				lazyUnmount(mp)
+				s, err := sisuFS.Mount(mp)
+				if err != nil {
+					return err
+				}
+				serverMu.Lock()
+				server = s
+				serverMu.Unlock()
+				return nil
+			},
+		}
+		go w.Run(bgCtx)
+	}
+	go sisuFS.RefreshPins(bgCtx, fs.PinRefreshInterval)
+	go sisuFS.WarmUp(bgCtx)
+	if cfg.Index != nil {
+		go newIndexer(userCfg.Index, sisuFS, cfg.Index).Run(bgCtx)
+	}
+	idle := make(chan time.Duration, 1)
+	if idleAfter > 0 {
+		go sisuFS.WatchIdle(bgCtx, idleAfter, func(d time.Duration) {
+			if noShell {
+				select {
+				case idle <- d:
+				default:
+				}
+				return
+			}
+			fmt.Fprintf(os.Stderr, "\nsisu: %s hasn't been used for %s; type 'exit' to unmount it\n", mp, d.Round(time.Second))
+		})
+	}
+
+	if noShell {
+		serveUntilSignal(bgCtx, mp, idle)
+	} else {
+		fmt.Println("\nMounted! Opening new shell. Type 'exit' to unmount.")
+		fmt.Println()
+
+		shellCmd.Stdin = os.Stdin
+		shellCmd.Stdout = os.Stdout
+		shellCmd.Stderr = os.Stderr
+
+		shellCmd.Run() // ignore exit status - it's just the shell's last command status
+	}
 
 	fmt.Println("\nUnmounting...")
-	server.Unmount()
+	stopBackground()
+	serverMu.Lock()
+	if err := server.Unmount(); err != nil {
+		// Still in use, e.g. by another container; detach it anyway
+		lazyUnmount(mp)
+	}
+	serverMu.Unlock()
 	fmt.Println("Done.")
 
 	return nil
 }
 
+// serveUntilSignal keeps the mount up until SIGINT or SIGTERM, or until it
+// has gone unused for --idle-timeout, serving /healthz meanwhile if running
+// in the foreground
+func serveUntilSignal(ctx context.Context, mp string, idle <-chan time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if foreground && healthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", &fs.Health{Mountpoint: mp, Timeout: 5 * time.Second})
+		srv := &http.Server{Addr: healthAddr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("health endpoint: %v", err)
+			}
+		}()
+		defer srv.Shutdown(ctx)
+		log.Printf("Serving /healthz on %s", healthAddr)
+	}
+
+	log.Printf("Mounted at %s; send SIGTERM or press Ctrl-C to unmount", mp)
+	select {
+	case sig := <-signals:
+		log.Printf("Received %s", sig)
+	case d := <-idle:
+		log.Printf("No activity for %s", d.Round(time.Second))
+	}
+}
+
+// changeJournal returns the local journal of observed changes
+func changeJournal() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sisu", "changes.log")
+}
+
+// fsConfig applies the user config and command-line flags to the provider
+// settings and returns the filesystem configuration
+func fsConfig(userCfg *config.Config) (fs.Config, error) {
+	if maxEntries == 0 {
+		maxEntries = userCfg.MaxEntries
+	}
+	if maxEntries > 0 {
+		provider.MaxEntries = maxEntries
+	}
+	provider.SSMAutoAdvancedTier = userCfg.SSMAutoAdvancedTier
+	provider.SSMExactValues = userCfg.SSMExactValues || userCfg.RoundTrip
+	if err := applySSMParameters(userCfg.SSMParameters); err != nil {
+		return fs.Config{}, fmt.Errorf("invalid ssm_parameters in %s: %w", configPath, err)
+	}
+
+	for _, ep := range userCfg.Endpoints {
+		if err := ep.Validate(); err != nil {
+			return fs.Config{}, fmt.Errorf("invalid endpoints in %s: %w", configPath, err)
+		}
+	}
+
+	var profiles []string
+	for profile, c := range userCfg.Credentials {
+		creds, err := provider.NewCredentials(c)
+		if err != nil {
+			return fs.Config{}, fmt.Errorf("invalid credentials for profile %s in %s: %w", profile, configPath, err)
+		}
+		provider.SetCredentials(profile, creds)
+		profiles = append(profiles, profile)
+	}
+
+	for _, h := range userCfg.Hooks {
+		if err := h.Validate(); err != nil {
+			return fs.Config{}, fmt.Errorf("invalid hooks in %s: %w", configPath, err)
+		}
+	}
+
+	render := make(map[string]provider.Format)
+	for key, name := range userCfg.Render {
+		// "*" for every service, or a service or one of its directories
+		service, _, _ := strings.Cut(key, "/")
+		if provider.ObjectStores[service] {
+			return fs.Config{}, fmt.Errorf("invalid render setting in %s: %s holds files, not generated documents", configPath, service)
+		}
+		format, err := provider.ParseFormat(name)
+		if err != nil {
+			return fs.Config{}, fmt.Errorf("invalid render setting in %s: %s: %w", configPath, key, err)
+		}
+		render[strings.TrimSuffix(key, "/")] = format
+	}
+
+	for _, glob := range userCfg.ProtectedProfiles {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fs.Config{}, fmt.Errorf("invalid protected_profiles in %s: %q: %w", configPath, glob, err)
+		}
+	}
+
+	for service, mode := range userCfg.Write {
+		if _, err := provider.WriteScope(service, string(mode)); err != nil {
+			return fs.Config{}, fmt.Errorf("invalid write setting in %s: %w", configPath, err)
+		}
+	}
+
+	cfg := fs.Config{
+		RateLimit:       rateLimit,
+		CaseInsensitive: caseFold || userCfg.CaseInsensitive,
+		Endpoints:       userCfg.Endpoints,
+		GCPProjects:     userCfg.GCP.Projects,
+		AzureSubs:       userCfg.Azure.Subscriptions,
+		HideDenied:      userCfg.HideDenied,
+		DirInfo:         userCfg.DirInfo,
+		Write:           userCfg.Write,
+		PinDir:          pinDir(),
+		Profiles:        profiles,
+		Hooks:           userCfg.Hooks,
+		Protected:       userCfg.ProtectedProfiles,
+		Render:          render,
+		RoundTrip:       userCfg.RoundTrip,
+		WarmUp:          userCfg.WarmUp,
+		Redact:          redact,
+	}
+	if userCfg.MaxReadMB != 0 {
+		cfg.MaxReadSize = int64(userCfg.MaxReadMB) << 20
+	}
+	if userCfg.ChangeJournal {
+		cfg.ChangeJournal = changeJournal()
+	}
+	if len(userCfg.Index.Paths) > 0 {
+		var err error
+		cfg.Index, err = index.Load(index.DefaultPath())
+		if err != nil {
+			return fs.Config{}, err
+		}
+	}
+	if len(userCfg.Writable) > 0 {
+		var err error
+		cfg.Writable, err = config.ParsePatterns(userCfg.Writable)
+		if err != nil {
+			return fs.Config{}, fmt.Errorf("invalid writable pattern in %s: %w", configPath, err)
+		}
+	}
+	if err := parseTimeouts(timeouts, &cfg); err != nil {
+		return fs.Config{}, err
+	}
+	return cfg, nil
+}
+
+// newIndexer returns an indexer keeping idx up to date with the paths in
+// the index config
+func newIndexer(c config.Index, tree index.Tree, idx *index.Index) *index.Indexer {
+	ix := &index.Indexer{
+		Tree:     tree,
+		Index:    idx,
+		File:     index.DefaultPath(),
+		Roots:    c.Paths,
+		Rate:     c.Rate,
+		Interval: c.Interval,
+	}
+	if ix.Rate <= 0 {
+		ix.Rate = index.DefaultRate
+	}
+	if ix.Interval <= 0 {
+		ix.Interval = index.DefaultInterval
+	}
+	return ix
+}
+
+// parseTimeouts applies --timeout specs of the form [service.]op=duration to cfg
+func parseTimeouts(specs []string, cfg *fs.Config) error {
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("invalid timeout %q: expected [service.]op=duration", spec)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", spec, err)
+		}
+
+		service, op, scoped := strings.Cut(key, ".")
+		if !scoped {
+			op = service
+			if err := cfg.Timeouts.Set(op, d); err != nil {
+				return fmt.Errorf("invalid timeout %q: %w", spec, err)
+			}
+			continue
+		}
+
+		if cfg.ServiceTimeouts == nil {
+			cfg.ServiceTimeouts = make(map[string]provider.Timeouts)
+		}
+		t := cfg.ServiceTimeouts[service]
+		if err := t.Set(op, d); err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", spec, err)
+		}
+		cfg.ServiceTimeouts[service] = t
+	}
+	return nil
+}
+
+// applySSMParameters turns ssm_parameters config entries into provider rules
+func applySSMParameters(params []config.SSMParameter) error {
+	for _, param := range params {
+		pattern, err := config.ParsePattern(param.Path)
+		if err != nil {
+			return err
+		}
+		if pattern.Service != "ssm" {
+			return fmt.Errorf("%s: not an SSM path", param.Path)
+		}
+		if _, err := provider.ParseSSMTier(param.Tier); err != nil {
+			return err
+		}
+		provider.SSMRules = append(provider.SSMRules, provider.SSMRule{
+			Match: func(path string) bool { return pattern.Match("ssm", path) },
+			Metadata: provider.SSMMetadata{
+				Tier:        param.Tier,
+				Description: param.Description,
+				Tags:        param.Tags,
+			},
+		})
+	}
+	return nil
+}
+
 func runStop(cmd *cobra.Command, args []string) error {
 	mp := mountpoint
 	if mp == "" {
@@ -155,20 +515,73 @@ func runStop(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no sisu mount found at %s", mp)
 	}
 
+	if forceStop {
+		if err := lazyUnmount(mp); err != nil {
+			return fmt.Errorf("failed to unmount: %w", err)
+		}
+		fmt.Println("Unmounted", mp)
+		return nil
+	}
+	users, err := mountUsers(mp)
+	if err != nil && debug {
+		log.Printf("Listing processes using %s: %v", mp, err)
+	}
+	if len(users) > 0 {
+		printMountUsers(os.Stderr, mp, users)
+		return fmt.Errorf("%s is busy: close those, or run 'sisu stop --force' to detach it anyway", mp)
+	}
 	return unmountDirect(mp)
 }
 
+// mountEntry is a mount in the system's mount table
+type mountEntry struct {
+	Point  string
+	FSType string // e.g. "fuse.sisu" on Linux
+	Source string // the fsname, "sisu" for sisu mounts
+}
+
+// isSisu reports whether the mount was made by sisu, which mounts with
+// fs.FSName as both its fsname and its FUSE subtype
+func (m mountEntry) isSisu() bool {
+	return m.Source == fs.FSName || strings.HasSuffix(m.FSType, "."+fs.FSName)
+}
+
+// isMounted reports whether a sisu mount is at exactly path
 func isMounted(path string) bool {
-	data, err := os.ReadFile("/proc/mounts")
+	mounts, err := mountTable()
 	if err != nil {
 		return false
 	}
-	return strings.Contains(string(data), filepath.Clean(path))
+	path = filepath.Clean(path)
+	for _, m := range mounts {
+		if m.Point == path && m.isSisu() {
+			return true
+		}
+	}
+	return false
+}
+
+// lazyUnmount detaches a mount even if it is busy or its server is hung
+func lazyUnmount(path string) error {
+	return unmountCommand(path, true).Run()
+}
+
+// openWatchdogLog opens ~/.sisu/watchdog.log for mount diagnostics, or
+// returns nil if it can't be written
+func openWatchdogLog() io.Writer {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(home, ".sisu", "watchdog.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil
+	}
+	return f
 }
 
 func unmountDirect(path string) error {
-	cmd := exec.Command("fusermount", "-u", path)
-	if err := cmd.Run(); err != nil {
+	if err := unmountCommand(path, false).Run(); err != nil {
 		return fmt.Errorf("failed to unmount: %w", err)
 	}
 	fmt.Println("Unmounted", path)
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ssmDecrypt   bool
+	ssmPrefix    string
+	ssmDryRun    bool
+	ssmOverwrite bool
+)
+
+var ssmCmd = &cobra.Command{
+	Use:   "ssm",
+	Short: "Copy SSM parameter trees between environments",
+	Long: `Export a parameter tree to JSON and import it under another path, in the
+same or another profile and region:
+
+  sisu ssm export /app/prod --with-decryption > params.json
+  sisu ssm import params.json --prefix /app/staging --dry-run
+  sisu --profile staging ssm import params.json --prefix /app/staging
+
+Parameters keep their type, so SecureStrings are re-encrypted with the
+target account's default key. Without --with-decryption their values are
+left out of the export and skipped on import. Existing parameters with a
+different value are left alone unless --overwrite is given. With --redact,
+SecureString values are always left out and access keys in the others are
+masked.`,
+}
+
+var ssmExportCmd = &cobra.Command{
+	Use:   "export <path>",
+	Short: "Write every parameter below path as JSON to stdout",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSSMExport,
+}
+
+var ssmImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Create the parameters of an export below --prefix",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSSMImport,
+}
+
+func init() {
+	ssmExportCmd.Flags().BoolVar(&ssmDecrypt, "with-decryption", false, "Include SecureString values in plain text")
+	ssmImportCmd.Flags().StringVar(&ssmPrefix, "prefix", "", "Path to import below, e.g. /app/staging")
+	ssmImportCmd.MarkFlagRequired("prefix")
+	ssmImportCmd.Flags().BoolVarP(&ssmDryRun, "dry-run", "n", false, "Show what would change without writing")
+	ssmImportCmd.Flags().BoolVar(&ssmOverwrite, "overwrite", false, "Replace existing parameters whose value differs")
+	ssmCmd.AddCommand(ssmExportCmd, ssmImportCmd)
+	rootCmd.AddCommand(ssmCmd)
+}
+
+func runSSMExport(cmd *cobra.Command, args []string) error {
+	// Loaded first: it sets the credentials the SSM provider is created with
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if _, err := fsConfig(userCfg); err != nil {
+		return err
+	}
+
+	p, err := provider.NewSSMProvider(profile, region)
+	if err != nil {
+		return err
+	}
+	export, err := p.Export(context.Background(), args[0], ssmDecrypt)
+	if err != nil {
+		return err
+	}
+	if redact {
+		export.Redact()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+func runSSMImport(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var export provider.SSMExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	// The write settings and ssm_parameters rules apply as they do in the mount
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+	allowed, err := provider.WriteScope("ssm", string(cfg.Write["ssm"]))
+	if err != nil {
+		return err
+	}
+
+	p, err := provider.NewSSMProvider(profile, region)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	steps, err := p.PlanImport(ctx, &export, ssmPrefix, ssmOverwrite)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, step := range steps {
+		path := strings.TrimPrefix(step.Name, "/")
+		if step.Changes() && (!allowed(path) || (cfg.Writable != nil && !cfg.Writable.Match("ssm", path))) {
+			fmt.Fprintf(os.Stderr, "%s: not writable per %s\n", step.Name, configPath)
+			failed++
+			continue
+		}
+		if ssmDryRun || !step.Changes() {
+			fmt.Printf("%-9s %s (%s)\n", step.Action, step.Name, step.Type)
+			continue
+		}
+		if err := p.ApplyImport(ctx, step); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", step.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%-9s %s (%s)\n", step.Action, step.Name, step.Type)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d parameters failed", failed, len(steps))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show mount status, AWS API usage and estimated cost",
+	RunE:  runStatus,
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	mp := mountpoint
+	if mp == "" {
+		mp = defaultMountpoint()
+	}
+
+	if !isMounted(mp) {
+		fmt.Println("Not mounted at", mp)
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(mp, fs.ControlDir, "stats.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read stats: %w", err)
+	}
+	var stats fs.Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return fmt.Errorf("failed to parse stats: %w", err)
+	}
+
+	fmt.Printf("Mounted at %s (up %s)\n", mp, time.Since(stats.MountedAt).Round(time.Second))
+	if wu := stats.WarmUp; wu != nil {
+		fmt.Printf("Warm-up: %d of %d service listings", wu.Done, wu.Total)
+		if wu.Failed > 0 {
+			fmt.Printf(", %d failed", wu.Failed)
+		}
+		if !wu.Finished.IsZero() {
+			fmt.Printf(", done in %s", wu.Finished.Sub(wu.Started).Round(time.Millisecond))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tCALLS\tERRORS\tAVG LATENCY")
+	for _, service := range sortedKeys(stats.Services) {
+		var calls, errors int64
+		var total time.Duration
+		for _, s := range stats.Services[service] {
+			calls += s.Calls
+			errors += s.Errors
+			total += s.Duration
+		}
+		avg := time.Duration(0)
+		if calls > 0 {
+			avg = total / time.Duration(calls)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", service, calls, errors, avg.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	if len(stats.Backoff) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "THROTTLED\tTHROTTLES\tLAST\tCACHE TTL")
+		for _, service := range sortedKeys(stats.Backoff) {
+			b := stats.Backoff[service]
+			fmt.Fprintf(w, "%s\t%d\t%s ago\tx%g\n", service, b.Throttles, time.Since(b.LastThrottle).Round(time.Second), b.Factor)
+		}
+		w.Flush()
+	}
+
+	if len(stats.Cache) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CACHE\tENTRIES\tMEMORY\tUNCOMPRESSED")
+		var total provider.CacheStats
+		for _, dir := range sortedKeys(stats.Cache) {
+			c := stats.Cache[dir]
+			total = total.Add(c)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", dir, c.Entries, fs.FormatSize(c.Bytes), fs.FormatSize(c.RawBytes))
+		}
+		fmt.Fprintf(w, "total\t%d\t%s\t%s\n", total.Entries, fs.FormatSize(total.Bytes), fs.FormatSize(total.RawBytes))
+		w.Flush()
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "AWS API\tREQUESTS")
+	for _, service := range sortedKeys(stats.APICalls) {
+		ops := stats.APICalls[service]
+		for _, op := range sortedKeys(ops) {
+			fmt.Fprintf(w, "%s:%s\t%d\n", service, op, ops[op])
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("\nEstimated request cost: $%.6f\n", stats.EstimatedCostUSD)
+	fmt.Println(stats.CostNote)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
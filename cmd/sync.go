@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/semonte/sisu/internal/bulk"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDelete   bool
+	syncDryRun   bool
+	syncParallel int
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <src> <dest>",
+	Short: "Copy a local directory to an S3 prefix or back, transferring only what changed",
+	Long: `sync copies files that are missing or differ between a local directory and
+an S3 prefix, given as its path in the mount. It talks to S3 directly, so it
+is much faster than rsync through the mount:
+
+  sisu sync ./site prod/global/s3/my-bucket/www
+  sisu sync prod/global/s3/my-bucket/www ./site --delete --dry-run
+
+Files are compared by size and MD5 (the object's ETag); objects uploaded in
+parts or encrypted with SSE-KMS or SSE-C, whose ETag isn't their MD5, are
+compared by size only. --delete removes files that exist only at
+the destination; in a profile under protected_profiles it needs sisu unlock
+first, as rm in the mount does.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncDelete, "delete", false, "Remove destination files missing from the source")
+	syncCmd.Flags().BoolVarP(&syncDryRun, "dry-run", "n", false, "Show what would be done without changing anything")
+	syncCmd.Flags().IntVarP(&syncParallel, "parallel", "p", 8, "Number of transfers run at once")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	// Loaded first: it sets the credentials the S3 provider is created with
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := fsConfig(userCfg)
+	if err != nil {
+		return err
+	}
+
+	src, srcIsS3, err := syncStore(args[0])
+	if err != nil {
+		return err
+	}
+	dst, dstIsS3, err := syncStore(args[1])
+	if err != nil {
+		return err
+	}
+	if srcIsS3 == dstIsS3 {
+		return fmt.Errorf("sync needs one local directory and one S3 path like <profile>/global/s3/<bucket>")
+	}
+	if syncDelete && dstIsS3 {
+		// Deletes go through the tree, so protected_profiles and hooks
+		// apply to them as in the mount
+		tree, err := fs.NewSisuFS(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize: %w", err)
+		}
+		if err := armFromMount(tree); err != nil {
+			return err
+		}
+		s3 := dst.(bulk.S3Prefix)
+		s3.Remover = tree
+		dst = s3
+	}
+
+	op := &bulk.Sync{Src: src, Dst: dst, Delete: syncDelete}
+	names, err := op.Plan()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("Already in sync")
+		return nil
+	}
+
+	total, denied := len(names), 0
+	if dstIsS3 {
+		names = writableSyncNames(cfg, dst.(bulk.S3Prefix), names)
+		denied = total - len(names)
+	}
+	if failed := denied + bulk.Run(names, op, syncParallel, syncDryRun, os.Stdout, os.Stderr); failed > 0 {
+		return fmt.Errorf("%d of %d transfers failed", failed, total)
+	}
+	return nil
+}
+
+// syncStore returns the store for a sync argument, reporting whether it
+// is an S3 path in the mount rather than a local directory. The config must
+// have been applied with fsConfig, so the profile's credentials are used.
+func syncStore(arg string) (bulk.Store, bool, error) {
+	profile, bucket, prefix, ok := parseS3MountPath(arg)
+	if !ok {
+		return bulk.LocalDir(arg), false, nil
+	}
+	p, err := provider.NewS3Provider(profile, "")
+	if err != nil {
+		return nil, false, err
+	}
+	path := strings.Join([]string{profile, "global", "s3", bucket}, "/")
+	if prefix != "" {
+		path += "/" + prefix
+	}
+	return bulk.S3Prefix{Provider: p, Bucket: bucket, Prefix: prefix, Path: path}, true, nil
+}
+
+// parseS3MountPath splits a mount path like prod/global/s3/bucket/dir, or
+// the same path below the mountpoint, into its profile, bucket and prefix.
+// Anything else is a local path.
+func parseS3MountPath(arg string) (profile, bucket, prefix string, ok bool) {
+	parts := strings.SplitN(strings.Trim(mountRelative(arg), "/"), "/", 5)
+	if len(parts) < 4 || parts[1] != "global" || parts[2] != "s3" || parts[3] == "" {
+		return "", "", "", false
+	}
+	if len(parts) == 5 {
+		prefix = strings.Trim(parts[4], "/")
+	}
+	return parts[0], parts[3], prefix, true
+}
+
+// mountRelative returns a path below the mountpoint relative to the mount
+// root, and any other path as is
+func mountRelative(arg string) string {
+	mp := mountpoint
+	if mp == "" {
+		mp = defaultMountpoint()
+	}
+	if abs, err := filepath.Abs(arg); err == nil {
+		if rel, err := filepath.Rel(mp, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return arg
+}
+
+// writableSyncNames drops names the write settings don't allow changing in
+// dst, reporting each, and returns the rest
+func writableSyncNames(cfg fs.Config, dst bulk.S3Prefix, names []string) []string {
+	allowed, _ := provider.WriteScope("s3", string(cfg.Write["s3"]))
+
+	var writable []string
+	for _, name := range names {
+		path := dst.Bucket + "/" + name
+		if dst.Prefix != "" {
+			path = dst.Bucket + "/" + dst.Prefix + "/" + name
+		}
+		if !allowed(path) || (cfg.Writable != nil && !cfg.Writable.Match("s3", path)) {
+			fmt.Fprintf(os.Stderr, "%s/%s: not writable per %s\n", dst.Path, name, configPath)
+			continue
+		}
+		writable = append(writable, name)
+	}
+	return writable
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var traceJSON bool
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Show traces of provider calls recorded with --debug",
+	Long: `While mounted with --debug, every provider call that misses the cache
+writes a trace into ~/.sisu/traces: the operation and path, and each AWS
+request it sent with its duration, HTTP status and request ID. The newest
+200 are kept. Attach the output of
+
+  sisu trace last
+
+to bug reports; AWS support can look requests up by their IDs.`,
+}
+
+var traceLastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Show the most recent trace",
+	Args:  cobra.NoArgs,
+	RunE:  runTraceLast,
+}
+
+func init() {
+	traceLastCmd.Flags().BoolVar(&traceJSON, "json", false, "Print the trace file as is")
+	traceCmd.AddCommand(traceLastCmd)
+	rootCmd.AddCommand(traceCmd)
+}
+
+// traceDir returns where traces are written with --debug
+func traceDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sisu", "traces")
+}
+
+func runTraceLast(cmd *cobra.Command, args []string) error {
+	tr, file, err := provider.LastTrace(traceDir())
+	if err != nil {
+		return err
+	}
+	if traceJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tr)
+	}
+
+	fmt.Printf("%s %s %q in %s, %s\n", tr.Provider, tr.Operation, tr.Path, tr.Duration.Round(time.Millisecond), tr.Time.Format(time.RFC3339))
+	if tr.Error != "" {
+		fmt.Println("Error:", tr.Error)
+	}
+	fmt.Println("Trace:", file)
+	if len(tr.Calls) == 0 {
+		fmt.Println("\nNo AWS requests were sent.")
+		return nil
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST\tSTATUS\tDURATION\tREQUEST ID\tERROR")
+	for _, c := range tr.Calls {
+		status := "-"
+		if c.Status != 0 {
+			status = fmt.Sprint(c.Status)
+		}
+		fmt.Fprintf(w, "%s:%s\t%s\t%s\t%s\t%s\n", c.Service, c.Operation, status, c.Duration.Round(time.Millisecond), c.RequestID, c.Error)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/semonte/sisu/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+var unlockFor time.Duration
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock <profile>",
+	Short: "Allow deletes in a protected profile for a while",
+	Long: `Profiles listed under protected_profiles in the config refuse deletes
+(rm, rmdir, mv) with "Operation not permitted" until they are unlocked:
+
+  sisu unlock prod --for 10m
+  sisu unlock prod --for 0      # lock again now
+
+This writes to .sisu/arm in the running mount; cat it to see which
+profiles are unlocked and until when. sisu bulk rm and sisu sync --delete
+read it too, so an unlock covers them while the mount runs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runUnlock,
+}
+
+func init() {
+	unlockCmd.Flags().DurationVar(&unlockFor, "for", fs.DefaultArmDuration, "How long deletes are allowed (0 locks the profile again)")
+	rootCmd.AddCommand(unlockCmd)
+}
+
+func runUnlock(cmd *cobra.Command, args []string) error {
+	mp := mountpoint
+	if mp == "" {
+		mp = defaultMountpoint()
+	}
+	if !isMounted(mp) {
+		return fmt.Errorf("not mounted at %s", mp)
+	}
+
+	line := fmt.Sprintf("%s %s\n", args[0], unlockFor)
+	if err := os.WriteFile(filepath.Join(mp, fs.ControlDir, fs.ArmFile), []byte(line), 0644); err != nil {
+		return fmt.Errorf("unlocking %s (is it in protected_profiles?): %w", args[0], err)
+	}
+	if unlockFor <= 0 {
+		fmt.Printf("Deletes in %s are refused again\n", args[0])
+		return nil
+	}
+	fmt.Printf("Deletes in %s allowed until %s\n", args[0], time.Now().Add(unlockFor).Format("15:04:05"))
+	return nil
+}
+
+// armFromMount unlocks tree's protected profiles as the running mount has
+// them unlocked. Without a mount, they stay locked.
+func armFromMount(tree *fs.SisuFS) error {
+	mp := mountpoint
+	if mp == "" {
+		mp = defaultMountpoint()
+	}
+	if !isMounted(mp) {
+		return nil
+	}
+	path := filepath.Join(mp, fs.ControlDir, fs.ArmFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := tree.ArmFrom(data); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
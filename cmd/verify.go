@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/semonte/sisu/internal/bulk"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <local> <s3path>",
+	Short: "Check local files match their S3 objects by checksum",
+	Long: `verify compares every file below a local directory with the object of the
+same name below an S3 path in the mount, without downloading anything:
+
+  sisu verify ./backup prod/global/s3/my-bucket/backup
+
+Files are compared by MD5 against the ETag, or for objects uploaded in parts
+or encrypted with SSE-KMS or SSE-C, whose ETag isn't their MD5, by SHA-256
+if they were uploaded with a full-object checksum. Objects with neither
+are compared by size and reported as "size only". The same checksums are
+readable on mounted objects as user.sisu.etag, user.sisu.md5 and
+user.sisu.sha256 extended attributes.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	// Loaded first: it sets the credentials the S3 provider is created with
+	userCfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if _, err := fsConfig(userCfg); err != nil {
+		return err
+	}
+
+	remote, isS3, err := syncStore(args[1])
+	if err != nil {
+		return err
+	}
+	if !isS3 {
+		return fmt.Errorf("%s is not an S3 path like <profile>/global/s3/<bucket>", args[1])
+	}
+
+	results, err := bulk.Verify(bulk.LocalDir(args[0]), remote)
+	if err != nil {
+		return err
+	}
+	var bad int
+	for _, r := range results {
+		fmt.Printf("%-9s %s\n", r.Status, r.Name)
+		if r.Status == bulk.VerifyMismatch || r.Status == bulk.VerifyMissing {
+			bad++
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d of %d files differ or are missing", bad, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+// Package browse is an interactive terminal browser over the sisu tree,
+// with fuzzy search and a preview pane, for navigating faster than cd/ls.
+package browse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Item is an entry of a directory
+type Item struct {
+	Name  string
+	IsDir bool
+}
+
+// Source is the tree being browsed, addressed by slash-separated paths
+// relative to its root ("" is the root)
+type Source interface {
+	List(dir string) ([]Item, error)
+	// Read returns up to limit bytes from the start of a file
+	Read(file string, limit int) ([]byte, error)
+	Delete(file string) error
+}
+
+// previewLimit caps how much of a file is fetched for the preview pane
+const previewLimit = 64 * 1024
+
+const helpLine = "↑↓ move  → open  ← back  / search  y copy path  c copy contents  d delete  q quit"
+
+// Browser holds the navigation state. It is driven by HandleKey and drawn
+// with Render, so it can run against any terminal (or none, in tests).
+type Browser struct {
+	src  Source
+	dir  string
+	all  []Item // entries of dir
+	list []Item // entries matching the filter
+
+	cursor int
+	offset int // index of the first row shown
+
+	filter    string
+	filtering bool // keys edit the filter
+	confirm   bool // waiting for y to confirm a delete
+	status    string
+
+	preview    []string
+	previewFor string
+
+	// Copy puts text on the clipboard
+	Copy func(text string)
+	// Quit is set once the user asked to leave
+	Quit bool
+}
+
+// New returns a browser showing dir
+func New(src Source, dir string) *Browser {
+	b := &Browser{src: src, Copy: func(string) {}}
+	b.open(strings.Trim(dir, "/"))
+	return b
+}
+
+// Dir returns the directory being shown
+func (b *Browser) Dir() string {
+	return b.dir
+}
+
+// open lists dir and makes it current, staying put if it can't be listed
+func (b *Browser) open(dir string) bool {
+	items, err := b.src.List(dir)
+	if err != nil {
+		b.status = fmt.Sprintf("%s: %v", displayPath(dir), err)
+		return false
+	}
+	b.dir = dir
+	b.all = items
+	b.filter = ""
+	b.filtering = false
+	b.list = items
+	b.cursor, b.offset = 0, 0
+	b.status = ""
+	b.previewFor = "\x00" // force a refresh
+	return true
+}
+
+// selected returns the item under the cursor
+func (b *Browser) selected() (Item, bool) {
+	if b.cursor < 0 || b.cursor >= len(b.list) {
+		return Item{}, false
+	}
+	return b.list[b.cursor], true
+}
+
+func (b *Browser) selectedPath() (string, bool) {
+	item, ok := b.selected()
+	if !ok {
+		return "", false
+	}
+	return join(b.dir, item.Name), true
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func displayPath(p string) string {
+	return "/" + p
+}
+
+// HandleKey applies a key press
+func (b *Browser) HandleKey(k Key) {
+	if b.confirm {
+		b.confirm = false
+		if k.Code == KeyRune && (k.Rune == 'y' || k.Rune == 'Y') {
+			b.deleteSelected()
+		} else {
+			b.status = "Delete cancelled"
+		}
+		return
+	}
+	b.status = ""
+
+	if b.filtering {
+		switch k.Code {
+		case KeyRune:
+			b.setFilter(b.filter + string(k.Rune))
+			return
+		case KeyBackspace:
+			if b.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(b.filter)
+				b.setFilter(b.filter[:len(b.filter)-size])
+			}
+			return
+		case KeyEsc:
+			b.filtering = false
+			b.setFilter("")
+			return
+		case KeyEnter:
+			b.filtering = false
+			return
+		}
+	}
+
+	switch k.Code {
+	case KeyUp:
+		b.move(-1)
+	case KeyDown:
+		b.move(1)
+	case KeyRight, KeyEnter:
+		b.enter()
+	case KeyLeft, KeyBackspace:
+		b.back()
+	case KeyEsc:
+		b.setFilter("")
+	case KeyCtrlC:
+		b.Quit = true
+	case KeyRune:
+		switch k.Rune {
+		case 'k':
+			b.move(-1)
+		case 'j':
+			b.move(1)
+		case 'l':
+			b.enter()
+		case 'h':
+			b.back()
+		case '/':
+			b.filtering = true
+		case 'y':
+			if p, ok := b.selectedPath(); ok {
+				b.Copy(displayPath(p))
+				b.status = "Copied path " + displayPath(p)
+			}
+		case 'c':
+			b.copyContents()
+		case 'd':
+			if item, ok := b.selected(); ok && !item.IsDir {
+				b.confirm = true
+				b.status = fmt.Sprintf("Delete %s? (y/N)", item.Name)
+			}
+		case 'q':
+			b.Quit = true
+		}
+	}
+}
+
+func (b *Browser) setFilter(filter string) {
+	b.filter = filter
+	b.list = fuzzyFilter(b.all, filter)
+	b.cursor, b.offset = 0, 0
+}
+
+func (b *Browser) move(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.list) {
+		b.cursor = len(b.list) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+func (b *Browser) enter() {
+	item, ok := b.selected()
+	if ok && item.IsDir {
+		b.open(join(b.dir, item.Name))
+	}
+}
+
+func (b *Browser) back() {
+	if b.dir == "" {
+		return
+	}
+	parent, child := path.Split(b.dir)
+	if !b.open(strings.TrimSuffix(parent, "/")) {
+		return
+	}
+	for i, item := range b.list {
+		if item.Name == child {
+			b.cursor = i
+		}
+	}
+}
+
+func (b *Browser) copyContents() {
+	item, ok := b.selected()
+	if !ok || item.IsDir {
+		return
+	}
+	p := join(b.dir, item.Name)
+	data, err := b.src.Read(p, previewLimit)
+	if err != nil {
+		b.status = fmt.Sprintf("%s: %v", item.Name, err)
+		return
+	}
+	b.Copy(string(data))
+	b.status = fmt.Sprintf("Copied %d bytes of %s", len(data), item.Name)
+}
+
+func (b *Browser) deleteSelected() {
+	p, ok := b.selectedPath()
+	if !ok {
+		return
+	}
+	if err := b.src.Delete(p); err != nil {
+		b.status = fmt.Sprintf("Delete %s: %v", path.Base(p), err)
+		return
+	}
+	cursor, filter := b.cursor, b.filter
+	b.open(b.dir)
+	b.setFilter(filter)
+	b.cursor = cursor
+	b.move(0)
+	b.status = "Deleted " + displayPath(p)
+}
+
+// updatePreview loads the preview of the selected file if it changed
+func (b *Browser) updatePreview() {
+	item, ok := b.selected()
+	key := ""
+	if ok {
+		key = join(b.dir, item.Name)
+	}
+	if key == b.previewFor {
+		return
+	}
+	b.previewFor = key
+
+	switch {
+	case !ok:
+		b.preview = nil
+	case item.IsDir:
+		b.preview = []string{"(directory)"}
+	default:
+		data, err := b.src.Read(key, previewLimit)
+		if err != nil {
+			b.preview = []string{"error: " + err.Error()}
+			return
+		}
+		b.preview = previewLines(data)
+	}
+}
+
+// previewLines formats file content for the preview pane, indenting JSON
+func previewLines(data []byte) []string {
+	if !utf8.Valid(data) {
+		return []string{"(binary file)"}
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") == nil {
+		data = out.Bytes()
+	}
+	text := strings.ReplaceAll(string(data), "\t", "    ")
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+// Render draws the browser for a terminal of the given size
+func (b *Browser) Render(width, height int) string {
+	b.updatePreview()
+
+	rows := height - 3 // title, separator and status lines
+	if rows < 1 {
+		rows = 1
+	}
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+rows {
+		b.offset = b.cursor - rows + 1
+	}
+
+	left := width * 2 / 5
+	if left < 20 {
+		left = min(20, width)
+	}
+	right := width - left - 3
+
+	var s strings.Builder
+	s.WriteString(clearScreen)
+	s.WriteString(bold + fit("sisu browse  "+displayPath(b.dir), width) + reset + "\r\n")
+	s.WriteString(strings.Repeat("─", width) + "\r\n")
+
+	for row := 0; row < rows; row++ {
+		i := b.offset + row
+		line := ""
+		if i < len(b.list) {
+			name := b.list[i].Name
+			if b.list[i].IsDir {
+				name += "/"
+			}
+			line = fit(" "+name, left)
+			if i == b.cursor {
+				line = inverse + line + reset
+			} else if b.list[i].IsDir {
+				line = blue + line + reset
+			}
+		} else {
+			line = fit("", left)
+		}
+		s.WriteString(line)
+		if right > 0 {
+			p := ""
+			if row < len(b.preview) {
+				p = b.preview[row]
+			}
+			s.WriteString(" │ " + fit(p, right))
+		}
+		s.WriteString("\r\n")
+	}
+
+	switch {
+	case b.filtering:
+		s.WriteString(fit("/"+b.filter, width))
+	case b.status != "":
+		s.WriteString(fit(b.status, width))
+	case b.filter != "":
+		s.WriteString(fit(fmt.Sprintf("filter: %s (%d of %d)   esc clears", b.filter, len(b.list), len(b.all)), width))
+	default:
+		s.WriteString(dim + fit(helpLine, width) + reset)
+	}
+	return s.String()
+}
+
+// fit pads or truncates s to exactly width runes. Control characters are
+// replaced so file content can't move the cursor or change colors.
+func fit(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '?'
+		}
+		return r
+	}, s)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		if width <= 1 {
+			return string(r[:width])
+		}
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,186 @@
+package browse
+
+import (
+	"bufio"
+	"errors"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeSource is an in-memory tree of files; directories are implied by paths
+type fakeSource struct {
+	files map[string]string
+}
+
+func (s *fakeSource) List(dir string) ([]Item, error) {
+	seen := make(map[string]bool)
+	var items []Item
+	for p := range s.files {
+		rel := p
+		if dir != "" {
+			if !strings.HasPrefix(p, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, dir+"/")
+		}
+		name, _, isDir := strings.Cut(rel, "/")
+		if !seen[name] {
+			seen[name] = true
+			items = append(items, Item{Name: name, IsDir: isDir})
+		}
+	}
+	if len(items) == 0 && dir != "" {
+		return nil, errors.New("no such directory")
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (s *fakeSource) Read(file string, limit int) ([]byte, error) {
+	data, ok := s.files[file]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(data), nil
+}
+
+func (s *fakeSource) Delete(file string) error {
+	if path.Base(file) == "locked" {
+		return errors.New("read-only file system")
+	}
+	delete(s.files, file)
+	return nil
+}
+
+func newTestBrowser() *Browser {
+	return New(&fakeSource{files: map[string]string{
+		"prod/us-east-1/ssm/app/database-url": "postgres://db\n",
+		"prod/us-east-1/ssm/app/api-key":      "secret\n",
+		"prod/us-east-1/ssm/app/locked":       "x\n",
+		"prod/global/iam/roles/admin.json":    `{"name":"admin"}`,
+		"dev/global/s3/bucket/readme.txt":     "hi\n",
+	}}, "")
+}
+
+func keys(b *Browser, s string) {
+	for _, r := range s {
+		b.HandleKey(Key{Code: KeyRune, Rune: r})
+	}
+}
+
+func names(items []Item) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = item.Name
+	}
+	return out
+}
+
+func TestBrowserNavigation(t *testing.T) {
+	b := newTestBrowser()
+	if got := names(b.list); !reflect.DeepEqual(got, []string{"dev", "prod"}) {
+		t.Fatalf("root = %v", got)
+	}
+
+	keys(b, "j")
+	b.HandleKey(Key{Code: KeyEnter})
+	keys(b, "jl")
+	if b.Dir() != "prod/us-east-1" {
+		t.Fatalf("dir = %q", b.Dir())
+	}
+
+	b.HandleKey(Key{Code: KeyLeft})
+	if b.Dir() != "prod" {
+		t.Fatalf("dir after back = %q", b.Dir())
+	}
+	if item, _ := b.selected(); item.Name != "us-east-1" {
+		t.Errorf("cursor after back on %q, want the directory we came from", item.Name)
+	}
+}
+
+func TestBrowserFilter(t *testing.T) {
+	b := newTestBrowser()
+	b.open("prod/us-east-1/ssm/app")
+
+	keys(b, "/dburl")
+	if got := names(b.list); !reflect.DeepEqual(got, []string{"database-url"}) {
+		t.Errorf("filtered = %v", got)
+	}
+	// While filtering, letters are part of the search rather than commands
+	keys(b, "q")
+	if b.Quit {
+		t.Error("q quit while typing a filter")
+	}
+
+	b.HandleKey(Key{Code: KeyEsc})
+	if len(b.list) != 3 {
+		t.Errorf("after esc = %v", names(b.list))
+	}
+}
+
+func TestBrowserPreviewIndentsJSON(t *testing.T) {
+	b := newTestBrowser()
+	b.open("prod/global/iam/roles")
+
+	out := b.Render(100, 10)
+	if !strings.Contains(out, `"name": "admin"`) {
+		t.Errorf("preview not indented:\n%s", out)
+	}
+}
+
+func TestBrowserDelete(t *testing.T) {
+	b := newTestBrowser()
+	var copied string
+	b.Copy = func(s string) { copied = s }
+	b.open("prod/us-east-1/ssm/app")
+
+	keys(b, "y")
+	if copied != "/prod/us-east-1/ssm/app/api-key" {
+		t.Errorf("copied %q", copied)
+	}
+
+	keys(b, "dn")
+	if len(b.list) != 3 {
+		t.Fatal("delete happened without confirmation")
+	}
+	keys(b, "dy")
+	if got := names(b.list); !reflect.DeepEqual(got, []string{"database-url", "locked"}) {
+		t.Errorf("after delete = %v", got)
+	}
+
+	keys(b, "jdy")
+	if !strings.Contains(b.status, "read-only") {
+		t.Errorf("status = %q, want the delete error", b.status)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\x1b[A\x1b[1;5C\r\x7f"))
+	want := []Key{
+		{Code: KeyRune, Rune: 'a'},
+		{Code: KeyUp},
+		{Code: KeyRight},
+		{Code: KeyEnter},
+		{Code: KeyBackspace},
+	}
+	for _, w := range want {
+		k, err := readKey(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != w {
+			t.Errorf("readKey = %+v, want %+v", k, w)
+		}
+	}
+}
+
+func TestFuzzyFilterRanksWordStarts(t *testing.T) {
+	items := []Item{{Name: "xlambda-role"}, {Name: "lambda"}, {Name: "other"}}
+	got := names(fuzzyFilter(items, "lam"))
+	if !reflect.DeepEqual(got, []string{"lambda", "xlambda-role"}) {
+		t.Errorf("fuzzyFilter = %v", got)
+	}
+}
@@ -0,0 +1,59 @@
+package browse
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzyScore reports whether the characters of pattern appear in s in
+// order, ignoring case, and scores the match: runs of consecutive matches
+// and matches at the start of a word score higher
+func fuzzyScore(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(strings.ToLower(pattern))
+	score, run := 0, 0
+	prev := ' '
+	i := 0
+	for _, r := range s {
+		if i < len(p) && unicode.ToLower(r) == p[i] {
+			i++
+			run++
+			score += run
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+		} else {
+			run = 0
+		}
+		prev = r
+	}
+	return score, i == len(p)
+}
+
+// fuzzyFilter returns the items matching pattern, best matches first and
+// otherwise in listing order
+func fuzzyFilter(items []Item, pattern string) []Item {
+	if pattern == "" {
+		return items
+	}
+	type scored struct {
+		item  Item
+		score int
+	}
+	var matches []scored
+	for _, item := range items {
+		if score, ok := fuzzyScore(pattern, item.Name); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	out := make([]Item, len(matches))
+	for i, m := range matches {
+		out[i] = m.item
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package browse
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// KeyCode identifies a key press
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota // a printable character, in Key.Rune
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyEnter
+	KeyBackspace
+	KeyEsc
+	KeyCtrlC
+)
+
+// Key is a decoded key press
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// Terminal escape sequences
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	altScreen   = "\x1b[?1049h\x1b[?25l"
+	mainScreen  = "\x1b[?25h\x1b[?1049l"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	inverse     = "\x1b[7m"
+	blue        = "\x1b[34m"
+	reset       = "\x1b[0m"
+)
+
+// readKey decodes the next key press from a terminal in raw mode. Escape
+// sequences the browser doesn't use are skipped.
+func readKey(r *bufio.Reader) (Key, error) {
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return Key{}, err
+		}
+		switch c {
+		case '\r', '\n':
+			return Key{Code: KeyEnter}, nil
+		case 127, '\b':
+			return Key{Code: KeyBackspace}, nil
+		case 3:
+			return Key{Code: KeyCtrlC}, nil
+		case 0x1b:
+			// A lone escape is the Esc key; arrows arrive as ESC [ A..D
+			if r.Buffered() == 0 {
+				return Key{Code: KeyEsc}, nil
+			}
+			if next, _ := r.Peek(1); next[0] != '[' && next[0] != 'O' {
+				return Key{Code: KeyEsc}, nil
+			}
+			r.ReadByte()
+			final, err := r.ReadByte()
+			if err != nil {
+				return Key{}, err
+			}
+			// Skip parameters such as the 1;5 in ESC [ 1 ; 5 A
+			for (final >= '0' && final <= '9') || final == ';' {
+				if final, err = r.ReadByte(); err != nil {
+					return Key{}, err
+				}
+			}
+			switch final {
+			case 'A':
+				return Key{Code: KeyUp}, nil
+			case 'B':
+				return Key{Code: KeyDown}, nil
+			case 'C':
+				return Key{Code: KeyRight}, nil
+			case 'D':
+				return Key{Code: KeyLeft}, nil
+			}
+		default:
+			if c >= ' ' {
+				return Key{Code: KeyRune, Rune: c}, nil
+			}
+		}
+	}
+}
+
+// Run shows b on the terminal until the user quits
+func Run(b *Browser) error {
+	in, out := os.Stdin, os.Stdout
+	fd := int(in.Fd())
+
+	saved, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return fmt.Errorf("browse needs an interactive terminal: %w", err)
+	}
+	raw := *saved
+	raw.Iflag &^= unix.ICRNL | unix.IXON | unix.ISTRIP | unix.INLCR | unix.IGNCR
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return err
+	}
+	defer unix.IoctlSetTermios(fd, ioctlSetTermios, saved)
+
+	io.WriteString(out, altScreen)
+	defer io.WriteString(out, mainScreen)
+
+	b.Copy = func(text string) { copyOSC52(out, text) }
+
+	keys := bufio.NewReader(in)
+	for !b.Quit {
+		width, height := 80, 24
+		if ws, err := unix.IoctlGetWinsize(int(out.Fd()), unix.TIOCGWINSZ); err == nil && ws.Col > 0 {
+			width, height = int(ws.Col), int(ws.Row)
+		}
+		io.WriteString(out, b.Render(width, height))
+
+		k, err := readKey(keys)
+		if err != nil {
+			return err
+		}
+		b.HandleKey(k)
+	}
+	return nil
+}
+
+// copyOSC52 sets the clipboard with the OSC 52 escape sequence, which
+// most terminals support, including over SSH
+func copyOSC52(w io.Writer, text string) {
+	fmt.Fprintf(w, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
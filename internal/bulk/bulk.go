@@ -0,0 +1,178 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// Op is an operation applied to each matched file
+type Op interface {
+	// Describe says what Apply does to name, e.g. "rm prod/global/s3/b/x"
+	Describe(name string) string
+	Apply(name string) error
+}
+
+// Run applies op to every name with up to parallel operations at a time,
+// printing each description to out as it completes and failures to errOut.
+// With dryRun nothing is applied and the descriptions are printed in
+// order. It returns the number of failures.
+func Run(names []string, op Op, parallel int, dryRun bool, out, errOut io.Writer) int {
+	if dryRun {
+		for _, name := range names {
+			fmt.Fprintln(out, op.Describe(name))
+		}
+		return 0
+	}
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, max(parallel, 1))
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := op.Apply(name)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				fmt.Fprintf(errOut, "%s: %v\n", op.Describe(name), err)
+				return
+			}
+			fmt.Fprintln(out, op.Describe(name))
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+// Remove deletes each file
+type Remove struct {
+	Tree Tree
+}
+
+func (op Remove) Describe(name string) string { return "rm " + name }
+func (op Remove) Apply(name string) error     { return op.Tree.Remove(name) }
+
+// Copy copies each file below Dest, keeping its path relative to Base
+// (usually the StaticPrefix of the glob)
+type Copy struct {
+	Tree Tree
+	Base string
+	Dest string
+}
+
+func (op Copy) target(name string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(name, op.Base), "/")
+	return join(strings.Trim(op.Dest, "/"), rel)
+}
+
+func (op Copy) Describe(name string) string { return "cp " + name + " " + op.target(name) }
+
+func (op Copy) Apply(name string) error {
+	data, err := op.Tree.ReadFile(name, 0)
+	if err != nil {
+		return err
+	}
+	return op.Tree.WriteFile(op.target(name), data)
+}
+
+// Tag adds tags to the resource behind each file. SSM parameters are
+// tagged through their metadata sidecar; EC2 instances, Lambda functions
+// and DynamoDB tables, through the tags.json of the directory the file is
+// in, keeping the tags already there.
+type Tag struct {
+	Tree Tree
+	Tags map[string]string
+}
+
+// tagFile returns the file that tags the resource behind name, and whether
+// it is a tags.json to merge into rather than an SSM sidecar
+func tagFile(name string) (string, bool, error) {
+	parts := strings.SplitN(name, "/", 5)
+	if len(parts) < 4 {
+		return "", false, fmt.Errorf("not a resource file")
+	}
+	switch parts[2] {
+	case "ssm":
+		return name + provider.SSMMetaSuffix, false, nil
+	case "ec2", "lambda", "dynamodb":
+		if len(parts) < 5 {
+			return "", false, fmt.Errorf("not a resource file")
+		}
+		return strings.Join(parts[:4], "/") + "/" + provider.TagsFile, true, nil
+	case "s3":
+		return "", false, fmt.Errorf("tagging is not supported for S3 objects; edit the bucket's %s sidecar", provider.S3TagsSuffix)
+	}
+	return "", false, fmt.Errorf("tagging is not supported for %s", parts[2])
+}
+
+func (op Tag) Describe(name string) string {
+	keys := make([]string, 0, len(op.Tags))
+	for k := range op.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + op.Tags[k]
+	}
+	return "tag " + name + " " + strings.Join(pairs, " ")
+}
+
+func (op Tag) Apply(name string) error {
+	file, merge, err := tagFile(name)
+	if err != nil {
+		return err
+	}
+	if !merge {
+		data, err := json.Marshal(provider.SSMMetadata{Tags: op.Tags})
+		if err != nil {
+			return err
+		}
+		return op.Tree.WriteFile(file, data)
+	}
+
+	data, err := op.Tree.ReadFile(file, 0)
+	if err != nil {
+		return err
+	}
+	tags := make(map[string]string)
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for k, v := range op.Tags {
+		tags[k] = v
+	}
+	data, err = json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	return op.Tree.WriteFile(file, data)
+}
+
+// ParseTags parses key=value arguments
+func ParseTags(args []string) (map[string]string, error) {
+	tags := make(map[string]string, len(args))
+	for _, arg := range args {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid tag %q: expected key=value", arg)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
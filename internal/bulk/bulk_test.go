@@ -0,0 +1,208 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// memTree is an in-memory Tree whose directories are implied by file paths
+type memTree struct {
+	mu     sync.Mutex
+	files  map[string]string
+	listed []string
+}
+
+func newMemTree(names ...string) *memTree {
+	t := &memTree{files: make(map[string]string)}
+	for _, name := range names {
+		t.files[name] = "content of " + name
+	}
+	return t
+}
+
+func (t *memTree) List(dir string) ([]provider.Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listed = append(t.listed, dir)
+
+	seen := make(map[string]bool)
+	var entries []provider.Entry
+	for name := range t.files {
+		rel, ok := strings.CutPrefix(name, dir+"/")
+		if dir == "" {
+			rel, ok = name, true
+		}
+		if !ok {
+			continue
+		}
+		child, _, isDir := strings.Cut(rel, "/")
+		if !seen[child] {
+			seen[child] = true
+			entries = append(entries, provider.Entry{Name: child, IsDir: isDir})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return entries, nil
+}
+
+func (t *memTree) ReadFile(name string, limit int) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, ok := t.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (t *memTree) WriteFile(name string, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files[name] = string(data)
+	return nil
+}
+
+func (t *memTree) Remove(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if strings.HasSuffix(name, "config.json") {
+		return fs.ErrPermission
+	}
+	delete(t.files, name)
+	return nil
+}
+
+func TestExpand(t *testing.T) {
+	tree := newMemTree(
+		"prod/us-east-1/lambda/api-staging/config.json",
+		"prod/us-east-1/lambda/api-staging/env.json",
+		"prod/us-east-1/lambda/api-prod/config.json",
+		"prod/eu-west-1/lambda/worker-staging/config.json",
+		"prod/eu-west-1/ssm/app-staging/config.json",
+		"dev/us-east-1/lambda/api-staging/config.json",
+	)
+
+	got, err := Expand(tree, "prod/*/lambda/*-staging/config.json", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"prod/eu-west-1/lambda/worker-staging/config.json",
+		"prod/us-east-1/lambda/api-staging/config.json",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand = %v, want %v", got, want)
+	}
+
+	// The literal "prod" is descended into without listing the root
+	for _, dir := range tree.listed {
+		if dir == "" {
+			t.Error("Expand listed the root for a literal first segment")
+		}
+	}
+}
+
+func TestExpandMissingDirectory(t *testing.T) {
+	tree := newMemTree("prod/us-east-1/ssm/a")
+
+	if _, err := Expand(tree, "prod/*/ssm/nothing/*", 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expand = %v, want ErrNotExist when nothing matched", err)
+	}
+	if _, err := Expand(tree, "prod/[/x", 1); err == nil {
+		t.Error("Expand accepted a malformed pattern")
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"prod/us-east-1/ssm/app/*": "prod/us-east-1/ssm/app",
+		"prod/*/ssm/app/*":         "prod",
+		"*/global":                 "",
+		"prod/global/s3/b/file":    "prod/global/s3/b",
+	} {
+		if got := StaticPrefix(pattern); got != want {
+			t.Errorf("StaticPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestRunCopyAndDryRun(t *testing.T) {
+	tree := newMemTree("prod/us-east-1/ssm/app/a", "prod/us-east-1/ssm/app/b")
+	names, _ := Expand(tree, "prod/us-east-1/ssm/app/*", 2)
+	op := Copy{Tree: tree, Base: "prod/us-east-1/ssm/app", Dest: "dev/us-east-1/ssm/app"}
+
+	var out, errOut bytes.Buffer
+	Run(names, op, 2, true, &out, &errOut)
+	if out.String() != "cp prod/us-east-1/ssm/app/a dev/us-east-1/ssm/app/a\ncp prod/us-east-1/ssm/app/b dev/us-east-1/ssm/app/b\n" {
+		t.Errorf("dry run printed %q", out.String())
+	}
+	if _, ok := tree.files["dev/us-east-1/ssm/app/a"]; ok {
+		t.Fatal("dry run copied a file")
+	}
+
+	if failed := Run(names, op, 2, false, &out, &errOut); failed != 0 {
+		t.Fatalf("%d failed: %s", failed, errOut.String())
+	}
+	if tree.files["dev/us-east-1/ssm/app/b"] != "content of prod/us-east-1/ssm/app/b" {
+		t.Errorf("copy = %q", tree.files["dev/us-east-1/ssm/app/b"])
+	}
+}
+
+func TestRunRemoveReportsFailures(t *testing.T) {
+	tree := newMemTree("p/r/lambda/f/config.json", "p/r/lambda/f/env.json")
+	names := []string{"p/r/lambda/f/config.json", "p/r/lambda/f/env.json"}
+
+	var out, errOut bytes.Buffer
+	if failed := Run(names, Remove{Tree: tree}, 4, false, &out, &errOut); failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	if !strings.Contains(errOut.String(), "rm p/r/lambda/f/config.json: permission denied") {
+		t.Errorf("errors = %q", errOut.String())
+	}
+	if _, ok := tree.files["p/r/lambda/f/env.json"]; ok {
+		t.Error("env.json was not removed")
+	}
+}
+
+func TestTagWritesSidecar(t *testing.T) {
+	tree := newMemTree("prod/us-east-1/ssm/app/db", "prod/global/s3/b/x")
+	op := Tag{Tree: tree, Tags: map[string]string{"team": "platform"}}
+
+	if err := op.Apply("prod/us-east-1/ssm/app/db"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.files["prod/us-east-1/ssm/app/db@meta.json"]; got != `{"tags":{"team":"platform"}}` {
+		t.Errorf("sidecar = %s", got)
+	}
+	if err := op.Apply("prod/global/s3/b/x"); err == nil {
+		t.Error("tagging an S3 object succeeded")
+	}
+}
+
+func TestTagMergesTagsFile(t *testing.T) {
+	tree := newMemTree("prod/us-east-1/ec2/i-1/info.json", "prod/us-east-1/lambda/api/config.json")
+	tree.files["prod/us-east-1/ec2/i-1/tags.json"] = `{"Name": "web", "team": "core"}`
+	tree.files["prod/us-east-1/lambda/api/tags.json"] = `{}`
+	op := Tag{Tree: tree, Tags: map[string]string{"team": "platform"}}
+
+	for _, name := range []string{"prod/us-east-1/ec2/i-1/info.json", "prod/us-east-1/lambda/api/config.json"} {
+		if err := op.Apply(name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if got := tree.files["prod/us-east-1/ec2/i-1/tags.json"]; got != "{\n  \"Name\": \"web\",\n  \"team\": \"platform\"\n}" {
+		t.Errorf("instance tags = %s", got)
+	}
+	if got := tree.files["prod/us-east-1/lambda/api/tags.json"]; got != "{\n  \"team\": \"platform\"\n}" {
+		t.Errorf("function tags = %s", got)
+	}
+}
@@ -0,0 +1,313 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CompareKind is how a file of a profile differs from the baseline's
+type CompareKind string
+
+const (
+	CompareMissing CompareKind = "missing" // only the baseline has it
+	CompareExtra   CompareKind = "extra"   // only the profile has it
+	CompareDrifted CompareKind = "drifted" // both have it with different content
+)
+
+// CompareDiff is a file that differs between the baseline and a profile
+type CompareDiff struct {
+	Kind    CompareKind `json:"kind"`
+	Service string      `json:"service"`
+	Path    string      `json:"path"`    // relative to the service directory
+	Profile string      `json:"profile"` // the profile compared with the baseline
+	// Fields lists the differing fields of JSON documents, e.g.
+	// "Statement[0].Action"; empty for other files
+	Fields []string `json:"fields,omitempty"`
+}
+
+// CompareReport is the result of a Compare
+type CompareReport struct {
+	Profiles    []string      `json:"profiles"` // the first is the baseline
+	Services    []string      `json:"services"`
+	Files       int           `json:"files"` // distinct paths compared
+	Differences []CompareDiff `json:"differences"`
+	// Failed lists the paths that couldn't be listed or read; nothing
+	// below them is reported missing
+	Failed []string `json:"failed,omitempty"`
+}
+
+// Compare reads the same services below several profiles and reports how
+// each profile differs from the first, e.g. a DR account from production.
+// ARNs are compared without their region and account, which always differ
+// between accounts, and JSON documents field by field.
+type Compare struct {
+	Tree     Tree
+	Profiles []string
+	Services []string
+	// Dir returns the directory of service for profile in Tree, e.g.
+	// "prod/us-east-1/ssm"
+	Dir      func(profile, service string) string
+	Parallel int
+	// Ignore holds JSON field names left out of the comparison anywhere
+	// in a document, e.g. creation dates and generated IDs
+	Ignore map[string]bool
+}
+
+// Run reads every file of the services below each profile, printing
+// failures to errOut
+func (c Compare) Run(errOut io.Writer) (*CompareReport, error) {
+	if len(c.Profiles) < 2 {
+		return nil, fmt.Errorf("compare needs at least two profiles")
+	}
+	report := &CompareReport{Profiles: c.Profiles, Services: c.Services, Differences: []CompareDiff{}}
+	for _, service := range c.Services {
+		sides := make([]*compareSide, len(c.Profiles))
+		for i, profile := range c.Profiles {
+			sides[i] = c.read(c.Dir(profile, service), errOut)
+			report.Failed = append(report.Failed, sides[i].failed...)
+		}
+		report.Files += c.diff(report, service, sides)
+	}
+	sort.Slice(report.Differences, func(i, j int) bool {
+		a, b := report.Differences[i], report.Differences[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Profile < b.Profile
+	})
+	sort.Strings(report.Failed)
+	return report, nil
+}
+
+// compareSide is the content of one profile's service directory, keyed by
+// path relative to it
+type compareSide struct {
+	files   map[string][]byte
+	skipped []string // relative directories and files that failed
+	failed  []string // the same, relative to the tree root
+}
+
+// unknown reports whether name is below a path that couldn't be read
+func (s *compareSide) unknown(name string) bool {
+	for _, dir := range s.skipped {
+		if dir == "" || name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// read lists and reads every file below root
+func (c Compare) read(root string, errOut io.Writer) *compareSide {
+	side := &compareSide{files: make(map[string][]byte)}
+	fail := func(name string, err error) {
+		fmt.Fprintf(errOut, "%s: %v\n", name, err)
+		side.skipped = append(side.skipped, strings.TrimPrefix(strings.TrimPrefix(name, root), "/"))
+		side.failed = append(side.failed, name)
+	}
+
+	entries, err := listAll(c.Tree, root)
+	if err != nil {
+		fail(root, err)
+		return side
+	}
+	w := &mirrorWalk{tree: c.Tree, sem: make(chan struct{}, max(c.Parallel, 1)), errOut: errOut}
+	w.visit(root, entries)
+	w.wg.Wait()
+	for dir := range w.skipped {
+		side.skipped = append(side.skipped, strings.TrimPrefix(dir, root+"/"))
+		side.failed = append(side.failed, dir)
+	}
+	// What a truncated listing left out isn't missing
+	for dir := range w.incomplete {
+		if dir == root {
+			dir = ""
+		}
+		side.skipped = append(side.skipped, strings.TrimPrefix(dir, root+"/"))
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(c.Parallel, 1))
+	)
+	for _, name := range w.files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			data, err := c.Tree.ReadFile(name, 0)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fail(name, err)
+				return
+			}
+			side.files[strings.TrimPrefix(name, root+"/")] = data
+		}()
+	}
+	wg.Wait()
+	return side
+}
+
+// diff adds the differences of each side from the first to report and
+// returns the number of distinct paths compared
+func (c Compare) diff(report *CompareReport, service string, sides []*compareSide) int {
+	names := make(map[string]bool)
+	for _, side := range sides {
+		for name := range side.files {
+			names[name] = true
+		}
+	}
+	base := sides[0]
+	for name := range names {
+		want, inBase := base.files[name]
+		for i, side := range sides[1:] {
+			d := CompareDiff{Service: service, Path: name, Profile: c.Profiles[i+1]}
+			got, inSide := side.files[name]
+			switch {
+			case inBase && !inSide:
+				if side.unknown(name) {
+					continue
+				}
+				d.Kind = CompareMissing
+			case !inBase && inSide:
+				if base.unknown(name) {
+					continue
+				}
+				d.Kind = CompareExtra
+			case !inBase && !inSide:
+				continue
+			default:
+				fields, same := c.compareContent(want, got)
+				if same {
+					continue
+				}
+				d.Kind, d.Fields = CompareDrifted, fields
+			}
+			report.Differences = append(report.Differences, d)
+		}
+	}
+	return len(names)
+}
+
+// arnScope matches the region and account of an ARN
+var arnScope = regexp.MustCompile(`(arn:aws[\w-]*:[\w-]+):[\w-]*:\d{12}:`)
+
+// compareContent compares two files, JSON documents field by field, and
+// returns the fields that differ
+func (c Compare) compareContent(a, b []byte) (fields []string, same bool) {
+	a = arnScope.ReplaceAll(a, []byte("$1:*:*:"))
+	b = arnScope.ReplaceAll(b, []byte("$1:*:*:"))
+	if bytes.Equal(a, b) {
+		return nil, true
+	}
+	var docA, docB any
+	if decodeJSON(a, &docA) != nil || decodeJSON(b, &docB) != nil {
+		return nil, false
+	}
+	c.diffJSON("", docA, docB, &fields)
+	return fields, len(fields) == 0
+}
+
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// diffJSON appends the paths at which two decoded documents differ.
+// Arrays of different lengths differ as a whole.
+func (c Compare) diffJSON(path string, a, b any, out *[]string) {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool, len(a)+len(b))
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			if !c.Ignore[k] {
+				sorted = append(sorted, k)
+			}
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			c.diffJSON(field, a[k], b[k], out)
+		}
+		return
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range a {
+			c.diffJSON(fmt.Sprintf("%s[%d]", path, i), a[i], b[i], out)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+		*out = append(*out, path)
+	}
+}
+
+var compareHTML = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sisu compare: {{range $i, $p := .Profiles}}{{if $i}} vs {{end}}{{$p}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+td.missing { color: #b00; } td.extra { color: #06c; } td.drifted { color: #a60; }
+code { font-size: 90%; }
+</style>
+</head>
+<body>
+<h1>{{range $i, $p := .Profiles}}{{if $i}} vs {{end}}{{$p}}{{end}}</h1>
+<p>Services: {{range $i, $s := .Services}}{{if $i}}, {{end}}{{$s}}{{end}}.
+{{.Files}} files compared against {{index .Profiles 0}}, {{len .Differences}} differences.</p>
+{{if .Differences}}<table>
+<tr><th>Service</th><th>Path</th><th>Profile</th><th>Difference</th><th>Fields</th></tr>
+{{range .Differences}}<tr><td>{{.Service}}</td><td><code>{{.Path}}</code></td><td>{{.Profile}}</td><td class="{{.Kind}}">{{.Kind}}</td><td>{{range .Fields}}<code>{{.}}</code><br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Failed}}<h2>Not compared</h2>
+<p>These couldn't be listed or read; nothing below them is reported missing.</p>
+<ul>{{range .Failed}}<li><code>{{.}}</code></li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a standalone HTML page
+func (r *CompareReport) WriteHTML(w io.Writer) error {
+	return compareHTML.Execute(w, r)
+}
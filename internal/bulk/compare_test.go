@@ -0,0 +1,117 @@
+package bulk
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tree := newMemTree(
+		"prod/global/iam/roles/api/info.json",
+		"prod/global/iam/roles/api/trust-policy.json",
+		"prod/global/iam/roles/batch/info.json",
+		"prod/us-east-1/ssm/app/url",
+		"dr/global/iam/roles/api/info.json",
+		"dr/global/iam/roles/api/trust-policy.json",
+		"dr/global/iam/roles/legacy/info.json",
+		"dr/us-west-2/ssm/app/url",
+	)
+	tree.files["prod/global/iam/roles/api/info.json"] = `{"Arn": "arn:aws:iam::111111111111:role/api", "CreateDate": "2024-01-01", "MaxSessionDuration": 3600}`
+	tree.files["dr/global/iam/roles/api/info.json"] = `{"Arn": "arn:aws:iam::222222222222:role/api", "CreateDate": "2025-06-01", "MaxSessionDuration": 3600}`
+	tree.files["prod/global/iam/roles/api/trust-policy.json"] = `{"Statement": [{"Effect": "Allow", "Principal": {"Service": "lambda.amazonaws.com"}}]}`
+	tree.files["dr/global/iam/roles/api/trust-policy.json"] = `{"Statement": [{"Effect": "Allow", "Principal": {"Service": "ecs-tasks.amazonaws.com"}}]}`
+	tree.files["prod/us-east-1/ssm/app/url"] = "https://prod.example.com\n"
+	tree.files["dr/us-west-2/ssm/app/url"] = "https://dr.example.com\n"
+
+	regions := map[string]string{"prod": "us-east-1", "dr": "us-west-2"}
+	c := Compare{
+		Tree:     tree,
+		Profiles: []string{"prod", "dr"},
+		Services: []string{"iam", "ssm"},
+		Dir: func(profile, service string) string {
+			if service == "iam" {
+				return profile + "/global/iam"
+			}
+			return profile + "/" + regions[profile] + "/" + service
+		},
+		Ignore: map[string]bool{"CreateDate": true},
+	}
+	report, err := c.Run(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CompareDiff{
+		{Kind: CompareDrifted, Service: "iam", Path: "roles/api/trust-policy.json", Profile: "dr", Fields: []string{"Statement[0].Principal.Service"}},
+		{Kind: CompareMissing, Service: "iam", Path: "roles/batch/info.json", Profile: "dr"},
+		{Kind: CompareExtra, Service: "iam", Path: "roles/legacy/info.json", Profile: "dr"},
+		{Kind: CompareDrifted, Service: "ssm", Path: "app/url", Profile: "dr"},
+	}
+	if !reflect.DeepEqual(report.Differences, want) {
+		t.Errorf("differences = %+v\nwant %+v", report.Differences, want)
+	}
+	if report.Files != 5 {
+		t.Errorf("files = %d, want 5", report.Files)
+	}
+
+	var page bytes.Buffer
+	if err := report.WriteHTML(&page); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), "roles/legacy/info.json") || !strings.Contains(page.String(), "Statement[0].Principal.Service") {
+		t.Errorf("HTML report misses differences:\n%s", page.String())
+	}
+}
+
+func TestCompareSkipsUnlistedDirectories(t *testing.T) {
+	tree := newMemTree("prod/global/iam/roles/a.json", "prod/global/iam/users/u.json", "dr/global/iam/roles/a.json")
+	var errOut bytes.Buffer
+	c := Compare{
+		Tree:     unlistableTree{tree, "dr/global/iam"},
+		Profiles: []string{"prod", "dr"},
+		Services: []string{"iam"},
+		Dir:      func(profile, service string) string { return profile + "/global/" + service },
+	}
+	report, err := c.Run(&errOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Differences) != 0 {
+		t.Errorf("differences = %+v, want none for an unlisted service", report.Differences)
+	}
+	if !reflect.DeepEqual(report.Failed, []string{"dr/global/iam"}) {
+		t.Errorf("failed = %v", report.Failed)
+	}
+}
+
+func TestCompareSkipsMarkersAndVolatileFiles(t *testing.T) {
+	tree := newMemTree(
+		"prod/global/iam/roles/a/info.json",
+		"prod/global/iam/roles/a/last-accessed.json",
+		"prod/global/iam/roles/b/info.json",
+		"dr/global/iam/roles/a/info.json",
+		"dr/global/iam/roles/a/last-accessed.json",
+		"dr/global/iam/roles/_more_results.txt",
+	)
+	tree.files["prod/global/iam/roles/a/info.json"] = `{"RoleName": "a"}`
+	tree.files["dr/global/iam/roles/a/info.json"] = `{"RoleName": "a"}`
+	c := Compare{
+		Tree:     tree,
+		Profiles: []string{"prod", "dr"},
+		Services: []string{"iam"},
+		Dir:      func(profile, service string) string { return profile + "/global/" + service },
+	}
+	report, err := c.Run(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// roles/b may be past the truncated listing of dr
+	if len(report.Differences) != 0 {
+		t.Errorf("differences = %+v, want none", report.Differences)
+	}
+	if report.Files != 2 {
+		t.Errorf("files = %d, want only the two info.json", report.Files)
+	}
+}
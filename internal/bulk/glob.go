@@ -0,0 +1,140 @@
+// Package bulk applies an operation to every file matching a glob,
+// expanding the glob against provider listings rather than through the
+// shell, which would stat every entry over FUSE.
+package bulk
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/semonte/sisu/internal/paging"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// Tree is the filesystem tree bulk operations run against, addressed by
+// paths relative to the mount root
+type Tree interface {
+	List(dir string) ([]provider.Entry, error)
+	ReadFile(name string, limit int) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	Remove(name string) error
+}
+
+// listAll lists dir with the entries of every page of a long listing, so
+// entries past the first page keep their real paths
+func listAll(tree Tree, dir string) ([]provider.Entry, error) {
+	return paging.ListAll(dir, tree.List, func(e provider.Entry) string { return e.Name })
+}
+
+// hasMeta reports whether a glob segment contains wildcards
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, "*?[")
+}
+
+// StaticPrefix returns the leading directories of pattern that contain no
+// wildcards, e.g. "prod/us-east-1/ssm/app" for "prod/us-east-1/ssm/app/*"
+func StaticPrefix(pattern string) string {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, s := range segments[:len(segments)-1] {
+		if hasMeta(s) {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return strings.Join(segments[:len(segments)-1], "/")
+}
+
+// Expand returns the files matching pattern, sorted. Each "/"-separated
+// segment is matched with path.Match against the entries of one directory
+// level; literal segments are descended into without listing their parent.
+// Directories are listed by up to parallel goroutines at a time.
+func Expand(tree Tree, pattern string, parallel int) ([]string, error) {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for _, s := range segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &expander{tree: tree, segments: segments, sem: make(chan struct{}, max(parallel, 1))}
+	e.walk("", 0)
+	e.wg.Wait()
+
+	sort.Strings(e.matches)
+	if len(e.matches) > 0 {
+		return e.matches, nil
+	}
+	return nil, e.err
+}
+
+type expander struct {
+	tree     Tree
+	segments []string
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	matches []string
+	err     error // first listing error other than a missing directory
+}
+
+// walk matches segments[i:] below dir
+func (e *expander) walk(dir string, i int) {
+	segment := e.segments[i]
+	last := i == len(e.segments)-1
+
+	// Literal directories are descended into directly; a literal last
+	// segment still needs a listing to tell files from directories
+	if !hasMeta(segment) && !last {
+		e.walk(join(dir, segment), i+1)
+		return
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.sem <- struct{}{}
+		entries, err := listAll(e.tree, dir)
+		<-e.sem
+		if err != nil {
+			e.fail(err)
+			return
+		}
+
+		for _, entry := range entries {
+			if ok, _ := path.Match(segment, entry.Name); !ok {
+				continue
+			}
+			name := join(dir, entry.Name)
+			switch {
+			case entry.Link != "":
+				// Links lead to files matched under their own path
+			case last && !entry.IsDir:
+				e.mu.Lock()
+				e.matches = append(e.matches, name)
+				e.mu.Unlock()
+			case !last && entry.IsDir:
+				e.walk(name, i+1)
+			}
+		}
+	}()
+}
+
+// fail records a listing error. Wildcards routinely reach directories that
+// don't exist or can't be listed (a service in a region it isn't used in),
+// so only the first error is kept, and only returned if nothing matched.
+func (e *expander) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,226 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// MirrorChange is a file Mirror added, modified or removed in its directory
+type MirrorChange struct {
+	Kind provider.ChangeKind
+	Name string // path relative to the mount root
+}
+
+// Mirror copies every file below Root in Tree to the same path below Dir,
+// e.g. prod/global/iam/roles/x/trust-policy.json to
+// Dir/prod/global/iam/roles/x/trust-policy.json, and removes files below
+// Dir/Root that no longer exist in Tree. Files that can't be listed or read,
+// or are below a truncated listing, keep their previous copy, so a failure
+// never shows up as a removal. Listing markers like _more_results.txt and
+// files that change on every read, like IAM last-accessed.json, aren't
+// mirrored.
+type Mirror struct {
+	Tree     Tree
+	Root     string
+	Dir      string
+	Parallel int
+	// Exclude, if set, leaves out the files and directories it matches
+	Exclude func(name string) bool
+}
+
+// Run updates the mirror, printing failures to errOut, and returns the
+// changes made, sorted by name, and the number of failures
+func (m Mirror) Run(errOut io.Writer) ([]MirrorChange, int, error) {
+	root := strings.Trim(m.Root, "/")
+	w := &mirrorWalk{tree: m.Tree, exclude: m.Exclude, sem: make(chan struct{}, max(m.Parallel, 1)), errOut: errOut}
+	entries, err := listAll(m.Tree, root)
+	if err != nil {
+		return nil, 0, err
+	}
+	w.visit(root, entries)
+	w.wg.Wait()
+	sort.Strings(w.files)
+
+	var (
+		mu      sync.Mutex
+		changes []MirrorChange
+		failed  = w.failed
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, max(m.Parallel, 1))
+	seen := make(map[string]bool, len(w.files))
+	for _, name := range w.files {
+		seen[name] = true
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			kind, err := m.update(name)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+				fmt.Fprintf(errOut, "%s: %v\n", name, err)
+			case kind != "":
+				changes = append(changes, MirrorChange{Kind: kind, Name: name})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// What a truncated listing left out isn't gone either
+	keep := make(map[string]bool, len(w.skipped)+len(w.incomplete))
+	for dir := range w.skipped {
+		keep[dir] = true
+	}
+	for dir := range w.incomplete {
+		keep[dir] = true
+	}
+	removed, err := m.removeStale(root, seen, keep)
+	if err != nil {
+		return nil, failed, err
+	}
+	changes = append(changes, removed...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes, failed, nil
+}
+
+// update copies one file into the mirror, returning how it changed ("" if
+// it didn't)
+func (m Mirror) update(name string) (provider.ChangeKind, error) {
+	data, err := m.Tree.ReadFile(name, 0)
+	if err != nil {
+		return "", err
+	}
+	local := filepath.Join(m.Dir, filepath.FromSlash(name))
+	old, err := os.ReadFile(local)
+	kind := provider.ChangeModified
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		kind = provider.ChangeAdded
+	case err != nil:
+		return "", err
+	case bytes.Equal(old, data):
+		return "", nil
+	}
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return "", err
+	}
+	return kind, os.WriteFile(local, data, 0644)
+}
+
+// removeStale deletes mirrored files below root that weren't seen, except
+// below skipped directories, which couldn't be listed in full
+func (m Mirror) removeStale(root string, seen, skipped map[string]bool) ([]MirrorChange, error) {
+	var removed []MirrorChange
+	base := filepath.Join(m.Dir, filepath.FromSlash(root))
+	err := filepath.WalkDir(base, func(local string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && local == base {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(m.Dir, local)
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || skipped[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if seen[name] || skipped[name] {
+			return nil
+		}
+		if err := os.Remove(local); err != nil {
+			return err
+		}
+		removed = append(removed, MirrorChange{Kind: provider.ChangeRemoved, Name: name})
+		return nil
+	})
+	return removed, err
+}
+
+// mirrorWalk lists every file below a directory, listing up to cap(sem)
+// directories at a time
+type mirrorWalk struct {
+	tree    Tree
+	exclude func(name string) bool
+	sem     chan struct{}
+	errOut  io.Writer
+	wg      sync.WaitGroup
+
+	mu         sync.Mutex
+	files      []string
+	skipped    map[string]bool // directories that couldn't be listed
+	incomplete map[string]bool // directories whose listing is truncated, partial or denied
+	failed     int
+}
+
+// visit adds the files of a listing and lists its directories. Listing
+// markers, symlinks, files that change on every read and files whose
+// reads have side effects are left out; a marker flags its directory
+// incomplete.
+func (w *mirrorWalk) visit(dir string, entries []provider.Entry) {
+	for _, entry := range entries {
+		name := join(dir, entry.Name)
+		if w.exclude != nil && w.exclude(name) {
+			continue
+		}
+		// Links point at files mirrored under their own path
+		if entry.Link != "" {
+			continue
+		}
+		if !entry.IsDir {
+			if isMarker(name) {
+				w.mu.Lock()
+				if w.incomplete == nil {
+					w.incomplete = make(map[string]bool)
+				}
+				w.incomplete[dir] = true
+				w.mu.Unlock()
+				continue
+			}
+			if skipRead(name) || isVolatile(name) {
+				continue
+			}
+			w.mu.Lock()
+			w.files = append(w.files, name)
+			w.mu.Unlock()
+			continue
+		}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.sem <- struct{}{}
+			entries, err := listAll(w.tree, name)
+			<-w.sem
+			if err != nil {
+				w.mu.Lock()
+				if w.skipped == nil {
+					w.skipped = make(map[string]bool)
+				}
+				w.skipped[name] = true
+				w.failed++
+				fmt.Fprintf(w.errOut, "%s: %v\n", name, err)
+				w.mu.Unlock()
+				return
+			}
+			w.visit(name, entries)
+		}()
+	}
+}
@@ -0,0 +1,168 @@
+package bulk
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestMirror(t *testing.T) {
+	dir := t.TempDir()
+	tree := newMemTree(
+		"prod/global/iam/roles/a/policy.json",
+		"prod/global/iam/roles/b/policy.json",
+		"prod/global/iam/users/u/info.json",
+		"prod/changes.log",
+	)
+	m := Mirror{
+		Tree:    tree,
+		Root:    "prod",
+		Dir:     dir,
+		Exclude: func(name string) bool { return name == "prod/changes.log" },
+	}
+
+	changes, failed, err := m.Run(os.Stderr)
+	if err != nil || failed != 0 {
+		t.Fatalf("Run = %d failed, %v", failed, err)
+	}
+	if len(changes) != 3 || changes[0].Kind != provider.ChangeAdded {
+		t.Errorf("first run changes = %v", changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prod/changes.log")); err == nil {
+		t.Error("excluded file was mirrored")
+	}
+
+	// Unchanged files aren't reported; changed and removed ones are
+	tree.files["prod/global/iam/roles/a/policy.json"] = "changed"
+	delete(tree.files, "prod/global/iam/users/u/info.json")
+	changes, _, err = m.Run(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MirrorChange{
+		{Kind: provider.ChangeModified, Name: "prod/global/iam/roles/a/policy.json"},
+		{Kind: provider.ChangeRemoved, Name: "prod/global/iam/users/u/info.json"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "prod/global/iam/roles/a/policy.json")); string(data) != "changed" {
+		t.Errorf("mirrored content = %q", data)
+	}
+}
+
+// unlistableTree fails to list one directory
+type unlistableTree struct {
+	*memTree
+	dir string
+}
+
+func (t unlistableTree) List(dir string) ([]provider.Entry, error) {
+	if dir == t.dir {
+		return nil, os.ErrPermission
+	}
+	return t.memTree.List(dir)
+}
+
+func TestMirrorKeepsUnlistedFiles(t *testing.T) {
+	dir := t.TempDir()
+	tree := newMemTree("p/iam/roles/a.json", "p/iam/users/u.json")
+	if _, _, err := (Mirror{Tree: tree, Root: "p", Dir: dir}).Run(os.Stderr); err != nil {
+		t.Fatal(err)
+	}
+
+	var errOut bytes.Buffer
+	m := Mirror{Tree: unlistableTree{tree, "p/iam/users"}, Root: "p", Dir: dir}
+	changes, failed, err := m.Run(&errOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 || !strings.Contains(errOut.String(), "p/iam/users") {
+		t.Errorf("failed = %d, errors %q", failed, errOut.String())
+	}
+	if len(changes) != 0 {
+		t.Errorf("changes = %v, want the unlisted directory kept", changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "p/iam/users/u.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMirrorSkipsReadsWithSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	tree := newMemTree("p/us-east-1/sqs/orders/attributes.json", "p/us-east-1/sqs/orders/peek.json")
+	changes, _, err := (Mirror{Tree: tree, Root: "p/us-east-1/sqs", Dir: dir}).Run(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Name != "p/us-east-1/sqs/orders/attributes.json" {
+		t.Errorf("changes = %v, want only attributes.json", changes)
+	}
+}
+
+// pagedTree lists a directory's entries after the first in a _page2
+// directory, like a mount listing more than max_entries
+type pagedTree struct {
+	*memTree
+	dir string
+}
+
+func (t pagedTree) List(dir string) ([]provider.Entry, error) {
+	if dir != t.dir && dir != t.dir+"/_page2" {
+		return t.memTree.List(dir)
+	}
+	entries, err := t.memTree.List(t.dir)
+	if err != nil || len(entries) < 2 {
+		return entries, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	if dir == t.dir {
+		return []provider.Entry{entries[0], {Name: "_page2", IsDir: true}}, nil
+	}
+	return entries[1:], nil
+}
+
+func TestMirrorMergesPages(t *testing.T) {
+	dir := t.TempDir()
+	tree := pagedTree{memTree: newMemTree("p/s3/b/a", "p/s3/b/c"), dir: "p/s3/b"}
+	changes, failed, err := (Mirror{Tree: tree, Root: "p/s3", Dir: dir}).Run(os.Stderr)
+	if err != nil || failed != 0 {
+		t.Fatalf("Run = %d, %v", failed, err)
+	}
+	var names []string
+	for _, c := range changes {
+		names = append(names, c.Name)
+	}
+	if want := []string{"p/s3/b/a", "p/s3/b/c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("changes = %v, want %v", names, want)
+	}
+}
+
+func TestMirrorSkipsMarkersAndVolatileFiles(t *testing.T) {
+	dir := t.TempDir()
+	tree := newMemTree("p/global/iam/roles/a/info.json", "p/global/iam/roles/b/info.json")
+	if _, _, err := (Mirror{Tree: tree, Root: "p/global/iam", Dir: dir}).Run(os.Stderr); err != nil {
+		t.Fatal(err)
+	}
+
+	// roles is now truncated before b, and a has a report generated on read
+	delete(tree.files, "p/global/iam/roles/b/info.json")
+	tree.files["p/global/iam/roles/_more_results.txt"] = "Showing first 1 entries."
+	tree.files["p/global/iam/roles/a/last-accessed.json"] = "{}"
+	changes, failed, err := (Mirror{Tree: tree, Root: "p/global/iam", Dir: dir}).Run(os.Stderr)
+	if err != nil || failed != 0 {
+		t.Fatalf("Run = %d, %v", failed, err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("changes = %v, want none", changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "p/global/iam/roles/b/info.json")); err != nil {
+		t.Errorf("file past the truncated listing removed: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package bulk
+
+import (
+	"path"
+	"strings"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// servicePath splits name, a path relative to the mount root like
+// "prod/us-east-1/sqs/orders/peek.json", into its service and the path
+// below the service directory
+func servicePath(name string) (service, rest string, ok bool) {
+	parts := strings.SplitN(name, "/", 4)
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	return parts[2], parts[3], true
+}
+
+// skipRead reports whether tools reading whole subtrees leave out the file
+// name because reading it has side effects. Such files are still read
+// when named, e.g. by bulk cp.
+func skipRead(name string) bool {
+	service, rest, ok := servicePath(name)
+	return ok && provider.HasReadSideEffects(service, rest)
+}
+
+// isVolatile reports whether the file name changes between reads on its
+// own, so copies and comparisons of it only show noise
+func isVolatile(name string) bool {
+	service, rest, ok := servicePath(name)
+	return ok && provider.IsVolatile(service, rest)
+}
+
+// isMarker reports whether name is a marker sisu adds to a listing, such
+// as _more_results.txt, rather than a file of the tree
+func isMarker(name string) bool {
+	return provider.IsMarker(path.Base(name))
+}
@@ -0,0 +1,252 @@
+package bulk
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// SyncFile is a file on one side of a sync
+type SyncFile struct {
+	Size int64
+	MD5  string // hex digest, empty if unknown (S3 objects uploaded in parts)
+}
+
+// listedMD5 reports whether the MD5 store listed for name really is one.
+// An S3 listing gives the ETag, which isn't the MD5 of objects encrypted
+// with SSE-KMS or SSE-C, as only a Checksummer's HEAD of the object tells.
+func listedMD5(store Store, name string) (bool, error) {
+	checksummer, ok := store.(Checksummer)
+	if !ok {
+		return true, nil
+	}
+	checksums, err := checksummer.Checksums(name)
+	if err != nil {
+		return false, err
+	}
+	return checksums["md5"] != "", nil
+}
+
+// same reports whether two files have the same content, judged by MD5
+// where both sides know it and by size alone otherwise
+func (f SyncFile) same(other SyncFile) bool {
+	if f.Size != other.Size {
+		return false
+	}
+	return f.MD5 == "" || other.MD5 == "" || f.MD5 == other.MD5
+}
+
+// Store is one side of a sync: a local directory or an S3 prefix. Names
+// are slash-separated paths relative to its root.
+type Store interface {
+	List() (map[string]SyncFile, error)
+	Get(name string) ([]byte, error)
+	Put(name string, data []byte) error
+	Delete(name string) error
+	// String is the store's root as shown to the user
+	String() string
+}
+
+// Sync copies files that are missing or differ from Src to Dst and, with
+// Delete, removes files that exist only in Dst. Plan must be called first
+// and returns the names to run it on.
+type Sync struct {
+	Src, Dst Store
+	Delete   bool
+
+	src, dst map[string]SyncFile
+}
+
+// Plan lists both sides and returns the names that need copying or deleting
+func (op *Sync) Plan() ([]string, error) {
+	var err error
+	if op.src, err = op.Src.List(); err != nil {
+		return nil, err
+	}
+	if op.dst, err = op.Dst.List(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, file := range op.src {
+		existing, ok := op.dst[name]
+		differ := !ok || !file.same(existing)
+		if ok && differ && file.Size == existing.Size {
+			// Files of the same size differ only if both MD5s are real
+			if differ, err = op.realMD5s(name); err != nil {
+				return nil, err
+			}
+		}
+		if differ {
+			names = append(names, name)
+		}
+	}
+	if op.Delete {
+		for name := range op.dst {
+			if _, ok := op.src[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// realMD5s reports whether the MD5s both sides listed for name are real
+func (op *Sync) realMD5s(name string) (bool, error) {
+	for _, store := range []Store{op.Src, op.Dst} {
+		if real, err := listedMD5(store, name); err != nil || !real {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (op *Sync) Describe(name string) string {
+	if _, ok := op.src[name]; ok {
+		return "cp " + join(op.Src.String(), name) + " " + join(op.Dst.String(), name)
+	}
+	return "rm " + join(op.Dst.String(), name)
+}
+
+func (op *Sync) Apply(name string) error {
+	if _, ok := op.src[name]; !ok {
+		return op.Dst.Delete(name)
+	}
+	data, err := op.Src.Get(name)
+	if err != nil {
+		return err
+	}
+	return op.Dst.Put(name, data)
+}
+
+// LocalDir is a Store over a local directory, which need not exist yet
+type LocalDir string
+
+func (d LocalDir) String() string { return string(d) }
+
+func (d LocalDir) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+// List returns every regular file below the directory with its MD5
+func (d LocalDir) List() (map[string]SyncFile, error) {
+	files := make(map[string]SyncFile)
+	err := filepath.WalkDir(string(d), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == string(d) && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(data)
+		files[filepath.ToSlash(rel)] = SyncFile{Size: int64(len(data)), MD5: hex.EncodeToString(sum[:])}
+		return nil
+	})
+	return files, err
+}
+
+func (d LocalDir) Get(name string) ([]byte, error) { return os.ReadFile(d.path(name)) }
+
+func (d LocalDir) Put(name string, data []byte) error {
+	path := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (d LocalDir) Delete(name string) error { return os.Remove(d.path(name)) }
+
+// KeyRemover deletes the file for a resource name below a directory of the
+// mount's tree, as *fs.SisuFS does
+type KeyRemover interface {
+	RemoveKey(dir, key string) error
+}
+
+// S3Prefix is a Store over the objects below Prefix in Bucket, talking to
+// the provider directly so listings carry ETags
+type S3Prefix struct {
+	Provider *provider.S3Provider
+	Bucket   string
+	Prefix   string // without trailing slash; empty for the whole bucket
+	Path     string // the prefix's path in the mount
+
+	// Remover, if set, takes the deletes instead of the provider, so
+	// protected profiles and hooks apply to them as in the mount
+	Remover KeyRemover
+}
+
+func (s S3Prefix) String() string { return s.Path }
+
+func (s S3Prefix) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// List returns every object below the prefix. Objects uploaded in parts
+// have no MD5 and are compared by size.
+func (s S3Prefix) List() (map[string]SyncFile, error) {
+	objects, err := s.Provider.Objects(context.Background(), s.Bucket, s.key(""))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]SyncFile, len(objects))
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, s.key(""))
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue // folder placeholder
+		}
+		file := SyncFile{Size: obj.Size, MD5: obj.ETag}
+		if strings.Contains(obj.ETag, "-") {
+			file.MD5 = ""
+		}
+		files[name] = file
+	}
+	return files, nil
+}
+
+func (s S3Prefix) Get(name string) ([]byte, error) {
+	return s.Provider.Read(context.Background(), s.Bucket+"/"+s.key(name))
+}
+
+func (s S3Prefix) Put(name string, data []byte) error {
+	return s.Provider.Write(context.Background(), s.Bucket+"/"+s.key(name), data)
+}
+
+func (s S3Prefix) Delete(name string) error {
+	if s.Remover != nil {
+		return s.Remover.RemoveKey(s.Path, name)
+	}
+	return s.Provider.Delete(context.Background(), s.Bucket+"/"+s.key(name))
+}
+
+// Checksums returns the checksums the object's metadata reports
+func (s S3Prefix) Checksums(name string) (map[string]string, error) {
+	entry, err := s.Provider.Stat(context.Background(), s.Bucket+"/"+s.key(name))
+	if err != nil {
+		return nil, err
+	}
+	return entry.Checksums, nil
+}
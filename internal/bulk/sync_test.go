@@ -0,0 +1,138 @@
+package bulk
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"index.html":    "<h1>new</h1>",
+		"css/site.css":  "body {}",
+		"img/logo.svg":  "<svg/>",
+		"same-size.txt": "aaaa",
+	})
+	writeFiles(t, dst, map[string]string{
+		"index.html":    "<h1>old</h1>",
+		"css/site.css":  "body {}",
+		"same-size.txt": "bbbb",
+		"stale.txt":     "gone",
+	})
+
+	op := &Sync{Src: LocalDir(src), Dst: LocalDir(dst), Delete: true}
+	names, err := op.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"img/logo.svg", "index.html", "same-size.txt", "stale.txt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("Plan = %v, want %v", names, want)
+	}
+	if got := op.Describe("stale.txt"); got != "rm "+dst+"/stale.txt" {
+		t.Errorf("Describe = %q", got)
+	}
+
+	var out, errOut bytes.Buffer
+	if failed := Run(names, op, 2, false, &out, &errOut); failed > 0 {
+		t.Fatalf("%d operations failed: %s", failed, errOut.String())
+	}
+	if names, err := (&Sync{Src: LocalDir(src), Dst: LocalDir(dst), Delete: true}).Plan(); err != nil || len(names) > 0 {
+		t.Errorf("after sync, Plan = %v, %v; want nothing to do", names, err)
+	}
+}
+
+func TestSyncToMissingDir(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"a.txt": "a"})
+
+	op := &Sync{Src: LocalDir(src), Dst: LocalDir(filepath.Join(t.TempDir(), "new"))}
+	names, err := op.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"a.txt"}) {
+		t.Errorf("Plan = %v, want [a.txt]", names)
+	}
+}
+
+func TestSyncEncryptedETag(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"kms.txt": "hello", "changed.txt": "hello"})
+	dst := memStore{
+		files: map[string]SyncFile{
+			"kms.txt":     {Size: 5, MD5: "ffff"},
+			"changed.txt": {Size: 5, MD5: "0000"},
+		},
+		checksums: map[string]map[string]string{
+			"kms.txt":     {"etag": "ffff"},
+			"changed.txt": {"etag": "0000", "md5": "0000"},
+		},
+	}
+	// The ETag of an SSE-KMS object isn't its MD5: compared by size only
+	names, err := (&Sync{Src: LocalDir(src), Dst: dst}).Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"changed.txt"}) {
+		t.Errorf("Plan = %v, want [changed.txt]", names)
+	}
+}
+
+func TestSyncFileSame(t *testing.T) {
+	tests := []struct {
+		a, b SyncFile
+		want bool
+	}{
+		{SyncFile{4, "abc"}, SyncFile{4, "abc"}, true},
+		{SyncFile{4, "abc"}, SyncFile{4, "def"}, false},
+		{SyncFile{4, "abc"}, SyncFile{4, ""}, true}, // multipart: size only
+		{SyncFile{4, "abc"}, SyncFile{5, ""}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.same(tt.b); got != tt.want {
+			t.Errorf("%v.same(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// lockedTree refuses deletes as a protected profile does before sisu unlock
+type lockedTree struct{ removed *[]string }
+
+func (l lockedTree) RemoveKey(dir, key string) error {
+	*l.removed = append(*l.removed, dir+"/"+key)
+	return syscall.EPERM
+}
+
+func TestSyncDeleteIntoProtectedProfile(t *testing.T) {
+	src := t.TempDir()
+	var removed []string
+	// No Provider: the delete must not reach S3 directly
+	dst := S3Prefix{Bucket: "bucket", Prefix: "www", Path: "prod/global/s3/bucket/www", Remover: lockedTree{&removed}}
+
+	op := &Sync{Src: LocalDir(src), Dst: dst, Delete: true, src: map[string]SyncFile{}}
+	var out, errOut bytes.Buffer
+	if failed := Run([]string{"stale.txt"}, op, 1, false, &out, &errOut); failed != 1 {
+		t.Fatalf("failed = %d, want the delete refused", failed)
+	}
+	if !reflect.DeepEqual(removed, []string{"prod/global/s3/bucket/www/stale.txt"}) {
+		t.Errorf("removed = %v", removed)
+	}
+}
@@ -0,0 +1,106 @@
+package bulk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Verify statuses
+const (
+	VerifyOK       = "ok"
+	VerifyMismatch = "mismatch"
+	VerifyMissing  = "missing"   // no object for the local file
+	VerifySizeOnly = "size only" // sizes match but the object has no comparable checksum
+)
+
+// VerifyResult is the outcome of checking one local file
+type VerifyResult struct {
+	Name   string
+	Status string
+}
+
+// Checksummer is implemented by stores that can look up further checksums
+// of a file, keyed by algorithm as in provider.Entry
+type Checksummer interface {
+	Checksums(name string) (map[string]string, error)
+}
+
+// Verify compares every file in local with the file of the same name in
+// remote: by MD5 where remote knows it (the ETag of objects uploaded in one
+// part without SSE-KMS or SSE-C), else by SHA-256 where remote is a
+// Checksummer with a full-object checksum, else by size alone
+func Verify(local LocalDir, remote Store) ([]VerifyResult, error) {
+	localFiles, err := local.List()
+	if err != nil {
+		return nil, err
+	}
+	remoteFiles, err := remote.List()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]VerifyResult, 0, len(localFiles))
+	for name, file := range localFiles {
+		result := VerifyResult{Name: name}
+		object, ok := remoteFiles[name]
+		switch {
+		case !ok:
+			result.Status = VerifyMissing
+		case object.Size != file.Size:
+			result.Status = VerifyMismatch
+		case object.MD5 == file.MD5:
+			result.Status = VerifyOK
+		default:
+			// A listed MD5 that differs may be the ETag of an encrypted
+			// object, which isn't its MD5
+			real := false
+			if object.MD5 != "" {
+				if real, err = listedMD5(remote, name); err != nil {
+					return nil, err
+				}
+			}
+			if real {
+				result.Status = VerifyMismatch
+			} else if result.Status, err = verifySHA256(local, remote, name); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+// verifySHA256 compares a file by the remote file's SHA-256 checksum, if
+// it has a full-object one
+func verifySHA256(local LocalDir, remote Store, name string) (string, error) {
+	checksummer, ok := remote.(Checksummer)
+	if !ok {
+		return VerifySizeOnly, nil
+	}
+	checksums, err := checksummer.Checksums(name)
+	if err != nil {
+		return "", err
+	}
+	sum := checksums["sha256"]
+	if sum == "" || strings.Contains(sum, "-") {
+		return VerifySizeOnly, nil
+	}
+
+	data, err := os.ReadFile(local.path(name))
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return status(hex.EncodeToString(digest[:]) == sum), nil
+}
+
+func status(match bool) string {
+	if match {
+		return VerifyOK
+	}
+	return VerifyMismatch
+}
@@ -0,0 +1,66 @@
+package bulk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// memStore is a Store of precomputed file summaries with optional checksums
+type memStore struct {
+	files     map[string]SyncFile
+	checksums map[string]map[string]string
+}
+
+func (s memStore) List() (map[string]SyncFile, error) { return s.files, nil }
+func (s memStore) Get(name string) ([]byte, error)    { return nil, nil }
+func (s memStore) Put(name string, data []byte) error { return nil }
+func (s memStore) Delete(name string) error           { return nil }
+func (s memStore) String() string                     { return "mem" }
+func (s memStore) Checksums(name string) (map[string]string, error) {
+	return s.checksums[name], nil
+}
+
+func TestVerify(t *testing.T) {
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{
+		"same.txt":      "hello",
+		"changed.txt":   "hello",
+		"missing.txt":   "hello",
+		"multipart.bin": "hello",
+		"composite.bin": "hello",
+		"kms.txt":       "hello",
+	})
+	const helloMD5 = "5d41402abc4b2a76b9719d911017c592"
+	const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	remote := memStore{
+		files: map[string]SyncFile{
+			"same.txt":      {Size: 5, MD5: helloMD5},
+			"changed.txt":   {Size: 5, MD5: "0000"},
+			"multipart.bin": {Size: 5},
+			"composite.bin": {Size: 5},
+			"kms.txt":       {Size: 5, MD5: "ffff"},
+		},
+		checksums: map[string]map[string]string{
+			"multipart.bin": {"sha256": helloSHA256},
+			"changed.txt":   {"md5": "0000"},
+			"composite.bin": {"sha256": "abcd-2"},
+			"kms.txt":       {"etag": "ffff", "sha256": helloSHA256},
+		},
+	}
+
+	results, err := Verify(LocalDir(local), remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VerifyResult{
+		{"changed.txt", VerifyMismatch},
+		{"composite.bin", VerifySizeOnly},
+		{"kms.txt", VerifyOK},
+		{"missing.txt", VerifyMissing},
+		{"multipart.bin", VerifyOK},
+		{"same.txt", VerifyOK},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Verify = %v, want %v", results, want)
+	}
+}
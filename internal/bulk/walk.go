@@ -0,0 +1,121 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/semonte/sisu/internal/paging"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// WalkEntry is a line of Walk's output
+type WalkEntry struct {
+	Path  string `json:"path"` // relative to the mount root
+	Dir   bool   `json:"dir"`
+	Depth int    `json:"depth"`          // 1 for the entries of Root
+	Link  string `json:"link,omitempty"` // target of a symlink, relative to its directory
+}
+
+// Walk lists the tree below Root breadth-first, one directory at a time
+// and at most Rate listings per second, so scripts can traverse a large
+// account without the burst of calls find makes over the mount. The pages
+// of a long listing count as listings of their own and are merged into
+// it. Files are never read, and symlinks are printed with their target
+// but not followed.
+type Walk struct {
+	Tree     Tree
+	Root     string
+	MaxDepth int     // deepest level listed, 0 for no limit
+	Rate     float64 // listings per second, 0 for no limit
+	// Exclude, if set, leaves out the files and directories it matches
+	Exclude func(name string) bool
+}
+
+// Run writes each entry to out as a line of JSON as soon as its directory
+// is listed, parents before children, and failures to errOut. It returns
+// the number of directories that couldn't be listed, or an error if Root
+// itself can't be or ctx is done.
+func (w Walk) Run(ctx context.Context, out, errOut io.Writer) (int, error) {
+	limit := rate.Inf
+	if w.Rate > 0 {
+		limit = rate.Limit(w.Rate)
+	}
+	limiter := rate.NewLimiter(limit, 1)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	root := strings.Trim(w.Root, "/")
+	failed := 0
+	level := []string{root}
+	for depth := 1; len(level) > 0 && (w.MaxDepth <= 0 || depth <= w.MaxDepth); depth++ {
+		var next []string
+		for _, dir := range level {
+			// Each page of a long listing is a listing of its own, and its
+			// entries are printed under their real paths
+			var waitErr error
+			entries, err := paging.ListAll(dir, func(listing string) ([]provider.Entry, error) {
+				if waitErr = limiter.Wait(ctx); waitErr != nil {
+					return nil, waitErr
+				}
+				return w.Tree.List(listing)
+			}, func(e provider.Entry) string { return e.Name })
+			if waitErr != nil {
+				return failed, waitErr
+			}
+			if err != nil {
+				if dir == root {
+					return 0, err
+				}
+				failed++
+				fmt.Fprintf(errOut, "%s: %v\n", dir, err)
+				continue
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+			for _, e := range entries {
+				name := join(dir, e.Name)
+				if w.Exclude != nil && w.Exclude(name) {
+					continue
+				}
+				if err := enc.Encode(WalkEntry{Path: name, Dir: e.IsDir, Depth: depth, Link: e.Link}); err != nil {
+					return failed, err
+				}
+				if e.IsDir {
+					next = append(next, name)
+				}
+			}
+		}
+		level = next
+	}
+	return failed, nil
+}
+
+// ParseRate parses a rate such as "5/s", "120/m" or "1000/h" into events
+// per second. A bare number is per second.
+func ParseRate(s string) (float64, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	per := time.Second
+	if ok {
+		switch unit {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return 0, fmt.Errorf("invalid rate %q: the unit must be s, m or h, e.g. 5/s", s)
+		}
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: want a positive count, e.g. 5/s", s)
+	}
+	return n / per.Seconds(), nil
+}
@@ -0,0 +1,164 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func walkEntries(t *testing.T, data []byte) []WalkEntry {
+	t.Helper()
+	var entries []WalkEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e WalkEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestWalkBreadthFirst(t *testing.T) {
+	tree := newMemTree(
+		"prod/global/iam/roles/a/info.json",
+		"prod/global/iam/users/u/info.json",
+		"prod/us-east-1/ssm/app/db-url",
+		"prod/changes.log",
+	)
+	var out, errOut bytes.Buffer
+	w := Walk{
+		Tree:     tree,
+		Root:     "/prod/",
+		MaxDepth: 3,
+		Exclude:  func(name string) bool { return name == "prod/changes.log" },
+	}
+	failed, err := w.Run(context.Background(), &out, &errOut)
+	if err != nil || failed != 0 {
+		t.Fatalf("Run = %d, %v: %s", failed, err, errOut.String())
+	}
+	want := []WalkEntry{
+		{Path: "prod/global", Dir: true, Depth: 1},
+		{Path: "prod/us-east-1", Dir: true, Depth: 1},
+		{Path: "prod/global/iam", Dir: true, Depth: 2},
+		{Path: "prod/us-east-1/ssm", Dir: true, Depth: 2},
+		{Path: "prod/global/iam/roles", Dir: true, Depth: 3},
+		{Path: "prod/global/iam/users", Dir: true, Depth: 3},
+		{Path: "prod/us-east-1/ssm/app", Dir: true, Depth: 3},
+	}
+	if got := walkEntries(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+	// Directories at MaxDepth are printed but not listed
+	for _, dir := range tree.listed {
+		if strings.Count(dir, "/") > 2 {
+			t.Errorf("listed %s below the maximum depth", dir)
+		}
+	}
+	if len(tree.files) != 4 {
+		t.Error("walk changed the tree")
+	}
+}
+
+func TestWalkFailures(t *testing.T) {
+	tree := unlistableTree{memTree: newMemTree("p/iam/roles/a.json", "p/ssm/x"), dir: "p/iam"}
+	var out, errOut bytes.Buffer
+	failed, err := Walk{Tree: tree, Root: "p"}.Run(context.Background(), &out, &errOut)
+	if err != nil || failed != 1 {
+		t.Fatalf("Run = %d, %v, want 1 failure", failed, err)
+	}
+	if !strings.HasPrefix(errOut.String(), "p/iam: ") {
+		t.Errorf("errors = %q", errOut.String())
+	}
+	if got := walkEntries(t, out.Bytes()); len(got) != 3 || got[2].Path != "p/ssm/x" || got[2].Dir {
+		t.Errorf("entries = %+v", got)
+	}
+
+	if _, err := (Walk{Tree: tree, Root: "nothing"}).Run(context.Background(), &out, &errOut); err == nil {
+		t.Error("walking a missing root succeeded")
+	}
+}
+
+func TestWalkRate(t *testing.T) {
+	tree := newMemTree("a/b/c/d")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var out bytes.Buffer
+	// The first listing is free, the second would wait a second
+	_, err := Walk{Tree: tree, Root: "a", Rate: 1}.Run(ctx, &out, &out)
+	if err == nil {
+		t.Fatal("Run didn't wait for the rate limit")
+	}
+	if len(tree.listed) != 1 {
+		t.Errorf("listed %v, want only the root before the deadline", tree.listed)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]float64{"5/s": 5, "120/m": 2, "3600/h": 1, "0.5": 0.5} {
+		if got, err := ParseRate(in); err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "5/d", "-1/s", "fast"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", in)
+		}
+	}
+}
+
+// linkTree lists name as a symlink to target
+type linkTree struct {
+	*memTree
+	name, target string
+}
+
+func (t linkTree) List(dir string) ([]provider.Entry, error) {
+	entries, err := t.memTree.List(dir)
+	for i, e := range entries {
+		if join(dir, e.Name) == t.name {
+			entries[i] = provider.Entry{Name: e.Name, Link: t.target}
+		}
+	}
+	return entries, err
+}
+
+func TestWalkRecordsLinks(t *testing.T) {
+	tree := linkTree{memTree: newMemTree("p/topology/consumers/queue/x", "p/topology/queues/orders/x"), name: "p/topology/consumers/queue", target: "../queues/orders"}
+	var out bytes.Buffer
+	if _, err := (Walk{Tree: tree, Root: "p/topology/consumers"}).Run(context.Background(), &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := []WalkEntry{{Path: "p/topology/consumers/queue", Depth: 1, Link: "../queues/orders"}}
+	if got := walkEntries(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestWalkMergesPages(t *testing.T) {
+	tree := newMemTree("p/big/a/x", "p/big/_page2/b")
+	var out bytes.Buffer
+	if _, err := (Walk{Tree: tree, Root: "p"}).Run(context.Background(), &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := []WalkEntry{
+		{Path: "p/big", Dir: true, Depth: 1},
+		{Path: "p/big/a", Dir: true, Depth: 2},
+		{Path: "p/big/b", Depth: 2},
+		{Path: "p/big/a/x", Depth: 3},
+	}
+	if got := walkEntries(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+	// The page is listed on its own, under the rate limit
+	if !slices.Contains(tree.listed, "p/big/_page2") {
+		t.Errorf("listed %v, want the page", tree.listed)
+	}
+}
@@ -20,6 +20,9 @@ type Cache struct {
 	mu      sync.RWMutex
 	entries map[string]Entry
 	ttl     time.Duration
+
+	done      chan struct{} // closed by Close to stop cleanup
+	closeOnce sync.Once
 }
 
 // New creates a new cache with the given TTL
@@ -27,6 +30,7 @@ func New(ttl time.Duration) *Cache {
 	c := &Cache{
 		entries: make(map[string]Entry),
 		ttl:     ttl,
+		done:    make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -94,6 +98,20 @@ func (c *Cache) Delete(key string) {
 	delete(c.entries, key)
 }
 
+// Range calls fn with each entry that hasn't expired. fn must not call
+// back into the cache.
+func (c *Cache) Range(fn func(key string, value interface{})) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !now.After(entry.ExpiresAt) {
+			fn(key, entry.Value)
+		}
+	}
+}
+
 // Clear removes all entries from the cache
 func (c *Cache) Clear() {
 	c.mu.Lock()
@@ -102,12 +120,23 @@ func (c *Cache) Clear() {
 	c.entries = make(map[string]Entry)
 }
 
-// cleanup periodically removes expired entries
+// Close stops removing expired entries in the background. The cache still
+// works, but should be dropped.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// cleanup periodically removes expired entries until Close
 func (c *Cache) cleanup() {
 	ticker := time.NewTicker(c.ttl)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
 		c.mu.Lock()
 		now := time.Now()
 		for key, entry := range c.entries {
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings loaded from ~/.sisu/config.yaml
+type Config struct {
+	// Writable restricts writes to matching paths, e.g. "s3://my-bucket/*"
+	// or "/app/config/*" (SSM). Empty means every writable service is writable.
+	Writable []string `yaml:"writable"`
+
+	// Write turns writes on or off per service, e.g. `s3: true` or
+	// `ssm: false`, or limits them to a named scope like `lambda: env-only`.
+	// Services left out keep their default; Lambda is read-only by default.
+	Write map[string]WriteMode `yaml:"write"`
+
+	// MaxEntries caps directory listings; 0 keeps the built-in default
+	MaxEntries int `yaml:"max_entries"`
+
+	// Render lists the JSON documents services generate in another
+	// format, e.g. `iam: yaml` lists policy.yaml instead of policy.json.
+	// Keys are a service, a directory of one like `iam/roles`, which
+	// overrides the service's format below it, or "*" for every service.
+	Render map[string]string `yaml:"render"`
+
+	// MaxReadMB caps the size of files read whole into memory, e.g. opened
+	// for editing; 0 keeps the built-in default and -1 removes the limit
+	MaxReadMB int `yaml:"max_read_mb"`
+
+	// CaseInsensitive renames entries whose names differ only by case, for
+	// clients on case-insensitive filesystems (e.g. an SMB re-export)
+	CaseInsensitive bool `yaml:"case_insensitive"`
+
+	// SSMAutoAdvancedTier stores SSM values over 4 KB as advanced-tier
+	// parameters (billed per parameter) instead of rejecting the write
+	SSMAutoAdvancedTier bool `yaml:"ssm_auto_advanced_tier"`
+
+	// SSMExactValues shows SSM values byte for byte instead of appending a
+	// newline on read and dropping one on write
+	SSMExactValues bool `yaml:"ssm_exact_values"`
+
+	// RoundTrip makes reads and writes byte-identical: generated JSON
+	// documents use one canonical form and SSM values are kept exact
+	RoundTrip bool `yaml:"round_trip"`
+
+	// SSMParameters set the tier, description and tags of SSM parameters
+	// written under matching paths
+	SSMParameters []SSMParameter `yaml:"ssm_parameters"`
+
+	// Endpoints mount JSON REST APIs as extra services under global/
+	Endpoints []Endpoint `yaml:"endpoints"`
+
+	// GCP mounts Google Cloud projects under gcp/ at the mount root
+	GCP GCP `yaml:"gcp"`
+
+	// Azure mounts Azure subscriptions under azure/ at the mount root
+	Azure Azure `yaml:"azure"`
+
+	// ProductionProfiles are profile names or globs (e.g. "prod*") shown
+	// in red in the shell prompt
+	ProductionProfiles []string `yaml:"production_profiles"`
+
+	// ProtectedProfiles are profile names or globs where deletes are
+	// refused until armed with `sisu unlock <profile>`
+	ProtectedProfiles []string `yaml:"protected_profiles"`
+
+	// Profiles annotate individual profiles in the shell prompt
+	Profiles map[string]ProfileStyle `yaml:"profiles"`
+
+	// HideDenied leaves services the credentials can't list out of region
+	// listings, instead of showing them with an _access-denied.txt explainer
+	HideDenied bool `yaml:"hide_denied"`
+
+	// DirInfo adds a .dirinfo.json to every directory of a service,
+	// summarizing its entries with their size, state and tags
+	DirInfo bool `yaml:"dir_info"`
+
+	// Index walks paths in the background to keep a local search index
+	Index Index `yaml:"index"`
+
+	// Hooks run after every successful write or delete through the mount
+	Hooks []Hook `yaml:"hooks"`
+
+	// ChangeJournal appends the changes observed in refetched listings to
+	// ~/.sisu/changes.log, besides each profile's changes.log in the mount
+	ChangeJournal bool `yaml:"change_journal"`
+
+	// Credentials give profiles a credential source other than the shared
+	// AWS config, keyed by profile name. Profiles listed here are mounted
+	// even if ~/.aws doesn't define them.
+	Credentials map[string]Credentials `yaml:"credentials"`
+
+	// WarmUp lists services in the background right after mounting, so the
+	// first ls of them is served from the cache
+	WarmUp WarmUp `yaml:"warm_up"`
+}
+
+// WarmUp selects the service directories listed after mounting: each of
+// Services in every region of each profile
+type WarmUp struct {
+	Services []string `yaml:"services"` // e.g. [ec2, lambda]; none disables warm-up
+	Profiles []string `yaml:"profiles"` // names or globs; default the --profile, else all
+	Parallel int      `yaml:"parallel"` // listings run at once (default 4)
+}
+
+// Credentials is where a profile's AWS credentials come from; exactly one
+// source must be set
+type Credentials struct {
+	// Process is a command printing credentials in the credential_process
+	// JSON format, e.g. "aws-vault exec prod --json"
+	Process string `yaml:"process"`
+	// Vault reads credentials from a HashiCorp Vault AWS secrets engine
+	Vault *Vault `yaml:"vault"`
+	// Env names environment variables holding the credentials, e.g. ones
+	// injected by a CI system
+	Env *EnvCredentials `yaml:"env"`
+}
+
+// Vault locates credentials in a Vault AWS secrets engine. The token comes
+// from $VAULT_TOKEN or ~/.vault-token.
+type Vault struct {
+	// Address defaults to $VAULT_ADDR
+	Address string `yaml:"address"`
+	// Path is the credentials endpoint, e.g. "aws/creds/readonly" or
+	// "aws/sts/deploy"
+	Path string `yaml:"path"`
+}
+
+// EnvCredentials names the environment variables holding credentials
+type EnvCredentials struct {
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"` // optional
+}
+
+// Validate checks exactly one credential source is fully configured
+func (c Credentials) Validate() error {
+	sources := 0
+	if c.Process != "" {
+		sources++
+	}
+	if c.Vault != nil {
+		sources++
+		if c.Vault.Path == "" {
+			return fmt.Errorf("vault credentials need a path, e.g. aws/creds/<role>")
+		}
+	}
+	if c.Env != nil {
+		sources++
+		if c.Env.AccessKeyID == "" || c.Env.SecretAccessKey == "" {
+			return fmt.Errorf("env credentials need access_key_id and secret_access_key variable names")
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("set exactly one of process, vault or env")
+	}
+	return nil
+}
+
+// Index configures the background indexer behind .sisu/search and sisu find
+type Index struct {
+	// Paths are walked relative to the mount root, e.g. "prod/us-east-1/ec2"
+	Paths []string `yaml:"paths"`
+	// Rate is the number of listings and reads per second (default 2)
+	Rate float64 `yaml:"rate"`
+	// Interval is how often each path is walked again, e.g. "6h" (default 1h)
+	Interval time.Duration `yaml:"interval"`
+}
+
+// WriteMode is a service's write setting: "true", "false" or a scope name.
+// It accepts YAML booleans as well as strings.
+type WriteMode string
+
+func (m *WriteMode) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: write mode must be true, false or a scope name", value.Line)
+	}
+	*m = WriteMode(value.Value)
+	return nil
+}
+
+// ProfileStyle is how a profile appears in the shell prompt
+type ProfileStyle struct {
+	// Color is red, green, yellow, blue, magenta or cyan
+	Color string `yaml:"color"`
+	// Emoji is shown in front of the prompt, e.g. "🔥"
+	Emoji string `yaml:"emoji"`
+}
+
+// GCP lists the Google Cloud projects to mount; credentials come from gcloud
+type GCP struct {
+	Projects []string `yaml:"projects"`
+}
+
+// Azure lists the Azure subscriptions to mount; credentials come from the az CLI
+type Azure struct {
+	Subscriptions []string `yaml:"subscriptions"`
+}
+
+// Endpoint describes a JSON REST API mounted as a read-only service
+type Endpoint struct {
+	// Name is the service directory, e.g. "inventory" for <profile>/global/inventory
+	Name    string `yaml:"name"`
+	BaseURL string `yaml:"base_url"`
+	// Headers are sent with every request; values may reference environment
+	// variables as $VAR or ${VAR}, e.g. "Authorization: Bearer ${TOKEN}"
+	Headers     map[string]string `yaml:"headers"`
+	Collections []Collection      `yaml:"collections"`
+}
+
+// Collection maps one API listing to a directory of JSON files
+type Collection struct {
+	// Path is the directory name within the service
+	Path string `yaml:"path"`
+	// List is the URL path, relative to BaseURL, returning the items
+	List string `yaml:"list"`
+	// ItemsField names the field holding the items when the listing is an
+	// object rather than an array, e.g. "data"
+	ItemsField string `yaml:"items_field"`
+	// NameField names the item field used as the filename (default "id");
+	// listings of plain strings use the string itself
+	NameField string `yaml:"name_field"`
+	// Item is the URL path of a single item with {name} as placeholder.
+	// If empty, files show the item as returned by the listing.
+	Item string `yaml:"item"`
+}
+
+// Validate checks the endpoint is usable
+func (e Endpoint) Validate() error {
+	if e.Name == "" || strings.Contains(e.Name, "/") {
+		return fmt.Errorf("endpoint needs a name without slashes")
+	}
+	if e.BaseURL == "" {
+		return fmt.Errorf("endpoint %s: base_url is required", e.Name)
+	}
+	seen := make(map[string]bool)
+	for _, c := range e.Collections {
+		if c.Path == "" || strings.Contains(c.Path, "/") {
+			return fmt.Errorf("endpoint %s: collection path must be a single directory name", e.Name)
+		}
+		if seen[c.Path] {
+			return fmt.Errorf("endpoint %s: duplicate collection %s", e.Name, c.Path)
+		}
+		seen[c.Path] = true
+		if c.List == "" {
+			return fmt.Errorf("endpoint %s: collection %s needs a list path", e.Name, c.Path)
+		}
+	}
+	return nil
+}
+
+// Hook notifies a command or a webhook of a change made through the mount
+type Hook struct {
+	// Command runs with sh -c, getting the event as JSON on stdin and as
+	// SISU_OPERATION, SISU_PATH etc. in its environment
+	Command string `yaml:"command"`
+	// URL receives the event as a JSON POST, e.g. a Slack incoming webhook
+	URL string `yaml:"url"`
+	// Headers are sent with webhook requests; values may reference
+	// environment variables as $VAR or ${VAR}
+	Headers map[string]string `yaml:"headers"`
+	// Paths limits the hook to matching paths, written like Writable
+	Paths []string `yaml:"paths"`
+}
+
+// Validate checks the hook has exactly one target and valid path patterns
+func (h Hook) Validate() error {
+	if (h.Command == "") == (h.URL == "") {
+		return fmt.Errorf("hook needs exactly one of command or url")
+	}
+	if h.URL != "" && !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("hook url %q must be http or https", h.URL)
+	}
+	_, err := ParsePatterns(h.Paths)
+	return err
+}
+
+// SSMParameter applies settings to SSM parameters matching Path, a pattern
+// like "/app/prod/*"
+type SSMParameter struct {
+	Path        string            `yaml:"path"`
+	Tier        string            `yaml:"tier"`
+	Description string            `yaml:"description"`
+	Tags        map[string]string `yaml:"tags"`
+}
+
+// DefaultPath returns the default config file location
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sisu", "config.yaml")
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
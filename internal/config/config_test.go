@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadWriteModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "write: { s3: true, ssm: false, lambda: env-only }\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]WriteMode{"s3": "true", "ssm": "false", "lambda": "env-only"}
+	if !reflect.DeepEqual(cfg.Write, want) {
+		t.Errorf("Write = %v, want %v", cfg.Write, want)
+	}
+}
+
+func TestLoadCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `credentials:
+  ci:
+    env: { access_key_id: CI_KEY, secret_access_key: CI_SECRET }
+  prod:
+    vault: { path: aws/sts/deploy }
+  both:
+    process: creds --json
+    vault: { path: aws/creds/readonly }
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Credentials["ci"].Validate(); err != nil {
+		t.Errorf("ci: %v", err)
+	}
+	if err := cfg.Credentials["prod"].Validate(); err != nil {
+		t.Errorf("prod: %v", err)
+	}
+	if err := cfg.Credentials["both"].Validate(); err == nil {
+		t.Error("two sources: want an error")
+	}
+	if err := (Credentials{Vault: &Vault{}}).Validate(); err == nil {
+		t.Error("vault without path: want an error")
+	}
+}
+
+func TestHookValidate(t *testing.T) {
+	valid := []Hook{
+		{Command: "notify-send sisu"},
+		{URL: "https://hooks.slack.com/services/T0/B0/x", Paths: []string{"s3://prod-*"}},
+	}
+	for _, h := range valid {
+		if err := h.Validate(); err != nil {
+			t.Errorf("%+v: %v", h, err)
+		}
+	}
+	invalid := []Hook{
+		{},
+		{Command: "true", URL: "https://example.com"},
+		{URL: "ftp://example.com"},
+		{Command: "true", Paths: []string{"no-service"}},
+	}
+	for _, h := range invalid {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%+v: want an error", h)
+		}
+	}
+}
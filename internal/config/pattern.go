@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathPattern matches provider paths within a service.
+// Patterns are written as "<service>://<path>", e.g. "s3://my-bucket/*".
+// A bare pattern starting with "/" is shorthand for an SSM parameter path.
+// "*" matches any sequence of characters, including "/".
+type PathPattern struct {
+	Service string
+	prefix  string // literal text before the first wildcard
+	re      *regexp.Regexp
+}
+
+// ParsePattern parses a single path pattern
+func ParsePattern(s string) (PathPattern, error) {
+	service, path, ok := strings.Cut(s, "://")
+	if !ok {
+		if !strings.HasPrefix(s, "/") {
+			return PathPattern{}, fmt.Errorf("invalid pattern %q: expected <service>://<path> or /ssm/path", s)
+		}
+		service, path = "ssm", s
+	}
+	if service == "ssm" {
+		// SSM provider paths have no leading slash
+		path = strings.TrimPrefix(path, "/")
+	}
+
+	prefix, _, _ := strings.Cut(path, "*")
+	quoted := regexp.QuoteMeta(path)
+	expr := "^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$"
+	return PathPattern{Service: service, prefix: prefix, re: regexp.MustCompile(expr)}, nil
+}
+
+// Match reports whether the pattern covers path in service
+func (p PathPattern) Match(service, path string) bool {
+	return p.Service == service && p.re.MatchString(path)
+}
+
+// MayContain reports whether paths under dir could match the pattern,
+// i.e. whether creating files somewhere below dir may be allowed
+func (p PathPattern) MayContain(service, dir string) bool {
+	if p.Service != service {
+		return false
+	}
+	if dir != "" {
+		dir += "/"
+	}
+	return strings.HasPrefix(p.prefix, dir) || strings.HasPrefix(dir, p.prefix)
+}
+
+// PatternList is a set of patterns; a path matches if any pattern does
+type PatternList []PathPattern
+
+// ParsePatterns parses every pattern in specs
+func ParsePatterns(specs []string) (PatternList, error) {
+	list := make(PatternList, 0, len(specs))
+	for _, s := range specs {
+		p, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Match reports whether any pattern covers path in service
+func (l PatternList) Match(service, path string) bool {
+	for _, p := range l {
+		if p.Match(service, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// MayContain reports whether any pattern may match paths under dir
+func (l PatternList) MayContain(service, dir string) bool {
+	for _, p := range l {
+		if p.MayContain(service, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package config
+
+import "testing"
+
+func TestPathPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern, service, path string
+		want                   bool
+	}{
+		{"s3://my-bucket/*", "s3", "my-bucket/a/b.txt", true},
+		{"s3://my-bucket/*", "s3", "other-bucket/a.txt", false},
+		{"s3://my-bucket/*", "ssm", "my-bucket/a.txt", false},
+		{"/app/config/*", "ssm", "app/config/db-url", true},
+		{"ssm:///app/config/*", "ssm", "app/config/db-url", true},
+		{"/app/config/*", "ssm", "app/secrets/key", false},
+		{"s3://logs/*.txt", "s3", "logs/2024/x.txt", true},
+		{"s3://logs/*.txt", "s3", "logs/x.json", false},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q): %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.service, tt.path); got != tt.want {
+			t.Errorf("%q.Match(%q, %q) = %v, want %v", tt.pattern, tt.service, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathPatternMayContain(t *testing.T) {
+	p, err := ParsePattern("s3://my-bucket/config/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, want := range map[string]bool{
+		"":                     true,
+		"my-bucket":            true,
+		"my-bucket/config":     true,
+		"my-bucket/config/sub": true,
+		"my-bucket/logs":       false,
+		"other-bucket":         false,
+	} {
+		if got := p.MayContain("s3", dir); got != want {
+			t.Errorf("MayContain(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestParsePatternRejectsRelativePath(t *testing.T) {
+	if _, err := ParsePattern("app/config/*"); err == nil {
+		t.Error("expected error for pattern without service or leading slash")
+	}
+}
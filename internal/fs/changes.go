@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// ChangesFile is the virtual file in each profile directory listing the
+// changes observed in that profile's listings since mount
+const ChangesFile = "changes.log"
+
+// maxChanges caps the changes kept per profile; older ones are dropped
+const maxChanges = 1000
+
+// changeLog collects the changes observed when cached listings are fetched
+// again, per profile, and appends them to the local journal if configured
+type changeLog struct {
+	mu      sync.Mutex
+	lines   map[string][]string // by profile
+	journal string              // file every change is appended to ("" = none)
+}
+
+func newChangeLog(journal string) *changeLog {
+	return &changeLog{lines: make(map[string][]string), journal: journal}
+}
+
+// record adds a change below dir, the mount path of a service
+func (c *changeLog) record(dir string, change provider.Change) {
+	path := joinPath(dir, change.Path)
+	line := fmt.Sprintf("%s\t%s\t%s\n", change.Time.UTC().Format(time.RFC3339), change.Kind, path)
+	profile, _, _ := strings.Cut(path, "/")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lines := append(c.lines[profile], line)
+	if len(lines) > maxChanges {
+		lines = lines[len(lines)-maxChanges:]
+	}
+	c.lines[profile] = lines
+
+	if c.journal != "" {
+		if err := appendFile(c.journal, line); err != nil {
+			log.Printf("[fs] change journal: %v", err)
+		}
+	}
+}
+
+// data returns the content of a profile's changes.log
+func (c *changeLog) data(profile string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return []byte(strings.Join(c.lines[profile], ""))
+}
+
+func appendFile(name, s string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(s); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,39 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestChangeLog(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "sisu", "changes.log")
+	c := newChangeLog(journal)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	c.record("prod/us-east-1/ec2", provider.Change{Time: now, Kind: provider.ChangeAdded, Path: "i-0abc"})
+	c.record("dev/global/s3", provider.Change{Time: now, Kind: provider.ChangeRemoved, Path: "bucket/key.txt"})
+
+	want := "2026-10-16T12:00:00Z\tadded\tprod/us-east-1/ec2/i-0abc\n"
+	if got := string(c.data("prod")); got != want {
+		t.Errorf("prod changes.log = %q, want %q", got, want)
+	}
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("journal has %d lines, want 2:\n%s", lines, data)
+	}
+
+	for i := 0; i < maxChanges+10; i++ {
+		c.record("dev/global/s3", provider.Change{Time: now, Kind: provider.ChangeAdded, Path: "bucket"})
+	}
+	if lines := strings.Count(string(c.data("dev")), "\n"); lines != maxChanges {
+		t.Errorf("dev changes.log has %d lines, want %d", lines, maxChanges)
+	}
+}
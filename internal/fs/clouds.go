@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// cloudPrefix starts the names of non-AWS clouds at the root, e.g. "@gcp",
+// keeping them apart from the AWS profiles listed next to them
+const cloudPrefix = "@"
+
+// cloud is a non-AWS cloud mounted at the root next to the AWS profiles.
+// Its scopes (GCP projects, Azure subscriptions) take the place of regions,
+// so @gcp/<project>/<service>/... parses like <profile>/<region>/<service>/...
+type cloud struct {
+	scopes      []string
+	services    []string
+	newProvider func(scope, service string) provider.Provider
+}
+
+// newClouds returns the clouds with at least one configured scope
+func newClouds(cfg Config) map[string]cloud {
+	clouds := make(map[string]cloud)
+	if len(cfg.GCPProjects) > 0 {
+		clouds[cloudPrefix+"gcp"] = cloud{
+			scopes:   cfg.GCPProjects,
+			services: []string{"gcs", "secrets"},
+			newProvider: func(project, service string) provider.Provider {
+				if service == "gcs" {
+					return provider.NewGCSProvider(project)
+				}
+				return provider.NewSecretManagerProvider(project)
+			},
+		}
+	}
+	if len(cfg.AzureSubs) > 0 {
+		clouds[cloudPrefix+"azure"] = cloud{
+			scopes:   cfg.AzureSubs,
+			services: []string{"blob", "keyvault"},
+			newProvider: func(subscription, service string) provider.Provider {
+				if service == "blob" {
+					return provider.NewAzureBlobProvider(subscription)
+				}
+				return provider.NewKeyVaultProvider(subscription)
+			},
+		}
+	}
+	return clouds
+}
+
+func (c cloud) hasScope(scope string) bool {
+	for _, s := range c.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (c cloud) hasService(service string) bool {
+	for _, s := range c.services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// names returns the directory names at a level above the services: the
+// scopes at the cloud's root, or the services inside a scope
+func (c cloud) names(scope string) []string {
+	if scope == "" {
+		return c.scopes
+	}
+	return c.services
+}
@@ -0,0 +1,227 @@
+package fs
+
+import (
+	"encoding/json"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/semonte/sisu/internal/index"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// ControlDir is the virtual directory at the mount root exposing sisu's own state
+const ControlDir = ".sisu"
+
+// Stats is the content of .sisu/stats.json
+type Stats struct {
+	MountedAt time.Time                                   `json:"mounted_at"`
+	Services  map[string]map[provider.Op]provider.OpStats `json:"services"`
+	// APICalls counts requests sent to AWS per service and operation
+	APICalls map[string]map[string]int64 `json:"api_calls"`
+	// Backoff shows the services AWS has throttled, by "profile/service",
+	// and how much longer their results are cached for it
+	Backoff map[string]provider.BackoffState `json:"backoff,omitempty"`
+	// Cache is the memory held by each provider's result cache, by the
+	// mount path of its service, e.g. "default/us-east-1/lambda"
+	Cache            map[string]provider.CacheStats `json:"cache,omitempty"`
+	EstimatedCostUSD float64                        `json:"estimated_cost_usd"`
+	CostNote         string                         `json:"cost_note"`
+	// WarmUp is the progress of listing services after mounting, if configured
+	WarmUp *WarmUpProgress `json:"warm_up,omitempty"`
+}
+
+// Stats returns a snapshot of provider and AWS API activity since mount
+func (f *SisuFS) Stats() Stats {
+	f.providersMu.RLock()
+	services := make(map[string]map[provider.Op]provider.OpStats, len(f.metrics))
+	for service, m := range f.metrics {
+		services[service] = m.Snapshot()
+	}
+	var backoff map[string]provider.BackoffState
+	for service, b := range f.backoffs {
+		if state := b.State(); state.Throttles > 0 {
+			if backoff == nil {
+				backoff = make(map[string]provider.BackoffState)
+			}
+			backoff[service] = state
+		}
+	}
+	var caches map[string]provider.CacheStats
+	for key, p := range f.providers {
+		if c, ok := p.(interface{ CacheStats() provider.CacheStats }); ok {
+			if caches == nil {
+				caches = make(map[string]provider.CacheStats)
+			}
+			key := splitProviderKey(key)
+			service := key[strings.LastIndex(key, "/")+1:]
+			caches[f.serviceDir(key, service)] = c.CacheStats()
+		}
+	}
+	f.providersMu.RUnlock()
+
+	calls := provider.Usage.Snapshot()
+	return Stats{
+		MountedAt:        f.mountTime,
+		Services:         services,
+		APICalls:         calls,
+		Backoff:          backoff,
+		Cache:            caches,
+		EstimatedCostUSD: provider.EstimateCost(calls),
+		CostNote:         provider.CostNote,
+		WarmUp:           f.warmUp.snapshot(),
+	}
+}
+
+// controlFiles generate the content of each file in ControlDir
+var controlFiles = map[string]func(f *SisuFS) ([]byte, error){
+	"stats.json": func(f *SisuFS) ([]byte, error) {
+		data, err := json.MarshalIndent(f.Stats(), "", "  ")
+		return append(data, '\n'), err
+	},
+	ArmFile: func(f *SisuFS) ([]byte, error) {
+		return f.protection.status(time.Now()), nil
+	},
+	RefreshFile: func(f *SisuFS) ([]byte, error) {
+		return nil, nil
+	},
+	CredentialsFile: func(f *SisuFS) ([]byte, error) {
+		return f.credentialsStatus(), nil
+	},
+	DuplicatesFile: func(f *SisuFS) ([]byte, error) {
+		return f.duplicatesData()
+	},
+}
+
+// controlMode returns the permissions of a control file; only the arm,
+// refresh and credentials files are writable
+func controlMode(name string) uint32 {
+	switch name {
+	case ControlDir + "/" + ArmFile, ControlDir + "/" + RefreshFile, ControlDir + "/" + CredentialsFile:
+		return 0644
+	}
+	return 0444
+}
+
+// isControlPath reports whether name is in ControlDir or HelpDir, the
+// virtual directories served by sisu itself
+func isControlPath(name string) bool {
+	return name == ControlDir || strings.HasPrefix(name, ControlDir+"/") || isHelpPath(name)
+}
+
+// searchDir holds one virtual file per query, e.g.
+// ".sisu/search/type:ec2 tag:Environment=prod web", listing the indexed
+// paths that match. It only exists when indexing is configured.
+const searchDir = ControlDir + "/search"
+
+// search returns the matches of a query as lines of path and ARN
+func (f *SisuFS) search(query string) ([]byte, fuse.Status) {
+	q, err := index.ParseQuery(query)
+	if err != nil {
+		return nil, fuse.EINVAL
+	}
+	var b strings.Builder
+	for _, r := range f.config.Index.Search(q) {
+		b.WriteString(r.Path)
+		if r.ARN != "" {
+			b.WriteString("\t" + r.ARN)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), fuse.OK
+}
+
+// controlData returns the content of the control file name
+func (f *SisuFS) controlData(name string) ([]byte, fuse.Status) {
+	if file, ok := strings.CutPrefix(name, HelpDir+"/"); ok {
+		data, ok := f.helpData(file)
+		if !ok {
+			return nil, fuse.ENOENT
+		}
+		return data, fuse.OK
+	}
+	if query, ok := strings.CutPrefix(name, searchDir+"/"); ok && f.config.Index != nil {
+		return f.search(query)
+	}
+	gen, ok := controlFiles[strings.TrimPrefix(name, ControlDir+"/")]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	data, err := gen(f)
+	if err != nil {
+		return nil, fuse.EIO
+	}
+	return data, fuse.OK
+}
+
+func (f *SisuFS) controlGetAttr(name string) (*fuse.Attr, fuse.Status) {
+	if name == ControlDir || name == HelpDir || (name == searchDir && f.config.Index != nil) {
+		return f.newAttr(fuse.S_IFDIR|0555, 0, time.Time{}), fuse.OK
+	}
+	if dir, ok := strings.CutPrefix(name, HelpDir+"/"); ok {
+		if _, ok := f.helpDir(dir); ok {
+			return f.newAttr(fuse.S_IFDIR|0555, 0, time.Time{}), fuse.OK
+		}
+	}
+	data, status := f.controlData(name)
+	if !status.Ok() {
+		return nil, status
+	}
+	return f.newAttr(fuse.S_IFREG|controlMode(name), int64(len(data)), time.Now()), fuse.OK
+}
+
+func (f *SisuFS) controlOpenDir(name string) ([]fuse.DirEntry, fuse.Status) {
+	if name == searchDir && f.config.Index != nil {
+		return nil, fuse.OK
+	}
+	if name == HelpDir {
+		var entries []fuse.DirEntry
+		for _, file := range f.helpFiles() {
+			entries = append(entries, fuse.DirEntry{Name: file, Mode: fuse.S_IFREG | 0444})
+		}
+		entries = append(entries, fuse.DirEntry{Name: helpSchemasDir, Mode: fuse.S_IFDIR | 0555})
+		return entries, fuse.OK
+	}
+	if dir, ok := strings.CutPrefix(name, HelpDir+"/"); ok {
+		if entries, ok := f.helpDir(dir); ok {
+			return entries, fuse.OK
+		}
+	}
+	if name != ControlDir {
+		return nil, fuse.ENOTDIR
+	}
+	entries := make([]fuse.DirEntry, 0, len(controlFiles)+1)
+	for file := range controlFiles {
+		entries = append(entries, fuse.DirEntry{Name: file, Mode: fuse.S_IFREG | controlMode(ControlDir+"/"+file)})
+	}
+	if f.config.Index != nil {
+		entries = append(entries, fuse.DirEntry{Name: "search", Mode: fuse.S_IFDIR | 0555})
+	}
+	return entries, fuse.OK
+}
+
+func (f *SisuFS) controlOpen(name string, flags uint32) (nodefs.File, fuse.Status) {
+	data, status := f.controlData(name)
+	if !status.Ok() {
+		return nil, status
+	}
+	if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		if controlMode(name) == 0444 {
+			return nil, fuse.EACCES
+		}
+		if name == ControlDir+"/"+RefreshFile {
+			return newRefreshFile(f), fuse.OK
+		}
+		if name == ControlDir+"/"+CredentialsFile {
+			return &credentialsFile{&armFile{File: nodefs.NewDefaultFile(), fs: f}}, fuse.OK
+		}
+		return &armFile{File: nodefs.NewDefaultFile(), fs: f}, fuse.OK
+	}
+	return &sisuFile{
+		File: nodefs.NewDefaultFile(),
+		data: data,
+		attr: f.newAttr(fuse.S_IFREG|0444, int64(len(data)), time.Now()),
+	}, fuse.OK
+}
@@ -0,0 +1,182 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/semonte/sisu/internal/index"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// DirInfoFile is the virtual file in each directory of a service that
+// summarizes its entries in one document, when Config.DirInfo is set
+const DirInfoFile = ".dirinfo.json"
+
+// maxDirInfoDocuments caps the subdirectories whose resource document is
+// read for their state and tags; the rest show only what the listing has
+const maxDirInfoDocuments = 500
+
+// maxDirInfos caps the summaries kept in memory; the oldest is dropped
+// past it
+const maxDirInfos = 256
+
+// DirInfoEntry is one entry of a directory in DirInfoFile
+type DirInfoEntry struct {
+	Name     string            `json:"name"`
+	Dir      bool              `json:"dir,omitempty"`
+	Size     int64             `json:"size,omitempty"`
+	Modified *time.Time        `json:"modified,omitempty"`
+	ARN      string            `json:"arn,omitempty"`
+	State    string            `json:"state,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// cutDirInfo returns the directory whose DirInfoFile subpath is, or false
+// if subpath names something else
+func (f *SisuFS) cutDirInfo(subpath string) (string, bool) {
+	if !f.config.DirInfo || path.Base(subpath) != DirInfoFile {
+		return "", false
+	}
+	dir := path.Dir(subpath)
+	if dir == "." {
+		dir = ""
+	}
+	return dir, true
+}
+
+// dirInfo returns the DirInfoFile of dir, served at name. Subdirectories
+// standing for a resource get the ARN, state and tags from their info.json
+// or config.json, which takes a listing and a read each, so the summary is
+// kept until the listing of dir is fetched again.
+func (f *SisuFS) dirInfo(prov provider.Provider, name, dir string) ([]byte, error) {
+	ctx := context.Background()
+	entries, err := prov.ReadDir(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := f.dirInfos.get(name, entries); ok {
+		return data, nil
+	}
+
+	info := make([]DirInfoEntry, len(entries))
+	documents := 0
+	for i, e := range entries {
+		info[i] = DirInfoEntry{Name: f.names.encode(e.Name), Dir: e.IsDir}
+		if !e.IsDir {
+			info[i].Size = e.Size
+		}
+		if !e.ModTime.IsZero() {
+			modified := e.ModTime.UTC()
+			info[i].Modified = &modified
+		}
+		if !e.IsDir || documents >= maxDirInfoDocuments {
+			continue
+		}
+		documents++
+		children, err := prov.ReadDir(ctx, joinPath(dir, e.Name))
+		if err != nil {
+			continue
+		}
+		for _, c := range children {
+			if c.IsDir || !index.IsResourceDocument(c.Name) {
+				continue
+			}
+			data, err := prov.Read(ctx, joinPath(joinPath(dir, e.Name), c.Name))
+			if err != nil {
+				continue
+			}
+			var r index.Record
+			index.Describe(&r, data)
+			info[i].ARN, info[i].Tags = r.ARN, r.Tags
+			info[i].State = resourceState(data)
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	f.dirInfos.put(name, entries, data)
+	return data, nil
+}
+
+// dirInfos keeps the DirInfoFile generated for each directory with the
+// listing it summarizes. Cached providers return the same listing until it
+// expires or is refreshed, so a summary is reused until then.
+type dirInfos struct {
+	mu     sync.Mutex
+	byName map[string]dirInfoSummary
+	order  []string // names by when first generated, oldest first
+}
+
+type dirInfoSummary struct {
+	listing *provider.Entry // first entry of the listing summarized
+	data    []byte
+}
+
+func newDirInfos() *dirInfos {
+	return &dirInfos{byName: make(map[string]dirInfoSummary)}
+}
+
+// get returns the summary at name if it was made from entries
+func (d *dirInfos) get(name string, entries []provider.Entry) ([]byte, bool) {
+	if d == nil || len(entries) == 0 {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.byName[name]
+	if !ok || s.listing != &entries[0] {
+		return nil, false
+	}
+	return s.data, true
+}
+
+// size returns the size of the last summary at name, 0 if there is none
+func (d *dirInfos) size(name string) int64 {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return int64(len(d.byName[name].data))
+}
+
+func (d *dirInfos) put(name string, entries []provider.Entry, data []byte) {
+	if d == nil || len(entries) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.byName[name]; !ok {
+		d.order = append(d.order, name)
+	}
+	d.byName[name] = dirInfoSummary{listing: &entries[0], data: data}
+	for len(d.order) > maxDirInfos {
+		delete(d.byName, d.order[0])
+		d.order = d.order[1:]
+	}
+}
+
+// resourceState returns the state in a resource document, found as
+// "State": "Active" (Lambda) or "State": {"Name": "running"} (EC2)
+func resourceState(data []byte) string {
+	var doc struct {
+		State json.RawMessage
+	}
+	if json.Unmarshal(data, &doc) != nil || doc.State == nil {
+		return ""
+	}
+	var state string
+	if json.Unmarshal(doc.State, &state) == nil {
+		return state
+	}
+	var named struct{ Name string }
+	json.Unmarshal(doc.State, &named)
+	return named.Name
+}
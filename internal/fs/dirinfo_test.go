@@ -0,0 +1,161 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// treeProvider lists the files of a memProvider as a tree and counts reads
+type treeProvider struct {
+	*memProvider
+	reads atomic.Int32
+}
+
+func (p *treeProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seen := make(map[string]bool)
+	var entries []provider.Entry
+	for name, data := range p.files {
+		rest, ok := strings.CutPrefix(name, path+"/")
+		if path == "" {
+			rest, ok = name, true
+		}
+		if !ok {
+			continue
+		}
+		child, _, isDir := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		entry := provider.Entry{Name: child, IsDir: isDir}
+		if !isDir {
+			entry.Size = int64(len(data))
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func (p *treeProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	p.reads.Add(1)
+	return p.memProvider.Read(ctx, path)
+}
+
+func readDirInfo(t *testing.T, f *SisuFS, name string) []byte {
+	t.Helper()
+	file, status := f.Open(name, uint32(syscall.O_RDONLY), nil)
+	if !status.Ok() {
+		t.Fatalf("Open: %v", status)
+	}
+	var attr fuse.Attr
+	file.GetAttr(&attr)
+	result, _ := file.Read(make([]byte, attr.Size), 0)
+	data, _ := result.Bytes(nil)
+	return data
+}
+
+func TestDirInfo(t *testing.T) {
+	prov := &treeProvider{memProvider: &memProvider{files: map[string]string{
+		"i-0abc/info.json":   `{"InstanceId": "i-0abc", "State": {"Code": 16, "Name": "running"}, "Tags": [{"Key": "Name", "Value": "web"}]}`,
+		"i-0abc/console.txt": "boot",
+		"fn/config.json":     `{"FunctionArn": "arn:aws:lambda:us-east-1:123456789012:function:fn", "State": "Active"}`,
+		"notes/readme.txt":   "hello",
+		"plain.txt":          "12345",
+	}}}
+	f := &SisuFS{
+		config:       Config{DirInfo: true},
+		providers:    map[string]provider.Provider{"prod/us-east-1/ec2": prov},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		lookups:      newLookups(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+	}
+	name := "prod/us-east-1/ec2/" + DirInfoFile
+
+	entries, _ := f.OpenDir("prod/us-east-1/ec2", nil)
+	if last := entries[len(entries)-1]; last.Name != DirInfoFile {
+		t.Errorf("listing ends with %q, want %s", last.Name, DirInfoFile)
+	}
+	if _, status := f.GetAttr(name, nil); !status.Ok() {
+		t.Fatalf("GetAttr: %v", status)
+	}
+	data := readDirInfo(t, f, name)
+
+	var info []DirInfoEntry
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	byName := make(map[string]DirInfoEntry)
+	for _, e := range info {
+		byName[e.Name] = e
+	}
+	if e := byName["i-0abc"]; !e.Dir || e.State != "running" || e.Tags["Name"] != "web" {
+		t.Errorf("i-0abc = %+v", e)
+	}
+	if e := byName["fn"]; e.State != "Active" || e.ARN != "arn:aws:lambda:us-east-1:123456789012:function:fn" {
+		t.Errorf("fn = %+v", e)
+	}
+	if e := byName["notes"]; !e.Dir || e.State != "" || e.Tags != nil {
+		t.Errorf("notes = %+v", e)
+	}
+	if e := byName["plain.txt"]; e.Dir || e.Size != 5 {
+		t.Errorf("plain.txt = %+v", e)
+	}
+
+	if _, status := f.Open(name, uint32(syscall.O_WRONLY), nil); status != fuse.EACCES {
+		t.Errorf("open for writing: %v, want EACCES", status)
+	}
+	f.config.DirInfo = false
+	if _, status := f.GetAttr(name, nil); status.Ok() {
+		t.Error("DirInfoFile exists with DirInfo off")
+	}
+}
+
+func TestDirInfoKeptWithListing(t *testing.T) {
+	prov := &treeProvider{memProvider: &memProvider{files: map[string]string{
+		"i-0abc/info.json": `{"State": {"Name": "running"}}`,
+		"i-0def/info.json": `{"State": {"Name": "stopped"}}`,
+	}}}
+	cached := provider.Cached(prov, provider.DefaultCachePolicy)
+	f := &SisuFS{
+		config:       Config{DirInfo: true},
+		providers:    map[string]provider.Provider{"prod/us-east-1/ec2": cached},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		lookups:      newLookups(),
+		dirInfos:     newDirInfos(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+	}
+	name := "prod/us-east-1/ec2/" + DirInfoFile
+
+	if attr, status := f.GetAttr(name, nil); !status.Ok() || prov.reads.Load() != 0 {
+		t.Fatalf("GetAttr = %v, %v after %d reads, want no summary generated", attr, status, prov.reads.Load())
+	}
+	first := readDirInfo(t, f, name)
+	if attr, _ := f.GetAttr(name, nil); attr.Size != uint64(len(first)) {
+		t.Errorf("size = %d, want the summary's %d", attr.Size, len(first))
+	}
+	prov.mu.Lock()
+	prov.files["i-0ghi/info.json"] = `{"State": {"Name": "pending"}}`
+	prov.mu.Unlock()
+	if data := readDirInfo(t, f, name); string(data) != string(first) || prov.reads.Load() != 2 {
+		t.Errorf("summary made again after %d reads: %s", prov.reads.Load(), data)
+	}
+
+	// Refetching the listing makes a new summary
+	cached.Invalidate("")
+	if data := readDirInfo(t, f, name); !strings.Contains(string(data), "i-0ghi") {
+		t.Errorf("summary = %s, want the new listing's", data)
+	}
+}
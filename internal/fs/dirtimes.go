@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirTimes tracks the newest modification time seen below each directory,
+// so a directory's mtime reflects its most recently changed descendant.
+// Times are learned from listings and from writes through the mount, and
+// only ever move forward.
+type dirTimes struct {
+	mu    sync.RWMutex
+	times map[string]time.Time // filesystem path -> newest descendant mtime
+}
+
+func newDirTimes() *dirTimes {
+	return &dirTimes{times: make(map[string]time.Time)}
+}
+
+// observe records a change at t somewhere below dir, updating dir and
+// all of its ancestors up to the mount root
+func (d *dirTimes) observe(dir string, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		if t.After(d.times[dir]) {
+			d.times[dir] = t
+		}
+		if dir == "" {
+			return
+		}
+		idx := strings.LastIndex(dir, "/")
+		if idx < 0 {
+			dir = ""
+		} else {
+			dir = dir[:idx]
+		}
+	}
+}
+
+// newest returns the later of t and the newest change seen below dir
+func (d *dirTimes) newest(dir string, t time.Time) time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if seen := d.times[dir]; seen.After(t) {
+		return seen
+	}
+	return t
+}
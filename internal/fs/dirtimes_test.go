@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDirTimesPropagateToAncestors(t *testing.T) {
+	d := newDirTimes()
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := old.Add(48 * time.Hour)
+
+	d.observe("default/eu-west-1/ssm/app", recent)
+	d.observe("default/eu-west-1/ssm/legacy", old)
+
+	for _, dir := range []string{"", "default", "default/eu-west-1/ssm", "default/eu-west-1/ssm/app"} {
+		if got := d.newest(dir, time.Time{}); !got.Equal(recent) {
+			t.Errorf("newest(%q) = %v, want %v", dir, got, recent)
+		}
+	}
+	if got := d.newest("default/eu-west-1/ssm/legacy", time.Time{}); !got.Equal(old) {
+		t.Errorf("newest(legacy) = %v, want %v", got, old)
+	}
+
+	// A provider-reported time newer than anything observed wins
+	later := recent.Add(time.Hour)
+	if got := d.newest("default/eu-west-1/ssm/app", later); !got.Equal(later) {
+		t.Errorf("newest with later own time = %v, want %v", got, later)
+	}
+}
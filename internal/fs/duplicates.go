@@ -0,0 +1,178 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/semonte/sisu/internal/index"
+)
+
+// DuplicatesFile is the control file listing resources found in more than
+// one profile, generated when read and reused for duplicatesTTL
+const DuplicatesFile = "duplicates.json"
+
+// duplicatesTTL is how long a generated DuplicatesFile is served again, so
+// the stat, open and read of one cat don't each list every profile's
+// buckets
+const duplicatesTTL = 30 * time.Second
+
+// duplicatesReport is the last DuplicatesFile generated
+type duplicatesReport struct {
+	mu   sync.Mutex
+	data []byte
+	at   time.Time
+}
+
+// duplicatesData returns the content of DuplicatesFile
+func (f *SisuFS) duplicatesData() ([]byte, error) {
+	r := &f.duplicates
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.data != nil && time.Since(r.at) < duplicatesTTL {
+		return r.data, nil
+	}
+	data, err := json.MarshalIndent(f.Duplicates(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	r.data, r.at = append(data, '\n'), time.Now()
+	return r.data, nil
+}
+
+// Duplicates is the content of .sisu/duplicates.json
+type Duplicates struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Names are resources with the same name in the same service of
+	// several profiles, e.g. a bucket that exists in two accounts
+	Names []DuplicateName `json:"names"`
+	// Tags are resources in several profiles carrying the same tags
+	Tags []DuplicateTags `json:"tags"`
+	// Errors are the profiles whose buckets couldn't be listed
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// DuplicateName is a resource name found in several profiles
+type DuplicateName struct {
+	Service string   `json:"service"`
+	Name    string   `json:"name"`
+	Paths   []string `json:"paths"`
+}
+
+// DuplicateTags is a set of tags found on resources in several profiles
+type DuplicateTags struct {
+	Tags  map[string]string `json:"tags"`
+	Paths []string          `json:"paths"`
+}
+
+// Duplicates compares the S3 buckets of every profile by name and, if
+// indexing is configured, the indexed resources by name and by tags. Only
+// groups spanning more than one profile are reported. Profiles of the same
+// account list the same buckets, so only the first profile of each
+// account has its buckets compared.
+func (f *SisuFS) Duplicates() Duplicates {
+	d := Duplicates{GeneratedAt: time.Now(), Names: []DuplicateName{}, Tags: []DuplicateTags{}}
+
+	names := make(map[[2]string]map[string]bool) // paths by service and name
+	addName := func(service, name, path string) {
+		k := [2]string{service, name}
+		if names[k] == nil {
+			names[k] = make(map[string]bool)
+		}
+		names[k][path] = true
+	}
+	accounts := make(map[string]bool) // whose buckets were listed
+	for _, profile := range f.profiles {
+		if account := f.accountOf(profile); account != "" {
+			if accounts[account] {
+				continue
+			}
+			accounts[account] = true
+		}
+		prov, err := f.getProvider(profile, "us-east-1", "s3")
+		if err != nil || prov == nil {
+			continue
+		}
+		buckets, err := prov.ReadDir(context.Background(), "")
+		if err != nil {
+			if d.Errors == nil {
+				d.Errors = make(map[string]string)
+			}
+			d.Errors[profile] = err.Error()
+			continue
+		}
+		for _, b := range buckets {
+			addName("s3", b.Name, profile+"/global/s3/"+f.names.encode(b.Name))
+		}
+	}
+
+	tags := make(map[string]*DuplicateTags) // by tagKey
+	if f.config.Index != nil {
+		for _, r := range f.config.Index.Search(index.Query{}) {
+			if r.Dir {
+				addName(r.Service(), r.Name(), r.Path)
+			}
+			if len(r.Tags) > 0 {
+				k := tagKey(r.Tags)
+				if tags[k] == nil {
+					tags[k] = &DuplicateTags{Tags: r.Tags}
+				}
+				tags[k].Paths = append(tags[k].Paths, r.Path)
+			}
+		}
+	}
+
+	for k, set := range names {
+		paths := make([]string, 0, len(set))
+		for p := range set {
+			paths = append(paths, p)
+		}
+		if spansProfiles(paths) {
+			sort.Strings(paths)
+			d.Names = append(d.Names, DuplicateName{Service: k[0], Name: k[1], Paths: paths})
+		}
+	}
+	sort.Slice(d.Names, func(i, j int) bool {
+		if d.Names[i].Service != d.Names[j].Service {
+			return d.Names[i].Service < d.Names[j].Service
+		}
+		return d.Names[i].Name < d.Names[j].Name
+	})
+
+	for _, t := range tags {
+		if spansProfiles(t.Paths) {
+			sort.Strings(t.Paths)
+			d.Tags = append(d.Tags, *t)
+		}
+	}
+	sort.Slice(d.Tags, func(i, j int) bool { return d.Tags[i].Paths[0] < d.Tags[j].Paths[0] })
+	return d
+}
+
+// tagKey returns a string identifying a set of tags regardless of order
+func tagKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + tags[k] + "\x00")
+	}
+	return b.String()
+}
+
+// spansProfiles reports whether paths are in more than one profile
+func spansProfiles(paths []string) bool {
+	first, _, _ := strings.Cut(paths[0], "/")
+	for _, p := range paths[1:] {
+		if profile, _, _ := strings.Cut(p, "/"); profile != first {
+			return true
+		}
+	}
+	return false
+}
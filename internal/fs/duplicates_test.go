@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/semonte/sisu/internal/index"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// bucketsProvider lists a fixed set of buckets
+type bucketsProvider struct {
+	provider.ReadOnlyProvider
+	buckets []string
+	lists   int
+}
+
+func (p *bucketsProvider) Name() string { return "s3" }
+
+func (p *bucketsProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	p.lists++
+	entries := make([]provider.Entry, len(p.buckets))
+	for i, b := range p.buckets {
+		entries[i] = provider.Entry{Name: b, IsDir: true}
+	}
+	return entries, nil
+}
+
+func (p *bucketsProvider) Read(ctx context.Context, path string) ([]byte, error) { return nil, nil }
+
+func (p *bucketsProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	return &provider.Entry{Name: path, IsDir: true}, nil
+}
+
+func TestDuplicates(t *testing.T) {
+	idx := index.New()
+	idx.Replace("dev", []index.Record{
+		{Path: "dev/us-east-1/lambda/api", Dir: true, Tags: map[string]string{"app": "api", "team": "web"}},
+		{Path: "dev/us-east-1/ssm/app/db-url"},
+	})
+	idx.Replace("prod", []index.Record{
+		{Path: "prod/eu-west-1/lambda/api", Dir: true},
+		{Path: "prod/eu-west-1/ec2/i-0abc", Dir: true, Tags: map[string]string{"team": "web", "app": "api"}},
+		{Path: "prod/eu-west-1/ssm/app/db-url"},
+	})
+	f := &SisuFS{
+		config:   Config{Index: idx},
+		profiles: []string{"dev", "prod", "prod-admin"},
+		providers: map[string]provider.Provider{
+			"dev/us-east-1/s3@arn:aws:sts::111111111111:assumed-role/dev/me":               &bucketsProvider{buckets: []string{"logs", "dev-assets"}},
+			"prod/us-east-1/s3@arn:aws:sts::222222222222:assumed-role/prod/me":             &bucketsProvider{buckets: []string{"logs", "prod-assets"}},
+			"prod-admin/us-east-1/s3@arn:aws:sts::222222222222:assumed-role/prod-admin/me": &bucketsProvider{buckets: []string{"logs", "prod-assets"}},
+		},
+		names: newNameCodec(),
+		// prod-admin is another role in prod's account, listing its buckets
+		identities: newIdentities(func(ctx context.Context, profile string) (string, error) {
+			if profile == "dev" {
+				return "arn:aws:sts::111111111111:assumed-role/dev/me", nil
+			}
+			return "arn:aws:sts::222222222222:assumed-role/" + profile + "/me", nil
+		}),
+	}
+
+	d := f.Duplicates()
+	if len(d.Names) != 2 {
+		t.Fatalf("names = %+v", d.Names)
+	}
+	if n := d.Names[0]; n.Service != "lambda" || n.Name != "api" || len(n.Paths) != 2 {
+		t.Errorf("names[0] = %+v", n)
+	}
+	if n := d.Names[1]; n.Service != "s3" || n.Name != "logs" || !reflect.DeepEqual(n.Paths, []string{"dev/global/s3/logs", "prod/global/s3/logs"}) {
+		t.Errorf("names[1] = %+v", n)
+	}
+	if len(d.Tags) != 1 || len(d.Tags[0].Paths) != 2 || d.Tags[0].Paths[1] != "prod/eu-west-1/ec2/i-0abc" {
+		t.Errorf("tags = %+v", d.Tags)
+	}
+}
+
+func TestDuplicatesFileReused(t *testing.T) {
+	buckets := &bucketsProvider{buckets: []string{"logs"}}
+	f := &SisuFS{
+		profiles:  []string{"prod"},
+		providers: map[string]provider.Provider{"prod/us-east-1/s3": buckets},
+		names:     newNameCodec(),
+	}
+	for i := 0; i < 3; i++ {
+		if _, status := f.controlData(ControlDir + "/" + DuplicatesFile); !status.Ok() {
+			t.Fatal(status)
+		}
+	}
+	if buckets.lists != 1 {
+		t.Errorf("buckets listed %d times, want once", buckets.lists)
+	}
+}
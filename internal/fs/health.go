@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Health serves /healthz for mounts run as a sidecar: 200 while the mount
+// answers a stat through the kernel within Timeout, 503 when it is dead or
+// hung, so an orchestrator can restart it
+type Health struct {
+	Mountpoint string
+	Timeout    time.Duration
+
+	check func() error // replaced in tests
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	check := h.check
+	if check == nil {
+		check = func() error { return statMount(h.Mountpoint, h.Timeout) }
+	}
+	if err := check(); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", h.Mountpoint, err), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
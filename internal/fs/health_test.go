@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	h := &Health{Mountpoint: t.TempDir(), Timeout: time.Second}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthy mount: %d %s", rec.Code, rec.Body)
+	}
+
+	h = &Health{Mountpoint: "/mnt/sisu", check: func() error { return errWedged }}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "mount did not respond") {
+		t.Errorf("wedged mount: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,567 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// HelpDir is the virtual directory at the mount root holding generated
+// help, e.g. help/s3.txt. A profile named "help" is hidden by it.
+const HelpDir = "help"
+
+func isHelpPath(name string) bool {
+	return name == HelpDir || strings.HasPrefix(name, HelpDir+"/")
+}
+
+// serviceHelp describes the layout and capabilities of each built-in
+// service; the settings that apply in the mount are appended when served
+var serviceHelp = map[string]string{
+	"s3": `S3 buckets and objects, under <profile>/global/s3.
+
+  s3/<bucket>/<key>         objects; "directories" are key prefixes
+  s3/<bucket>.tags.json     the bucket's tags (not listed)
+  s3/_audit/public-buckets.json  buckets made public by policy or ACL (not listed)
+
+Objects can be read, written, copied in and removed like files. Files
+over 1 MB are fetched in ranges as they are read. Key prefixes show up
+as directories, and mkdir creates an empty one until a file is written
+into it. getfattr -n user.sisu.sha256 (or user.sisu.md5) shows an
+object's checksum without downloading it. With write: {s3: true} or
+{s3: tags-only}, writing a bucket's .tags.json replaces its tags.
+`,
+	"ssm": `SSM Parameter Store, under <profile>/<region>/ssm.
+
+  ssm/<path>/<name>             parameter values; /app/db/url is ssm/app/db/url
+  ssm/<path>/<name>@meta.json   tier, description and tags (not listed)
+  ssm/<path>/<name>@b64         base64 of values that aren't plain text
+
+Parameters can be read, written and removed; writes store String
+parameters. Writing a @meta.json changes the tier, description and tags.
+A newline is added to values on read and dropped on write unless
+ssm_exact_values is set.
+`,
+	"iam": `IAM users, roles, policies and groups, under <profile>/global/iam.
+
+  iam/users/<name>/         info.json, policies.json, groups.json, last-accessed.json
+  iam/roles/<name>/         info.json, policies.json, trust-policy.json, last-accessed.json
+  iam/policies/<name>.json  the default version of customer managed policies
+  iam/groups/<name>/        info.json, policies.json, members.json
+
+last-accessed.json is generated by IAM on the first read, which fails with
+"Resource temporarily unavailable" until the report is ready; read it
+again a few seconds later. Read-only unless enabled with write: {iam:
+true}, which allows editing a role's trust-policy.json.
+`,
+	"vpc": `VPCs and their networking, under <profile>/<region>/vpc.
+
+  vpc/<vpc-id>/info.json, summary.json
+  vpc/<vpc-id>/subnets/, route-tables/, security-groups/
+  vpc/_audit/open-to-world.json  security group rules open to 0.0.0.0/0 or ::/0
+                                 in any VPC (not listed)
+
+security-groups/<id>.referenced-by.json lists what uses each group.
+Read-only.
+`,
+	"lambda": `Lambda functions, under <profile>/<region>/lambda.
+
+  lambda/<function>/        config.json, policy.json, env.json, tags.json,
+                            layers.json, concurrency.json, url-config.json, code.zip
+
+Read-only unless enabled with write: {lambda: true}, which allows editing
+env.json to replace the function's environment and tags.json to change
+its tags. lambda: env-only and lambda: tags-only allow just one of them.
+`,
+	"ec2": `EC2 instances and capacity, under <profile>/<region>/ec2.
+
+  ec2/<instance-id>/        info.json, security-groups.json, tags.json
+  ec2/_capacity/spot-requests/, reserved-instances/, capacity-reservations/
+                            <id>.json
+  ec2/_audit/unencrypted-volumes.json  EBS volumes without encryption (not listed)
+  ec2/<instance-id>/port-forward/<port>  the aws ssm start-session command
+                            forwarding a local port to it (managed instances)
+
+Any port can be read under port-forward/, not just those listed; sisu
+port-forward <path> runs the command. With write: {ec2: true}, writing
+an instance's tags.json adds, changes and removes its tags to match.
+Everything else is read-only.
+`,
+	"dynamodb": `DynamoDB tables, under <profile>/<region>/dynamodb.
+
+  dynamodb/<table>/         info.json (schema, status, size), indexes.json,
+                            tags.json
+  dynamodb/<table>/keys/    a sample of the table's first items, named
+                            <partition key>[,<sort key>].json, where a "/"
+                            in a key shows as "／"
+
+The sample is a single Scan of a few items, so reading it consumes read
+capacity. With write: {dynamodb: true}, writing a table's tags.json adds,
+changes and removes its tags to match; everything else is read-only.
+`,
+	"cloudwatch": `CloudWatch alarms, metrics, dashboards and Synthetics canaries, under
+<profile>/<region>/cloudwatch.
+
+  cloudwatch/alarms/<alarm>.json       the alarm's configuration and current state
+  cloudwatch/metrics/<namespace>/      e.g. metrics/AWS/EC2/, one directory per metric
+  cloudwatch/metrics/<namespace>/<metric>/dimensions.json, recent-datapoints.json
+  cloudwatch/dashboards/<name>.json    the dashboard's body, as put-dashboard takes it
+  cloudwatch/canaries/<name>/config.json, last-run.json
+
+recent-datapoints.json is the last hour of the metric's average in
+5-minute periods, for its first 10 dimension sets. last-run.json is the
+canary's most recent run with its state, reason and artifact location. A
+"/" in an alarm's name shows as "／". Read-only.
+`,
+	"sqs": `SQS queues, under <profile>/<region>/sqs.
+
+  sqs/<queue>/attributes.json   the queue's attributes, e.g. message counts
+  sqs/<queue>/peek.json         up to 10 messages, left on the queue
+  sqs/<queue>/send              write a message body to send it
+
+peek.json receives messages with a visibility timeout of 0, so consumers
+still get them, but each read counts towards a message's maxReceiveCount
+and can move it to the dead-letter queue; sisu mirror and sisu audit
+compare skip it. With write: {sqs: true}, echo 'hello' > sqs/<queue>/send
+sends one message; FIFO queues get it in group "sisu". Nothing else is
+writable.
+`,
+	"kinesis": `Kinesis data streams, under <profile>/<region>/kinesis.
+
+  kinesis/<stream>/info.json     status, capacity mode, retention and encryption
+  kinesis/<stream>/shards.json   shards with their hash key and sequence ranges
+  kinesis/<stream>/tail          the latest 100 records of the last 5 minutes
+
+tail reads every open shard from 5 minutes ago and shows one record per
+line: arrival time, shard, partition key and data, which is shown as
+base64 when it isn't text. Its GetRecords calls count towards each
+shard's limit of 5 reads a second, which the stream's consumers share,
+so a busy consumer may be throttled while tail is read; mirror and audit
+compare leave it out. Read-only.
+`,
+	"apprunner": `App Runner services, under <profile>/<region>/apprunner.
+
+  apprunner/<service>/info.json   source, instance, health check and network configuration
+  apprunner/<service>/status      e.g. RUNNING or OPERATION_IN_PROGRESS
+
+Read-only.
+`,
+	"lightsail": `Lightsail instances and managed databases, under <profile>/<region>/lightsail.
+
+  lightsail/instances/<name>/info.json   blueprint, bundle, addresses and open ports
+  lightsail/instances/<name>/state       e.g. running or stopped
+  lightsail/databases/<name>/info.json   engine, bundle, endpoint and backups
+  lightsail/databases/<name>/state       e.g. available or backing-up
+
+Read-only.
+`,
+	"batch": `AWS Batch, under <profile>/<region>/batch.
+
+  batch/compute-environments/<name>.json     state, status, instance types and scaling
+  batch/job-queues/<name>/info.json          state, priority and compute environment order
+  batch/job-queues/<name>/recent-jobs.json   jobs created in the last day, newest first
+
+Each recent job has its status, exit code and, once its container has
+started, the log group and stream it writes to with a link to the stream
+in the CloudWatch console. Read-only.
+`,
+	"emr": `EMR clusters that haven't terminated, under <profile>/<region>/emr, by cluster ID.
+
+  emr/<id>/info.json              name, status, release label, applications and roles
+  emr/<id>/instance-groups.json   instance type, market and running count of each group
+  emr/<id>/steps.json             steps, newest first, with their state and any failure
+  emr/<id>/configurations.json    the configuration classifications of the cluster
+
+steps.json holds the newest max entries steps of a cluster with more.
+Read-only.
+`,
+	"mwaa": `Managed Workflows for Apache Airflow environments, under <profile>/<region>/mwaa.
+
+  mwaa/<environment>/info.json          Airflow version, class, configuration options and network
+  mwaa/<environment>/last-update.json   status of the last update and its error, if it failed
+
+Read-only.
+`,
+	"dms": `Database Migration Service replication tasks, under <profile>/<region>/dms.
+
+  dms/<task>/info.json         endpoints, replication instance, migration type, table mappings
+  dms/<task>/statistics.json   status, progress, rows loaded and changed per table, last error
+
+statistics.json totals the full-load rows, inserts, updates and deletes
+of all tables and lists each table with its state. Read-only.
+`,
+	"datasync": `DataSync tasks, under <profile>/<region>/datasync, by task ID.
+
+  datasync/<task-id>/info.json         name, source and destination locations, options, schedule
+  datasync/<task-id>/statistics.json   task status and its latest execution
+
+The latest execution is the running one, if any, with bytes and files
+transferred so far and the error of a failed run. Read-only.
+`,
+	"athena": `Athena workgroups and queries, under <profile>/<region>/athena.
+
+  athena/workgroups/<name>.json              state, engine version, output location and limits
+  athena/named-queries/<name>.sql            saved queries, with their database and workgroup
+  athena/queries/<workgroup>/<name>.sql      write SQL here to run it in the workgroup
+  athena/queries/<workgroup>/<name>.csv      its results; reading waits for the query to finish
+
+With write: {athena: true}, writing a .sql file starts the query in the
+workgroup's database and output location, and rm cancels it if it is
+still running. Queries are scanned and billed as if run from the
+console. queries/ lists only the queries written since mount. Writing
+the same .sql again reruns it; its .csv is cached like any other file
+until it expires or is refreshed. A "/" in a saved query's name shows as
+"／".
+`,
+	"elb": `Application, Network and Gateway Load Balancers, under <profile>/<region>/elb.
+
+  elb/<lb>/info.json                            type, scheme, DNS name, state, zones
+  elb/<lb>/listeners/<protocol>-<port>.json     default actions and, for ALBs, the rules
+  elb/<lb>/target-groups/<group>/info.json      target type, port and health check settings
+  elb/<lb>/target-groups/<group>/health.json    each target's health, with counts by state
+
+health.json asks for the targets' health when read, so during an outage
+cat it again after the read cache expires or sisu refresh the group's
+directory. Read-only.
+`,
+	"topology": `How the region's queues, topics, functions and EventBridge rules feed each
+other, under <profile>/<region>/topology.
+
+  topology/edges.json                                every edge, with its kind and state
+  topology/<kind>/<name>/consumers/<kind>/<name>     links to what the node delivers to
+  topology/<kind>/<name>/sources/<kind>/<name>       links to what delivers to it
+
+Kinds are queues, topics, functions and rules; rules on buses other than
+default are named <bus>.<rule>. Edges come from Lambda event source
+mappings, SNS subscriptions, SQS redrive policies and rule targets, so
+ls topology/queues/orders/consumers/* shows what consumes a queue. Edges
+to other regions, accounts or non-AWS endpoints are only in edges.json,
+which also lists services the profile can't read and, under truncated,
+listings cut off at max entries, whose edges are missing. Read-only.
+`,
+	"access-analyzer": `IAM Access Analyzer, under <profile>/global/access-analyzer, showing the
+analyzers of the profile's configured region.
+
+  access-analyzer/<analyzer>/info.json
+  access-analyzer/<analyzer>/active/, archived/, resolved/  <finding-id>.json
+
+Read-only.
+`,
+	"identity-center": `IAM Identity Center (SSO), under <profile>/global/identity-center, from the
+instance in the profile's configured region.
+
+  identity-center/permission-sets/<name>.json   policies, and the users and groups assigned it per account
+  identity-center/users/<user-name>.json        the user and the groups they belong to
+  identity-center/groups/<name>.json            the group and the user names of its members
+
+Assignments name their principal, so grep -l alice@example.com
+permission-sets/*.json finds what a user is assigned directly. A "/" in
+a group's name shows as "／". Read-only.
+`,
+	"gcs": `Google Cloud Storage, under @gcp/<project>/gcs.
+
+  gcs/<bucket>/<object>     objects; "directories" are name prefixes
+
+Read-only, using the credentials of the logged-in gcloud CLI.
+`,
+	"secrets": `Google Secret Manager, under @gcp/<project>/secrets.
+
+  secrets/<secret>          the latest version of each secret
+
+Read-only, using the credentials of the logged-in gcloud CLI.
+`,
+	"blob": `Azure Blob Storage, under @azure/<subscription>/blob.
+
+  blob/<account>/<container>/<blob>
+
+Read-only, using the credentials of the logged-in az CLI.
+`,
+	"keyvault": `Azure Key Vault, under @azure/<subscription>/keyvault.
+
+  keyvault/<vault>/<secret> the current value of each secret
+
+Read-only, using the credentials of the logged-in az CLI.
+`,
+}
+
+const helpLayout = `sisu mounts cloud resources as files:
+
+  <profile>/<region>/<service>/...   regional services: apprunner, athena, batch, cloudwatch,
+                                     datasync, dms, dynamodb, ec2, elb, emr, kinesis, lambda,
+                                     lightsail, mwaa, sqs, ssm, topology, vpc
+  <profile>/global/<service>/...     access-analyzer, iam, identity-center, s3 and endpoints
+  <profile>/changes.log              what changed between two listings since mount
+  <profile>/<region>/this-instance   on EC2, a link to ec2/<id> of the instance sisu runs on,
+                                     in the profiles of its account
+  @gcp/<project>/<service>/...       when Google Cloud projects are configured
+  @azure/<subscription>/<service>/...
+                                     when Azure subscriptions are configured
+  .sisu/stats.json                   API calls and estimated cost (sisu status)
+  .sisu/arm                          arms deletes in protected profiles
+  .sisu/refresh                      relists a subtree now; see sisu refresh
+  .sisu/credentials                  identities of the profiles; write a profile to drop its caches
+  .sisu/duplicates.json              buckets and resources found in several profiles
+  help/                              these files
+  help/schemas/<service>/            JSON Schemas of the documents a service generates, from
+                                     the SDK types they are written from
+
+Some files are generated rather than stored:
+
+  _pageN/               the rest of a listing over the entry cap
+  _more_results.txt     explains a listing that was cut off
+  _warning.txt          explains a listing that stopped early
+  _access-denied.txt    explains a directory your credentials can't list
+  .sisu-error           the last internal error of a service, with its stack
+  .dirinfo.json         every entry of its directory with size, state and
+                        tags, when dir_info is set; made when opened and
+                        kept until the directory is listed again
+  <name>.yaml, .md, .tsv, .jsonl
+                        <name>.json as YAML, Markdown, a table or JSON lines,
+                        where the render setting picks that format
+
+Read <service>.txt for what each service shows, writes.txt for what
+writing does and config.txt for the settings of this mount.
+`
+
+const helpWrites = `Writes go to AWS when a file is closed, so editors and shell redirection
+work as usual; a failed write shows up as an error from close (e.g. in
+vim's :w). Content is checked before it is sent: JSON documents must
+parse, tags.json must be an object of strings, and SSM values must fit
+their tier.
+
+  >, cp, vim      create or replace the resource behind the file
+  >>              append to it
+  rm              delete it
+  mv              rename within a service; across services it copies and deletes
+  mkdir           create an empty directory to write into
+
+To change several files together, write them below the service's .staging/
+directory (e.g. cp app/db-url .staging/app/db-url, then edit it there) and
+touch .staging/APPLY. All files are written at once; if one fails, those
+already written get their previous content back and everything stays
+staged. .staging/RESULTS shows the outcome per file.
+
+Files are writable only where the service supports it (see <service>.txt),
+the write setting enables it and the writable patterns, if any, match.
+Read-only files show as r--. In protected profiles rm, rmdir and mv fail
+with "Operation not permitted" until armed with sisu unlock.
+
+Changes made through the mount are visible right away; changes made
+elsewhere show up when the cached listing expires (5 minutes by default).
+`
+
+// helpSchemasDir holds the JSON Schemas of generated documents, as
+// schemas/<service>/<name>.schema.json
+const helpSchemasDir = "schemas"
+
+// schemaSuffix ends the names of the files in helpSchemasDir
+const schemaSuffix = ".schema.json"
+
+// helpFiles returns the names of the files in HelpDir
+func (f *SisuFS) helpFiles() []string {
+	names := []string{"README.txt", "config.txt", "writes.txt"}
+	for _, service := range f.helpServices() {
+		names = append(names, service+".txt")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// helpDir lists a directory below HelpDir, e.g. "schemas" or
+// "schemas/ec2", returning false if there is none. Entries are files
+// except in helpSchemasDir itself, which has one directory per service.
+func (f *SisuFS) helpDir(dir string) ([]fuse.DirEntry, bool) {
+	if dir == helpSchemasDir {
+		var entries []fuse.DirEntry
+		for _, service := range f.helpServices() {
+			if len(provider.Schemas(service)) > 0 {
+				entries = append(entries, fuse.DirEntry{Name: service, Mode: fuse.S_IFDIR | 0555})
+			}
+		}
+		return entries, true
+	}
+	service, ok := strings.CutPrefix(dir, helpSchemasDir+"/")
+	schemas := provider.Schemas(service)
+	if !ok || len(schemas) == 0 {
+		return nil, false
+	}
+	entries := make([]fuse.DirEntry, 0, len(schemas))
+	for _, s := range schemas {
+		entries = append(entries, fuse.DirEntry{Name: s.Name + schemaSuffix, Mode: fuse.S_IFREG | 0444})
+	}
+	return entries, true
+}
+
+// helpSchema returns the file schemas/<service>/<name>.schema.json
+func helpSchema(name string) ([]byte, bool) {
+	rest, ok := strings.CutPrefix(name, helpSchemasDir+"/")
+	if !ok {
+		return nil, false
+	}
+	service, file, _ := strings.Cut(rest, "/")
+	for _, s := range provider.Schemas(service) {
+		if s.Name+schemaSuffix == file {
+			data, err := s.JSON(service)
+			return data, err == nil
+		}
+	}
+	return nil, false
+}
+
+// helpServices returns the services mounted with this configuration
+func (f *SisuFS) helpServices() []string {
+	var services []string
+	for service := range globalServices {
+		services = append(services, service)
+	}
+	services = append(services, regionalServices...)
+	for _, ep := range f.config.Endpoints {
+		services = append(services, ep.Name)
+	}
+	for _, c := range f.clouds {
+		services = append(services, c.services...)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// helpData returns the content of a file in HelpDir
+func (f *SisuFS) helpData(name string) ([]byte, bool) {
+	switch name {
+	case "README.txt":
+		return []byte(helpLayout), true
+	case "writes.txt":
+		return []byte(helpWrites), true
+	case "config.txt":
+		return []byte(f.helpConfig()), true
+	}
+	if data, ok := helpSchema(name); ok {
+		return data, true
+	}
+	service, ok := strings.CutSuffix(name, ".txt")
+	if !ok {
+		return nil, false
+	}
+	for _, s := range f.helpServices() {
+		if s == service {
+			return []byte(f.helpService(service)), true
+		}
+	}
+	return nil, false
+}
+
+// helpService describes a service and the settings that apply to it
+func (f *SisuFS) helpService(service string) string {
+	var b strings.Builder
+	if text, ok := serviceHelp[service]; ok {
+		b.WriteString(text)
+	} else if ep, ok := f.endpoint(service); ok {
+		fmt.Fprintf(&b, "JSON REST API at %s, under <profile>/global/%s.\n\n", ep.BaseURL, service)
+		for _, c := range ep.Collections {
+			fmt.Fprintf(&b, "  %s/%s/<name>.json    items of %s\n", service, c.Path, c.List)
+		}
+		b.WriteString("\nRead-only.\n")
+	}
+
+	b.WriteString("\nIn this mount:\n")
+	fmt.Fprintf(&b, "  writes:  %s\n", f.helpWriteMode(service))
+	rules := f.renderRules(service)
+	dirs := make([]string, 0, len(rules))
+	for dir := range rules {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		format := rules[dir]
+		where := ".json documents"
+		if dir != "" {
+			where += " in " + dir + "/"
+		}
+		fmt.Fprintf(&b, "  shown as %s: %s are listed as %s\n", format, where, format.Ext())
+	}
+	t := f.timeoutsFor(service)
+	fmt.Fprintf(&b, "  timeouts: readdir %s, read %s, write %s\n", t.ReadDir, t.Read, t.Write)
+	return b.String()
+}
+
+// helpWriteMode describes the write setting of a service
+func (f *SisuFS) helpWriteMode(service string) string {
+	mode := string(f.config.Write[service])
+	scope, err := provider.WriteScope(service, mode)
+	switch {
+	case err != nil:
+		return "invalid write mode " + mode
+	case mode == "" && provider.OptInTags(service):
+		return fmt.Sprintf("enabled except tags (default; enable them with write: {%s: tags-only} or true)", service)
+	case mode == "" && scope(""):
+		return "enabled where supported (default)"
+	case mode == "":
+		return fmt.Sprintf("disabled (default; enable with write: {%s: true})", service)
+	case mode == "true":
+		return "enabled where supported"
+	case mode == "false":
+		return "disabled"
+	}
+	return "limited to " + mode
+}
+
+// helpConfig lists the settings of the mount
+func (f *SisuFS) helpConfig() string {
+	var b strings.Builder
+	line := func(name, format string, args ...any) {
+		fmt.Fprintf(&b, "%-18s %s\n", name+":", fmt.Sprintf(format, args...))
+	}
+
+	line("profiles", "%s", strings.Join(f.profiles, ", "))
+	line("regions", "%s", strings.Join(f.config.Regions, ", "))
+	if f.config.Root != "" {
+		line("root", "%s", f.config.Root)
+	}
+	line("max entries", "%d per listing", provider.MaxEntries)
+	policy := provider.DefaultCachePolicy
+	if f.config.Cache != nil {
+		policy = *f.config.Cache
+	}
+	line("cache", "listings %s, reads %s, stats %s", policy.ReadDir, policy.Read, policy.Stat)
+	if policy.CompressAbove > 0 {
+		line("cache compression", "contents from %s", FormatSize(policy.CompressAbove))
+	}
+	if f.config.RateLimit > 0 {
+		line("rate limit", "%g calls/s per service", f.config.RateLimit)
+	}
+	if len(f.config.Writable) > 0 {
+		line("writable", "only %d configured patterns", len(f.config.Writable))
+	}
+	if len(f.config.Protected) > 0 {
+		line("protected", "%s", strings.Join(f.config.Protected, ", "))
+	}
+	if len(f.config.Hooks) > 0 {
+		line("hooks", "%d run after writes and deletes", len(f.config.Hooks))
+	}
+	line("round trip", "%t", f.config.RoundTrip)
+	line("redact", "%t", f.config.Redact)
+	line("case insensitive", "%t", f.config.CaseInsensitive)
+	line("hide denied", "%t", f.config.HideDenied)
+	line("dir info", "%t", f.config.DirInfo)
+	if f.config.Index != nil {
+		line("search", "enabled in .sisu/search")
+	}
+	if f.config.Replay != nil {
+		line("replay", "serving a recorded session, not AWS")
+	}
+	if f.config.Record != nil {
+		line("record", "recording every call")
+	}
+	if f.config.Trace != nil {
+		line("trace", "writing traces (sisu trace last)")
+	}
+
+	b.WriteString("\nwrite settings:\n")
+	for _, service := range f.helpServices() {
+		fmt.Fprintf(&b, "  %-16s %s\n", service, f.helpWriteMode(service))
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package fs
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestHelpFiles(t *testing.T) {
+	f := &SisuFS{config: Config{
+		Write:     map[string]config.WriteMode{"lambda": "env-only", "s3": "false"},
+		Render:    map[string]provider.Format{"iam": provider.FormatYAML, "iam/roles": provider.FormatMarkdown},
+		Endpoints: []config.Endpoint{{Name: "inventory", BaseURL: "https://inventory.internal/api", Collections: []config.Collection{{Path: "hosts", List: "/v1/hosts"}}}},
+	}}
+
+	entries, status := f.OpenDir(HelpDir, nil)
+	if !status.Ok() {
+		t.Fatal(status)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	for _, want := range []string{"README.txt", "config.txt", "writes.txt", "s3.txt", "iam.txt", "inventory.txt"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("help/ = %v, missing %s", names, want)
+		}
+	}
+
+	for file, want := range map[string][]string{
+		"s3.txt":        {"S3 buckets", "writes:  disabled"},
+		"lambda.txt":    {"env.json", "writes:  limited to env-only"},
+		"iam.txt":       {"trust-policy.json", "shown as yaml", "in roles/ are listed as .md", "writes:  disabled (default"},
+		"inventory.txt": {"https://inventory.internal/api", "inventory/hosts/<name>.json"},
+		"config.txt":    {"max entries:", "lambda", "limited to env-only"},
+	} {
+		attr, status := f.GetAttr(HelpDir+"/"+file, nil)
+		if !status.Ok() || attr.Mode != fuse.S_IFREG|0444 {
+			t.Errorf("GetAttr(%s) = %v, %v", file, attr, status)
+			continue
+		}
+		data, _ := f.controlData(HelpDir + "/" + file)
+		for _, w := range want {
+			if !strings.Contains(string(data), w) {
+				t.Errorf("%s doesn't mention %q:\n%s", file, w, data)
+			}
+		}
+	}
+
+	if _, status := f.GetAttr(HelpDir+"/rds.txt", nil); status != fuse.ENOENT {
+		t.Errorf("GetAttr(help/rds.txt) = %v, want ENOENT", status)
+	}
+}
+
+func TestHelpSchemas(t *testing.T) {
+	f := &SisuFS{}
+
+	entries, status := f.OpenDir(HelpDir+"/schemas", nil)
+	if !status.Ok() {
+		t.Fatal(status)
+	}
+	var services []string
+	for _, e := range entries {
+		services = append(services, e.Name)
+	}
+	if !slices.Contains(services, "ec2") || slices.Contains(services, "s3") {
+		t.Errorf("help/schemas/ = %v, want ec2 and not s3", services)
+	}
+	if attr, status := f.GetAttr(HelpDir+"/schemas/ec2", nil); !status.Ok() || !attr.IsDir() {
+		t.Errorf("GetAttr(help/schemas/ec2) = %v, %v", attr, status)
+	}
+
+	name := HelpDir + "/schemas/ec2/instance.schema.json"
+	entries, _ = f.OpenDir(HelpDir+"/schemas/ec2", nil)
+	if len(entries) == 0 || entries[0].Name != "instance.schema.json" {
+		t.Errorf("help/schemas/ec2/ = %v", entries)
+	}
+	data, status := f.controlData(name)
+	if !status.Ok() || !strings.Contains(string(data), `"title": "ec2/<instance-id>/info.json"`) {
+		t.Errorf("%s = %v:\n%s", name, status, data)
+	}
+
+	for _, missing := range []string{"schemas/s3", "schemas/ec2/missing.schema.json", "schemas/ec2/instance.json"} {
+		if _, status := f.GetAttr(HelpDir+"/"+missing, nil); status != fuse.ENOENT {
+			t.Errorf("GetAttr(help/%s) = %v, want ENOENT", missing, status)
+		}
+	}
+}
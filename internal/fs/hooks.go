@@ -0,0 +1,182 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// hookTimeout bounds each hook command or webhook request
+const hookTimeout = 10 * time.Second
+
+// HookEvent describes a write or delete made through the mount. It is the
+// JSON given to hooks.
+type HookEvent struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"` // "write" or "delete"
+	Path      string    `json:"path"`      // path in the mount, e.g. prod/global/s3/bucket/key
+	Profile   string    `json:"profile"`
+	Region    string    `json:"region"`
+	Service   string    `json:"service"`
+	Key       string    `json:"key"`                // resource path within the service
+	Identity  string    `json:"identity,omitempty"` // ARN of the AWS identity that made the change
+	User      string    `json:"user"`               // local user running sisu
+	Host      string    `json:"host"`
+	// Text summarizes the event in a line, which is what Slack incoming
+	// webhooks post
+	Text string `json:"text"`
+}
+
+type hook struct {
+	config.Hook
+	paths config.PatternList
+}
+
+// hooks runs the configured hooks after each write and delete. Hooks run
+// in the background; failures are logged and never fail the operation.
+type hooks struct {
+	list       []hook
+	client     *http.Client
+	identities *identities // of the mount's AWS profiles, nil in replays
+}
+
+// newHooks returns the hooks for cfg, which have been validated. It returns
+// nil if none are configured.
+func newHooks(cfg []config.Hook) *hooks {
+	if len(cfg) == 0 {
+		return nil
+	}
+	h := &hooks{client: &http.Client{Timeout: hookTimeout}}
+	for _, c := range cfg {
+		paths, _ := config.ParsePatterns(c.Paths)
+		h.list = append(h.list, hook{Hook: c, paths: paths})
+	}
+	return h
+}
+
+// middleware fires the hooks for successful mutations of the provider
+// mounted at dir, e.g. "prod/global/s3"
+func (h *hooks) middleware(dir string, aws bool) provider.Middleware {
+	return provider.Intercept(func(ctx context.Context, op provider.Op, path string, call func(context.Context) error) error {
+		err := call(ctx)
+		if err == nil && op.IsMutation() {
+			go h.fire(dir, aws, op, path)
+		}
+		return err
+	})
+}
+
+// fire runs every hook matching path. The caller identity is only looked
+// up for AWS profiles.
+func (h *hooks) fire(dir string, aws bool, op provider.Op, path string) {
+	parts := strings.SplitN(dir, "/", 3)
+	if len(parts) != 3 {
+		return
+	}
+	event := HookEvent{
+		Time:      time.Now().UTC(),
+		Operation: string(op),
+		Path:      joinPath(dir, path),
+		Profile:   parts[0],
+		Region:    parts[1],
+		Service:   parts[2],
+		Key:       path,
+	}
+	var matching []hook
+	for _, hk := range h.list {
+		if len(hk.paths) == 0 || hk.paths.Match(event.Service, path) {
+			matching = append(matching, hk)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	if aws {
+		event.Identity = h.callerIdentity(event.Profile)
+	}
+	event.User = os.Getenv("USER")
+	event.Host, _ = os.Hostname()
+	event.Text = fmt.Sprintf("sisu: %s %s %s", event.User, opVerb(op), event.Path)
+	if event.Identity != "" {
+		event.Text += " as " + event.Identity
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, hk := range matching {
+		if err := h.run(hk, event, payload); err != nil {
+			log.Printf("[fs] hook for %s: %v", event.Path, err)
+		}
+	}
+}
+
+func opVerb(op provider.Op) string {
+	if op == provider.OpDelete {
+		return "deleted"
+	}
+	return "wrote"
+}
+
+// callerIdentity returns the caller ARN of profile, from the identities
+// the mount keys its providers by
+func (h *hooks) callerIdentity(profile string) string {
+	if h.identities == nil {
+		return ""
+	}
+	return h.identities.of(profile)
+}
+
+// run sends the event to a single hook
+func (h *hooks) run(hk hook, event HookEvent, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	if hk.Command != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", hk.Command)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Env = append(os.Environ(),
+			"SISU_OPERATION="+event.Operation,
+			"SISU_PATH="+event.Path,
+			"SISU_PROFILE="+event.Profile,
+			"SISU_REGION="+event.Region,
+			"SISU_SERVICE="+event.Service,
+			"SISU_KEY="+event.Key,
+			"SISU_IDENTITY="+event.Identity,
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %w: %s", hk.Command, err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hk.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hk.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", hk.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestHooks(t *testing.T) {
+	posted := make(chan HookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event HookEvent
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &event)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		posted <- event
+	}))
+	defer server.Close()
+	t.Setenv("HOOK_TOKEN", "secret")
+
+	out := filepath.Join(t.TempDir(), "event")
+	h := newHooks([]config.Hook{
+		{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer ${HOOK_TOKEN}"}},
+		{Command: `cat > ` + out + `; echo "$SISU_OPERATION $SISU_KEY" >> ` + out, Paths: []string{"/app/*"}},
+	})
+	var lookups int
+	h.identities = newIdentities(func(ctx context.Context, profile string) (string, error) {
+		lookups++
+		return "arn:aws:iam::123456789012:user/" + profile, nil
+	})
+
+	h.fire("prod/eu-west-1/ssm", true, provider.OpWrite, "app/db-url")
+	event := <-posted
+	if event.Path != "prod/eu-west-1/ssm/app/db-url" || event.Operation != "write" || event.Service != "ssm" {
+		t.Errorf("event = %+v", event)
+	}
+	if event.Identity != "arn:aws:iam::123456789012:user/prod" || !strings.Contains(event.Text, "wrote prod/eu-west-1/ssm/app/db-url") {
+		t.Errorf("identity = %q, text = %q", event.Identity, event.Text)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"key":"app/db-url"`) || !strings.HasSuffix(string(data), "write app/db-url\n") {
+		t.Errorf("command got %q", data)
+	}
+
+	// Only the webhook matches outside /app; the identity is looked up once
+	os.Remove(out)
+	h.fire("prod/eu-west-1/ssm", true, provider.OpDelete, "other")
+	if event := <-posted; event.Operation != "delete" {
+		t.Errorf("event = %+v", event)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("command ran for a path outside its patterns")
+	}
+	if lookups != 1 {
+		t.Errorf("identity looked up %d times, want 1", lookups)
+	}
+}
@@ -0,0 +1,247 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// CredentialsFile is the control file dropping a profile's providers when
+// its credentials change. Writing profile names, one per line, forgets
+// their identities, clients and caches, so the next access loads the
+// credentials again; reading it lists the identity each profile resolved
+// to.
+const CredentialsFile = "credentials"
+
+// identityTimeout bounds looking up the identity of a profile, which the
+// first access to any of its services waits for
+const identityTimeout = 10 * time.Second
+
+// identityRetry is how long a failed lookup is left alone before the next
+// use of the profile tries again, so a profile without credentials doesn't
+// wait for STS on every access
+var identityRetry = 30 * time.Second
+
+// identities resolves the identity each AWS profile's credentials belong
+// to, once per profile until it is forgotten. Providers are cached under
+// it, so a profile switched to another account or role gets new clients
+// and caches instead of serving the previous identity's. Only successful
+// lookups are kept: a failed one is retried after identityRetry, e.g.
+// once the profile's credentials appear.
+type identities struct {
+	lookup func(ctx context.Context, profile string) (string, error)
+
+	mu   sync.Mutex
+	arns map[string]*identity // by profile
+}
+
+// identity is the caller ARN of a profile. The ARN is empty while it is
+// looked up or if the lookup failed, and the profile's providers are then
+// cached under the profile alone.
+type identity struct {
+	done   chan struct{} // closed once looked up
+	arn    string        // guarded by identities.mu, as it is listed while looked up
+	failed time.Time     // when the lookup failed, guarded by identities.mu
+}
+
+func newIdentities(lookup func(ctx context.Context, profile string) (string, error)) *identities {
+	return &identities{lookup: lookup, arns: make(map[string]*identity)}
+}
+
+// of returns the caller ARN of profile, or "" if it can't be looked up.
+// Concurrent callers for the same profile wait for a single lookup.
+func (ids *identities) of(profile string) string {
+	ids.mu.Lock()
+	id, ok := ids.arns[profile]
+	if !ok || !id.failed.IsZero() && time.Since(id.failed) >= identityRetry {
+		id = &identity{done: make(chan struct{})}
+		ids.arns[profile] = id
+		ids.mu.Unlock()
+		ids.resolve(profile, id)
+	} else {
+		ids.mu.Unlock()
+	}
+	<-id.done
+
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	return id.arn
+}
+
+// resolve looks up the identity of profile into id
+func (ids *identities) resolve(profile string, id *identity) {
+	defer close(id.done)
+	profileArg := profile
+	if profile == "default" {
+		profileArg = ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), identityTimeout)
+	defer cancel()
+	arn, err := ids.lookup(ctx, profileArg)
+
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	if err != nil {
+		log.Printf("[fs] looking up identity of %s: %v", profile, err)
+		id.failed = time.Now()
+		return
+	}
+	id.arn = arn
+}
+
+// forget drops the identity of profile, to be looked up again on next use
+func (ids *identities) forget(profile string) {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	delete(ids.arns, profile)
+}
+
+// resolved returns the identities looked up so far, by profile
+func (ids *identities) resolved() map[string]string {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	out := make(map[string]string, len(ids.arns))
+	for profile, id := range ids.arns {
+		if id.arn != "" {
+			out[profile] = id.arn
+		}
+	}
+	return out
+}
+
+// providerKey returns the key of a provider in the providers map: key,
+// "profile/region/service", followed by the identity of the profile, if
+// it has one
+func (f *SisuFS) providerKey(key, profile string) string {
+	if f.identities == nil {
+		return key
+	}
+	if _, ok := f.clouds[profile]; ok {
+		return key
+	}
+	if arn := f.identities.of(profile); arn != "" {
+		return key + "@" + arn
+	}
+	return key
+}
+
+// accountOf returns the AWS account of profile's identity, or "" if it
+// isn't known
+func (f *SisuFS) accountOf(profile string) string {
+	if f.identities == nil {
+		return ""
+	}
+	if _, ok := f.clouds[profile]; ok {
+		return ""
+	}
+	// arn:partition:service:region:account:resource
+	parts := strings.SplitN(f.identities.of(profile), ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// splitProviderKey returns the "profile/region/service" part of a key in
+// the providers map
+func splitProviderKey(key string) string {
+	key, _, _ = strings.Cut(key, "@")
+	return key
+}
+
+// DropProfile forgets everything cached for profile under its current
+// credentials: its identity, the providers of each of its services with
+// their result caches, page cursors and denied listings. The next access
+// creates them again with freshly loaded credentials. Pinned snapshots
+// are kept.
+func (f *SisuFS) DropProfile(profile string) error {
+	if !slices.Contains(f.profiles, profile) {
+		return fmt.Errorf("%s: no such profile: %w", profile, os.ErrNotExist)
+	}
+	if f.identities != nil {
+		f.identities.forget(profile)
+	}
+	provider.InvalidateCredentials(profile)
+
+	prefix := profile + "/"
+	var dropped []provider.Provider
+	f.providersMu.Lock()
+	for key, p := range f.providers {
+		if strings.HasPrefix(key, prefix) {
+			dropped = append(dropped, p)
+			delete(f.providers, key)
+		}
+	}
+	for key := range f.pages {
+		if strings.HasPrefix(key, prefix) {
+			delete(f.pages, key)
+		}
+	}
+	f.providersMu.Unlock()
+	// Calls still running on them finish; only their cache sweeping stops
+	for _, p := range dropped {
+		provider.Close(p)
+	}
+
+	f.mu.Lock()
+	for key := range f.denied {
+		if strings.HasPrefix(key, prefix) {
+			delete(f.denied, key)
+		}
+	}
+	f.mu.Unlock()
+	return nil
+}
+
+// credentialsStatus lists the resolved identity of each profile as
+// "<profile> <arn>" lines
+func (f *SisuFS) credentialsStatus() []byte {
+	if f.identities == nil {
+		return nil
+	}
+	arns := f.identities.resolved()
+	profiles := make([]string, 0, len(arns))
+	for profile := range arns {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	var b bytes.Buffer
+	for _, profile := range profiles {
+		fmt.Fprintf(&b, "%s %s\n", profile, arns[profile])
+	}
+	return b.Bytes()
+}
+
+// credentialsFile takes the profiles written to .sisu/credentials and
+// drops them when flushed, like armFile
+type credentialsFile struct {
+	*armFile
+}
+
+func (f *credentialsFile) Flush() fuse.Status {
+	f.mu.Lock()
+	data := f.data
+	f.data = nil
+	f.mu.Unlock()
+
+	status := fuse.OK
+	for _, profile := range strings.Fields(string(data)) {
+		if err := f.fs.DropProfile(profile); err != nil {
+			log.Printf("[fs] %s: %v", CredentialsFile, err)
+			status = fuse.EINVAL
+			continue
+		}
+		log.Printf("[fs] dropped the providers of %s", profile)
+	}
+	return status
+}
@@ -0,0 +1,199 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func newIdentityFS(t *testing.T, arn *atomic.Value, lookups *atomic.Int32) *SisuFS {
+	t.Helper()
+	f, err := NewSisuFS(Config{
+		Regions:   []string{"us-east-1"},
+		Endpoints: []config.Endpoint{{Name: "inventory", BaseURL: "https://inventory.internal/api"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.profiles = []string{"prod", "dev"}
+	f.identities = newIdentities(func(ctx context.Context, profile string) (string, error) {
+		lookups.Add(1)
+		if profile == "dev" {
+			return "", errors.New("no credentials")
+		}
+		return arn.Load().(string), nil
+	})
+	return f
+}
+
+func TestProvidersKeyedByIdentity(t *testing.T) {
+	var arn atomic.Value
+	arn.Store("arn:aws:sts::111111111111:assumed-role/Reader/session")
+	var lookups atomic.Int32
+	f := newIdentityFS(t, &arn, &lookups)
+
+	var wg sync.WaitGroup
+	got := make([]any, 8)
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := f.getProvider("prod", "us-east-1", "inventory")
+			if err != nil {
+				t.Error(err)
+			}
+			got[i] = p
+		}()
+	}
+	wg.Wait()
+	for _, p := range got[1:] {
+		if p != got[0] {
+			t.Fatal("concurrent lookups created several providers")
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("identity looked up %d times, want once", n)
+	}
+	if _, ok := f.providers["prod/us-east-1/inventory@arn:aws:sts::111111111111:assumed-role/Reader/session"]; !ok {
+		t.Errorf("providers = %v, want keyed by identity", f.providers)
+	}
+
+	// A failed lookup caches under the profile alone
+	if _, err := f.getProvider("dev", "us-east-1", "inventory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.providers["dev/us-east-1/inventory"]; !ok {
+		t.Errorf("providers = %v, want dev without identity", f.providers)
+	}
+
+	// The role changes: nothing changes until the profile is dropped
+	arn.Store("arn:aws:sts::222222222222:assumed-role/Admin/session")
+	p, _ := f.getProvider("prod", "us-east-1", "inventory")
+	if p != got[0] {
+		t.Error("provider replaced before the profile was dropped")
+	}
+	if err := f.DropProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = f.getProvider("prod", "us-east-1", "inventory")
+	if p == got[0] {
+		t.Error("provider kept after the profile was dropped")
+	}
+	if _, ok := f.providers["prod/us-east-1/inventory@arn:aws:sts::222222222222:assumed-role/Admin/session"]; !ok || len(f.providers) != 2 {
+		t.Errorf("providers = %v, want the new identity and dev", f.providers)
+	}
+	if _, ok := f.Stats().Cache["prod/global/inventory"]; !ok {
+		t.Errorf("stats cache = %v, want keyed by service directory", f.Stats().Cache)
+	}
+
+	if err := f.DropProfile("staging"); err == nil {
+		t.Error("dropping an unknown profile succeeded")
+	}
+}
+
+func TestFailedIdentityRetried(t *testing.T) {
+	defer func(d time.Duration) { identityRetry = d }(identityRetry)
+	var lookups int
+	fail := true
+	ids := newIdentities(func(ctx context.Context, profile string) (string, error) {
+		lookups++
+		if fail {
+			return "", errors.New("no credentials")
+		}
+		return "arn:aws:iam::111111111111:user/ci", nil
+	})
+
+	// Within identityRetry the failure is reused rather than looked up again
+	identityRetry = time.Hour
+	if ids.of("ci") != "" || ids.of("ci") != "" || lookups != 1 {
+		t.Errorf("%d lookups, want 1 failed", lookups)
+	}
+	// After it, the credentials that appeared are found and then kept
+	identityRetry = 0
+	fail = false
+	if arn := ids.of("ci"); arn != "arn:aws:iam::111111111111:user/ci" {
+		t.Errorf("identity = %q after the retry", arn)
+	}
+	ids.of("ci")
+	if lookups != 2 {
+		t.Errorf("%d lookups, want 2", lookups)
+	}
+}
+
+func TestCredentialsFile(t *testing.T) {
+	var arn atomic.Value
+	arn.Store("arn:aws:iam::111111111111:user/ci")
+	var lookups atomic.Int32
+	f := newIdentityFS(t, &arn, &lookups)
+	if _, err := f.getProvider("prod", "us-east-1", "inventory"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, status := f.controlData(ControlDir + "/" + CredentialsFile)
+	if !status.Ok() || string(data) != "prod arn:aws:iam::111111111111:user/ci\n" {
+		t.Errorf("credentials = %q, %v", data, status)
+	}
+
+	file, status := f.controlOpen(ControlDir+"/"+CredentialsFile, syscall.O_WRONLY)
+	if !status.Ok() {
+		t.Fatalf("open = %v", status)
+	}
+	file.Write([]byte("prod\n"), 0)
+	if status := file.Flush(); !status.Ok() {
+		t.Errorf("flush = %v", status)
+	}
+	if len(f.providers) != 0 {
+		t.Errorf("providers = %v after dropping prod", f.providers)
+	}
+	if data, _ := f.controlData(ControlDir + "/" + CredentialsFile); len(data) != 0 {
+		t.Errorf("credentials = %q after dropping prod", data)
+	}
+
+	file.Write([]byte("nope\n"), 0)
+	if status := file.Flush(); status != fuse.EINVAL {
+		t.Errorf("flush of an unknown profile = %v, want EINVAL", status)
+	}
+}
+
+func TestDeniedServicesExpire(t *testing.T) {
+	f := &SisuFS{config: Config{HideDenied: true}, denied: map[string]time.Time{
+		"prod/us-east-1/ssm": time.Now(),
+		"prod/us-east-1/sqs": time.Now().Add(-deniedTTL),
+	}}
+	if !f.hidden("prod", "us-east-1", "ssm") {
+		t.Error("a service denied just now isn't hidden")
+	}
+	if f.hidden("prod", "us-east-1", "sqs") {
+		t.Error("a service denied deniedTTL ago is still hidden")
+	}
+}
+
+// closingProvider records being closed
+type closingProvider struct {
+	*memProvider
+	closed bool
+}
+
+func (p *closingProvider) Close() { p.closed = true }
+
+func TestDropProfileClosesProviders(t *testing.T) {
+	prod, dev := &closingProvider{memProvider: &memProvider{}}, &closingProvider{memProvider: &memProvider{}}
+	f := &SisuFS{
+		profiles:  []string{"prod", "dev"},
+		providers: map[string]provider.Provider{"prod/us-east-1/ssm": prod, "dev/us-east-1/ssm": dev},
+	}
+	if err := f.DropProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if !prod.closed || dev.closed {
+		t.Errorf("closed: prod %v, dev %v; want only prod", prod.closed, dev.closed)
+	}
+}
@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+)
+
+// activityFS notes the time of every kernel request in lastActivity, except
+// those that don't mean anyone is using the mount: stats of the mount root,
+// as made by the watchdog and health checks, and anything in ControlDir,
+// as read by sisu status. Reads and writes of open files count too, so a
+// long copy isn't taken for idleness.
+type activityFS struct {
+	pathfs.FileSystem
+	f *SisuFS
+}
+
+func (a *activityFS) touch(name string) {
+	if name == ControlDir || strings.HasPrefix(name, ControlDir+"/") {
+		return
+	}
+	a.f.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (a *activityFS) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Status) {
+	if name != "" {
+		a.touch(name)
+	}
+	return a.FileSystem.GetAttr(name, ctx)
+}
+
+func (a *activityFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	a.touch(name)
+	return a.FileSystem.OpenDir(name, ctx)
+}
+
+func (a *activityFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	a.touch(name)
+	file, status := a.FileSystem.Open(name, flags, ctx)
+	return a.wrap(name, file), status
+}
+
+func (a *activityFS) Create(name string, flags uint32, mode uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	a.touch(name)
+	file, status := a.FileSystem.Create(name, flags, mode, ctx)
+	return a.wrap(name, file), status
+}
+
+// wrap returns file noting its reads and writes as activity
+func (a *activityFS) wrap(name string, file nodefs.File) nodefs.File {
+	if file == nil {
+		return nil
+	}
+	// Open flags only take effect on the outermost file
+	if wf, ok := file.(*nodefs.WithFlags); ok {
+		flagged := *wf
+		flagged.File = a.wrap(name, wf.File)
+		return &flagged
+	}
+	return &activityFile{File: file, fs: a, name: name}
+}
+
+// activityFile is an open file of activityFS
+type activityFile struct {
+	nodefs.File
+	fs   *activityFS
+	name string
+}
+
+func (f *activityFile) InnerFile() nodefs.File {
+	return f.File
+}
+
+func (f *activityFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	f.fs.touch(f.name)
+	return f.File.Read(dest, off)
+}
+
+func (f *activityFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	f.fs.touch(f.name)
+	return f.File.Write(data, off)
+}
+
+func (a *activityFS) Mkdir(name string, mode uint32, ctx *fuse.Context) fuse.Status {
+	a.touch(name)
+	return a.FileSystem.Mkdir(name, mode, ctx)
+}
+
+func (a *activityFS) Unlink(name string, ctx *fuse.Context) fuse.Status {
+	a.touch(name)
+	return a.FileSystem.Unlink(name, ctx)
+}
+
+func (a *activityFS) Rmdir(name string, ctx *fuse.Context) fuse.Status {
+	a.touch(name)
+	return a.FileSystem.Rmdir(name, ctx)
+}
+
+func (a *activityFS) Rename(oldName string, newName string, ctx *fuse.Context) fuse.Status {
+	a.touch(oldName)
+	return a.FileSystem.Rename(oldName, newName, ctx)
+}
+
+func (a *activityFS) GetXAttr(name string, attribute string, ctx *fuse.Context) ([]byte, fuse.Status) {
+	a.touch(name)
+	return a.FileSystem.GetXAttr(name, attribute, ctx)
+}
+
+// IdleFor returns how long the mount has gone without activity
+func (f *SisuFS) IdleFor() time.Duration {
+	return time.Since(time.Unix(0, f.lastActivity.Load()))
+}
+
+// WatchIdle calls onIdle, with how long the mount has been idle, once it
+// goes timeout without activity, and again each time it goes idle after
+// being used, until ctx is done
+func (f *SisuFS) WatchIdle(ctx context.Context, timeout time.Duration, onIdle func(idle time.Duration)) {
+	f.lastActivity.CompareAndSwap(0, time.Now().UnixNano())
+	ticker := time.NewTicker(min(max(timeout/10, 10*time.Millisecond), time.Minute))
+	defer ticker.Stop()
+
+	notified := int64(0) // lastActivity when onIdle was last called
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		last := f.lastActivity.Load()
+		if idle := f.IdleFor(); idle >= timeout && last != notified {
+			notified = last
+			onIdle(idle)
+		}
+	}
+}
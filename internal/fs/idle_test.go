@@ -0,0 +1,91 @@
+package fs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+)
+
+func TestActivityIgnoresProbes(t *testing.T) {
+	f := &SisuFS{}
+	a := &activityFS{FileSystem: pathfs.NewDefaultFileSystem(), f: f}
+
+	a.GetAttr("", nil)
+	a.OpenDir(ControlDir, nil)
+	a.Open(ControlDir+"/stats.json", 0, nil)
+	if f.lastActivity.Load() != 0 {
+		t.Error("root stat or control file read counted as activity")
+	}
+	a.GetAttr("prod", nil)
+	if f.IdleFor() > time.Second {
+		t.Errorf("IdleFor = %s after a stat", f.IdleFor())
+	}
+}
+
+// openFS opens every file as a file of data
+type openFS struct {
+	pathfs.FileSystem
+}
+
+func (openFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	return nodefs.NewDataFile([]byte("data")), fuse.OK
+}
+
+func TestActivityCountsReads(t *testing.T) {
+	f := &SisuFS{}
+	a := &activityFS{FileSystem: openFS{pathfs.NewDefaultFileSystem()}, f: f}
+
+	file, status := a.Open("prod/us-east-1/ssm/app", 0, nil)
+	if !status.Ok() {
+		t.Fatal(status)
+	}
+	f.lastActivity.Store(0)
+	if _, status := file.Read(make([]byte, 4), 0); !status.Ok() {
+		t.Fatal(status)
+	}
+	if f.lastActivity.Load() == 0 {
+		t.Error("read of an open file not counted as activity")
+	}
+}
+
+func TestActivityKeepsOpenFlags(t *testing.T) {
+	a := &activityFS{f: &SisuFS{}}
+	file := a.wrap("f", &nodefs.WithFlags{File: nodefs.NewDefaultFile(), FuseFlags: fuse.FOPEN_DIRECT_IO})
+
+	wf, ok := file.(*nodefs.WithFlags)
+	if !ok || wf.FuseFlags != fuse.FOPEN_DIRECT_IO {
+		t.Fatalf("wrapped file = %#v, want the flags kept outermost", file)
+	}
+	if _, ok := wf.File.(*activityFile); !ok {
+		t.Errorf("inner file = %T, want *activityFile", wf.File)
+	}
+}
+
+func TestWatchIdleNotifiesOncePerIdlePeriod(t *testing.T) {
+	f := &SisuFS{}
+	a := &activityFS{FileSystem: pathfs.NewDefaultFileSystem(), f: f}
+	idle := make(chan time.Duration, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.WatchIdle(ctx, 20*time.Millisecond, func(d time.Duration) { idle <- d })
+
+	if d := <-idle; d < 20*time.Millisecond {
+		t.Errorf("idle after %s, want at least 20ms", d)
+	}
+	select {
+	case <-idle:
+		t.Fatal("notified twice without activity in between")
+	case <-time.After(60 * time.Millisecond):
+	}
+
+	a.OpenDir("prod", nil)
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("not notified after going idle again")
+	}
+}
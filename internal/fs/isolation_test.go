@@ -0,0 +1,79 @@
+package fs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestSlowProfileDoesNotBlockOthers(t *testing.T) {
+	f, err := NewSisuFS(Config{Regions: []string{"us-east-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var listed []string
+	release := make(chan struct{})
+	f.clouds = map[string]cloud{
+		"slow": {scopes: []string{"a"}, services: []string{"gcs"}, newProvider: func(scope, service string) provider.Provider {
+			<-release
+			return &listProvider{mu: &mu, listed: &listed, key: "slow"}
+		}},
+		"fast": {scopes: []string{"a"}, services: []string{"gcs"}, newProvider: func(scope, service string) provider.Provider {
+			return &listProvider{mu: &mu, listed: &listed, key: "fast"}
+		}},
+	}
+
+	slow := make(chan provider.Provider, 2)
+	for range 2 {
+		go func() {
+			p, _ := f.getProvider("slow", "a", "gcs")
+			slow <- p
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.getProvider("fast", "a", "gcs")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("creating a provider waited for another profile")
+	}
+
+	close(release)
+	if a, b := <-slow, <-slow; a == nil || a != b {
+		t.Errorf("concurrent creators got providers %p and %p, want the same one", a, b)
+	}
+}
+
+func TestBackoffAndPoolsArePerProfile(t *testing.T) {
+	f := &SisuFS{
+		config:   Config{RateLimit: 5},
+		backoffs: make(map[string]*provider.Backoff),
+		pools:    make(map[string]*provider.Pool),
+		limiters: make(map[string]*provider.Limiter),
+	}
+	if f.backoffFor("prod", "s3") == f.backoffFor("dev", "s3") {
+		t.Error("profiles share a backoff")
+	}
+	if f.backoffFor("prod", "s3") != f.backoffFor("prod", "s3") {
+		t.Error("a profile's providers of a service don't share a backoff")
+	}
+	if f.poolFor("prod") == f.poolFor("dev") || f.poolFor("prod") != f.poolFor("prod") {
+		t.Error("pools are not per profile")
+	}
+	if f.limiterFor("prod", "s3") == f.limiterFor("dev", "s3") || f.limiterFor("prod", "s3") == f.limiterFor("prod", "ec2") {
+		t.Error("rate limits shared across profiles or services")
+	}
+	if f.limiterFor("prod", "s3") != f.limiterFor("prod", "s3") {
+		t.Error("a profile's providers of a service don't share a rate limit")
+	}
+}
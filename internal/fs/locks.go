@@ -0,0 +1,278 @@
+package fs
+
+import (
+	"math"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+)
+
+// fileLocks holds the flock and POSIX (fcntl) locks taken on files in the
+// mount, so editors and tools that insist on locking work. The locks are
+// advisory and only known to this mount: they keep two processes using
+// the same mount apart, not two machines or the AWS console.
+type fileLocks struct {
+	mu       sync.Mutex
+	released chan struct{} // closed and replaced whenever locks are dropped
+	held     map[string][]heldLock
+	// interrupts holds the channel the kernel closes to interrupt the
+	// SetLkw request of each owner waiting for a lock
+	interrupts map[uint64]<-chan struct{}
+}
+
+// heldLock is a lock on the bytes start to end (inclusive) of a file
+type heldLock struct {
+	owner      uint64 // the kernel's lock owner: an open file for flock, a process for POSIX
+	flock      bool   // flock and POSIX locks don't conflict with each other
+	typ        uint32 // syscall.F_RDLCK or syscall.F_WRLCK
+	start, end uint64
+	pid        uint32
+}
+
+func newFileLocks() *fileLocks {
+	return &fileLocks{
+		released:   make(chan struct{}),
+		held:       make(map[string][]heldLock),
+		interrupts: make(map[uint64]<-chan struct{}),
+	}
+}
+
+// broadcast wakes the requests waiting for locks to go. Callers must hold
+// mu.
+func (l *fileLocks) broadcast() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// interruptWith makes cancel interrupt owner's wait for a lock until the
+// returned function is called
+func (l *fileLocks) interruptWith(owner uint64, cancel <-chan struct{}) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interrupts[owner] = cancel
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.interrupts[owner] == cancel {
+			delete(l.interrupts, owner)
+		}
+	}
+}
+
+func (h heldLock) conflicts(owner uint64, flock bool, lk *fuse.FileLock) bool {
+	return h.owner != owner && h.flock == flock &&
+		(h.typ == syscall.F_WRLCK || lk.Typ == syscall.F_WRLCK) &&
+		h.start <= lk.End && lk.Start <= h.end
+}
+
+// conflict returns a lock another owner holds that keeps owner from taking
+// lk on path. Callers must hold mu.
+func (l *fileLocks) conflict(path string, owner uint64, flock bool, lk *fuse.FileLock) (heldLock, bool) {
+	for _, h := range l.held[path] {
+		if h.conflicts(owner, flock, lk) {
+			return h, true
+		}
+	}
+	return heldLock{}, false
+}
+
+// unlock drops owner's locks on the range of lk, keeping the parts of them
+// outside it. Callers must hold mu.
+func (l *fileLocks) unlock(path string, owner uint64, flock bool, lk *fuse.FileLock) {
+	var kept []heldLock
+	for _, h := range l.held[path] {
+		if h.owner != owner || h.flock != flock || h.end < lk.Start || lk.End < h.start {
+			kept = append(kept, h)
+			continue
+		}
+		if h.start < lk.Start {
+			before := h
+			before.end = lk.Start - 1
+			kept = append(kept, before)
+		}
+		if lk.End < h.end {
+			after := h
+			after.start = lk.End + 1
+			kept = append(kept, after)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.held, path)
+	} else {
+		l.held[path] = kept
+	}
+	l.broadcast()
+}
+
+// set takes or releases lk on path for owner, waiting for conflicting
+// locks to go if wait is set, unless the wait is interrupted
+func (l *fileLocks) set(path string, owner uint64, flags uint32, lk *fuse.FileLock, wait bool) fuse.Status {
+	flock := flags&fuse.FUSE_LK_FLOCK != 0
+	if flock {
+		lk = &fuse.FileLock{Start: 0, End: math.MaxUint64, Typ: lk.Typ, Pid: lk.Pid}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch lk.Typ {
+	case syscall.F_UNLCK:
+		l.unlock(path, owner, flock, lk)
+		return fuse.OK
+	case syscall.F_RDLCK, syscall.F_WRLCK:
+	default:
+		return fuse.EINVAL
+	}
+	for {
+		if _, ok := l.conflict(path, owner, flock, lk); !ok {
+			break
+		}
+		if !wait {
+			return fuse.EAGAIN
+		}
+		released, interrupt := l.released, l.interrupts[owner]
+		l.mu.Unlock()
+		select {
+		case <-released:
+			l.mu.Lock()
+		case <-interrupt:
+			l.mu.Lock()
+			return fuse.EINTR
+		}
+	}
+	// A new lock replaces what the owner held on the range, e.g. turning
+	// a read lock into a write lock
+	l.unlock(path, owner, flock, lk)
+	l.held[path] = append(l.held[path], heldLock{
+		owner: owner, flock: flock, typ: lk.Typ, start: lk.Start, end: lk.End, pid: lk.Pid,
+	})
+	return fuse.OK
+}
+
+// get reports a lock that would keep owner from taking lk on path in out,
+// or sets out.Typ to F_UNLCK if there's none
+func (l *fileLocks) get(path string, owner uint64, flags uint32, lk *fuse.FileLock, out *fuse.FileLock) fuse.Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.conflict(path, owner, flags&fuse.FUSE_LK_FLOCK != 0, lk)
+	if !ok {
+		*out = fuse.FileLock{Typ: syscall.F_UNLCK}
+		return fuse.OK
+	}
+	*out = fuse.FileLock{Start: h.start, End: h.end, Typ: h.typ, Pid: h.pid}
+	return fuse.OK
+}
+
+// release drops every lock of the given owners on path
+func (l *fileLocks) release(path string, owners map[uint64]bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var kept []heldLock
+	for _, h := range l.held[path] {
+		if !owners[h.owner] {
+			kept = append(kept, h)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.held, path)
+	} else {
+		l.held[path] = kept
+	}
+	l.broadcast()
+}
+
+// interruptibleFS passes the kernel's interrupts of SetLkw requests on to
+// fileLocks, as pathfs doesn't hand them to the files
+type interruptibleFS struct {
+	fuse.RawFileSystem
+	locks *fileLocks
+}
+
+func (r *interruptibleFS) SetLkw(cancel <-chan struct{}, input *fuse.LkIn) fuse.Status {
+	defer r.locks.interruptWith(input.Owner, cancel)()
+	return r.RawFileSystem.SetLkw(cancel, input)
+}
+
+// lockingFS serves lock requests on every file opened in the mount from
+// fileLocks, as the files themselves don't implement locking
+type lockingFS struct {
+	pathfs.FileSystem
+	locks *fileLocks
+}
+
+func (l *lockingFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	file, status := l.FileSystem.Open(name, flags, ctx)
+	return l.wrap(name, file), status
+}
+
+func (l *lockingFS) Create(name string, flags uint32, mode uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	file, status := l.FileSystem.Create(name, flags, mode, ctx)
+	return l.wrap(name, file), status
+}
+
+func (l *lockingFS) wrap(name string, file nodefs.File) nodefs.File {
+	if file == nil {
+		return nil
+	}
+	// Open flags only take effect on the outermost file
+	if wf, ok := file.(*nodefs.WithFlags); ok {
+		flagged := *wf
+		flagged.File = l.wrap(name, wf.File)
+		return &flagged
+	}
+	return &lockedFile{File: file, locks: l.locks, path: name}
+}
+
+// lockedFile is an open file whose locks are kept in fileLocks. Closing it
+// drops the locks taken through it, since the kernel doesn't always send
+// the unlock for POSIX locks of a process that exits.
+type lockedFile struct {
+	nodefs.File
+	locks *fileLocks
+	path  string
+
+	mu     sync.Mutex
+	owners map[uint64]bool
+}
+
+func (f *lockedFile) InnerFile() nodefs.File {
+	return f.File
+}
+
+func (f *lockedFile) GetLk(owner uint64, lk *fuse.FileLock, flags uint32, out *fuse.FileLock) fuse.Status {
+	return f.locks.get(f.path, owner, flags, lk, out)
+}
+
+func (f *lockedFile) SetLk(owner uint64, lk *fuse.FileLock, flags uint32) fuse.Status {
+	f.own(owner)
+	return f.locks.set(f.path, owner, flags, lk, false)
+}
+
+func (f *lockedFile) SetLkw(owner uint64, lk *fuse.FileLock, flags uint32) fuse.Status {
+	f.own(owner)
+	return f.locks.set(f.path, owner, flags, lk, true)
+}
+
+func (f *lockedFile) own(owner uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.owners == nil {
+		f.owners = make(map[uint64]bool)
+	}
+	f.owners[owner] = true
+}
+
+func (f *lockedFile) Release() {
+	f.mu.Lock()
+	owners := f.owners
+	f.mu.Unlock()
+	if owners != nil {
+		f.locks.release(f.path, owners)
+	}
+	f.File.Release()
+}
@@ -0,0 +1,143 @@
+package fs
+
+import (
+	"math"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+)
+
+func TestPOSIXLocksConflictOnOverlap(t *testing.T) {
+	l := newFileLocks()
+
+	if s := l.set("f", 1, 0, &fuse.FileLock{Start: 0, End: 99, Typ: syscall.F_WRLCK, Pid: 10}, false); s != fuse.OK {
+		t.Fatalf("first lock = %v", s)
+	}
+	if s := l.set("f", 2, 0, &fuse.FileLock{Start: 50, End: 60, Typ: syscall.F_RDLCK}, false); s != fuse.EAGAIN {
+		t.Errorf("overlapping lock = %v, want EAGAIN", s)
+	}
+	if s := l.set("f", 2, 0, &fuse.FileLock{Start: 100, End: 199, Typ: syscall.F_WRLCK}, false); s != fuse.OK {
+		t.Errorf("lock past the range = %v, want OK", s)
+	}
+	if s := l.set("g", 2, 0, &fuse.FileLock{Start: 0, End: 99, Typ: syscall.F_WRLCK}, false); s != fuse.OK {
+		t.Errorf("lock on another file = %v, want OK", s)
+	}
+
+	var out fuse.FileLock
+	l.get("f", 2, 0, &fuse.FileLock{Start: 10, End: 10, Typ: syscall.F_RDLCK}, &out)
+	if out.Typ != syscall.F_WRLCK || out.Pid != 10 || out.End != 99 {
+		t.Errorf("GetLk = %+v, want owner 1's write lock", out)
+	}
+
+	// Unlocking the middle leaves the two ends locked
+	l.set("f", 1, 0, &fuse.FileLock{Start: 40, End: 59, Typ: syscall.F_UNLCK}, false)
+	if s := l.set("f", 2, 0, &fuse.FileLock{Start: 45, End: 50, Typ: syscall.F_WRLCK}, false); s != fuse.OK {
+		t.Errorf("lock in the unlocked middle = %v, want OK", s)
+	}
+	if s := l.set("f", 3, 0, &fuse.FileLock{Start: 60, End: 60, Typ: syscall.F_RDLCK}, false); s != fuse.EAGAIN {
+		t.Errorf("lock on the kept end = %v, want EAGAIN", s)
+	}
+}
+
+func TestReadLocksShare(t *testing.T) {
+	l := newFileLocks()
+	whole := &fuse.FileLock{Start: 0, End: math.MaxUint64, Typ: syscall.F_RDLCK}
+
+	for owner := uint64(1); owner <= 2; owner++ {
+		if s := l.set("f", owner, 0, whole, false); s != fuse.OK {
+			t.Fatalf("read lock %d = %v", owner, s)
+		}
+	}
+	upgrade := &fuse.FileLock{Start: 0, End: math.MaxUint64, Typ: syscall.F_WRLCK}
+	if s := l.set("f", 1, 0, upgrade, false); s != fuse.EAGAIN {
+		t.Errorf("upgrade while shared = %v, want EAGAIN", s)
+	}
+	l.set("f", 2, 0, &fuse.FileLock{Start: 0, End: math.MaxUint64, Typ: syscall.F_UNLCK}, false)
+	if s := l.set("f", 1, 0, upgrade, false); s != fuse.OK {
+		t.Errorf("upgrade once alone = %v, want OK", s)
+	}
+}
+
+func TestFlockIgnoresPOSIXLocks(t *testing.T) {
+	l := newFileLocks()
+
+	l.set("f", 1, 0, &fuse.FileLock{Start: 0, End: 10, Typ: syscall.F_WRLCK}, false)
+	if s := l.set("f", 2, fuse.FUSE_LK_FLOCK, &fuse.FileLock{Typ: syscall.F_WRLCK}, false); s != fuse.OK {
+		t.Errorf("flock next to a POSIX lock = %v, want OK", s)
+	}
+	if s := l.set("f", 3, fuse.FUSE_LK_FLOCK, &fuse.FileLock{Typ: syscall.F_RDLCK}, false); s != fuse.EAGAIN {
+		t.Errorf("second flock = %v, want EAGAIN", s)
+	}
+}
+
+func TestSetLkwWaitsForRelease(t *testing.T) {
+	locks := newFileLocks()
+	first := &lockedFile{File: nodefs.NewDefaultFile(), locks: locks, path: "f"}
+	second := &lockedFile{File: nodefs.NewDefaultFile(), locks: locks, path: "f"}
+
+	if s := first.SetLk(1, &fuse.FileLock{Typ: syscall.F_WRLCK}, fuse.FUSE_LK_FLOCK); s != fuse.OK {
+		t.Fatalf("SetLk = %v", s)
+	}
+	done := make(chan fuse.Status)
+	go func() { done <- second.SetLkw(2, &fuse.FileLock{Typ: syscall.F_WRLCK}, fuse.FUSE_LK_FLOCK) }()
+
+	select {
+	case s := <-done:
+		t.Fatalf("SetLkw returned %v while the lock was held", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// Closing the first file drops its lock
+	first.Release()
+	select {
+	case s := <-done:
+		if s != fuse.OK {
+			t.Errorf("SetLkw = %v, want OK", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SetLkw still waiting after the lock was released")
+	}
+}
+
+func TestSetLkwInterrupted(t *testing.T) {
+	locks := newFileLocks()
+	first := &lockedFile{File: nodefs.NewDefaultFile(), locks: locks, path: "f"}
+	second := &lockedFile{File: nodefs.NewDefaultFile(), locks: locks, path: "f"}
+
+	if s := first.SetLk(1, &fuse.FileLock{Typ: syscall.F_WRLCK}, fuse.FUSE_LK_FLOCK); s != fuse.OK {
+		t.Fatalf("SetLk = %v", s)
+	}
+	cancel := make(chan struct{})
+	defer locks.interruptWith(2, cancel)()
+	done := make(chan fuse.Status)
+	go func() { done <- second.SetLkw(2, &fuse.FileLock{Typ: syscall.F_WRLCK}, fuse.FUSE_LK_FLOCK) }()
+
+	// The kernel interrupts the request, e.g. as the process got a signal
+	close(cancel)
+	select {
+	case s := <-done:
+		if s != fuse.EINTR {
+			t.Errorf("SetLkw = %v, want EINTR", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SetLkw still waiting after the interrupt")
+	}
+	if held := locks.held["f"]; len(held) != 1 || held[0].owner != 1 {
+		t.Errorf("held = %+v, want only the first lock", held)
+	}
+}
+
+func TestLockingKeepsOpenFlags(t *testing.T) {
+	l := &lockingFS{locks: newFileLocks()}
+	file := l.wrap("f", &nodefs.WithFlags{File: nodefs.NewDefaultFile(), FuseFlags: fuse.FOPEN_KEEP_CACHE})
+
+	wf, ok := file.(*nodefs.WithFlags)
+	if !ok || wf.FuseFlags != fuse.FOPEN_KEEP_CACHE {
+		t.Fatalf("wrapped file = %#v, want the flags kept outermost", file)
+	}
+	if _, ok := wf.File.(*lockedFile); !ok {
+		t.Errorf("inner file = %T, want *lockedFile", wf.File)
+	}
+}
@@ -0,0 +1,53 @@
+package fs
+
+import (
+	"sync"
+	"time"
+)
+
+// A directory that sees lookupStormSize lookups, each within lookupWindow of
+// the one before, is in a lookup storm, e.g. from shell tab-completion
+// stat'ing every candidate. Lookups in a storm are answered with batch
+// stats, which cost one listing instead of one or more calls per entry.
+const (
+	lookupStormSize = 8
+	lookupWindow    = time.Second
+)
+
+// lookups counts recent lookups per directory
+type lookups struct {
+	mu   sync.Mutex
+	dirs map[string]*lookupCount
+}
+
+type lookupCount struct {
+	n    int
+	last time.Time
+}
+
+func newLookups() *lookups {
+	return &lookups{dirs: make(map[string]*lookupCount)}
+}
+
+// storm records a lookup in dir and reports whether dir is in a lookup storm
+func (l *lookups) storm(dir string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.dirs[dir]
+	if !ok || now.Sub(c.last) > lookupWindow {
+		// Drop directories that went quiet, so the map only holds recent ones
+		if len(l.dirs) > 1000 {
+			for d, old := range l.dirs {
+				if now.Sub(old.last) > lookupWindow {
+					delete(l.dirs, d)
+				}
+			}
+		}
+		c = &lookupCount{}
+		l.dirs[dir] = c
+	}
+	c.n++
+	c.last = now
+	return c.n >= lookupStormSize
+}
@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupStorm(t *testing.T) {
+	l := newLookups()
+	now := time.Now()
+
+	for i := 1; i < lookupStormSize; i++ {
+		if l.storm("p/global/s3/bucket", now) {
+			t.Fatalf("storm after %d lookups", i)
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+	if !l.storm("p/global/s3/bucket", now) {
+		t.Error("no storm after a burst of lookups")
+	}
+	if l.storm("p/global/s3/other", now) {
+		t.Error("storm in a directory that wasn't looked up in")
+	}
+
+	// A pause ends the storm
+	if l.storm("p/global/s3/bucket", now.Add(2*lookupWindow)) {
+		t.Error("storm continued after a pause")
+	}
+}
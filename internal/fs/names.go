@@ -0,0 +1,192 @@
+package fs
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxNameLen is the longest filename most kernels and tools accept
+const maxNameLen = 255
+
+// maxAliases caps the shortened and renamed filenames remembered; the
+// oldest is dropped past it, and resolves again once its directory is
+// listed again
+var maxAliases = 50000
+
+// nameCodec maps resource names (S3 key segments, SSM parameter names) to
+// filenames and back. Characters that are invalid or surprising in
+// filenames are percent-escaped, so the mapping is reversible:
+//
+//   - control characters, '%' and ':' become %XX
+//   - a leading '-' becomes %2D so names aren't mistaken for flags
+//   - "." and ".." become %2E and %2E%2E
+//   - an empty segment (e.g. from "a//b") becomes %00
+//   - invalid UTF-8 bytes become %XX
+//
+// Names still longer than maxNameLen after escaping are shortened with a
+// hash suffix and remembered, so lookups of the short name resolve to the
+// original once its directory has been listed. Case-collision aliases
+// (see disambiguate) are remembered the same way.
+type nameCodec struct {
+	mu      sync.RWMutex
+	aliases map[string]string // shortened or renamed filename -> original name
+	order   []string          // aliases by when first remembered, oldest first
+}
+
+func newNameCodec() *nameCodec {
+	return &nameCodec{aliases: make(map[string]string)}
+}
+
+// encode returns the filename for a single resource name segment
+func (c *nameCodec) encode(name string) string {
+	encoded := escapeName(name)
+	if len(encoded) <= maxNameLen {
+		return encoded
+	}
+
+	sum := sha1.Sum([]byte(name))
+	suffix := "~" + hex.EncodeToString(sum[:8])
+	keep := maxNameLen - len(suffix)
+	// Don't cut through an escape sequence or a multi-byte rune
+	for keep > 0 && (!utf8.RuneStart(encoded[keep]) || strings.LastIndex(encoded[:keep], "%") > keep-3) {
+		keep--
+	}
+	short := encoded[:keep] + suffix
+
+	c.remember(short, name)
+	return short
+}
+
+func (c *nameCodec) remember(filename, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.aliases[filename]; !ok {
+		c.order = append(c.order, filename)
+	}
+	c.aliases[filename] = name
+	for len(c.order) > maxAliases {
+		delete(c.aliases, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// decode returns the resource name for a single filename
+func (c *nameCodec) decode(filename string) string {
+	c.mu.RLock()
+	original, ok := c.aliases[filename]
+	c.mu.RUnlock()
+	if ok {
+		return original
+	}
+	return unescapeName(filename)
+}
+
+// decodePath decodes every segment of a slash-separated filesystem path
+func (c *nameCodec) decodePath(path string) string {
+	if path == "" || !strings.ContainsAny(path, "%~") {
+		return path
+	}
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = c.decode(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// encodePath encodes every segment of a slash-separated provider path
+func (c *nameCodec) encodePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = c.encode(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func escapeName(name string) string {
+	switch name {
+	case "":
+		return "%00"
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size == 1,
+			r < 0x20, r == 0x7f, r == '%', r == ':',
+			r == '-' && i == 0:
+			fmt.Fprintf(&b, "%%%02X", name[i])
+		default:
+			b.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func unescapeName(filename string) string {
+	if filename == "%00" {
+		return ""
+	}
+	if !strings.Contains(filename, "%") {
+		return filename
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(filename); i++ {
+		if filename[i] == '%' && i+2 < len(filename) {
+			if v, err := hex.DecodeString(filename[i+1 : i+3]); err == nil {
+				b.WriteByte(v[0])
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(filename[i])
+	}
+	return b.String()
+}
+
+// disambiguate renames filenames in a single directory listing that differ
+// only by case, for clients on case-insensitive filesystems. Within each
+// colliding group the byte-wise smallest name is kept and the others get a
+// "~cN" suffix before the extension, so the result is deterministic
+// regardless of listing order. names holds the original resource names
+// parallel to filenames, and is used to remember each alias.
+func (c *nameCodec) disambiguate(filenames, names []string) {
+	groups := make(map[string][]int)
+	for i, fn := range filenames {
+		key := strings.ToLower(fn)
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, idx := range groups {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.Slice(idx, func(a, b int) bool { return filenames[idx[a]] < filenames[idx[b]] })
+		for n, i := range idx[1:] {
+			alias := caseAlias(filenames[i], n+2)
+			c.remember(alias, names[i])
+			filenames[i] = alias
+		}
+	}
+}
+
+// caseAlias inserts a "~cN" marker before the file extension
+func caseAlias(filename string, n int) string {
+	ext := path.Ext(filename)
+	if ext == filename {
+		ext = ""
+	}
+	return fmt.Sprintf("%s~c%d%s", strings.TrimSuffix(filename, ext), n, ext)
+}
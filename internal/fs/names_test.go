@@ -0,0 +1,94 @@
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameCodecRoundTrip(t *testing.T) {
+	c := newNameCodec()
+	for _, name := range []string{
+		"plain.txt",
+		"line\nbreak",
+		"2024-01-01T10:00:00.log",
+		"-rf",
+		"100%",
+		".",
+		"..",
+		"",
+		"snow☃man",
+		"bad\xffutf8",
+		"%41",
+	} {
+		encoded := c.encode(name)
+		if strings.ContainsAny(encoded, "/\n\x00") || encoded == "." || encoded == ".." {
+			t.Errorf("encode(%q) = %q is not a safe filename", name, encoded)
+		}
+		if got := c.decode(encoded); got != name {
+			t.Errorf("decode(encode(%q)) = %q", name, got)
+		}
+	}
+}
+
+func TestNameCodecPlainNamesUnchanged(t *testing.T) {
+	c := newNameCodec()
+	for _, name := range []string{"info.json", "my-bucket", "snow☃man", "a b"} {
+		if got := c.encode(name); got != name {
+			t.Errorf("encode(%q) = %q, want unchanged", name, got)
+		}
+	}
+}
+
+func TestNameCodecLongNames(t *testing.T) {
+	c := newNameCodec()
+	name := strings.Repeat("ä", 200) + ":suffix"
+
+	encoded := c.encode(name)
+	if len(encoded) > maxNameLen {
+		t.Fatalf("encoded length %d exceeds %d", len(encoded), maxNameLen)
+	}
+	if got := c.decode(encoded); got != name {
+		t.Errorf("long name did not round-trip")
+	}
+	if got := c.decodePath("dir/" + encoded); got != "dir/"+name {
+		t.Errorf("decodePath did not resolve long name")
+	}
+}
+
+func TestNameCodecDropsOldestAlias(t *testing.T) {
+	defer func(n int) { maxAliases = n }(maxAliases)
+	maxAliases = 2
+
+	c := newNameCodec()
+	first := c.encode(strings.Repeat("a", 300))
+	c.encode(strings.Repeat("b", 300))
+	c.encode(strings.Repeat("a", 300)) // already remembered
+	last := c.encode(strings.Repeat("c", 300))
+	if len(c.aliases) != 2 {
+		t.Errorf("%d aliases remembered, want 2", len(c.aliases))
+	}
+	if got := c.decode(first); got == strings.Repeat("a", 300) {
+		t.Error("oldest alias still resolves")
+	}
+	if got := c.decode(last); got != strings.Repeat("c", 300) {
+		t.Errorf("newest alias = %q", got)
+	}
+}
+
+func TestNameCodecDisambiguate(t *testing.T) {
+	c := newNameCodec()
+	names := []string{"readme.md", "README.md", "Readme.md", "other.txt"}
+	filenames := append([]string(nil), names...)
+
+	c.disambiguate(filenames, names)
+
+	want := []string{"readme~c3.md", "README.md", "Readme~c2.md", "other.txt"}
+	for i := range want {
+		if filenames[i] != want[i] {
+			t.Errorf("filenames[%d] = %q, want %q", i, filenames[i], want[i])
+		}
+		if got := c.decode(filenames[i]); got != names[i] {
+			t.Errorf("decode(%q) = %q, want %q", filenames[i], got, names[i])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+)
+
+// keptPagesMax bounds how many files' contents keptPages remembers; past
+// it, it starts over, which only costs a cache miss in the kernel
+const keptPagesMax = 10000
+
+// keptPages lets the kernel keep the page cache of read-only files between
+// opens while their content is unchanged, so tools that mmap them (less,
+// language runtimes loading data files) see stable pages instead of the
+// cache being dropped on every open. A file's pages are kept only when its
+// content is the same as at the previous open: once the result cache
+// expires and a refetch brings different content, the kernel is told to
+// drop them.
+type keptPages struct {
+	mu   sync.Mutex
+	sums map[string][sha256.Size]byte // content digest at the last open, by path
+}
+
+func newKeptPages() *keptPages {
+	return &keptPages{sums: make(map[string][sha256.Size]byte)}
+}
+
+// open returns file, flagged to keep the kernel's cached pages of name if
+// data is what it held at the previous open. changed reports that name
+// was opened before with different content, whose pages are stale.
+func (k *keptPages) open(name string, file nodefs.File, data []byte) (opened nodefs.File, changed bool) {
+	if k == nil {
+		return file, false
+	}
+	sum := sha256.Sum256(data)
+
+	k.mu.Lock()
+	prev, seen := k.sums[name]
+	if !seen && len(k.sums) >= keptPagesMax {
+		k.sums = make(map[string][sha256.Size]byte)
+	}
+	k.sums[name] = sum
+	k.mu.Unlock()
+
+	if seen && prev == sum {
+		return &nodefs.WithFlags{File: file, FuseFlags: fuse.FOPEN_KEEP_CACHE}, false
+	}
+	return file, seen
+}
+
+// forget drops what's known about name after it was written through the
+// mount, so its next open doesn't keep pages
+func (k *keptPages) forget(name string) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	delete(k.sums, name)
+	k.mu.Unlock()
+}
+
+// openReadOnly returns the file for the generated content of name, keeping
+// the kernel's pages while the content is unchanged
+func (f *SisuFS) openReadOnly(name string, file *sisuFile) nodefs.File {
+	opened, changed := f.kept.open(name, file, file.data)
+	if changed {
+		f.invalidatePages(name)
+	}
+	return opened
+}
+
+// invalidatePages tells the kernel to drop its cached pages and attributes
+// of name, including those mapped by other processes. Like notifyChanged,
+// it notifies asynchronously.
+func (f *SisuFS) invalidatePages(name string) {
+	name, ok := f.mountPath(name)
+	if f.nodeFs == nil || !ok {
+		return
+	}
+	go f.nodeFs.FileNotify(name, 0, 0)
+}
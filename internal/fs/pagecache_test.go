@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestReadOnlyFilesKeepPagesWhileUnchanged(t *testing.T) {
+	prov := &memProvider{files: map[string]string{"i-0abc/info.json": `{"State": "running"}`}}
+	f := &SisuFS{
+		providers:    map[string]provider.Provider{"prod/us-east-1/ec2": prov},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		lookups:      newLookups(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+		kept:         newKeptPages(),
+	}
+	name := "prod/us-east-1/ec2/i-0abc/info.json"
+	keeps := func() bool {
+		t.Helper()
+		file, status := f.Open(name, uint32(syscall.O_RDONLY), nil)
+		if !status.Ok() {
+			t.Fatalf("Open: %v", status)
+		}
+		wf, ok := file.(*nodefs.WithFlags)
+		return ok && wf.FuseFlags&fuse.FOPEN_KEEP_CACHE != 0
+	}
+
+	if keeps() {
+		t.Error("first open kept pages it never cached")
+	}
+	if !keeps() {
+		t.Error("second open of unchanged content dropped the pages")
+	}
+
+	prov.mu.Lock()
+	prov.files["i-0abc/info.json"] = `{"State": "stopped"}`
+	prov.mu.Unlock()
+	if keeps() {
+		t.Error("open after the content changed kept stale pages")
+	}
+	if !keeps() {
+		t.Error("pages of the new content weren't kept")
+	}
+
+	f.notifyChanged(name)
+	if keeps() {
+		t.Error("open after a write through the mount kept pages")
+	}
+}
@@ -0,0 +1,47 @@
+package fs
+
+import (
+	"strconv"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// Extended attributes of listed directories, e.g. user.sisu.page is "2" for
+// bucket/_page2, user.sisu.truncated is "true" if it ends with a _page3
+// directory and user.sisu.next_page names that directory
+const (
+	xattrPage      = "user.sisu.page"
+	xattrTruncated = "user.sisu.truncated"
+	xattrNextPage  = "user.sisu.next_page"
+)
+
+// pagesFor returns the page cursors of the provider under key. Callers
+// must hold providersMu.
+func (f *SisuFS) pagesFor(key string) *paging.Cursors {
+	c, ok := f.pages[key]
+	if !ok {
+		c = paging.NewCursors()
+		f.pages[key] = c
+	}
+	return c
+}
+
+// pageXattrs adds the paging attributes of a directory below key that has
+// been listed
+func (f *SisuFS) pageXattrs(attrs map[string][]byte, key, subpath string) {
+	f.providersMu.RLock()
+	cursors := f.pages[key]
+	f.providersMu.RUnlock()
+	if cursors == nil {
+		return
+	}
+	info, ok := cursors.Info(subpath)
+	if !ok {
+		return
+	}
+	attrs[xattrPage] = []byte(strconv.Itoa(info.Page))
+	attrs[xattrTruncated] = []byte(strconv.FormatBool(info.NextPage != ""))
+	if info.NextPage != "" {
+		attrs[xattrNextPage] = []byte(info.NextPage)
+	}
+}
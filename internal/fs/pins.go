@@ -0,0 +1,212 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// PinRefreshInterval is how often a mount refreshes pinned snapshots
+const PinRefreshInterval = 15 * time.Minute
+
+// pinsFile lists the pinned paths in the pin directory, one per line
+const pinsFile = "pins.txt"
+
+// ReadPins returns the paths pinned in dir
+func ReadPins(dir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(dir, pinsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var pins []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			pins = append(pins, line)
+		}
+	}
+	return pins, scanner.Err()
+}
+
+// writePins replaces the pins listed in dir
+func writePins(dir string, pins []string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, pin := range pins {
+		b.WriteString(pin + "\n")
+	}
+	return os.WriteFile(filepath.Join(dir, pinsFile), []byte(b.String()), 0600)
+}
+
+// snapshotFile is where the snapshot of a pinned path is kept
+func snapshotFile(dir, pin string) string {
+	return filepath.Join(dir, "snapshots", url.PathEscape(pin)+".json")
+}
+
+// pinTarget resolves a pinned path, relative to the mount root, to the key
+// of its provider and the subtree's path within it
+func (f *SisuFS) pinTarget(pin string) (key, subpath string, err error) {
+	profile, region, service, subpath, ok := f.parsePath(pin)
+	if !ok || service == "" {
+		return "", "", fmt.Errorf("%s: pin a path inside a service, e.g. prod/global/iam/policies", pin)
+	}
+	if region == "global" {
+		region = "us-east-1"
+	}
+	return profile + "/" + region + "/" + service, subpath, nil
+}
+
+// snapshotsFor returns the snapshots served for the provider under key.
+// Callers must hold providersMu.
+func (f *SisuFS) snapshotsFor(key string) *provider.Snapshots {
+	s, ok := f.snapshots[key]
+	if !ok {
+		s = &provider.Snapshots{}
+		f.snapshots[key] = s
+	}
+	return s
+}
+
+// loadPins serves the saved snapshots of every pinned path, so they're
+// available even if AWS can't be reached from the start
+func (f *SisuFS) loadPins() {
+	pins, err := ReadPins(f.config.PinDir)
+	if err != nil {
+		log.Printf("pins: %v", err)
+		return
+	}
+	for _, pin := range pins {
+		key, _, err := f.pinTarget(pin)
+		if err != nil {
+			continue
+		}
+		s, err := provider.LoadSnapshot(snapshotFile(f.config.PinDir, pin))
+		if err != nil {
+			continue
+		}
+		f.providersMu.Lock()
+		f.snapshotsFor(key).Put(s)
+		f.providersMu.Unlock()
+	}
+}
+
+// Pin takes a snapshot of path now, saves it and adds path to the pins,
+// which a mount keeps refreshed with RefreshPins
+func (f *SisuFS) Pin(ctx context.Context, path string) (*provider.Snapshot, error) {
+	path = strings.Trim(path, "/")
+	s, err := f.snapshot(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := ReadPins(f.config.PinDir)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(pins, path) {
+		if err := writePins(f.config.PinDir, append(pins, path)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Unpin removes path from the pins and deletes its snapshot
+func (f *SisuFS) Unpin(path string) error {
+	path = strings.Trim(path, "/")
+	pins, err := ReadPins(f.config.PinDir)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(pins, path)
+	if i < 0 {
+		return fmt.Errorf("%s is not pinned", path)
+	}
+	if err := writePins(f.config.PinDir, slices.Delete(pins, i, i+1)); err != nil {
+		return err
+	}
+
+	if key, subpath, err := f.pinTarget(path); err == nil {
+		f.providersMu.Lock()
+		f.snapshotsFor(key).Remove(subpath)
+		f.providersMu.Unlock()
+	}
+	if err := os.Remove(snapshotFile(f.config.PinDir, path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// snapshot takes and saves a fresh snapshot of a pinned path
+func (f *SisuFS) snapshot(ctx context.Context, pin string) (*provider.Snapshot, error) {
+	key, subpath, err := f.pinTarget(pin)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(key, "/", 3)
+	prov, err := f.getProvider(parts[0], parts[1], parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if prov == nil {
+		return nil, fmt.Errorf("%s: no such service", pin)
+	}
+
+	s, err := provider.TakeSnapshot(ctx, prov, subpath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pin, err)
+	}
+	if err := s.Save(snapshotFile(f.config.PinDir, pin)); err != nil {
+		return nil, err
+	}
+	f.providersMu.Lock()
+	f.snapshotsFor(key).Put(s)
+	f.providersMu.Unlock()
+	return s, nil
+}
+
+// RefreshPins re-takes the snapshot of every pinned path each interval
+// until ctx is done. Pins added by `sisu pin` while mounted are picked up.
+// A failed refresh keeps the previous snapshot.
+func (f *SisuFS) RefreshPins(ctx context.Context, interval time.Duration) {
+	if f.config.PinDir == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pins, err := ReadPins(f.config.PinDir)
+		if err != nil {
+			log.Printf("pins: %v", err)
+			continue
+		}
+		for _, pin := range pins {
+			if _, err := f.snapshot(ctx, pin); err != nil && Debug {
+				log.Printf("[fs] refreshing pin: %v", err)
+			}
+		}
+	}
+}
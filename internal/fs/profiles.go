@@ -0,0 +1,129 @@
+package fs
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Section prefixes of ~/.aws/config. Other named sections, such as
+// "services <name>" endpoint settings, aren't profiles.
+const (
+	profileSectionPrefix = "profile "
+	ssoSessionPrefix     = "sso-session "
+)
+
+// awsProfile is what decides whether a profile can load credentials
+type awsProfile struct {
+	sourceProfile string // source_profile, assumed from for role_arn
+	ssoSession    string // sso_session, defined in its own section
+}
+
+// loadAWSProfiles reads profile names from the shared credentials and
+// config files, honoring AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE
+func loadAWSProfiles() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []string{"default"}, nil
+	}
+	credPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credPath == "" {
+		credPath = filepath.Join(home, ".aws", "credentials")
+	}
+	configPath := os.Getenv("AWS_CONFIG_FILE")
+	if configPath == "" {
+		configPath = filepath.Join(home, ".aws", "config")
+	}
+	return awsProfiles(credPath, configPath), nil
+}
+
+// awsProfiles returns the profiles defined in the credentials and config
+// files the way the SDK reads them: "profile <name>" and "default" sections
+// of the config file, every section of the credentials file but "profile"
+// ones, and never the sso-session or services sections profiles refer to.
+// Profiles whose source_profile chain or sso_session leads nowhere are left
+// out, as loading their credentials would fail; default is always listed.
+func awsProfiles(credPath, configPath string) []string {
+	profiles := map[string]*awsProfile{"default": {}}
+	ssoSessions := make(map[string]bool)
+
+	add := func(name string, section *ini.Section) {
+		p, ok := profiles[name]
+		if !ok {
+			p = &awsProfile{}
+			profiles[name] = p
+		}
+		if k, err := section.GetKey("source_profile"); err == nil {
+			p.sourceProfile = k.String()
+		}
+		if k, err := section.GetKey("sso_session"); err == nil {
+			p.ssoSession = k.String()
+		}
+	}
+
+	if cfg, err := ini.Load(credPath); err == nil {
+		for _, section := range cfg.Sections() {
+			name := section.Name()
+			if name != ini.DefaultSection && !strings.HasPrefix(name, profileSectionPrefix) {
+				add(name, section)
+			}
+		}
+	}
+	if cfg, err := ini.Load(configPath); err == nil {
+		for _, section := range cfg.Sections() {
+			name := section.Name()
+			switch {
+			case strings.HasPrefix(name, profileSectionPrefix):
+				add(strings.TrimSpace(strings.TrimPrefix(name, profileSectionPrefix)), section)
+			case name == "default":
+				add(name, section)
+			case strings.HasPrefix(name, ssoSessionPrefix):
+				ssoSessions[strings.TrimSpace(strings.TrimPrefix(name, ssoSessionPrefix))] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(profiles))
+	for name := range profiles {
+		if name == "default" {
+			result = append(result, name)
+			continue
+		}
+		if reason := brokenProfile(name, profiles, ssoSessions); reason != "" {
+			log.Printf("Skipping AWS profile %s: %s", name, reason)
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// brokenProfile explains why the credentials of profile name can't load,
+// following its source_profile chain, or returns "" if nothing is missing
+func brokenProfile(name string, profiles map[string]*awsProfile, ssoSessions map[string]bool) string {
+	seen := make(map[string]bool)
+	for current := name; ; {
+		p, ok := profiles[current]
+		if !ok {
+			return "source_profile " + current + " is not defined"
+		}
+		if p.ssoSession != "" && !ssoSessions[p.ssoSession] {
+			return "sso-session " + p.ssoSession + " is not defined"
+		}
+		// A profile may be its own source, using its static keys to
+		// assume its role
+		if p.sourceProfile == "" || p.sourceProfile == current {
+			return ""
+		}
+		if seen[current] {
+			return "source_profile chain loops back to " + current
+		}
+		seen[current] = true
+		current = p.sourceProfile
+	}
+}
@@ -0,0 +1,201 @@
+package fs
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+)
+
+// ArmFile is the control file arming deletes in protected profiles. Writing
+// "<profile> [duration]" allows deletes there for the duration (default
+// DefaultArmDuration, 0 disarms); reading it lists the armed profiles.
+const ArmFile = "arm"
+
+// DefaultArmDuration is how long a profile stays armed when no duration is given
+const DefaultArmDuration = 5 * time.Minute
+
+// protection refuses deletes in protected profiles unless they were armed
+type protection struct {
+	globs []string // protected profile names or globs
+
+	mu    sync.Mutex
+	armed map[string]time.Time // deletes allowed until, by profile
+}
+
+func newProtection(globs []string) *protection {
+	return &protection{globs: globs, armed: make(map[string]time.Time)}
+}
+
+// protected reports whether profile matches a protected name or glob
+func (p *protection) protected(profile string) bool {
+	for _, g := range p.globs {
+		if ok, _ := filepath.Match(g, profile); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowDelete reports whether deletes in profile may go ahead
+func (p *protection) allowDelete(profile string, now time.Time) bool {
+	if !p.protected(profile) {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return now.Before(p.armed[profile])
+}
+
+// arm allows deletes in profile for d; d <= 0 disarms it
+func (p *protection) arm(profile string, d time.Duration, now time.Time) error {
+	if !p.protected(profile) {
+		return fmt.Errorf("profile %s is not protected", profile)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d <= 0 {
+		delete(p.armed, profile)
+		return nil
+	}
+	p.armed[profile] = now.Add(d)
+	return nil
+}
+
+// apply arms the profiles in lines of "<profile> [duration]"
+func (p *protection) apply(data []byte, now time.Time) error {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return fmt.Errorf("expected <profile> [duration], got %q", line)
+		}
+		d := DefaultArmDuration
+		if len(fields) == 2 {
+			var err error
+			if d, err = time.ParseDuration(fields[1]); err != nil {
+				return err
+			}
+		}
+		if err := p.arm(fields[0], d, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// status lists the armed profiles and when they are disarmed again
+func (p *protection) status(now time.Time) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	profiles := make([]string, 0, len(p.armed))
+	for profile, until := range p.armed {
+		if now.Before(until) {
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Strings(profiles)
+	var b strings.Builder
+	for _, profile := range profiles {
+		fmt.Fprintf(&b, "%s\t%s\n", profile, p.armed[profile].UTC().Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
+
+// load arms the profiles in lines of "<profile>\t<until>", as status
+// lists them. Profiles that aren't protected here are skipped.
+func (p *protection) load(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("expected <profile> <until>, got %q", line)
+		}
+		until, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return err
+		}
+		if p.protected(fields[0]) {
+			p.armed[fields[0]] = until
+		}
+	}
+	return nil
+}
+
+// ArmFrom arms protected profiles as a running mount's .sisu/arm lists
+// them, so commands deleting without the mount honour sisu unlock
+func (f *SisuFS) ArmFrom(status []byte) error {
+	return f.protection.load(status)
+}
+
+// checkDelete returns EPERM for a delete in a protected profile that
+// isn't armed
+func (f *SisuFS) checkDelete(profile string) fuse.Status {
+	if f.protection.allowDelete(profile, time.Now()) {
+		return fuse.OK
+	}
+	log.Printf("[fs] refusing delete in protected profile %s; run sisu unlock %s first", profile, profile)
+	return fuse.EPERM
+}
+
+// armFile buffers what is written to .sisu/arm and applies it on close
+type armFile struct {
+	nodefs.File
+	fs   *SisuFS
+	mu   sync.Mutex
+	data []byte
+}
+
+func (f *armFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := off + int64(len(data))
+	if end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	copy(f.data[off:], data)
+	return uint32(len(data)), fuse.OK
+}
+
+func (f *armFile) Flush() fuse.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.data) == 0 {
+		return fuse.OK
+	}
+	err := f.fs.protection.apply(f.data, time.Now())
+	f.data = nil
+	if err != nil {
+		log.Printf("[fs] %s: %v", ArmFile, err)
+		return fuse.EINVAL
+	}
+	return fuse.OK
+}
+
+func (f *armFile) GetAttr(out *fuse.Attr) fuse.Status {
+	*out = *f.fs.newAttr(fuse.S_IFREG|0644, 0, time.Now())
+	return fuse.OK
+}
+
+func (f *armFile) Truncate(size uint64) fuse.Status {
+	if size != 0 {
+		return fuse.Status(syscall.EINVAL)
+	}
+	f.mu.Lock()
+	f.data = nil
+	f.mu.Unlock()
+	return fuse.OK
+}
@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestProtection(t *testing.T) {
+	p := newProtection([]string{"prod", "*-prod"})
+	now := time.Now()
+
+	if !p.allowDelete("dev", now) {
+		t.Error("unprotected profile: delete refused")
+	}
+	if p.allowDelete("eu-prod", now) {
+		t.Error("protected profile: delete allowed before arming")
+	}
+
+	if err := p.apply([]byte("eu-prod 10m\nprod\n"), now); err != nil {
+		t.Fatal(err)
+	}
+	if !p.allowDelete("eu-prod", now.Add(9*time.Minute)) || p.allowDelete("eu-prod", now.Add(11*time.Minute)) {
+		t.Error("eu-prod should be armed for 10 minutes")
+	}
+	if !p.allowDelete("prod", now.Add(DefaultArmDuration-time.Second)) {
+		t.Error("prod should be armed for the default duration")
+	}
+
+	if err := p.apply([]byte("prod 0s"), now); err != nil {
+		t.Fatal(err)
+	}
+	if p.allowDelete("prod", now) {
+		t.Error("prod still armed after disarming")
+	}
+
+	for _, bad := range []string{"dev 5m", "prod soon", "prod 5m extra"} {
+		if err := p.apply([]byte(bad), now); err == nil {
+			t.Errorf("%q: want an error", bad)
+		}
+	}
+}
+
+func TestArmFile(t *testing.T) {
+	f := &SisuFS{protection: newProtection([]string{"prod"})}
+	if status := f.checkDelete("prod"); status != fuse.EPERM {
+		t.Fatalf("checkDelete before arming = %v, want EPERM", status)
+	}
+
+	file, status := f.controlOpen(ControlDir+"/"+ArmFile, uint32(syscall.O_WRONLY|syscall.O_TRUNC))
+	if !status.Ok() {
+		t.Fatalf("open for writing: %v", status)
+	}
+	file.Write([]byte("prod 1m\n"), 0)
+	if status := file.Flush(); !status.Ok() {
+		t.Fatalf("Flush = %v", status)
+	}
+	if status := f.checkDelete("prod"); !status.Ok() {
+		t.Errorf("checkDelete after arming = %v", status)
+	}
+
+	data, _ := f.controlData(ControlDir + "/" + ArmFile)
+	if !strings.HasPrefix(string(data), "prod\t") {
+		t.Errorf("arm file = %q", data)
+	}
+
+	if _, status := f.controlOpen(ControlDir+"/stats.json", uint32(syscall.O_WRONLY)); status != fuse.EACCES {
+		t.Errorf("writing stats.json: %v, want EACCES", status)
+	}
+}
+
+func TestRemoveAfterUnlock(t *testing.T) {
+	// The mount, unlocked with sisu unlock
+	mount := &SisuFS{protection: newProtection([]string{"prod"})}
+	mount.protection.apply([]byte("prod 1m"), time.Now())
+	armed, _ := mount.controlData(ControlDir + "/" + ArmFile)
+
+	// bulk rm and sync --delete delete through a tree of their own
+	prov := &memProvider{files: map[string]string{"bucket/www/a:b.txt": "a"}}
+	tree := &SisuFS{
+		config:     Config{Write: map[string]config.WriteMode{"s3": "true"}},
+		providers:  map[string]provider.Provider{"prod/us-east-1/s3": prov},
+		names:      newNameCodec(),
+		dirTimes:   newDirTimes(),
+		protection: newProtection([]string{"prod"}),
+	}
+	if err := tree.RemoveKey("prod/global/s3/bucket/www", "a:b.txt"); !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("RemoveKey before unlocking = %v, want EPERM", err)
+	}
+	if err := tree.ArmFrom(armed); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.RemoveKey("prod/global/s3/bucket/www", "a:b.txt"); err != nil {
+		t.Fatalf("RemoveKey after unlocking = %v", err)
+	}
+	if len(prov.files) != 0 {
+		t.Errorf("files = %v, want the key deleted", prov.files)
+	}
+
+	if err := tree.ArmFrom([]byte("prod soon\n")); err == nil {
+		t.Error("ArmFrom of a malformed line: want an error")
+	}
+}
@@ -0,0 +1,84 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// rangeProvider serves a single large file and records requested ranges
+type rangeProvider struct {
+	provider.ReadOnlyProvider
+	data   []byte
+	ranges [][2]int64
+}
+
+func (p *rangeProvider) Name() string { return "range" }
+
+func (p *rangeProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	return nil, nil
+}
+
+func (p *rangeProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	p.ranges = append(p.ranges, [2]int64{0, int64(len(p.data))})
+	return p.data, nil
+}
+
+func (p *rangeProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	return &provider.Entry{Name: path, Size: int64(len(p.data))}, nil
+}
+
+func (p *rangeProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	p.ranges = append(p.ranges, [2]int64{off, length})
+	end := min(off+length, int64(len(p.data)))
+	return p.data[off:end], nil
+}
+
+func readAt(t *testing.T, f *rangeFile, off int64, n int) []byte {
+	t.Helper()
+	res, status := f.Read(make([]byte, n), off)
+	if status != fuse.OK {
+		t.Fatalf("Read at %d: %v", off, status)
+	}
+	data, _ := res.Bytes(make([]byte, n))
+	return data
+}
+
+func TestRangeFileFetchesOnlyWhatIsRead(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1<<20) // 10 MB
+	prov := &rangeProvider{data: data}
+	f := newRangeFile(prov, "big", &fuse.Attr{Size: uint64(len(data))})
+
+	if got := readAt(t, f, 0, 100); !bytes.Equal(got, data[:100]) {
+		t.Fatalf("head = %q", got)
+	}
+	if len(prov.ranges) != 1 || prov.ranges[0] != [2]int64{0, rangeBlockMin} {
+		t.Fatalf("ranges = %v, want one block at 0", prov.ranges)
+	}
+
+	// Reads within the fetched block are served locally
+	readAt(t, f, 4096, 4096)
+	if len(prov.ranges) != 1 {
+		t.Errorf("read inside block fetched again: %v", prov.ranges)
+	}
+
+	// Sequential reads past the block fetch a bigger one
+	readAt(t, f, rangeBlockMin, 4096)
+	if last := prov.ranges[len(prov.ranges)-1]; last != [2]int64{rangeBlockMin, 2 * rangeBlockMin} {
+		t.Errorf("sequential fetch = %v, want doubled block", last)
+	}
+
+	// Reading the tail returns only what's left
+	tail := readAt(t, f, int64(len(data))-10, 100)
+	if !bytes.Equal(tail, data[len(data)-10:]) {
+		t.Errorf("tail = %q", tail)
+	}
+	for _, r := range prov.ranges {
+		if r[0] == 0 && r[1] == int64(len(data)) {
+			t.Errorf("whole file was downloaded")
+		}
+	}
+}
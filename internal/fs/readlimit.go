@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"fmt"
+	"log"
+	"syscall"
+)
+
+// DefaultMaxReadSize is the largest file read into memory whole: opened
+// for editing, renamed, or copied with sisu bulk cp. Bigger files can only
+// be read in ranges (cat, head, cp from the mount), which keeps a stray
+// edit of a multi-GB object from exhausting memory.
+const DefaultMaxReadSize = 100 << 20
+
+// TooLargeError is returned for files over the limit for whole reads
+type TooLargeError struct {
+	Name  string
+	Size  int64
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s is %s, over the %s limit for files read whole (max_read_mb in the config); copy it with sisu bulk cp --no-limit or read it with cat",
+		e.Name, FormatSize(e.Size), FormatSize(e.Limit))
+}
+
+// Unwrap lets errors.Is match EFBIG ("File too large")
+func (e *TooLargeError) Unwrap() error {
+	return syscall.EFBIG
+}
+
+// checkReadSize returns a TooLargeError, also logged, if a file of size
+// bytes may not be read whole
+func (f *SisuFS) checkReadSize(name string, size int64) error {
+	limit := f.config.MaxReadSize
+	if limit == 0 {
+		limit = DefaultMaxReadSize
+	}
+	if limit < 0 || size <= limit {
+		return nil
+	}
+	err := &TooLargeError{Name: name, Size: size, Limit: limit}
+	log.Printf("[fs] %v", err)
+	return err
+}
+
+// FormatSize formats a byte count with a binary unit, e.g. "1.5 GB"
+func FormatSize(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
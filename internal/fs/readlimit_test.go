@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestReadFileLimit(t *testing.T) {
+	prov := &rangeProvider{data: make([]byte, 2048)}
+	f := &SisuFS{
+		config:    Config{MaxReadSize: 1024},
+		providers: map[string]provider.Provider{"default/us-east-1/s3": prov},
+		names:     newNameCodec(),
+		lookups:   newLookups(),
+	}
+
+	_, err := f.ReadFile("default/global/s3/bucket/big.bin", 0)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || !errors.Is(err, syscall.EFBIG) {
+		t.Fatalf("ReadFile = %v, want a TooLargeError", err)
+	}
+	if want := "default/global/s3/bucket/big.bin is 2.0 KB, over the 1.0 KB limit"; err.Error()[:len(want)] != want {
+		t.Errorf("error = %q", err)
+	}
+
+	// Reading only the start of a file isn't limited
+	if data, err := f.ReadFile("default/global/s3/bucket/big.bin", 100); err != nil || len(data) != 100 {
+		t.Errorf("ReadFile with limit = %d bytes, %v", len(data), err)
+	}
+
+	f.config.MaxReadSize = -1
+	if data, err := f.ReadFile("default/global/s3/bucket/big.bin", 0); err != nil || len(data) != 2048 {
+		t.Errorf("ReadFile without limit = %d bytes, %v", len(data), err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:         "512 B",
+		1536:        "1.5 KB",
+		100 << 20:   "100.0 MB",
+		3 << 30 / 2: "1.5 GB",
+	} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// RefreshFile is the control file refreshing a subtree on demand. A path in
+// the mount written to it has everything cached below it dropped and
+// listed again, and reading the same open file back returns what changed
+// as JSON, for scripts that poll for a new object or parameter.
+const RefreshFile = "refresh"
+
+// RefreshChange is an entry found changed by a refresh
+type RefreshChange struct {
+	Kind provider.ChangeKind `json:"kind"`
+	Path string              `json:"path"` // in the mount
+}
+
+// refresher is implemented by the cached providers
+type refresher interface {
+	Refresh(ctx context.Context, dir string) ([]provider.Change, error)
+}
+
+// Refresh drops the cached state of the subtree at path, a path in the
+// mount inside a service, lists it again and returns the entries that
+// changed since they were last listed. Directories never listed before
+// have nothing to compare with, so the first refresh of a path only
+// records its listing.
+func (f *SisuFS) Refresh(ctx context.Context, path string) ([]RefreshChange, error) {
+	path = strings.Trim(path, "/")
+	if f.config.Root != "" {
+		path = joinPath(f.config.Root, path)
+	}
+	profile, region, service, subpath, ok := f.parsePath(path)
+	if !ok || service == "" {
+		return nil, fmt.Errorf("%s: refresh a path inside a service, e.g. prod/us-east-1/ssm/app: %w", path, os.ErrInvalid)
+	}
+	if region == "global" {
+		region = "us-east-1"
+	}
+	prov, err := f.getProvider(profile, region, service)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := prov.(refresher)
+	if !ok {
+		return nil, fmt.Errorf("%s: no such service: %w", path, os.ErrInvalid)
+	}
+	changes, err := r.Refresh(ctx, subpath)
+
+	dir := f.serviceDir(profile+"/"+region+"/"+service, service)
+	out := make([]RefreshChange, 0, len(changes))
+	for _, c := range changes {
+		name := joinPath(dir, f.names.encodePath(c.Path))
+		f.notifyChanged(name)
+		if name, ok := f.mountPath(name); ok {
+			out = append(out, RefreshChange{Kind: c.Kind, Path: name})
+		}
+	}
+	return out, err
+}
+
+// refreshFile takes the path written to .sisu/refresh and serves the
+// result of refreshing it to reads of the same open file
+type refreshFile struct {
+	nodefs.File
+	fs *SisuFS
+
+	mu      sync.Mutex
+	request []byte
+	result  []byte
+}
+
+func newRefreshFile(fs *SisuFS) nodefs.File {
+	// Direct I/O, as the kernel would otherwise skip reading a file whose
+	// size was 0 when opened
+	return &nodefs.WithFlags{
+		File:      &refreshFile{File: nodefs.NewDefaultFile(), fs: fs},
+		FuseFlags: fuse.FOPEN_DIRECT_IO,
+	}
+}
+
+func (f *refreshFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := off + int64(len(data))
+	if end > int64(len(f.request)) {
+		f.request = append(f.request, make([]byte, end-int64(len(f.request)))...)
+	}
+	copy(f.request[off:], data)
+	f.result = nil
+	return uint32(len(data)), fuse.OK
+}
+
+func (f *refreshFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.result == nil {
+		path := string(bytes.TrimSpace(f.request))
+		if path == "" {
+			return fuse.ReadResultData(nil), fuse.OK
+		}
+		changes, err := f.fs.Refresh(context.Background(), path)
+		if err != nil {
+			log.Printf("[fs] %s %s: %v", RefreshFile, path, err)
+			return nil, errStatus(err, fuse.EIO)
+		}
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return nil, fuse.EIO
+		}
+		f.result = append(data, '\n')
+	}
+	if off >= int64(len(f.result)) {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+	end := min(off+int64(len(dest)), int64(len(f.result)))
+	return fuse.ReadResultData(f.result[off:end]), fuse.OK
+}
+
+func (f *refreshFile) GetAttr(out *fuse.Attr) fuse.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*out = *f.fs.newAttr(fuse.S_IFREG|0644, int64(len(f.result)), time.Now())
+	return fuse.OK
+}
+
+func (f *refreshFile) Truncate(size uint64) fuse.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.request = nil
+	f.result = nil
+	return fuse.OK
+}
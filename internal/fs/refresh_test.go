@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// paramsProvider lists a fixed set of names in every directory
+type paramsProvider struct {
+	provider.ReadOnlyProvider
+	names []string
+}
+
+func (p *paramsProvider) Name() string { return "ssm" }
+
+func (p *paramsProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	var entries []provider.Entry
+	for _, name := range p.names {
+		entries = append(entries, provider.Entry{Name: name})
+	}
+	return entries, nil
+}
+
+func (p *paramsProvider) Read(ctx context.Context, path string) ([]byte, error) { return nil, nil }
+
+func (p *paramsProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	return &provider.Entry{Name: path}, nil
+}
+
+func TestRefreshFileReportsChanges(t *testing.T) {
+	params := &paramsProvider{names: []string{"db-url"}}
+	f := &SisuFS{
+		providers: map[string]provider.Provider{
+			"prod/us-east-1/ssm": provider.Cached(params, provider.DefaultCachePolicy).Observe(func(provider.Change) {}),
+		},
+		names:    newNameCodec(),
+		dirTimes: newDirTimes(),
+	}
+
+	refresh := func() []RefreshChange {
+		t.Helper()
+		file := newRefreshFile(f).(*nodefs.WithFlags)
+		if file.FuseFlags&fuse.FOPEN_DIRECT_IO == 0 {
+			t.Fatal("refresh file opened without direct I/O")
+		}
+		file.Write([]byte("prod/us-east-1/ssm/app\n"), 0)
+		res, status := file.Read(make([]byte, 4096), 0)
+		if !status.Ok() {
+			t.Fatalf("Read = %v", status)
+		}
+		data, _ := res.Bytes(nil)
+		var changes []RefreshChange
+		if err := json.Unmarshal(data, &changes); err != nil {
+			t.Fatalf("result %q: %v", data, err)
+		}
+		return changes
+	}
+
+	if changes := refresh(); len(changes) != 0 {
+		t.Errorf("first refresh = %v, want nothing to compare with", changes)
+	}
+	params.names = append(params.names, "api-key")
+	changes := refresh()
+	if len(changes) != 1 || changes[0] != (RefreshChange{Kind: provider.ChangeAdded, Path: "prod/us-east-1/ssm/app/api-key"}) {
+		t.Errorf("second refresh = %v, want api-key added", changes)
+	}
+
+	if _, err := f.Refresh(context.Background(), "prod/us-east-1"); err == nil {
+		t.Error("refreshing above a service succeeded")
+	}
+}
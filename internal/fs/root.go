@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+)
+
+// rootedFS serves the subtree at Config.Root as the whole mount, e.g. only
+// prod/eu-west-1. Names from the kernel are relative to the mount and are
+// translated to paths in the full tree; the control directory stays at the
+// mount root.
+type rootedFS struct {
+	*SisuFS
+	root string
+}
+
+// fullPath returns the path in the full tree of a name in the mount
+func (r *rootedFS) fullPath(name string) string {
+	if isControlPath(name) {
+		return name
+	}
+	if name == "" {
+		return r.root
+	}
+	return r.root + "/" + name
+}
+
+// mountPath returns the name in the mount of a path in the full tree, or
+// false if the path is outside the mounted subtree
+func (f *SisuFS) mountPath(path string) (string, bool) {
+	root := f.config.Root
+	switch {
+	case root == "":
+		return path, true
+	case path == root:
+		return "", true
+	case strings.HasPrefix(path, root+"/"):
+		return path[len(root)+1:], true
+	}
+	return "", false
+}
+
+// checkRoot verifies Config.Root names a directory
+func (f *SisuFS) checkRoot() error {
+	attr, status := f.GetAttr(f.config.Root, nil)
+	if !status.Ok() || !attr.IsDir() {
+		return fmt.Errorf("root %s is not a directory in the mount, expected e.g. <profile>/<region>", f.config.Root)
+	}
+	return nil
+}
+
+func (r *rootedFS) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Status) {
+	return r.SisuFS.GetAttr(r.fullPath(name), ctx)
+}
+
+func (r *rootedFS) Readlink(name string, ctx *fuse.Context) (string, fuse.Status) {
+	return r.SisuFS.Readlink(r.fullPath(name), ctx)
+}
+
+func (r *rootedFS) GetXAttr(name string, attribute string, ctx *fuse.Context) ([]byte, fuse.Status) {
+	return r.SisuFS.GetXAttr(r.fullPath(name), attribute, ctx)
+}
+
+func (r *rootedFS) ListXAttr(name string, ctx *fuse.Context) ([]string, fuse.Status) {
+	return r.SisuFS.ListXAttr(r.fullPath(name), ctx)
+}
+
+func (r *rootedFS) Access(name string, mode uint32, ctx *fuse.Context) fuse.Status {
+	return r.SisuFS.Access(r.fullPath(name), mode, ctx)
+}
+
+func (r *rootedFS) Mkdir(name string, mode uint32, ctx *fuse.Context) fuse.Status {
+	return r.SisuFS.Mkdir(r.fullPath(name), mode, ctx)
+}
+
+func (r *rootedFS) Unlink(name string, ctx *fuse.Context) fuse.Status {
+	return r.SisuFS.Unlink(r.fullPath(name), ctx)
+}
+
+func (r *rootedFS) Rmdir(name string, ctx *fuse.Context) fuse.Status {
+	return r.SisuFS.Rmdir(r.fullPath(name), ctx)
+}
+
+func (r *rootedFS) Rename(oldName string, newName string, ctx *fuse.Context) fuse.Status {
+	return r.SisuFS.Rename(r.fullPath(oldName), r.fullPath(newName), ctx)
+}
+
+func (r *rootedFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	entries, status := r.SisuFS.OpenDir(r.fullPath(name), ctx)
+	if name == "" && status.Ok() {
+		entries = append(entries,
+			fuse.DirEntry{Name: ControlDir, Mode: fuse.S_IFDIR | 0555},
+			fuse.DirEntry{Name: HelpDir, Mode: fuse.S_IFDIR | 0555})
+	}
+	return entries, status
+}
+
+func (r *rootedFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	return r.SisuFS.Open(r.fullPath(name), flags, ctx)
+}
+
+func (r *rootedFS) Create(name string, flags uint32, mode uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
+	return r.SisuFS.Create(r.fullPath(name), flags, mode, ctx)
+}
+
+// mountedFS returns the filesystem served to the kernel
+func (f *SisuFS) mountedFS() pathfs.FileSystem {
+	if f.config.Root == "" {
+		return f
+	}
+	return &rootedFS{SisuFS: f, root: f.config.Root}
+}
@@ -0,0 +1,49 @@
+package fs
+
+import "testing"
+
+func TestRootedPaths(t *testing.T) {
+	f := &SisuFS{config: Config{Root: "prod/eu-west-1"}}
+	r := f.mountedFS().(*rootedFS)
+
+	for name, want := range map[string]string{
+		"":                 "prod/eu-west-1",
+		"ssm/app":          "prod/eu-west-1/ssm/app",
+		ControlDir:         ControlDir,
+		".sisu/stats.json": ".sisu/stats.json",
+		"help/s3.txt":      "help/s3.txt",
+	} {
+		if got := r.fullPath(name); got != want {
+			t.Errorf("fullPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"prod/eu-west-1":         "",
+		"prod/eu-west-1/ssm/app": "ssm/app",
+	} {
+		if got, ok := f.mountPath(path); !ok || got != want {
+			t.Errorf("mountPath(%q) = %q, %v; want %q", path, got, ok, want)
+		}
+	}
+	for _, path := range []string{"prod/us-east-1/ssm", "prod/eu-west-10", "staging"} {
+		if _, ok := f.mountPath(path); ok {
+			t.Errorf("mountPath(%q) is inside the root", path)
+		}
+	}
+}
+
+func TestRootedRmdir(t *testing.T) {
+	f := &SisuFS{
+		config:      Config{Root: "prod/eu-west-1"},
+		names:       newNameCodec(),
+		protection:  newProtection(nil),
+		virtualDirs: map[string]bool{"prod/eu-west-1/s3/bucket/new": true},
+	}
+	if status := f.mountedFS().Rmdir("s3/bucket/new", nil); !status.Ok() {
+		t.Fatalf("Rmdir = %v", status)
+	}
+	if len(f.virtualDirs) != 0 {
+		t.Errorf("virtual directories = %v, want the removed one gone", f.virtualDirs)
+	}
+}
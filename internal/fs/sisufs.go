@@ -1,21 +1,26 @@
 package fs
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
 	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/index"
+	"github.com/semonte/sisu/internal/paging"
 	"github.com/semonte/sisu/internal/provider"
-	"gopkg.in/ini.v1"
 )
 
 // Debug controls whether filesystem operations are logged
@@ -23,26 +28,55 @@ var Debug bool
 
 // Config holds configuration for the filesystem
 type Config struct {
-	Profile  string
-	Region   string
-	Regions  []string // regions to show
+	Profile         string
+	Region          string
+	Regions         []string                     // regions to show
+	Timeouts        provider.Timeouts            // per-operation deadlines for all services
+	ServiceTimeouts map[string]provider.Timeouts // per-service overrides of Timeouts
+	RateLimit       float64                      // max API calls per second per profile and service (0 = unlimited)
+	Writable        config.PatternList           // if set, only matching paths are writable
+	Write           map[string]config.WriteMode  // per-service write toggles and scopes
+	PinDir          string                       // pinned paths and their snapshots ("" = no pinning)
+	Root            string                       // subtree served as the mount root, e.g. "prod/eu-west-1" ("" = everything)
+	Index           *index.Index                 // searched by .sisu/search (nil = no search)
+	CaseInsensitive bool                         // rename entries whose names differ only by case
+	Cache           *provider.CachePolicy        // result caching policy (nil = provider.DefaultCachePolicy)
+	Record          *provider.SessionRecorder    // if set, every provider call is recorded
+	Replay          *provider.Session            // if set, providers are served from this recording instead of AWS
+	Endpoints       []config.Endpoint            // JSON REST APIs mounted as extra global services
+	GCPProjects     []string                     // Google Cloud projects mounted under @gcp/
+	AzureSubs       []string                     // Azure subscriptions mounted under @azure/
+	HideDenied      bool                         // omit services whose listing was denied
+	ChangeJournal   string                       // file observed changes are appended to ("" = only <profile>/changes.log)
+	Hooks           []config.Hook                // run after each successful write or delete
+	Protected       []string                     // profile names or globs where deletes must be armed first
+	MaxReadSize     int64                        // largest file read whole into memory (0 = DefaultMaxReadSize, < 0 = no limit)
+	Render          map[string]provider.Format   // format generated .json documents are listed in, by service, <service>/<dir> or "*"
+	Profiles        []string                     // profiles listed besides those in ~/.aws, e.g. with sisu-configured credentials
+	RoundTrip       bool                         // canonicalize generated .json documents so reads and writes match byte for byte
+	WarmUp          config.WarmUp                // services listed in the background after mounting
+	Trace           *provider.Tracer             // if set, every provider call that misses the cache is traced
+	DirInfo         bool                         // serve a DirInfoFile summary in every service directory
+	Redact          bool                         // mask secrets and access keys in what is read, and mount read-only
 }
 
 // Global services that don't need a region
 var globalServices = map[string]bool{
-	"iam": true,
-	"s3":  true,
+	"access-analyzer": true,
+	"iam":             true,
+	"identity-center": true,
+	"s3":              true,
 }
 
-// Regional services
-var regionalServices = []string{"ssm", "vpc", "lambda", "ec2"}
-
-// Writable services (support write/delete)
-var writableServices = map[string]bool{
-	"s3":  true,
-	"ssm": true,
+// IsGlobalService reports whether service is listed under <profile>/global
+// rather than in each region
+func IsGlobalService(service string) bool {
+	return globalServices[service]
 }
 
+// Regional services
+var regionalServices = []string{"ssm", "vpc", "lambda", "ec2", "dynamodb", "cloudwatch", "sqs", "kinesis", "apprunner", "lightsail", "batch", "emr", "mwaa", "dms", "datasync", "athena", "topology", "elb"}
+
 // Default regions to show
 var defaultRegions = []string{"us-east-1", "us-west-2", "eu-west-1", "eu-central-1", "ap-northeast-1"}
 
@@ -50,12 +84,39 @@ var defaultRegions = []string{"us-east-1", "us-west-2", "eu-west-1", "eu-central
 type SisuFS struct {
 	pathfs.FileSystem
 	config       Config
-	profiles     []string                          // available AWS profiles
-	providers    map[string]provider.Provider      // cache: "profile/region/service" -> provider
+	nodeFs       *pathfs.PathNodeFs           // set on mount; used to push invalidations to the kernel
+	profiles     []string                     // available AWS profiles
+	providers    map[string]provider.Provider // cache: "profile/region/service@identity" -> provider, see providerKey
 	providersMu  sync.RWMutex
+	creating     map[string]*sync.Mutex       // held while the provider under a key is created
+	metrics      map[string]*provider.Metrics // per-service call metrics
+	backoffs     map[string]*provider.Backoff // cache TTL backoff while throttled, by "profile/service"
+	pools        map[string]*provider.Pool    // calls in flight per profile
+	limiters     map[string]*provider.Limiter // call rate limits, by "profile/service"
+	identities   *identities                  // identities of the AWS profiles, nil in replays
 	pendingFiles map[string]*writeableSisuFile
 	virtualDirs  map[string]bool
 	mu           sync.RWMutex
+	names        *nameCodec                     // maps resource names to safe filenames and back
+	dirTimes     *dirTimes                      // newest known change below each directory
+	owner        fuse.Owner                     // all entries are owned by the mounting user
+	mountTime    time.Time                      // reported for synthetic directories without a real mtime
+	clouds       map[string]cloud               // non-AWS clouds mounted next to the AWS profiles
+	denied       map[string]time.Time           // when the listing of "profile/region/service" keys was last denied
+	snapshots    map[string]*provider.Snapshots // pinned snapshots by provider key, guarded by providersMu
+	pages        map[string]*paging.Cursors     // page cursors by provider key, guarded by providersMu
+	lookups      *lookups                       // recent lookups per directory, to spot lookup storms
+	dirInfos     *dirInfos                      // DirInfoFile summaries, kept with their listing
+	changes      *changeLog                     // changes observed in refetched listings
+	hooks        *hooks                         // nil if no hooks are configured
+	protection   *protection                    // deletes allowed in protected profiles
+	staging      *staging                       // files staged in each service's StagingDir
+	instance     *thisInstance                  // the EC2 instance sisu runs on, nil in replays
+	locks        *fileLocks                     // advisory flock and fcntl locks taken in the mount
+	kept         *keptPages                     // content of read-only files whose kernel pages are kept
+	warmUp       warmUp                         // progress of WarmUp
+	duplicates   duplicatesReport               // the last DuplicatesFile generated
+	lastActivity atomic.Int64                   // unix nanoseconds of the last kernel request, see activityFS
 }
 
 // NewSisuFS creates a new SisuFS instance
@@ -64,127 +125,534 @@ func NewSisuFS(cfg Config) (*SisuFS, error) {
 		FileSystem:   pathfs.NewDefaultFileSystem(),
 		config:       cfg,
 		providers:    make(map[string]provider.Provider),
+		creating:     make(map[string]*sync.Mutex),
+		metrics:      make(map[string]*provider.Metrics),
+		backoffs:     make(map[string]*provider.Backoff),
+		pools:        make(map[string]*provider.Pool),
+		limiters:     make(map[string]*provider.Limiter),
 		pendingFiles: make(map[string]*writeableSisuFile),
 		virtualDirs:  make(map[string]bool),
+		denied:       make(map[string]time.Time),
+		snapshots:    make(map[string]*provider.Snapshots),
+		pages:        make(map[string]*paging.Cursors),
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		locks:        newFileLocks(),
+		kept:         newKeptPages(),
+		lookups:      newLookups(),
+		dirInfos:     newDirInfos(),
+		changes:      newChangeLog(cfg.ChangeJournal),
+		hooks:        newHooks(cfg.Hooks),
+		protection:   newProtection(cfg.Protected),
+		staging:      newStaging(),
+		owner:        fuse.Owner{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())},
+		mountTime:    time.Now(),
+	}
+
+	for _, ep := range cfg.Endpoints {
+		if globalServices[ep.Name] || isRegionalService(ep.Name) {
+			return nil, fmt.Errorf("endpoint %s: name is taken by a built-in service", ep.Name)
+		}
+	}
+
+	if cfg.Replay != nil {
+		fs.profiles = cfg.Replay.Profiles()
+		if len(cfg.Regions) == 0 {
+			fs.config.Regions = cfg.Replay.Regions()
+		}
+		return fs, nil
 	}
 
 	if cfg.Regions == nil || len(cfg.Regions) == 0 {
 		fs.config.Regions = defaultRegions
 	}
+	fs.clouds = newClouds(cfg)
+	fs.identities = newIdentities(provider.CallerIdentity)
+	if fs.hooks != nil {
+		fs.hooks.identities = fs.identities
+	}
+	fs.instance = lookupThisInstance(provider.CurrentInstance)
 
 	// Load profiles from AWS credentials/config
 	profiles, err := loadAWSProfiles()
 	if err != nil {
 		return nil, err
 	}
-	fs.profiles = profiles
-
-	return fs, nil
-}
-
-// loadAWSProfiles reads profile names from ~/.aws/credentials and ~/.aws/config
-func loadAWSProfiles() ([]string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return []string{"default"}, nil
-	}
-
-	profiles := make(map[string]bool)
-	profiles["default"] = true
-
-	// Read credentials file
-	credPath := filepath.Join(home, ".aws", "credentials")
-	if cfg, err := ini.Load(credPath); err == nil {
-		for _, section := range cfg.Sections() {
-			name := section.Name()
-			if name != "DEFAULT" {
-				profiles[name] = true
-			}
+	for _, p := range cfg.Profiles {
+		if !slices.Contains(profiles, p) {
+			profiles = append(profiles, p)
 		}
 	}
-
-	// Read config file
-	configPath := filepath.Join(home, ".aws", "config")
-	if cfg, err := ini.Load(configPath); err == nil {
-		for _, section := range cfg.Sections() {
-			name := section.Name()
-			if name != "DEFAULT" {
-				// Config file uses "profile xxx" format
-				name = strings.TrimPrefix(name, "profile ")
-				profiles[name] = true
-			}
+	// A profile can't share its directory with a cloud
+	profiles = slices.DeleteFunc(profiles, func(p string) bool {
+		_, cloud := fs.clouds[p]
+		if cloud {
+			log.Printf("[fs] profile %s is hidden by the cloud of the same name", p)
 		}
-	}
+		return cloud
+	})
+	fs.profiles = profiles
 
-	result := make([]string, 0, len(profiles))
-	for p := range profiles {
-		result = append(result, p)
+	if cfg.PinDir != "" {
+		fs.loadPins()
 	}
-	return result, nil
+	return fs, nil
 }
 
-// getProvider returns a cached provider or creates a new one
+// getProvider returns a cached provider or creates a new one. Creation
+// locks only its own key, so a profile whose credentials are slow to load
+// (e.g. an expired SSO session) doesn't hold up listings in the others.
 func (f *SisuFS) getProvider(profile, region, service string) (provider.Provider, error) {
 	key := profile + "/" + region + "/" + service
+	cacheKey := f.providerKey(key, profile)
 
 	f.providersMu.RLock()
-	if p, ok := f.providers[key]; ok {
+	if p, ok := f.providers[cacheKey]; ok {
 		f.providersMu.RUnlock()
 		return p, nil
 	}
 	f.providersMu.RUnlock()
 
+	creating := f.creatingLock(cacheKey)
+	creating.Lock()
+	defer creating.Unlock()
+
+	// Double-check after waiting for another creator of the same key
+	f.providersMu.RLock()
+	if p, ok := f.providers[cacheKey]; ok {
+		f.providersMu.RUnlock()
+		return p, nil
+	}
+	f.providersMu.RUnlock()
+
+	p, err := f.newProvider(profile, region, service)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
 	f.providersMu.Lock()
 	defer f.providersMu.Unlock()
+	p = f.wrapProvider(key, service, p)
+	f.providers[cacheKey] = p
+	return p, nil
+}
 
-	// Double-check after acquiring write lock
-	if p, ok := f.providers[key]; ok {
-		return p, nil
+// creatingLock returns the lock held while the provider under key is created
+func (f *SisuFS) creatingLock(key string) *sync.Mutex {
+	f.providersMu.Lock()
+	defer f.providersMu.Unlock()
+	mu, ok := f.creating[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		f.creating[key] = mu
 	}
+	return mu
+}
 
+// newProvider creates the unwrapped provider of a service, or returns nil
+// if the profile doesn't have it
+func (f *SisuFS) newProvider(profile, region, service string) (provider.Provider, error) {
 	// Use "default" if profile is "default"
 	profileArg := profile
 	if profile == "default" {
 		profileArg = ""
 	}
 
-	var p provider.Provider
-	var err error
+	if c, ok := f.clouds[profile]; ok {
+		if !c.hasScope(region) || !c.hasService(service) {
+			return nil, nil
+		}
+		return c.newProvider(region, service), nil
+	}
+
+	if f.config.Replay != nil {
+		if !f.isGlobalService(service) && !isRegionalService(service) {
+			return nil, nil
+		}
+		return f.config.Replay.Provider(profile+"/"+region+"/"+service, service), nil
+	}
 
 	switch service {
 	case "s3":
-		p, err = provider.NewS3Provider(profileArg, region)
+		return provider.NewS3Provider(profileArg, region)
 	case "ssm":
-		p, err = provider.NewSSMProvider(profileArg, region)
+		return provider.NewSSMProvider(profileArg, region)
 	case "vpc":
-		p, err = provider.NewVPCProvider(profileArg, region)
+		return provider.NewVPCProvider(profileArg, region)
 	case "iam":
-		p, err = provider.NewIAMProvider(profileArg, region)
+		return provider.NewIAMProvider(profileArg, region)
+	case "access-analyzer":
+		return provider.NewAccessAnalyzerProvider(profileArg)
+	case "identity-center":
+		return provider.NewIdentityCenterProvider(profileArg)
 	case "lambda":
-		p, err = provider.NewLambdaProvider(profileArg, region)
+		return provider.NewLambdaProvider(profileArg, region)
 	case "ec2":
-		p, err = provider.NewEC2Provider(profileArg, region)
-	default:
+		return provider.NewEC2Provider(profileArg, region)
+	case "dynamodb":
+		return provider.NewDynamoDBProvider(profileArg, region)
+	case "cloudwatch":
+		return provider.NewCloudWatchProvider(profileArg, region)
+	case "sqs":
+		return provider.NewSQSProvider(profileArg, region)
+	case "kinesis":
+		return provider.NewKinesisProvider(profileArg, region)
+	case "apprunner":
+		return provider.NewAppRunnerProvider(profileArg, region)
+	case "lightsail":
+		return provider.NewLightsailProvider(profileArg, region)
+	case "batch":
+		return provider.NewBatchProvider(profileArg, region)
+	case "emr":
+		return provider.NewEMRProvider(profileArg, region)
+	case "mwaa":
+		return provider.NewMWAAProvider(profileArg, region)
+	case "dms":
+		return provider.NewDMSProvider(profileArg, region)
+	case "datasync":
+		return provider.NewDataSyncProvider(profileArg, region)
+	case "athena":
+		return provider.NewAthenaProvider(profileArg, region)
+	case "topology":
+		return provider.NewTopologyProvider(profileArg, region)
+	case "elb":
+		return provider.NewELBProvider(profileArg, region)
+	}
+	ep, ok := f.endpoint(service)
+	if !ok {
 		return nil, nil
 	}
+	return provider.NewHTTPProvider(ep), nil
+}
 
-	if err != nil {
-		return nil, err
+// wrapProvider applies the middleware chain and result cache to p, the
+// provider under key. Callers must hold providersMu.
+func (f *SisuFS) wrapProvider(key, service string, p provider.Provider) provider.Provider {
+	denied := provider.AccessDenied(func(path string) {
+		if path == "" {
+			f.mu.Lock()
+			f.denied[key] = time.Now()
+			f.mu.Unlock()
+		}
+	})
+	dir := f.serviceDir(key, service)
+	profile, _, _ := strings.Cut(key, "/")
+	mws := append([]provider.Middleware{provider.Offline(f.snapshotsFor(key)), denied}, f.middlewareFor(profile, service)...)
+	if f.hooks != nil {
+		_, cloud := f.clouds[profile]
+		mws = append([]provider.Middleware{f.hooks.middleware(dir, !cloud)}, mws...)
+	}
+	if f.config.Record != nil {
+		mws = append([]provider.Middleware{f.config.Record.Middleware(key)}, mws...)
+	}
+	if f.config.RoundTrip && !provider.ObjectStores[service] {
+		mws = append([]provider.Middleware{provider.Canonical(service)}, mws...)
+	}
+	mws = append([]provider.Middleware{provider.Paged(f.pagesFor(key))}, mws...)
+	if rules := f.renderRules(service); len(rules) > 0 {
+		mws = append([]provider.Middleware{provider.Render(rules)}, mws...)
+	}
+	if f.config.Redact {
+		// Above everything that produces content, so snapshots and
+		// rendered documents are masked too. p answers IsSecret from the
+		// reads that reached it through the chain, without calls that
+		// would bypass it.
+		secrets, _ := p.(provider.SecretReader)
+		mws = append([]provider.Middleware{provider.Redact(secrets)}, mws...)
+	}
+	// Outermost, so a panic anywhere beneath the cache fails one call
+	// instead of the mount
+	mws = append([]provider.Middleware{provider.Recover()}, mws...)
+
+	policy := provider.DefaultCachePolicy
+	if f.config.Cache != nil {
+		policy = *f.config.Cache
+	}
+	cached := provider.Cached(provider.Chain(p, mws...), policy)
+	if provider.ListingStats(p) {
+		cached = cached.StatFromListings()
+	}
+	p = cached.Throttled(f.backoffFor(profile, service)).Observe(func(c provider.Change) {
+		c.Path = f.names.encodePath(c.Path)
+		f.changes.record(dir, c)
+	})
+	return p
+}
+
+// isChangesFile reports whether the parsed path is a profile's changes.log
+func (f *SisuFS) isChangesFile(profile, region, service string) bool {
+	return region == ChangesFile && service == "" && slices.Contains(f.profiles, profile)
+}
+
+// serviceDir returns the mount path of the provider under key, whose region
+// is us-east-1 for AWS global services
+func (f *SisuFS) serviceDir(key, service string) string {
+	profile, rest, _ := strings.Cut(key, "/")
+	region, _, _ := strings.Cut(rest, "/")
+	if _, ok := f.clouds[profile]; !ok && f.isGlobalService(service) {
+		region = "global"
 	}
+	return profile + "/" + region + "/" + service
+}
 
-	f.providers[key] = p
-	return p, nil
+// endpoint returns the configured JSON endpoint mounted as service
+func (f *SisuFS) endpoint(service string) (config.Endpoint, bool) {
+	for _, ep := range f.config.Endpoints {
+		if ep.Name == service {
+			return ep, true
+		}
+	}
+	return config.Endpoint{}, false
+}
+
+// isGlobalService reports whether service is listed under global/, either
+// built in or a configured endpoint
+func (f *SisuFS) isGlobalService(service string) bool {
+	if globalServices[service] {
+		return true
+	}
+	_, ok := f.endpoint(service)
+	return ok
+}
+
+// deniedTTL is how long a service stays hidden after its listing was
+// denied, so it reappears once access is granted without a remount
+const deniedTTL = 10 * time.Minute
+
+// hidden reports whether a service is left out of listings because its
+// listing was denied within deniedTTL and HideDenied is set
+func (f *SisuFS) hidden(profile, region, service string) bool {
+	if !f.config.HideDenied {
+		return false
+	}
+	if region == "global" {
+		region = "us-east-1"
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	at, ok := f.denied[profile+"/"+region+"/"+service]
+	return ok && time.Since(at) < deniedTTL
+}
+
+func isRegionalService(service string) bool {
+	for _, s := range regionalServices {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// backoffFor returns the throttling backoff shared by a service's providers
+// in one profile, so throttling in one account doesn't stretch the cache
+// TTLs of the others. Callers must hold providersMu.
+func (f *SisuFS) backoffFor(profile, service string) *provider.Backoff {
+	key := profile + "/" + service
+	b, ok := f.backoffs[key]
+	if !ok {
+		b = provider.NewBackoff()
+		f.backoffs[key] = b
+	}
+	return b
 }
 
+// limiterFor returns the rate limit shared by a service's providers in one
+// profile, whatever their region. Callers must hold providersMu.
+func (f *SisuFS) limiterFor(profile, service string) *provider.Limiter {
+	key := profile + "/" + service
+	l, ok := f.limiters[key]
+	if !ok {
+		l = provider.NewLimiter(f.config.RateLimit, 1)
+		f.limiters[key] = l
+	}
+	return l
+}
+
+// poolFor returns the pool bounding the calls in flight across a profile's
+// providers. Callers must hold providersMu.
+func (f *SisuFS) poolFor(profile string) *provider.Pool {
+	p, ok := f.pools[profile]
+	if !ok {
+		p = provider.NewPool(provider.ProfilePoolSize)
+		f.pools[profile] = p
+	}
+	return p
+}
+
+// middlewareFor builds the decorator chain applied to every provider of a service,
+// beneath the result cache. Writes are validated first so rejected content never
+// reaches AWS or the metrics. Metrics sit next so they count every call that
+// missed the cache, and the tracer sits above the retries so one trace holds
+// every attempt. The backoff sits beneath the retries so it sees every
+// throttled attempt, and the timeout sits beneath them so every retry gets a
+// fresh deadline. The profile's pool is innermost, so waiting for a slot
+// counts against that deadline and a profile whose calls hang fails its own
+// calls instead of piling them up.
+func (f *SisuFS) middlewareFor(profile, service string) []provider.Middleware {
+	m, ok := f.metrics[service]
+	if !ok {
+		m = provider.NewMetrics()
+		f.metrics[service] = m
+	}
+
+	mws := []provider.Middleware{
+		provider.Validate(provider.Validators(service)...),
+		m.Middleware(),
+		provider.Logging(),
+	}
+	if f.config.Trace != nil {
+		mws = append(mws, f.config.Trace.Middleware())
+	}
+	if f.config.RateLimit > 0 {
+		mws = append(mws, f.limiterFor(profile, service).Middleware())
+	}
+	mws = append(mws,
+		provider.Retry(3),
+		f.backoffFor(profile, service).Middleware(),
+		provider.Timeout(f.timeoutsFor(service)),
+		f.poolFor(profile).Middleware(),
+	)
+	return mws
+}
+
+// timeoutsFor returns the effective operation timeouts for a service
+func (f *SisuFS) timeoutsFor(service string) provider.Timeouts {
+	base := f.config.Timeouts.Merge(provider.DefaultTimeouts)
+	return f.config.ServiceTimeouts[service].Merge(base)
+}
+
+// errStatus maps a provider error to a FUSE status, using fallback for
+// errors that have no more specific mapping
+func errStatus(err error, fallback fuse.Status) fuse.Status {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fuse.Status(syscall.ETIMEDOUT)
+	}
+	if errors.Is(err, provider.ErrPanic) {
+		return fuse.EIO
+	}
+	if errors.Is(err, os.ErrInvalid) {
+		return fuse.EINVAL
+	}
+	if provider.IsAccessDenied(err) {
+		return fuse.EACCES
+	}
+	if errors.Is(err, syscall.EFBIG) {
+		return fuse.Status(syscall.EFBIG)
+	}
+	if errors.Is(err, syscall.EAGAIN) {
+		return fuse.EAGAIN
+	}
+	return fallback
+}
+
+// newAttr builds attributes owned by the mounting user with consistent
+// atime/mtime/ctime. A zero mtime (synthetic directories, resources without
+// timestamps) falls back to the mount time rather than the epoch.
+func (f *SisuFS) newAttr(mode uint32, size int64, mtime time.Time) *fuse.Attr {
+	if mtime.IsZero() {
+		mtime = f.mountTime
+	}
+	attr := &fuse.Attr{
+		Mode:  mode,
+		Size:  uint64(size),
+		Owner: f.owner,
+	}
+	attr.SetTimes(&mtime, &mtime, &mtime)
+	return attr
+}
+
+// writable reports whether a write at subpath will be attempted: the provider
+// must support writes there, the service's write setting must allow it and,
+// if an allowlist is configured, the path (or for directories, something
+// below it) must match it
+func (f *SisuFS) writable(prov provider.Provider, service, subpath string, isDir bool) bool {
+	if prov == nil || !prov.Writable(subpath) {
+		return false
+	}
+	// Scopes and patterns name the documents as generated, outside page
+	// directories
+	subpath = f.renderRules(service).Source(paging.Strip(subpath))
+	if allowed, err := provider.WriteScope(service, string(f.config.Write[service])); err != nil || !allowed(subpath) {
+		return false
+	}
+	if f.config.Writable == nil {
+		return true
+	}
+	if isDir {
+		return f.config.Writable.MayContain(service, subpath)
+	}
+	return f.config.Writable.Match(service, subpath)
+}
+
+// renderRules returns the formats service lists documents in: the
+// mount's "*" setting, overridden by the service's and then by those of
+// its directories
+func (f *SisuFS) renderRules(service string) provider.RenderRules {
+	rules := provider.RenderRules{}
+	if format, ok := f.config.Render["*"]; ok && !provider.ObjectStores[service] {
+		rules[""] = format
+	}
+	for key, format := range f.config.Render {
+		if key == service {
+			rules[""] = format
+		} else if dir, ok := strings.CutPrefix(key, service+"/"); ok {
+			rules[dir] = format
+		}
+	}
+	return rules
+}
+
+// entryMode returns the mode bits for a provider entry, so that permission
+// bits reflect whether writes will actually be attempted
+func (f *SisuFS) entryMode(prov provider.Provider, service, subpath string, isDir bool) uint32 {
+	w := f.writable(prov, service, subpath, isDir)
+	switch {
+	case isDir && w:
+		return fuse.S_IFDIR | 0755
+	case isDir:
+		return fuse.S_IFDIR | 0555
+	case w:
+		return fuse.S_IFREG | 0644
+	}
+	return fuse.S_IFREG | 0444
+}
+
+// joinPath joins a provider subpath and a child name
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// FSName is the fsname and FUSE subtype of sisu mounts, so they show up
+// as "sisu on <path> type fuse.sisu" in mount and can be told apart from
+// other FUSE mounts
+const FSName = "sisu"
+
 // Mount mounts the filesystem at the given path
 func (f *SisuFS) Mount(mountpoint string) (*fuse.Server, error) {
-	nfs := pathfs.NewPathNodeFs(f, nil)
+	if f.config.Root != "" {
+		if err := f.checkRoot(); err != nil {
+			return nil, err
+		}
+	}
+	f.lastActivity.CompareAndSwap(0, time.Now().UnixNano())
+	nfs := pathfs.NewPathNodeFs(&activityFS{FileSystem: &lockingFS{FileSystem: f.mountedFS(), locks: f.locks}, f: f}, nil)
 	opts := &nodefs.Options{
 		AttrTimeout:  time.Second,
 		EntryTimeout: time.Second,
 	}
 
-	server, _, err := nodefs.MountRoot(mountpoint, nfs.Root(), opts)
+	// Locks are forwarded to sisu and kept in fileLocks, rather than
+	// failing with ENOSYS where the FUSE layer doesn't handle them itself
+	mountOpts := &fuse.MountOptions{
+		EnableLocks: true,
+		FsName:      FSName,
+		Name:        FSName,
+	}
+	conn := nodefs.NewFileSystemConnector(nfs.Root(), opts)
+	server, err := fuse.NewServer(&interruptibleFS{RawFileSystem: conn.RawFS(), locks: f.locks}, mountpoint, mountOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +676,7 @@ var ignoredFiles = map[string]bool{
 
 // parsePath parses a path and returns profile, region, service, and subpath
 // Structure: profile/region/service/subpath or profile/global/service/subpath
+// The returned subpath is decoded back to the provider's resource names.
 func (f *SisuFS) parsePath(path string) (profile, region, service, subpath string, ok bool) {
 	parts := strings.SplitN(path, "/", 4)
 	if len(parts) < 1 {
@@ -229,7 +698,7 @@ func (f *SisuFS) parsePath(path string) (profile, region, service, subpath strin
 		return profile, region, service, "", true
 	}
 
-	subpath = parts[3]
+	subpath = f.names.decodePath(parts[3])
 	return profile, region, service, subpath, true
 }
 
@@ -241,7 +710,10 @@ func (f *SisuFS) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Statu
 
 	// Root directory
 	if name == "" {
-		return &fuse.Attr{Mode: fuse.S_IFDIR | 0777}, fuse.OK
+		return f.newAttr(fuse.S_IFDIR|0777, 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
+	}
+	if isControlPath(name) {
+		return f.controlGetAttr(name)
 	}
 
 	// Quick reject for shell probe files
@@ -262,90 +734,220 @@ func (f *SisuFS) GetAttr(name string, ctx *fuse.Context) (*fuse.Attr, fuse.Statu
 	f.mu.RLock()
 	if pending, ok := f.pendingFiles[name]; ok {
 		f.mu.RUnlock()
-		return &fuse.Attr{Mode: fuse.S_IFREG | 0666, Size: uint64(pending.buf.Len())}, fuse.OK
+		var attr fuse.Attr
+		pending.GetAttr(&attr)
+		return &attr, fuse.OK
 	}
 	if f.virtualDirs[name] {
 		f.mu.RUnlock()
-		return &fuse.Attr{Mode: fuse.S_IFDIR | 0777}, fuse.OK
+		return f.newAttr(fuse.S_IFDIR|0777, 0, time.Time{}), fuse.OK
 	}
 	f.mu.RUnlock()
 
+	// Other clouds down to the service level
+	if c, ok := f.clouds[profile]; ok && subpath == "" {
+		if (region != "" && !c.hasScope(region)) || (service != "" && !c.hasService(service)) {
+			return nil, fuse.ENOENT
+		}
+		mode := uint32(fuse.S_IFDIR | 0555)
+		if service != "" {
+			prov, _ := f.getProvider(profile, region, service)
+			mode = f.entryMode(prov, service, "", true)
+		}
+		return f.newAttr(mode, 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
+	}
+
 	// Profile level
 	if region == "" {
 		for _, p := range f.profiles {
 			if p == profile {
-				return &fuse.Attr{Mode: fuse.S_IFDIR | 0555}, fuse.OK
+				return f.newAttr(fuse.S_IFDIR|0555, 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
 			}
 		}
 		return nil, fuse.ENOENT
 	}
 
+	if f.isChangesFile(profile, region, service) {
+		return f.newAttr(fuse.S_IFREG|0444, int64(len(f.changes.data(profile))), time.Now()), fuse.OK
+	}
+	if service == ThisInstance && subpath == "" {
+		if target, ok := f.thisInstanceTarget(profile, region); ok {
+			return f.newAttr(fuse.S_IFLNK|0777, int64(len(target)), f.mountTime), fuse.OK
+		}
+	}
+
 	// Region/global level
 	if service == "" {
 		if region == "global" {
-			return &fuse.Attr{Mode: fuse.S_IFDIR | 0555}, fuse.OK
+			return f.newAttr(fuse.S_IFDIR|0555, 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
 		}
 		for _, r := range f.config.Regions {
 			if r == region {
-				return &fuse.Attr{Mode: fuse.S_IFDIR | 0555}, fuse.OK
+				return f.newAttr(fuse.S_IFDIR|0555, 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
 			}
 		}
 		return nil, fuse.ENOENT
 	}
 
+	// Delegate to provider
+	actualRegion := region
+	if region == "global" {
+		actualRegion = "us-east-1" // IAM/S3 default
+	}
+
 	// Service level
 	if subpath == "" {
-		mode := uint32(0555) // read-only by default
-		if writableServices[service] {
-			mode = 0755
-		}
-		if region == "global" && globalServices[service] {
-			return &fuse.Attr{Mode: fuse.S_IFDIR | mode}, fuse.OK
-		}
+		known := region == "global" && f.isGlobalService(service)
 		for _, s := range regionalServices {
-			if s == service {
-				return &fuse.Attr{Mode: fuse.S_IFDIR | mode}, fuse.OK
+			if region != "global" && s == service {
+				known = true
 			}
 		}
-		return nil, fuse.ENOENT
-	}
-
-	// Delegate to provider
-	actualRegion := region
-	if region == "global" {
-		actualRegion = "us-east-1" // IAM/S3 default
+		if !known {
+			return nil, fuse.ENOENT
+		}
+		prov, _ := f.getProvider(profile, actualRegion, service)
+		return f.newAttr(f.entryMode(prov, service, "", true), 0, f.dirTimes.newest(name, time.Time{})), fuse.OK
 	}
 
 	prov, err := f.getProvider(profile, actualRegion, service)
 	if err != nil || prov == nil {
 		return nil, fuse.ENOENT
 	}
+	if rest, ok := cutStaging(subpath); ok {
+		return f.stagingGetAttr(prov, profile+"/"+region+"/"+service, rest)
+	}
+	if _, ok := f.cutDirInfo(subpath); ok {
+		// Generated on open, which reads it with direct I/O; the size is
+		// the last summary's
+		return f.newAttr(fuse.S_IFREG|0444, f.dirInfos.size(name), time.Now()), fuse.OK
+	}
 
-	entry, err := prov.Stat(context.Background(), subpath)
+	entry, err := f.lookup(prov, name, subpath)
 	if err != nil {
-		return nil, fuse.ENOENT
+		return nil, errStatus(err, fuse.ENOENT)
 	}
 
-	attr := &fuse.Attr{
-		Size:  uint64(entry.Size),
-		Mtime: uint64(entry.ModTime.Unix()),
+	if entry.Link != "" {
+		return f.newAttr(fuse.S_IFLNK|0777, int64(len(entry.Link)), entry.ModTime), fuse.OK
 	}
-
+	mtime := entry.ModTime
 	if entry.IsDir {
-		if writableServices[service] {
-			attr.Mode = fuse.S_IFDIR | 0755
-		} else {
-			attr.Mode = fuse.S_IFDIR | 0555
-		}
-	} else {
-		if writableServices[service] {
-			attr.Mode = fuse.S_IFREG | 0644
-		} else {
-			attr.Mode = fuse.S_IFREG | 0444
+		mtime = f.dirTimes.newest(name, mtime)
+	}
+	return f.newAttr(f.entryMode(prov, service, subpath, entry.IsDir), entry.Size, mtime), fuse.OK
+}
+
+// lookup stats subpath for GetAttr. During a lookup storm in name's
+// directory it uses a batch stat, which providers can answer for the whole
+// directory at once.
+func (f *SisuFS) lookup(prov provider.Provider, name, subpath string) (*provider.Entry, error) {
+	ctx := context.Background()
+	if !f.lookups.storm(filepath.Dir(name), time.Now()) {
+		return prov.Stat(ctx, subpath)
+	}
+	entries, err := provider.StatBatch(ctx, prov, []string{subpath})
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[subpath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return entry, nil
+}
+
+// Readlink resolves ThisInstance and the symlinks providers list, e.g.
+// between the nodes of topology
+func (f *SisuFS) Readlink(name string, ctx *fuse.Context) (string, fuse.Status) {
+	profile, region, service, subpath, ok := f.parsePath(name)
+	if !ok || !slices.Contains(f.profiles, profile) || service == "" {
+		return "", fuse.EINVAL
+	}
+	if service == ThisInstance && subpath == "" {
+		target, ok := f.thisInstanceTarget(profile, region)
+		if !ok {
+			return "", fuse.ENOENT
 		}
+		return target, fuse.OK
+	}
+	if subpath == "" {
+		return "", fuse.EINVAL
+	}
+	if region == "global" {
+		region = "us-east-1"
+	}
+	prov, err := f.getProvider(profile, region, service)
+	if err != nil || prov == nil {
+		return "", fuse.ENOENT
+	}
+	entry, err := prov.Stat(context.Background(), subpath)
+	if err != nil {
+		return "", errStatus(err, fuse.ENOENT)
+	}
+	if entry.Link == "" {
+		return "", fuse.EINVAL
+	}
+	return entry.Link, fuse.OK
+}
+
+// xattrKey carries the provider path an entry maps to, which can differ
+// from its filename after escaping or case-collision renaming
+const xattrKey = "user.sisu.key"
+
+// GetXAttr returns extended attributes of provider entries
+func (f *SisuFS) GetXAttr(name string, attribute string, ctx *fuse.Context) ([]byte, fuse.Status) {
+	attrs, status := f.xattrs(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	value, ok := attrs[attribute]
+	if !ok {
+		return nil, fuse.ENOATTR
+	}
+	return value, fuse.OK
+}
+
+// ListXAttr lists extended attribute names of provider entries
+func (f *SisuFS) ListXAttr(name string, ctx *fuse.Context) ([]string, fuse.Status) {
+	attrs, status := f.xattrs(name)
+	if status != fuse.OK {
+		return nil, status
+	}
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	return names, fuse.OK
+}
+
+// xattrChecksumPrefix names checksum attributes, e.g. user.sisu.sha256
+const xattrChecksumPrefix = "user.sisu."
+
+// xattrs collects the extended attributes for a path: its provider key,
+// any checksums the provider reports for it and, for listed directories,
+// which page of the listing they show
+func (f *SisuFS) xattrs(name string) (map[string][]byte, fuse.Status) {
+	profile, region, service, subpath, ok := f.parsePath(name)
+	if !ok || subpath == "" {
+		return map[string][]byte{}, fuse.OK
+	}
+	attrs := map[string][]byte{
+		xattrKey: []byte(subpath),
 	}
 
-	return attr, fuse.OK
+	if region == "global" {
+		region = "us-east-1"
+	}
+	if prov, err := f.getProvider(profile, region, service); err == nil && prov != nil {
+		if entry, err := prov.Stat(context.Background(), subpath); err == nil {
+			for algorithm, sum := range entry.Checksums {
+				attrs[xattrChecksumPrefix+algorithm] = []byte(sum)
+			}
+		}
+		f.pageXattrs(attrs, profile+"/"+region+"/"+service, subpath)
+	}
+	return attrs, fuse.OK
 }
 
 // Access checks file access permissions
@@ -358,6 +960,15 @@ func (f *SisuFS) Mkdir(name string, mode uint32, ctx *fuse.Context) fuse.Status
 	if Debug {
 		log.Printf("[fs] Mkdir: name=%q mode=%d", name, mode)
 	}
+	if isControlPath(name) {
+		return fuse.EPERM
+	}
+	if profile, region, service, subpath, ok := f.parsePath(name); ok {
+		if rest, ok := cutStaging(subpath); ok && rest != "" {
+			f.staging.mkdir(profile+"/"+region+"/"+service, rest)
+			return fuse.OK
+		}
+	}
 
 	f.mu.Lock()
 	f.virtualDirs[name] = true
@@ -376,6 +987,9 @@ func (f *SisuFS) Unlink(name string, ctx *fuse.Context) fuse.Status {
 	if !ok || subpath == "" {
 		return fuse.EPERM
 	}
+	if rest, ok := cutStaging(subpath); ok {
+		return f.stagingUnlink(profile+"/"+region+"/"+service, rest)
+	}
 
 	actualRegion := region
 	if region == "global" {
@@ -387,13 +1001,172 @@ func (f *SisuFS) Unlink(name string, ctx *fuse.Context) fuse.Status {
 		return fuse.ENOENT
 	}
 
+	if _, ok := f.cutDirInfo(subpath); ok || !f.writable(prov, service, subpath, false) {
+		return fuse.Status(syscall.EROFS)
+	}
+	if status := f.checkDelete(profile); !status.Ok() {
+		return status
+	}
+
 	if err := prov.Delete(context.Background(), subpath); err != nil {
-		return fuse.EIO
+		return errStatus(err, fuse.EIO)
+	}
+
+	f.notifyChanged(name)
+	return fuse.OK
+}
+
+// Rmdir removes a directory created with Mkdir that holds no files yet.
+// Directories backed by resources disappear with their last file instead.
+func (f *SisuFS) Rmdir(name string, ctx *fuse.Context) fuse.Status {
+	if Debug {
+		log.Printf("[fs] Rmdir: name=%q", name)
+	}
+
+	profile, region, service, subpath, ok := f.parsePath(name)
+	if !ok {
+		return fuse.ENOENT
+	}
+	if rest, ok := cutStaging(subpath); ok && rest != "" {
+		return f.staging.rmdir(profile+"/"+region+"/"+service, rest)
+	}
+	if status := f.checkDelete(profile); !status.Ok() {
+		return status
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.virtualDirs[name] {
+		return fuse.EPERM
+	}
+	delete(f.virtualDirs, name)
+	return fuse.OK
+}
+
+// Rename moves a file within a single service by copying its content to the
+// new path and deleting the old one. Moves across services, regions or
+// profiles return EXDEV so tools like mv fall back to copy and delete.
+func (f *SisuFS) Rename(oldName string, newName string, ctx *fuse.Context) fuse.Status {
+	if Debug {
+		log.Printf("[fs] Rename: old=%q new=%q", oldName, newName)
+	}
+
+	profile, region, service, oldPath, ok := f.parsePath(oldName)
+	if !ok || oldPath == "" {
+		return fuse.EPERM
+	}
+	newProfile, newRegion, newService, newPath, ok := f.parsePath(newName)
+	if !ok || newPath == "" {
+		return fuse.EPERM
+	}
+	if profile != newProfile || region != newRegion || service != newService {
+		return fuse.Status(syscall.EXDEV)
+	}
+	// Moving files in or out of StagingDir would write or delete them
+	// right away, so only moves within it are allowed
+	oldRest, oldStaged := cutStaging(oldPath)
+	newRest, newStaged := cutStaging(newPath)
+	if oldStaged || newStaged {
+		if !oldStaged || !newStaged {
+			return fuse.EPERM
+		}
+		return f.stagingRename(profile+"/"+region+"/"+service, oldRest, newRest)
+	}
+
+	actualRegion := region
+	if region == "global" {
+		actualRegion = "us-east-1"
+	}
+
+	prov, err := f.getProvider(profile, actualRegion, service)
+	if err != nil || prov == nil {
+		return fuse.ENOENT
+	}
+
+	if !f.writable(prov, service, oldPath, false) || !f.writable(prov, service, newPath, false) {
+		return fuse.Status(syscall.EROFS)
+	}
+	// A rename deletes the old path
+	if status := f.checkDelete(profile); !status.Ok() {
+		return status
+	}
+
+	ctx2 := context.Background()
+	if entry, err := prov.Stat(ctx2, oldPath); err == nil {
+		if err := f.checkReadSize(oldName, entry.Size); err != nil {
+			return errStatus(err, fuse.EIO)
+		}
+	}
+	data, err := prov.Read(ctx2, oldPath)
+	if err != nil {
+		return errStatus(err, fuse.ENOENT)
+	}
+	if err := prov.Write(ctx2, newPath, data); err != nil {
+		return errStatus(err, fuse.EIO)
+	}
+	if err := prov.Delete(ctx2, oldPath); err != nil {
+		return errStatus(err, fuse.EIO)
 	}
 
+	f.notifyChanged(oldName)
+	f.notifyChanged(newName)
 	return fuse.OK
 }
 
+// OnMount keeps the node filesystem so changes can be pushed to the kernel
+func (f *SisuFS) OnMount(nodeFs *pathfs.PathNodeFs) {
+	f.nodeFs = nodeFs
+}
+
+// notifyChanged evicts the kernel's cached dentry, attributes and page cache
+// for a path modified through the mount, so other shells see the change
+// immediately instead of after the entry timeout. The provider cache has
+// already been evicted by the caching layer, and the parent directories'
+// mtimes move to now. Notifications are sent
+// asynchronously: the kernel holds the parent directory lock while the
+// current request runs, and notifying it from inside would deadlock.
+func (f *SisuFS) notifyChanged(name string) {
+	dir, base := filepath.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	f.dirTimes.observe(dir, time.Now())
+	f.kept.forget(name)
+
+	name, ok := f.mountPath(name)
+	if f.nodeFs == nil || !ok {
+		return
+	}
+	dir, base = filepath.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+
+	go func() {
+		f.nodeFs.EntryNotify(dir, base)
+		f.nodeFs.FileNotify(name, 0, 0)
+		f.nodeFs.FileNotify(dir, 0, 0)
+	}()
+}
+
+// notifyWritten is notifyChanged for a file written through prov, whose
+// path in it is subpath. Files showing the same resource, e.g. an SSM
+// parameter and its @b64 sidecar, are dropped from prov's cache and
+// notified too.
+func (f *SisuFS) notifyWritten(prov provider.Provider, name, subpath string) {
+	f.notifyChanged(name)
+	_, _, service, _, ok := f.parsePath(name)
+	if !ok {
+		return
+	}
+	prefix, ok := strings.CutSuffix(name, f.names.encodePath(subpath))
+	if !ok {
+		return
+	}
+	for _, related := range provider.RelatedFiles(service, subpath) {
+		if inv, ok := prov.(provider.Invalidator); ok {
+			inv.Invalidate(related)
+		}
+		f.notifyChanged(prefix + f.names.encodePath(related))
+	}
+}
+
 // OpenDir opens a directory for reading
 func (f *SisuFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
 	if Debug {
@@ -402,27 +1175,55 @@ func (f *SisuFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.
 
 	// Root directory - list profiles
 	if name == "" {
-		entries := make([]fuse.DirEntry, len(f.profiles))
-		for i, p := range f.profiles {
-			entries[i] = fuse.DirEntry{Name: p, Mode: fuse.S_IFDIR | 0555}
+		entries := make([]fuse.DirEntry, 0, len(f.profiles)+1)
+		for _, p := range f.profiles {
+			entries = append(entries, fuse.DirEntry{Name: p, Mode: fuse.S_IFDIR | 0555})
+		}
+		for name := range f.clouds {
+			entries = append(entries, fuse.DirEntry{Name: name, Mode: fuse.S_IFDIR | 0555})
 		}
+		entries = append(entries,
+			fuse.DirEntry{Name: ControlDir, Mode: fuse.S_IFDIR | 0555},
+			fuse.DirEntry{Name: HelpDir, Mode: fuse.S_IFDIR | 0555})
 		return entries, fuse.OK
 	}
+	if isControlPath(name) {
+		return f.controlOpenDir(name)
+	}
 
 	profile, region, service, subpath, ok := f.parsePath(name)
 	if !ok {
 		return nil, fuse.ENOENT
 	}
 
+	// Other clouds: list scopes, then services
+	if c, ok := f.clouds[profile]; ok && service == "" {
+		if region != "" && !c.hasScope(region) {
+			return nil, fuse.ENOENT
+		}
+		names := c.names(region)
+		entries := make([]fuse.DirEntry, 0, len(names))
+		for _, n := range names {
+			if region == "" || !f.hidden(profile, region, n) {
+				entries = append(entries, fuse.DirEntry{Name: n, Mode: fuse.S_IFDIR | 0555})
+			}
+		}
+		return entries, fuse.OK
+	}
+
 	// Profile level: list regions + global
 	if region == "" {
-		entries := make([]fuse.DirEntry, 0, len(f.config.Regions)+1)
+		entries := make([]fuse.DirEntry, 0, len(f.config.Regions)+2)
 		entries = append(entries, fuse.DirEntry{Name: "global", Mode: fuse.S_IFDIR | 0555})
 		for _, r := range f.config.Regions {
 			entries = append(entries, fuse.DirEntry{Name: r, Mode: fuse.S_IFDIR | 0555})
 		}
+		entries = append(entries, fuse.DirEntry{Name: ChangesFile, Mode: fuse.S_IFREG | 0444})
 		return entries, fuse.OK
 	}
+	if f.isChangesFile(profile, region, service) {
+		return nil, fuse.ENOTDIR
+	}
 
 	// Region/global level: list services
 	if service == "" {
@@ -431,16 +1232,26 @@ func (f *SisuFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.
 			for s := range globalServices {
 				services = append(services, s)
 			}
+			for _, ep := range f.config.Endpoints {
+				services = append(services, ep.Name)
+			}
 		} else {
 			services = regionalServices
 		}
-		entries := make([]fuse.DirEntry, len(services))
-		for i, s := range services {
-			mode := uint32(0555)
-			if writableServices[s] {
-				mode = 0755
+		actualRegion := region
+		if region == "global" {
+			actualRegion = "us-east-1"
+		}
+		entries := make([]fuse.DirEntry, 0, len(services)+1)
+		for _, s := range services {
+			if !f.hidden(profile, region, s) {
+				// The same mode as GetAttr, e.g. writable with a write scope
+				prov, _ := f.getProvider(profile, actualRegion, s)
+				entries = append(entries, fuse.DirEntry{Name: s, Mode: f.entryMode(prov, s, "", true)})
 			}
-			entries[i] = fuse.DirEntry{Name: s, Mode: fuse.S_IFDIR | mode}
+		}
+		if _, ok := f.thisInstanceTarget(profile, region); ok {
+			entries = append(entries, fuse.DirEntry{Name: ThisInstance, Mode: fuse.S_IFLNK | 0777})
 		}
 		return entries, fuse.OK
 	}
@@ -463,6 +1274,10 @@ func (f *SisuFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.
 		return nil, fuse.ENOENT
 	}
 
+	if rest, ok := cutStaging(subpath); ok {
+		return f.stagingOpenDir(profile+"/"+region+"/"+service, rest)
+	}
+
 	provEntries, err := prov.ReadDir(context.Background(), subpath)
 	if err != nil {
 		f.mu.RLock()
@@ -471,38 +1286,64 @@ func (f *SisuFS) OpenDir(name string, ctx *fuse.Context) ([]fuse.DirEntry, fuse.
 		if isVirtual {
 			return []fuse.DirEntry{}, fuse.OK
 		}
-		return nil, fuse.EIO
+		return nil, errStatus(err, fuse.EIO)
+	}
+
+	filenames := make([]string, len(provEntries))
+	names := make([]string, len(provEntries))
+	for i, e := range provEntries {
+		filenames[i] = f.names.encode(e.Name)
+		names[i] = e.Name
+	}
+	if f.config.CaseInsensitive {
+		f.names.disambiguate(filenames, names)
 	}
 
 	entries := make([]fuse.DirEntry, len(provEntries))
 	for i, e := range provEntries {
-		var mode uint32
 		if e.IsDir {
-			if writableServices[service] {
-				mode = fuse.S_IFDIR | 0755
-			} else {
-				mode = fuse.S_IFDIR | 0555
-			}
+			f.dirTimes.observe(joinPath(name, filenames[i]), e.ModTime)
 		} else {
-			if writableServices[service] {
-				mode = fuse.S_IFREG | 0644
-			} else {
-				mode = fuse.S_IFREG | 0444
-			}
+			f.dirTimes.observe(name, e.ModTime)
 		}
-		entries[i] = fuse.DirEntry{Name: e.Name, Mode: mode}
+		mode := f.entryMode(prov, service, joinPath(subpath, e.Name), e.IsDir)
+		if e.Link != "" {
+			mode = fuse.S_IFLNK | 0777
+		}
+		entries[i] = fuse.DirEntry{Name: filenames[i], Mode: mode}
+	}
+	if f.config.DirInfo {
+		entries = append(entries, fuse.DirEntry{Name: DirInfoFile, Mode: fuse.S_IFREG | 0444})
 	}
 
 	return entries, fuse.OK
 }
 
-// Open opens a file for reading
+// Open opens a file. Read-only opens get a snapshot of the content; opens
+// with write intent (O_WRONLY, O_RDWR or O_TRUNC) get a buffer that is
+// written back on close, starting empty for O_TRUNC and from the current
+// content otherwise.
 func (f *SisuFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File, fuse.Status) {
 	if Debug {
 		log.Printf("[fs] Open: name=%q flags=%d", name, flags)
 	}
 
+	if isControlPath(name) {
+		return f.controlOpen(name, flags)
+	}
+
 	profile, region, service, subpath, ok := f.parsePath(name)
+	if ok && f.isChangesFile(profile, region, service) {
+		if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+			return nil, fuse.EACCES
+		}
+		data := f.changes.data(profile)
+		return &sisuFile{
+			File: nodefs.NewDefaultFile(),
+			data: data,
+			attr: f.newAttr(fuse.S_IFREG|0444, int64(len(data)), time.Now()),
+		}, fuse.OK
+	}
 	if !ok || subpath == "" {
 		return nil, fuse.ENOENT
 	}
@@ -516,16 +1357,71 @@ func (f *SisuFS) Open(name string, flags uint32, ctx *fuse.Context) (nodefs.File
 	if err != nil || prov == nil {
 		return nil, fuse.ENOENT
 	}
+	if rest, ok := cutStaging(subpath); ok {
+		return f.stagingOpen(prov, profile+"/"+region+"/"+service, service, name, rest, flags, false)
+	}
+	if dir, ok := f.cutDirInfo(subpath); ok {
+		if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+			return nil, fuse.EACCES
+		}
+		data, err := f.dirInfo(prov, name, dir)
+		if err != nil {
+			return nil, errStatus(err, fuse.EIO)
+		}
+		// Direct I/O, as GetAttr may have reported an older summary's size
+		return &nodefs.WithFlags{
+			File: &sisuFile{
+				File: nodefs.NewDefaultFile(),
+				data: data,
+				attr: f.newAttr(fuse.S_IFREG|0444, int64(len(data)), time.Now()),
+			},
+			FuseFlags: fuse.FOPEN_DIRECT_IO,
+		}, fuse.OK
+	}
+
+	write := flags&syscall.O_ACCMODE != syscall.O_RDONLY || flags&syscall.O_TRUNC != 0
+	if write && !f.writable(prov, service, subpath, false) {
+		return nil, fuse.Status(syscall.EROFS)
+	}
+
+	if write && flags&syscall.O_TRUNC != 0 {
+		wf := f.newWriteableFile(prov, subpath, name, nil)
+		wf.dirty = true // truncating an existing file must write it back even if nothing follows
+		return wf, fuse.OK
+	}
+
+	// Stat is normally served from cache since the kernel looked the file up
+	// first. Big files are read in ranges, unless opened for editing, which
+	// needs the whole content in memory.
+	var mtime time.Time
+	if entry, err := prov.Stat(context.Background(), subpath); err == nil {
+		mtime = entry.ModTime
+		if write {
+			if err := f.checkReadSize(name, entry.Size); err != nil {
+				return nil, errStatus(err, fuse.EIO)
+			}
+		} else if entry.Size > rangeReadMinSize {
+			return newRangeFile(prov, subpath, f.newAttr(f.entryMode(prov, service, subpath, false), entry.Size, mtime)), fuse.OK
+		}
+	}
 
 	data, err := prov.Read(context.Background(), subpath)
 	if err != nil {
 		if Debug {
 			log.Printf("[fs] Open: Read failed for %q: %v", name, err)
 		}
-		return nil, fuse.EIO
+		return nil, errStatus(err, fuse.EIO)
+	}
+
+	if write {
+		return f.newWriteableFile(prov, subpath, name, data), fuse.OK
 	}
 
-	return &sisuFile{File: nodefs.NewDefaultFile(), data: data}, fuse.OK
+	return f.openReadOnly(name, &sisuFile{
+		File: nodefs.NewDefaultFile(),
+		data: data,
+		attr: f.newAttr(f.entryMode(prov, service, subpath, false), int64(len(data)), mtime),
+	}), fuse.OK
 }
 
 // Create creates a new file for writing
@@ -548,26 +1444,50 @@ func (f *SisuFS) Create(name string, flags uint32, mode uint32, ctx *fuse.Contex
 	if err != nil || prov == nil {
 		return nil, fuse.ENOENT
 	}
+	if rest, ok := cutStaging(subpath); ok {
+		return f.stagingOpen(prov, profile+"/"+region+"/"+service, service, name, rest, flags, true)
+	}
 
+	if _, ok := f.cutDirInfo(subpath); ok || !f.writable(prov, service, subpath, false) {
+		return nil, fuse.Status(syscall.EROFS)
+	}
+
+	// The kernel rejects O_EXCL for names it knows exist, but its view may
+	// be stale, so check the provider too (e.g. set -o noclobber)
+	if flags&syscall.O_EXCL != 0 {
+		if _, err := prov.Stat(context.Background(), subpath); err == nil {
+			return nil, fuse.Status(syscall.EEXIST)
+		}
+	}
+
+	return f.newWriteableFile(prov, subpath, name, nil), fuse.OK
+}
+
+// newWriteableFile returns a write buffer for name seeded with data and
+// registers it so GetAttr reflects the pending content
+func (f *SisuFS) newWriteableFile(prov provider.Provider, subpath, name string, data []byte) *writeableSisuFile {
 	wf := &writeableSisuFile{
-		File: nodefs.NewDefaultFile(),
-		prov: prov,
-		path: subpath,
-		fs:   f,
-		name: name,
+		File:  nodefs.NewDefaultFile(),
+		prov:  prov,
+		path:  subpath,
+		data:  append([]byte(nil), data...),
+		fs:    f,
+		name:  name,
+		mtime: time.Now(),
 	}
 
 	f.mu.Lock()
 	f.pendingFiles[name] = wf
 	f.mu.Unlock()
 
-	return wf, fuse.OK
+	return wf
 }
 
 // sisuFile is a simple in-memory file
 type sisuFile struct {
 	nodefs.File
 	data []byte
+	attr *fuse.Attr
 }
 
 func (f *sisuFile) Read(buf []byte, off int64) (fuse.ReadResult, fuse.Status) {
@@ -582,15 +1502,15 @@ func (f *sisuFile) Read(buf []byte, off int64) (fuse.ReadResult, fuse.Status) {
 }
 
 func (f *sisuFile) GetAttr(out *fuse.Attr) fuse.Status {
-	out.Mode = fuse.S_IFREG | 0644
+	*out = *f.attr
 	out.Size = uint64(len(f.data))
 	return fuse.OK
 }
 
-func (f *sisuFile) Release()                          {}
-func (f *sisuFile) Flush() fuse.Status                { return fuse.OK }
-func (f *sisuFile) Fsync(flags int) fuse.Status       { return fuse.OK }
-func (f *sisuFile) Truncate(size uint64) fuse.Status  { return fuse.Status(syscall.EROFS) }
+func (f *sisuFile) Release()                         {}
+func (f *sisuFile) Flush() fuse.Status               { return fuse.OK }
+func (f *sisuFile) Fsync(flags int) fuse.Status      { return fuse.OK }
+func (f *sisuFile) Truncate(size uint64) fuse.Status { return fuse.Status(syscall.EROFS) }
 func (f *sisuFile) Write(data []byte, off int64) (uint32, fuse.Status) {
 	return 0, fuse.Status(syscall.EROFS)
 }
@@ -598,52 +1518,91 @@ func (f *sisuFile) Write(data []byte, off int64) (uint32, fuse.Status) {
 // writeableSisuFile is a file that buffers writes and flushes to provider
 type writeableSisuFile struct {
 	nodefs.File
-	prov provider.Provider
-	path string
-	buf  bytes.Buffer
-	fs   *SisuFS
-	name string
+	prov  provider.Provider
+	path  string
+	mu    sync.Mutex
+	data  []byte
+	dirty bool // data differs from what the provider holds
+	fs    *SisuFS
+	name  string
+	mtime time.Time
 }
 
 func (f *writeableSisuFile) Write(data []byte, off int64) (uint32, fuse.Status) {
-	if off == 0 {
-		f.buf.Reset()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := off + int64(len(data))
+	if end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
 	}
-	n, err := f.buf.Write(data)
-	if err != nil {
-		return 0, fuse.EIO
+	copy(f.data[off:], data)
+	f.dirty = true
+	f.mtime = time.Now()
+	return uint32(len(data)), fuse.OK
+}
+
+func (f *writeableSisuFile) Read(buf []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if off >= int64(len(f.data)) {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+	end := off + int64(len(buf))
+	if end > int64(len(f.data)) {
+		end = int64(len(f.data))
 	}
-	return uint32(n), fuse.OK
+	return fuse.ReadResultData(append([]byte(nil), f.data[off:end]...)), fuse.OK
 }
 
+// Flush writes the buffer back on every close of the file. Unmodified
+// buffers are skipped so reading through a writable handle costs nothing.
 func (f *writeableSisuFile) Flush() fuse.Status {
-	if f.buf.Len() == 0 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty {
 		return fuse.OK
 	}
-	if err := f.prov.Write(context.Background(), f.path, f.buf.Bytes()); err != nil {
-		return fuse.EIO
+	if err := f.prov.Write(context.Background(), f.path, f.data); err != nil {
+		return errStatus(err, fuse.EIO)
 	}
+	f.dirty = false
+	f.fs.notifyWritten(f.prov, f.name, f.path)
 	return fuse.OK
 }
 
 func (f *writeableSisuFile) Release() {
 	if f.fs != nil {
 		f.fs.mu.Lock()
-		delete(f.fs.pendingFiles, f.name)
+		if f.fs.pendingFiles[f.name] == f {
+			delete(f.fs.pendingFiles, f.name)
+		}
 		f.fs.mu.Unlock()
 	}
-	f.buf.Reset()
+	f.mu.Lock()
+	f.data = nil
+	f.mu.Unlock()
 }
 
 func (f *writeableSisuFile) GetAttr(out *fuse.Attr) fuse.Status {
-	out.Mode = fuse.S_IFREG | 0644
-	out.Size = uint64(f.buf.Len())
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*out = *f.fs.newAttr(fuse.S_IFREG|0644, int64(len(f.data)), f.mtime)
 	return fuse.OK
 }
 
 func (f *writeableSisuFile) Truncate(size uint64) fuse.Status {
-	if size == 0 {
-		f.buf.Reset()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if size < uint64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-uint64(len(f.data)))...)
 	}
+	f.dirty = true
+	f.mtime = time.Now()
 	return fuse.OK
 }
@@ -0,0 +1,480 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// StagingDir is the directory in each service where files are collected to
+// be written together, e.g. prod/eu-west-1/ssm/.staging/app/db-url stages
+// app/db-url. Staged files stay in memory until ApplyFile is created.
+const StagingDir = ".staging"
+
+// ApplyFile writes every staged file of the service when created, e.g. with
+// touch. If one write fails, the files already written are restored to
+// what they held before, and the staged files are kept for another try.
+const ApplyFile = "APPLY"
+
+// ResultsFile lists the outcome for each file of the last apply
+const ResultsFile = "RESULTS"
+
+// cutStaging returns the path below StagingDir of a provider subpath, or
+// false if subpath is outside it
+func cutStaging(subpath string) (string, bool) {
+	if subpath == StagingDir {
+		return "", true
+	}
+	return strings.CutPrefix(subpath, StagingDir+"/")
+}
+
+// staging holds the files staged in each service, by "profile/region/service"
+type staging struct {
+	mu      sync.Mutex
+	files   map[string]map[string][]byte // staged content by provider path
+	dirs    map[string]map[string]bool   // directories made with mkdir
+	results map[string][]byte            // content of ResultsFile
+	apply   sync.Mutex                   // held while staged files are applied
+}
+
+func newStaging() *staging {
+	return &staging{
+		files:   make(map[string]map[string][]byte),
+		dirs:    make(map[string]map[string]bool),
+		results: make(map[string][]byte),
+	}
+}
+
+// file returns the staged content of path
+func (s *staging) file(key, path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[key][path]
+	return data, ok
+}
+
+func (s *staging) put(key, path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files[key] == nil {
+		s.files[key] = make(map[string][]byte)
+	}
+	s.files[key][path] = append([]byte(nil), data...)
+}
+
+// remove unstages path and reports whether it was staged
+func (s *staging) remove(key, path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[key][path]
+	delete(s.files[key], path)
+	return ok
+}
+
+func (s *staging) mkdir(key, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirs[key] == nil {
+		s.dirs[key] = make(map[string]bool)
+	}
+	s.dirs[key][path] = true
+}
+
+// isDir reports whether path was made with mkdir or has files staged below it
+func (s *staging) isDir(key, path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirs[key][path] {
+		return true
+	}
+	for p := range s.files[key] {
+		if strings.HasPrefix(p, path+"/") {
+			return true
+		}
+	}
+	for d := range s.dirs[key] {
+		if strings.HasPrefix(d, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rmdir removes a directory made with mkdir if nothing is staged below it
+func (s *staging) rmdir(key, path string) fuse.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirs[key][path] {
+		return fuse.ENOENT
+	}
+	for p := range s.files[key] {
+		if strings.HasPrefix(p, path+"/") {
+			return fuse.Status(syscall.ENOTEMPTY)
+		}
+	}
+	for d := range s.dirs[key] {
+		if strings.HasPrefix(d, path+"/") {
+			return fuse.Status(syscall.ENOTEMPTY)
+		}
+	}
+	delete(s.dirs[key], path)
+	return fuse.OK
+}
+
+// list returns the staged files and directories directly below dir
+func (s *staging) list(key, dir string) (files, dirs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	add := func(path string, isDir bool) {
+		rest, ok := path, dir == ""
+		if !ok {
+			rest, ok = strings.CutPrefix(path, dir+"/")
+		}
+		if !ok {
+			return
+		}
+		name, _, nested := strings.Cut(rest, "/")
+		if rest == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		if isDir || nested {
+			dirs = append(dirs, name)
+		} else {
+			files = append(files, name)
+		}
+	}
+	for p := range s.files[key] {
+		add(p, false)
+	}
+	for d := range s.dirs[key] {
+		add(d, true)
+	}
+	return files, dirs
+}
+
+// take returns the staged files of a service and their sorted paths
+func (s *staging) take(key string) (map[string][]byte, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make(map[string][]byte, len(s.files[key]))
+	paths := make([]string, 0, len(s.files[key]))
+	for p, data := range s.files[key] {
+		files[p] = data
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return files, paths
+}
+
+// clear unstages everything in a service after a successful apply, except
+// files staged again while it ran
+func (s *staging) clear(key string, applied map[string][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p, data := range applied {
+		if staged, ok := s.files[key][p]; ok && string(staged) == string(data) {
+			delete(s.files[key], p)
+		}
+	}
+	delete(s.dirs, key)
+}
+
+func (s *staging) setResults(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = data
+}
+
+func (s *staging) result(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.results[key]
+	return data, ok
+}
+
+// stagedProvider stages writes instead of sending them, so staged files can
+// be edited with the same write buffers as provider files
+type stagedProvider struct {
+	provider.Provider
+	staging *staging
+	key     string
+}
+
+func (p *stagedProvider) Write(ctx context.Context, path string, data []byte) error {
+	p.staging.put(p.key, path, data)
+	return nil
+}
+
+// stagingGetAttr returns the attributes of rest below a service's StagingDir.
+// Directories of the service exist in it too, so files can be copied in
+// without mkdir -p.
+func (f *SisuFS) stagingGetAttr(prov provider.Provider, key, rest string) (*fuse.Attr, fuse.Status) {
+	switch rest {
+	case "":
+		return f.newAttr(fuse.S_IFDIR|0755, 0, time.Time{}), fuse.OK
+	case ApplyFile:
+		return nil, fuse.ENOENT
+	case ResultsFile:
+		data, ok := f.staging.result(key)
+		if !ok {
+			return nil, fuse.ENOENT
+		}
+		return f.newAttr(fuse.S_IFREG|0444, int64(len(data)), time.Time{}), fuse.OK
+	}
+	if data, ok := f.staging.file(key, rest); ok {
+		return f.newAttr(fuse.S_IFREG|0644, int64(len(data)), time.Time{}), fuse.OK
+	}
+	if f.staging.isDir(key, rest) {
+		return f.newAttr(fuse.S_IFDIR|0755, 0, time.Time{}), fuse.OK
+	}
+	if entry, err := prov.Stat(context.Background(), rest); err == nil && entry.IsDir {
+		return f.newAttr(fuse.S_IFDIR|0755, 0, time.Time{}), fuse.OK
+	}
+	return nil, fuse.ENOENT
+}
+
+// stagingOpenDir lists what is staged directly below rest
+func (f *SisuFS) stagingOpenDir(key, rest string) ([]fuse.DirEntry, fuse.Status) {
+	files, dirs := f.staging.list(key, rest)
+	entries := make([]fuse.DirEntry, 0, len(files)+len(dirs)+1)
+	for _, name := range dirs {
+		entries = append(entries, fuse.DirEntry{Name: f.names.encode(name), Mode: fuse.S_IFDIR | 0755})
+	}
+	for _, name := range files {
+		entries = append(entries, fuse.DirEntry{Name: f.names.encode(name), Mode: fuse.S_IFREG | 0644})
+	}
+	if _, ok := f.staging.result(key); ok && rest == "" {
+		entries = append(entries, fuse.DirEntry{Name: ResultsFile, Mode: fuse.S_IFREG | 0444})
+	}
+	return entries, fuse.OK
+}
+
+// stagingOpen opens a staged file, or creates one with create set. Only
+// files the service would accept a write for can be staged.
+func (f *SisuFS) stagingOpen(prov provider.Provider, key, service, name, rest string, flags uint32, create bool) (nodefs.File, fuse.Status) {
+	write := create || flags&syscall.O_ACCMODE != syscall.O_RDONLY || flags&syscall.O_TRUNC != 0
+	switch rest {
+	case ApplyFile:
+		if !create && !write {
+			return nil, fuse.ENOENT
+		}
+		return &applyFile{File: nodefs.NewDefaultFile(), fs: f, prov: prov, key: key, service: service}, fuse.OK
+	case ResultsFile:
+		data, ok := f.staging.result(key)
+		if !ok {
+			return nil, fuse.ENOENT
+		}
+		if write {
+			return nil, fuse.EACCES
+		}
+		return &sisuFile{
+			File: nodefs.NewDefaultFile(),
+			data: data,
+			attr: f.newAttr(fuse.S_IFREG|0444, int64(len(data)), time.Time{}),
+		}, fuse.OK
+	case "":
+		return nil, fuse.EISDIR
+	}
+
+	data, staged := f.staging.file(key, rest)
+	if !write {
+		if !staged {
+			return nil, fuse.ENOENT
+		}
+		return &sisuFile{
+			File: nodefs.NewDefaultFile(),
+			data: data,
+			attr: f.newAttr(fuse.S_IFREG|0644, int64(len(data)), time.Time{}),
+		}, fuse.OK
+	}
+	if !f.writable(prov, service, rest, false) {
+		return nil, fuse.Status(syscall.EROFS)
+	}
+	if flags&syscall.O_TRUNC != 0 {
+		data = nil
+	}
+	sp := &stagedProvider{Provider: prov, staging: f.staging, key: key}
+	wf := f.newWriteableFile(sp, rest, name, data)
+	wf.dirty = flags&syscall.O_TRUNC != 0
+	return wf, fuse.OK
+}
+
+// stagingRename moves a staged file within the same StagingDir
+func (f *SisuFS) stagingRename(key, oldRest, newRest string) fuse.Status {
+	data, ok := f.staging.file(key, oldRest)
+	if !ok {
+		return fuse.ENOENT
+	}
+	if newRest == "" || newRest == ApplyFile || newRest == ResultsFile {
+		return fuse.EPERM
+	}
+	f.staging.put(key, newRest, data)
+	f.staging.remove(key, oldRest)
+	return fuse.OK
+}
+
+// stagingUnlink unstages a file; removing ResultsFile forgets the last apply
+func (f *SisuFS) stagingUnlink(key, rest string) fuse.Status {
+	if rest == ResultsFile {
+		f.staging.mu.Lock()
+		delete(f.staging.results, key)
+		f.staging.mu.Unlock()
+		return fuse.OK
+	}
+	if !f.staging.remove(key, rest) {
+		return fuse.ENOENT
+	}
+	return fuse.OK
+}
+
+// Outcomes of a staged file in ResultsFile
+const (
+	stagedApplied        = "applied"
+	stagedFailed         = "failed"
+	stagedRolledBack     = "rolled-back"
+	stagedRollbackFailed = "rollback-failed"
+	stagedNotApplied     = "not-applied"
+)
+
+// stagedResult is the outcome of applying one staged file
+type stagedResult struct {
+	path   string
+	status string
+	err    error
+}
+
+// applyStaged writes the staged files of the service at key (its
+// "profile/region/service" path) in path order. Every file is checked and
+// its current content read before anything is written; if a write fails,
+// the files written before it get their old content back, or are deleted
+// if they didn't exist. The outcome per file goes to ResultsFile.
+func (f *SisuFS) applyStaged(prov provider.Provider, key, service string) error {
+	f.staging.apply.Lock()
+	defer f.staging.apply.Unlock()
+
+	files, paths := f.staging.take(key)
+	results, err := f.writeStaged(prov, service, files, paths)
+
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "%s\t%s", r.status, r.path)
+		if r.err != nil {
+			fmt.Fprintf(&b, "\t%v", r.err)
+		}
+		b.WriteString("\n")
+		if r.status != stagedNotApplied {
+			f.notifyWritten(prov, key+"/"+f.names.encodePath(r.path), r.path)
+		}
+	}
+	f.staging.setResults(key, []byte(b.String()))
+
+	if err != nil {
+		return err
+	}
+	f.staging.clear(key, files)
+	return nil
+}
+
+func (f *SisuFS) writeStaged(prov provider.Provider, service string, files map[string][]byte, paths []string) ([]stagedResult, error) {
+	ctx := context.Background()
+	results := make([]stagedResult, len(paths))
+	for i, p := range paths {
+		results[i] = stagedResult{path: p, status: stagedNotApplied}
+	}
+
+	// Check everything before the first write
+	previous := make([][]byte, len(paths)) // nil if the file didn't exist
+	var failed error
+	for i, p := range paths {
+		var err error
+		if !f.writable(prov, service, p, false) {
+			err = os.ErrPermission
+		} else if previous[i], err = prov.Read(ctx, p); errors.Is(err, os.ErrNotExist) {
+			previous[i], err = nil, nil
+		}
+		if err != nil {
+			results[i].status, results[i].err = stagedFailed, err
+			failed = fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	if failed != nil {
+		return results, failed
+	}
+
+	for i, p := range paths {
+		err := prov.Write(ctx, p, files[p])
+		if err == nil {
+			results[i].status = stagedApplied
+			continue
+		}
+		results[i].status, results[i].err = stagedFailed, err
+		for j := i - 1; j >= 0; j-- {
+			results[j].status = stagedRolledBack
+			if previous[j] == nil {
+				results[j].err = prov.Delete(ctx, paths[j])
+			} else {
+				results[j].err = prov.Write(ctx, paths[j], previous[j])
+			}
+			if results[j].err != nil {
+				results[j].status = stagedRollbackFailed
+				log.Printf("[fs] rolling back %s: %v", paths[j], results[j].err)
+			}
+		}
+		return results, fmt.Errorf("%s: %w", p, err)
+	}
+	return results, nil
+}
+
+// applyFile applies the staged files of a service when closed
+type applyFile struct {
+	nodefs.File
+	fs      *SisuFS
+	prov    provider.Provider
+	key     string
+	service string
+	mu      sync.Mutex
+	done    bool
+}
+
+func (f *applyFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	return uint32(len(data)), fuse.OK
+}
+
+// Flush applies on the first close only, since a file can be closed once
+// per duplicated descriptor
+func (f *applyFile) Flush() fuse.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done {
+		return fuse.OK
+	}
+	f.done = true
+	if err := f.fs.applyStaged(f.prov, f.key, f.service); err != nil {
+		log.Printf("[fs] %s/%s/%s: %v", f.key, StagingDir, ApplyFile, err)
+		return errStatus(err, fuse.EIO)
+	}
+	return fuse.OK
+}
+
+func (f *applyFile) GetAttr(out *fuse.Attr) fuse.Status {
+	*out = *f.fs.newAttr(fuse.S_IFREG|0644, 0, time.Now())
+	return fuse.OK
+}
+
+func (f *applyFile) Truncate(size uint64) fuse.Status { return fuse.OK }
+
+// Utimens lets touch create the file
+func (f *applyFile) Utimens(atime *time.Time, mtime *time.Time) fuse.Status { return fuse.OK }
@@ -0,0 +1,227 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// memProvider keeps files in memory and fails writes to the paths in failWrites
+type memProvider struct {
+	mu         sync.Mutex
+	files      map[string]string
+	failWrites map[string]bool
+}
+
+func (p *memProvider) Name() string { return "mem" }
+
+func (p *memProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	return nil, nil
+}
+
+func (p *memProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, ok := p.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (p *memProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if data, ok := p.files[path]; ok {
+		return &provider.Entry{Name: path, Size: int64(len(data))}, nil
+	}
+	for name := range p.files {
+		if strings.HasPrefix(name, path+"/") {
+			return &provider.Entry{Name: path, IsDir: true}, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (p *memProvider) Write(ctx context.Context, path string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failWrites[path] {
+		return errors.New("ParameterLimitExceeded")
+	}
+	p.files[path] = string(data)
+	return nil
+}
+
+func (p *memProvider) Delete(ctx context.Context, path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.files, path)
+	return nil
+}
+
+func (p *memProvider) Writable(path string) bool { return true }
+
+func stageFile(t *testing.T, f *SisuFS, name, content string) {
+	t.Helper()
+	file, status := f.Create(name, uint32(syscall.O_WRONLY|syscall.O_TRUNC), 0644, nil)
+	if !status.Ok() {
+		t.Fatalf("create %s: %v", name, status)
+	}
+	file.Write([]byte(content), 0)
+	if status := file.Flush(); !status.Ok() {
+		t.Fatalf("close %s: %v", name, status)
+	}
+	file.Release()
+}
+
+func applyStaging(f *SisuFS, dir string) fuse.Status {
+	file, status := f.Create(dir+"/"+StagingDir+"/"+ApplyFile, uint32(syscall.O_WRONLY|syscall.O_CREAT), 0644, nil)
+	if !status.Ok() {
+		return status
+	}
+	return file.Flush()
+}
+
+func TestStagingApply(t *testing.T) {
+	prov := &memProvider{files: map[string]string{"app/db/host": "old-host"}}
+	f := &SisuFS{
+		providers:    map[string]provider.Provider{"prod/us-east-1/ssm": prov},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		staging:      newStaging(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+	}
+	dir := "prod/us-east-1/ssm"
+
+	// Directories of the service exist in the staging area
+	if attr, status := f.GetAttr(dir+"/"+StagingDir+"/app/db", nil); !status.Ok() || !attr.IsDir() {
+		t.Fatalf("GetAttr of staged directory: %v", status)
+	}
+
+	stageFile(t, f, dir+"/"+StagingDir+"/app/db/host", "new-host")
+	stageFile(t, f, dir+"/"+StagingDir+"/app/db/port", "5432")
+	if prov.files["app/db/host"] != "old-host" {
+		t.Fatal("staging a file wrote it")
+	}
+	entries, _ := f.OpenDir(dir+"/"+StagingDir+"/app/db", nil)
+	if len(entries) != 2 {
+		t.Errorf("staged entries = %v", entries)
+	}
+
+	if status := applyStaging(f, dir); !status.Ok() {
+		t.Fatalf("apply: %v", status)
+	}
+	if prov.files["app/db/host"] != "new-host" || prov.files["app/db/port"] != "5432" {
+		t.Errorf("files after apply = %v", prov.files)
+	}
+	results, _ := f.staging.result(dir)
+	if want := "applied\tapp/db/host\napplied\tapp/db/port\n"; string(results) != want {
+		t.Errorf("results = %q, want %q", results, want)
+	}
+	if _, status := f.GetAttr(dir+"/"+StagingDir+"/app/db/port", nil); status != fuse.ENOENT {
+		t.Errorf("staged file after apply: %v, want ENOENT", status)
+	}
+}
+
+func TestStagingRollsBackOnFailure(t *testing.T) {
+	prov := &memProvider{
+		files:      map[string]string{"app/a": "old-a"},
+		failWrites: map[string]bool{"app/c": true},
+	}
+	f := &SisuFS{
+		providers:    map[string]provider.Provider{"prod/us-east-1/ssm": prov},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		staging:      newStaging(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+	}
+	dir := "prod/us-east-1/ssm"
+	for _, name := range []string{"a", "b", "c", "d"} {
+		stageFile(t, f, dir+"/"+StagingDir+"/app/"+name, "new-"+name)
+	}
+
+	if status := applyStaging(f, dir); status.Ok() {
+		t.Fatal("apply with a failing write succeeded")
+	}
+	if len(prov.files) != 1 || prov.files["app/a"] != "old-a" {
+		t.Errorf("files after rollback = %v", prov.files)
+	}
+	results, _ := f.staging.result(dir)
+	want := "rolled-back\tapp/a\nrolled-back\tapp/b\nfailed\tapp/c\tParameterLimitExceeded\nnot-applied\tapp/d\n"
+	if string(results) != want {
+		t.Errorf("results = %q, want %q", results, want)
+	}
+	if _, ok := f.staging.file(dir, "app/d"); !ok {
+		t.Error("staged files were dropped after a failed apply")
+	}
+
+	// Fixed, the retry applies everything still staged
+	delete(prov.failWrites, "app/c")
+	if status := applyStaging(f, dir); !status.Ok() {
+		t.Fatalf("retry: %v", status)
+	}
+	if len(prov.files) != 4 || prov.files["app/a"] != "new-a" {
+		t.Errorf("files after retry = %v", prov.files)
+	}
+}
+
+func TestStagingMovesStayInside(t *testing.T) {
+	prov := &memProvider{files: map[string]string{"app/a": "a"}}
+	f := &SisuFS{
+		providers:    map[string]provider.Provider{"prod/us-east-1/ssm": prov},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		staging:      newStaging(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+		protection:   newProtection(nil),
+	}
+	dir := "prod/us-east-1/ssm"
+	stageFile(t, f, dir+"/"+StagingDir+"/app/b", "b")
+
+	if status := f.Rename(dir+"/app/a", dir+"/"+StagingDir+"/app/a", nil); status != fuse.EPERM {
+		t.Errorf("moving a file into the staging area: %v, want EPERM", status)
+	}
+	if status := f.Rename(dir+"/"+StagingDir+"/app/b", dir+"/"+StagingDir+"/app/c", nil); !status.Ok() {
+		t.Errorf("moving a staged file: %v", status)
+	}
+	if status := f.Unlink(dir+"/"+StagingDir+"/app/c", nil); !status.Ok() {
+		t.Errorf("unstaging: %v", status)
+	}
+	if _, ok := prov.files["app/a"]; !ok {
+		t.Error("the original file was touched")
+	}
+}
+
+func TestServiceDirModes(t *testing.T) {
+	f := &SisuFS{
+		profiles:  []string{"prod"},
+		config:    Config{Regions: []string{"us-east-1"}},
+		providers: map[string]provider.Provider{"prod/us-east-1/ssm": &memProvider{}},
+		creating:  make(map[string]*sync.Mutex),
+		dirTimes:  newDirTimes(),
+	}
+	attr, status := f.GetAttr("prod/us-east-1/ssm", nil)
+	if !status.Ok() {
+		t.Fatal(status)
+	}
+	entries, _ := f.OpenDir("prod/us-east-1", nil)
+	for _, e := range entries {
+		if e.Name == "ssm" && e.Mode != attr.Mode {
+			t.Errorf("listed mode %o, GetAttr mode %o", e.Mode, attr.Mode)
+		}
+		if e.Name == "ec2" && e.Mode != fuse.S_IFDIR|0555 {
+			t.Errorf("ec2 listed with mode %o, want read-only", e.Mode)
+		}
+	}
+	if attr.Mode != fuse.S_IFDIR|0755 {
+		t.Errorf("ssm mode %o, want writable", attr.Mode)
+	}
+}
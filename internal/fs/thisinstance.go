@@ -0,0 +1,57 @@
+package fs
+
+import (
+	"context"
+	"time"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// ThisInstance is the symlink in the region directory of the EC2 instance
+// sisu runs on, pointing at the instance's directory under ec2/
+const ThisInstance = "this-instance"
+
+// imdsTimeout bounds the instance metadata lookup, which only answers on EC2
+const imdsTimeout = time.Second
+
+// thisInstance is the result of looking up the current instance, started
+// at mount so that it's usually known by the time a region is listed
+type thisInstance struct {
+	done     chan struct{}
+	identity *provider.InstanceIdentity // nil when not on EC2
+}
+
+func lookupThisInstance(lookup func(ctx context.Context) (*provider.InstanceIdentity, error)) *thisInstance {
+	t := &thisInstance{done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		ctx, cancel := context.WithTimeout(context.Background(), imdsTimeout)
+		defer cancel()
+		if id, err := lookup(ctx); err == nil {
+			t.identity = id
+		}
+	}()
+	return t
+}
+
+// target returns where ThisInstance in region points, or false if sisu
+// doesn't run on an instance in that region. account returns the account
+// of the profile listing the region: the instance is only in the tree of
+// profiles of its own account, not in every profile's region of the same
+// name.
+func (t *thisInstance) target(region string, account func() string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	<-t.done
+	if t.identity == nil || t.identity.Region != region || account() != t.identity.AccountID {
+		return "", false
+	}
+	return "ec2/" + t.identity.InstanceID, true
+}
+
+// thisInstanceTarget returns where ThisInstance in the region directory of
+// profile points, or false if it has no such link
+func (f *SisuFS) thisInstanceTarget(profile, region string) (string, bool) {
+	return f.instance.target(region, func() string { return f.accountOf(profile) })
+}
@@ -0,0 +1,136 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+func TestThisInstanceLink(t *testing.T) {
+	f := &SisuFS{
+		profiles: []string{"prod", "dev"},
+		config:   Config{Regions: []string{"us-east-1", "eu-west-1"}},
+		// The listing makes the providers of its services, which fail
+		// without AWS profiles
+		providers: map[string]provider.Provider{},
+		creating:  make(map[string]*sync.Mutex),
+		instance: lookupThisInstance(func(ctx context.Context) (*provider.InstanceIdentity, error) {
+			return &provider.InstanceIdentity{InstanceID: "i-0abc", Region: "eu-west-1", AccountID: "123456789012"}, nil
+		}),
+		identities: newIdentities(func(ctx context.Context, profile string) (string, error) {
+			if profile == "dev" {
+				return "arn:aws:sts::210987654321:assumed-role/dev/me", nil
+			}
+			return "arn:aws:sts::123456789012:assumed-role/prod/me", nil
+		}),
+	}
+
+	entries, _ := f.OpenDir("prod/eu-west-1", nil)
+	if last := entries[len(entries)-1]; last.Name != ThisInstance || last.Mode&fuse.S_IFLNK != fuse.S_IFLNK {
+		t.Errorf("region listing ends with %+v, want the %s link", last, ThisInstance)
+	}
+	if attr, status := f.GetAttr("prod/eu-west-1/"+ThisInstance, nil); !status.Ok() || !attr.IsSymlink() {
+		t.Errorf("GetAttr = %v, %v", attr, status)
+	}
+	if target, status := f.Readlink("prod/eu-west-1/"+ThisInstance, nil); target != "ec2/i-0abc" || !status.Ok() {
+		t.Errorf("Readlink = %q, %v", target, status)
+	}
+
+	// Other regions don't have the link
+	entries, _ = f.OpenDir("prod/us-east-1", nil)
+	for _, e := range entries {
+		if e.Name == ThisInstance {
+			t.Error("link listed in another region")
+		}
+	}
+	if _, status := f.Readlink("prod/us-east-1/"+ThisInstance, nil); status != fuse.ENOENT {
+		t.Errorf("Readlink in another region: %v, want ENOENT", status)
+	}
+
+	// Nor do profiles of other accounts
+	if _, status := f.Readlink("dev/eu-west-1/"+ThisInstance, nil); status != fuse.ENOENT {
+		t.Errorf("Readlink in another account: %v, want ENOENT", status)
+	}
+	entries, _ = f.OpenDir("dev/eu-west-1", nil)
+	for _, e := range entries {
+		if e.Name == ThisInstance {
+			t.Error("link listed in another account")
+		}
+	}
+}
+
+func TestThisInstanceOffEC2(t *testing.T) {
+	f := &SisuFS{
+		profiles: []string{"prod"},
+		config:   Config{Regions: []string{"us-east-1"}},
+		instance: lookupThisInstance(func(ctx context.Context) (*provider.InstanceIdentity, error) {
+			return nil, errors.New("no IMDS")
+		}),
+	}
+	if _, status := f.GetAttr("prod/us-east-1/"+ThisInstance, nil); status != fuse.ENOENT {
+		t.Errorf("GetAttr off EC2: %v, want ENOENT", status)
+	}
+}
+
+// linkProvider lists a single symlink, consumers/queue, as the topology
+// service does
+type linkProvider struct {
+	provider.ReadOnlyProvider
+}
+
+func (p *linkProvider) Name() string { return "links" }
+
+func (p *linkProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	return []provider.Entry{{Name: "queue", Size: 16, Link: "../queues/orders"}}, nil
+}
+
+func (p *linkProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (p *linkProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	if path != "consumers/queue" {
+		return &provider.Entry{Name: path, IsDir: true}, nil
+	}
+	return &provider.Entry{Name: "queue", Size: 16, Link: "../queues/orders"}, nil
+}
+
+func TestProviderLinks(t *testing.T) {
+	f := &SisuFS{
+		profiles:     []string{"prod"},
+		providers:    map[string]provider.Provider{"prod/us-east-1/topology": &linkProvider{}},
+		names:        newNameCodec(),
+		dirTimes:     newDirTimes(),
+		lookups:      newLookups(),
+		pendingFiles: make(map[string]*writeableSisuFile),
+	}
+	name := "prod/us-east-1/topology/consumers/queue"
+
+	entries, _ := f.OpenDir("prod/us-east-1/topology/consumers", nil)
+	if len(entries) != 1 || entries[0].Mode&fuse.S_IFLNK != fuse.S_IFLNK {
+		t.Errorf("listing = %+v, want one link", entries)
+	}
+	if attr, status := f.GetAttr(name, nil); !status.Ok() || !attr.IsSymlink() || attr.Size != 16 {
+		t.Errorf("GetAttr = %v, %v", attr, status)
+	}
+	if target, status := f.Readlink(name, nil); target != "../queues/orders" || !status.Ok() {
+		t.Errorf("Readlink = %q, %v", target, status)
+	}
+	if _, status := f.Readlink("prod/us-east-1/topology/consumers", nil); status != fuse.EINVAL {
+		t.Errorf("Readlink of a directory: %v, want EINVAL", status)
+	}
+	if listed, err := f.List("prod/us-east-1/topology/consumers"); err != nil || len(listed) != 1 || listed[0].Link != "../queues/orders" {
+		t.Errorf("List = %+v, %v, want the link with its target", listed, err)
+	}
+
+	f.config.Root = "prod/us-east-1"
+	if target, status := f.mountedFS().Readlink("topology/consumers/queue", nil); target != "../queues/orders" || !status.Ok() {
+		t.Errorf("Readlink below the root = %q, %v", target, status)
+	}
+}
@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// The methods in this file give commands such as browse and bulk the same
+// view of the tree as the mount, including name escaping, caching and
+// writability, without mounting it. Paths are relative to the mount root.
+
+// List returns the entries of a directory, symlinks with their Link set
+func (f *SisuFS) List(dir string) ([]provider.Entry, error) {
+	entries, status := f.OpenDir(dir, nil)
+	if !status.Ok() {
+		return nil, syscall.Errno(status)
+	}
+	out := make([]provider.Entry, len(entries))
+	for i, e := range entries {
+		out[i] = provider.Entry{Name: e.Name, IsDir: e.Mode&fuse.S_IFDIR != 0}
+		// Links keep their target, so walkers can record them rather
+		// than read through them
+		if e.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+			if target, status := f.Readlink(joinPath(dir, e.Name), nil); status.Ok() {
+				out[i].Link = target
+			}
+		}
+	}
+	return out, nil
+}
+
+// ReadFile returns the content of a file, or its first limit bytes if limit > 0.
+// Whole reads of files over the read limit fail with a TooLargeError.
+func (f *SisuFS) ReadFile(name string, limit int) ([]byte, error) {
+	file, status := f.Open(name, uint32(syscall.O_RDONLY), nil)
+	if !status.Ok() {
+		return nil, syscall.Errno(status)
+	}
+	defer file.Release()
+
+	var attr fuse.Attr
+	file.GetAttr(&attr)
+	size := int(attr.Size)
+	if limit > 0 && limit < size {
+		size = limit
+	} else if err := f.checkReadSize(name, int64(size)); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	n := 0
+	for n < size {
+		res, status := file.Read(buf[n:], int64(n))
+		if !status.Ok() {
+			return nil, syscall.Errno(status)
+		}
+		chunk, _ := res.Bytes(buf[n:])
+		if len(chunk) == 0 {
+			break
+		}
+		n += copy(buf[n:], chunk)
+	}
+	return buf[:n], nil
+}
+
+// WriteFile replaces the content of a file, creating it if needed
+func (f *SisuFS) WriteFile(name string, data []byte) error {
+	file, status := f.Open(name, uint32(syscall.O_WRONLY|syscall.O_TRUNC), nil)
+	if !status.Ok() {
+		return syscall.Errno(status)
+	}
+	defer file.Release()
+
+	if len(data) > 0 {
+		if _, status := file.Write(data, 0); !status.Ok() {
+			return syscall.Errno(status)
+		}
+	}
+	if status := file.Flush(); !status.Ok() {
+		return syscall.Errno(status)
+	}
+	return nil
+}
+
+// Remove deletes a file
+func (f *SisuFS) Remove(name string) error {
+	if status := f.Unlink(name, nil); !status.Ok() {
+		return syscall.Errno(status)
+	}
+	return nil
+}
+
+// RemoveKey deletes the file for a resource name below dir, such as an S3
+// key below a bucket's directory, escaping it as the mount lists it
+func (f *SisuFS) RemoveKey(dir, key string) error {
+	return f.Remove(dir + "/" + f.names.encodePath(key))
+}
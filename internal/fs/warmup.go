@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWarmUpParallel is how many warm-up listings run at once by default
+const defaultWarmUpParallel = 4
+
+// WarmUpProgress is the state of the warm-up as shown in stats.json
+type WarmUpProgress struct {
+	Total    int       `json:"total"`  // service directories to list
+	Done     int       `json:"done"`   // listed so far, including failures
+	Failed   int       `json:"failed"` // listings that failed
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+// warmUp tracks the progress of WarmUp
+type warmUp struct {
+	mu       sync.Mutex
+	progress *WarmUpProgress
+}
+
+func (w *warmUp) snapshot() *WarmUpProgress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.progress == nil {
+		return nil
+	}
+	p := *w.progress
+	return &p
+}
+
+func (w *warmUp) update(fn func(p *WarmUpProgress)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fn(w.progress)
+}
+
+// WarmUp lists the configured services in every region of the configured
+// profiles, a few at a time, so their listings are cached before the first
+// interactive ls. It returns when all are listed or ctx is done.
+func (f *SisuFS) WarmUp(ctx context.Context) {
+	cfg := f.config.WarmUp
+	if len(cfg.Services) == 0 {
+		return
+	}
+	dirs := f.warmUpDirs()
+	f.warmUp.mu.Lock()
+	f.warmUp.progress = &WarmUpProgress{Total: len(dirs), Started: time.Now()}
+	f.warmUp.mu.Unlock()
+
+	parallel := cfg.Parallel
+	if parallel <= 0 {
+		parallel = defaultWarmUpParallel
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for _, dir := range dirs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, err := f.List(dir)
+			if err != nil && Debug {
+				log.Printf("[fs] warm-up: %s: %v", dir, err)
+			}
+			f.warmUp.update(func(p *WarmUpProgress) {
+				p.Done++
+				if err != nil {
+					p.Failed++
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	f.warmUp.update(func(p *WarmUpProgress) { p.Finished = time.Now() })
+}
+
+// warmUpDirs returns the service directories to warm up that the mount
+// serves
+func (f *SisuFS) warmUpDirs() []string {
+	cfg := f.config.WarmUp
+	var dirs []string
+	for _, profile := range f.profiles {
+		if len(cfg.Profiles) > 0 && !slices.ContainsFunc(cfg.Profiles, func(g string) bool {
+			ok, _ := filepath.Match(g, profile)
+			return ok
+		}) {
+			continue
+		}
+		regions, err := f.List(profile)
+		if err != nil {
+			continue
+		}
+		for _, region := range regions {
+			if !region.IsDir {
+				continue
+			}
+			services, err := f.List(profile + "/" + region.Name)
+			if err != nil {
+				continue
+			}
+			for _, service := range services {
+				dir := profile + "/" + region.Name + "/" + service.Name
+				if slices.Contains(cfg.Services, service.Name) && f.inRoot(dir) {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+	}
+	return dirs
+}
+
+// inRoot reports whether dir is in the subtree served by the mount
+func (f *SisuFS) inRoot(dir string) bool {
+	root := f.config.Root
+	return root == "" || dir == root || strings.HasPrefix(dir, root+"/")
+}
@@ -0,0 +1,74 @@
+package fs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/semonte/sisu/internal/config"
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// listProvider records the directories listed
+type listProvider struct {
+	provider.ReadOnlyProvider
+	mu     *sync.Mutex
+	listed *[]string
+	key    string
+}
+
+func (p *listProvider) Name() string { return "list" }
+
+func (p *listProvider) ReadDir(ctx context.Context, path string) ([]provider.Entry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*p.listed = append(*p.listed, p.key)
+	return []provider.Entry{{Name: "fn", IsDir: true}}, nil
+}
+
+func (p *listProvider) Read(ctx context.Context, path string) ([]byte, error) { return nil, nil }
+
+func (p *listProvider) Stat(ctx context.Context, path string) (*provider.Entry, error) {
+	return &provider.Entry{Name: path, IsDir: true}, nil
+}
+
+func TestWarmUp(t *testing.T) {
+	var listed []string
+	var mu sync.Mutex
+	providers := make(map[string]provider.Provider)
+	for _, key := range []string{"prod/us-east-1/lambda", "prod/eu-west-1/lambda", "dev/us-east-1/lambda"} {
+		providers[key] = &listProvider{mu: &mu, listed: &listed, key: key}
+	}
+	f := &SisuFS{
+		config: Config{
+			Regions: []string{"us-east-1", "eu-west-1"},
+			WarmUp:  config.WarmUp{Services: []string{"lambda"}, Profiles: []string{"prod"}},
+		},
+		profiles:  []string{"dev", "prod"},
+		providers: providers,
+		creating:  make(map[string]*sync.Mutex),
+		names:     newNameCodec(),
+		lookups:   newLookups(),
+		dirTimes:  newDirTimes(),
+	}
+
+	f.WarmUp(context.Background())
+
+	sort.Strings(listed)
+	if want := []string{"prod/eu-west-1/lambda", "prod/us-east-1/lambda"}; len(listed) != 2 || listed[0] != want[0] || listed[1] != want[1] {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+	p := f.Stats().WarmUp
+	if p == nil || p.Total != 2 || p.Done != 2 || p.Failed != 0 || p.Finished.IsZero() {
+		t.Errorf("progress = %+v", p)
+	}
+}
+
+func TestWarmUpDisabled(t *testing.T) {
+	f := &SisuFS{}
+	f.WarmUp(context.Background())
+	if p := f.Stats().WarmUp; p != nil {
+		t.Errorf("progress = %+v without warm-up configured", p)
+	}
+}
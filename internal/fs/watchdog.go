@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/pprof"
+	"syscall"
+	"time"
+)
+
+// errWedged reports a mount that didn't answer a stat within the timeout
+var errWedged = errors.New("mount did not respond")
+
+// Watchdog keeps a long-lived mount usable. It periodically stats the
+// mountpoint through the kernel, the way any other process would, and if
+// the mount is dead (ENOTCONN) or hangs it records diagnostics and calls
+// Remount.
+type Watchdog struct {
+	Mountpoint string
+	Interval   time.Duration
+	Timeout    time.Duration
+	// Remount lazily unmounts the wedged mount and mounts it again
+	Remount func() error
+	// Diagnostics receives a report, including a goroutine dump, for every
+	// failed check; nil discards them
+	Diagnostics io.Writer
+
+	check func() error // replaced in tests
+}
+
+// Run checks the mount every Interval until ctx is done
+func (w *Watchdog) Run(ctx context.Context) {
+	check := w.check
+	if check == nil {
+		check = func() error { return statMount(w.Mountpoint, w.Timeout) }
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := check()
+		if err == nil {
+			continue
+		}
+		w.report(err)
+		if err := w.Remount(); err != nil {
+			log.Printf("[watchdog] remounting %s failed: %v", w.Mountpoint, err)
+			continue
+		}
+		log.Printf("[watchdog] %s was unresponsive (%v) and has been remounted; run 'cd .' in open shells", w.Mountpoint, err)
+	}
+}
+
+// report writes what went wrong and the state of every goroutine, which
+// shows which FUSE request the server was stuck on
+func (w *Watchdog) report(err error) {
+	if w.Diagnostics == nil {
+		return
+	}
+	fmt.Fprintf(w.Diagnostics, "=== %s: %s: %v\n", time.Now().Format(time.RFC3339), w.Mountpoint, err)
+	pprof.Lookup("goroutine").WriteTo(w.Diagnostics, 1)
+	fmt.Fprintln(w.Diagnostics)
+}
+
+// statMount stats mountpoint, treating ENOTCONN and no answer within
+// timeout as a wedged mount. Other errors (e.g. the mountpoint was removed)
+// aren't something a remount fixes. A stat that never returns leaves its
+// goroutine behind, but the lazy unmount releases it.
+func statMount(mountpoint string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(mountpoint)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, syscall.ENOTCONN) {
+			return err
+		}
+		return nil
+	case <-time.After(timeout):
+		return errWedged
+	}
+}
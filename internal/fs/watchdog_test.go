@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdogRemountsWedgedMount(t *testing.T) {
+	var mu sync.Mutex
+	checks, remounts := 0, 0
+	var diag bytes.Buffer
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Watchdog{
+		Mountpoint:  "/mnt/sisu",
+		Interval:    time.Millisecond,
+		Diagnostics: &diag,
+		Remount: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			remounts++
+			return nil
+		},
+		check: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			checks++
+			if checks == 2 {
+				return errWedged
+			}
+			if checks == 5 {
+				cancel()
+			}
+			return nil
+		},
+	}
+	w.Run(ctx)
+
+	if remounts != 1 {
+		t.Errorf("remounted %d times, want 1", remounts)
+	}
+	if !strings.Contains(diag.String(), "/mnt/sisu: mount did not respond") || !strings.Contains(diag.String(), "goroutine") {
+		t.Errorf("diagnostics = %q", diag.String())
+	}
+}
+
+func TestStatMountHealthy(t *testing.T) {
+	if err := statMount(t.TempDir(), time.Second); err != nil {
+		t.Errorf("statMount = %v", err)
+	}
+}
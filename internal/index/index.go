@@ -0,0 +1,176 @@
+// Package index keeps a local index of resource names, ARNs and tags,
+// built by walking the tree in the background, so searches don't have to
+// list every service in every region.
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one indexed file or directory
+type Record struct {
+	// Path is relative to the mount root, e.g. "prod/us-east-1/ec2/i-0abc"
+	Path string            `json:"path"`
+	ARN  string            `json:"arn,omitempty"`
+	Tags map[string]string `json:"tags,omitempty"`
+	// Dir is set for directories, which is how resources like instances,
+	// functions and roles appear
+	Dir bool `json:"dir,omitempty"`
+}
+
+// Name returns the last element of the record's path
+func (r Record) Name() string {
+	return path.Base(r.Path)
+}
+
+// Profile returns the profile directory the record is in, e.g. "prod"
+func (r Record) Profile() string {
+	profile, _, _ := strings.Cut(r.Path, "/")
+	return profile
+}
+
+// Service returns the service directory the record is in, e.g. "ec2"
+func (r Record) Service() string {
+	parts := strings.SplitN(r.Path, "/", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// Region returns the region directory the record is in, or "global"
+func (r Record) Region() string {
+	parts := strings.SplitN(r.Path, "/", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Index is a set of records persisted as JSON. It is safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	records map[string]Record
+	updated map[string]time.Time // when each walked root was last indexed
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{records: make(map[string]Record), updated: make(map[string]time.Time)}
+}
+
+// DefaultPath returns the default index location
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sisu", "index.json")
+}
+
+// indexFile is the on-disk form of an Index
+type indexFile struct {
+	Updated map[string]time.Time `json:"updated"`
+	Records []Record             `json:"records"`
+}
+
+// Load reads the index at file. A missing file yields an empty index.
+func Load(file string) (*Index, error) {
+	idx := New()
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	for _, r := range f.Records {
+		idx.records[r.Path] = r
+	}
+	for root, t := range f.Updated {
+		idx.updated[root] = t
+	}
+	return idx, nil
+}
+
+// Save writes the index to file, replacing it atomically
+func (idx *Index) Save(file string) error {
+	idx.mu.RLock()
+	f := indexFile{Updated: idx.updated, Records: make([]Record, 0, len(idx.records))}
+	for _, r := range idx.records {
+		f.Records = append(f.Records, r)
+	}
+	sort.Slice(f.Records, func(i, j int) bool { return f.Records[i].Path < f.Records[j].Path })
+	data, err := json.Marshal(f)
+	idx.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Replace swaps every record below root for records, as found by a fresh
+// walk, so resources that disappeared drop out of the index
+func (idx *Index) Replace(root string, records []Record) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for p := range idx.records {
+		if p == root || strings.HasPrefix(p, root+"/") {
+			delete(idx.records, p)
+		}
+	}
+	for _, r := range records {
+		idx.records[r.Path] = r
+	}
+	idx.updated[root] = time.Now()
+}
+
+// Updated returns when root was last indexed, or the zero time
+func (idx *Index) Updated(root string) time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.updated[root]
+}
+
+// Len returns the number of records
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.records)
+}
+
+// Search returns the records matching q, sorted by path
+func (idx *Index) Search(q Query) []Record {
+	idx.mu.RLock()
+	var matches []Record
+	for _, r := range idx.records {
+		if q.Match(r) {
+			matches = append(matches, r)
+		}
+	}
+	idx.mu.RUnlock()
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+	return matches
+}
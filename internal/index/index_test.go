@@ -0,0 +1,178 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/semonte/sisu/internal/provider"
+)
+
+// mapTree is a Tree of files keyed by path; directories are implied
+type mapTree map[string]string
+
+func (t mapTree) List(dir string) ([]provider.Entry, error) {
+	seen := make(map[string]bool)
+	var entries []provider.Entry
+	for name := range t {
+		rel, ok := strings.CutPrefix(name, dir+"/")
+		if !ok {
+			continue
+		}
+		child, _, nested := strings.Cut(rel, "/")
+		if !seen[child] {
+			seen[child] = true
+			entries = append(entries, provider.Entry{Name: child, IsDir: nested})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return entries, nil
+}
+
+func (t mapTree) ReadFile(name string, limit int) ([]byte, error) {
+	data, ok := t[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if len(data) > limit {
+		return nil, errors.New("too big")
+	}
+	return []byte(data), nil
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery("name~web* type:ec2 region:all tag:Environment=prod Team= nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Query{
+		Name:    "web*",
+		Words:   []string{"nginx"},
+		Service: "ec2",
+		Region:  "all",
+		Tags:    map[string]string{"Environment": "prod", "Team": ""},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("ParseQuery = %+v, want %+v", q, want)
+	}
+
+	if _, err := ParseQuery("name~[web"); err == nil {
+		t.Error("expected an error for a malformed glob")
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	web := Record{
+		Path: "prod/us-east-1/ec2/web-1",
+		ARN:  "arn:aws:ec2:us-east-1:123456789012:instance/i-0abc",
+		Tags: map[string]string{"Environment": "prod", "Team": "edge"},
+		Dir:  true,
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"name~web*", true},
+		{"name~db*", false},
+		{"type:ec2 region:us-east-1", true},
+		{"type:lambda", false},
+		{"profile:prod", true},
+		{"profile:dev", false},
+		{"region:all", true},
+		{"region:eu-west-1", false},
+		{"Environment=prod Team=", true},
+		{"Environment=staging", false},
+		{"Owner=", false},
+		{"I-0ABC", true},
+		{"i-0abc other", false},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Match(web); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceAndSave(t *testing.T) {
+	idx := New()
+	idx.Replace("prod/us-east-1/ec2", []Record{
+		{Path: "prod/us-east-1/ec2/web-1", Dir: true},
+		{Path: "prod/us-east-1/ec2/web-2", Dir: true},
+	})
+	idx.Replace("prod/us-east-1/lambda", []Record{{Path: "prod/us-east-1/lambda/api", Dir: true}})
+
+	// A fresh walk drops what disappeared without touching other roots
+	idx.Replace("prod/us-east-1/ec2", []Record{{Path: "prod/us-east-1/ec2/web-2", Dir: true}})
+	if idx.Len() != 2 {
+		t.Errorf("Len = %d, want 2", idx.Len())
+	}
+
+	file := filepath.Join(t.TempDir(), "index.json")
+	if err := idx.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.Search(Query{})
+	if len(got) != 2 || got[0].Path != "prod/us-east-1/ec2/web-2" || got[1].Path != "prod/us-east-1/lambda/api" {
+		t.Errorf("loaded records = %+v", got)
+	}
+	if loaded.Updated("prod/us-east-1/ec2").IsZero() {
+		t.Error("walk times were not saved")
+	}
+
+	empty, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || empty.Len() != 0 {
+		t.Errorf("Load of a missing file = %v, %v", empty, err)
+	}
+}
+
+func TestIndexerWalk(t *testing.T) {
+	tree := mapTree{
+		"prod/us-east-1/lambda/api/config.json": `{"FunctionName":"api","FunctionArn":"arn:aws:lambda:us-east-1:1:function:api","Role":"arn:aws:iam::1:role/api","Tags":{"Environment":"prod"}}`,
+		"prod/us-east-1/lambda/api/env.json":    `{}`,
+		"prod/us-east-1/ec2/web-1/info.json":    `{"InstanceId":"i-1","Tags":[{"Key":"Environment","Value":"prod"},{"Key":"Name","Value":"web-1"}]}`,
+		"prod/us-east-1/ec2/web-2/info.json":    `not json`,
+	}
+	idx := New()
+	file := filepath.Join(t.TempDir(), "index.json")
+	ix := &Indexer{Tree: tree, Index: idx, File: file, Rate: 1000}
+	ctx := context.Background()
+	for _, root := range []string{"prod/us-east-1/lambda", "prod/us-east-1/ec2"} {
+		if err := ix.Walk(ctx, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var paths []string
+	for _, r := range idx.Search(Query{Tags: map[string]string{"Environment": "prod"}}) {
+		paths = append(paths, r.Path)
+	}
+	if want := []string{"prod/us-east-1/ec2/web-1", "prod/us-east-1/lambda/api"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("tagged records = %v, want %v", paths, want)
+	}
+
+	api := idx.Search(Query{Name: "api"})
+	if len(api) != 1 || api[0].ARN != "arn:aws:lambda:us-east-1:1:function:api" {
+		t.Errorf("api record = %+v", api)
+	}
+	if idx.Len() != 7 {
+		t.Errorf("Len = %d, want 7", idx.Len())
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("index was not saved: %v", err)
+	}
+}
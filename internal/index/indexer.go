@@ -0,0 +1,186 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+	"github.com/semonte/sisu/internal/provider"
+	"golang.org/x/time/rate"
+)
+
+// Tree is the filesystem tree the indexer walks, addressed by paths
+// relative to the mount root
+type Tree interface {
+	List(dir string) ([]provider.Entry, error)
+	ReadFile(name string, limit int) ([]byte, error)
+}
+
+// resourceDocuments are files whose ARN and tags describe their directory
+var resourceDocuments = map[string]bool{
+	"info.json":   true,
+	"config.json": true,
+}
+
+// IsResourceDocument reports whether a file named name describes the
+// resource its directory stands for
+func IsResourceDocument(name string) bool {
+	return resourceDocuments[name]
+}
+
+// Walk limits: documents are read up to maxDocument bytes, and a root with
+// more than maxRecords entries is indexed only partly
+const (
+	maxDocument = 256 << 10
+	maxRecords  = 100000
+)
+
+// Defaults for Indexer.Rate and Indexer.Interval
+const (
+	DefaultRate     = 2
+	DefaultInterval = time.Hour
+)
+
+// Indexer keeps an Index up to date by walking Roots at a low rate, so it
+// doesn't compete with browsing for API quota
+type Indexer struct {
+	Tree     Tree
+	Index    *Index
+	File     string        // where the index is saved after each walk ("" = not saved)
+	Roots    []string      // paths relative to the mount root, e.g. "prod/us-east-1/ec2"
+	Rate     float64       // listings and reads per second
+	Interval time.Duration // how old a root's entries may get before it is walked again
+}
+
+// Run walks every root that is due until ctx is done
+func (ix *Indexer) Run(ctx context.Context) {
+	for {
+		for _, root := range ix.Roots {
+			if time.Since(ix.Index.Updated(root)) < ix.Interval {
+				continue
+			}
+			if err := ix.Walk(ctx, root); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("index: %s: %v", root, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+// Walk indexes everything below root and replaces its previous records
+func (ix *Indexer) Walk(ctx context.Context, root string) error {
+	root = strings.Trim(root, "/")
+	w := &walker{tree: ix.Tree, limiter: rate.NewLimiter(rate.Limit(ix.Rate), 1), records: make(map[string]*Record)}
+	if err := w.walk(ctx, root); err != nil {
+		return err
+	}
+
+	records := make([]Record, 0, len(w.records))
+	for _, r := range w.records {
+		records = append(records, *r)
+	}
+	ix.Index.Replace(root, records)
+	if ix.File == "" {
+		return nil
+	}
+	return ix.Index.Save(ix.File)
+}
+
+type walker struct {
+	tree    Tree
+	limiter *rate.Limiter
+	records map[string]*Record
+}
+
+func (w *walker) walk(ctx context.Context, dir string) error {
+	// Each page of a long listing is a listing of its own
+	entries, err := paging.ListAll(dir, func(listing string) ([]provider.Entry, error) {
+		if err := w.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		return w.tree.List(listing)
+	}, func(e provider.Entry) string { return e.Name })
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	for _, entry := range entries {
+		if len(w.records) >= maxRecords {
+			return nil
+		}
+		name := dir + "/" + entry.Name
+		w.records[name] = &Record{Path: name, Dir: entry.IsDir}
+
+		switch {
+		case entry.IsDir:
+			// Subdirectories that can't be listed (e.g. denied) are skipped
+			if err := w.walk(ctx, name); err != nil && ctx.Err() != nil {
+				return err
+			}
+		case resourceDocuments[entry.Name]:
+			if err := w.limiter.Wait(ctx); err != nil {
+				return err
+			}
+			data, err := w.tree.ReadFile(name, maxDocument)
+			if err != nil {
+				continue
+			}
+			if r, ok := w.records[dir]; ok {
+				Describe(r, data)
+			}
+		}
+	}
+	return nil
+}
+
+// Describe sets the ARN and tags of r from a resource document, the
+// info.json or config.json of its directory
+func Describe(r *Record, data []byte) {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(data, &doc) != nil {
+		return
+	}
+
+	// The ARN is under "Arn", or a key like "FunctionArn"; related ARNs
+	// like "RoleArn" sort after the resource's own in most documents
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		if strings.HasSuffix(k, "Arn") || strings.HasSuffix(k, "ARN") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] == "Arn" || (keys[j] != "Arn" && keys[i] < keys[j]) })
+	for _, k := range keys {
+		var arn string
+		if json.Unmarshal(doc[k], &arn) == nil && strings.HasPrefix(arn, "arn:") {
+			r.ARN = arn
+			break
+		}
+	}
+
+	// Tags come as [{"Key": k, "Value": v}] from most services, or as a map
+	var list []struct{ Key, Value string }
+	var tags map[string]string
+	switch {
+	case json.Unmarshal(doc["Tags"], &list) == nil && len(list) > 0:
+		r.Tags = make(map[string]string, len(list))
+		for _, t := range list {
+			r.Tags[t.Key] = t.Value
+		}
+	case json.Unmarshal(doc["Tags"], &tags) == nil && len(tags) > 0:
+		r.Tags = tags
+	}
+}
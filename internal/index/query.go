@@ -0,0 +1,84 @@
+package index
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Query selects records; empty fields match everything
+type Query struct {
+	Name    string            // glob matched against the record's name, e.g. "web*"
+	Words   []string          // each must appear in the path or ARN, ignoring case
+	Profile string            // e.g. "prod"
+	Service string            // e.g. "ec2"
+	Region  string            // e.g. "us-east-1" or "global"
+	Tags    map[string]string // each tag must be set; an empty value matches any value
+}
+
+// ParseQuery parses space-separated terms: name~glob, type:service,
+// profile:name, region:name, tag:key=value (or just key=value), and plain words
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	for _, term := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(term, "name~"):
+			q.Name = strings.TrimPrefix(term, "name~")
+			if _, err := path.Match(q.Name, ""); err != nil {
+				return Query{}, fmt.Errorf("invalid name pattern %q: %w", q.Name, err)
+			}
+		case strings.HasPrefix(term, "type:"):
+			q.Service = strings.TrimPrefix(term, "type:")
+		case strings.HasPrefix(term, "profile:"):
+			q.Profile = strings.TrimPrefix(term, "profile:")
+		case strings.HasPrefix(term, "region:"):
+			q.Region = strings.TrimPrefix(term, "region:")
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(strings.TrimPrefix(term, "tag:"), "=")
+			q.AddTag(key, value)
+		default:
+			q.Words = append(q.Words, term)
+		}
+	}
+	return q, nil
+}
+
+// AddTag requires records to have tag key set to value, or to any value if
+// value is empty
+func (q *Query) AddTag(key, value string) {
+	if q.Tags == nil {
+		q.Tags = make(map[string]string)
+	}
+	q.Tags[key] = value
+}
+
+// Match reports whether r satisfies every condition of q
+func (q Query) Match(r Record) bool {
+	if q.Name != "" {
+		if ok, _ := path.Match(q.Name, r.Name()); !ok {
+			return false
+		}
+	}
+	if q.Profile != "" && r.Profile() != q.Profile {
+		return false
+	}
+	if q.Service != "" && r.Service() != q.Service {
+		return false
+	}
+	if q.Region != "" && q.Region != "all" && r.Region() != q.Region {
+		return false
+	}
+	for key, value := range q.Tags {
+		got, ok := r.Tags[key]
+		if !ok || (value != "" && got != value) {
+			return false
+		}
+	}
+	haystack := strings.ToLower(r.Path + " " + r.ARN)
+	for _, word := range q.Words {
+		if !strings.Contains(haystack, strings.ToLower(word)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+// Package paging splits long directory listings into page directories.
+// A listing stops once it has collected a cap's worth of entries and ends
+// with a "_page2" directory listing the entries after them, which ends
+// with "_page3" and so on. Each page resumes from the continuation token
+// the previous one stopped at, so no page is fetched twice and nothing is
+// dropped between pages.
+package paging
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DirPrefix starts the names of page directories, e.g. "_page2"
+const DirPrefix = "_page"
+
+// Name returns the name of the directory holding page n
+func Name(n int) string {
+	return DirPrefix + strconv.Itoa(n)
+}
+
+// Page returns the page number of a page directory name, or 0 if name
+// isn't one. The first page is the directory itself, so numbers start at 2.
+func Page(name string) int {
+	digits, ok := strings.CutPrefix(name, DirPrefix)
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 2 || Name(n) != name {
+		return 0
+	}
+	return n
+}
+
+// Split returns the directory a listing path lists and its page, e.g.
+// "bucket", 3 for "bucket/_page3" and "bucket", 1 for "bucket"
+func Split(path string) (string, int) {
+	parent, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parent, name = path[:i], path[i+1:]
+	}
+	if n := Page(name); n > 0 {
+		return Strip(parent), n
+	}
+	return Strip(path), 1
+}
+
+// Strip removes page directories from path, e.g. "bucket/_page2/key" is
+// "bucket/key"
+func Strip(path string) string {
+	if !strings.Contains(path, DirPrefix) {
+		return path
+	}
+	parts := strings.Split(path, "/")
+	kept := parts[:0]
+	for _, part := range parts {
+		if Page(part) == 0 {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, "/")
+}
+
+// Join returns the listing path of page n of dir
+func Join(dir string, n int) string {
+	if n <= 1 {
+		return dir
+	}
+	if dir == "" {
+		return Name(n)
+	}
+	return dir + "/" + Name(n)
+}
+
+// ListAll lists dir with list and follows the page directory each page
+// ends with, returning the entries of every page as one listing without
+// the page directories. Tools walking a whole tree use it so entries
+// past the first page keep their real paths.
+func ListAll[E any](dir string, list func(dir string) ([]E, error), name func(E) string) ([]E, error) {
+	var all []E
+	listing := dir
+	for {
+		entries, err := list(listing)
+		if err != nil {
+			return nil, err
+		}
+		next := ""
+		for _, e := range entries {
+			if Page(name(e)) > 0 {
+				next = strings.TrimPrefix(listing+"/"+name(e), "/")
+				continue
+			}
+			all = append(all, e)
+		}
+		if next == "" {
+			return all, nil
+		}
+		listing = next
+	}
+}
+
+// Cursor is where a listing starts and, once listed, where the next page
+// starts
+type Cursor struct {
+	Start string // continuation token of the first API page; "" for the beginning
+	Next  string // continuation token after the last API page fetched; "" if complete
+}
+
+type cursorKey struct{}
+
+// WithCursor returns a context whose listings start at c and record in it
+// where they stopped
+func WithCursor(ctx context.Context, c *Cursor) context.Context {
+	return context.WithValue(ctx, cursorKey{}, c)
+}
+
+// Fetch returns the page of items starting at token ("" for the first)
+// and the token of the page after it ("" after the last)
+type Fetch[T any] func(ctx context.Context, token string) ([]T, string, error)
+
+// Collect fetches pages, starting at the cursor in ctx if any, until it
+// has max items (any number if max <= 0) or the listing is complete.
+// Whole API pages are kept, so it can return somewhat more than max items.
+// Where it stopped is recorded in the cursor and reported as more. On
+// error, the items fetched so far are returned with it.
+func Collect[T any](ctx context.Context, max int, fetch Fetch[T]) (items []T, more bool, err error) {
+	cursor, _ := ctx.Value(cursorKey{}).(*Cursor)
+	token := ""
+	if cursor != nil {
+		token = cursor.Start
+	}
+	for {
+		page, next, err := fetch(ctx, token)
+		if err != nil {
+			return items, false, err
+		}
+		items = append(items, page...)
+		token = next
+		if token == "" || (max > 0 && len(items) >= max) {
+			break
+		}
+	}
+	if cursor != nil {
+		cursor.Next = token
+	}
+	return items, token != "", nil
+}
+
+// Info describes one page of a directory listing
+type Info struct {
+	Page     int    // 1 for the directory itself
+	NextPage string // name of the directory holding the next page; "" on the last
+}
+
+// Cursors remembers where each page of a provider's listings starts. It
+// is safe for concurrent use.
+type Cursors struct {
+	mu     sync.Mutex
+	starts map[string]string // continuation tokens by page listing path, e.g. "bucket/_page2"
+	last   map[string]bool   // listing paths of the last page of their directory
+}
+
+// NewCursors returns an empty set of cursors
+func NewCursors() *Cursors {
+	return &Cursors{starts: make(map[string]string), last: make(map[string]bool)}
+}
+
+// Start returns the token page n of dir starts at, if known. The first
+// page always starts at the beginning.
+func (c *Cursors) Start(dir string, n int) (string, bool) {
+	if n <= 1 {
+		return "", true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, ok := c.starts[Join(dir, n)]
+	return token, ok
+}
+
+// Record stores where the page after page n of dir starts; next is "" if
+// page n was the last
+func (c *Cursors) Record(dir string, n int, next string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := Join(dir, n)
+	if next == "" {
+		c.last[path] = true
+		delete(c.starts, Join(dir, n+1))
+		return
+	}
+	delete(c.last, path)
+	c.starts[Join(dir, n+1)] = next
+}
+
+// Info returns the page a listing path shows and whether it is followed by
+// another, if the path has been listed
+func (c *Cursors) Info(path string) (Info, bool) {
+	dir, n := Split(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listing := Join(dir, n)
+	if c.last[listing] {
+		return Info{Page: n}, true
+	}
+	if _, ok := c.starts[Join(dir, n+1)]; ok {
+		return Info{Page: n, NextPage: Name(n + 1)}, true
+	}
+	return Info{}, false
+}
@@ -0,0 +1,137 @@
+package paging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSplitAndStrip(t *testing.T) {
+	tests := []struct {
+		path  string
+		dir   string
+		page  int
+		strip string
+	}{
+		{"bucket", "bucket", 1, "bucket"},
+		{"bucket/_page2", "bucket", 2, "bucket"},
+		{"bucket/logs/_page3", "bucket/logs", 3, "bucket/logs"},
+		{"_page2", "", 2, ""},
+		{"bucket/_page2/key", "bucket/key", 1, "bucket/key"},
+		{"bucket/_page1", "bucket/_page1", 1, "bucket/_page1"},
+		{"bucket/_page02", "bucket/_page02", 1, "bucket/_page02"},
+	}
+	for _, tt := range tests {
+		if dir, n := Split(tt.path); dir != tt.dir || n != tt.page {
+			t.Errorf("Split(%q) = %q, %d, want %q, %d", tt.path, dir, n, tt.dir, tt.page)
+		}
+		if got := Strip(tt.path); got != tt.strip {
+			t.Errorf("Strip(%q) = %q, want %q", tt.path, got, tt.strip)
+		}
+	}
+}
+
+// numbers fetches pages of size numbers below 10, continuing at the
+// token's number
+func numbers(size int) Fetch[int] {
+	return func(ctx context.Context, token string) ([]int, string, error) {
+		start, _ := strconv.Atoi(token)
+		var page []int
+		for i := start; i < start+size && i < 10; i++ {
+			page = append(page, i)
+		}
+		if start+size >= 10 {
+			return page, "", nil
+		}
+		return page, strconv.Itoa(start + size), nil
+	}
+}
+
+func TestCollectResumesAtCursor(t *testing.T) {
+	cursor := &Cursor{}
+	ctx := WithCursor(context.Background(), cursor)
+	items, more, err := Collect(ctx, 4, numbers(3))
+	if err != nil || !more || !reflect.DeepEqual(items, []int{0, 1, 2, 3, 4, 5}) {
+		t.Fatalf("Collect = %v, %v, %v", items, more, err)
+	}
+	if cursor.Next != "6" {
+		t.Fatalf("Next = %q, want 6", cursor.Next)
+	}
+
+	cursor = &Cursor{Start: cursor.Next}
+	items, more, err = Collect(WithCursor(context.Background(), cursor), 4, numbers(3))
+	if err != nil || more || !reflect.DeepEqual(items, []int{6, 7, 8, 9}) || cursor.Next != "" {
+		t.Fatalf("Collect = %v, %v, %v (next %q)", items, more, err, cursor.Next)
+	}
+}
+
+func TestCollectReturnsItemsBeforeError(t *testing.T) {
+	boom := errors.New("boom")
+	fetch := func(ctx context.Context, token string) ([]int, string, error) {
+		if token != "" {
+			return nil, "", boom
+		}
+		return []int{1, 2}, "next", nil
+	}
+	items, _, err := Collect(context.Background(), 0, fetch)
+	if !errors.Is(err, boom) || !reflect.DeepEqual(items, []int{1, 2}) {
+		t.Fatalf("Collect = %v, %v", items, err)
+	}
+}
+
+func TestCursorsInfo(t *testing.T) {
+	c := NewCursors()
+	if _, ok := c.Info("bucket"); ok {
+		t.Fatal("Info of an unlisted directory")
+	}
+	c.Record("bucket", 1, "t2")
+	c.Record("bucket", 2, "")
+	if info, _ := c.Info("bucket"); info != (Info{Page: 1, NextPage: "_page2"}) {
+		t.Errorf("Info(bucket) = %+v", info)
+	}
+	if info, _ := c.Info("bucket/_page2"); info != (Info{Page: 2}) {
+		t.Errorf("Info(bucket/_page2) = %+v", info)
+	}
+	if start, ok := c.Start("bucket", 2); !ok || start != "t2" {
+		t.Errorf("Start(bucket, 2) = %q, %v", start, ok)
+	}
+	if _, ok := c.Start("bucket", 3); ok {
+		t.Error("Start of a page after the last")
+	}
+}
+
+func TestListAll(t *testing.T) {
+	pages := map[string][]string{
+		"bucket":               {"a", "b", "_page2"},
+		"bucket/_page2":        {"c", "_page3"},
+		"bucket/_page2/_page3": {"d"},
+	}
+	var listed []string
+	list := func(dir string) ([]string, error) {
+		listed = append(listed, dir)
+		names, ok := pages[dir]
+		if !ok {
+			return nil, errors.New("no such directory")
+		}
+		return names, nil
+	}
+	name := func(s string) string { return s }
+
+	all, err := ListAll("bucket", list, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("ListAll = %v, want %v", all, want)
+	}
+	if len(listed) != 3 {
+		t.Errorf("listed %v, want each page once", listed)
+	}
+
+	delete(pages, "bucket/_page2/_page3")
+	if _, err := ListAll("bucket", list, name); err == nil {
+		t.Error("ListAll succeeded with a page missing")
+	}
+}
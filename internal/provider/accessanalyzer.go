@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// AccessAnalyzerProvider provides IAM Access Analyzer analyzers and their
+// findings, with each finding filed under a directory for its status:
+//
+//	<analyzer>/info.json
+//	<analyzer>/active/<finding-id>.json
+//	<analyzer>/archived/<finding-id>.json
+//	<analyzer>/resolved/<finding-id>.json
+type AccessAnalyzerProvider struct {
+	ReadOnlyProvider
+	client *restJSONClient
+
+	mu        sync.Mutex
+	analyzers map[string]string // analyzer ARNs by name
+}
+
+// accessAnalyzerStatuses maps finding status directories to API statuses
+var accessAnalyzerStatuses = map[string]string{
+	"active":   "ACTIVE",
+	"archived": "ARCHIVED",
+	"resolved": "RESOLVED",
+}
+
+// NewAccessAnalyzerProvider creates a new Access Analyzer provider.
+// Analyzers are regional; the profile's region is used, or us-east-1.
+func NewAccessAnalyzerProvider(profile string) (*AccessAnalyzerProvider, error) {
+	cfg, err := LoadAWSConfig(profile, "")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return newAccessAnalyzerProvider(cfg), nil
+}
+
+func newAccessAnalyzerProvider(cfg aws.Config) *AccessAnalyzerProvider {
+	return &AccessAnalyzerProvider{
+		client:    newRESTJSONClient(cfg, "AccessAnalyzer", "access-analyzer"),
+		analyzers: make(map[string]string),
+	}
+}
+
+func (p *AccessAnalyzerProvider) Name() string {
+	return "access-analyzer"
+}
+
+// accessAnalyzerSummary is the part of an analyzer sisu uses
+type accessAnalyzerSummary struct {
+	Arn  string `json:"arn"`
+	Name string `json:"name"`
+}
+
+func (p *AccessAnalyzerProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		return p.listAnalyzers(ctx)
+	case len(parts) == 1:
+		return []Entry{
+			{Name: "info.json", IsDir: false},
+			{Name: "active", IsDir: true},
+			{Name: "archived", IsDir: true},
+			{Name: "resolved", IsDir: true},
+		}, nil
+	case len(parts) == 2 && accessAnalyzerStatuses[parts[1]] != "":
+		return p.listFindings(ctx, parts[0], accessAnalyzerStatuses[parts[1]])
+	}
+	return nil, fmt.Errorf("invalid path: %s", path)
+}
+
+func (p *AccessAnalyzerProvider) listAnalyzers(ctx context.Context) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		query := url.Values{}
+		if token != "" {
+			query.Set("nextToken", token)
+		}
+		var resp struct {
+			Analyzers []accessAnalyzerSummary `json:"analyzers"`
+			NextToken string                  `json:"nextToken"`
+		}
+		if err := p.client.do(ctx, "ListAnalyzers", "GET", "/analyzer", query, nil, &resp); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(resp.Analyzers))
+		for _, a := range resp.Analyzers {
+			p.rememberAnalyzer(a.Name, a.Arn)
+			entries = append(entries, Entry{Name: a.Name, IsDir: true})
+		}
+		return entries, resp.NextToken, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, accessAnalyzerHint(""), err)
+	}
+	return entries, nil
+}
+
+func (p *AccessAnalyzerProvider) listFindings(ctx context.Context, analyzer, status string) ([]Entry, error) {
+	arn, err := p.analyzerARN(ctx, analyzer)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		input := map[string]any{
+			"analyzerArn": arn,
+			"filter":      map[string]any{"status": map[string][]string{"eq": {status}}},
+		}
+		if token != "" {
+			input["nextToken"] = token
+		}
+		var resp struct {
+			Findings []struct {
+				ID        string    `json:"id"`
+				UpdatedAt time.Time `json:"updatedAt"`
+			} `json:"findings"`
+			NextToken string `json:"nextToken"`
+		}
+		if err := p.client.do(ctx, "ListFindingsV2", "POST", "/findingv2", nil, input, &resp); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(resp.Findings))
+		for _, f := range resp.Findings {
+			entries = append(entries, Entry{Name: f.ID + ".json", Size: 4096, ModTime: f.UpdatedAt})
+		}
+		return entries, resp.NextToken, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, accessAnalyzerHint(analyzer), err)
+	}
+	return entries, nil
+}
+
+// accessAnalyzerHint is the CLI command listing everything below path: all
+// analyzers at the top level, otherwise an analyzer's findings
+func accessAnalyzerHint(path string) string {
+	if path == "" {
+		return "aws accessanalyzer list-analyzers"
+	}
+	return "aws accessanalyzer list-findings-v2 --analyzer-arn <arn>"
+}
+
+func (p *AccessAnalyzerProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "info.json":
+		return p.getAnalyzer(ctx, parts[0])
+	case len(parts) == 3 && accessAnalyzerStatuses[parts[1]] != "" && strings.HasSuffix(parts[2], ".json"):
+		return p.getFinding(ctx, parts[0], strings.TrimSuffix(parts[2], ".json"))
+	}
+	return nil, fmt.Errorf("unknown path: %s", path)
+}
+
+func (p *AccessAnalyzerProvider) getAnalyzer(ctx context.Context, name string) ([]byte, error) {
+	var resp struct {
+		Analyzer json.RawMessage `json:"analyzer"`
+	}
+	if err := p.client.do(ctx, "GetAnalyzer", "GET", "/analyzer/"+url.PathEscape(name), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	var analyzer any
+	if err := json.Unmarshal(resp.Analyzer, &analyzer); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(analyzer, "", "  ")
+}
+
+// getFinding returns a finding with all pages of its details
+func (p *AccessAnalyzerProvider) getFinding(ctx context.Context, analyzer, id string) ([]byte, error) {
+	arn, err := p.analyzerARN(ctx, analyzer)
+	if err != nil {
+		return nil, err
+	}
+
+	var finding map[string]any
+	var details []any
+	query := url.Values{"analyzerArn": {arn}}
+	for {
+		var resp map[string]any
+		if err := p.client.do(ctx, "GetFindingV2", "GET", "/findingv2/"+url.PathEscape(id), query, nil, &resp); err != nil {
+			return nil, err
+		}
+		if page, ok := resp["findingDetails"].([]any); ok {
+			details = append(details, page...)
+		}
+		if finding == nil {
+			finding = resp
+		}
+		next, _ := resp["nextToken"].(string)
+		if next == "" {
+			break
+		}
+		query.Set("nextToken", next)
+	}
+	delete(finding, "nextToken")
+	if details != nil {
+		finding["findingDetails"] = details
+	}
+	return json.MarshalIndent(finding, "", "  ")
+}
+
+// rememberAnalyzer records a listed analyzer's ARN
+func (p *AccessAnalyzerProvider) rememberAnalyzer(name, arn string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.analyzers[name] = arn
+}
+
+// analyzerARN returns an analyzer's ARN, from its listing or else GetAnalyzer
+func (p *AccessAnalyzerProvider) analyzerARN(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	arn, ok := p.analyzers[name]
+	p.mu.Unlock()
+	if ok {
+		return arn, nil
+	}
+
+	var resp struct {
+		Analyzer accessAnalyzerSummary `json:"analyzer"`
+	}
+	if err := p.client.do(ctx, "GetAnalyzer", "GET", "/analyzer/"+url.PathEscape(name), nil, nil, &resp); err != nil {
+		return "", err
+	}
+	p.rememberAnalyzer(name, resp.Analyzer.Arn)
+	return resp.Analyzer.Arn, nil
+}
+
+func (p *AccessAnalyzerProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "access-analyzer", IsDir: true}, nil
+	}
+
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	switch {
+	case len(parts) == 1:
+		return &Entry{Name: name, IsDir: true}, nil
+	case len(parts) == 2 && parts[1] == "info.json":
+		return &Entry{Name: name, Size: 4096}, nil
+	case len(parts) == 2 && accessAnalyzerStatuses[parts[1]] != "":
+		return &Entry{Name: name, IsDir: true}, nil
+	case len(parts) == 3 && accessAnalyzerStatuses[parts[1]] != "" && strings.HasSuffix(name, ".json"):
+		return &Entry{Name: name, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
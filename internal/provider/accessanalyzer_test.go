@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestAccessAnalyzerFindingsByStatus(t *testing.T) {
+	cfg, client := fixtureConfig(t, "accessanalyzer")
+	p := newAccessAnalyzerProvider(cfg)
+	ctx := context.Background()
+
+	analyzers, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analyzers) != 1 || analyzers[0].Name != "account" {
+		t.Fatalf("analyzers = %v, want [account]", analyzers)
+	}
+
+	active, err := p.ReadDir(ctx, "account/active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].Name != "4f1c2a9e-public-bucket.json" {
+		t.Fatalf("active = %v, want the public bucket finding", active)
+	}
+	archived, err := p.ReadDir(ctx, "account/archived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 0 {
+		t.Errorf("archived = %v, want none", archived)
+	}
+
+	data, err := p.Read(ctx, "account/active/4f1c2a9e-public-bucket.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "accessanalyzer/finding.json", data)
+
+	// The listing supplied the analyzer's ARN, so findings need no GetAnalyzer
+	want := []string{"ListAnalyzers", "ListFindingsV2", "ListFindingsV2", "GetFindingV2"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestAccessAnalyzerInfo(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "accessanalyzer")
+	p := newAccessAnalyzerProvider(cfg)
+
+	data, err := p.Read(context.Background(), "account/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "accessanalyzer/info.json", data)
+}
+
+func TestAccessAnalyzerErrors(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "accessanalyzer")
+	p := newAccessAnalyzerProvider(cfg)
+
+	_, err := p.ReadDir(context.Background(), "missing/active")
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "ResourceNotFoundException" || apiErr.ErrorMessage() != "Analyzer missing not found" {
+		t.Errorf("err = %v, want ResourceNotFoundException", err)
+	}
+}
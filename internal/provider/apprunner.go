@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// AppRunnerProvider provides App Runner services as directories:
+//
+//	<service>/info.json  the service's description: source, instance and network configuration, URL
+//	<service>/status     its status, e.g. RUNNING or OPERATION_IN_PROGRESS
+type AppRunnerProvider struct {
+	ReadOnlyProvider
+	client   *restJSONClient
+	services *documents[cappedList[map[string]string]] // service ARNs by name, under ""
+}
+
+// Files of an App Runner service directory
+const (
+	appRunnerInfoFile   = "info.json"
+	appRunnerStatusFile = "status"
+)
+
+// NewAppRunnerProvider creates a new App Runner provider
+func NewAppRunnerProvider(profile, region string) (*AppRunnerProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newAppRunnerProvider(cfg), nil
+}
+
+func newAppRunnerProvider(cfg aws.Config) *AppRunnerProvider {
+	return &AppRunnerProvider{
+		client:   newJSONRPCClient(cfg, "AppRunner", "apprunner", "AppRunner"),
+		services: newDocuments[cappedList[map[string]string]](),
+	}
+}
+
+func (p *AppRunnerProvider) Name() string {
+	return "apprunner"
+}
+
+// Invalidate forgets the memoized service ARNs, so a refresh describes them anew
+func (p *AppRunnerProvider) Invalidate(string) {
+	forgetDocuments(p.services)
+}
+
+func (p *AppRunnerProvider) Close() {
+	closeDocuments(p.services)
+}
+
+// listServices returns the ARNs of the region's services by name, up to
+// MaxEntries of them
+func (p *AppRunnerProvider) listServices(ctx context.Context) (cappedList[map[string]string], error) {
+	return p.services.get("", func() (cappedList[map[string]string], error) {
+		arns := make(map[string]string)
+		in := map[string]any{"MaxResults": 20}
+		for {
+			var resp struct {
+				ServiceSummaryList []struct {
+					ServiceName string
+					ServiceArn  string
+				}
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListServices", in, &resp); err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			for _, s := range resp.ServiceSummaryList {
+				arns[s.ServiceName] = s.ServiceArn
+			}
+			if resp.NextToken == "" || len(arns) >= MaxEntries {
+				return cappedList[map[string]string]{items: arns, more: resp.NextToken != ""}, nil
+			}
+			in = map[string]any{"MaxResults": 20, "NextToken": resp.NextToken}
+		}
+	})
+}
+
+// serviceARN returns the ARN of the named service, or an error wrapping
+// os.ErrNotExist
+func (p *AppRunnerProvider) serviceARN(ctx context.Context, name string) (string, error) {
+	arns, err := p.listServices(ctx)
+	if err != nil {
+		return "", err
+	}
+	arn, ok := arns.items[name]
+	if !ok {
+		return "", fmt.Errorf("service not found: %s: %w", name, os.ErrNotExist)
+	}
+	return arn, nil
+}
+
+func (p *AppRunnerProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		arns, err := p.listServices(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws apprunner list-services", err)
+		}
+		entries := make([]Entry, 0, len(arns.items))
+		for name := range arns.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, arns.more, "aws apprunner list-services"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if _, err := p.serviceARN(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: appRunnerInfoFile, IsDir: false, Size: 4096},
+		{Name: appRunnerStatusFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+// describe returns the description of the named service
+func (p *AppRunnerProvider) describe(ctx context.Context, name string) (map[string]any, error) {
+	arn, err := p.serviceARN(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Service map[string]any
+	}
+	if err := p.client.call(ctx, "DescribeService", map[string]any{"ServiceArn": arn}, &resp); err != nil {
+		if isAPIError(err, "ResourceNotFoundException") {
+			return nil, fmt.Errorf("service not found: %s: %w", name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	return jsonTimes(resp.Service), nil
+}
+
+func (p *AppRunnerProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	service, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if file != appRunnerInfoFile && file != appRunnerStatusFile {
+		return nil, fmt.Errorf("unknown file: %s", file)
+	}
+	desc, err := p.describe(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	if file == appRunnerStatusFile {
+		return fmt.Appendf(nil, "%v\n", desc["Status"]), nil
+	}
+	return json.MarshalIndent(desc, "", "  ")
+}
+
+// Prefetch returns both files of a service from its one description
+func (p *AppRunnerProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	service, _, _ := strings.Cut(path, "/")
+	desc, err := p.describe(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	info, err := json.MarshalIndent(desc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		service + "/" + appRunnerInfoFile:   info,
+		service + "/" + appRunnerStatusFile: fmt.Appendf(nil, "%v\n", desc["Status"]),
+	}, nil
+}
+
+func (p *AppRunnerProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "apprunner", IsDir: true}, nil
+	}
+	service, file, _ := strings.Cut(path, "/")
+	if _, err := p.serviceARN(ctx, service); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: service, IsDir: true}, nil
+	case appRunnerInfoFile, appRunnerStatusFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestAppRunnerServices(t *testing.T) {
+	cfg, client := fixtureConfig(t, "apprunner")
+	p := newAppRunnerProvider(cfg)
+	ctx := context.Background()
+
+	services, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(services); !reflect.DeepEqual(names, []string{"api", "web"}) {
+		t.Fatalf("services = %v", names)
+	}
+
+	files, err := Prefetch(ctx, p, "web/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "apprunner/info.json", files["web/info.json"])
+	if status := string(files["web/status"]); status != "RUNNING\n" {
+		t.Errorf("status = %q", status)
+	}
+	// Both files come from one description
+	if want := []string{"ListServices", "DescribeService"}; !reflect.DeepEqual(client.Calls(), want) {
+		t.Errorf("calls = %v, want %v", client.Calls(), want)
+	}
+}
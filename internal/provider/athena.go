@@ -0,0 +1,490 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// AthenaProvider provides Athena workgroups, saved queries, and a place to
+// run queries:
+//
+//	workgroups/<name>.json              the workgroup: state, engine, output location, limits
+//	named-queries/<name>.sql            a saved query's SQL
+//	queries/<workgroup>/<name>.sql      writing SQL starts running it in the workgroup
+//	queries/<workgroup>/<name>.csv      its results, once it has succeeded
+//
+// Queries are tracked for the life of the mount: queries/ lists only those
+// written through it.
+type AthenaProvider struct {
+	client     *restJSONClient
+	workgroups *documents[cappedList[map[string]map[string]any]] // workgroups by name, under ""
+	named      *documents[cappedList[map[string]map[string]any]] // named queries by file name, under ""
+	poll       time.Duration
+
+	mu         sync.Mutex
+	executions map[string]athenaExecution // by path of the .sql file
+}
+
+// athenaExecution is a query started by writing a .sql file
+type athenaExecution struct {
+	ID    string
+	Query string
+	Start time.Time
+}
+
+// Directories of the athena service
+const (
+	athenaWorkgroupsDir   = "workgroups"
+	athenaNamedQueriesDir = "named-queries"
+	athenaQueriesDir      = "queries"
+)
+
+// athenaPollInterval is how often reading results checks on a query that
+// is still running
+const athenaPollInterval = time.Second
+
+// NewAthenaProvider creates a new Athena provider
+func NewAthenaProvider(profile, region string) (*AthenaProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newAthenaProvider(cfg), nil
+}
+
+func newAthenaProvider(cfg aws.Config) *AthenaProvider {
+	client := newJSONRPCClient(cfg, "Athena", "athena", "AmazonAthena")
+	client.jsonVersion = "1.1"
+	return &AthenaProvider{
+		client:     client,
+		workgroups: newDocuments[cappedList[map[string]map[string]any]](),
+		named:      newDocuments[cappedList[map[string]map[string]any]](),
+		poll:       athenaPollInterval,
+		executions: make(map[string]athenaExecution),
+	}
+}
+
+func (p *AthenaProvider) Name() string {
+	return "athena"
+}
+
+// Invalidate forgets the memoized workgroups and named queries, so a refresh describes them anew
+func (p *AthenaProvider) Invalidate(string) {
+	forgetDocuments(p.workgroups, p.named)
+}
+
+func (p *AthenaProvider) Close() {
+	closeDocuments(p.workgroups, p.named)
+}
+
+// isAthenaQueryFile reports whether path is a .sql file of a workgroup's
+// queries directory
+func isAthenaQueryFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 3 && parts[0] == athenaQueriesDir && strings.HasSuffix(parts[2], ".sql") && len(parts[2]) > len(".sql")
+}
+
+// listWorkgroups returns the region's workgroups by name, up to MaxEntries
+// of them
+func (p *AthenaProvider) listWorkgroups(ctx context.Context) (cappedList[map[string]map[string]any], error) {
+	return p.workgroups.get("", func() (cappedList[map[string]map[string]any], error) {
+		workgroups := make(map[string]map[string]any)
+		in := map[string]any{"MaxResults": 50}
+		for {
+			var resp struct {
+				WorkGroups []map[string]any
+				NextToken  string
+			}
+			if err := p.client.call(ctx, "ListWorkGroups", in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			for _, wg := range resp.WorkGroups {
+				if name, ok := wg["Name"].(string); ok {
+					workgroups[name] = wg
+				}
+			}
+			if resp.NextToken == "" || len(workgroups) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: workgroups, more: resp.NextToken != ""}, nil
+			}
+			in = map[string]any{"MaxResults": 50, "NextToken": resp.NextToken}
+		}
+	})
+}
+
+// checkWorkgroup returns an error wrapping os.ErrNotExist unless the
+// workgroup is listed, or found past a truncated listing
+func (p *AthenaProvider) checkWorkgroup(ctx context.Context, name string) error {
+	workgroups, err := p.listWorkgroups(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := workgroups.items[name]; ok {
+		return nil
+	}
+	if workgroups.more {
+		err := p.client.call(ctx, "GetWorkGroup", map[string]any{"WorkGroup": name}, nil)
+		if err == nil || !isAPIError(err, "InvalidRequestException") {
+			return err
+		}
+	}
+	return fmt.Errorf("workgroup not found: %s: %w", name, os.ErrNotExist)
+}
+
+// listNamedQueries returns the region's named queries by file name: the
+// query's name, or name.<id> for all but the first by ID of queries
+// sharing one. Up to MaxEntries of them are fetched.
+func (p *AthenaProvider) listNamedQueries(ctx context.Context) (cappedList[map[string]map[string]any], error) {
+	return p.named.get("", func() (cappedList[map[string]map[string]any], error) {
+		var ids []string
+		in := map[string]any{"MaxResults": 50}
+		more := false
+		for {
+			var resp struct {
+				NamedQueryIds []string
+				NextToken     string
+			}
+			if err := p.client.call(ctx, "ListNamedQueries", in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			ids = append(ids, resp.NamedQueryIds...)
+			if resp.NextToken == "" {
+				break
+			}
+			if len(ids) >= MaxEntries {
+				more = true
+				break
+			}
+			in = map[string]any{"MaxResults": 50, "NextToken": resp.NextToken}
+		}
+
+		var queries []map[string]any
+		for start := 0; start < len(ids); start += 50 {
+			batch := ids[start:min(start+50, len(ids))]
+			var resp struct {
+				NamedQueries []map[string]any
+			}
+			if err := p.client.call(ctx, "BatchGetNamedQuery", map[string]any{"NamedQueryIds": batch}, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			queries = append(queries, resp.NamedQueries...)
+		}
+		sort.SliceStable(queries, func(i, j int) bool {
+			return fmt.Sprint(queries[i]["NamedQueryId"]) < fmt.Sprint(queries[j]["NamedQueryId"])
+		})
+		named := make(map[string]map[string]any, len(queries))
+		for _, q := range queries {
+			name := escapeSlash(fmt.Sprint(q["Name"]))
+			if _, taken := named[name+".sql"]; taken {
+				name += "." + fmt.Sprint(q["NamedQueryId"])
+			}
+			named[name+".sql"] = q
+		}
+		return cappedList[map[string]map[string]any]{items: named, more: more}, nil
+	})
+}
+
+// namedQuerySQL returns a named query's SQL, with a comment naming its
+// database and workgroup
+func namedQuerySQL(q map[string]any) []byte {
+	var b bytes.Buffer
+	if desc, _ := q["Description"].(string); desc != "" {
+		fmt.Fprintf(&b, "-- %s\n", desc)
+	}
+	fmt.Fprintf(&b, "-- database: %v, workgroup: %v\n", q["Database"], q["WorkGroup"])
+	b.WriteString(strings.TrimRight(fmt.Sprint(q["QueryString"]), "\n"))
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// execution returns the query started by writing the .sql file at path
+func (p *AthenaProvider) execution(path string) (athenaExecution, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.executions[path]
+	return e, ok
+}
+
+func (p *AthenaProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		return []Entry{
+			{Name: athenaNamedQueriesDir, IsDir: true},
+			{Name: athenaQueriesDir, IsDir: true},
+			{Name: athenaWorkgroupsDir, IsDir: true},
+		}, nil
+	case path == athenaWorkgroupsDir || path == athenaQueriesDir:
+		workgroups, err := p.listWorkgroups(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws athena list-work-groups", err)
+		}
+		entries := make([]Entry, 0, len(workgroups.items))
+		for name := range workgroups.items {
+			if path == athenaWorkgroupsDir {
+				entries = append(entries, Entry{Name: name + ".json", IsDir: false, Size: 4096})
+			} else {
+				entries = append(entries, Entry{Name: name, IsDir: true})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, workgroups.more, "aws athena list-work-groups"), nil
+	case path == athenaNamedQueriesDir:
+		named, err := p.listNamedQueries(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws athena list-named-queries", err)
+		}
+		entries := make([]Entry, 0, len(named.items))
+		for name, q := range named.items {
+			entries = append(entries, Entry{Name: name, IsDir: false, Size: int64(len(namedQuerySQL(q)))})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, named.more, "aws athena list-named-queries"), nil
+	case len(parts) == 2 && parts[0] == athenaQueriesDir:
+		if err := p.checkWorkgroup(ctx, parts[1]); err != nil {
+			return nil, err
+		}
+		prefix := path + "/"
+		var entries []Entry
+		p.mu.Lock()
+		for file, e := range p.executions {
+			if name, ok := strings.CutPrefix(file, prefix); ok {
+				entries = append(entries,
+					Entry{Name: name, IsDir: false, Size: int64(len(e.Query)), ModTime: e.Start},
+					Entry{Name: strings.TrimSuffix(name, ".sql") + ".csv", IsDir: false, Size: 4096, ModTime: e.Start})
+			}
+		}
+		p.mu.Unlock()
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return entries, nil
+	}
+	return nil, fmt.Errorf("unknown path: %s", path)
+}
+
+func (p *AthenaProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == athenaWorkgroupsDir && strings.HasSuffix(parts[1], ".json"):
+		name := strings.TrimSuffix(parts[1], ".json")
+		if err := p.checkWorkgroup(ctx, name); err != nil {
+			return nil, err
+		}
+		var resp struct {
+			WorkGroup map[string]any
+		}
+		if err := p.client.call(ctx, "GetWorkGroup", map[string]any{"WorkGroup": name}, &resp); err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(jsonTimes(resp.WorkGroup), "", "  ")
+	case len(parts) == 2 && parts[0] == athenaNamedQueriesDir:
+		named, err := p.listNamedQueries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q, ok := named.items[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("named query not found: %s: %w", parts[1], os.ErrNotExist)
+		}
+		return namedQuerySQL(q), nil
+	case isAthenaQueryFile(path):
+		e, ok := p.execution(path)
+		if !ok {
+			return nil, fmt.Errorf("query not found: %s: %w", path, os.ErrNotExist)
+		}
+		return []byte(e.Query), nil
+	case len(parts) == 3 && parts[0] == athenaQueriesDir && strings.HasSuffix(parts[2], ".csv"):
+		e, ok := p.execution(strings.TrimSuffix(path, ".csv") + ".sql")
+		if !ok {
+			return nil, fmt.Errorf("query not found: %s: %w", path, os.ErrNotExist)
+		}
+		if err := p.wait(ctx, e.ID); err != nil {
+			return nil, err
+		}
+		return p.results(ctx, e.ID)
+	}
+	return nil, fmt.Errorf("invalid path: %s", path)
+}
+
+// wait polls a query until it has succeeded, returning its failure reason
+// if it failed or was cancelled
+func (p *AthenaProvider) wait(ctx context.Context, id string) error {
+	for {
+		var resp struct {
+			QueryExecution struct {
+				Status struct {
+					State             string
+					StateChangeReason string
+				}
+			}
+		}
+		if err := p.client.call(ctx, "GetQueryExecution", map[string]any{"QueryExecutionId": id}, &resp); err != nil {
+			return err
+		}
+		switch status := resp.QueryExecution.Status; status.State {
+		case "SUCCEEDED":
+			return nil
+		case "FAILED", "CANCELLED":
+			return fmt.Errorf("query %s %s: %s", id, strings.ToLower(status.State), status.StateChangeReason)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.poll):
+		}
+	}
+}
+
+// results returns a query's result set as CSV. For SELECT queries Athena
+// returns the column names as the first row.
+func (p *AthenaProvider) results(ctx context.Context, id string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	in := map[string]any{"QueryExecutionId": id, "MaxResults": 1000}
+	for {
+		var resp struct {
+			ResultSet struct {
+				Rows []struct {
+					Data []struct {
+						VarCharValue *string
+					}
+				}
+			}
+			NextToken string
+		}
+		if err := p.client.call(ctx, "GetQueryResults", in, &resp); err != nil {
+			return nil, err
+		}
+		for _, row := range resp.ResultSet.Rows {
+			record := make([]string, len(row.Data))
+			for i, d := range row.Data {
+				record[i] = aws.ToString(d.VarCharValue)
+			}
+			w.Write(record)
+		}
+		if resp.NextToken == "" {
+			break
+		}
+		in = map[string]any{"QueryExecutionId": id, "MaxResults": 1000, "NextToken": resp.NextToken}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+func (p *AthenaProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "athena", IsDir: true}, nil
+	}
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	switch {
+	case len(parts) == 1 && (path == athenaWorkgroupsDir || path == athenaNamedQueriesDir || path == athenaQueriesDir):
+		return &Entry{Name: path, IsDir: true}, nil
+	case len(parts) == 2 && parts[0] == athenaWorkgroupsDir && strings.HasSuffix(name, ".json"):
+		if err := p.checkWorkgroup(ctx, strings.TrimSuffix(name, ".json")); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: name, IsDir: false, Size: 4096}, nil
+	case len(parts) == 2 && parts[0] == athenaNamedQueriesDir:
+		named, err := p.listNamedQueries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q, ok := named.items[name]
+		if !ok {
+			return nil, fmt.Errorf("named query not found: %s: %w", name, os.ErrNotExist)
+		}
+		return &Entry{Name: name, IsDir: false, Size: int64(len(namedQuerySQL(q)))}, nil
+	case len(parts) == 2 && parts[0] == athenaQueriesDir:
+		if err := p.checkWorkgroup(ctx, name); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: name, IsDir: true}, nil
+	case len(parts) == 3 && parts[0] == athenaQueriesDir:
+		sql := path
+		if strings.HasSuffix(name, ".csv") {
+			sql = strings.TrimSuffix(path, ".csv") + ".sql"
+		}
+		e, ok := p.execution(sql)
+		if !ok || !isAthenaQueryFile(sql) {
+			return nil, fmt.Errorf("query not found: %s: %w", path, os.ErrNotExist)
+		}
+		if sql == path {
+			return &Entry{Name: name, IsDir: false, Size: int64(len(e.Query)), ModTime: e.Start}, nil
+		}
+		return &Entry{Name: name, IsDir: false, Size: 4096, ModTime: e.Start}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
+
+// Writable reports whether path is a workgroup's queries directory or a
+// .sql file in it
+func (p *AthenaProvider) Writable(path string) bool {
+	parts := strings.Split(path, "/")
+	return (len(parts) == 2 && parts[0] == athenaQueriesDir) || isAthenaQueryFile(path)
+}
+
+// Write starts running the SQL written to a .sql file in the workgroup of
+// its directory. Writing the file again starts the query again.
+func (p *AthenaProvider) Write(ctx context.Context, path string, data []byte) error {
+	if !isAthenaQueryFile(path) {
+		return fs.ErrPermission
+	}
+	workgroup := strings.Split(path, "/")[1]
+	if err := p.checkWorkgroup(ctx, workgroup); err != nil {
+		return err
+	}
+	query := string(data)
+	var resp struct {
+		QueryExecutionId string
+	}
+	in := map[string]any{"QueryString": query, "WorkGroup": workgroup}
+	if err := p.client.call(ctx, "StartQueryExecution", in, &resp); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.executions[path] = athenaExecution{ID: resp.QueryExecutionId, Query: query, Start: time.Now()}
+	p.mu.Unlock()
+	return nil
+}
+
+// Written returns .sql files as written; results are fetched
+func (p *AthenaProvider) Written(path string, data []byte) ([]byte, bool) {
+	return data, isAthenaQueryFile(path)
+}
+
+// Delete forgets a query, cancelling it if it is still running
+func (p *AthenaProvider) Delete(ctx context.Context, path string) error {
+	if !isAthenaQueryFile(path) {
+		return fs.ErrPermission
+	}
+	e, ok := p.execution(path)
+	if !ok {
+		return fmt.Errorf("query not found: %s: %w", path, os.ErrNotExist)
+	}
+	if err := p.client.call(ctx, "StopQueryExecution", map[string]any{"QueryExecutionId": e.ID}, nil); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.executions, path)
+	p.mu.Unlock()
+	return nil
+}
+
+// validateAthenaQuery rejects empty queries, e.g. from an editor saving an
+// empty .sql file
+func validateAthenaQuery(path string, data []byte) error {
+	if isAthenaQueryFile(path) && len(bytes.TrimSpace(data)) == 0 {
+		return invalidf("%s: query is empty", path)
+	}
+	return nil
+}
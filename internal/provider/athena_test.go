@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestAthenaNamedQueries(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "athena")
+	p := newAthenaProvider(cfg)
+	ctx := context.Background()
+
+	queries, err := p.ReadDir(ctx, "named-queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Of two queries sharing a name, the first by ID keeps it, and slashes
+	// in names don't split them into directories
+	if names := entryNames(queries); !reflect.DeepEqual(names, []string{"daily-orders.b1.sql", "daily-orders.sql", "reports／weekly.sql"}) {
+		t.Fatalf("named queries = %v", names)
+	}
+	data, err := p.Read(ctx, "named-queries/daily-orders.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-- Orders per day\n-- database: sales, workgroup: primary\nSELECT date, count(*) FROM orders GROUP BY date\n"
+	if string(data) != want {
+		t.Errorf("daily-orders.sql = %q, want %q", data, want)
+	}
+	if _, err := p.Stat(ctx, "named-queries/reports／weekly.sql"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAthenaQueryResults(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "athena")
+	p := newAthenaProvider(cfg)
+	p.poll = 0
+	ctx := context.Background()
+
+	if !p.Writable("queries/analysts") || !p.Writable("queries/analysts/status.sql") || p.Writable("queries/analysts/status.csv") {
+		t.Error("only .sql files in a workgroup's queries should be writable")
+	}
+	if err := p.Write(ctx, "queries/missing/status.sql", []byte("SELECT 1")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Write to a missing workgroup = %v, want ErrNotExist", err)
+	}
+	query := "SELECT status, count(*) AS orders FROM orders GROUP BY status\n"
+	if err := p.Write(ctx, "queries/analysts/status.sql", []byte(query)); err != nil {
+		t.Fatal(err)
+	}
+	files, err := p.ReadDir(ctx, "queries/analysts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(files); !reflect.DeepEqual(names, []string{"status.csv", "status.sql"}) {
+		t.Errorf("queries = %v", names)
+	}
+
+	// Reading the results waits for the query to succeed
+	data, err := p.Read(ctx, "queries/analysts/status.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "status,orders\nshipped,\"1,204\"\npending,\n"; string(data) != want {
+		t.Errorf("status.csv = %q, want %q", data, want)
+	}
+
+	if err := validateAthenaQuery("queries/analysts/empty.sql", []byte(" \n")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("empty query = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// AuditDir is the unlisted directory at the root of a service holding
+// security checks generated on demand from its Describe calls, e.g.
+// s3/_audit/public-buckets.json. Each file is a JSON array of findings.
+const AuditDir = "_audit"
+
+// auditFiles are the checks of a service by filename
+type auditFiles map[string]func(ctx context.Context) (any, error)
+
+// inAudit reports whether path is AuditDir or inside it
+func inAudit(path string) bool {
+	return path == AuditDir || strings.HasPrefix(path, AuditDir+"/")
+}
+
+// cutAudit returns the file of AuditDir that path names
+func cutAudit(path string) (string, bool) {
+	return strings.CutPrefix(path, AuditDir+"/")
+}
+
+func (a auditFiles) entries() []Entry {
+	entries := make([]Entry, 0, len(a))
+	for name := range a {
+		entries = append(entries, Entry{Name: name, IsDir: false, Size: 4096})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// stat returns the entry of AuditDir or one of its files, or false if path
+// is outside AuditDir
+func (a auditFiles) stat(path string) (*Entry, bool, error) {
+	if path == AuditDir {
+		return &Entry{Name: AuditDir, IsDir: true}, true, nil
+	}
+	file, ok := cutAudit(path)
+	if !ok {
+		return nil, false, nil
+	}
+	if a[file] == nil {
+		return nil, true, fmt.Errorf("path not found: %s", path)
+	}
+	return &Entry{Name: file, IsDir: false, Size: 4096}, true, nil
+}
+
+func (a auditFiles) read(ctx context.Context, file string) ([]byte, error) {
+	check := a[file]
+	if check == nil {
+		return nil, fmt.Errorf("unknown file: %s", file)
+	}
+	findings, err := check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(findings, "", "  ")
+}
+
+// PublicBucket is a finding of s3/_audit/public-buckets.json
+type PublicBucket struct {
+	Bucket string `json:"bucket"`
+	// PublicPolicy is set when the bucket policy grants access to anyone
+	PublicPolicy bool `json:"public_policy,omitempty"`
+	// PublicACL lists the ACL grants to everyone or to any AWS account,
+	// e.g. "AllUsers:READ"
+	PublicACL []string `json:"public_acl,omitempty"`
+	// BlockPublicAccess is set when all four public access block settings
+	// are on, which overrides the policy and ACL
+	BlockPublicAccess bool `json:"block_public_access"`
+	// Error is why the bucket couldn't be checked
+	Error string `json:"error,omitempty"`
+}
+
+// auditFiles are the checks under s3/_audit
+func (p *S3Provider) auditFiles() auditFiles {
+	return auditFiles{"public-buckets.json": p.publicBuckets}
+}
+
+// publicBuckets reports buckets whose policy or ACL makes them public,
+// and buckets that couldn't be checked
+func (p *S3Provider) publicBuckets(ctx context.Context) (any, error) {
+	buckets, err := p.listBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*PublicBucket, len(buckets))
+	FanOut(ctx, FanOutLimit, len(buckets), func(ctx context.Context, i int) error {
+		results[i] = p.checkBucket(ctx, buckets[i].Name)
+		return nil
+	})
+
+	findings := []*PublicBucket{}
+	for _, r := range results {
+		if r != nil && (r.PublicPolicy || len(r.PublicACL) > 0 || r.Error != "") {
+			findings = append(findings, r)
+		}
+	}
+	return findings, nil
+}
+
+// publicGrantees are the ACL grantee groups that make a bucket public
+var publicGrantees = map[string]string{
+	"http://acs.amazonaws.com/groups/global/AllUsers":           "AllUsers",
+	"http://acs.amazonaws.com/groups/global/AuthenticatedUsers": "AuthenticatedUsers",
+}
+
+func (p *S3Provider) checkBucket(ctx context.Context, bucket string) *PublicBucket {
+	b := &PublicBucket{Bucket: bucket}
+
+	status, err := p.client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: aws.String(bucket)})
+	switch {
+	case err == nil:
+		b.PublicPolicy = status.PolicyStatus != nil && aws.ToBool(status.PolicyStatus.IsPublic)
+	case !isAPIError(err, "NoSuchBucketPolicy"):
+		b.Error = err.Error()
+		return b
+	}
+
+	acl, err := p.client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+	for _, g := range acl.Grants {
+		if g.Grantee == nil || g.Grantee.Type != s3types.TypeGroup {
+			continue
+		}
+		if group, ok := publicGrantees[aws.ToString(g.Grantee.URI)]; ok {
+			b.PublicACL = append(b.PublicACL, group+":"+string(g.Permission))
+		}
+	}
+
+	block, err := p.client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucket)})
+	switch {
+	case err == nil:
+		c := block.PublicAccessBlockConfiguration
+		b.BlockPublicAccess = c != nil && aws.ToBool(c.BlockPublicAcls) && aws.ToBool(c.IgnorePublicAcls) &&
+			aws.ToBool(c.BlockPublicPolicy) && aws.ToBool(c.RestrictPublicBuckets)
+	case !isAPIError(err, "NoSuchPublicAccessBlockConfiguration"):
+		b.Error = err.Error()
+	}
+	return b
+}
+
+// isAPIError reports whether err is an AWS error with the given code
+func isAPIError(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// UnencryptedVolume is a finding of ec2/_audit/unencrypted-volumes.json
+type UnencryptedVolume struct {
+	VolumeID   string   `json:"volume_id"`
+	State      string   `json:"state"`
+	Size       int32    `json:"size_gib"`
+	Instances  []string `json:"instances,omitempty"`
+	SnapshotID string   `json:"snapshot_id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+}
+
+// auditFiles are the checks under ec2/_audit
+func (p *EC2Provider) auditFiles() auditFiles {
+	return auditFiles{"unencrypted-volumes.json": p.unencryptedVolumes}
+}
+
+// unencryptedVolumes lists the EBS volumes that aren't encrypted
+func (p *EC2Provider) unencryptedVolumes(ctx context.Context) (any, error) {
+	var volumes []ec2types.Volume
+	paginator := ec2.NewDescribeVolumesPaginator(p.client, &ec2.DescribeVolumesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("encrypted"), Values: []string{"false"}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, page.Volumes...)
+	}
+
+	findings := make([]UnencryptedVolume, 0, len(volumes))
+	for _, v := range volumes {
+		f := UnencryptedVolume{
+			VolumeID:   aws.ToString(v.VolumeId),
+			State:      string(v.State),
+			Size:       aws.ToInt32(v.Size),
+			SnapshotID: aws.ToString(v.SnapshotId),
+		}
+		for _, a := range v.Attachments {
+			f.Instances = append(f.Instances, aws.ToString(a.InstanceId))
+		}
+		for _, tag := range v.Tags {
+			if aws.ToString(tag.Key) == "Name" {
+				f.Name = aws.ToString(tag.Value)
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// OpenIngress is a finding of vpc/_audit/open-to-world.json: a security
+// group rule accepting traffic from any address
+type OpenIngress struct {
+	GroupID   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	VpcID     string `json:"vpc_id"`
+	Protocol  string `json:"protocol"`
+	// Ports is the port range, e.g. "22", "8000-8080", or "all"
+	Ports string `json:"ports"`
+	CIDR  string `json:"cidr"`
+}
+
+// auditFiles are the checks under vpc/_audit
+func (p *VPCProvider) auditFiles() auditFiles {
+	return auditFiles{"open-to-world.json": p.openToWorld}
+}
+
+// openToWorld lists the ingress rules of every security group in the
+// region that allow 0.0.0.0/0 or ::/0
+func (p *VPCProvider) openToWorld(ctx context.Context) (any, error) {
+	var groups []ec2types.SecurityGroup
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(p.client, &ec2.DescribeSecurityGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.SecurityGroups...)
+	}
+	return findOpenIngress(groups), nil
+}
+
+func findOpenIngress(groups []ec2types.SecurityGroup) []OpenIngress {
+	findings := []OpenIngress{}
+	for _, sg := range groups {
+		for _, perm := range sg.IpPermissions {
+			var open []string
+			for _, r := range perm.IpRanges {
+				if aws.ToString(r.CidrIp) == "0.0.0.0/0" {
+					open = append(open, "0.0.0.0/0")
+				}
+			}
+			for _, r := range perm.Ipv6Ranges {
+				if aws.ToString(r.CidrIpv6) == "::/0" {
+					open = append(open, "::/0")
+				}
+			}
+			for _, cidr := range open {
+				findings = append(findings, OpenIngress{
+					GroupID:   aws.ToString(sg.GroupId),
+					GroupName: aws.ToString(sg.GroupName),
+					VpcID:     aws.ToString(sg.VpcId),
+					Protocol:  ingressProtocol(aws.ToString(perm.IpProtocol)),
+					Ports:     ingressPorts(perm),
+					CIDR:      cidr,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// ingressProtocol names the protocol of a rule, which is "-1" for all
+func ingressProtocol(protocol string) string {
+	if protocol == "-1" {
+		return "all"
+	}
+	return protocol
+}
+
+func ingressPorts(perm ec2types.IpPermission) string {
+	if aws.ToString(perm.IpProtocol) == "-1" || perm.FromPort == nil {
+		return "all"
+	}
+	from, to := aws.ToInt32(perm.FromPort), aws.ToInt32(perm.ToPort)
+	if from == to {
+		return fmt.Sprint(from)
+	}
+	return fmt.Sprintf("%d-%d", from, to)
+}
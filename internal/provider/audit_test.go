@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAuditFiles(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		service string
+		file    string
+		prov    func(t *testing.T) Provider
+	}{
+		{"s3", "public-buckets.json", func(t *testing.T) Provider { cfg, _ := fixtureConfig(t, "s3"); return newS3Provider(cfg) }},
+		{"ec2", "unencrypted-volumes.json", func(t *testing.T) Provider { cfg, _ := fixtureConfig(t, "ec2"); return newEC2Provider(cfg) }},
+		{"vpc", "open-to-world.json", func(t *testing.T) Provider { cfg, _ := fixtureConfig(t, "vpc"); return newVPCProvider(cfg) }},
+	} {
+		p := tt.prov(t)
+		entries, err := p.ReadDir(ctx, AuditDir)
+		if err != nil || len(entries) != 1 || entries[0].Name != tt.file {
+			t.Errorf("%s: ReadDir = %v, %v", tt.service, entries, err)
+		}
+		if entry, err := p.Stat(ctx, AuditDir+"/"+tt.file); err != nil || entry.IsDir {
+			t.Errorf("%s: Stat = %v, %v", tt.service, entry, err)
+		}
+		if p.Writable(AuditDir + "/" + tt.file) {
+			t.Errorf("%s: audit file is writable", tt.service)
+		}
+		data, err := p.Read(ctx, AuditDir+"/"+tt.file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.service, err)
+		}
+		assertGolden(t, tt.service+"/audit-"+tt.file, data)
+	}
+}
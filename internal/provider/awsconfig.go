@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// LoadAWSConfig loads the shared AWS configuration for a profile and region.
+// An empty profile or region falls back to the SDK's default resolution.
+// Profiles given credentials with SetCredentials use those instead of the
+// shared config's.
+func LoadAWSConfig(profile, region string) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+
+	if creds, ok := credentialsFor(profile); ok {
+		opts = append(opts, config.WithCredentialsProvider(creds))
+	} else if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	cfg.APIOptions = append(cfg.APIOptions, Usage.apiOption, traceAPIOption)
+	return cfg, nil
+}
@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// azureStorageVersion is the Blob service API version; bearer tokens need 2017-11-09 or later
+const azureStorageVersion = "2021-08-06"
+
+// AzureBlobProvider provides read access to the blob containers of every
+// storage account in an Azure subscription, as <account>/<container>/<blob>
+type AzureBlobProvider struct {
+	ReadOnlyProvider
+	arm          *restClient
+	storage      *restClient
+	subscription string
+	armURL       string
+	accountURL   func(account string) string
+}
+
+// NewAzureBlobProvider creates a Blob storage provider for a subscription
+func NewAzureBlobProvider(subscription string) *AzureBlobProvider {
+	return newAzureBlobProvider(subscription,
+		newRESTClient(http.DefaultClient, AzureToken("https://management.azure.com/")),
+		newRESTClient(http.DefaultClient, AzureToken("https://storage.azure.com/")),
+		"https://management.azure.com",
+		func(account string) string { return "https://" + account + ".blob.core.windows.net" },
+	)
+}
+
+func newAzureBlobProvider(subscription string, arm, storage *restClient, armURL string, accountURL func(string) string) *AzureBlobProvider {
+	storage.header.Set("x-ms-version", azureStorageVersion)
+	return &AzureBlobProvider{
+		arm:          arm,
+		storage:      storage,
+		subscription: subscription,
+		armURL:       armURL,
+		accountURL:   accountURL,
+	}
+}
+
+func (p *AzureBlobProvider) Name() string {
+	return "blob"
+}
+
+// azureContainers is a page of List Containers
+type azureContainers struct {
+	Containers []struct {
+		Name         string `xml:"Name"`
+		LastModified string `xml:"Properties>Last-Modified"`
+	} `xml:"Containers>Container"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// azureBlobs is a page of List Blobs
+type azureBlobs struct {
+	Prefixes []string `xml:"Blobs>BlobPrefix>Name"`
+	Blobs    []struct {
+		Name          string `xml:"Name"`
+		LastModified  string `xml:"Properties>Last-Modified"`
+		ContentLength int64  `xml:"Properties>Content-Length"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (p *AzureBlobProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		names, err := armList(ctx, p.arm, p.armURL+"/subscriptions/"+url.PathEscape(p.subscription)+
+			"/providers/Microsoft.Storage/storageAccounts?api-version=2023-01-01")
+		if err != nil {
+			return nil, partialListing(dirEntries(names), "az storage account list --subscription "+p.subscription, err)
+		}
+		return dirEntries(names), nil
+	}
+
+	account, rest, _ := strings.Cut(path, "/")
+	if rest == "" {
+		return p.listContainers(ctx, account)
+	}
+
+	container, prefix, _ := strings.Cut(rest, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return p.listBlobs(ctx, account, container, prefix)
+}
+
+func (p *AzureBlobProvider) listContainers(ctx context.Context, account string) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		q := url.Values{"comp": {"list"}, "maxresults": {strconv.Itoa(min(MaxEntries, 5000))}}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		var page azureContainers
+		if err := p.getXML(ctx, p.accountURL(account)+"/?"+q.Encode(), &page); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(page.Containers))
+		for _, c := range page.Containers {
+			entries = append(entries, Entry{Name: c.Name, IsDir: true, ModTime: parseHTTPTime(c.LastModified)})
+		}
+		return entries, page.NextMarker, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, "az storage container list --account-name "+account, err)
+	}
+	return entries, nil
+}
+
+func (p *AzureBlobProvider) listBlobs(ctx context.Context, account, container, prefix string) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		q := url.Values{
+			"restype":    {"container"},
+			"comp":       {"list"},
+			"prefix":     {prefix},
+			"delimiter":  {"/"},
+			"maxresults": {strconv.Itoa(min(MaxEntries, 5000))},
+		}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		var page azureBlobs
+		if err := p.getXML(ctx, p.containerURL(account, container)+"?"+q.Encode(), &page); err != nil {
+			return nil, "", err
+		}
+
+		var entries []Entry
+		for _, bp := range page.Prefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(bp, prefix), "/")
+			if name != "" {
+				entries = append(entries, Entry{Name: name, IsDir: true})
+			}
+		}
+		for _, b := range page.Blobs {
+			name := strings.TrimPrefix(b.Name, prefix)
+			if name == "" {
+				continue
+			}
+			entries = append(entries, Entry{Name: name, Size: b.ContentLength, ModTime: parseHTTPTime(b.LastModified)})
+		}
+		return entries, page.NextMarker, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, azureBlobListHint(account, container, prefix), err)
+	}
+	return entries, nil
+}
+
+// azureBlobListHint returns the CLI command listing a container prefix in full
+func azureBlobListHint(account, container, prefix string) string {
+	return "az storage blob list --account-name " + account + " --container-name " + container + " --prefix " + prefix
+}
+
+func (p *AzureBlobProvider) containerURL(account, container string) string {
+	return p.accountURL(account) + "/" + url.PathEscape(container)
+}
+
+func (p *AzureBlobProvider) blobURL(account, container, key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return p.containerURL(account, container) + "/" + strings.Join(segments, "/")
+}
+
+func (p *AzureBlobProvider) getXML(ctx context.Context, url string, v any) error {
+	body, err := p.storage.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// splitBlobPath splits account/container/key, reporting whether all three are present
+func splitBlobPath(path string) (account, container, key string, ok bool) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+func (p *AzureBlobProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	account, container, key, ok := splitBlobPath(path)
+	if !ok {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	return p.storage.get(ctx, p.blobURL(account, container, key))
+}
+
+// ReadRange fetches part of a blob with a ranged GET
+func (p *AzureBlobProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	account, container, key, ok := splitBlobPath(path)
+	if !ok || length <= 0 {
+		data, err := p.Read(ctx, path)
+		return sliceRange(data, off, length), err
+	}
+	return p.storage.getRange(ctx, p.blobURL(account, container, key), off, length)
+}
+
+func (p *AzureBlobProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "blob", IsDir: true}, nil
+	}
+
+	account, container, key, ok := splitBlobPath(path)
+	if !ok {
+		// An account or container: it exists if it can be listed
+		if _, err := p.ReadDir(ctx, path); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: path, IsDir: true}, nil
+	}
+
+	// A "directory" is a prefix with blobs under it
+	q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {key + "/"}, "maxresults": {"1"}}
+	var page azureBlobs
+	if err := p.getXML(ctx, p.containerURL(account, container)+"?"+q.Encode(), &page); err == nil && len(page.Blobs) > 0 {
+		return &Entry{Name: key, IsDir: true}, nil
+	}
+
+	resp, err := p.storage.do(ctx, http.MethodHead, p.blobURL(account, container, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &Entry{Name: key, Size: resp.ContentLength, ModTime: parseHTTPTime(resp.Header.Get("Last-Modified"))}, nil
+}
+
+// armList follows an Azure Resource Manager listing through its nextLink
+// pages and returns the resource names, reporting whether more were left
+func armList(ctx context.Context, arm *restClient, url string) ([]string, error) {
+	names, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, next string) ([]string, string, error) {
+		if next == "" {
+			next = url
+		}
+		var page struct {
+			Value []struct {
+				Name string `json:"name"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := arm.getJSON(ctx, next, &page); err != nil {
+			return nil, "", err
+		}
+		names := make([]string, 0, len(page.Value))
+		for _, v := range page.Value {
+			names = append(names, v.Name)
+		}
+		return names, page.NextLink, nil
+	})
+	return names, err
+}
+
+// dirEntries returns a directory entry for each name
+func dirEntries(names []string) []Entry {
+	entries := make([]Entry, len(names))
+	for i, name := range names {
+		entries[i] = Entry{Name: name, IsDir: true}
+	}
+	return entries
+}
+
+// parseHTTPTime parses an RFC 1123 timestamp, returning the zero time if it's malformed
+func parseHTTPTime(s string) time.Time {
+	t, _ := http.ParseTime(s)
+	return t
+}
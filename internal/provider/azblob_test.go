@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestAzureBlobProvider(t *testing.T) *AzureBlobProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions/sub-1/providers/Microsoft.Storage/storageAccounts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value": [{"name": "mystorage"}]}`))
+	})
+	mux.HandleFunc("/mystorage/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-ms-version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Containers><Container><Name>assets</Name><Properties><Last-Modified>Wed, 01 May 2024 10:00:00 GMT</Last-Modified></Properties></Container></Containers><NextMarker/></EnumerationResults>`))
+	})
+	mux.HandleFunc("/mystorage/assets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs><BlobPrefix><Name>img/</Name></BlobPrefix><Blob><Name>index.html</Name><Properties><Last-Modified>Thu, 02 May 2024 10:00:00 GMT</Last-Modified><Content-Length>15</Content-Length></Properties></Blob></Blobs><NextMarker/></EnumerationResults>`))
+	})
+	mux.HandleFunc("/mystorage/assets/index.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>hello</h1>\n"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	rest := newRESTClient(srv.Client(), staticToken("token"))
+	return newAzureBlobProvider("sub-1", rest, newRESTClient(srv.Client(), staticToken("token")), srv.URL,
+		func(account string) string { return srv.URL + "/" + account })
+}
+
+func TestAzureBlobReadDir(t *testing.T) {
+	p := newTestAzureBlobProvider(t)
+	ctx := context.Background()
+
+	accounts, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Name != "mystorage" || !accounts[0].IsDir {
+		t.Errorf("accounts = %+v", accounts)
+	}
+
+	containers, err := p.ReadDir(ctx, "mystorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "azblob/containers.json", containers)
+
+	blobs, err := p.ReadDir(ctx, "mystorage/assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "azblob/blobs.json", blobs)
+
+	data, err := p.Read(ctx, "mystorage/assets/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "<h1>hello</h1>\n" {
+		t.Errorf("Read = %q", data)
+	}
+}
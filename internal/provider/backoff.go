@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// Cache TTL backoff: each throttled call doubles a service's TTLs, at most
+// once per backoffStep and up to MaxBackoffFactor times the policy, and
+// every backoffDecay without throttling halves them again
+const (
+	MaxBackoffFactor = 8
+	backoffStep      = 10 * time.Second
+	backoffDecay     = 2 * time.Minute
+)
+
+var throttles = retry.IsErrorThrottles(retry.DefaultThrottles)
+
+// IsThrottled reports whether err is AWS throttling requests
+func IsThrottled(err error) bool {
+	return err != nil && throttles.IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// BackoffState is a service's throttling backoff as shown in stats.json
+type BackoffState struct {
+	Factor       float64   `json:"ttl_factor"`
+	Throttles    int64     `json:"throttles"`
+	LastThrottle time.Time `json:"last_throttle"`
+}
+
+// Backoff stretches a service's cache TTLs while AWS throttles it, so busy
+// shared accounts see fewer requests from the mount. It is safe for
+// concurrent use.
+type Backoff struct {
+	mu        sync.Mutex
+	factor    float64
+	changed   time.Time // when factor last rose or decayed
+	throttles int64
+	last      time.Time
+}
+
+// NewBackoff returns a backoff that doesn't stretch TTLs until throttled
+func NewBackoff() *Backoff {
+	return &Backoff{factor: 1}
+}
+
+// Middleware returns a middleware that raises the backoff on every
+// throttled call. It belongs beneath Retry so retried throttles count.
+func (b *Backoff) Middleware() Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		err := call(ctx)
+		if IsThrottled(err) {
+			b.throttled(time.Now())
+		}
+		return err
+	})
+}
+
+func (b *Backoff) throttled(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decay(now)
+	b.throttles++
+	b.last = now
+	if b.factor < MaxBackoffFactor && now.Sub(b.changed) >= backoffStep {
+		b.factor *= 2
+		b.changed = now
+	}
+}
+
+// decay halves the factor for every backoffDecay since it last changed.
+// Callers must hold mu.
+func (b *Backoff) decay(now time.Time) {
+	for b.factor > 1 && now.Sub(b.changed) >= backoffDecay {
+		b.factor /= 2
+		b.changed = b.changed.Add(backoffDecay)
+	}
+}
+
+// Scale returns ttl stretched by the current backoff
+func (b *Backoff) Scale(ttl time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decay(time.Now())
+	return time.Duration(float64(ttl) * b.factor)
+}
+
+// State returns the current backoff
+func (b *Backoff) State() BackoffState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decay(time.Now())
+	return BackoffState{Factor: b.factor, Throttles: b.throttles, LastThrottle: b.last}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestIsThrottled(t *testing.T) {
+	if !IsThrottled(&smithy.GenericAPIError{Code: "ThrottlingException"}) {
+		t.Error("ThrottlingException is throttling")
+	}
+	if IsThrottled(&smithy.GenericAPIError{Code: "AccessDenied"}) || IsThrottled(errors.New("boom")) || IsThrottled(nil) {
+		t.Error("other errors aren't throttling")
+	}
+}
+
+func TestBackoffRisesAndDecays(t *testing.T) {
+	b := NewBackoff()
+	start := time.Now().Add(-time.Hour)
+
+	// A burst counts once per step
+	b.throttled(start)
+	b.throttled(start.Add(time.Second))
+	if b.factor != 2 || b.throttles != 2 {
+		t.Fatalf("after a burst: factor %v, %d throttles; want 2, 2", b.factor, b.throttles)
+	}
+	for i := 1; i <= 5; i++ {
+		b.throttled(start.Add(time.Duration(i) * backoffStep))
+	}
+	if b.factor != MaxBackoffFactor {
+		t.Fatalf("factor = %v, want the cap %v", b.factor, MaxBackoffFactor)
+	}
+
+	b.decay(b.changed.Add(backoffDecay))
+	if b.factor != MaxBackoffFactor/2 {
+		t.Errorf("factor after one quiet period = %v, want %v", b.factor, MaxBackoffFactor/2)
+	}
+	if got := b.Scale(time.Minute); got != time.Minute {
+		t.Errorf("Scale long after throttling = %v, want the policy TTL", got)
+	}
+}
+
+func TestCachedStretchesTTLWhenThrottled(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("x")})
+	b := NewBackoff()
+	p := Cached(Chain(fake, b.Middleware()), CachePolicy{Read: time.Minute, MaxReadSize: 1 << 20}).Throttled(b)
+	ctx := context.Background()
+
+	fake.fail = &smithy.GenericAPIError{Code: "Throttling"}
+	if _, err := p.ReadDir(ctx, "a"); err == nil {
+		t.Fatal("expected the throttling error")
+	}
+	fake.fail = nil
+	if b.State().Throttles != 1 {
+		t.Fatalf("State = %+v, want one throttle", b.State())
+	}
+
+	if _, err := p.Read(ctx, "a/x"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := p.ttl(time.Minute); ttl != 2*time.Minute {
+		t.Errorf("ttl = %v, want doubled", ttl)
+	}
+}
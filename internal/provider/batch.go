@@ -0,0 +1,378 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// BatchProvider provides AWS Batch compute environments and job queues:
+//
+//	compute-environments/<name>.json  the environment: state, status, instance types, scaling
+//	job-queues/<name>/info.json       the queue: state, priority, compute environment order
+//	job-queues/<name>/recent-jobs.json  its jobs of the last day, newest first
+type BatchProvider struct {
+	ReadOnlyProvider
+	client *restJSONClient
+	region string
+	lists  *documents[cappedList[map[string]map[string]any]] // environments or queues by name, under the directory
+	now    func() time.Time
+}
+
+// Directories of the batch service
+const (
+	batchEnvironmentsDir = "compute-environments"
+	batchQueuesDir       = "job-queues"
+)
+
+// Files of a job queue directory
+const (
+	batchQueueInfoFile = "info.json"
+	batchRecentJobs    = "recent-jobs.json"
+)
+
+// BatchRecentJobs caps the jobs in recent-jobs.json, the newest of those
+// created within batchRecentWindow
+const BatchRecentJobs = 100
+
+const batchRecentWindow = 24 * time.Hour
+
+// batchDefaultLogGroup is where job containers log unless their log
+// configuration names another group
+const batchDefaultLogGroup = "/aws/batch/job"
+
+// NewBatchProvider creates a new Batch provider
+func NewBatchProvider(profile, region string) (*BatchProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchProvider(cfg), nil
+}
+
+func newBatchProvider(cfg aws.Config) *BatchProvider {
+	return &BatchProvider{
+		client: newRESTJSONClient(cfg, "Batch", "batch"),
+		region: cfg.Region,
+		lists:  newDocuments[cappedList[map[string]map[string]any]](),
+		now:    time.Now,
+	}
+}
+
+func (p *BatchProvider) Name() string {
+	return "batch"
+}
+
+// Invalidate forgets the memoized environment and queue lists, so a refresh describes them anew
+func (p *BatchProvider) Invalidate(string) {
+	forgetDocuments(p.lists)
+}
+
+func (p *BatchProvider) Close() {
+	closeDocuments(p.lists)
+}
+
+// batchList describes how a directory's resources are listed
+var batchList = map[string]struct {
+	op, path, key, nameKey, hint string
+}{
+	batchEnvironmentsDir: {"DescribeComputeEnvironments", "/v1/describecomputeenvironments", "computeEnvironments", "computeEnvironmentName", "aws batch describe-compute-environments"},
+	batchQueuesDir:       {"DescribeJobQueues", "/v1/describejobqueues", "jobQueues", "jobQueueName", "aws batch describe-job-queues"},
+}
+
+// list returns the compute environments or job queues of the region by
+// name, up to MaxEntries of them
+func (p *BatchProvider) list(ctx context.Context, dir string) (cappedList[map[string]map[string]any], error) {
+	l := batchList[dir]
+	return p.lists.get(dir, func() (cappedList[map[string]map[string]any], error) {
+		resources := make(map[string]map[string]any)
+		in := map[string]any{"maxResults": 100}
+		for {
+			var resp map[string]json.RawMessage
+			if err := p.client.do(ctx, l.op, "POST", l.path, nil, in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			var page []map[string]any
+			if raw, ok := resp[l.key]; ok {
+				if err := json.Unmarshal(raw, &page); err != nil {
+					return cappedList[map[string]map[string]any]{}, err
+				}
+			}
+			for _, r := range page {
+				if name, ok := r[l.nameKey].(string); ok {
+					resources[name] = r
+				}
+			}
+			var token string
+			if raw, ok := resp["nextToken"]; ok {
+				json.Unmarshal(raw, &token)
+			}
+			if token == "" || len(resources) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: resources, more: token != ""}, nil
+			}
+			in = map[string]any{"maxResults": 100, "nextToken": token}
+		}
+	})
+}
+
+// lookup returns the named environment or queue, or an error wrapping
+// os.ErrNotExist
+func (p *BatchProvider) lookup(ctx context.Context, dir, name string) (map[string]any, error) {
+	resources, err := p.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := resources.items[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found: %s: %w", dir, name, os.ErrNotExist)
+	}
+	return r, nil
+}
+
+func (p *BatchProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		return []Entry{
+			{Name: batchEnvironmentsDir, IsDir: true},
+			{Name: batchQueuesDir, IsDir: true},
+		}, nil
+	}
+	dir, name, nested := strings.Cut(path, "/")
+	l, ok := batchList[dir]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("unknown path: %s", path)
+	case nested:
+		if dir != batchQueuesDir || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("unknown path: %s", path)
+		}
+		if _, err := p.lookup(ctx, dir, name); err != nil {
+			return nil, err
+		}
+		return []Entry{
+			{Name: batchQueueInfoFile, IsDir: false, Size: 4096},
+			{Name: batchRecentJobs, IsDir: false, Size: 4096},
+		}, nil
+	}
+
+	resources, err := p.list(ctx, dir)
+	if err != nil {
+		return nil, partialListing(nil, l.hint, err)
+	}
+	entries := make([]Entry, 0, len(resources.items))
+	for name := range resources.items {
+		if dir == batchEnvironmentsDir {
+			entries = append(entries, Entry{Name: name + ".json", IsDir: false, Size: 4096})
+		} else {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return capEntries(entries, resources.more, l.hint), nil
+}
+
+func (p *BatchProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == batchEnvironmentsDir && strings.HasSuffix(parts[1], ".json"):
+		env, err := p.lookup(ctx, batchEnvironmentsDir, strings.TrimSuffix(parts[1], ".json"))
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(env, "", "  ")
+	case len(parts) == 3 && parts[0] == batchQueuesDir && parts[2] == batchQueueInfoFile:
+		queue, err := p.lookup(ctx, batchQueuesDir, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(queue, "", "  ")
+	case len(parts) == 3 && parts[0] == batchQueuesDir && parts[2] == batchRecentJobs:
+		if _, err := p.lookup(ctx, batchQueuesDir, parts[1]); err != nil {
+			return nil, err
+		}
+		jobs, err := p.recentJobs(ctx, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(jobs, "", "  ")
+	}
+	return nil, fmt.Errorf("invalid path: %s", path)
+}
+
+// batchJob is a job as shown in recent-jobs.json
+type batchJob struct {
+	JobID         string     `json:"jobId"`
+	JobName       string     `json:"jobName"`
+	Status        string     `json:"status"`
+	StatusReason  string     `json:"statusReason,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	StoppedAt     *time.Time `json:"stoppedAt,omitempty"`
+	ExitCode      *int       `json:"exitCode,omitempty"`
+	JobDefinition string     `json:"jobDefinition,omitempty"`
+	LogGroup      string     `json:"logGroup,omitempty"`
+	LogStream     string     `json:"logStream,omitempty"`
+	LogURL        string     `json:"logUrl,omitempty"` // the stream in the CloudWatch console
+}
+
+// batchJobDetail is the part of DescribeJobs sisu shows
+type batchJobDetail struct {
+	JobID         string `json:"jobId"`
+	JobName       string `json:"jobName"`
+	Status        string `json:"status"`
+	StatusReason  string `json:"statusReason"`
+	CreatedAt     int64  `json:"createdAt"`
+	StartedAt     int64  `json:"startedAt"`
+	StoppedAt     int64  `json:"stoppedAt"`
+	JobDefinition string `json:"jobDefinition"`
+	Container     *struct {
+		ExitCode         *int   `json:"exitCode"`
+		LogStreamName    string `json:"logStreamName"`
+		LogConfiguration *struct {
+			LogDriver string            `json:"logDriver"`
+			Options   map[string]string `json:"options"`
+		} `json:"logConfiguration"`
+	} `json:"container"`
+}
+
+// recentJobs returns the queue's jobs created in the last day, newest
+// first, with where their containers log. ListJobs doesn't sort by
+// creation time, so every job of the day is listed to find the newest.
+func (p *BatchProvider) recentJobs(ctx context.Context, queue string) ([]batchJob, error) {
+	type summary struct {
+		JobID     string `json:"jobId"`
+		CreatedAt int64  `json:"createdAt"`
+	}
+	since := p.now().Add(-batchRecentWindow).UnixMilli()
+	var summaries []summary
+	in := map[string]any{
+		"jobQueue": queue,
+		// Filtering by creation time lists jobs of every status, rather
+		// than only running ones
+		"filters":    []map[string]any{{"name": "AFTER_CREATED_AT", "values": []string{strconv.FormatInt(since, 10)}}},
+		"maxResults": 100,
+	}
+	for {
+		var resp struct {
+			JobSummaryList []summary `json:"jobSummaryList"`
+			NextToken      string    `json:"nextToken"`
+		}
+		if err := p.client.do(ctx, "ListJobs", "POST", "/v1/listjobs", nil, in, &resp); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, resp.JobSummaryList...)
+		if resp.NextToken == "" {
+			break
+		}
+		in["nextToken"] = resp.NextToken
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].CreatedAt > summaries[j].CreatedAt })
+	if len(summaries) > BatchRecentJobs {
+		summaries = summaries[:BatchRecentJobs]
+	}
+
+	jobs := make([]batchJob, 0, len(summaries))
+	if len(summaries) == 0 {
+		return jobs, nil
+	}
+	ids := make([]string, len(summaries))
+	for i, s := range summaries {
+		ids[i] = s.JobID
+	}
+	var resp struct {
+		Jobs []batchJobDetail `json:"jobs"`
+	}
+	if err := p.client.do(ctx, "DescribeJobs", "POST", "/v1/describejobs", nil, map[string]any{"jobs": ids}, &resp); err != nil {
+		return nil, err
+	}
+	for _, d := range resp.Jobs {
+		jobs = append(jobs, p.batchJob(d))
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+func (p *BatchProvider) batchJob(d batchJobDetail) batchJob {
+	job := batchJob{
+		JobID:         d.JobID,
+		JobName:       d.JobName,
+		Status:        d.Status,
+		StatusReason:  d.StatusReason,
+		CreatedAt:     time.UnixMilli(d.CreatedAt).UTC(),
+		StartedAt:     batchTime(d.StartedAt),
+		StoppedAt:     batchTime(d.StoppedAt),
+		JobDefinition: d.JobDefinition,
+	}
+	if c := d.Container; c != nil {
+		job.ExitCode = c.ExitCode
+		if c.LogStreamName != "" {
+			job.LogGroup = batchDefaultLogGroup
+			if lc := c.LogConfiguration; lc != nil && lc.Options["awslogs-group"] != "" {
+				job.LogGroup = lc.Options["awslogs-group"]
+			}
+			job.LogStream = c.LogStreamName
+			job.LogURL = cloudWatchLogURL(p.region, job.LogGroup, job.LogStream)
+		}
+	}
+	return job
+}
+
+// batchTime converts epoch milliseconds, 0 for unset
+func batchTime(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
+}
+
+// cloudWatchLogURL links to a log stream in the CloudWatch console, whose
+// fragment escapes the group and stream twice, with $ for %
+func cloudWatchLogURL(region, group, stream string) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.PathEscape(url.PathEscape(s)), "%", "$")
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#logsV2:log-groups/log-group/%s/log-events/%s",
+		region, region, escape(group), escape(stream))
+}
+
+func (p *BatchProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "batch", IsDir: true}, nil
+	}
+	parts := strings.Split(path, "/")
+	if _, ok := batchList[parts[0]]; !ok {
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	switch {
+	case len(parts) == 1:
+		return &Entry{Name: parts[0], IsDir: true}, nil
+	case len(parts) == 2 && parts[0] == batchEnvironmentsDir:
+		name, ok := strings.CutSuffix(parts[1], ".json")
+		if !ok {
+			return nil, fmt.Errorf("path not found: %s: %w", path, os.ErrNotExist)
+		}
+		if _, err := p.lookup(ctx, parts[0], name); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+	case parts[0] == batchQueuesDir && len(parts) <= 3:
+		if _, err := p.lookup(ctx, parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+		if len(parts) == 2 {
+			return &Entry{Name: parts[1], IsDir: true}, nil
+		}
+		if parts[2] == batchQueueInfoFile || parts[2] == batchRecentJobs {
+			return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+		}
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
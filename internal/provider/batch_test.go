@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatchQueues(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "batch")
+	p := newBatchProvider(cfg)
+	p.now = func() time.Time { return time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	envs, err := p.ReadDir(ctx, "compute-environments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(envs); !reflect.DeepEqual(names, []string{"spot.json"}) {
+		t.Errorf("compute environments = %v", names)
+	}
+	queues, err := p.ReadDir(ctx, "job-queues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(queues); !reflect.DeepEqual(names, []string{"adhoc", "nightly"}) {
+		t.Fatalf("job queues = %v", names)
+	}
+
+	data, err := p.Read(ctx, "job-queues/nightly/recent-jobs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "batch/recent-jobs.json", data)
+}
+
+func TestCloudWatchLogURL(t *testing.T) {
+	got := cloudWatchLogURL("us-east-1", "/aws/batch/job", "etl/default/0a1b")
+	want := "https://us-east-1.console.aws.amazon.com/cloudwatch/home?region=us-east-1#logsV2:log-groups/log-group/$252Faws$252Fbatch$252Fjob/log-events/etl$252Fdefault$252F0a1b"
+	if got != want {
+		t.Errorf("cloudWatchLogURL = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,454 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/cache"
+)
+
+// CachePolicy configures how long each operation's results are cached.
+// A zero TTL disables caching for that operation.
+type CachePolicy struct {
+	ReadDir time.Duration
+	Read    time.Duration
+	Stat    time.Duration
+
+	// MaxReadSize is the largest file content kept in the cache; bigger
+	// reads (e.g. large S3 objects) are always fetched fresh.
+	MaxReadSize int64
+
+	// CompressAbove is the size from which file contents are kept
+	// compressed in the cache; 0 keeps them all as they are.
+	CompressAbove int64
+}
+
+// DefaultCachePolicy caches everything for 5 minutes, skips contents over
+// 1 MB and compresses those over 16 KB
+var DefaultCachePolicy = CachePolicy{
+	ReadDir:       5 * time.Minute,
+	Read:          5 * time.Minute,
+	Stat:          5 * time.Minute,
+	MaxReadSize:   1 << 20,
+	CompressAbove: 16 << 10,
+}
+
+// Invalidator is implemented by providers that can drop cached state for a path
+type Invalidator interface {
+	Invalidate(path string)
+}
+
+// Invalidate drops p's cached state for path, if p keeps any
+func Invalidate(p Provider, path string) {
+	if inv, ok := p.(Invalidator); ok {
+		inv.Invalidate(path)
+	}
+}
+
+// Closer is implemented by providers running background work, such as
+// sweeping expired cache entries, to stop once they are dropped
+type Closer interface {
+	Close()
+}
+
+// Close stops p's background work, if it runs any. p shouldn't be used
+// afterwards.
+func Close(p Provider) {
+	if c, ok := p.(Closer); ok {
+		c.Close()
+	}
+}
+
+// CachedProvider serves repeated reads from a TTL cache and evicts
+// affected entries when the wrapped provider mutates a path.
+// Errors are never cached.
+type CachedProvider struct {
+	Provider
+	policy  CachePolicy
+	cache   *cache.Cache
+	backoff *Backoff
+
+	// listingStats is set by StatFromListings
+	listingStats bool
+
+	// expanded serves ReadRange from compressed contents
+	expanded lastExpanded
+
+	// listings and onChange are set by Observe
+	listings *listings
+	onChange func(Change)
+}
+
+// Cached wraps p with a result cache governed by policy
+func Cached(p Provider, policy CachePolicy) *CachedProvider {
+	return &CachedProvider{
+		Provider: p,
+		policy:   policy,
+		cache:    cache.New(maxTTL(policy)),
+	}
+}
+
+// Throttled stretches the cache TTLs by b while AWS throttles the service
+func (p *CachedProvider) Throttled(b *Backoff) *CachedProvider {
+	p.backoff = b
+	return p
+}
+
+// StatFromListings lets StatBatch answer from cached listings, for
+// providers whose listings carry the same details as Stat (see
+// ListingStats). Other providers list files without sizes or with
+// placeholders, e.g. IAM documents at 0 bytes.
+func (p *CachedProvider) StatFromListings() *CachedProvider {
+	p.listingStats = true
+	return p
+}
+
+// Observe calls fn with the differences between each fetched listing and
+// the previous listing of the same directory
+func (p *CachedProvider) Observe(fn func(Change)) *CachedProvider {
+	p.listings = newListings()
+	p.onChange = fn
+	return p
+}
+
+// ttl returns the TTL to cache a result for, given the policy's
+func (p *CachedProvider) ttl(policy time.Duration) time.Duration {
+	if p.backoff == nil {
+		return policy
+	}
+	return p.backoff.Scale(policy)
+}
+
+func maxTTL(policy CachePolicy) time.Duration {
+	ttl := policy.ReadDir
+	if policy.Read > ttl {
+		ttl = policy.Read
+	}
+	if policy.Stat > ttl {
+		ttl = policy.Stat
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return ttl
+}
+
+func (p *CachedProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	cacheKey := "readdir:" + path
+	if cached, ok := p.cache.Get(cacheKey); ok {
+		return cached.([]Entry), nil
+	}
+
+	entries, _, err := p.list(ctx, path)
+	return entries, err
+}
+
+// list fetches the listing of path, caches it and reports how it differs
+// from the previous one
+func (p *CachedProvider) list(ctx context.Context, path string) ([]Entry, []Change, error) {
+	entries, err := p.Provider.ReadDir(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.policy.ReadDir > 0 {
+		p.cache.SetWithTTL("readdir:"+path, entries, p.ttl(p.policy.ReadDir))
+	}
+	var changes []Change
+	if p.listings != nil {
+		changes = p.listings.observe(path, entries, time.Now())
+		for _, c := range changes {
+			p.onChange(c)
+		}
+	}
+	return entries, changes, nil
+}
+
+func (p *CachedProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	if data, ok := p.cachedRead(path); ok {
+		return data, nil
+	}
+
+	// Siblings fetched by the same call are cached too, so opening the
+	// other files of an instance or function costs no further requests
+	files, err := Prefetch(ctx, p.Provider, path)
+	if err != nil {
+		return nil, err
+	}
+	if p.policy.Read > 0 {
+		for name, data := range files {
+			if int64(len(data)) <= p.policy.MaxReadSize {
+				p.cacheRead(name, data)
+			}
+		}
+	}
+	return files[path], nil
+}
+
+// cacheRead keeps data for later reads of path, compressed if it's large
+func (p *CachedProvider) cacheRead(path string, data []byte) {
+	var value interface{} = data
+	if p.policy.CompressAbove > 0 && int64(len(data)) >= p.policy.CompressAbove {
+		if c, ok := compress(data); ok {
+			value = c
+		}
+	}
+	p.cache.SetWithTTL("read:"+path, value, p.ttl(p.policy.Read))
+}
+
+// cachedRead returns the cached content of path
+func (p *CachedProvider) cachedRead(path string) ([]byte, bool) {
+	cached, ok := p.cache.Get("read:" + path)
+	if !ok {
+		return nil, false
+	}
+	if c, ok := cached.(*compressed); ok {
+		data, err := c.expand()
+		return data, err == nil
+	}
+	return cached.([]byte), true
+}
+
+// ReadRange serves ranges from a cached full read when there is one.
+// Ranges themselves aren't cached; they're used for files too big to cache.
+func (p *CachedProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	if data, ok := p.cachedRange(path); ok {
+		return sliceRange(data, off, length), nil
+	}
+	return ReadRange(ctx, p.Provider, path, off, length)
+}
+
+// cachedRange returns the cached content of path to slice ranges from.
+// Unlike cachedRead, it reuses the content decompressed last, which is
+// shared and must not be modified.
+func (p *CachedProvider) cachedRange(path string) ([]byte, bool) {
+	cached, ok := p.cache.Get("read:" + path)
+	if !ok {
+		return nil, false
+	}
+	if c, ok := cached.(*compressed); ok {
+		data, err := p.expanded.get(c)
+		return data, err == nil
+	}
+	return cached.([]byte), true
+}
+
+func (p *CachedProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	cacheKey := "stat:" + path
+	if cached, ok := p.cache.Get(cacheKey); ok {
+		return cached.(*Entry), nil
+	}
+
+	entry, err := p.Provider.Stat(ctx, path)
+	if err == nil && p.policy.Stat > 0 {
+		p.cache.SetWithTTL(cacheKey, entry, p.ttl(p.policy.Stat))
+	}
+	return entry, err
+}
+
+// StatBatch answers from cached stats and, with StatFromListings, cached
+// listings of the paths' parents where it can and batches the rest. Entries from listings are kept
+// apart from Stat results, which can carry more (e.g. S3 checksums).
+func (p *CachedProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	found := make(map[string]*Entry, len(paths))
+	var missing []string
+	for _, path := range paths {
+		entry, known := p.cachedStat(path)
+		switch {
+		case entry != nil:
+			found[path] = entry
+		case !known:
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	entries, err := StatBatch(ctx, p.Provider, missing)
+	if err != nil {
+		return nil, err
+	}
+	for path, entry := range entries {
+		if p.policy.Stat > 0 {
+			p.cache.SetWithTTL("lookup:"+path, entry, p.ttl(p.policy.Stat))
+		}
+		found[path] = entry
+	}
+	return found, nil
+}
+
+// cachedStat returns the cached entry for path. known is also true when a
+// complete cached listing of its parent shows path doesn't exist.
+func (p *CachedProvider) cachedStat(path string) (entry *Entry, known bool) {
+	for _, key := range []string{"stat:" + path, "lookup:" + path} {
+		if cached, ok := p.cache.Get(key); ok {
+			return cached.(*Entry), true
+		}
+	}
+	if !p.listingStats {
+		return nil, false
+	}
+	dir, name := splitParent(path)
+	if cached, ok := p.cache.Get("readdir:" + dir); ok {
+		entry, complete := listedEntry(cached.([]Entry), name)
+		return entry, entry != nil || complete
+	}
+	return nil, false
+}
+
+// Write evicts what path affects and, when the provider knows what path
+// reads back as, caches that content and lists the file in its cached
+// directory right away, so the change is visible before the service's own
+// listings and reads catch up.
+func (p *CachedProvider) Write(ctx context.Context, path string, data []byte) error {
+	if err := p.Provider.Write(ctx, path, data); err != nil {
+		return err
+	}
+	p.wrote(path)
+	dir, _ := splitParent(path)
+	listing, listed := p.cache.Get("readdir:" + dir)
+	p.Invalidate(path)
+	if content, ok := Written(p.Provider, path, data); ok {
+		var entries []Entry
+		if listed {
+			entries = listing.([]Entry)
+		}
+		p.writeThrough(path, content, entries)
+	}
+	return nil
+}
+
+// writeThrough caches content written to path, and its entry in listing,
+// the cached listing of its directory before the write. Truncated or paged
+// listings are left to be fetched again, as the file may belong on a page
+// not listed. The entry is kept apart from Stat results, which can carry
+// more (e.g. S3 checksums).
+func (p *CachedProvider) writeThrough(path string, content []byte, listing []Entry) {
+	dir, name := splitParent(path)
+	entry := Entry{Name: name, Size: int64(len(content)), ModTime: time.Now()}
+	if p.policy.Read > 0 && entry.Size <= p.policy.MaxReadSize {
+		p.cacheRead(path, content)
+	}
+	if p.policy.Stat > 0 {
+		p.cache.SetWithTTL("lookup:"+path, &entry, p.ttl(p.policy.Stat))
+	}
+	if listing == nil || p.policy.ReadDir <= 0 {
+		return
+	}
+	if _, complete := listedEntry(listing, name); !complete {
+		return
+	}
+	// Copied, as readers may still hold the cached slice
+	entries := make([]Entry, 0, len(listing)+1)
+	for _, e := range listing {
+		if e.Name != name {
+			entries = append(entries, e)
+		}
+	}
+	entries = append(entries, entry)
+	p.cache.SetWithTTL("readdir:"+dir, entries, p.ttl(p.policy.ReadDir))
+}
+
+func (p *CachedProvider) Delete(ctx context.Context, path string) error {
+	err := p.Provider.Delete(ctx, path)
+	if err == nil {
+		p.wrote(path)
+		p.Invalidate(path)
+	}
+	return err
+}
+
+// wrote keeps a change made through sisu out of the observed changes
+func (p *CachedProvider) wrote(path string) {
+	if p.listings != nil {
+		p.listings.wrote(path)
+	}
+}
+
+// Invalidate drops cached state for path and the listings of all its
+// ancestors, since a new file may also create intermediate directories.
+func (p *CachedProvider) Invalidate(path string) {
+	p.cache.Delete("stat:" + path)
+	p.cache.Delete("lookup:" + path)
+	p.cache.Delete("read:" + path)
+	p.cache.Delete("readdir:" + path)
+
+	for {
+		idx := strings.LastIndex(path, "/")
+		if idx < 0 {
+			p.cache.Delete("readdir:")
+			return
+		}
+		path = path[:idx]
+		p.cache.Delete("readdir:" + path)
+		p.cache.Delete("stat:" + path)
+		p.cache.Delete("lookup:" + path)
+	}
+}
+
+// Close stops the cache's sweeping, and that of the providers beneath it
+func (p *CachedProvider) Close() {
+	p.cache.Close()
+	Close(p.Provider)
+}
+
+// refreshMaxDirs caps the directories a Refresh lists again, so refreshing
+// near the top of a large tree doesn't relist all of it at once
+var refreshMaxDirs = 100
+
+// Refresh drops everything cached at or below dir and lists dir again,
+// along with the directories below it listed since they were last
+// refreshed, shallowest first and up to refreshMaxDirs in all, returning
+// how those listings changed. The rest are listed again when next read,
+// when Observe learns of their changes. Directories that no longer exist
+// are skipped; their parent's listing reports them removed. Changes are
+// only known for directories listed before, so none are reported without
+// Observe.
+func (p *CachedProvider) Refresh(ctx context.Context, dir string) ([]Change, error) {
+	under := func(path string) bool {
+		return dir == "" || path == dir || strings.HasPrefix(path, dir+"/")
+	}
+	var stale []string
+	p.cache.Range(func(key string, _ interface{}) {
+		if _, path, ok := strings.Cut(key, ":"); ok && under(path) {
+			stale = append(stale, key)
+		}
+	})
+	for _, key := range stale {
+		p.cache.Delete(key)
+	}
+	p.Invalidate(dir)
+	// The provider's memoized descriptions would answer the relisting
+	// below with what the cache just dropped
+	Invalidate(p.Provider, dir)
+
+	dirs := []string{dir}
+	if p.listings != nil {
+		dirs = p.listings.dirsUnder(dir)
+		slices.SortStableFunc(dirs[1:], func(a, b string) int {
+			return strings.Count(a, "/") - strings.Count(b, "/")
+		})
+		dirs = dirs[:min(len(dirs), refreshMaxDirs)]
+	}
+	var changes []Change
+	for _, d := range dirs {
+		_, c, err := p.list(ctx, d)
+		if errors.Is(err, fs.ErrNotExist) && d != dir {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, c...)
+	}
+	return changes, nil
+}
+
+// Clear drops every cached entry
+func (p *CachedProvider) Clear() {
+	p.cache.Clear()
+}
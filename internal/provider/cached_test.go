@@ -0,0 +1,319 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachedServesRepeatedReads(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/b.txt": []byte("hello")})
+	p := Cached(fake, DefaultCachePolicy)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		data, err := p.Read(ctx, "a/b.txt")
+		if err != nil || string(data) != "hello" {
+			t.Fatalf("Read = %q, %v", data, err)
+		}
+	}
+	if fake.calls[OpRead] != 1 {
+		t.Errorf("underlying Read called %d times, want 1", fake.calls[OpRead])
+	}
+}
+
+func TestCachedDoesNotCacheErrors(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{})
+	fake.fail = errors.New("throttled")
+	p := Cached(fake, DefaultCachePolicy)
+	ctx := context.Background()
+
+	p.ReadDir(ctx, "a")
+	fake.fail = nil
+	if _, err := p.ReadDir(ctx, "a"); err != nil {
+		t.Fatalf("ReadDir after recovery: %v", err)
+	}
+	if fake.calls[OpReadDir] != 2 {
+		t.Errorf("underlying ReadDir called %d times, want 2", fake.calls[OpReadDir])
+	}
+}
+
+func TestCachedWriteInvalidatesAncestors(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/b/c.txt": []byte("old")})
+	p := Cached(fake, DefaultCachePolicy)
+	ctx := context.Background()
+
+	p.ReadDir(ctx, "a/b")
+	p.ReadDir(ctx, "a")
+	p.Read(ctx, "a/b/c.txt")
+
+	if err := p.Write(ctx, "a/b/c.txt", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := p.Read(ctx, "a/b/c.txt")
+	if string(data) != "new" {
+		t.Errorf("Read after Write = %q, want %q", data, "new")
+	}
+	p.ReadDir(ctx, "a/b")
+	p.ReadDir(ctx, "a")
+	if fake.calls[OpReadDir] != 4 {
+		t.Errorf("underlying ReadDir called %d times, want 4", fake.calls[OpReadDir])
+	}
+}
+
+// storeProvider is a fakeProvider whose files read back as written
+type storeProvider struct {
+	*fakeProvider
+}
+
+func (p storeProvider) Written(path string, data []byte) ([]byte, bool) {
+	return data, true
+}
+
+func TestCachedWriteThrough(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/old.txt": []byte("old")})
+	p := Cached(Chain(storeProvider{fake}, Canonical("iam")), DefaultCachePolicy)
+	ctx := context.Background()
+
+	p.ReadDir(ctx, "a")
+	if err := p.Write(ctx, "a/new.json", []byte(`{"b":1,"a":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	// The service doesn't list or serve the file yet
+	delete(fake.files, "a/new.json")
+
+	entries, _ := p.ReadDir(ctx, "a")
+	if names := entryNames(entries); len(names) != 2 || names[1] != "new.json" {
+		t.Errorf("ReadDir after Write = %v, want old.txt and new.json", names)
+	}
+	data, err := p.Read(ctx, "a/new.json")
+	if want := "{\n  \"a\": 2,\n  \"b\": 1\n}\n"; err != nil || string(data) != want {
+		t.Errorf("Read after Write = %q, %v, want %q", data, err, want)
+	}
+	found, _ := p.StatBatch(ctx, []string{"a/new.json"})
+	if e := found["a/new.json"]; e == nil || e.Size != int64(len(data)) {
+		t.Errorf("StatBatch after Write = %+v, want size %d", e, len(data))
+	}
+	if fake.calls[OpReadDir] != 1 || fake.calls[OpRead] != 0 || fake.calls[OpStat] != 0 {
+		t.Errorf("write-through hit the provider: %v", fake.calls)
+	}
+}
+
+func TestCachedSkipsLargeReads(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"big": make([]byte, 64)})
+	p := Cached(fake, CachePolicy{Read: DefaultCachePolicy.Read, MaxReadSize: 32})
+	ctx := context.Background()
+
+	p.Read(ctx, "big")
+	p.Read(ctx, "big")
+	if fake.calls[OpRead] != 2 {
+		t.Errorf("underlying Read called %d times, want 2", fake.calls[OpRead])
+	}
+}
+
+func TestReadRangeFallsBackToRead(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/b.txt": []byte("hello world")})
+	p := Cached(Chain(fake, Logging()), DefaultCachePolicy)
+	ctx := context.Background()
+
+	data, err := ReadRange(ctx, p, "a/b.txt", 6, 100)
+	if err != nil || string(data) != "world" {
+		t.Fatalf("ReadRange = %q, %v", data, err)
+	}
+
+	// Once the full content is cached, ranges are sliced from it
+	p.Read(ctx, "a/b.txt")
+	calls := fake.calls[OpRead]
+	if data, _ := ReadRange(ctx, p, "a/b.txt", 0, 5); string(data) != "hello" {
+		t.Errorf("cached ReadRange = %q", data)
+	}
+	if fake.calls[OpRead] != calls {
+		t.Errorf("cached range hit the provider")
+	}
+}
+
+func TestCachedObservesChanges(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"a/kept.txt":    []byte("same"),
+		"a/changed.txt": []byte("old"),
+		"a/gone.txt":    []byte("bye"),
+	})
+	var changes []Change
+	p := Cached(fake, CachePolicy{}).Observe(func(c Change) { changes = append(changes, c) })
+	ctx := context.Background()
+
+	p.ReadDir(ctx, "a")
+	if len(changes) != 0 {
+		t.Fatalf("first listing reported %v", changes)
+	}
+
+	fake.files["a/changed.txt"] = []byte("newer")
+	fake.files["a/new.txt"] = []byte("hi")
+	delete(fake.files, "a/gone.txt")
+	p.Write(ctx, "a/mine.txt", []byte("written through sisu"))
+	p.ReadDir(ctx, "a")
+
+	got := make(map[string]ChangeKind)
+	for _, c := range changes {
+		got[c.Path] = c.Kind
+	}
+	want := map[string]ChangeKind{
+		"a/changed.txt": ChangeModified,
+		"a/new.txt":     ChangeAdded,
+		"a/gone.txt":    ChangeRemoved,
+	}
+	if len(got) != len(want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	for path, kind := range want {
+		if got[path] != kind {
+			t.Errorf("%s: %q, want %q", path, got[path], kind)
+		}
+	}
+}
+
+func TestListingsForgetLeastRecentlyListed(t *testing.T) {
+	defer func(n int) { maxListings = n }(maxListings)
+	maxListings = 2
+
+	l := newListings()
+	now := time.Now()
+	l.observe("a", []Entry{{Name: "x"}}, now)
+	l.observe("b", []Entry{{Name: "x"}}, now)
+	l.observe("a", []Entry{{Name: "x"}}, now) // a is now the most recent
+	l.observe("c", []Entry{{Name: "x"}}, now)
+	if len(l.entries) != 2 || l.entries["b"] != nil {
+		t.Errorf("remembered %v, want a and c", l.dirsUnder(""))
+	}
+	if changes := l.observe("a", []Entry{{Name: "y"}}, now); len(changes) != 2 {
+		t.Errorf("changes of a = %v, want x removed and y added", changes)
+	}
+	// b was forgotten, so its next listing is the first again
+	if changes := l.observe("b", []Entry{{Name: "y"}}, now); len(changes) != 0 {
+		t.Errorf("changes of b = %v, want none", changes)
+	}
+}
+
+func TestCachedCompressesLargeReads(t *testing.T) {
+	doc := []byte(strings.Repeat(`{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"},`, 100))
+	fake := newFakeProvider(map[string][]byte{"policy.json": doc, "small.txt": []byte("hi")})
+	p := Cached(fake, CachePolicy{Read: DefaultCachePolicy.Read, MaxReadSize: 1 << 20, CompressAbove: 1 << 10})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		data, err := p.Read(ctx, "policy.json")
+		if err != nil || !bytes.Equal(data, doc) {
+			t.Fatalf("Read = %d bytes, %v; want the document back", len(data), err)
+		}
+	}
+	if fake.calls[OpRead] != 1 {
+		t.Errorf("underlying Read called %d times, want 1", fake.calls[OpRead])
+	}
+	if data, err := p.ReadRange(ctx, "policy.json", 2, 6); err != nil || string(data) != "Effect" {
+		t.Errorf("ReadRange = %q, %v", data, err)
+	}
+	// The next range is sliced from the content decompressed for the last
+	first, _ := p.ReadRange(ctx, "policy.json", 0, 10)
+	next, _ := p.ReadRange(ctx, "policy.json", 10, 10)
+	if len(first) != 10 || len(next) != 10 || &first[:11][10] != &next[0] {
+		t.Error("ReadRange decompressed the content again for the next range")
+	}
+	p.Read(ctx, "small.txt")
+
+	stats := p.CacheStats()
+	if stats.Entries != 2 || stats.Compressed != 1 {
+		t.Errorf("stats = %+v, want 2 entries with 1 compressed", stats)
+	}
+	raw := int64(len("read:policy.json") + len(doc) + len("read:small.txt") + 2)
+	if stats.RawBytes != raw || stats.Bytes >= raw/5 {
+		t.Errorf("stats = %+v, want %d raw bytes compressed at least 5x", stats, raw)
+	}
+}
+
+func TestCachedRefreshRelistsSubtree(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"a/x.txt":   []byte("x"),
+		"a/b/y.txt": []byte("y"),
+		"c/z.txt":   []byte("z"),
+	})
+	p := Cached(fake, DefaultCachePolicy).Observe(func(Change) {})
+	ctx := context.Background()
+	for _, dir := range []string{"a", "a/b", "c"} {
+		p.ReadDir(ctx, dir)
+	}
+
+	fake.files["a/b/new.txt"] = []byte("new")
+	fake.files["c/new.txt"] = []byte("new")
+	if entries, _ := p.ReadDir(ctx, "a/b"); len(entries) != 1 {
+		t.Fatalf("cached listing = %v, want the old one", entries)
+	}
+
+	changes, err := p.Refresh(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "a/b/new.txt" || changes[0].Kind != ChangeAdded {
+		t.Errorf("Refresh = %+v, want a/b/new.txt added", changes)
+	}
+	if entries, _ := p.ReadDir(ctx, "a/b"); len(entries) != 2 {
+		t.Errorf("listing after refresh = %v", entries)
+	}
+	if entries, _ := p.ReadDir(ctx, "c"); len(entries) != 1 {
+		t.Errorf("listing outside the subtree = %v, want it still cached", entries)
+	}
+}
+
+func TestCachedRefreshCapsDirs(t *testing.T) {
+	defer func(n int) { refreshMaxDirs = n }(refreshMaxDirs)
+	refreshMaxDirs = 2
+	fake := newFakeProvider(map[string][]byte{
+		"a/b/c/x.txt": []byte("x"),
+		"a/d/y.txt":   []byte("y"),
+	})
+	var observed []Change
+	p := Cached(fake, DefaultCachePolicy).Observe(func(c Change) { observed = append(observed, c) })
+	ctx := context.Background()
+	for _, dir := range []string{"a", "a/b", "a/b/c", "a/d"} {
+		p.ReadDir(ctx, dir)
+	}
+
+	fake.files["a/b/c/new.txt"] = []byte("new")
+	fake.files["a/b/new.txt"] = []byte("new")
+	changes, err := p.Refresh(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a and the shallowest directory below it, a/b, are listed again
+	if len(changes) != 1 || changes[0].Path != "a/b/new.txt" {
+		t.Errorf("Refresh = %+v, want a/b/new.txt added", changes)
+	}
+	// a/b/c is listed again when next read, still reporting its change
+	observed = nil
+	if entries, _ := p.ReadDir(ctx, "a/b/c"); len(entries) != 2 {
+		t.Errorf("a/b/c = %v, want it listed again", entries)
+	}
+	if len(observed) != 1 || observed[0].Path != "a/b/c/new.txt" {
+		t.Errorf("observed = %+v, want a/b/c/new.txt added", observed)
+	}
+}
+
+// closingProvider records being closed
+type closingProvider struct {
+	*fakeProvider
+	closed bool
+}
+
+func (p *closingProvider) Close() { p.closed = true }
+
+func TestCachedCloseReachesProvider(t *testing.T) {
+	inner := &closingProvider{fakeProvider: newFakeProvider(nil)}
+	p := Cached(Chain(inner, Recover(), Logging(), Paged(nil)), DefaultCachePolicy)
+	p.Close()
+	if !inner.closed {
+		t.Error("the provider beneath the cache and middleware wasn't closed")
+	}
+}
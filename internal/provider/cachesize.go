@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// compressed is file content kept deflated in the result cache. JSON
+// documents like policies and configs shrink 5-10x, which adds up over a
+// long session of browsing them.
+type compressed struct {
+	data []byte
+	size int // length of the content uncompressed
+}
+
+// compress returns data deflated, or false if that doesn't save at least
+// an eighth of it
+func compress(data []byte) (*compressed, bool) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() > len(data)-len(data)/8 {
+		return nil, false
+	}
+	return &compressed{data: bytes.Clone(buf.Bytes()), size: len(data)}, true
+}
+
+func (c *compressed) expand() ([]byte, error) {
+	data := make([]byte, 0, c.size)
+	buf := bytes.NewBuffer(data)
+	if _, err := io.Copy(buf, flate.NewReader(bytes.NewReader(c.data))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lastExpanded keeps the content of the compressed entry decompressed
+// last, so a file read in ranges, e.g. 128 KB at a time by the kernel,
+// is decompressed once rather than for every range. Only one is kept, to
+// bound the memory it holds.
+type lastExpanded struct {
+	mu   sync.Mutex
+	from *compressed
+	data []byte
+}
+
+// get returns c decompressed, reusing the last content if it came from c
+func (e *lastExpanded) get(c *compressed) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.from == c {
+		return e.data, nil
+	}
+	data, err := c.expand()
+	if err != nil {
+		return nil, err
+	}
+	e.from, e.data = c, data
+	return data, nil
+}
+
+// CacheStats is the memory held by a provider's result cache, as shown in
+// stats.json. Sizes count file contents and entry names, not Go overhead.
+type CacheStats struct {
+	Entries int `json:"entries"`
+	// Bytes is what the cached results take, with large contents compressed
+	Bytes int64 `json:"bytes"`
+	// RawBytes is what they would take uncompressed
+	RawBytes int64 `json:"raw_bytes"`
+	// Compressed counts the file contents kept compressed
+	Compressed int `json:"compressed"`
+}
+
+// Add sums two providers' stats, e.g. for a service across profiles
+func (s CacheStats) Add(o CacheStats) CacheStats {
+	return CacheStats{
+		Entries:    s.Entries + o.Entries,
+		Bytes:      s.Bytes + o.Bytes,
+		RawBytes:   s.RawBytes + o.RawBytes,
+		Compressed: s.Compressed + o.Compressed,
+	}
+}
+
+// entrySize estimates the memory of a cached Entry
+func entrySize(e *Entry) int64 {
+	if e == nil {
+		return 0
+	}
+	size := int64(len(e.Name))
+	for k, v := range e.Checksums {
+		size += int64(len(k) + len(v))
+	}
+	return size
+}
+
+// CacheStats returns the memory held by the cache now
+func (p *CachedProvider) CacheStats() CacheStats {
+	var s CacheStats
+	p.cache.Range(func(key string, value interface{}) {
+		s.Entries++
+		size, raw := int64(len(key)), int64(len(key))
+		switch v := value.(type) {
+		case []byte:
+			size += int64(len(v))
+			raw += int64(len(v))
+		case *compressed:
+			s.Compressed++
+			size += int64(len(v.data))
+			raw += int64(v.size)
+		case *Entry:
+			size += entrySize(v)
+			raw += entrySize(v)
+		case []Entry:
+			for i := range v {
+				size += entrySize(&v[i])
+				raw += entrySize(&v[i])
+			}
+		}
+		s.Bytes += size
+		s.RawBytes += raw
+	})
+	return s
+}
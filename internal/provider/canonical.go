@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// ObjectStores are services whose files are stored as is rather than
+// generated, so are never rendered or canonicalized
+var ObjectStores = map[string]bool{"s3": true, "gcs": true, "blob": true}
+
+// Canonical returns a middleware that reads and writes .json documents in
+// one canonical form: keys sorted, two-space indent and a final newline.
+// Writing back what was read then leaves the resource unchanged, and
+// reading back what was written returns it byte for byte, so copies and
+// diffs between the mount and a git checkout show no spurious changes.
+// Content that isn't valid JSON passes through as is, and so do the
+// stored values of service, such as SSM parameters named *.json.
+func Canonical(service string) Middleware {
+	return func(p Provider) Provider {
+		return &canonicalProvider{Provider: p, stored: storedValues[service]}
+	}
+}
+
+// storedValues recognize, by service, the files holding values exactly as
+// they were written rather than documents sisu generates. A parameter or
+// secret that happens to be JSON keeps its bytes; only its generated
+// @meta.json sidecar is canonical.
+var storedValues = map[string]func(path string) bool{
+	"ssm":      func(path string) bool { return !isSSMMeta(path) },
+	"secrets":  func(string) bool { return true },
+	"keyvault": func(string) bool { return true },
+}
+
+type canonicalProvider struct {
+	Provider
+	stored func(path string) bool
+}
+
+// generated reports whether path is a .json document sisu generates,
+// the only files rewritten in canonical form
+func (p *canonicalProvider) generated(path string) bool {
+	return strings.HasSuffix(path, ".json") && (p.stored == nil || !p.stored(path))
+}
+
+func (p *canonicalProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := p.Provider.Read(ctx, path)
+	if err != nil || !p.generated(path) {
+		return data, err
+	}
+	return canonicalJSON(data), nil
+}
+
+// ReadRange slices the canonical document, whose offsets differ from the
+// provider's own
+func (p *canonicalProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	if !p.generated(path) {
+		return ReadRange(ctx, p.Provider, path, off, length)
+	}
+	data, err := p.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, off, length), nil
+}
+
+func (p *canonicalProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	files, err := Prefetch(ctx, p.Provider, path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(files))
+	for name, data := range files {
+		if p.generated(name) {
+			data = canonicalJSON(data)
+		}
+		out[name] = data
+	}
+	return out, nil
+}
+
+func (p *canonicalProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	return StatBatch(ctx, p.Provider, paths)
+}
+
+func (p *canonicalProvider) Write(ctx context.Context, path string, data []byte) error {
+	if p.generated(path) {
+		data = canonicalJSON(data)
+	}
+	return p.Provider.Write(ctx, path, data)
+}
+
+func (p *canonicalProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *canonicalProvider) Close() {
+	Close(p.Provider)
+}
+
+// Written reads back generated .json documents in canonical form, as
+// Read does
+func (p *canonicalProvider) Written(path string, data []byte) ([]byte, bool) {
+	if p.generated(path) {
+		data = canonicalJSON(data)
+	}
+	return Written(p.Provider, path, data)
+}
+
+// canonicalJSON re-encodes a JSON document in canonical form, keeping
+// numbers as written
+func canonicalJSON(data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return data
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
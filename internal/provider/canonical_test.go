@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCanonicalRoundTrip(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"role/policy.json": []byte(`{"Version":"2012-10-17","Statement":[{"Resource":"arn:aws:s3:::b/<key>","Effect":"Allow"}],"Limit":12345678901234567890}`),
+		"role/notes.txt":   []byte("plain"),
+	})
+	p := Canonical("iam")(writableFake{fake})
+	ctx := context.Background()
+
+	want := `{
+  "Limit": 12345678901234567890,
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Resource": "arn:aws:s3:::b/<key>"
+    }
+  ],
+  "Version": "2012-10-17"
+}
+`
+	data, err := p.Read(ctx, "role/policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("Read = %s, want %s", data, want)
+	}
+
+	// Writing what was read stores it byte for byte
+	if err := p.Write(ctx, "role/policy.json", data); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.files["role/policy.json"]) != want {
+		t.Errorf("stored = %s", fake.files["role/policy.json"])
+	}
+
+	// Other formatting of the same document is stored in canonical form too
+	if err := p.Write(ctx, "role/policy.json", []byte(`{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Resource": "arn:aws:s3:::b/<key>"}], "Limit": 12345678901234567890}`)); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.files["role/policy.json"]) != want {
+		t.Errorf("stored = %s", fake.files["role/policy.json"])
+	}
+
+	if data, _ := p.Read(ctx, "role/notes.txt"); string(data) != "plain" {
+		t.Errorf("Read notes = %q", data)
+	}
+	if err := p.Write(ctx, "role/broken.json", []byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.files["role/broken.json"]) != "{not json" {
+		t.Errorf("invalid JSON was changed: %q", fake.files["role/broken.json"])
+	}
+}
+
+func TestCanonicalKeepsStoredValues(t *testing.T) {
+	value := `{"b": 1, "a": 2}`
+	fake := newFakeProvider(map[string][]byte{
+		"app/config.json":           []byte(value),
+		"app/config.json@meta.json": []byte(`{"Description":"d"}`),
+	})
+	p := Canonical("ssm")(writableFake{fake})
+	ctx := context.Background()
+
+	if data, _ := p.Read(ctx, "app/config.json"); string(data) != value {
+		t.Errorf("Read value = %q, want it as stored", data)
+	}
+	if err := p.Write(ctx, "app/config.json", []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.files["app/config.json"]) != value {
+		t.Errorf("stored = %q, want it as written", fake.files["app/config.json"])
+	}
+	if data, _ := p.Read(ctx, "app/config.json@meta.json"); string(data) != "{\n  \"Description\": \"d\"\n}\n" {
+		t.Errorf("Read sidecar = %q, want canonical JSON", data)
+	}
+}
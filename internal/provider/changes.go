@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// ChangeKind says how an entry differs from the previous listing
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a difference between two listings of a directory, observed
+// when a cached listing is fetched again
+type Change struct {
+	Time time.Time
+	Kind ChangeKind
+	Path string
+}
+
+// maxListings caps the directories whose last listing is remembered; the
+// one listed longest ago is forgotten past it, and its next listing is
+// compared with nothing
+var maxListings = 1000
+
+// listings remembers the last listing of each directory, to compare the
+// next one against
+type listings struct {
+	mu      sync.Mutex
+	entries map[string]map[string]Entry // by directory, then name
+	order   []string                    // directories by when last listed, oldest first
+	written map[string]bool             // paths changed through sisu since their directory was last listed
+}
+
+func newListings() *listings {
+	return &listings{
+		entries: make(map[string]map[string]Entry),
+		written: make(map[string]bool),
+	}
+}
+
+// observe records a fresh listing of dir and returns how it differs from
+// the previous one. Truncated listings and page directories are compared
+// by neither side, since entries past the cap come and go; nor are paths
+// sisu itself changed.
+func (l *listings) observe(dir string, entries []Entry, now time.Time) []Change {
+	if _, n := paging.Split(dir); n > 1 {
+		return nil // later pages shift as entries come and go before them
+	}
+	current := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		current[e.Name] = e
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous, seen := l.entries[dir]
+	l.entries[dir] = current
+	if seen {
+		l.order = slices.DeleteFunc(l.order, func(d string) bool { return d == dir })
+	}
+	l.order = append(l.order, dir)
+	for len(l.order) > maxListings {
+		delete(l.entries, l.order[0])
+		l.order = l.order[1:]
+	}
+	written := l.written
+	l.written = make(map[string]bool)
+	for path := range written {
+		if parent, _ := splitParent(path); parent != dir {
+			l.written[path] = true
+		}
+	}
+	truncated := false
+	for _, marker := range []string{MoreResultsFile, WarningFile, paging.Name(2)} {
+		_, listed := current[marker]
+		_, listedBefore := previous[marker]
+		truncated = truncated || listed || listedBefore
+	}
+	if !seen || truncated {
+		return nil
+	}
+
+	var changes []Change
+	add := func(kind ChangeKind, name string) {
+		if path := joinPath(dir, name); !written[path] {
+			changes = append(changes, Change{Time: now, Kind: kind, Path: path})
+		}
+	}
+	for _, e := range entries {
+		old, ok := previous[e.Name]
+		switch {
+		case !ok:
+			add(ChangeAdded, e.Name)
+		case modified(old, e):
+			add(ChangeModified, e.Name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			add(ChangeRemoved, name)
+		}
+	}
+	return changes
+}
+
+// dirsUnder returns dir and the directories below it that were listed,
+// parents first
+func (l *listings) dirsUnder(dir string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dirs := []string{dir}
+	for d := range l.entries {
+		if d != dir && (dir == "" || strings.HasPrefix(d, dir+"/")) {
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs[1:])
+	return dirs
+}
+
+// wrote notes that sisu changed path, so the next listing doesn't report it
+func (l *listings) wrote(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.written[path] = true
+}
+
+// modified reports whether a file's size or modification time changed.
+// Directories are only ever added or removed.
+func modified(old, e Entry) bool {
+	if old.IsDir || e.IsDir {
+		return old.IsDir != e.IsDir
+	}
+	return old.Size != e.Size || !old.ModTime.Equal(e.ModTime)
+}
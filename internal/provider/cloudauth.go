@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies OAuth bearer tokens for GCP and Azure APIs
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// staticToken is a token supplied up front, e.g. in tests or from the environment
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// commandToken obtains tokens from a cloud CLI and reuses each one for ttl,
+// so only the first request after expiry pays for spawning the CLI
+type commandToken struct {
+	name string
+	args []string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (t *commandToken) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	out, err := exec.CommandContext(ctx, t.name, t.args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed to provide an access token (are you logged in?): %w", t.name, err)
+	}
+	t.token = strings.TrimSpace(string(out))
+	t.expires = time.Now().Add(t.ttl)
+	return t.token, nil
+}
+
+// GCloudToken returns tokens from $GOOGLE_OAUTH_ACCESS_TOKEN if set, and
+// from `gcloud auth print-access-token` otherwise
+func GCloudToken() TokenSource {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return staticToken(token)
+	}
+	return &commandToken{
+		name: "gcloud",
+		args: []string{"auth", "print-access-token"},
+		ttl:  45 * time.Minute,
+	}
+}
+
+// AzureToken returns tokens for an Azure resource (e.g. https://storage.azure.com/)
+// from `az account get-access-token`
+func AzureToken(resource string) TokenSource {
+	return &commandToken{
+		name: "az",
+		args: []string{"account", "get-access-token", "--resource", resource, "--query", "accessToken", "--output", "tsv"},
+		ttl:  45 * time.Minute,
+	}
+}
+
+// restClient sends authenticated requests to a cloud REST API
+type restClient struct {
+	client *http.Client
+	token  TokenSource
+	header http.Header // sent with every request, e.g. x-ms-version
+}
+
+func newRESTClient(client *http.Client, token TokenSource) *restClient {
+	return &restClient{client: client, token: token, header: http.Header{}}
+}
+
+// do sends a request and returns the response for any 2xx status. Other
+// statuses are turned into errors, with 404 and 401/403 wrapping
+// fs.ErrNotExist and fs.ErrPermission.
+func (c *restClient) do(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.token.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	err = fmt.Errorf("%s %s: %s", method, req.URL.Path, resp.Status)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return resp, fmt.Errorf("%w: %w", err, fs.ErrNotExist)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return resp, fmt.Errorf("%w: %w", err, fs.ErrPermission)
+	}
+	return resp, err
+}
+
+// get returns the body of a GET request
+func (c *restClient) get(ctx context.Context, url string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// getJSON decodes the body of a GET request into v
+func (c *restClient) getJSON(ctx context.Context, url string, v any) error {
+	body, err := c.get(ctx, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// getRange fetches bytes [off, off+length) of url with a Range request. A
+// range starting past the end yields no data.
+func (c *restClient) getRange(ctx context.Context, url string, off, length int64) ([]byte, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+length-1)}}
+	resp, err := c.do(ctx, http.MethodGet, url, header)
+	if resp != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
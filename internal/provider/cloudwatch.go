@@ -0,0 +1,708 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// CloudWatchProvider provides CloudWatch alarms with their current state,
+// the region's metrics, with namespaces like AWS/EC2 as directories,
+// dashboards and Synthetics canaries:
+//
+//	alarms/<alarm>.json
+//	metrics/<namespace>/<metric>/dimensions.json
+//	metrics/<namespace>/<metric>/recent-datapoints.json
+//	dashboards/<dashboard>.json        the dashboard's body: its widgets
+//	canaries/<canary>/config.json      the canary: schedule, runtime, code location, status
+//	canaries/<canary>/last-run.json    its most recent run: state, reason, timeline, artifacts
+type CloudWatchProvider struct {
+	ReadOnlyProvider
+	client     *restJSONClient
+	synthetics *restJSONClient
+	alarms     *documents[map[string]map[string]any]             // all alarms by name, under ""
+	namespaces *documents[cloudWatchNamespaces]                  // all namespaces, under ""
+	metrics    *documents[[]cloudWatchMetric]                    // by namespace
+	dashboards *documents[cappedList[[]string]]                  // dashboard names, under ""
+	canaries   *documents[cappedList[map[string]map[string]any]] // canaries by name, under ""
+	now        func() time.Time
+}
+
+// CloudWatchNamespacePages caps the ListMetrics pages read to find the
+// region's namespaces, which has to go through every metric
+const CloudWatchNamespacePages = 20
+
+// cloudWatchNamespaces are the sorted namespaces found in the first
+// CloudWatchNamespacePages pages of metrics
+type cloudWatchNamespaces struct {
+	names []string
+	more  bool // pages were left unread, so namespaces may be missing
+}
+
+// cloudWatchNamespacesHint lists the region's namespaces in full
+const cloudWatchNamespacesHint = "aws cloudwatch list-metrics --query 'Metrics[].Namespace'"
+
+// CloudWatchSeries caps the dimension sets of a metric whose datapoints
+// recent-datapoints.json fetches
+const CloudWatchSeries = 10
+
+// cloudWatchWindow and cloudWatchPeriod are the span and resolution of
+// recent-datapoints.json
+const (
+	cloudWatchWindow = time.Hour
+	cloudWatchPeriod = 5 * time.Minute
+)
+
+// NewCloudWatchProvider creates a new CloudWatch provider
+func NewCloudWatchProvider(profile, region string) (*CloudWatchProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newCloudWatchProvider(cfg), nil
+}
+
+func newCloudWatchProvider(cfg aws.Config) *CloudWatchProvider {
+	return &CloudWatchProvider{
+		client:     newJSONRPCClient(cfg, "CloudWatch", "monitoring", "GraniteServiceVersion20100801"),
+		synthetics: newRESTJSONClient(cfg, "Synthetics", "synthetics"),
+		alarms:     newDocuments[map[string]map[string]any](),
+		namespaces: newDocuments[cloudWatchNamespaces](),
+		metrics:    newDocuments[[]cloudWatchMetric](),
+		dashboards: newDocuments[cappedList[[]string]](),
+		canaries:   newDocuments[cappedList[map[string]map[string]any]](),
+		now:        time.Now,
+	}
+}
+
+func (p *CloudWatchProvider) Name() string {
+	return "cloudwatch"
+}
+
+// Invalidate forgets the memoized alarms, metrics, dashboards and canaries, so a refresh describes them anew
+func (p *CloudWatchProvider) Invalidate(string) {
+	forgetDocuments(p.alarms, p.namespaces, p.metrics, p.dashboards, p.canaries)
+}
+
+func (p *CloudWatchProvider) Close() {
+	closeDocuments(p.alarms, p.namespaces, p.metrics, p.dashboards, p.canaries)
+}
+
+// cloudWatchDimension is a dimension of a metric, e.g. InstanceId=i-0abc
+type cloudWatchDimension struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// cloudWatchMetric is a metric with one set of dimensions
+type cloudWatchMetric struct {
+	Namespace  string                `json:"Namespace"`
+	MetricName string                `json:"MetricName"`
+	Dimensions []cloudWatchDimension `json:"Dimensions"`
+}
+
+// cloudWatchPath is a path below metrics/, resolved against the namespaces
+type cloudWatchPath struct {
+	namespace string   // "" above the namespaces, e.g. in metrics/AWS
+	prefix    string   // the namespace prefix listed when namespace is ""
+	rest      []string // the metric and file below the namespace
+}
+
+// resolveMetricsPath splits the segments below metrics/ into the longest
+// namespace they start with and what follows, e.g. [AWS EC2 CPUUtilization]
+// is the metric CPUUtilization of AWS/EC2
+func (p *CloudWatchProvider) resolveMetricsPath(ctx context.Context, segments []string) (cloudWatchPath, error) {
+	list, err := p.listNamespaces(ctx)
+	if err != nil {
+		return cloudWatchPath{}, err
+	}
+	namespaces := list.names
+	for k := len(segments); k > 0; k-- {
+		ns := strings.Join(segments[:k], "/")
+		if i := sort.SearchStrings(namespaces, ns); i < len(namespaces) && namespaces[i] == ns {
+			return cloudWatchPath{namespace: ns, rest: segments[k:]}, nil
+		}
+	}
+	prefix := strings.Join(segments, "/")
+	for _, ns := range namespaces {
+		if prefix == "" || strings.HasPrefix(ns, prefix+"/") {
+			return cloudWatchPath{prefix: prefix}, nil
+		}
+	}
+	return cloudWatchPath{}, fmt.Errorf("namespace not found: %s: %w", prefix, os.ErrNotExist)
+}
+
+func (p *CloudWatchProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		return []Entry{
+			{Name: "alarms", IsDir: true},
+			{Name: "canaries", IsDir: true},
+			{Name: "dashboards", IsDir: true},
+			{Name: "metrics", IsDir: true},
+		}, nil
+	case path == "dashboards":
+		names, err := p.listDashboards(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws cloudwatch list-dashboards", err)
+		}
+		entries := make([]Entry, 0, len(names.items))
+		for _, name := range names.items {
+			entries = append(entries, Entry{Name: name + ".json", IsDir: false, Size: 4096})
+		}
+		return capEntries(entries, names.more, "aws cloudwatch list-dashboards"), nil
+	case path == "canaries":
+		canaries, err := p.listCanaries(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws synthetics describe-canaries", err)
+		}
+		entries := make([]Entry, 0, len(canaries.items))
+		for name := range canaries.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, canaries.more, "aws synthetics describe-canaries"), nil
+	case len(parts) == 2 && parts[0] == "canaries":
+		if _, err := p.canary(ctx, parts[1]); err != nil {
+			return nil, err
+		}
+		return []Entry{
+			{Name: "config.json", IsDir: false, Size: 4096},
+			{Name: "last-run.json", IsDir: false, Size: 4096},
+		}, nil
+	case path == "alarms":
+		alarms, err := p.listAlarms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]Entry, 0, len(alarms))
+		for name := range alarms {
+			entries = append(entries, Entry{Name: escapeSlash(name) + ".json", IsDir: false, Size: 4096})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, false, "aws cloudwatch describe-alarms"), nil
+	case parts[0] == "metrics":
+		mp, err := p.resolveMetricsPath(ctx, parts[1:])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case mp.namespace == "":
+			return p.listNamespacePrefix(ctx, mp.prefix)
+		case len(mp.rest) == 0:
+			return p.listNamespace(ctx, mp.namespace)
+		case len(mp.rest) == 1:
+			return []Entry{
+				{Name: "dimensions.json", IsDir: false},
+				{Name: "recent-datapoints.json", IsDir: false},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown path: %s", path)
+}
+
+// listAlarms returns every metric and composite alarm, keyed by name
+func (p *CloudWatchProvider) listAlarms(ctx context.Context) (map[string]map[string]any, error) {
+	return p.alarms.get("", func() (map[string]map[string]any, error) {
+		alarms := make(map[string]map[string]any)
+		var token string
+		for {
+			in := map[string]any{"AlarmTypes": []string{"MetricAlarm", "CompositeAlarm"}}
+			if token != "" {
+				in["NextToken"] = token
+			}
+			var resp struct {
+				MetricAlarms    []map[string]any
+				CompositeAlarms []map[string]any
+				NextToken       string
+			}
+			if err := p.client.call(ctx, "DescribeAlarms", in, &resp); err != nil {
+				return nil, err
+			}
+			for _, a := range append(resp.MetricAlarms, resp.CompositeAlarms...) {
+				if name, ok := a["AlarmName"].(string); ok {
+					alarms[name] = jsonTimes(a)
+				}
+			}
+			if resp.NextToken == "" {
+				return alarms, nil
+			}
+			token = resp.NextToken
+		}
+	})
+}
+
+// listNamespaces returns the namespaces of the region's metrics
+func (p *CloudWatchProvider) listNamespaces(ctx context.Context) (cloudWatchNamespaces, error) {
+	return p.namespaces.get("", func() (cloudWatchNamespaces, error) {
+		seen := make(map[string]bool)
+		var token string
+		for page := 0; page < CloudWatchNamespacePages; page++ {
+			in := map[string]any{}
+			if token != "" {
+				in["NextToken"] = token
+			}
+			var resp struct {
+				Metrics   []cloudWatchMetric
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListMetrics", in, &resp); err != nil {
+				return cloudWatchNamespaces{}, err
+			}
+			for _, m := range resp.Metrics {
+				seen[m.Namespace] = true
+			}
+			token = resp.NextToken
+			if token == "" {
+				break
+			}
+		}
+		namespaces := make([]string, 0, len(seen))
+		for ns := range seen {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		return cloudWatchNamespaces{names: namespaces, more: token != ""}, nil
+	})
+}
+
+// listNamespacePrefix lists the next segment of the namespaces under
+// prefix, e.g. EC2 and Lambda for AWS
+func (p *CloudWatchProvider) listNamespacePrefix(ctx context.Context, prefix string) ([]Entry, error) {
+	namespaces, err := p.listNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return capEntries(namespaceChildren(namespaces.names, prefix, nil), namespaces.more, cloudWatchNamespacesHint), nil
+}
+
+// namespaceChildren returns the directories for the next segment of the
+// namespaces below prefix, skipping names already in seen
+func namespaceChildren(namespaces []string, prefix string, seen map[string]bool) []Entry {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	var entries []Entry
+	for _, ns := range namespaces {
+		rest := ns
+		if prefix != "" {
+			var ok bool
+			if rest, ok = strings.CutPrefix(ns, prefix+"/"); !ok {
+				continue
+			}
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if !seen[child] {
+			seen[child] = true
+			entries = append(entries, Entry{Name: child, IsDir: true})
+		}
+	}
+	return entries
+}
+
+// namespaceMetrics returns every metric of a namespace with its dimension
+// sets, shared by the namespace listing and the files of its metrics
+func (p *CloudWatchProvider) namespaceMetrics(ctx context.Context, namespace string) ([]cloudWatchMetric, error) {
+	return p.metrics.get(namespace, func() ([]cloudWatchMetric, error) {
+		metrics, _, err := paging.Collect(ctx, 0, func(ctx context.Context, token string) ([]cloudWatchMetric, string, error) {
+			in := map[string]any{"Namespace": namespace}
+			if token != "" {
+				in["NextToken"] = token
+			}
+			var resp struct {
+				Metrics   []cloudWatchMetric
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListMetrics", in, &resp); err != nil {
+				return nil, "", err
+			}
+			return resp.Metrics, resp.NextToken, nil
+		})
+		return metrics, err
+	})
+}
+
+// listNamespace lists a namespace's metrics, and the next segment of
+// namespaces nested below it, e.g. AWS/ApplicationELB/...
+func (p *CloudWatchProvider) listNamespace(ctx context.Context, namespace string) ([]Entry, error) {
+	metrics, err := p.namespaceMetrics(ctx, namespace)
+	if err != nil {
+		return nil, partialListing(nil, cloudWatchListHint(namespace), err)
+	}
+	seen := make(map[string]bool)
+	var entries []Entry
+	for _, m := range metrics {
+		if !seen[m.MetricName] {
+			seen[m.MetricName] = true
+			entries = append(entries, Entry{Name: m.MetricName, IsDir: true})
+		}
+	}
+	namespaces, err := p.listNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, namespaceChildren(namespaces.names, namespace, seen)...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return capEntries(entries, namespaces.more, cloudWatchListHint(namespace)), nil
+}
+
+func cloudWatchListHint(namespace string) string {
+	return fmt.Sprintf("aws cloudwatch list-metrics --namespace %s", namespace)
+}
+
+// metricDimensions returns the dimension sets a metric is published with
+func (p *CloudWatchProvider) metricDimensions(ctx context.Context, namespace, metric string) ([][]cloudWatchDimension, error) {
+	metrics, err := p.namespaceMetrics(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var sets [][]cloudWatchDimension
+	for _, m := range metrics {
+		if m.MetricName == metric {
+			dims := m.Dimensions
+			if dims == nil {
+				dims = []cloudWatchDimension{}
+			}
+			sets = append(sets, dims)
+		}
+	}
+	if sets == nil {
+		return nil, fmt.Errorf("metric not found: %s/%s: %w", namespace, metric, os.ErrNotExist)
+	}
+	return sets, nil
+}
+
+// cloudWatchSeries is one dimension set's datapoints in recent-datapoints.json
+type cloudWatchSeries struct {
+	Dimensions []cloudWatchDimension `json:"Dimensions"`
+	Statistic  string                `json:"Statistic"`
+	Datapoints []cloudWatchDatapoint `json:"Datapoints"`
+}
+
+type cloudWatchDatapoint struct {
+	Timestamp time.Time `json:"Timestamp"`
+	Value     float64   `json:"Value"`
+}
+
+// recentDatapoints fetches the last hour of a metric's average in
+// 5-minute periods, for its first CloudWatchSeries dimension sets, with a
+// single GetMetricData call
+func (p *CloudWatchProvider) recentDatapoints(ctx context.Context, namespace, metric string) ([]cloudWatchSeries, error) {
+	sets, err := p.metricDimensions(ctx, namespace, metric)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) > CloudWatchSeries {
+		sets = sets[:CloudWatchSeries]
+	}
+
+	queries := make([]map[string]any, len(sets))
+	for i, dims := range sets {
+		queries[i] = map[string]any{
+			"Id": fmt.Sprintf("m%d", i),
+			"MetricStat": map[string]any{
+				"Metric": cloudWatchMetric{Namespace: namespace, MetricName: metric, Dimensions: dims},
+				"Period": int(cloudWatchPeriod.Seconds()),
+				"Stat":   "Average",
+			},
+		}
+	}
+	end := p.now().Truncate(cloudWatchPeriod)
+	in := map[string]any{
+		"MetricDataQueries": queries,
+		"StartTime":         end.Add(-cloudWatchWindow).Unix(),
+		"EndTime":           end.Unix(),
+		"ScanBy":            "TimestampAscending",
+	}
+
+	series := make([]cloudWatchSeries, len(sets))
+	for i, dims := range sets {
+		series[i] = cloudWatchSeries{Dimensions: dims, Statistic: "Average", Datapoints: []cloudWatchDatapoint{}}
+	}
+	for {
+		var resp struct {
+			MetricDataResults []struct {
+				Id         string
+				Timestamps []float64
+				Values     []float64
+			}
+			NextToken string
+		}
+		if err := p.client.call(ctx, "GetMetricData", in, &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.MetricDataResults {
+			var i int
+			if _, err := fmt.Sscanf(r.Id, "m%d", &i); err != nil || i >= len(series) {
+				continue
+			}
+			for j, ts := range r.Timestamps {
+				if j < len(r.Values) {
+					series[i].Datapoints = append(series[i].Datapoints, cloudWatchDatapoint{Timestamp: epochTime(ts), Value: r.Values[j]})
+				}
+			}
+		}
+		if resp.NextToken == "" {
+			return series, nil
+		}
+		in["NextToken"] = resp.NextToken
+	}
+}
+
+// listDashboards returns the sorted names of the region's dashboards, up
+// to MaxEntries of them
+func (p *CloudWatchProvider) listDashboards(ctx context.Context) (cappedList[[]string], error) {
+	return p.dashboards.get("", func() (cappedList[[]string], error) {
+		var names []string
+		in := map[string]any{}
+		for {
+			var resp struct {
+				DashboardEntries []struct {
+					DashboardName string
+				}
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListDashboards", in, &resp); err != nil {
+				return cappedList[[]string]{}, err
+			}
+			for _, d := range resp.DashboardEntries {
+				names = append(names, d.DashboardName)
+			}
+			if resp.NextToken == "" || len(names) >= MaxEntries {
+				sort.Strings(names)
+				return cappedList[[]string]{items: names, more: resp.NextToken != ""}, nil
+			}
+			in = map[string]any{"NextToken": resp.NextToken}
+		}
+	})
+}
+
+// dashboard returns a dashboard's body, the JSON document of its widgets
+func (p *CloudWatchProvider) dashboard(ctx context.Context, name string) ([]byte, error) {
+	var resp struct {
+		DashboardBody string
+	}
+	if err := p.client.call(ctx, "GetDashboard", map[string]any{"DashboardName": name}, &resp); err != nil {
+		if isAPIError(err, "ResourceNotFound") || isAPIError(err, "ResourceNotFoundException") {
+			return nil, fmt.Errorf("dashboard not found: %s: %w", name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	var body any
+	if err := json.Unmarshal([]byte(resp.DashboardBody), &body); err != nil {
+		return nil, fmt.Errorf("dashboard %s: %w", name, err)
+	}
+	return json.MarshalIndent(body, "", "  ")
+}
+
+// listCanaries returns the region's Synthetics canaries by name, up to
+// MaxEntries of them
+func (p *CloudWatchProvider) listCanaries(ctx context.Context) (cappedList[map[string]map[string]any], error) {
+	return p.canaries.get("", func() (cappedList[map[string]map[string]any], error) {
+		canaries := make(map[string]map[string]any)
+		in := map[string]any{"MaxResults": 20}
+		for {
+			var resp struct {
+				Canaries  []map[string]any
+				NextToken string
+			}
+			if err := p.synthetics.do(ctx, "DescribeCanaries", "POST", "/canaries", nil, in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			for _, c := range resp.Canaries {
+				if name, ok := c["Name"].(string); ok {
+					canaries[name] = syntheticsTimes(c)
+				}
+			}
+			if resp.NextToken == "" || len(canaries) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: canaries, more: resp.NextToken != ""}, nil
+			}
+			in = map[string]any{"MaxResults": 20, "NextToken": resp.NextToken}
+		}
+	})
+}
+
+// canary returns the named canary, described on its own if the listing
+// was cut off before it, or an error wrapping os.ErrNotExist
+func (p *CloudWatchProvider) canary(ctx context.Context, name string) (map[string]any, error) {
+	canaries, err := p.listCanaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := canaries.items[name]; ok {
+		return c, nil
+	}
+	if canaries.more {
+		var resp struct {
+			Canaries []map[string]any
+		}
+		in := map[string]any{"Names": []string{name}}
+		if err := p.synthetics.do(ctx, "DescribeCanaries", "POST", "/canaries", nil, in, &resp); err != nil {
+			return nil, err
+		}
+		for _, c := range resp.Canaries {
+			if c["Name"] == name {
+				return syntheticsTimes(c), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("canary not found: %s: %w", name, os.ErrNotExist)
+}
+
+// canaryLastRun returns the most recent run of a canary, or null if it
+// hasn't run
+func (p *CloudWatchProvider) canaryLastRun(ctx context.Context, name string) ([]byte, error) {
+	var resp struct {
+		CanariesLastRun []struct {
+			CanaryName string
+			LastRun    map[string]any
+		}
+	}
+	in := map[string]any{"Names": []string{name}}
+	if err := p.synthetics.do(ctx, "DescribeCanariesLastRun", "POST", "/canaries/last-run", nil, in, &resp); err != nil {
+		return nil, err
+	}
+	var run map[string]any
+	for _, r := range resp.CanariesLastRun {
+		if r.CanaryName == name {
+			run = syntheticsTimes(r.LastRun)
+		}
+	}
+	return json.MarshalIndent(run, "", "  ")
+}
+
+// syntheticsTimes turns the epoch seconds of a canary's or run's Timeline,
+// e.g. Created or Started, into RFC 3339 times
+func syntheticsTimes(doc map[string]any) map[string]any {
+	timeline, _ := doc["Timeline"].(map[string]any)
+	for k, v := range timeline {
+		if secs, ok := v.(float64); ok {
+			timeline[k] = epochTime(secs)
+		}
+	}
+	return doc
+}
+
+func (p *CloudWatchProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "dashboards" && strings.HasSuffix(parts[1], ".json"):
+		return p.dashboard(ctx, strings.TrimSuffix(parts[1], ".json"))
+	case len(parts) == 3 && parts[0] == "canaries" && parts[2] == "config.json":
+		c, err := p.canary(ctx, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(c, "", "  ")
+	case len(parts) == 3 && parts[0] == "canaries" && parts[2] == "last-run.json":
+		if _, err := p.canary(ctx, parts[1]); err != nil {
+			return nil, err
+		}
+		return p.canaryLastRun(ctx, parts[1])
+	case len(parts) == 2 && parts[0] == "alarms":
+		alarms, err := p.listAlarms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		alarm, ok := alarms[unescapeSlash(strings.TrimSuffix(parts[1], ".json"))]
+		if !ok {
+			return nil, fmt.Errorf("alarm not found: %s: %w", parts[1], os.ErrNotExist)
+		}
+		return json.MarshalIndent(alarm, "", "  ")
+	case len(parts) > 3 && parts[0] == "metrics":
+		mp, err := p.resolveMetricsPath(ctx, parts[1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(mp.rest) != 2 {
+			break
+		}
+		switch mp.rest[1] {
+		case "dimensions.json":
+			sets, err := p.metricDimensions(ctx, mp.namespace, mp.rest[0])
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(sets, "", "  ")
+		case "recent-datapoints.json":
+			series, err := p.recentDatapoints(ctx, mp.namespace, mp.rest[0])
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(series, "", "  ")
+		}
+	}
+	return nil, fmt.Errorf("invalid path: %s", path)
+}
+
+func (p *CloudWatchProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		return &Entry{Name: "cloudwatch", IsDir: true}, nil
+	case path == "alarms" || path == "metrics" || path == "dashboards" || path == "canaries":
+		return &Entry{Name: path, IsDir: true}, nil
+	case len(parts) == 2 && parts[0] == "dashboards":
+		names, err := p.listDashboards(ctx)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := strings.CutSuffix(parts[1], ".json")
+		if i := sort.SearchStrings(names.items, name); ok && i < len(names.items) && names.items[i] == name {
+			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+		}
+		if ok && names.more {
+			// Past a truncated listing: the dashboard exists if it can be read
+			if _, err := p.dashboard(ctx, name); err != nil {
+				return nil, err
+			}
+			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+		}
+		return nil, fmt.Errorf("dashboard not found: %s: %w", parts[1], os.ErrNotExist)
+	case parts[0] == "canaries" && len(parts) <= 3:
+		if _, err := p.canary(ctx, parts[1]); err != nil {
+			return nil, err
+		}
+		if len(parts) == 2 {
+			return &Entry{Name: parts[1], IsDir: true}, nil
+		}
+		if parts[2] == "config.json" || parts[2] == "last-run.json" {
+			return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+		}
+	case len(parts) == 2 && parts[0] == "alarms":
+		alarms, err := p.listAlarms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := alarms[unescapeSlash(strings.TrimSuffix(parts[1], ".json"))]; ok && strings.HasSuffix(parts[1], ".json") {
+			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+		}
+	case parts[0] == "metrics":
+		mp, err := p.resolveMetricsPath(ctx, parts[1:])
+		if err != nil {
+			return nil, err
+		}
+		name := parts[len(parts)-1]
+		switch {
+		case mp.namespace == "" || len(mp.rest) == 0:
+			return &Entry{Name: name, IsDir: true}, nil
+		case len(mp.rest) == 1:
+			if _, err := p.metricDimensions(ctx, mp.namespace, mp.rest[0]); err != nil {
+				return nil, err
+			}
+			return &Entry{Name: name, IsDir: true}, nil
+		case len(mp.rest) == 2 && (name == "dimensions.json" || name == "recent-datapoints.json"):
+			return &Entry{Name: name, IsDir: false, Size: 4096}, nil
+		}
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCloudWatchAlarms(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "cloudwatch")
+	p := newCloudWatchProvider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "alarms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Slashes in names don't split them into directories
+	if names := entryNames(entries); !reflect.DeepEqual(names, []string{"api-degraded.json", "api-high-cpu.json", "web／latency.json"}) {
+		t.Fatalf("alarms = %v", names)
+	}
+	if _, err := p.Read(ctx, "alarms/web／latency.json"); err != nil {
+		t.Error(err)
+	}
+
+	data, err := p.Read(ctx, "alarms/api-high-cpu.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "cloudwatch/alarm.json", data)
+
+	if _, err := p.Stat(ctx, "alarms/missing.json"); err == nil {
+		t.Error("Stat of a missing alarm succeeded")
+	}
+}
+
+func TestCloudWatchNamespaces(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "cloudwatch")
+	p := newCloudWatchProvider(cfg)
+	ctx := context.Background()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"metrics", []string{"AWS", "MyApp"}},
+		{"metrics/AWS", []string{"EC2", "Lambda"}},
+		{"metrics/AWS/EC2", []string{"CPUUtilization", "NetworkIn"}},
+		{"metrics/AWS/EC2/CPUUtilization", []string{"dimensions.json", "recent-datapoints.json"}},
+	}
+	for _, tt := range tests {
+		entries, err := p.ReadDir(ctx, tt.path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if names := entryNames(entries); !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, names, tt.want)
+		}
+	}
+
+	if _, err := p.Stat(ctx, "metrics/Nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing namespace = %v, want ErrNotExist", err)
+	}
+	if _, err := p.Stat(ctx, "metrics/AWS/EC2/Nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing metric = %v, want ErrNotExist", err)
+	}
+}
+
+func TestCloudWatchNamespacePages(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 1000
+	cfg, client := fixtureConfig(t, "cloudwatch-endless")
+	p := newCloudWatchProvider(cfg)
+
+	// Namespaces found before CloudWatchNamespacePages ran out are listed
+	// with a marker, even under MaxEntries
+	entries, err := p.ReadDir(context.Background(), "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(entries); !reflect.DeepEqual(names, []string{"AWS", MoreResultsFile}) {
+		t.Errorf("metrics = %v", names)
+	}
+	if n := len(client.Calls()); n != CloudWatchNamespacePages {
+		t.Errorf("%d ListMetrics calls, want %d", n, CloudWatchNamespacePages)
+	}
+}
+
+func TestCloudWatchMetricFiles(t *testing.T) {
+	cfg, client := fixtureConfig(t, "cloudwatch")
+	p := newCloudWatchProvider(cfg)
+	p.now = func() time.Time { return time.Date(2024, 5, 1, 9, 2, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	data, err := p.Read(ctx, "metrics/AWS/EC2/CPUUtilization/dimensions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "cloudwatch/dimensions.json", data)
+
+	data, err = p.Read(ctx, "metrics/AWS/EC2/CPUUtilization/recent-datapoints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "cloudwatch/recent-datapoints.json", data)
+
+	// Both files share the namespace's metrics, listed once
+	want := []string{"ListMetrics", "ListMetrics", "GetMetricData"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestCloudWatchDashboardsAndCanaries(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "cloudwatch")
+	p := newCloudWatchProvider(cfg)
+	ctx := context.Background()
+
+	dashboards, err := p.ReadDir(ctx, "dashboards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(dashboards); !reflect.DeepEqual(names, []string{"billing.json", "service-health.json"}) {
+		t.Fatalf("dashboards = %v", names)
+	}
+	data, err := p.Read(ctx, "dashboards/service-health.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "cloudwatch/dashboard.json", data)
+
+	canaries, err := p.ReadDir(ctx, "canaries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(canaries); !reflect.DeepEqual(names, []string{"checkout-flow"}) {
+		t.Fatalf("canaries = %v", names)
+	}
+	data, err = p.Read(ctx, "canaries/checkout-flow/last-run.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "cloudwatch/last-run.json", data)
+
+	if _, err := p.Stat(ctx, "canaries/missing/config.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing canary = %v, want ErrNotExist", err)
+	}
+}
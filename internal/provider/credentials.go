@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/processcreds"
+	"github.com/semonte/sisu/internal/config"
+)
+
+var (
+	credentialsMu sync.RWMutex
+	// credentialSources are the profiles whose credentials come from the
+	// sisu config instead of the shared AWS config
+	credentialSources = make(map[string]aws.CredentialsProvider)
+)
+
+// SetCredentials makes LoadAWSConfig use creds for profile. Credentials
+// are cached until they expire, across every client of the profile.
+func SetCredentials(profile string, creds aws.CredentialsProvider) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	credentialSources[profile] = aws.NewCredentialsCache(creds)
+}
+
+// InvalidateCredentials makes the next client of profile retrieve its
+// configured credentials again instead of using the cached ones
+func InvalidateCredentials(profile string) {
+	creds, ok := credentialsFor(profile)
+	if !ok {
+		return
+	}
+	if c, ok := creds.(*aws.CredentialsCache); ok {
+		c.Invalidate()
+	}
+}
+
+// credentialsFor returns the credentials configured for profile, if any
+func credentialsFor(profile string) (aws.CredentialsProvider, bool) {
+	if profile == "" {
+		profile = "default"
+	}
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+	creds, ok := credentialSources[profile]
+	return creds, ok
+}
+
+// NewCredentials returns the credentials provider for a configured source
+func NewCredentials(c config.Credentials) (aws.CredentialsProvider, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch {
+	case c.Process != "":
+		return processcreds.NewProvider(c.Process), nil
+	case c.Vault != nil:
+		return &vaultCredentials{address: c.Vault.Address, path: c.Vault.Path, client: http.DefaultClient}, nil
+	}
+	return envCredentials(*c.Env), nil
+}
+
+// envCredentials reads credentials from the named environment variables
+// on every retrieval, so rotated values are picked up once they expire
+type envCredentials config.EnvCredentials
+
+func (e envCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds := aws.Credentials{
+		AccessKeyID:     os.Getenv(e.AccessKeyID),
+		SecretAccessKey: os.Getenv(e.SecretAccessKey),
+		Source:          "sisu env credentials",
+	}
+	if e.SessionToken != "" {
+		creds.SessionToken = os.Getenv(e.SessionToken)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return aws.Credentials{}, fmt.Errorf("credentials not set: $%s and $%s must both be set", e.AccessKeyID, e.SecretAccessKey)
+	}
+	return creds, nil
+}
+
+// vaultCredentials reads credentials from a Vault AWS secrets engine. Each
+// read of aws/creds/<role> issues a new IAM user and aws/sts/<role> new STS
+// credentials, so they're only read again once the lease runs out.
+type vaultCredentials struct {
+	address string
+	path    string
+	client  *http.Client
+}
+
+func (v *vaultCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	address := v.address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return aws.Credentials{}, fmt.Errorf("vault: no address configured and $VAULT_ADDR not set")
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	url := strings.TrimRight(address, "/") + "/v1/" + strings.TrimLeft(v.path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Errors may come from a proxy or load balancer in front of Vault as
+	// HTML or plain text, so the status is checked before the body is
+	// decoded as a secret
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		msg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &failure) == nil && len(failure.Errors) > 0 {
+			msg = strings.Join(failure.Errors, "; ")
+		}
+		return aws.Credentials{}, fmt.Errorf("vault: reading %s: %s: %s", v.path, resp.Status, msg)
+	}
+
+	var secret struct {
+		LeaseDuration int64 `json:"lease_duration"`
+		Data          struct {
+			AccessKey     string `json:"access_key"`
+			SecretKey     string `json:"secret_key"`
+			SecurityToken string `json:"security_token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return aws.Credentials{}, fmt.Errorf("vault: reading %s: %w", v.path, err)
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     secret.Data.AccessKey,
+		SecretAccessKey: secret.Data.SecretKey,
+		SessionToken:    secret.Data.SecurityToken,
+		Source:          "sisu vault credentials",
+	}
+	if secret.LeaseDuration > 0 {
+		creds.CanExpire = true
+		creds.Expires = time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second)
+	}
+	return creds, nil
+}
+
+// vaultToken returns $VAULT_TOKEN, or the token `vault login` saved
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("vault: no token in $VAULT_TOKEN or ~/.vault-token (run vault login)")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semonte/sisu/internal/config"
+)
+
+func TestVaultCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") == "s.proxied" {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+			return
+		}
+		if r.URL.Path != "/v1/aws/sts/deploy" || r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"lease_duration":3600,"data":{"access_key":"AKIAVAULT","secret_key":"secret","security_token":"session"}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	creds, err := NewCredentials(config.Credentials{Vault: &config.Vault{Address: server.URL, Path: "aws/sts/deploy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := creds.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "AKIAVAULT" || got.SecretAccessKey != "secret" || got.SessionToken != "session" {
+		t.Errorf("credentials = %+v", got)
+	}
+	if !got.CanExpire || time.Until(got.Expires) < 59*time.Minute {
+		t.Errorf("Expires = %v, want in an hour", got.Expires)
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := creds.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "403 Forbidden: permission denied") {
+		t.Errorf("bad token: err = %v, want Vault's error", err)
+	}
+
+	// A proxy's error page is reported as such, not as invalid JSON
+	t.Setenv("VAULT_TOKEN", "s.proxied")
+	if _, err := creds.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "502 Bad Gateway: <html>") {
+		t.Errorf("proxy error: err = %v, want the status and body", err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	creds, err := NewCredentials(config.Credentials{Env: &config.EnvCredentials{AccessKeyID: "CI_KEY", SecretAccessKey: "CI_SECRET"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CI_KEY", "")
+	if _, err := creds.Retrieve(context.Background()); err == nil {
+		t.Error("unset variables: want an error")
+	}
+
+	t.Setenv("CI_KEY", "AKIAENV")
+	t.Setenv("CI_SECRET", "secret")
+	got, err := creds.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "AKIAENV" || got.SecretAccessKey != "secret" {
+		t.Errorf("credentials = %+v", got)
+	}
+}
+
+func TestProcessCredentials(t *testing.T) {
+	creds, err := NewCredentials(config.Credentials{
+		Process: `echo '{"Version":1,"AccessKeyId":"AKIAPROC","SecretAccessKey":"secret"}'`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := creds.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "AKIAPROC" {
+		t.Errorf("AccessKeyID = %q, want AKIAPROC", got.AccessKeyID)
+	}
+}
+
+func TestLoadAWSConfigCredentials(t *testing.T) {
+	t.Setenv("SISU_TEST_KEY", "AKIASET")
+	t.Setenv("SISU_TEST_SECRET", "secret")
+	creds, err := NewCredentials(config.Credentials{Env: &config.EnvCredentials{AccessKeyID: "SISU_TEST_KEY", SecretAccessKey: "SISU_TEST_SECRET"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetCredentials("sisu-test-only", creds)
+
+	// The profile isn't in any shared config file, so loading it only
+	// succeeds when the configured credentials are used
+	cfg, err := LoadAWSConfig("sisu-test-only", "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "AKIASET" {
+		t.Errorf("AccessKeyID = %q, want AKIASET", got.AccessKeyID)
+	}
+}
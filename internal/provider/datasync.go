@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// DataSyncProvider provides DataSync tasks as directories named by task
+// ID, as names are optional and needn't be unique:
+//
+//	<task-id>/info.json        the task: name, locations, options, schedule, status
+//	<task-id>/statistics.json  its latest execution: status, bytes and files transferred, error
+type DataSyncProvider struct {
+	ReadOnlyProvider
+	client *restJSONClient
+	tasks  *documents[cappedList[map[string]string]] // task ARNs by ID, under ""
+}
+
+// Files of a DataSync task directory
+const (
+	dataSyncInfoFile       = "info.json"
+	dataSyncStatisticsFile = "statistics.json"
+)
+
+// NewDataSyncProvider creates a new DataSync provider
+func NewDataSyncProvider(profile, region string) (*DataSyncProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newDataSyncProvider(cfg), nil
+}
+
+func newDataSyncProvider(cfg aws.Config) *DataSyncProvider {
+	client := newJSONRPCClient(cfg, "DataSync", "datasync", "FmrsService")
+	client.jsonVersion = "1.1"
+	return &DataSyncProvider{
+		client: client,
+		tasks:  newDocuments[cappedList[map[string]string]](),
+	}
+}
+
+func (p *DataSyncProvider) Name() string {
+	return "datasync"
+}
+
+// Invalidate forgets the memoized task ARNs, so a refresh describes them anew
+func (p *DataSyncProvider) Invalidate(string) {
+	forgetDocuments(p.tasks)
+}
+
+func (p *DataSyncProvider) Close() {
+	closeDocuments(p.tasks)
+}
+
+// listTasks returns the ARNs of the region's tasks by ID, the last part
+// of the ARN, up to MaxEntries of them
+func (p *DataSyncProvider) listTasks(ctx context.Context) (cappedList[map[string]string], error) {
+	return p.tasks.get("", func() (cappedList[map[string]string], error) {
+		arns := make(map[string]string)
+		in := map[string]any{"MaxResults": 100}
+		for {
+			var resp struct {
+				Tasks []struct {
+					TaskArn string
+				}
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListTasks", in, &resp); err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			for _, t := range resp.Tasks {
+				arns[t.TaskArn[strings.LastIndex(t.TaskArn, "/")+1:]] = t.TaskArn
+			}
+			if resp.NextToken == "" || len(arns) >= MaxEntries {
+				return cappedList[map[string]string]{items: arns, more: resp.NextToken != ""}, nil
+			}
+			in = map[string]any{"MaxResults": 100, "NextToken": resp.NextToken}
+		}
+	})
+}
+
+// taskARN returns the ARN of the task with the ID, or an error wrapping
+// os.ErrNotExist
+func (p *DataSyncProvider) taskARN(ctx context.Context, id string) (string, error) {
+	arns, err := p.listTasks(ctx)
+	if err != nil {
+		return "", err
+	}
+	arn, ok := arns.items[id]
+	if !ok {
+		return "", fmt.Errorf("task not found: %s: %w", id, os.ErrNotExist)
+	}
+	return arn, nil
+}
+
+// describeTask returns the description of the task with the ID
+func (p *DataSyncProvider) describeTask(ctx context.Context, id string) (map[string]any, error) {
+	arn, err := p.taskARN(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var task map[string]any
+	if err := p.client.call(ctx, "DescribeTask", map[string]any{"TaskArn": arn}, &task); err != nil {
+		if isAPIError(err, "InvalidRequestException") {
+			return nil, fmt.Errorf("task not found: %s: %w", id, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	return jsonTimes(task), nil
+}
+
+// dataSyncStatistics is statistics.json of a task
+type dataSyncStatistics struct {
+	TaskStatus string         `json:"taskStatus"`
+	LastError  string         `json:"lastError,omitempty"` // of the task, e.g. an unreachable location
+	Execution  map[string]any `json:"latestExecution"`     // DescribeTaskExecution of the newest run, null if it never ran
+}
+
+// statistics returns the task's status with its newest execution
+func (p *DataSyncProvider) statistics(ctx context.Context, task map[string]any) (*dataSyncStatistics, error) {
+	stats := &dataSyncStatistics{}
+	stats.TaskStatus, _ = task["Status"].(string)
+	if detail, _ := task["ErrorDetail"].(string); detail != "" {
+		stats.LastError = detail
+	} else {
+		stats.LastError, _ = task["ErrorCode"].(string)
+	}
+
+	// The current execution is set while one runs; otherwise the newest is
+	// the last of ListTaskExecutions, which lists oldest first
+	execArn, _ := task["CurrentTaskExecutionArn"].(string)
+	if execArn == "" {
+		arn, _ := task["TaskArn"].(string)
+		in := map[string]any{"TaskArn": arn, "MaxResults": 100}
+		for {
+			var resp struct {
+				TaskExecutions []struct {
+					TaskExecutionArn string
+				}
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListTaskExecutions", in, &resp); err != nil {
+				return nil, err
+			}
+			if n := len(resp.TaskExecutions); n > 0 {
+				execArn = resp.TaskExecutions[n-1].TaskExecutionArn
+			}
+			if resp.NextToken == "" {
+				break
+			}
+			in = map[string]any{"TaskArn": arn, "MaxResults": 100, "NextToken": resp.NextToken}
+		}
+	}
+	if execArn == "" {
+		return stats, nil
+	}
+	if err := p.client.call(ctx, "DescribeTaskExecution", map[string]any{"TaskExecutionArn": execArn}, &stats.Execution); err != nil {
+		return nil, err
+	}
+	jsonTimes(stats.Execution)
+	return stats, nil
+}
+
+func (p *DataSyncProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		arns, err := p.listTasks(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws datasync list-tasks", err)
+		}
+		entries := make([]Entry, 0, len(arns.items))
+		for id := range arns.items {
+			entries = append(entries, Entry{Name: id, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, arns.more, "aws datasync list-tasks"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if _, err := p.taskARN(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: dataSyncInfoFile, IsDir: false, Size: 4096},
+		{Name: dataSyncStatisticsFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+func (p *DataSyncProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	id, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if file != dataSyncInfoFile && file != dataSyncStatisticsFile {
+		return nil, fmt.Errorf("unknown file: %s", file)
+	}
+	task, err := p.describeTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file == dataSyncInfoFile {
+		return json.MarshalIndent(task, "", "  ")
+	}
+	stats, err := p.statistics(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(stats, "", "  ")
+}
+
+func (p *DataSyncProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "datasync", IsDir: true}, nil
+	}
+	id, file, _ := strings.Cut(path, "/")
+	if _, err := p.taskARN(ctx, id); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: id, IsDir: true}, nil
+	case dataSyncInfoFile, dataSyncStatisticsFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
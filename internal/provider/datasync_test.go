@@ -0,0 +1,19 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDataSyncTasks(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "datasync")
+	p := newDataSyncProvider(cfg)
+	ctx := context.Background()
+
+	// Without a running execution, statistics come from the newest listed
+	data, err := p.Read(ctx, "task-0a1b2c3d4e5f60718/statistics.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "datasync/statistics.json", data)
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// AccessDeniedFile is the virtual file listed in place of a directory's
+// contents when the credentials aren't allowed to list it
+const AccessDeniedFile = "_access-denied.txt"
+
+// IsAccessDenied reports whether err means the credentials lack permission,
+// as opposed to the resource being missing or the call failing
+func IsAccessDenied(err error) bool {
+	if errors.Is(err, fs.ErrPermission) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return strings.Contains(code, "AccessDenied") ||
+		code == "UnauthorizedOperation" ||
+		code == "AuthorizationError" ||
+		code == "Forbidden"
+}
+
+// AccessDenied returns a middleware that turns a denied directory listing
+// into a listing of AccessDeniedFile explaining what was denied, so a role
+// that can use some services but not others still gets a browsable tree.
+// A listing denied, throttled or over a quota after some pages (see
+// PartialListingError) lists what was fetched and a WarningFile. It also
+// serves the MoreResultsFile of listings cut off at MaxEntries, so
+// providers only need capEntries to add one. onDenied, if set, is called
+// with the path of each denied directory.
+func AccessDenied(onDenied func(path string)) Middleware {
+	return func(p Provider) Provider {
+		return &deniedProvider{Provider: p, onDenied: onDenied, denied: make(map[string]deniedDir)}
+	}
+}
+
+type deniedProvider struct {
+	Provider
+	onDenied func(path string)
+
+	mu     sync.Mutex
+	denied map[string]deniedDir // explainers by marker file path
+}
+
+type deniedDir struct {
+	name    string // AccessDeniedFile, WarningFile or MoreResultsFile
+	message string
+	at      time.Time
+}
+
+func (d deniedDir) entry() *Entry {
+	return &Entry{Name: d.name, Size: int64(len(d.message)), ModTime: d.at}
+}
+
+func (p *deniedProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.Provider.ReadDir(ctx, path)
+	if err == nil {
+		if n := len(entries); n > 0 && entries[n-1].moreHint != "" {
+			p.remember(path, deniedDir{name: MoreResultsFile, message: moreResultsMessage(entries[n-1].moreHint), at: time.Now()})
+		}
+		return entries, nil
+	}
+
+	var partial *PartialListingError
+	if errors.As(err, &partial) {
+		d := deniedDir{name: WarningFile, message: warningMessage(p.Name(), path, partial), at: time.Now()}
+		p.remember(path, d)
+		return append(partial.Entries, *d.entry()), nil
+	}
+	if !IsAccessDenied(err) {
+		return nil, err
+	}
+
+	d := deniedDir{name: AccessDeniedFile, message: deniedMessage(p.Name(), path, err), at: time.Now()}
+	p.remember(path, d)
+	if p.onDenied != nil {
+		p.onDenied(path)
+	}
+	return []Entry{*d.entry()}, nil
+}
+
+func (p *deniedProvider) remember(dir string, d deniedDir) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.denied[joinPath(dir, d.name)] = d
+}
+
+// deniedFile returns the explainer for path if it is the marker of a
+// denied, partially listed or truncated directory
+func (p *deniedProvider) deniedFile(path string) (deniedDir, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.denied[path]
+	return d, ok
+}
+
+func (p *deniedProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	if d, ok := p.deniedFile(path); ok {
+		return []byte(d.message), nil
+	}
+	return p.Provider.Read(ctx, path)
+}
+
+func (p *deniedProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	if d, ok := p.deniedFile(path); ok {
+		return sliceRange([]byte(d.message), off, length), nil
+	}
+	return ReadRange(ctx, p.Provider, path, off, length)
+}
+
+func (p *deniedProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	if d, ok := p.deniedFile(path); ok {
+		return map[string][]byte{path: []byte(d.message)}, nil
+	}
+	return Prefetch(ctx, p.Provider, path)
+}
+
+func (p *deniedProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if d, ok := p.deniedFile(path); ok {
+		return d.entry(), nil
+	}
+	return p.Provider.Stat(ctx, path)
+}
+
+func (p *deniedProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	rest := make([]string, 0, len(paths))
+	markers := make(map[string]*Entry)
+	for _, path := range paths {
+		if d, ok := p.deniedFile(path); ok {
+			markers[path] = d.entry()
+		} else {
+			rest = append(rest, path)
+		}
+	}
+	if len(rest) == 0 {
+		return markers, nil
+	}
+	entries, err := StatBatch(ctx, p.Provider, rest)
+	if err != nil {
+		return nil, err
+	}
+	for path, entry := range markers {
+		entries[path] = entry
+	}
+	return entries, nil
+}
+
+func (p *deniedProvider) Writable(path string) bool {
+	if _, ok := p.deniedFile(path); ok {
+		return false
+	}
+	return p.Provider.Writable(path)
+}
+
+func (p *deniedProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *deniedProvider) Close() {
+	Close(p.Provider)
+}
+
+func (p *deniedProvider) Written(path string, data []byte) ([]byte, bool) {
+	return Written(p.Provider, path, data)
+}
+
+// deniedMessage explains a denied listing and how to find the missing permission
+func deniedMessage(service, path string, err error) string {
+	where := service
+	if path != "" {
+		where += "/" + path
+	}
+	return fmt.Sprintf("Access denied listing %s.\n\n%v\n\n"+
+		"The credentials for this profile don't allow it. Other services are\n"+
+		"unaffected. Ask for the missing permission, or set hide_denied: true in\n"+
+		"~/.sisu/config.yaml to leave denied services out of the tree.\n", where, err)
+}
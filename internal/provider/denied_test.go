@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestAccessDeniedListsExplainer(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/b.txt": []byte("hello")})
+	fake.fail = &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform iam:ListRoles"}
+	var denied []string
+	p := Cached(Chain(fake, AccessDenied(func(path string) { denied = append(denied, path) })), DefaultCachePolicy)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != AccessDeniedFile {
+		t.Fatalf("ReadDir = %+v, want only %s", entries, AccessDeniedFile)
+	}
+	if len(denied) != 1 || denied[0] != "" {
+		t.Errorf("onDenied called with %q", denied)
+	}
+
+	data, err := p.Read(ctx, AccessDeniedFile)
+	if err != nil || !strings.Contains(string(data), "iam:ListRoles") {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+	if entry, err := p.Stat(ctx, AccessDeniedFile); err != nil || entry.Size != int64(len(data)) {
+		t.Errorf("Stat = %+v, %v", entry, err)
+	}
+	if p.Writable(AccessDeniedFile) {
+		t.Error("explainer is writable")
+	}
+}
+
+func TestAccessDeniedPassesOtherErrors(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{})
+	fake.fail = errors.New("throttled")
+	p := Chain(fake, AccessDenied(nil))
+
+	if _, err := p.ReadDir(context.Background(), "a"); err == nil {
+		t.Error("ReadDir hid a non-permission error")
+	}
+	if _, err := p.Read(context.Background(), "a/"+AccessDeniedFile); err == nil {
+		t.Error("explainer served for a directory that wasn't denied")
+	}
+}
+
+func TestAccessDeniedServesMoreResults(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{})
+	p := Chain(truncatedProvider{fake}, AccessDenied(nil))
+	ctx := context.Background()
+
+	if _, err := p.Read(ctx, "a/"+MoreResultsFile); err == nil {
+		t.Error("marker served before its directory was listed")
+	}
+	entries, err := p.ReadDir(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Read(ctx, "a/"+MoreResultsFile)
+	if err != nil || !strings.Contains(string(data), "aws example list") {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+	if entry := entries[len(entries)-1]; entry.Size != int64(len(data)) {
+		t.Errorf("listed size %d, read %d bytes", entry.Size, len(data))
+	}
+}
+
+// truncatedProvider lists every directory as cut off at MaxEntries
+type truncatedProvider struct {
+	*fakeProvider
+}
+
+func (p truncatedProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	return capEntries([]Entry{{Name: "x"}}, true, "aws example list"), nil
+}
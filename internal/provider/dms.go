@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// DMSProvider provides Database Migration Service replication tasks as
+// directories named by task identifier:
+//
+//	<task>/info.json        the task: endpoints, instance, migration type, table mappings
+//	<task>/statistics.json  its progress: status, rows replicated per table, last error
+type DMSProvider struct {
+	ReadOnlyProvider
+	client *restJSONClient
+	tasks  *documents[cappedList[map[string]map[string]any]] // tasks by identifier, under ""
+}
+
+// Files of a replication task directory
+const (
+	dmsInfoFile       = "info.json"
+	dmsStatisticsFile = "statistics.json"
+)
+
+// NewDMSProvider creates a new DMS provider
+func NewDMSProvider(profile, region string) (*DMSProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newDMSProvider(cfg), nil
+}
+
+func newDMSProvider(cfg aws.Config) *DMSProvider {
+	client := newJSONRPCClient(cfg, "DatabaseMigrationService", "dms", "AmazonDMSv20160101")
+	client.jsonVersion = "1.1"
+	return &DMSProvider{
+		client: client,
+		tasks:  newDocuments[cappedList[map[string]map[string]any]](),
+	}
+}
+
+func (p *DMSProvider) Name() string {
+	return "dms"
+}
+
+// Invalidate forgets the memoized replication tasks, so a refresh describes them anew
+func (p *DMSProvider) Invalidate(string) {
+	forgetDocuments(p.tasks)
+}
+
+func (p *DMSProvider) Close() {
+	closeDocuments(p.tasks)
+}
+
+// listTasks returns the region's replication tasks by identifier, up to
+// MaxEntries of them
+func (p *DMSProvider) listTasks(ctx context.Context) (cappedList[map[string]map[string]any], error) {
+	return p.tasks.get("", func() (cappedList[map[string]map[string]any], error) {
+		tasks := make(map[string]map[string]any)
+		in := map[string]any{"MaxRecords": 100}
+		for {
+			var resp struct {
+				ReplicationTasks []map[string]any
+				Marker           string
+			}
+			if err := p.client.call(ctx, "DescribeReplicationTasks", in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			for _, t := range resp.ReplicationTasks {
+				if id, ok := t["ReplicationTaskIdentifier"].(string); ok {
+					if stats, ok := t["ReplicationTaskStats"].(map[string]any); ok {
+						jsonTimes(stats)
+					}
+					tasks[id] = jsonTimes(t)
+				}
+			}
+			if resp.Marker == "" || len(tasks) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: tasks, more: resp.Marker != ""}, nil
+			}
+			in = map[string]any{"MaxRecords": 100, "Marker": resp.Marker}
+		}
+	})
+}
+
+// task returns the replication task with the identifier, or an error
+// wrapping os.ErrNotExist
+func (p *DMSProvider) task(ctx context.Context, id string) (map[string]any, error) {
+	tasks, err := p.listTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := tasks.items[id]
+	if !ok {
+		return nil, fmt.Errorf("replication task not found: %s: %w", id, os.ErrNotExist)
+	}
+	return t, nil
+}
+
+// dmsStatistics is statistics.json of a replication task
+type dmsStatistics struct {
+	Status       string           `json:"status"`
+	StopReason   string           `json:"stopReason,omitempty"`
+	LastError    string           `json:"lastError,omitempty"`
+	Progress     any              `json:"progress,omitempty"` // ReplicationTaskStats: percent loaded, tables loaded and errored
+	FullLoadRows int64            `json:"fullLoadRows"`
+	Inserts      int64            `json:"inserts"`
+	Updates      int64            `json:"updates"`
+	Deletes      int64            `json:"deletes"`
+	Tables       []map[string]any `json:"tables"`
+	// TablesOmitted counts the tables past MaxEntries left out of Tables;
+	// the row counts above include them
+	TablesOmitted int `json:"tablesOmitted,omitempty"`
+}
+
+// statistics returns the progress of a task with its per-table row counts
+func (p *DMSProvider) statistics(ctx context.Context, task map[string]any) (*dmsStatistics, error) {
+	stats := &dmsStatistics{Tables: []map[string]any{}, Progress: task["ReplicationTaskStats"]}
+	stats.Status, _ = task["Status"].(string)
+	stats.StopReason, _ = task["StopReason"].(string)
+	stats.LastError, _ = task["LastFailureMessage"].(string)
+
+	arn, _ := task["ReplicationTaskArn"].(string)
+	in := map[string]any{"ReplicationTaskArn": arn, "MaxRecords": 500}
+	for {
+		var resp struct {
+			TableStatistics []map[string]any
+			Marker          string
+		}
+		if err := p.client.call(ctx, "DescribeTableStatistics", in, &resp); err != nil {
+			return nil, err
+		}
+		for _, t := range resp.TableStatistics {
+			if len(stats.Tables) < MaxEntries {
+				stats.Tables = append(stats.Tables, jsonTimes(t))
+			} else {
+				stats.TablesOmitted++
+			}
+			stats.FullLoadRows += dmsCount(t["FullLoadRows"])
+			stats.Inserts += dmsCount(t["Inserts"])
+			stats.Updates += dmsCount(t["Updates"])
+			stats.Deletes += dmsCount(t["Deletes"])
+		}
+		if resp.Marker == "" {
+			return stats, nil
+		}
+		in = map[string]any{"ReplicationTaskArn": arn, "MaxRecords": 500, "Marker": resp.Marker}
+	}
+}
+
+func dmsCount(v any) int64 {
+	n, _ := v.(float64)
+	return int64(n)
+}
+
+func (p *DMSProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		tasks, err := p.listTasks(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws dms describe-replication-tasks", err)
+		}
+		entries := make([]Entry, 0, len(tasks.items))
+		for id := range tasks.items {
+			entries = append(entries, Entry{Name: id, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, tasks.more, "aws dms describe-replication-tasks"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if _, err := p.task(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: dmsInfoFile, IsDir: false, Size: 4096},
+		{Name: dmsStatisticsFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+func (p *DMSProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	id, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if file != dmsInfoFile && file != dmsStatisticsFile {
+		return nil, fmt.Errorf("unknown file: %s", file)
+	}
+	task, err := p.task(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file == dmsInfoFile {
+		return json.MarshalIndent(task, "", "  ")
+	}
+	stats, err := p.statistics(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(stats, "", "  ")
+}
+
+func (p *DMSProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "dms", IsDir: true}, nil
+	}
+	id, file, _ := strings.Cut(path, "/")
+	if _, err := p.task(ctx, id); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: id, IsDir: true}, nil
+	case dmsInfoFile, dmsStatisticsFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDMSTasks(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "dms")
+	p := newDMSProvider(cfg)
+	ctx := context.Background()
+
+	data, err := p.Read(ctx, "orders-to-aurora/statistics.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "dms/statistics.json", data)
+}
+
+func TestDMSTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	cfg, _ := fixtureConfig(t, "dms")
+	ctx := context.Background()
+	var full dmsStatistics
+	data, err := newDMSProvider(cfg).Read(ctx, "orders-to-aurora/statistics.json")
+	if err != nil || json.Unmarshal(data, &full) != nil {
+		t.Fatalf("Read = %s, %v", data, err)
+	}
+
+	MaxEntries = 1
+	p := newDMSProvider(cfg)
+	tasks, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(tasks); !reflect.DeepEqual(names, []string{"archive-copy", MoreResultsFile}) {
+		t.Errorf("tasks = %v", names)
+	}
+
+	// Row counts cover the tables left out of the list
+	var stats dmsStatistics
+	data, err = p.Read(ctx, "orders-to-aurora/statistics.json")
+	if err != nil || json.Unmarshal(data, &stats) != nil {
+		t.Fatalf("Read = %s, %v", data, err)
+	}
+	if len(stats.Tables) != 1 || stats.TablesOmitted != 1 || stats.FullLoadRows != full.FullLoadRows || stats.Inserts != full.Inserts {
+		t.Errorf("statistics = %+v, want one table listed and the totals of both", stats)
+	}
+}
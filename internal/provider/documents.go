@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/semonte/sisu/internal/cache"
+)
+
+// documentTTL is how long a resource's description is shared between the
+// files derived from it. It only has to span one burst of stats and reads
+// (an `ls -l` or `cat *.json`); the result cache governs freshness beyond that.
+const documentTTL = 15 * time.Second
+
+// documents memoizes the API response describing each resource, e.g. an
+// EC2 instance, so every file and stat derived from it costs one call even
+// where the result cache doesn't help: stats, ranged reads, and differently
+// named files. Errors are never memoized.
+type documents[T any] struct {
+	cache *cache.Cache
+}
+
+func newDocuments[T any]() *documents[T] {
+	return &documents[T]{cache: cache.New(documentTTL)}
+}
+
+// get returns the document for key, calling fetch if there's none recent
+func (d *documents[T]) get(key string, fetch func() (T, error)) (T, error) {
+	if cached, ok := d.cache.Get(key); ok {
+		return cached.(T), nil
+	}
+	doc, err := fetch()
+	if err == nil {
+		d.cache.Set(key, doc)
+	}
+	return doc, err
+}
+
+// forget drops the document for key after the resource changed
+func (d *documents[T]) forget(key string) {
+	d.cache.Delete(key)
+}
+
+// forgetAll drops every document
+func (d *documents[T]) forgetAll() {
+	d.cache.Clear()
+}
+
+// forgetDocuments drops every document of each memo, for providers'
+// Invalidate
+func forgetDocuments(memos ...interface{ forgetAll() }) {
+	for _, m := range memos {
+		m.forgetAll()
+	}
+}
+
+// close stops the memo's sweeping of expired documents
+func (d *documents[T]) close() {
+	d.cache.Close()
+}
+
+// closeDocuments stops the sweeping of each memo, for providers' Close
+func closeDocuments(memos ...interface{ close() }) {
+	for _, m := range memos {
+		m.close()
+	}
+}
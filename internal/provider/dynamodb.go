@@ -0,0 +1,373 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// DynamoDBSampleSize is how many items of a table are listed in keys/
+const DynamoDBSampleSize = 25
+
+// DynamoDBProvider provides access to DynamoDB table schemas, tags and a
+// sample of each table's items
+type DynamoDBProvider struct {
+	ReadOnlyProvider
+	client  *dynamodb.Client
+	tables  *documents[*types.TableDescription]
+	samples *documents[map[string]map[string]types.AttributeValue]
+}
+
+// NewDynamoDBProvider creates a new DynamoDB provider
+func NewDynamoDBProvider(profile, region string) (*DynamoDBProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newDynamoDBProvider(cfg), nil
+}
+
+func newDynamoDBProvider(cfg aws.Config) *DynamoDBProvider {
+	return &DynamoDBProvider{
+		client:  dynamodb.NewFromConfig(cfg),
+		tables:  newDocuments[*types.TableDescription](),
+		samples: newDocuments[map[string]map[string]types.AttributeValue](),
+	}
+}
+
+func (p *DynamoDBProvider) Name() string {
+	return "dynamodb"
+}
+
+// Invalidate forgets the memoized table descriptions and samples, so a refresh describes them anew
+func (p *DynamoDBProvider) Invalidate(string) {
+	forgetDocuments(p.tables, p.samples)
+}
+
+func (p *DynamoDBProvider) Close() {
+	closeDocuments(p.tables, p.samples)
+}
+
+func (p *DynamoDBProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	// Root: list all tables
+	if path == "" {
+		return p.listTables(ctx)
+	}
+
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 1:
+		return []Entry{
+			{Name: "info.json", IsDir: false},
+			{Name: "indexes.json", IsDir: false},
+			{Name: "tags.json", IsDir: false},
+			{Name: "keys", IsDir: true},
+		}, nil
+	case len(parts) == 2 && parts[1] == "keys":
+		items, err := p.sample(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]Entry, 0, len(items))
+		for name := range items {
+			entries = append(entries, Entry{Name: name, IsDir: false})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return entries, nil
+	}
+
+	return nil, fmt.Errorf("unknown path: %s", path)
+}
+
+func (p *DynamoDBProvider) listTables(ctx context.Context) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, start string) ([]Entry, string, error) {
+		resp, err := p.client.ListTables(ctx, &dynamodb.ListTablesInput{
+			ExclusiveStartTableName: optionalString(start),
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		entries := make([]Entry, 0, len(resp.TableNames))
+		for _, name := range resp.TableNames {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		return entries, aws.ToString(resp.LastEvaluatedTableName), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, dynamoDBListHint, err)
+	}
+	return entries, nil
+}
+
+const dynamoDBListHint = "aws dynamodb list-tables"
+
+// describeTable returns the table's description, shared by its directory
+// stat, info.json and indexes.json
+func (p *DynamoDBProvider) describeTable(ctx context.Context, table string) (*types.TableDescription, error) {
+	return p.tables.get(table, func() (*types.TableDescription, error) {
+		resp, err := p.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Table, nil
+	})
+}
+
+// sample scans the first DynamoDBSampleSize items of a table, keyed by
+// their filename in keys/
+func (p *DynamoDBProvider) sample(ctx context.Context, table string) (map[string]map[string]types.AttributeValue, error) {
+	desc, err := p.describeTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return p.samples.get(table, func() (map[string]map[string]types.AttributeValue, error) {
+		resp, err := p.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName: aws.String(table),
+			Limit:     aws.Int32(DynamoDBSampleSize),
+		})
+		if err != nil {
+			return nil, err
+		}
+		items := make(map[string]map[string]types.AttributeValue, len(resp.Items))
+		for _, item := range resp.Items {
+			items[dynamoDBItemName(desc.KeySchema, item)] = item
+		}
+		return items, nil
+	})
+}
+
+// dynamoDBItemName names an item's file after its key: the partition key
+// value, then the sort key value if the table has one, e.g. "user-1,2024-03-01.json".
+// Slashes in string keys are escaped, as in "orders／2024,1.json".
+func dynamoDBItemName(schema []types.KeySchemaElement, item map[string]types.AttributeValue) string {
+	values := make([]string, 0, len(schema))
+	for _, keyType := range []types.KeyType{types.KeyTypeHash, types.KeyTypeRange} {
+		for _, k := range schema {
+			if k.KeyType == keyType {
+				values = append(values, dynamoDBKeyString(item[aws.ToString(k.AttributeName)]))
+			}
+		}
+	}
+	return strings.Join(values, ",") + ".json"
+}
+
+// dynamoDBKeyString formats a key attribute, which is a string, number or
+// binary, as part of a file name
+func dynamoDBKeyString(v types.AttributeValue) string {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return escapeSlash(v.Value)
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return base64.RawURLEncoding.EncodeToString(v.Value)
+	}
+	return ""
+}
+
+// dynamoDBValue converts an attribute value to plain JSON: maps, lists,
+// strings, numbers (kept exact), booleans, null, base64 binaries, and
+// sets as arrays
+func dynamoDBValue(v types.AttributeValue) any {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return json.Number(v.Value)
+	case *types.AttributeValueMemberB:
+		return v.Value
+	case *types.AttributeValueMemberBOOL:
+		return v.Value
+	case *types.AttributeValueMemberNULL:
+		return nil
+	case *types.AttributeValueMemberM:
+		return dynamoDBItem(v.Value)
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = dynamoDBValue(e)
+		}
+		return list
+	case *types.AttributeValueMemberSS:
+		return v.Value
+	case *types.AttributeValueMemberNS:
+		numbers := make([]json.Number, len(v.Value))
+		for i, n := range v.Value {
+			numbers[i] = json.Number(n)
+		}
+		return numbers
+	case *types.AttributeValueMemberBS:
+		return v.Value
+	}
+	return nil
+}
+
+func dynamoDBItem(item map[string]types.AttributeValue) map[string]any {
+	doc := make(map[string]any, len(item))
+	for k, v := range item {
+		doc[k] = dynamoDBValue(v)
+	}
+	return doc
+}
+
+func (p *DynamoDBProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "info.json":
+		desc, err := p.describeTable(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(desc, "", "  ")
+	case len(parts) == 2 && parts[1] == "indexes.json":
+		desc, err := p.describeTable(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(struct {
+			GlobalSecondaryIndexes []types.GlobalSecondaryIndexDescription
+			LocalSecondaryIndexes  []types.LocalSecondaryIndexDescription
+		}{desc.GlobalSecondaryIndexes, desc.LocalSecondaryIndexes}, "", "  ")
+	case len(parts) == 2 && parts[1] == TagsFile:
+		tags, err := p.tableTags(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return renderTags(tags)
+	case len(parts) == 3 && parts[1] == "keys":
+		items, err := p.sample(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		item, ok := items[parts[2]]
+		if !ok {
+			return nil, fmt.Errorf("item not in sample: %s: %w", path, os.ErrNotExist)
+		}
+		return json.MarshalIndent(dynamoDBItem(item), "", "  ")
+	}
+
+	return nil, fmt.Errorf("invalid path: %s", path)
+}
+
+func (p *DynamoDBProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "dynamodb", IsDir: true}, nil
+	}
+
+	parts := strings.Split(path, "/")
+	if _, err := p.describeTable(ctx, parts[0]); err != nil {
+		return nil, fmt.Errorf("table not found: %s", parts[0])
+	}
+	switch {
+	case len(parts) == 1:
+		return &Entry{Name: parts[0], IsDir: true}, nil
+	case len(parts) == 2 && parts[1] == "keys":
+		return &Entry{Name: "keys", IsDir: true}, nil
+	case len(parts) == 2 && (parts[1] == "info.json" || parts[1] == "indexes.json" || parts[1] == TagsFile):
+		return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+	case len(parts) == 3 && parts[1] == "keys":
+		items, err := p.sample(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := items[parts[2]]; ok {
+			return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("path not found: %s", path)
+}
+
+// tableTags lists the tags of a table, which are looked up by its ARN
+func (p *DynamoDBProvider) tableTags(ctx context.Context, table string) (map[string]string, error) {
+	desc, err := p.describeTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	var token *string
+	for {
+		resp, err := p.client.ListTagsOfResource(ctx, &dynamodb.ListTagsOfResourceInput{
+			ResourceArn: desc.TableArn,
+			NextToken:   token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range resp.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		if resp.NextToken == nil {
+			return tags, nil
+		}
+		token = resp.NextToken
+	}
+}
+
+// Writable reports whether path is a table's tags.json, the one file sisu
+// can update
+func (p *DynamoDBProvider) Writable(path string) bool {
+	return isTagsFile(path)
+}
+
+// Write tags and untags a table so its tags match tags.json
+func (p *DynamoDBProvider) Write(ctx context.Context, path string, data []byte) error {
+	if !isTagsFile(path) {
+		return os.ErrPermission
+	}
+	desired, err := parseTags(data)
+	if err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+
+	table := strings.Split(path, "/")[0]
+	desc, err := p.describeTable(ctx, table)
+	if err != nil {
+		return err
+	}
+	current, err := p.tableTags(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	set, remove := diffTags(current, desired)
+	if len(remove) > 0 {
+		if _, err := p.client.UntagResource(ctx, &dynamodb.UntagResourceInput{
+			ResourceArn: desc.TableArn,
+			TagKeys:     remove,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(set) > 0 {
+		keys := make([]string, 0, len(set))
+		for k := range set {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		tags := make([]types.Tag, len(keys))
+		for i, k := range keys {
+			tags[i] = types.Tag{Key: aws.String(k), Value: aws.String(set[k])}
+		}
+		if _, err := p.client.TagResource(ctx, &dynamodb.TagResourceInput{
+			ResourceArn: desc.TableArn,
+			Tags:        tags,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
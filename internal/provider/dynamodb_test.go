@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestDynamoDBTables(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "dynamodb")
+	p := newDynamoDBProvider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "dynamodb/tables.json", entries)
+
+	for _, file := range []string{"info.json", "indexes.json", "tags.json"} {
+		data, err := p.Read(ctx, "orders/"+file)
+		if err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+		assertGolden(t, "dynamodb/"+file, data)
+	}
+}
+
+func TestDynamoDBSampledItems(t *testing.T) {
+	cfg, client := fixtureConfig(t, "dynamodb")
+	p := newDynamoDBProvider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "orders/keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Named partition key first, though the schema lists the sort key first
+	assertGoldenEntries(t, "dynamodb/keys.json", entries)
+
+	data, err := p.Read(ctx, "orders/keys/c-1001,2024-03-01T10:00:00Z.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "dynamodb/item.json", data)
+
+	if _, err := p.Read(ctx, "orders/keys/c-9999,2024-01-01T00:00:00Z.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("item outside the sample: %v, want ErrNotExist", err)
+	}
+	if _, err := p.Stat(ctx, "orders/keys/c-1002,2024-03-02T08:30:00Z.json"); err != nil {
+		t.Errorf("Stat sampled item: %v", err)
+	}
+
+	// The sample and description are fetched once for all of the above
+	if calls := client.Calls(); len(calls) != 2 {
+		t.Errorf("calls = %v, want DescribeTable and Scan", calls)
+	}
+}
+
+func TestDynamoDBItemNameEscapesSlashes(t *testing.T) {
+	schema := []types.KeySchemaElement{
+		{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+	}
+	item := map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "tenant/42"},
+		"sk": &types.AttributeValueMemberN{Value: "7"},
+	}
+	if name := dynamoDBItemName(schema, item); name != "tenant／42,7.json" {
+		t.Errorf("name = %q", name)
+	}
+}
+
+func TestDynamoDBTagsWrite(t *testing.T) {
+	cfg, client := fixtureConfig(t, "dynamodb")
+	p := newDynamoDBProvider(cfg)
+	ctx := context.Background()
+
+	for path, want := range map[string]bool{"orders/tags.json": true, "orders/info.json": false, "orders/keys/c-1001,2024-03-01T10:00:00Z.json": false} {
+		if got := p.Writable(path); got != want {
+			t.Errorf("Writable(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if err := p.Write(ctx, "orders/tags.json", []byte(`{"team": "payments", "retention": "90d"}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DescribeTable", "ListTagsOfResource", "UntagResource", "TagResource"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
@@ -4,91 +4,118 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/semonte/sisu/internal/cache"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/semonte/sisu/internal/paging"
 )
 
-// EC2Provider provides access to AWS EC2 instances
+// EC2Provider provides access to AWS EC2 instances and capacity planning data
 type EC2Provider struct {
 	ReadOnlyProvider
-	client *ec2.Client
-	cache  *cache.Cache
+	client     *ec2.Client
+	ssm        *ssm.Client
+	instances  *documents[types.Instance]
+	ssmManaged *documents[map[string]bool] // IDs of managed instances, under ""
+	profile    string                      // as passed to the AWS CLI, "" for the default
+	region     string
 }
 
 // NewEC2Provider creates a new EC2 provider
 func NewEC2Provider(profile, region string) (*EC2Provider, error) {
-	var opts []func(*config.LoadOptions) error
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
 		return nil, err
 	}
+	p := newEC2Provider(cfg)
+	p.profile = profile
+	return p, nil
+}
 
+func newEC2Provider(cfg aws.Config) *EC2Provider {
 	return &EC2Provider{
-		client: ec2.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+		client:     ec2.NewFromConfig(cfg),
+		ssm:        ssm.NewFromConfig(cfg),
+		instances:  newDocuments[types.Instance](),
+		ssmManaged: newDocuments[map[string]bool](),
+		region:     cfg.Region,
+	}
 }
 
 func (p *EC2Provider) Name() string {
 	return "ec2"
 }
 
-func (p *EC2Provider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
+// Invalidate forgets the memoized instances and managed instance IDs, so a refresh describes them anew
+func (p *EC2Provider) Invalidate(string) {
+	forgetDocuments(p.instances, p.ssmManaged)
+}
 
-	entries, err := p.readDirUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entries)
-	}
-	return entries, err
+func (p *EC2Provider) Close() {
+	closeDocuments(p.instances, p.ssmManaged)
 }
 
-func (p *EC2Provider) readDirUncached(ctx context.Context, path string) ([]Entry, error) {
-	// Root: list all instances
+func (p *EC2Provider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	// Root: the capacity directory, then all instances
 	if path == "" {
-		return p.listInstances(ctx)
+		entries, err := p.listInstances(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return append([]Entry{{Name: EC2CapacityDir, IsDir: true}}, entries...), nil
+	}
+
+	if path == EC2CapacityDir {
+		return ec2CapacityDirs(), nil
+	}
+	if c, file, ok := cutCapacity(path); ok && file == "" {
+		return p.listCapacity(ctx, c)
+	}
+	if path == AuditDir {
+		return p.auditFiles().entries(), nil
 	}
 
 	// Instance directory: show files
 	parts := strings.SplitN(path, "/", 2)
 	if len(parts) == 1 {
-		return []Entry{
-			{Name: "info.json", IsDir: false},
-			{Name: "security-groups.json", IsDir: false},
-			{Name: "tags.json", IsDir: false},
-		}, nil
+		entries := []Entry{{Name: "info.json", IsDir: false}}
+		// Without access to Systems Manager no sessions can be started,
+		// so the instance is treated as unmanaged
+		managed, err := p.managed(ctx, parts[0])
+		if err != nil && !IsAccessDenied(err) {
+			return nil, err
+		}
+		if managed {
+			entries = append(entries, Entry{Name: EC2PortForwardDir, IsDir: true})
+		}
+		return append(entries,
+			Entry{Name: "security-groups.json", IsDir: false},
+			Entry{Name: "tags.json", IsDir: false},
+		), nil
+	}
+	if parts[1] == EC2PortForwardDir {
+		return p.listPortForwards(ctx, parts[0])
 	}
 
 	return nil, fmt.Errorf("unknown path: %s", path)
 }
 
 func (p *EC2Provider) listInstances(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	var nextToken *string
-
-	for {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
 		resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-			NextToken: nextToken,
+			NextToken: optionalString(token),
 		})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		var entries []Entry
 		for _, reservation := range resp.Reservations {
 			for _, instance := range reservation.Instances {
 				entries = append(entries, Entry{
@@ -97,130 +124,142 @@ func (p *EC2Provider) listInstances(ctx context.Context) ([]Entry, error) {
 				})
 			}
 		}
-
-		if resp.NextToken == nil {
-			break
-		}
-		nextToken = resp.NextToken
+		return entries, aws.ToString(resp.NextToken), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, ec2ListHint, err)
 	}
-
 	return entries, nil
 }
 
-func (p *EC2Provider) Read(ctx context.Context, path string) ([]byte, error) {
-	cacheKey := "read:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]byte), nil
-	}
+const ec2ListHint = "aws ec2 describe-instances"
 
-	data, err := p.readUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, data)
+func (p *EC2Provider) Read(ctx context.Context, path string) ([]byte, error) {
+	if c, file, ok := cutCapacity(path); ok && strings.HasSuffix(file, ".json") {
+		return p.readCapacity(ctx, c, strings.TrimSuffix(file, ".json"))
 	}
-	return data, err
-}
-
-func (p *EC2Provider) readUncached(ctx context.Context, path string) ([]byte, error) {
 	parts := strings.Split(path, "/")
+	if len(parts) == 3 && parts[1] == EC2PortForwardDir {
+		return p.readPortForward(ctx, parts[0], parts[2])
+	}
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid path: %s", path)
 	}
+	if parts[0] == AuditDir {
+		return p.auditFiles().read(ctx, parts[1])
+	}
 
 	instanceID := parts[0]
 	file := parts[1]
 
-	switch file {
-	case "info.json":
-		return p.getInstanceInfo(ctx, instanceID)
-	case "security-groups.json":
-		return p.getSecurityGroups(ctx, instanceID)
-	case "tags.json":
-		return p.getTags(ctx, instanceID)
+	if ec2InstanceFiles[file] {
+		return p.readInstanceFile(ctx, instanceID, file)
 	}
 
 	return nil, fmt.Errorf("unknown file: %s", file)
 }
 
-func (p *EC2Provider) getInstanceInfo(ctx context.Context, instanceID string) ([]byte, error) {
-	resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	if len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
-		return nil, fmt.Errorf("instance not found: %s", instanceID)
+// Prefetch returns all of an instance's files from the single
+// DescribeInstances call any one of them needs
+func (p *EC2Provider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || !ec2InstanceFiles[parts[1]] {
+		return nil, nil
 	}
 
-	return json.MarshalIndent(resp.Reservations[0].Instances[0], "", "  ")
-}
-
-func (p *EC2Provider) getSecurityGroups(ctx context.Context, instanceID string) ([]byte, error) {
-	resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
-	})
+	instance, err := p.describeInstance(ctx, parts[0])
 	if err != nil {
 		return nil, err
 	}
-
-	if len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
-		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	files := make(map[string][]byte, len(ec2InstanceFiles))
+	for file := range ec2InstanceFiles {
+		data, err := renderInstanceFile(instance, file)
+		if err != nil {
+			return nil, err
+		}
+		files[parts[0]+"/"+file] = data
 	}
+	return files, nil
+}
 
-	instance := resp.Reservations[0].Instances[0]
-	return json.MarshalIndent(instance.SecurityGroups, "", "  ")
+// ec2InstanceFiles are the files derived from an instance's description
+var ec2InstanceFiles = map[string]bool{
+	"info.json":            true,
+	"security-groups.json": true,
+	"tags.json":            true,
 }
 
-func (p *EC2Provider) getTags(ctx context.Context, instanceID string) ([]byte, error) {
-	resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
+// describeInstance returns the instance's description, shared by its
+// directory stat and every file derived from it
+func (p *EC2Provider) describeInstance(ctx context.Context, instanceID string) (types.Instance, error) {
+	return p.instances.get(instanceID, func() (types.Instance, error) {
+		resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: []string{instanceID},
+		})
+		if err != nil {
+			return types.Instance{}, err
+		}
+
+		if len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
+			return types.Instance{}, fmt.Errorf("instance not found: %s", instanceID)
+		}
+		return resp.Reservations[0].Instances[0], nil
 	})
+}
+
+func (p *EC2Provider) readInstanceFile(ctx context.Context, instanceID, file string) ([]byte, error) {
+	instance, err := p.describeInstance(ctx, instanceID)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
-		return nil, fmt.Errorf("instance not found: %s", instanceID)
-	}
-
-	instance := resp.Reservations[0].Instances[0]
-
-	// Convert tags to a simple map for easier grepping
-	tags := make(map[string]string)
-	for _, tag := range instance.Tags {
-		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
-	}
-
-	return json.MarshalIndent(tags, "", "  ")
+	return renderInstanceFile(instance, file)
 }
 
-func (p *EC2Provider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
-	}
-
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
+// renderInstanceFile derives one of ec2InstanceFiles from the instance
+func renderInstanceFile(instance types.Instance, file string) ([]byte, error) {
+	switch file {
+	case "security-groups.json":
+		return json.MarshalIndent(instance.SecurityGroups, "", "  ")
+	case "tags.json":
+		// Convert tags to a simple map for easier grepping
+		tags := make(map[string]string)
+		for _, tag := range instance.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		return json.MarshalIndent(tags, "", "  ")
 	}
-	return entry, err
+	return json.MarshalIndent(instance, "", "  ")
 }
 
-func (p *EC2Provider) statUncached(ctx context.Context, path string) (*Entry, error) {
+func (p *EC2Provider) Stat(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return &Entry{Name: "ec2", IsDir: true}, nil
 	}
 
+	if entry, ok, err := p.auditFiles().stat(path); ok {
+		return entry, err
+	}
+
 	parts := strings.Split(path, "/")
 
+	if parts[0] == EC2CapacityDir {
+		if len(parts) == 1 {
+			return &Entry{Name: parts[0], IsDir: true}, nil
+		}
+		if _, ok := ec2Capacity[parts[1]]; ok {
+			switch {
+			case len(parts) == 2:
+				return &Entry{Name: parts[1], IsDir: true}, nil
+			case len(parts) == 3 && strings.HasSuffix(parts[2], ".json"):
+				return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+			}
+		}
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+
 	// Instance directory
 	if len(parts) == 1 {
-		resp, err := p.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-			InstanceIds: []string{parts[0]},
-		})
-		if err != nil || len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
+		if _, err := p.describeInstance(ctx, parts[0]); err != nil {
 			return nil, fmt.Errorf("instance not found: %s", parts[0])
 		}
 		return &Entry{Name: parts[0], IsDir: true}, nil
@@ -231,8 +270,88 @@ func (p *EC2Provider) statUncached(ctx context.Context, path string) (*Entry, er
 		switch parts[1] {
 		case "info.json", "security-groups.json", "tags.json":
 			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+		case EC2PortForwardDir:
+			if err := p.checkManaged(ctx, parts[0]); err != nil {
+				return nil, err
+			}
+			return &Entry{Name: parts[1], IsDir: true}, nil
 		}
 	}
+	if len(parts) == 3 && parts[1] == EC2PortForwardDir {
+		if _, ok := parsePort(parts[2]); !ok {
+			return nil, fmt.Errorf("not a port: %s: %w", parts[2], fs.ErrNotExist)
+		}
+		if err := p.checkManaged(ctx, parts[0]); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+	}
 
 	return nil, fmt.Errorf("path not found: %s", path)
 }
+
+// Writable reports whether path is an instance's tags.json, the one file
+// sisu can update
+func (p *EC2Provider) Writable(path string) bool {
+	return isEC2TagsFile(path)
+}
+
+// Write applies the tags written to an instance's tags.json: changed and
+// new keys are created, missing keys deleted
+func (p *EC2Provider) Write(ctx context.Context, path string, data []byte) error {
+	if !isEC2TagsFile(path) {
+		return fs.ErrPermission
+	}
+	desired, err := parseTags(data)
+	if err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+
+	instanceID := strings.Split(path, "/")[0]
+	instance, err := p.describeInstance(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]string, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		current[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	defer p.instances.forget(instanceID)
+
+	set, remove := diffTags(current, desired)
+	if len(remove) > 0 {
+		tags := make([]types.Tag, len(remove))
+		for i, k := range remove {
+			tags[i] = types.Tag{Key: aws.String(k)}
+		}
+		if _, err := p.client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+			Resources: []string{instanceID},
+			Tags:      tags,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(set) > 0 {
+		tags := make([]types.Tag, 0, len(set))
+		for k, v := range set {
+			tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		sort.Slice(tags, func(i, j int) bool { return aws.ToString(tags[i].Key) < aws.ToString(tags[j].Key) })
+		if _, err := p.client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{instanceID},
+			Tags:      tags,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEC2TagsFile reports whether an EC2 path is an instance's tags file
+func isEC2TagsFile(path string) bool {
+	if !isTagsFile(path) {
+		return false
+	}
+	dir := strings.Split(path, "/")[0]
+	return dir != EC2CapacityDir && dir != AuditDir
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// ec2Record is one spot request, reservation or capacity reservation
+type ec2Record struct {
+	id  string
+	doc any
+}
+
+// ec2CapacityKind describes a directory of capacity planning records, each
+// shown as <id>.json. describe returns the records with the given IDs, or
+// a page of up to MaxEntries of all of them when ids is nil.
+type ec2CapacityKind struct {
+	hint     string
+	describe func(ctx context.Context, client *ec2.Client, ids []string) ([]ec2Record, error)
+}
+
+// EC2CapacityDir is the directory at the root of ec2 holding the capacity
+// directories, e.g. ec2/_capacity/spot-requests/<id>.json, kept apart so
+// they can't be mistaken for instances
+const EC2CapacityDir = "_capacity"
+
+// ec2Capacity are the capacity directories in EC2CapacityDir
+var ec2Capacity = map[string]ec2CapacityKind{
+	"spot-requests": {
+		hint:     "aws ec2 describe-spot-instance-requests",
+		describe: describeSpotRequests,
+	},
+	"reserved-instances": {
+		hint:     "aws ec2 describe-reserved-instances",
+		describe: describeReservedInstances,
+	},
+	"capacity-reservations": {
+		hint:     "aws ec2 describe-capacity-reservations",
+		describe: describeCapacityReservations,
+	},
+}
+
+// cutCapacity returns the capacity directory a path below EC2CapacityDir
+// is in and the path's file within it, if any
+func cutCapacity(path string) (ec2CapacityKind, string, bool) {
+	rest, ok := strings.CutPrefix(path, EC2CapacityDir+"/")
+	if !ok {
+		return ec2CapacityKind{}, "", false
+	}
+	dir, file, _ := strings.Cut(rest, "/")
+	c, ok := ec2Capacity[dir]
+	return c, file, ok
+}
+
+// ec2CapacityDirs returns the entries of the capacity directories
+func ec2CapacityDirs() []Entry {
+	entries := make([]Entry, 0, len(ec2Capacity))
+	for name := range ec2Capacity {
+		entries = append(entries, Entry{Name: name, IsDir: true})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+func (p *EC2Provider) listCapacity(ctx context.Context, c ec2CapacityKind) ([]Entry, error) {
+	records, err := c.describe(ctx, p.client, nil)
+	entries := make([]Entry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, Entry{Name: r.id + ".json", IsDir: false, Size: 4096})
+	}
+	if err != nil {
+		return nil, partialListing(entries, c.hint, err)
+	}
+	return entries, nil
+}
+
+func (p *EC2Provider) readCapacity(ctx context.Context, c ec2CapacityKind, id string) ([]byte, error) {
+	records, err := c.describe(ctx, p.client, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("not found: %s", id)
+	}
+	return json.MarshalIndent(records[0].doc, "", "  ")
+}
+
+func describeSpotRequests(ctx context.Context, client *ec2.Client, ids []string) ([]ec2Record, error) {
+	records, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]ec2Record, string, error) {
+		page, err := client.DescribeSpotInstanceRequests(ctx, &ec2.DescribeSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: ids,
+			NextToken:              optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		records := make([]ec2Record, 0, len(page.SpotInstanceRequests))
+		for _, r := range page.SpotInstanceRequests {
+			records = append(records, ec2Record{id: aws.ToString(r.SpotInstanceRequestId), doc: r})
+		}
+		return records, aws.ToString(page.NextToken), nil
+	})
+	return records, err
+}
+
+// describeReservedInstances lists reservations; the API isn't paginated
+func describeReservedInstances(ctx context.Context, client *ec2.Client, ids []string) ([]ec2Record, error) {
+	resp, err := client.DescribeReservedInstances(ctx, &ec2.DescribeReservedInstancesInput{
+		ReservedInstancesIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ec2Record, 0, len(resp.ReservedInstances))
+	for _, r := range resp.ReservedInstances {
+		records = append(records, ec2Record{id: aws.ToString(r.ReservedInstancesId), doc: r})
+	}
+	return records, nil
+}
+
+func describeCapacityReservations(ctx context.Context, client *ec2.Client, ids []string) ([]ec2Record, error) {
+	records, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]ec2Record, string, error) {
+		page, err := client.DescribeCapacityReservations(ctx, &ec2.DescribeCapacityReservationsInput{
+			CapacityReservationIds: ids,
+			NextToken:              optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		records := make([]ec2Record, 0, len(page.CapacityReservations))
+		for _, r := range page.CapacityReservations {
+			records = append(records, ec2Record{id: aws.ToString(r.CapacityReservationId), doc: r})
+		}
+		return records, aws.ToString(page.NextToken), nil
+	})
+	return records, err
+}
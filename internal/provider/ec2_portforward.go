@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// EC2PortForwardDir holds, in each instance directory, a file per port
+// whose content is the command forwarding a local port to it through a
+// Session Manager session, e.g. i-0abc/port-forward/5432. Only instances
+// managed by Systems Manager have one. Any port can be read; the common
+// ones are listed.
+const EC2PortForwardDir = "port-forward"
+
+// ec2ForwardedPorts are the ports listed in EC2PortForwardDir: SSH, HTTP(S),
+// MySQL, RDP, PostgreSQL, Redis and the usual alternative HTTP port
+var ec2ForwardedPorts = []int{22, 80, 443, 3306, 3389, 5432, 6379, 8080}
+
+// PortForwardLocalPort returns the local port a remote port is forwarded
+// to: the same port, or 10000 above it for privileged ports, which only
+// root could listen on
+func PortForwardLocalPort(port int) int {
+	if port < 1024 {
+		return port + 10000
+	}
+	return port
+}
+
+// PortForwardArgs returns the AWS CLI arguments starting a Session Manager
+// session that forwards a local port to port on the instance
+func PortForwardArgs(profile, region, instanceID string, port int) []string {
+	args := []string{
+		"ssm", "start-session",
+		"--target", instanceID,
+		"--document-name", "AWS-StartPortForwardingSession",
+		"--parameters", fmt.Sprintf("portNumber=%d,localPortNumber=%d", port, PortForwardLocalPort(port)),
+		"--region", region,
+	}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	return args
+}
+
+// parsePort returns the port a file in EC2PortForwardDir is named after
+func parsePort(name string) (int, bool) {
+	port, err := strconv.Atoi(name)
+	if err != nil || port < 1 || port > 65535 || strconv.Itoa(port) != name {
+		return 0, false
+	}
+	return port, true
+}
+
+// managedInstances returns the IDs of the region's instances managed by
+// Systems Manager, i.e. whose agent is registered, so sessions can be
+// started to them
+func (p *EC2Provider) managedInstances(ctx context.Context) (map[string]bool, error) {
+	return p.ssmManaged.get("", func() (map[string]bool, error) {
+		managed := make(map[string]bool)
+		pages := ssm.NewDescribeInstanceInformationPaginator(p.ssm, &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{
+				{Key: aws.String("ResourceType"), Values: []string{"EC2Instance"}},
+			},
+		})
+		for pages.HasMorePages() {
+			resp, err := pages.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range resp.InstanceInformationList {
+				managed[aws.ToString(info.InstanceId)] = true
+			}
+		}
+		return managed, nil
+	})
+}
+
+// managed reports whether Systems Manager manages the instance
+func (p *EC2Provider) managed(ctx context.Context, instanceID string) (bool, error) {
+	managed, err := p.managedInstances(ctx)
+	if err != nil {
+		return false, err
+	}
+	return managed[instanceID], nil
+}
+
+// checkManaged returns an error wrapping fs.ErrNotExist unless the instance
+// is managed, as its EC2PortForwardDir then doesn't exist
+func (p *EC2Provider) checkManaged(ctx context.Context, instanceID string) error {
+	ok, err := p.managed(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not managed by Systems Manager, so has no %s: %w", instanceID, EC2PortForwardDir, fs.ErrNotExist)
+	}
+	return nil
+}
+
+func (p *EC2Provider) listPortForwards(ctx context.Context, instanceID string) ([]Entry, error) {
+	if err := p.checkManaged(ctx, instanceID); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(ec2ForwardedPorts))
+	for i, port := range ec2ForwardedPorts {
+		entries[i] = Entry{Name: strconv.Itoa(port), Size: 4096}
+	}
+	return entries, nil
+}
+
+// readPortForward returns the command forwarding to a port, as a shell
+// command line
+func (p *EC2Provider) readPortForward(ctx context.Context, instanceID, name string) ([]byte, error) {
+	port, ok := parsePort(name)
+	if !ok {
+		return nil, fmt.Errorf("not a port: %s: %w", name, fs.ErrNotExist)
+	}
+	if err := p.checkManaged(ctx, instanceID); err != nil {
+		return nil, err
+	}
+	args := PortForwardArgs(p.profile, p.region, instanceID, port)
+	return fmt.Appendf(nil, "# forwards localhost:%d to port %d of %s\naws %s\n",
+		PortForwardLocalPort(port), port, instanceID, strings.Join(args, " ")), nil
+}
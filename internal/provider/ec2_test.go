@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEC2Documents(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ec2")
+	p := newEC2Provider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "ec2/instances.json", entries)
+
+	for _, file := range []string{"info.json", "security-groups.json", "tags.json"} {
+		data, err := p.Read(ctx, "i-0abc123def4567890/"+file)
+		if err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+		assertGolden(t, "ec2/"+file, data)
+	}
+}
+
+func TestEC2PrefetchSharesDescribe(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ec2")
+	p := Cached(Chain(newEC2Provider(cfg), Logging()), DefaultCachePolicy)
+	ctx := context.Background()
+
+	for _, file := range []string{"info.json", "security-groups.json", "tags.json"} {
+		if _, err := p.Read(ctx, "i-0abc123def4567890/"+file); err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+	}
+	if calls := client.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single DescribeInstances", calls)
+	}
+}
+
+func TestEC2FilesShareInstanceDocument(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ec2")
+	p := newEC2Provider(cfg)
+	ctx := context.Background()
+
+	if _, err := p.Stat(ctx, "i-0abc123def4567890"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"info.json", "tags.json"} {
+		if _, err := p.Read(ctx, "i-0abc123def4567890/"+file); err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+	}
+	if calls := client.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single DescribeInstances", calls)
+	}
+}
+
+func TestEC2CapacityDirectories(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ec2")
+	p := newEC2Provider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, EC2CapacityDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(entries); !reflect.DeepEqual(names, []string{"capacity-reservations", "reserved-instances", "spot-requests"}) {
+		t.Errorf("ReadDir %s = %v", EC2CapacityDir, names)
+	}
+
+	for dir, id := range map[string]string{
+		"spot-requests":         "sir-08b93456",
+		"reserved-instances":    "af9f760e-6f91-4559-85f7-4980eexample",
+		"capacity-reservations": "cr-0a1b2c3d4e5f67890",
+	} {
+		path := EC2CapacityDir + "/" + dir
+		entries, err := p.ReadDir(ctx, path)
+		if err != nil {
+			t.Fatalf("ReadDir %s: %v", path, err)
+		}
+		if len(entries) != 1 || entries[0].Name != id+".json" {
+			t.Fatalf("ReadDir %s = %v, want %s.json", path, entries, id)
+		}
+		if entry, err := p.Stat(ctx, path+"/"+id+".json"); err != nil || entry.IsDir {
+			t.Fatalf("Stat %s/%s.json = %v, %v", path, id, entry, err)
+		}
+		data, err := p.Read(ctx, path+"/"+id+".json")
+		if err != nil {
+			t.Fatalf("Read %s: %v", path, err)
+		}
+		assertGolden(t, "ec2/"+dir+".json", data)
+	}
+}
+
+func TestEC2TagsWrite(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ec2")
+	p := newEC2Provider(cfg)
+	ctx := context.Background()
+
+	for path, want := range map[string]bool{
+		"i-0abc123def4567890/tags.json":     true,
+		"i-0abc123def4567890/info.json":     false,
+		"_capacity/spot-requests/tags.json": false,
+		"i-0abc123def4567890":               false,
+	} {
+		if got := p.Writable(path); got != want {
+			t.Errorf("Writable(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	// Name is removed, Environment changed and team added
+	if err := p.Write(ctx, "i-0abc123def4567890/tags.json", []byte(`{"Environment": "staging", "team": "web"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(ctx, "i-0abc123def4567890/tags.json"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DescribeInstances", "DeleteTags", "CreateTags", "DescribeInstances"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestEC2PortForward(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ec2")
+	p := newEC2Provider(cfg)
+	p.profile = "prod"
+	ctx := context.Background()
+
+	// Only managed instances have a port-forward directory, found with one
+	// DescribeInstanceInformation for the region
+	for id, want := range map[string]bool{"i-0abc123def4567890": true, "i-0ff8a91507f77f867": false} {
+		entries, err := p.ReadDir(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := slices.Contains(entryNames(entries), EC2PortForwardDir); got != want {
+			t.Errorf("%s lists %s: %v, want %v", id, EC2PortForwardDir, got, want)
+		}
+	}
+	n := 0
+	for _, call := range client.Calls() {
+		if call == "DescribeInstanceInformation" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("%d DescribeInstanceInformation calls, want 1", n)
+	}
+
+	entries, err := p.ReadDir(ctx, "i-0abc123def4567890/port-forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(entries); len(names) == 0 || names[0] != "22" {
+		t.Errorf("ports = %v", names)
+	}
+	data, err := p.Read(ctx, "i-0abc123def4567890/port-forward/5432")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# forwards localhost:5432 to port 5432 of i-0abc123def4567890\n" +
+		"aws ssm start-session --target i-0abc123def4567890 --document-name AWS-StartPortForwardingSession" +
+		" --parameters portNumber=5432,localPortNumber=5432 --region us-east-1 --profile prod\n"
+	if string(data) != want {
+		t.Errorf("Read = %q, want %q", data, want)
+	}
+	// Privileged ports are forwarded from above 10000
+	if args := PortForwardArgs("", "us-east-1", "i-1", 22); !strings.Contains(strings.Join(args, " "), "localPortNumber=10022") {
+		t.Errorf("args = %v", args)
+	}
+
+	for _, path := range []string{"i-0fff000000000000f/port-forward", "i-0abc123def4567890/port-forward/ssh", "i-0abc123def4567890/port-forward/070000"} {
+		if _, err := p.Stat(ctx, path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%s) = %v, want ErrNotExist", path, err)
+		}
+	}
+}
+
+func TestEC2RefreshForgetsDocuments(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ec2")
+	p := Cached(Chain(newEC2Provider(cfg), Recover(), Logging()), DefaultCachePolicy)
+	ctx := context.Background()
+
+	if _, err := p.Read(ctx, "i-0abc123def4567890/info.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Refresh(ctx, "i-0abc123def4567890"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(ctx, "i-0abc123def4567890/info.json"); err != nil {
+		t.Fatal(err)
+	}
+	var describes int
+	for _, call := range client.Calls() {
+		if call == "DescribeInstances" {
+			describes++
+		}
+	}
+	if describes != 2 {
+		t.Errorf("calls = %v, want the instance described again after the refresh", client.Calls())
+	}
+}
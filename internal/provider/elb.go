@@ -0,0 +1,494 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ELBProvider provides the region's Application, Network and Gateway Load
+// Balancers as directories named by load balancer:
+//
+//	<lb>/info.json                              the load balancer: type, scheme, DNS name, state
+//	<lb>/listeners/<protocol>-<port>.json       a listener with its rules
+//	<lb>/target-groups/<group>/info.json        a target group and its health check
+//	<lb>/target-groups/<group>/health.json      the live health of its targets
+//
+// health.json comes from its own DescribeTargetHealth call rather than with
+// the group, so it's as fresh as the read cache allows; sisu refresh on
+// the group's directory fetches it again.
+type ELBProvider struct {
+	ReadOnlyProvider
+	client    *restJSONClient
+	balancers *documents[cappedList[map[string]elbLoadBalancer]] // by name, under ""
+	listeners *documents[map[string]elbListener]                 // by file name, under the load balancer ARN
+	groups    *documents[map[string]elbTargetGroup]              // by name, under the load balancer ARN
+}
+
+// Directories and files of a load balancer directory
+const (
+	elbInfoFile        = "info.json"
+	elbListenersDir    = "listeners"
+	elbTargetGroupsDir = "target-groups"
+	elbHealthFile      = "health.json"
+)
+
+// NewELBProvider creates a new Elastic Load Balancing (v2) provider
+func NewELBProvider(profile, region string) (*ELBProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newELBProvider(cfg), nil
+}
+
+func newELBProvider(cfg aws.Config) *ELBProvider {
+	return &ELBProvider{
+		client:    newQueryClient(cfg, "ElasticLoadBalancingV2", "elasticloadbalancing", "2015-12-01"),
+		balancers: newDocuments[cappedList[map[string]elbLoadBalancer]](),
+		listeners: newDocuments[map[string]elbListener](),
+		groups:    newDocuments[map[string]elbTargetGroup](),
+	}
+}
+
+func (p *ELBProvider) Name() string {
+	return "elb"
+}
+
+// Invalidate forgets the memoized load balancers, listeners and target groups, so a refresh describes them anew
+func (p *ELBProvider) Invalidate(string) {
+	forgetDocuments(p.balancers, p.listeners, p.groups)
+}
+
+func (p *ELBProvider) Close() {
+	closeDocuments(p.balancers, p.listeners, p.groups)
+}
+
+// The shapes below are the XML of the ELBv2 API, written as JSON with the
+// API's field names. Lists are <member> elements.
+
+type elbLoadBalancer struct {
+	LoadBalancerName      string
+	LoadBalancerArn       string
+	Type                  string
+	Scheme                string
+	DNSName               string
+	CanonicalHostedZoneId string
+	VpcId                 string
+	IpAddressType         string
+	CreatedTime           time.Time
+	State                 struct {
+		Code   string
+		Reason string `json:",omitempty"`
+	}
+	AvailabilityZones []struct {
+		ZoneName string
+		SubnetId string
+	} `xml:"AvailabilityZones>member"`
+	SecurityGroups []string `xml:"SecurityGroups>member"`
+}
+
+type elbAction struct {
+	Type           string
+	Order          int    `json:",omitempty"`
+	TargetGroupArn string `json:",omitempty"`
+	ForwardConfig  *struct {
+		TargetGroups []struct {
+			TargetGroupArn string
+			Weight         int
+		} `xml:"TargetGroups>member"`
+	} `json:",omitempty"`
+	RedirectConfig *struct {
+		Protocol   string
+		Host       string
+		Port       string
+		Path       string
+		Query      string
+		StatusCode string
+	} `json:",omitempty"`
+	FixedResponseConfig *struct {
+		StatusCode  string
+		ContentType string `json:",omitempty"`
+		MessageBody string `json:",omitempty"`
+	} `json:",omitempty"`
+}
+
+type elbRule struct {
+	RuleArn    string
+	Priority   string
+	IsDefault  bool
+	Conditions []struct {
+		Field  string
+		Values []string `xml:"Values>member"`
+	} `xml:"Conditions>member" json:",omitempty"` // none for the default rule
+	Actions []elbAction `xml:"Actions>member"`
+}
+
+type elbListener struct {
+	ListenerArn    string
+	Protocol       string
+	Port           int
+	SslPolicy      string      `json:",omitempty"`
+	Certificates   []string    `xml:"Certificates>member>CertificateArn" json:",omitempty"`
+	AlpnPolicy     []string    `xml:"AlpnPolicy>member" json:",omitempty"`
+	DefaultActions []elbAction `xml:"DefaultActions>member"`
+	Rules          []elbRule   `xml:"-"` // from DescribeRules; none for NLB and GWLB listeners
+}
+
+type elbTargetGroup struct {
+	TargetGroupName            string
+	TargetGroupArn             string
+	TargetType                 string
+	Protocol                   string `json:",omitempty"` // none for Lambda targets
+	Port                       int    `json:",omitempty"`
+	VpcId                      string `json:",omitempty"`
+	HealthCheckEnabled         bool
+	HealthCheckProtocol        string `json:",omitempty"`
+	HealthCheckPort            string `json:",omitempty"`
+	HealthCheckPath            string `json:",omitempty"`
+	HealthCheckIntervalSeconds int
+	HealthCheckTimeoutSeconds  int
+	HealthyThresholdCount      int
+	UnhealthyThresholdCount    int
+	Matcher                    *struct {
+		HttpCode string `json:",omitempty"`
+		GrpcCode string `json:",omitempty"`
+	} `json:",omitempty"`
+	LoadBalancerArns []string `xml:"LoadBalancerArns>member"`
+}
+
+// elbTargetHealth is a target of health.json
+type elbTargetHealth struct {
+	Id               string `xml:"Target>Id"`
+	Port             int    `xml:"Target>Port" json:",omitempty"`
+	AvailabilityZone string `xml:"Target>AvailabilityZone" json:",omitempty"`
+	State            string `xml:"TargetHealth>State"`
+	Reason           string `xml:"TargetHealth>Reason" json:",omitempty"`
+	Description      string `xml:"TargetHealth>Description" json:",omitempty"`
+}
+
+// elbHealth is health.json of a target group
+type elbHealth struct {
+	States  map[string]int    `json:"states"` // targets by state, e.g. {"healthy": 2, "unhealthy": 1}
+	Targets []elbTargetHealth `json:"targets"`
+}
+
+// listBalancers returns the region's load balancers by name, up to
+// MaxEntries of them
+func (p *ELBProvider) listBalancers(ctx context.Context) (cappedList[map[string]elbLoadBalancer], error) {
+	return p.balancers.get("", func() (cappedList[map[string]elbLoadBalancer], error) {
+		balancers := make(map[string]elbLoadBalancer)
+		params := url.Values{}
+		for {
+			var resp struct {
+				LoadBalancers []elbLoadBalancer `xml:"DescribeLoadBalancersResult>LoadBalancers>member"`
+				NextMarker    string            `xml:"DescribeLoadBalancersResult>NextMarker"`
+			}
+			if err := p.client.query(ctx, "DescribeLoadBalancers", params, &resp); err != nil {
+				return cappedList[map[string]elbLoadBalancer]{}, err
+			}
+			for _, lb := range resp.LoadBalancers {
+				balancers[lb.LoadBalancerName] = lb
+			}
+			if resp.NextMarker == "" || len(balancers) >= MaxEntries {
+				return cappedList[map[string]elbLoadBalancer]{items: balancers, more: resp.NextMarker != ""}, nil
+			}
+			params = url.Values{"Marker": {resp.NextMarker}}
+		}
+	})
+}
+
+// balancer returns the load balancer named name, described on its own if
+// the listing was cut off before it, or an error wrapping os.ErrNotExist
+func (p *ELBProvider) balancer(ctx context.Context, name string) (elbLoadBalancer, error) {
+	balancers, err := p.listBalancers(ctx)
+	if err != nil {
+		return elbLoadBalancer{}, err
+	}
+	if lb, ok := balancers.items[name]; ok {
+		return lb, nil
+	}
+	if balancers.more {
+		var resp struct {
+			LoadBalancers []elbLoadBalancer `xml:"DescribeLoadBalancersResult>LoadBalancers>member"`
+		}
+		err := p.client.query(ctx, "DescribeLoadBalancers", url.Values{"Names.member.1": {name}}, &resp)
+		if err != nil && !isAPIError(err, "LoadBalancerNotFound") {
+			return elbLoadBalancer{}, err
+		}
+		if err == nil && len(resp.LoadBalancers) == 1 {
+			return resp.LoadBalancers[0], nil
+		}
+	}
+	return elbLoadBalancer{}, fmt.Errorf("load balancer not found: %s: %w", name, os.ErrNotExist)
+}
+
+// elbListenerFile is the file name of a listener, unique as a load
+// balancer has one listener per port
+func elbListenerFile(l elbListener) string {
+	return fmt.Sprintf("%s-%d.json", strings.ToLower(l.Protocol), l.Port)
+}
+
+// listListeners returns a load balancer's listeners with their rules, by
+// file name
+func (p *ELBProvider) listListeners(ctx context.Context, lb elbLoadBalancer) (map[string]elbListener, error) {
+	return p.listeners.get(lb.LoadBalancerArn, func() (map[string]elbListener, error) {
+		listeners := make(map[string]elbListener)
+		params := url.Values{"LoadBalancerArn": {lb.LoadBalancerArn}}
+		for {
+			var resp struct {
+				Listeners  []elbListener `xml:"DescribeListenersResult>Listeners>member"`
+				NextMarker string        `xml:"DescribeListenersResult>NextMarker"`
+			}
+			if err := p.client.query(ctx, "DescribeListeners", params, &resp); err != nil {
+				return nil, err
+			}
+			for _, l := range resp.Listeners {
+				listeners[elbListenerFile(l)] = l
+			}
+			if resp.NextMarker == "" {
+				break
+			}
+			params = url.Values{"LoadBalancerArn": {lb.LoadBalancerArn}, "Marker": {resp.NextMarker}}
+		}
+		if lb.Type != "application" {
+			return listeners, nil
+		}
+		for file, l := range listeners {
+			rules, err := p.listRules(ctx, l.ListenerArn)
+			if err != nil {
+				return nil, err
+			}
+			l.Rules = rules
+			listeners[file] = l
+		}
+		return listeners, nil
+	})
+}
+
+// listRules returns the rules of an Application Load Balancer listener,
+// the default rule last
+func (p *ELBProvider) listRules(ctx context.Context, listenerArn string) ([]elbRule, error) {
+	rules := []elbRule{}
+	params := url.Values{"ListenerArn": {listenerArn}}
+	for {
+		var resp struct {
+			Rules      []elbRule `xml:"DescribeRulesResult>Rules>member"`
+			NextMarker string    `xml:"DescribeRulesResult>NextMarker"`
+		}
+		if err := p.client.query(ctx, "DescribeRules", params, &resp); err != nil {
+			return nil, err
+		}
+		rules = append(rules, resp.Rules...)
+		if resp.NextMarker == "" {
+			return rules, nil
+		}
+		params = url.Values{"ListenerArn": {listenerArn}, "Marker": {resp.NextMarker}}
+	}
+}
+
+// listGroups returns the target groups of a load balancer by name
+func (p *ELBProvider) listGroups(ctx context.Context, lb elbLoadBalancer) (map[string]elbTargetGroup, error) {
+	return p.groups.get(lb.LoadBalancerArn, func() (map[string]elbTargetGroup, error) {
+		groups := make(map[string]elbTargetGroup)
+		params := url.Values{"LoadBalancerArn": {lb.LoadBalancerArn}}
+		for {
+			var resp struct {
+				TargetGroups []elbTargetGroup `xml:"DescribeTargetGroupsResult>TargetGroups>member"`
+				NextMarker   string           `xml:"DescribeTargetGroupsResult>NextMarker"`
+			}
+			if err := p.client.query(ctx, "DescribeTargetGroups", params, &resp); err != nil {
+				return nil, err
+			}
+			for _, g := range resp.TargetGroups {
+				groups[g.TargetGroupName] = g
+			}
+			if resp.NextMarker == "" {
+				return groups, nil
+			}
+			params = url.Values{"LoadBalancerArn": {lb.LoadBalancerArn}, "Marker": {resp.NextMarker}}
+		}
+	})
+}
+
+// group returns a target group of the load balancer named lbName, or an
+// error wrapping os.ErrNotExist
+func (p *ELBProvider) group(ctx context.Context, lbName, name string) (elbTargetGroup, error) {
+	lb, err := p.balancer(ctx, lbName)
+	if err != nil {
+		return elbTargetGroup{}, err
+	}
+	groups, err := p.listGroups(ctx, lb)
+	if err != nil {
+		return elbTargetGroup{}, err
+	}
+	g, ok := groups[name]
+	if !ok {
+		return g, fmt.Errorf("target group not found: %s: %w", name, os.ErrNotExist)
+	}
+	return g, nil
+}
+
+// health returns the live health of a target group's targets
+func (p *ELBProvider) health(ctx context.Context, g elbTargetGroup) (*elbHealth, error) {
+	var resp struct {
+		Targets []elbTargetHealth `xml:"DescribeTargetHealthResult>TargetHealthDescriptions>member"`
+	}
+	if err := p.client.query(ctx, "DescribeTargetHealth", url.Values{"TargetGroupArn": {g.TargetGroupArn}}, &resp); err != nil {
+		return nil, err
+	}
+	h := &elbHealth{States: make(map[string]int), Targets: resp.Targets}
+	if h.Targets == nil {
+		h.Targets = []elbTargetHealth{}
+	}
+	for _, t := range h.Targets {
+		h.States[t.State]++
+	}
+	return h, nil
+}
+
+func (p *ELBProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		balancers, err := p.listBalancers(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws elbv2 describe-load-balancers", err)
+		}
+		entries := make([]Entry, 0, len(balancers.items))
+		for name, lb := range balancers.items {
+			entries = append(entries, Entry{Name: name, IsDir: true, ModTime: lb.CreatedTime})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, balancers.more, "aws elbv2 describe-load-balancers"), nil
+	}
+
+	parts := strings.Split(path, "/")
+	lb, err := p.balancer(ctx, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	switch {
+	case len(parts) == 1:
+		return []Entry{
+			{Name: elbInfoFile, IsDir: false, Size: 4096},
+			{Name: elbListenersDir, IsDir: true},
+			{Name: elbTargetGroupsDir, IsDir: true},
+		}, nil
+	case len(parts) == 2 && parts[1] == elbListenersDir:
+		listeners, err := p.listListeners(ctx, lb)
+		if err != nil {
+			return nil, err
+		}
+		for file := range listeners {
+			entries = append(entries, Entry{Name: file, IsDir: false, Size: 4096})
+		}
+	case len(parts) == 2 && parts[1] == elbTargetGroupsDir:
+		groups, err := p.listGroups(ctx, lb)
+		if err != nil {
+			return nil, err
+		}
+		for name := range groups {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+	case len(parts) == 3 && parts[1] == elbTargetGroupsDir:
+		if _, err := p.group(ctx, parts[0], parts[2]); err != nil {
+			return nil, err
+		}
+		return []Entry{
+			{Name: elbHealthFile, IsDir: false, Size: 4096},
+			{Name: elbInfoFile, IsDir: false, Size: 4096},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func (p *ELBProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 2 && parts[1] == elbInfoFile:
+		lb, err := p.balancer(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(lb, "", "  ")
+	case len(parts) == 3 && parts[1] == elbListenersDir:
+		lb, err := p.balancer(ctx, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		listeners, err := p.listListeners(ctx, lb)
+		if err != nil {
+			return nil, err
+		}
+		l, ok := listeners[parts[2]]
+		if !ok {
+			return nil, fmt.Errorf("listener not found: %s: %w", parts[2], os.ErrNotExist)
+		}
+		return json.MarshalIndent(l, "", "  ")
+	case len(parts) == 4 && parts[1] == elbTargetGroupsDir:
+		g, err := p.group(ctx, parts[0], parts[2])
+		if err != nil {
+			return nil, err
+		}
+		switch parts[3] {
+		case elbInfoFile:
+			return json.MarshalIndent(g, "", "  ")
+		case elbHealthFile:
+			h, err := p.health(ctx, g)
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(h, "", "  ")
+		}
+	}
+	return nil, fmt.Errorf("unknown file: %s", path)
+}
+
+func (p *ELBProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "elb", IsDir: true}, nil
+	}
+	parts := strings.Split(path, "/")
+	lb, err := p.balancer(ctx, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(parts) == 1:
+		return &Entry{Name: parts[0], IsDir: true, ModTime: lb.CreatedTime}, nil
+	case len(parts) == 2 && parts[1] == elbInfoFile:
+		return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+	case len(parts) == 2 && (parts[1] == elbListenersDir || parts[1] == elbTargetGroupsDir):
+		return &Entry{Name: parts[1], IsDir: true}, nil
+	case len(parts) == 3 && parts[1] == elbListenersDir:
+		listeners, err := p.listListeners(ctx, lb)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := listeners[parts[2]]; !ok {
+			return nil, fmt.Errorf("listener not found: %s: %w", parts[2], os.ErrNotExist)
+		}
+		return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+	case len(parts) == 3 && parts[1] == elbTargetGroupsDir:
+		if _, err := p.group(ctx, parts[0], parts[2]); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: parts[2], IsDir: true}, nil
+	case len(parts) == 4 && parts[1] == elbTargetGroupsDir && (parts[3] == elbInfoFile || parts[3] == elbHealthFile):
+		if _, err := p.group(ctx, parts[0], parts[2]); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: parts[3], IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestELB(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "elb")
+	p := newELBProvider(cfg)
+	ctx := context.Background()
+
+	for dir, want := range map[string][]string{
+		"web":               {"info.json", "listeners", "target-groups"},
+		"web/listeners":     {"http-80.json", "https-443.json"},
+		"web/target-groups": {"web-blue", "web-green"},
+	} {
+		entries, err := p.ReadDir(ctx, dir)
+		if err != nil {
+			t.Fatalf("ReadDir(%q): %v", dir, err)
+		}
+		if names := entryNames(entries); !reflect.DeepEqual(names, want) {
+			t.Errorf("ReadDir(%q) = %v, want %v", dir, names, want)
+		}
+	}
+
+	for path, golden := range map[string]string{
+		"web/info.json":                          "elb/info.json",
+		"web/listeners/https-443.json":           "elb/listener.json",
+		"web/target-groups/web-blue/health.json": "elb/health.json",
+	} {
+		data, err := p.Read(ctx, path)
+		if err != nil {
+			t.Fatalf("Read(%s): %v", path, err)
+		}
+		assertGolden(t, golden, data)
+	}
+}
@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// EMRProvider provides the region's active EMR clusters as directories
+// named by cluster ID, as names needn't be unique:
+//
+//	<id>/info.json             the cluster: name, status, release, applications, roles
+//	<id>/instance-groups.json  its instance groups: market, instance type, running count
+//	<id>/steps.json            its steps, newest first, with their state
+//	<id>/configurations.json   the configuration classifications it was created with
+type EMRProvider struct {
+	ReadOnlyProvider
+	client   *restJSONClient
+	clusters *documents[cappedList[map[string]string]] // cluster names by ID, under ""
+	describe *documents[map[string]any]                // clusters by ID
+	lists    *documents[[]map[string]any]              // instance groups or steps, under "<id>/<file>"
+}
+
+// Files of an EMR cluster directory
+const (
+	emrInfoFile           = "info.json"
+	emrInstanceGroupsFile = "instance-groups.json"
+	emrStepsFile          = "steps.json"
+	emrConfigurationsFile = "configurations.json"
+)
+
+// emrActiveStates are the cluster states listed; terminated clusters stay
+// listable by the API for two months
+var emrActiveStates = []string{"STARTING", "BOOTSTRAPPING", "RUNNING", "WAITING", "TERMINATING"}
+
+// emrLists describes how the list files of a cluster are fetched
+var emrLists = map[string]struct{ op, key string }{
+	emrInstanceGroupsFile: {"ListInstanceGroups", "InstanceGroups"},
+	emrStepsFile:          {"ListSteps", "Steps"},
+}
+
+// NewEMRProvider creates a new EMR provider
+func NewEMRProvider(profile, region string) (*EMRProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newEMRProvider(cfg), nil
+}
+
+func newEMRProvider(cfg aws.Config) *EMRProvider {
+	client := newJSONRPCClient(cfg, "EMR", "elasticmapreduce", "ElasticMapReduce")
+	client.jsonVersion = "1.1"
+	return &EMRProvider{
+		client:   client,
+		clusters: newDocuments[cappedList[map[string]string]](),
+		describe: newDocuments[map[string]any](),
+		lists:    newDocuments[[]map[string]any](),
+	}
+}
+
+func (p *EMRProvider) Name() string {
+	return "emr"
+}
+
+// Invalidate forgets the memoized clusters, instance groups and steps, so a refresh describes them anew
+func (p *EMRProvider) Invalidate(string) {
+	forgetDocuments(p.clusters, p.describe, p.lists)
+}
+
+func (p *EMRProvider) Close() {
+	closeDocuments(p.clusters, p.describe, p.lists)
+}
+
+// listClusters returns the names of the region's active clusters by ID,
+// up to MaxEntries of them
+func (p *EMRProvider) listClusters(ctx context.Context) (cappedList[map[string]string], error) {
+	return p.clusters.get("", func() (cappedList[map[string]string], error) {
+		names := make(map[string]string)
+		in := map[string]any{"ClusterStates": emrActiveStates}
+		for {
+			var resp struct {
+				Clusters []struct {
+					Id   string
+					Name string
+				}
+				Marker string
+			}
+			if err := p.client.call(ctx, "ListClusters", in, &resp); err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			for _, c := range resp.Clusters {
+				names[c.Id] = c.Name
+			}
+			if resp.Marker == "" || len(names) >= MaxEntries {
+				return cappedList[map[string]string]{items: names, more: resp.Marker != ""}, nil
+			}
+			in = map[string]any{"ClusterStates": emrActiveStates, "Marker": resp.Marker}
+		}
+	})
+}
+
+// checkCluster returns an error wrapping os.ErrNotExist unless the cluster
+// is listed, or described past a truncated listing
+func (p *EMRProvider) checkCluster(ctx context.Context, id string) error {
+	names, err := p.listClusters(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := names.items[id]; ok {
+		return nil
+	}
+	if names.more {
+		_, err := p.describeCluster(ctx, id)
+		return err
+	}
+	return fmt.Errorf("cluster not found: %s: %w", id, os.ErrNotExist)
+}
+
+// cluster returns the description of a cluster
+func (p *EMRProvider) cluster(ctx context.Context, id string) (map[string]any, error) {
+	if err := p.checkCluster(ctx, id); err != nil {
+		return nil, err
+	}
+	return p.describeCluster(ctx, id)
+}
+
+// describeCluster returns the description of a cluster, listed or not
+func (p *EMRProvider) describeCluster(ctx context.Context, id string) (map[string]any, error) {
+	return p.describe.get(id, func() (map[string]any, error) {
+		var resp struct {
+			Cluster map[string]any
+		}
+		if err := p.client.call(ctx, "DescribeCluster", map[string]any{"ClusterId": id}, &resp); err != nil {
+			if isAPIError(err, "InvalidRequestException") {
+				return nil, fmt.Errorf("cluster not found: %s: %w", id, os.ErrNotExist)
+			}
+			return nil, err
+		}
+		return emrTimes(resp.Cluster), nil
+	})
+}
+
+// list returns a cluster's instance groups or steps. ListSteps returns
+// the newest first, so a cluster with more than MaxEntries steps shows
+// the newest of them.
+func (p *EMRProvider) list(ctx context.Context, id, file string) ([]map[string]any, error) {
+	l := emrLists[file]
+	return p.lists.get(id+"/"+file, func() ([]map[string]any, error) {
+		items := []map[string]any{}
+		in := map[string]any{"ClusterId": id}
+		for {
+			var resp map[string]json.RawMessage
+			if err := p.client.call(ctx, l.op, in, &resp); err != nil {
+				return nil, err
+			}
+			var page []map[string]any
+			if raw, ok := resp[l.key]; ok {
+				if err := json.Unmarshal(raw, &page); err != nil {
+					return nil, err
+				}
+			}
+			for _, item := range page {
+				items = append(items, emrTimes(item))
+			}
+			var marker string
+			if raw, ok := resp["Marker"]; ok {
+				json.Unmarshal(raw, &marker)
+			}
+			if marker == "" || len(items) >= MaxEntries {
+				return items, nil
+			}
+			in = map[string]any{"ClusterId": id, "Marker": marker}
+		}
+	})
+}
+
+// emrTimes turns the epoch seconds of a resource's Status.Timeline, e.g.
+// CreationDateTime, into RFC 3339 times
+func emrTimes(r map[string]any) map[string]any {
+	status, _ := r["Status"].(map[string]any)
+	timeline, _ := status["Timeline"].(map[string]any)
+	for k, v := range timeline {
+		if secs, ok := v.(float64); ok {
+			timeline[k] = epochTime(secs)
+		}
+	}
+	return r
+}
+
+func (p *EMRProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		names, err := p.listClusters(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws emr list-clusters --active", err)
+		}
+		entries := make([]Entry, 0, len(names.items))
+		for id := range names.items {
+			entries = append(entries, Entry{Name: id, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, names.more, "aws emr list-clusters --active"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if err := p.checkCluster(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: emrConfigurationsFile, IsDir: false, Size: 4096},
+		{Name: emrInfoFile, IsDir: false, Size: 4096},
+		{Name: emrInstanceGroupsFile, IsDir: false, Size: 4096},
+		{Name: emrStepsFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+func (p *EMRProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	id, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	switch file {
+	case emrInfoFile, emrConfigurationsFile:
+		cluster, err := p.cluster(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if file == emrConfigurationsFile {
+			configurations, _ := cluster["Configurations"].([]any)
+			if configurations == nil {
+				configurations = []any{}
+			}
+			return json.MarshalIndent(configurations, "", "  ")
+		}
+		return json.MarshalIndent(cluster, "", "  ")
+	case emrInstanceGroupsFile, emrStepsFile:
+		if err := p.checkCluster(ctx, id); err != nil {
+			return nil, err
+		}
+		items, err := p.list(ctx, id, file)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(items, "", "  ")
+	}
+	return nil, fmt.Errorf("unknown file: %s", file)
+}
+
+func (p *EMRProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "emr", IsDir: true}, nil
+	}
+	id, file, _ := strings.Cut(path, "/")
+	if err := p.checkCluster(ctx, id); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: id, IsDir: true}, nil
+	case emrInfoFile, emrInstanceGroupsFile, emrStepsFile, emrConfigurationsFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
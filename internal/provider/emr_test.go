@@ -0,0 +1,20 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEMRClusters(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "emr")
+	p := newEMRProvider(cfg)
+	ctx := context.Background()
+
+	for _, file := range []string{"info.json", "configurations.json", "steps.json"} {
+		data, err := p.Read(ctx, "j-2AXXXXXXGAPLF/"+file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertGolden(t, "emr/"+file, data)
+	}
+}
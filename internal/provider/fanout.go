@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// FanOutLimit is the default number of concurrent calls for listings and
+// documents assembled from several AWS requests
+const FanOutLimit = 8
+
+// FanOut runs fn for every i in [0, n) with at most limit calls in flight.
+// The context passed to fn is cancelled once a call fails, and the first
+// error is returned after every started call has finished. Results are
+// usually written to a slice at index i, which keeps them in order. A
+// panic in fn is raised again in the caller's goroutine once every call has
+// finished, where Recover can catch it.
+func FanOut(ctx context.Context, limit, n int, fn func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		once      sync.Once
+		panicOnce sync.Once
+		firstErr  error
+		panicked  *goroutinePanic
+		sem       = make(chan struct{}, limit)
+	)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				if v := recover(); v != nil {
+					panicOnce.Do(func() {
+						panicked = &goroutinePanic{value: v, stack: debug.Stack()}
+						cancel()
+					})
+				}
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if panicked != nil {
+		panic(*panicked)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOutBoundsConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	results := make([]int, 20)
+
+	err := FanOut(context.Background(), 3, len(results), func(ctx context.Context, i int) error {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak.Load() > 3 {
+		t.Errorf("%d calls in flight, limit is 3", peak.Load())
+	}
+	for i, r := range results {
+		if r != i*i {
+			t.Fatalf("results[%d] = %d", i, r)
+		}
+	}
+}
+
+func TestFanOutCancelsOnError(t *testing.T) {
+	boom := errors.New("throttled")
+	var started atomic.Int32
+
+	err := FanOut(context.Background(), 1, 10, func(ctx context.Context, i int) error {
+		started.Add(1)
+		if i == 2 {
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("FanOut = %v, want %v", err, boom)
+	}
+	if started.Load() > 4 {
+		t.Errorf("%d calls started after the failure was known", started.Load())
+	}
+}
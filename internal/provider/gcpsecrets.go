@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// SecretManagerProvider provides read access to the latest version of each
+// GCP Secret Manager secret in a project
+type SecretManagerProvider struct {
+	ReadOnlyProvider
+	rest    *restClient
+	project string
+	baseURL string
+}
+
+// NewSecretManagerProvider creates a Secret Manager provider for a project
+func NewSecretManagerProvider(project string) *SecretManagerProvider {
+	return newSecretManagerProvider(project, newRESTClient(http.DefaultClient, GCloudToken()), "https://secretmanager.googleapis.com")
+}
+
+func newSecretManagerProvider(project string, rest *restClient, baseURL string) *SecretManagerProvider {
+	return &SecretManagerProvider{rest: rest, project: project, baseURL: baseURL}
+}
+
+func (p *SecretManagerProvider) Name() string {
+	return "secrets"
+}
+
+// gcpSecrets is a page of secrets.list
+type gcpSecrets struct {
+	Secrets []struct {
+		Name       string    `json:"name"` // projects/<number>/secrets/<id>
+		CreateTime time.Time `json:"createTime"`
+	} `json:"secrets"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (p *SecretManagerProvider) secretsURL() string {
+	return p.baseURL + "/v1/projects/" + url.PathEscape(p.project) + "/secrets"
+}
+
+func (p *SecretManagerProvider) listHint() string {
+	return "gcloud secrets list --project " + p.project
+}
+
+func (p *SecretManagerProvider) ReadDir(ctx context.Context, dir string) ([]Entry, error) {
+	if dir != "" {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, pageToken string) ([]Entry, string, error) {
+		q := url.Values{"pageSize": {strconv.Itoa(min(MaxEntries, 25000))}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		var page gcpSecrets
+		if err := p.rest.getJSON(ctx, p.secretsURL()+"?"+q.Encode(), &page); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(page.Secrets))
+		for _, s := range page.Secrets {
+			entries = append(entries, Entry{Name: path.Base(s.Name), ModTime: s.CreateTime})
+		}
+		return entries, page.NextPageToken, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, p.listHint(), err)
+	}
+	return entries, nil
+}
+
+func (p *SecretManagerProvider) Read(ctx context.Context, name string) ([]byte, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid path: %s", name)
+	}
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := p.rest.getJSON(ctx, p.secretsURL()+"/"+url.PathEscape(name)+"/versions/latest:access", &resp); err != nil {
+		return nil, err
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+
+	// Add newline for better cat output
+	if len(value) > 0 && value[len(value)-1] != '\n' {
+		value = append(value, '\n')
+	}
+	return value, nil
+}
+
+// IsSecret reports whether name is a secret, which every file is
+func (p *SecretManagerProvider) IsSecret(ctx context.Context, name string) (bool, error) {
+	return name != "" && !strings.Contains(name, "/"), nil
+}
+
+func (p *SecretManagerProvider) Stat(ctx context.Context, name string) (*Entry, error) {
+	if name == "" {
+		return &Entry{Name: "secrets", IsDir: true}, nil
+	}
+
+	var secret struct {
+		CreateTime time.Time `json:"createTime"`
+	}
+	if err := p.rest.getJSON(ctx, p.secretsURL()+"/"+url.PathEscape(name), &secret); err != nil {
+		return nil, err
+	}
+	// The size is only known from the value itself
+	data, err := p.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Name: name, Size: int64(len(data)), ModTime: secret.CreateTime}, nil
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecretManagerRead(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/projects/my-project/secrets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secrets": [{"name": "projects/123/secrets/db-password", "createTime": "2024-01-01T00:00:00Z"}]}`))
+	})
+	mux.HandleFunc("/v1/projects/my-project/secrets/db-password/versions/latest:access", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"payload": {"data": "aHVudGVyMg=="}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	p := newSecretManagerProvider("my-project", newRESTClient(srv.Client(), staticToken("token")), srv.URL)
+
+	entries, err := p.ReadDir(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "db-password" {
+		t.Errorf("ReadDir = %+v", entries)
+	}
+
+	data, err := p.Read(context.Background(), "db-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hunter2\n" {
+		t.Errorf("Read = %q", data)
+	}
+}
@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// GCSProvider provides read access to Google Cloud Storage buckets and
+// objects of a project through the JSON API
+type GCSProvider struct {
+	ReadOnlyProvider
+	rest    *restClient
+	project string
+	baseURL string
+}
+
+// NewGCSProvider creates a GCS provider for a project
+func NewGCSProvider(project string) *GCSProvider {
+	return newGCSProvider(project, newRESTClient(http.DefaultClient, GCloudToken()), "https://storage.googleapis.com")
+}
+
+func newGCSProvider(project string, rest *restClient, baseURL string) *GCSProvider {
+	return &GCSProvider{rest: rest, project: project, baseURL: baseURL}
+}
+
+func (p *GCSProvider) Name() string {
+	return "gcs"
+}
+
+// gcsBuckets is a page of buckets.list
+type gcsBuckets struct {
+	Items []struct {
+		Name        string    `json:"name"`
+		TimeCreated time.Time `json:"timeCreated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// gcsObject is the metadata of an object
+type gcsObject struct {
+	Name    string    `json:"name"`
+	Size    string    `json:"size"` // int64 encoded as a string
+	Updated time.Time `json:"updated"`
+}
+
+// gcsObjects is a page of objects.list
+type gcsObjects struct {
+	Prefixes      []string    `json:"prefixes"`
+	Items         []gcsObject `json:"items"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+func (p *GCSProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		return p.listBuckets(ctx)
+	}
+
+	bucket, prefix, _ := strings.Cut(path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return p.listObjects(ctx, bucket, prefix)
+}
+
+func (p *GCSProvider) listBuckets(ctx context.Context) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, pageToken string) ([]Entry, string, error) {
+		q := url.Values{"project": {p.project}, "maxResults": {strconv.Itoa(gcsPageSize())}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		var page gcsBuckets
+		if err := p.rest.getJSON(ctx, p.baseURL+"/storage/v1/b?"+q.Encode(), &page); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(page.Items))
+		for _, b := range page.Items {
+			entries = append(entries, Entry{Name: b.Name, IsDir: true, ModTime: b.TimeCreated})
+		}
+		return entries, page.NextPageToken, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, "gcloud storage ls --project "+p.project, err)
+	}
+	return entries, nil
+}
+
+func (p *GCSProvider) listObjects(ctx context.Context, bucket, prefix string) ([]Entry, error) {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, pageToken string) ([]Entry, string, error) {
+		q := url.Values{"prefix": {prefix}, "delimiter": {"/"}, "maxResults": {strconv.Itoa(gcsPageSize())}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		var page gcsObjects
+		if err := p.rest.getJSON(ctx, p.bucketURL(bucket)+"/o?"+q.Encode(), &page); err != nil {
+			return nil, "", err
+		}
+
+		var entries []Entry
+		for _, cp := range page.Prefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(cp, prefix), "/")
+			if name != "" {
+				entries = append(entries, Entry{Name: name, IsDir: true})
+			}
+		}
+		for _, obj := range page.Items {
+			name := strings.TrimPrefix(obj.Name, prefix)
+			if name == "" {
+				continue
+			}
+			size, _ := strconv.ParseInt(obj.Size, 10, 64)
+			entries = append(entries, Entry{Name: name, Size: size, ModTime: obj.Updated})
+		}
+		return entries, page.NextPageToken, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, gcsListHint(bucket, prefix), err)
+	}
+	return entries, nil
+}
+
+// gcsPageSize keeps pages no larger than needed to fill a listing
+func gcsPageSize() int {
+	return min(MaxEntries, 1000)
+}
+
+// gcsListHint returns the CLI command listing a bucket prefix in full
+func gcsListHint(bucket, prefix string) string {
+	return "gcloud storage ls gs://" + bucket + "/" + prefix
+}
+
+func (p *GCSProvider) bucketURL(bucket string) string {
+	return p.baseURL + "/storage/v1/b/" + url.PathEscape(bucket)
+}
+
+func (p *GCSProvider) objectURL(bucket, key string) string {
+	return p.bucketURL(bucket) + "/o/" + url.PathEscape(key)
+}
+
+func (p *GCSProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	bucket, key, ok := strings.Cut(path, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	return p.rest.get(ctx, p.objectURL(bucket, key)+"?alt=media")
+}
+
+// ReadRange fetches part of an object with a ranged download
+func (p *GCSProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	bucket, key, ok := strings.Cut(path, "/")
+	if !ok || length <= 0 {
+		data, err := p.Read(ctx, path)
+		return sliceRange(data, off, length), err
+	}
+	return p.rest.getRange(ctx, p.objectURL(bucket, key)+"?alt=media", off, length)
+}
+
+func (p *GCSProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	bucket, key, ok := strings.Cut(path, "/")
+	if !ok {
+		if err := p.rest.getJSON(ctx, p.bucketURL(bucket), &struct{}{}); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: bucket, IsDir: true}, nil
+	}
+
+	// A "directory" is a prefix with objects under it
+	q := url.Values{"prefix": {key + "/"}, "maxResults": {"1"}}
+	var page gcsObjects
+	if err := p.rest.getJSON(ctx, p.bucketURL(bucket)+"/o?"+q.Encode(), &page); err == nil && len(page.Items) > 0 {
+		return &Entry{Name: key, IsDir: true}, nil
+	}
+
+	var obj gcsObject
+	if err := p.rest.getJSON(ctx, p.objectURL(bucket, key), &obj); err != nil {
+		return nil, err
+	}
+	size, _ := strconv.ParseInt(obj.Size, 10, 64)
+	return &Entry{Name: key, Size: size, ModTime: obj.Updated}, nil
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGCSProvider(t *testing.T) *GCSProvider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/storage/v1/b/my-bucket/o", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("prefix") != "logs/" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"prefixes": ["logs/2024/"], "items": [{"name": "logs/a.log", "size": "12", "updated": "2024-05-01T10:00:00Z"}], "nextPageToken": "p2"}`))
+			return
+		}
+		w.Write([]byte(`{"items": [{"name": "logs/b.log", "size": "7", "updated": "2024-05-02T10:00:00Z"}]}`))
+	})
+	mux.HandleFunc("/storage/v1/b/my-bucket/o/logs%2Fa.log", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("alt") != "media" {
+			w.Write([]byte(`{"name": "logs/a.log", "size": "12", "updated": "2024-05-01T10:00:00Z"}`))
+			return
+		}
+		http.ServeContent(w, r, "a.log", time.Time{}, strings.NewReader("hello world\n"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return newGCSProvider("my-project", newRESTClient(srv.Client(), staticToken("token")), srv.URL)
+}
+
+func TestGCSReadDir(t *testing.T) {
+	p := newTestGCSProvider(t)
+
+	entries, err := p.ReadDir(context.Background(), "my-bucket/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "gcs/logs.json", entries)
+}
+
+func TestGCSReadAndStat(t *testing.T) {
+	p := newTestGCSProvider(t)
+	ctx := context.Background()
+
+	data, err := p.Read(ctx, "my-bucket/logs/a.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world\n" {
+		t.Errorf("Read = %q", data)
+	}
+
+	part, err := ReadRange(ctx, p, "my-bucket/logs/a.log", 6, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(part) != "world\n" {
+		t.Errorf("ReadRange = %q", part)
+	}
+	if part, err := ReadRange(ctx, p, "my-bucket/logs/a.log", 50, 10); err != nil || len(part) != 0 {
+		t.Errorf("ReadRange past the end = %q, %v", part, err)
+	}
+
+	entry, err := p.Stat(ctx, "my-bucket/logs/a.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.IsDir || entry.Size != 12 {
+		t.Errorf("Stat = %+v", entry)
+	}
+
+	if _, err := p.Stat(ctx, "my-bucket/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a missing object = %v, want ErrNotExist", err)
+	}
+}
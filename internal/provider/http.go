@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/semonte/sisu/internal/config"
+)
+
+// HTTPProvider mounts a JSON REST API described in the config file. Each
+// collection is a directory with one <name>.json file per listed item.
+type HTTPProvider struct {
+	ReadOnlyProvider
+	endpoint config.Endpoint
+	client   *http.Client
+}
+
+// NewHTTPProvider creates a provider for a configured endpoint
+func NewHTTPProvider(endpoint config.Endpoint) *HTTPProvider {
+	return newHTTPProvider(endpoint, http.DefaultClient)
+}
+
+func newHTTPProvider(endpoint config.Endpoint, client *http.Client) *HTTPProvider {
+	return &HTTPProvider{endpoint: endpoint, client: client}
+}
+
+func (p *HTTPProvider) Name() string {
+	return p.endpoint.Name
+}
+
+func (p *HTTPProvider) collection(name string) (config.Collection, bool) {
+	for _, c := range p.endpoint.Collections {
+		if c.Path == name {
+			return c, true
+		}
+	}
+	return config.Collection{}, false
+}
+
+func (p *HTTPProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		entries := make([]Entry, len(p.endpoint.Collections))
+		for i, c := range p.endpoint.Collections {
+			entries[i] = Entry{Name: c.Path, IsDir: true}
+		}
+		return entries, nil
+	}
+
+	c, ok := p.collection(path)
+	if !ok {
+		return nil, fmt.Errorf("unknown collection: %s", path)
+	}
+	items, err := p.listItems(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, Entry{Name: item.name + ".json", Size: int64(len(item.data))})
+		if len(entries) >= MaxEntries {
+			break
+		}
+	}
+	return capEntries(entries, len(items) > MaxEntries, p.listHint(c)), nil
+}
+
+func (p *HTTPProvider) listHint(c config.Collection) string {
+	return "curl " + p.url(c.List)
+}
+
+// httpItem is a single element of a collection listing
+type httpItem struct {
+	name string
+	data []byte // the element as listed, indented
+}
+
+// listItems fetches a collection and names each element by NameField
+func (p *HTTPProvider) listItems(ctx context.Context, c config.Collection) ([]httpItem, error) {
+	body, err := p.get(ctx, c.List)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := json.RawMessage(body)
+	if c.ItemsField != "" {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(body, &wrapper); err != nil {
+			return nil, fmt.Errorf("%s: listing is not an object: %w", c.List, err)
+		}
+		raw = wrapper[c.ItemsField]
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(raw, &elements); err != nil {
+		return nil, fmt.Errorf("%s: listing is not an array: %w", c.List, err)
+	}
+
+	nameField := c.NameField
+	if nameField == "" {
+		nameField = "id"
+	}
+
+	items := make([]httpItem, 0, len(elements))
+	for _, el := range elements {
+		name, ok := itemName(el, nameField)
+		if !ok {
+			continue
+		}
+		data, err := indentJSON(el)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, httpItem{name: name, data: data})
+	}
+	return items, nil
+}
+
+// itemName returns a plain string element itself, or the named field of an object
+func itemName(el json.RawMessage, field string) (string, bool) {
+	var s string
+	if json.Unmarshal(el, &s) == nil {
+		return s, s != ""
+	}
+	var obj map[string]any
+	if json.Unmarshal(el, &obj) != nil {
+		return "", false
+	}
+	switch v := obj[field].(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func indentJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func (p *HTTPProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	dir, file, ok := strings.Cut(path, "/")
+	c, found := p.collection(dir)
+	if !ok || !found {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if file == MoreResultsFile {
+		return []byte(moreResultsMessage(p.listHint(c))), nil
+	}
+	name := strings.TrimSuffix(file, ".json")
+
+	if c.Item != "" {
+		body, err := p.get(ctx, strings.ReplaceAll(c.Item, "{name}", url.PathEscape(name)))
+		if err != nil {
+			return nil, err
+		}
+		if data, err := indentJSON(body); err == nil {
+			return data, nil
+		}
+		return body, nil
+	}
+
+	items, err := p.listItems(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.name == name {
+			return item.data, nil
+		}
+	}
+	return nil, fmt.Errorf("item not found: %s", path)
+}
+
+func (p *HTTPProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: p.endpoint.Name, IsDir: true}, nil
+	}
+
+	dir, file, ok := strings.Cut(path, "/")
+	c, found := p.collection(dir)
+	if !found {
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	if !ok {
+		return &Entry{Name: dir, IsDir: true}, nil
+	}
+	if file == MoreResultsFile {
+		entry := moreResultsEntry(p.listHint(c))
+		return &entry, nil
+	}
+
+	// Items fetched individually have to be read for their size; otherwise
+	// sizes come from the listing, so ls -l costs one request per directory
+	if c.Item != "" {
+		data, err := p.Read(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return &Entry{Name: file, Size: int64(len(data))}, nil
+	}
+
+	name := strings.TrimSuffix(file, ".json")
+	items, err := p.listItems(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.name == name {
+			return &Entry{Name: file, Size: int64(len(item.data))}, nil
+		}
+	}
+	return nil, fmt.Errorf("item not found: %s", path)
+}
+
+// url joins a path to the endpoint's base URL
+func (p *HTTPProvider) url(path string) string {
+	return strings.TrimSuffix(p.endpoint.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// get fetches a path relative to the base URL with the configured headers
+func (p *HTTPProvider) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range p.endpoint.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Path, resp.Status)
+	}
+	return body, nil
+}
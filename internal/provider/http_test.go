@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/semonte/sisu/internal/config"
+)
+
+// inventoryServer serves a small JSON inventory API
+func inventoryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/hosts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data": [{"hostname": "web-1", "ip": "10.0.0.1"}, {"hostname": "web-2", "ip": "10.0.0.2"}, {"ip": "unnamed"}]}`))
+	})
+	mux.HandleFunc("/v1/hosts/web-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hostname":"web-1","ip":"10.0.0.1","rack":"a3"}`))
+	})
+	mux.HandleFunc("/v1/teams", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["platform", "data"]`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newInventoryProvider(t *testing.T, item string) *HTTPProvider {
+	srv := inventoryServer(t)
+	t.Setenv("INVENTORY_TOKEN", "secret")
+	return newHTTPProvider(config.Endpoint{
+		Name:    "inventory",
+		BaseURL: srv.URL + "/",
+		Headers: map[string]string{"Authorization": "Bearer ${INVENTORY_TOKEN}"},
+		Collections: []config.Collection{
+			{Path: "hosts", List: "/v1/hosts", ItemsField: "data", NameField: "hostname", Item: item},
+			{Path: "teams", List: "v1/teams"},
+		},
+	}, srv.Client())
+}
+
+func TestHTTPReadDir(t *testing.T) {
+	p := newInventoryProvider(t, "")
+
+	entries, err := p.ReadDir(context.Background(), "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "http/hosts.json", entries)
+
+	entries, err = p.ReadDir(context.Background(), "teams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "platform.json" {
+		t.Errorf("teams = %+v", entries)
+	}
+}
+
+func TestHTTPReadFromListing(t *testing.T) {
+	p := newInventoryProvider(t, "")
+
+	data, err := p.Read(context.Background(), "hosts/web-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "http/web-2.json", data)
+
+	if _, err := p.Read(context.Background(), "hosts/web-9.json"); err == nil {
+		t.Error("Read of a missing item succeeded")
+	}
+}
+
+func TestHTTPReadItem(t *testing.T) {
+	p := newInventoryProvider(t, "/v1/hosts/{name}")
+
+	data, err := p.Read(context.Background(), "hosts/web-1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "http/web-1.json", data)
+
+	entry, err := p.Stat(context.Background(), "hosts/web-1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != int64(len(data)) {
+		t.Errorf("Stat size = %d, want %d", entry.Size, len(data))
+	}
+}
+
+func TestHTTPMissingAuth(t *testing.T) {
+	p := newInventoryProvider(t, "")
+	t.Setenv("INVENTORY_TOKEN", "")
+
+	if _, err := p.ReadDir(context.Background(), "hosts"); err == nil {
+		t.Error("ReadDir without a token succeeded")
+	}
+}
@@ -1,45 +1,63 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"net/url"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/semonte/sisu/internal/cache"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/semonte/sisu/internal/paging"
 )
 
 // IAMProvider provides access to AWS IAM resources
 type IAMProvider struct {
 	ReadOnlyProvider
 	client *iam.Client
-	cache  *cache.Cache
+	sts    *sts.Client
+
+	mu        sync.Mutex
+	policies  map[string]iamPolicyRef // customer policies by name
+	arnPrefix string                  // e.g. "arn:aws:iam::123456789012:policy/"
+	jobs      map[string]*string      // last-accessed report jobs in progress, by user or role path
+}
+
+// iamPolicyRef locates the default version of a customer managed policy
+type iamPolicyRef struct {
+	arn            string
+	defaultVersion string
+	seen           time.Time
 }
 
+// iamPolicyRefTTL bounds how long a policy's default version is trusted
+// before it is looked up again, since it changes when a new version is set
+const iamPolicyRefTTL = 5 * time.Minute
+
 // NewIAMProvider creates a new IAM provider
 func NewIAMProvider(profile, region string) (*IAMProvider, error) {
-	var opts []func(*config.LoadOptions) error
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
 		return nil, err
 	}
+	return newIAMProvider(cfg), nil
+}
 
+func newIAMProvider(cfg aws.Config) *IAMProvider {
 	return &IAMProvider{
-		client: iam.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+		client:   iam.NewFromConfig(cfg),
+		sts:      sts.NewFromConfig(cfg),
+		policies: make(map[string]iamPolicyRef),
+		jobs:     make(map[string]*string),
+	}
 }
 
 func (p *IAMProvider) Name() string {
@@ -47,19 +65,6 @@ func (p *IAMProvider) Name() string {
 }
 
 func (p *IAMProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
-
-	entries, err := p.readDirUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entries)
-	}
-	return entries, err
-}
-
-func (p *IAMProvider) readDirUncached(ctx context.Context, path string) ([]Entry, error) {
 	// Root: list categories
 	if path == "" {
 		return []Entry{
@@ -102,50 +107,55 @@ func (p *IAMProvider) readDirUncached(ctx context.Context, path string) ([]Entry
 }
 
 func (p *IAMProvider) listUsers(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	paginator := iam.NewListUsersPaginator(p.client, &iam.ListUsersInput{})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		page, err := p.client.ListUsers(ctx, &iam.ListUsersInput{Marker: optionalString(marker)})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		entries := make([]Entry, 0, len(page.Users))
 		for _, user := range page.Users {
-			entries = append(entries, Entry{
-				Name:  aws.ToString(user.UserName),
-				IsDir: true,
-			})
+			entries = append(entries, Entry{Name: aws.ToString(user.UserName), IsDir: true})
 		}
+		return entries, iamNextMarker(page.IsTruncated, page.Marker), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, iamListHints["users"], err)
 	}
-
 	return entries, nil
 }
 
+// iamNextMarker returns the marker of the page after a truncated one
+func iamNextMarker(truncated bool, marker *string) string {
+	if !truncated {
+		return ""
+	}
+	return aws.ToString(marker)
+}
+
 func (p *IAMProvider) listUserFiles(ctx context.Context) ([]Entry, error) {
 	return []Entry{
 		{Name: "info.json", IsDir: false},
 		{Name: "policies.json", IsDir: false},
 		{Name: "groups.json", IsDir: false},
+		{Name: "last-accessed.json", IsDir: false},
 	}, nil
 }
 
 func (p *IAMProvider) listRoles(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	paginator := iam.NewListRolesPaginator(p.client, &iam.ListRolesInput{})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		page, err := p.client.ListRoles(ctx, &iam.ListRolesInput{Marker: optionalString(marker)})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		entries := make([]Entry, 0, len(page.Roles))
 		for _, role := range page.Roles {
-			entries = append(entries, Entry{
-				Name:  aws.ToString(role.RoleName),
-				IsDir: true,
-			})
+			entries = append(entries, Entry{Name: aws.ToString(role.RoleName), IsDir: true})
 		}
+		return entries, iamNextMarker(page.IsTruncated, page.Marker), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, iamListHints["roles"], err)
 	}
-
 	return entries, nil
 }
 
@@ -153,49 +163,46 @@ func (p *IAMProvider) listRoleFiles(ctx context.Context) ([]Entry, error) {
 	return []Entry{
 		{Name: "info.json", IsDir: false},
 		{Name: "policies.json", IsDir: false},
+		{Name: "trust-policy.json", IsDir: false},
+		{Name: "last-accessed.json", IsDir: false},
 	}, nil
 }
 
 func (p *IAMProvider) listPolicies(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	// Only list customer managed policies (not AWS managed)
-	paginator := iam.NewListPoliciesPaginator(p.client, &iam.ListPoliciesInput{
-		Scope: "Local",
-	})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		// Only list customer managed policies (not AWS managed)
+		page, err := p.client.ListPolicies(ctx, &iam.ListPoliciesInput{Scope: "Local", Marker: optionalString(marker)})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		entries := make([]Entry, 0, len(page.Policies))
 		for _, policy := range page.Policies {
-			entries = append(entries, Entry{
-				Name:  aws.ToString(policy.PolicyName) + ".json",
-				IsDir: false,
-			})
+			entries = append(entries, Entry{Name: aws.ToString(policy.PolicyName) + ".json"})
+			p.rememberPolicy(aws.ToString(policy.PolicyName), aws.ToString(policy.Arn), aws.ToString(policy.DefaultVersionId))
 		}
+		return entries, iamNextMarker(page.IsTruncated, page.Marker), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, iamListHints["policies"], err)
 	}
-
 	return entries, nil
 }
 
 func (p *IAMProvider) listGroups(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	paginator := iam.NewListGroupsPaginator(p.client, &iam.ListGroupsInput{})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
+		page, err := p.client.ListGroups(ctx, &iam.ListGroupsInput{Marker: optionalString(marker)})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		entries := make([]Entry, 0, len(page.Groups))
 		for _, group := range page.Groups {
-			entries = append(entries, Entry{
-				Name:  aws.ToString(group.GroupName),
-				IsDir: true,
-			})
+			entries = append(entries, Entry{Name: aws.ToString(group.GroupName), IsDir: true})
 		}
+		return entries, iamNextMarker(page.IsTruncated, page.Marker), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, iamListHints["groups"], err)
 	}
-
 	return entries, nil
 }
 
@@ -207,20 +214,15 @@ func (p *IAMProvider) listGroupFiles(ctx context.Context) ([]Entry, error) {
 	}, nil
 }
 
-func (p *IAMProvider) Read(ctx context.Context, path string) ([]byte, error) {
-	cacheKey := "read:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]byte), nil
-	}
-
-	data, err := p.readUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, data)
-	}
-	return data, err
+// iamListHints maps each category to the CLI command listing it in full
+var iamListHints = map[string]string{
+	"users":    "aws iam list-users",
+	"roles":    "aws iam list-roles",
+	"policies": "aws iam list-policies --scope Local",
+	"groups":   "aws iam list-groups",
 }
 
-func (p *IAMProvider) readUncached(ctx context.Context, path string) ([]byte, error) {
+func (p *IAMProvider) Read(ctx context.Context, path string) ([]byte, error) {
 	parts := strings.Split(path, "/")
 
 	// policies/<name>.json (policies stay flat)
@@ -247,6 +249,14 @@ func (p *IAMProvider) readUncached(ctx context.Context, path string) ([]byte, er
 			return p.getUserPolicies(ctx, name)
 		case "groups.json":
 			return p.getUserGroups(ctx, name)
+		case "last-accessed.json":
+			return p.getLastAccessed(ctx, category+"/"+name, func(ctx context.Context) (*string, error) {
+				resp, err := p.client.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(name)})
+				if err != nil {
+					return nil, err
+				}
+				return resp.User.Arn, nil
+			})
 		}
 	case "roles":
 		switch file {
@@ -254,6 +264,16 @@ func (p *IAMProvider) readUncached(ctx context.Context, path string) ([]byte, er
 			return p.getRoleInfo(ctx, name)
 		case "policies.json":
 			return p.getRolePolicies(ctx, name)
+		case "trust-policy.json":
+			return p.getRoleTrustPolicy(ctx, name)
+		case "last-accessed.json":
+			return p.getLastAccessed(ctx, category+"/"+name, func(ctx context.Context) (*string, error) {
+				resp, err := p.client.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+				if err != nil {
+					return nil, err
+				}
+				return resp.Role.Arn, nil
+			})
 		}
 	case "groups":
 		switch file {
@@ -280,28 +300,49 @@ func (p *IAMProvider) getUserInfo(ctx context.Context, userName string) ([]byte,
 }
 
 func (p *IAMProvider) getUserPolicies(ctx context.Context, userName string) ([]byte, error) {
-	var policies []string
+	return attachedAndInline(ctx,
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
+				UserName: aws.String(userName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			var arns []string
+			for _, policy := range resp.AttachedPolicies {
+				arns = append(arns, aws.ToString(policy.PolicyArn))
+			}
+			return arns, nil
+		},
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListUserPolicies(ctx, &iam.ListUserPoliciesInput{
+				UserName: aws.String(userName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return resp.PolicyNames, nil
+		})
+}
 
-	// Get attached policies
-	attachedResp, err := p.client.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
-		UserName: aws.String(userName),
-	})
-	if err == nil {
-		for _, policy := range attachedResp.AttachedPolicies {
-			policies = append(policies, aws.ToString(policy.PolicyArn))
+// attachedAndInline fetches a principal's attached policy ARNs and inline
+// policy names concurrently and lists them as policies.json. Either list
+// is left out if it can't be fetched.
+func attachedAndInline(ctx context.Context, attached, inline func(context.Context) ([]string, error)) ([]byte, error) {
+	var arns, names []string
+	FanOut(ctx, 2, 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			arns, _ = attached(ctx)
+		} else {
+			names, _ = inline(ctx)
 		}
-	}
-
-	// Get inline policies
-	inlineResp, err := p.client.ListUserPolicies(ctx, &iam.ListUserPoliciesInput{
-		UserName: aws.String(userName),
+		return nil
 	})
-	if err == nil {
-		for _, policyName := range inlineResp.PolicyNames {
-			policies = append(policies, "inline:"+policyName)
-		}
-	}
 
+	policies := arns
+	for _, name := range names {
+		policies = append(policies, "inline:"+name)
+	}
 	return json.MarshalIndent(policies, "", "  ")
 }
 
@@ -349,66 +390,156 @@ func (p *IAMProvider) getRoleInfo(ctx context.Context, roleName string) ([]byte,
 	return json.MarshalIndent(resp.Role, "", "  ")
 }
 
-func (p *IAMProvider) getRolePolicies(ctx context.Context, roleName string) ([]byte, error) {
-	var policies []string
-
-	// Get attached policies
-	attachedResp, err := p.client.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+// getRoleTrustPolicy returns the decoded document of who may assume the role
+func (p *IAMProvider) getRoleTrustPolicy(ctx context.Context, roleName string) ([]byte, error) {
+	resp, err := p.client.GetRole(ctx, &iam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	})
-	if err == nil {
-		for _, policy := range attachedResp.AttachedPolicies {
-			policies = append(policies, aws.ToString(policy.PolicyArn))
-		}
+	if err != nil {
+		return nil, err
 	}
-
-	// Get inline policies
-	inlineResp, err := p.client.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{
-		RoleName: aws.String(roleName),
-	})
-	if err == nil {
-		for _, policyName := range inlineResp.PolicyNames {
-			policies = append(policies, "inline:"+policyName)
-		}
+	decoded, err := url.QueryUnescape(aws.ToString(resp.Role.AssumeRolePolicyDocument))
+	if err != nil {
+		return nil, err
 	}
+	var policyDoc interface{}
+	if err := json.Unmarshal([]byte(decoded), &policyDoc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(policyDoc, "", "  ")
+}
 
-	return json.MarshalIndent(policies, "", "  ")
+// pendingError is returned while a report generated on read is still
+// being prepared, so the read fails with EAGAIN ("Resource temporarily
+// unavailable") instead of holding the file system until it is ready
+type pendingError struct {
+	what string
 }
 
-func (p *IAMProvider) getPolicyInfo(ctx context.Context, policyName string) ([]byte, error) {
-	// First, list policies to find the ARN and default version
-	var policyArn string
-	var defaultVersionId string
+func (e *pendingError) Error() string {
+	return e.what + " is still being generated; read it again in a few seconds"
+}
 
-	paginator := iam.NewListPoliciesPaginator(p.client, &iam.ListPoliciesInput{
-		Scope: "Local",
-	})
+// Unwrap lets errors.Is match EAGAIN
+func (e *pendingError) Unwrap() error {
+	return syscall.EAGAIN
+}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+// iamLastAccessed is the content of last-accessed.json
+type iamLastAccessed struct {
+	JobCompletionDate *time.Time
+	// LastAuthenticated is the latest use of any service, or null if the
+	// principal hasn't used its permissions within the tracking period
+	LastAuthenticated *time.Time
+	Services          []types.ServiceLastAccessed
+}
+
+// getLastAccessed returns the service last-accessed report for the user
+// or role at path, whose ARN arn returns. The first read starts the report
+// job and each read checks it once, returning a pendingError until it
+// completes; IAM takes seconds to generate a report, too long to wait for
+// inside a read.
+func (p *IAMProvider) getLastAccessed(ctx context.Context, path string, arn func(context.Context) (*string, error)) ([]byte, error) {
+	p.mu.Lock()
+	jobID, started := p.jobs[path]
+	p.mu.Unlock()
+	if !started {
+		principal, err := arn(ctx)
 		if err != nil {
 			return nil, err
 		}
-		for _, policy := range page.Policies {
-			if aws.ToString(policy.PolicyName) == policyName {
-				policyArn = aws.ToString(policy.Arn)
-				defaultVersionId = aws.ToString(policy.DefaultVersionId)
-				break
+		job, err := p.client.GenerateServiceLastAccessedDetails(ctx, &iam.GenerateServiceLastAccessedDetailsInput{
+			Arn: principal,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobID = job.JobId
+		p.mu.Lock()
+		p.jobs[path] = jobID
+		p.mu.Unlock()
+	}
+
+	report := iamLastAccessed{Services: []types.ServiceLastAccessed{}}
+	input := &iam.GetServiceLastAccessedDetailsInput{JobId: jobID}
+	for {
+		resp, err := p.client.GetServiceLastAccessedDetails(ctx, input)
+		if err != nil {
+			p.forgetJob(path)
+			return nil, err
+		}
+		switch resp.JobStatus {
+		case types.JobStatusTypeInProgress:
+			return nil, &pendingError{what: "the last-accessed report for " + path}
+		case types.JobStatusTypeFailed:
+			p.forgetJob(path)
+			msg := "job failed"
+			if resp.Error != nil {
+				msg = aws.ToString(resp.Error.Message)
+			}
+			return nil, fmt.Errorf("last-accessed report for %s: %s", path, msg)
+		}
+
+		report.JobCompletionDate = resp.JobCompletionDate
+		for _, svc := range resp.ServicesLastAccessed {
+			if t := svc.LastAuthenticated; t != nil && (report.LastAuthenticated == nil || t.After(*report.LastAuthenticated)) {
+				report.LastAuthenticated = t
 			}
+			report.Services = append(report.Services, svc)
 		}
-		if policyArn != "" {
+		if !resp.IsTruncated {
 			break
 		}
+		input.Marker = resp.Marker
 	}
+	// The next read once the cached report expires generates a fresh one
+	p.forgetJob(path)
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// forgetJob drops the report job of path once it is done with
+func (p *IAMProvider) forgetJob(path string) {
+	p.mu.Lock()
+	delete(p.jobs, path)
+	p.mu.Unlock()
+}
+
+func (p *IAMProvider) getRolePolicies(ctx context.Context, roleName string) ([]byte, error) {
+	return attachedAndInline(ctx,
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{
+				RoleName: aws.String(roleName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			var arns []string
+			for _, policy := range resp.AttachedPolicies {
+				arns = append(arns, aws.ToString(policy.PolicyArn))
+			}
+			return arns, nil
+		},
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{
+				RoleName: aws.String(roleName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return resp.PolicyNames, nil
+		})
+}
 
-	if policyArn == "" {
-		return nil, fmt.Errorf("policy not found: %s", policyName)
+func (p *IAMProvider) getPolicyInfo(ctx context.Context, policyName string) ([]byte, error) {
+	ref, err := p.policyRef(ctx, policyName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the policy document from the default version
 	versionResp, err := p.client.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
-		PolicyArn: aws.String(policyArn),
-		VersionId: aws.String(defaultVersionId),
+		PolicyArn: aws.String(ref.arn),
+		VersionId: aws.String(ref.defaultVersion),
 	})
 	if err != nil {
 		return nil, err
@@ -429,40 +560,128 @@ func (p *IAMProvider) getPolicyInfo(ctx context.Context, policyName string) ([]b
 	return json.MarshalIndent(versionResp.PolicyVersion, "", "  ")
 }
 
-func (p *IAMProvider) getGroupInfo(ctx context.Context, groupName string) ([]byte, error) {
-	resp, err := p.client.GetGroup(ctx, &iam.GetGroupInput{
-		GroupName: aws.String(groupName),
-	})
+// rememberPolicy records where a listed policy's document lives
+func (p *IAMProvider) rememberPolicy(name, arn, defaultVersion string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policies[name] = iamPolicyRef{arn: arn, defaultVersion: defaultVersion, seen: time.Now()}
+}
+
+// policyRef finds a customer policy's ARN and default version, from the
+// last listing when recent and otherwise with GetPolicy on its listed ARN
+// or, for a policy not listed yet, on the ARN built from the account,
+// rather than paging through every policy. Only a policy under a path,
+// e.g. /team/, which is part of its ARN, is looked for in the listing.
+func (p *IAMProvider) policyRef(ctx context.Context, name string) (iamPolicyRef, error) {
+	p.mu.Lock()
+	ref, ok := p.policies[name]
+	p.mu.Unlock()
+	if ok && time.Since(ref.seen) < iamPolicyRefTTL {
+		return ref, nil
+	}
+
+	arn := ref.arn
+	if arn == "" {
+		prefix, err := p.policyARNPrefix(ctx)
+		if err != nil {
+			return iamPolicyRef{}, err
+		}
+		arn = prefix + name
+	}
+	resp, err := p.client.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(arn)})
 	if err != nil {
-		return nil, err
+		if strings.Contains(err.Error(), "NoSuchEntity") {
+			if ref.arn == "" {
+				return p.findPolicy(ctx, name)
+			}
+			return iamPolicyRef{}, fmt.Errorf("policy not found: %s", name)
+		}
+		return iamPolicyRef{}, err
 	}
-	return json.MarshalIndent(resp.Group, "", "  ")
+	p.rememberPolicy(name, arn, aws.ToString(resp.Policy.DefaultVersionId))
+	return iamPolicyRef{arn: arn, defaultVersion: aws.ToString(resp.Policy.DefaultVersionId)}, nil
 }
 
-func (p *IAMProvider) getGroupPolicies(ctx context.Context, groupName string) ([]byte, error) {
-	var policies []string
+// findPolicy pages through the customer policies until it finds name,
+// remembering the ARNs listed on the way
+func (p *IAMProvider) findPolicy(ctx context.Context, name string) (iamPolicyRef, error) {
+	paginator := iam.NewListPoliciesPaginator(p.client, &iam.ListPoliciesInput{Scope: "Local"})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return iamPolicyRef{}, err
+		}
+		for _, policy := range page.Policies {
+			p.rememberPolicy(aws.ToString(policy.PolicyName), aws.ToString(policy.Arn), aws.ToString(policy.DefaultVersionId))
+			if aws.ToString(policy.PolicyName) == name {
+				return iamPolicyRef{arn: aws.ToString(policy.Arn), defaultVersion: aws.ToString(policy.DefaultVersionId)}, nil
+			}
+		}
+	}
+	return iamPolicyRef{}, fmt.Errorf("policy not found: %s", name)
+}
 
-	// Get attached policies
-	attachedResp, err := p.client.ListAttachedGroupPolicies(ctx, &iam.ListAttachedGroupPoliciesInput{
-		GroupName: aws.String(groupName),
-	})
-	if err == nil {
-		for _, policy := range attachedResp.AttachedPolicies {
-			policies = append(policies, aws.ToString(policy.PolicyArn))
+// policyARNPrefix returns the ARN prefix of customer policies at the root
+// path, taken from a listed policy or else from the caller's account
+func (p *IAMProvider) policyARNPrefix(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.arnPrefix != "" {
+		return p.arnPrefix, nil
+	}
+	for _, ref := range p.policies {
+		if i := strings.Index(ref.arn, ":policy/"); i >= 0 {
+			p.arnPrefix = ref.arn[:i] + ":policy/"
+			return p.arnPrefix, nil
 		}
 	}
 
-	// Get inline policies
-	inlineResp, err := p.client.ListGroupPolicies(ctx, &iam.ListGroupPoliciesInput{
+	identity, err := p.sts.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	partition := "aws"
+	if parts := strings.SplitN(aws.ToString(identity.Arn), ":", 3); len(parts) == 3 {
+		partition = parts[1]
+	}
+	p.arnPrefix = fmt.Sprintf("arn:%s:iam::%s:policy/", partition, aws.ToString(identity.Account))
+	return p.arnPrefix, nil
+}
+
+func (p *IAMProvider) getGroupInfo(ctx context.Context, groupName string) ([]byte, error) {
+	resp, err := p.client.GetGroup(ctx, &iam.GetGroupInput{
 		GroupName: aws.String(groupName),
 	})
-	if err == nil {
-		for _, policyName := range inlineResp.PolicyNames {
-			policies = append(policies, "inline:"+policyName)
-		}
+	if err != nil {
+		return nil, err
 	}
+	return json.MarshalIndent(resp.Group, "", "  ")
+}
 
-	return json.MarshalIndent(policies, "", "  ")
+func (p *IAMProvider) getGroupPolicies(ctx context.Context, groupName string) ([]byte, error) {
+	return attachedAndInline(ctx,
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListAttachedGroupPolicies(ctx, &iam.ListAttachedGroupPoliciesInput{
+				GroupName: aws.String(groupName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			var arns []string
+			for _, policy := range resp.AttachedPolicies {
+				arns = append(arns, aws.ToString(policy.PolicyArn))
+			}
+			return arns, nil
+		},
+		func(ctx context.Context) ([]string, error) {
+			resp, err := p.client.ListGroupPolicies(ctx, &iam.ListGroupPoliciesInput{
+				GroupName: aws.String(groupName),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return resp.PolicyNames, nil
+		})
 }
 
 func (p *IAMProvider) getGroupMembers(ctx context.Context, groupName string) ([]byte, error) {
@@ -483,19 +702,6 @@ func (p *IAMProvider) getGroupMembers(ctx context.Context, groupName string) ([]
 }
 
 func (p *IAMProvider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
-	}
-
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
-	}
-	return entry, err
-}
-
-func (p *IAMProvider) statUncached(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return &Entry{Name: "iam", IsDir: true}, nil
 	}
@@ -531,3 +737,37 @@ func (p *IAMProvider) statUncached(ctx context.Context, path string) (*Entry, er
 
 	return nil, fmt.Errorf("path not found: %s", path)
 }
+
+// Writable reports whether path is a role's trust-policy.json, the one file
+// sisu can update
+func (p *IAMProvider) Writable(path string) bool {
+	return isIAMTrustPolicyFile(path)
+}
+
+// Write replaces a role's trust relationship with the policy document
+// written to its trust-policy.json
+func (p *IAMProvider) Write(ctx context.Context, path string, data []byte) error {
+	if !isIAMTrustPolicyFile(path) {
+		return fs.ErrPermission
+	}
+	if err := validateTrustPolicy(path, data); err != nil {
+		return err
+	}
+	// Compact the document, since IAM counts whitespace against the size quota
+	var doc bytes.Buffer
+	if err := json.Compact(&doc, data); err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+
+	_, err := p.client.UpdateAssumeRolePolicy(ctx, &iam.UpdateAssumeRolePolicyInput{
+		RoleName:       aws.String(strings.Split(path, "/")[1]),
+		PolicyDocument: aws.String(doc.String()),
+	})
+	return err
+}
+
+// isIAMTrustPolicyFile reports whether an IAM path holds a role's trust policy
+func isIAMTrustPolicyFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 3 && parts[0] == "roles" && parts[2] == "trust-policy.json"
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestIAMRoleDocuments(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+	ctx := context.Background()
+
+	for _, file := range []string{"info.json", "policies.json", "trust-policy.json"} {
+		data, err := p.Read(ctx, "roles/api/"+file)
+		if err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+		assertGolden(t, "iam/role-"+file, data)
+	}
+}
+
+func TestIAMPolicyReadUsesListing(t *testing.T) {
+	cfg, client := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+	ctx := context.Background()
+
+	if _, err := p.ReadDir(ctx, "policies"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Read(ctx, "policies/deploy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "iam/policy-deploy.json", data)
+
+	if calls := client.Calls(); !reflect.DeepEqual(calls, []string{"ListPolicies", "GetPolicyVersion"}) {
+		t.Errorf("calls = %v, want the listing and GetPolicyVersion only", calls)
+	}
+}
+
+func TestIAMPolicyReadWithoutListing(t *testing.T) {
+	cfg, client := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+
+	data, err := p.Read(context.Background(), "policies/deploy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "iam/policy-deploy.json", data)
+
+	want := []string{"GetCallerIdentity", "GetPolicy", "GetPolicyVersion"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestIAMPolicyReadUnderPath(t *testing.T) {
+	cfg, client := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+
+	// The ARN built from the account misses the policy's /team/ path
+	if _, err := p.Read(context.Background(), "policies/ci-deploy.json"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GetCallerIdentity", "GetPolicy", "ListPolicies", "GetPolicyVersion"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if arn := p.policies["ci-deploy"].arn; arn != "arn:aws:iam::123456789012:policy/team/ci-deploy" {
+		t.Errorf("ARN = %q, want the listed one", arn)
+	}
+}
+
+func TestIAMTrustPolicyWrite(t *testing.T) {
+	cfg, client := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+	ctx := context.Background()
+
+	for _, path := range []string{"roles/api/info.json", "roles/api/policies.json", "policies/deploy.json", "users/alice/info.json"} {
+		if p.Writable(path) {
+			t.Errorf("Writable(%s) = true, want only trust-policy.json writable", path)
+		}
+	}
+	if !p.Writable("roles/api/trust-policy.json") {
+		t.Error("trust-policy.json not writable")
+	}
+
+	noPrincipal := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Resource":"*"}]}`
+	if err := p.Write(ctx, "roles/api/trust-policy.json", []byte(noPrincipal)); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("statement without Principal: err = %v, want ErrInvalid", err)
+	}
+	if calls := client.Calls(); len(calls) != 0 {
+		t.Fatalf("invalid trust policy made calls %v", calls)
+	}
+
+	trust := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"Service": "ecs-tasks.amazonaws.com"}, "Action": "sts:AssumeRole"}
+  ]
+}
+`
+	if err := p.Write(ctx, "roles/api/trust-policy.json", []byte(trust)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"UpdateAssumeRolePolicy"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestIAMRoleLastAccessed(t *testing.T) {
+	cfg, client := fixtureConfig(t, "iam")
+	p := newIAMProvider(cfg)
+	ctx := context.Background()
+
+	// The report isn't ready on the first read, and the second checks the
+	// same job
+	if _, err := p.Read(ctx, "roles/api/last-accessed.json"); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("first Read = %v, want EAGAIN", err)
+	}
+	data, err := p.Read(ctx, "roles/api/last-accessed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "iam/role-last-accessed.json", data)
+
+	want := []string{"GetRole", "GenerateServiceLastAccessedDetails", "GetServiceLastAccessedDetails", "GetServiceLastAccessedDetails"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestIAMListUsersStoppedMidListing(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "iam")
+	p := Chain(newIAMProvider(cfg), AccessDenied(nil))
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "alice" || entries[1].Name != WarningFile {
+		t.Fatalf("ReadDir = %+v, want alice and %s", entries, WarningFile)
+	}
+	data, err := p.Read(ctx, "users/"+WarningFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"only the first 1 entries", "aren't allowed", "iam:ListUsers", "aws iam list-users"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("warning %q doesn't mention %q", data, want)
+		}
+	}
+}
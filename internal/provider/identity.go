@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// CallerIdentity returns the ARN of the identity a profile's credentials
+// belong to
+func CallerIdentity(ctx context.Context, profile string) (string, error) {
+	cfg, err := LoadAWSConfig(profile, "")
+	if err != nil {
+		return "", err
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Arn), nil
+}
@@ -0,0 +1,571 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// IdentityCenterProvider provides IAM Identity Center (SSO) permission sets
+// with their account assignments, and the users and groups of its identity
+// store, so access reviews can grep for a user or account:
+//
+//	permission-sets/<name>.json  the permission set, its policies and who is assigned it in which account
+//	users/<user-name>.json       the user and the groups they belong to
+//	groups/<name>.json           the group and its members' user names
+type IdentityCenterProvider struct {
+	ReadOnlyProvider
+	admin *restJSONClient // SSO Admin
+	store *restJSONClient // Identity Store
+
+	instance       *documents[identityCenterInstance]
+	permissionSets *documents[cappedList[map[string]string]]         // permission set ARNs by name, under ""
+	principals     *documents[cappedList[map[string]map[string]any]] // users or groups by name, under the directory
+}
+
+// identityCenterInstance is the organization's Identity Center instance
+type identityCenterInstance struct {
+	InstanceArn     string
+	IdentityStoreId string
+}
+
+// Directories of the identity-center service
+const (
+	identityCenterPermissionSetsDir = "permission-sets"
+	identityCenterUsersDir          = "users"
+	identityCenterGroupsDir         = "groups"
+)
+
+// identityCenterPrincipals describes how the users or groups of the
+// identity store are listed
+var identityCenterPrincipals = map[string]struct{ op, key, idKey, nameKey string }{
+	identityCenterUsersDir:  {"ListUsers", "Users", "UserId", "UserName"},
+	identityCenterGroupsDir: {"ListGroups", "Groups", "GroupId", "DisplayName"},
+}
+
+// NewIdentityCenterProvider creates a new Identity Center provider. The
+// instance lives in one region; the profile's region is used, or us-east-1.
+func NewIdentityCenterProvider(profile string) (*IdentityCenterProvider, error) {
+	cfg, err := LoadAWSConfig(profile, "")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return newIdentityCenterProvider(cfg), nil
+}
+
+func newIdentityCenterProvider(cfg aws.Config) *IdentityCenterProvider {
+	admin := newJSONRPCClient(cfg, "SSOAdmin", "sso", "SWBExternalService")
+	admin.jsonVersion = "1.1"
+	store := newJSONRPCClient(cfg, "IdentityStore", "identitystore", "AWSIdentityStore")
+	store.jsonVersion = "1.1"
+	return &IdentityCenterProvider{
+		admin:          admin,
+		store:          store,
+		instance:       newDocuments[identityCenterInstance](),
+		permissionSets: newDocuments[cappedList[map[string]string]](),
+		principals:     newDocuments[cappedList[map[string]map[string]any]](),
+	}
+}
+
+func (p *IdentityCenterProvider) Name() string {
+	return "identity-center"
+}
+
+// Invalidate forgets the memoized instance, permission sets and principals, so a refresh describes them anew
+func (p *IdentityCenterProvider) Invalidate(string) {
+	forgetDocuments(p.instance, p.permissionSets, p.principals)
+}
+
+func (p *IdentityCenterProvider) Close() {
+	closeDocuments(p.instance, p.permissionSets, p.principals)
+}
+
+// getInstance returns the Identity Center instance, or an error wrapping
+// os.ErrNotExist if there is none in the region
+func (p *IdentityCenterProvider) getInstance(ctx context.Context) (identityCenterInstance, error) {
+	return p.instance.get("", func() (identityCenterInstance, error) {
+		var resp struct {
+			Instances []identityCenterInstance
+		}
+		if err := p.admin.call(ctx, "ListInstances", nil, &resp); err != nil {
+			return identityCenterInstance{}, err
+		}
+		if len(resp.Instances) == 0 {
+			return identityCenterInstance{}, fmt.Errorf("no Identity Center instance in %s: %w", p.admin.cfg.Region, os.ErrNotExist)
+		}
+		return resp.Instances[0], nil
+	})
+}
+
+// listPermissionSets returns the ARNs of the instance's permission sets by
+// name, up to MaxEntries of them
+func (p *IdentityCenterProvider) listPermissionSets(ctx context.Context) (cappedList[map[string]string], error) {
+	return p.permissionSets.get("", func() (cappedList[map[string]string], error) {
+		inst, err := p.getInstance(ctx)
+		if err != nil {
+			return cappedList[map[string]string]{}, err
+		}
+		var arns []string
+		in := map[string]any{"InstanceArn": inst.InstanceArn, "MaxResults": 100}
+		more := false
+		for {
+			var resp struct {
+				PermissionSets []string
+				NextToken      string
+			}
+			if err := p.admin.call(ctx, "ListPermissionSets", in, &resp); err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			arns = append(arns, resp.PermissionSets...)
+			if resp.NextToken == "" {
+				break
+			}
+			if len(arns) >= MaxEntries {
+				more = true
+				break
+			}
+			in = map[string]any{"InstanceArn": inst.InstanceArn, "MaxResults": 100, "NextToken": resp.NextToken}
+		}
+
+		// Permission sets are listed by ARN only
+		names := make(map[string]string, len(arns))
+		for _, arn := range arns {
+			ps, err := p.describePermissionSet(ctx, inst, arn)
+			if err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			if name, ok := ps["Name"].(string); ok {
+				names[name] = arn
+			}
+		}
+		return cappedList[map[string]string]{items: names, more: more}, nil
+	})
+}
+
+func (p *IdentityCenterProvider) describePermissionSet(ctx context.Context, inst identityCenterInstance, arn string) (map[string]any, error) {
+	var resp struct {
+		PermissionSet map[string]any
+	}
+	in := map[string]any{"InstanceArn": inst.InstanceArn, "PermissionSetArn": arn}
+	if err := p.admin.call(ctx, "DescribePermissionSet", in, &resp); err != nil {
+		return nil, err
+	}
+	return jsonTimes(resp.PermissionSet), nil
+}
+
+// listPrincipals returns the users or groups of the identity store by
+// name, up to MaxEntries of them
+func (p *IdentityCenterProvider) listPrincipals(ctx context.Context, dir string) (cappedList[map[string]map[string]any], error) {
+	l := identityCenterPrincipals[dir]
+	return p.principals.get(dir, func() (cappedList[map[string]map[string]any], error) {
+		inst, err := p.getInstance(ctx)
+		if err != nil {
+			return cappedList[map[string]map[string]any]{}, err
+		}
+		principals := make(map[string]map[string]any)
+		in := map[string]any{"IdentityStoreId": inst.IdentityStoreId, "MaxResults": 100}
+		for {
+			var resp map[string]json.RawMessage
+			if err := p.store.call(ctx, l.op, in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			var page []map[string]any
+			if raw, ok := resp[l.key]; ok {
+				if err := json.Unmarshal(raw, &page); err != nil {
+					return cappedList[map[string]map[string]any]{}, err
+				}
+			}
+			for _, r := range page {
+				if name, ok := r[l.nameKey].(string); ok {
+					principals[name] = r
+				}
+			}
+			var token string
+			if raw, ok := resp["NextToken"]; ok {
+				json.Unmarshal(raw, &token)
+			}
+			if token == "" || len(principals) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: principals, more: token != ""}, nil
+			}
+			in = map[string]any{"IdentityStoreId": inst.IdentityStoreId, "MaxResults": 100, "NextToken": token}
+		}
+	})
+}
+
+// principalNames returns the names of the users or groups by ID
+func (p *IdentityCenterProvider) principalNames(ctx context.Context, dir string) (map[string]string, error) {
+	principals, err := p.listPrincipals(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	l := identityCenterPrincipals[dir]
+	names := make(map[string]string, len(principals.items))
+	for name, r := range principals.items {
+		if id, ok := r[l.idKey].(string); ok {
+			names[id] = name
+		}
+	}
+	return names, nil
+}
+
+// lookup returns the named user or group, or an error wrapping os.ErrNotExist
+func (p *IdentityCenterProvider) lookup(ctx context.Context, dir, name string) (map[string]any, error) {
+	principals, err := p.listPrincipals(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := principals.items[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found: %s: %w", strings.TrimSuffix(dir, "s"), name, os.ErrNotExist)
+	}
+	return r, nil
+}
+
+// permissionSetARN returns the ARN of the named permission set, or an error
+// wrapping os.ErrNotExist
+func (p *IdentityCenterProvider) permissionSetARN(ctx context.Context, name string) (string, error) {
+	arns, err := p.listPermissionSets(ctx)
+	if err != nil {
+		return "", err
+	}
+	arn, ok := arns.items[name]
+	if !ok {
+		return "", fmt.Errorf("permission set not found: %s: %w", name, os.ErrNotExist)
+	}
+	return arn, nil
+}
+
+// identityCenterAssignment is an account assignment of a permission set
+type identityCenterAssignment struct {
+	AccountID     string `json:"accountId"`
+	PrincipalType string `json:"principalType"` // USER or GROUP
+	PrincipalID   string `json:"principalId"`
+	PrincipalName string `json:"principalName,omitempty"` // user or group name, if it's in the identity store
+}
+
+// permissionSet returns permission-sets/<name>.json: the description, its
+// managed and inline policies and its assignments
+func (p *IdentityCenterProvider) permissionSet(ctx context.Context, name string) (map[string]any, error) {
+	arn, err := p.permissionSetARN(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := p.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := p.describePermissionSet(ctx, inst, arn)
+	if err != nil {
+		return nil, err
+	}
+	in := map[string]any{"InstanceArn": inst.InstanceArn, "PermissionSetArn": arn}
+
+	var managed struct {
+		AttachedManagedPolicies []map[string]any
+	}
+	if err := p.admin.call(ctx, "ListManagedPoliciesInPermissionSet", in, &managed); err != nil {
+		return nil, err
+	}
+	ps["ManagedPolicies"] = managed.AttachedManagedPolicies
+
+	var inline struct {
+		InlinePolicy string
+	}
+	if err := p.admin.call(ctx, "GetInlinePolicyForPermissionSet", in, &inline); err != nil {
+		return nil, err
+	}
+	if inline.InlinePolicy != "" {
+		var policy any
+		if json.Unmarshal([]byte(inline.InlinePolicy), &policy) == nil {
+			ps["InlinePolicy"] = policy
+		} else {
+			ps["InlinePolicy"] = inline.InlinePolicy
+		}
+	}
+
+	assignments, err := p.assignments(ctx, inst, arn)
+	if err != nil {
+		return nil, err
+	}
+	ps["Assignments"] = assignments
+	return ps, nil
+}
+
+// assignments returns who is assigned the permission set in which
+// accounts, by account
+func (p *IdentityCenterProvider) assignments(ctx context.Context, inst identityCenterInstance, arn string) ([]identityCenterAssignment, error) {
+	var accounts []string
+	in := map[string]any{"InstanceArn": inst.InstanceArn, "PermissionSetArn": arn}
+	for {
+		var resp struct {
+			AccountIds []string
+			NextToken  string
+		}
+		if err := p.admin.call(ctx, "ListAccountsForProvisionedPermissionSet", in, &resp); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, resp.AccountIds...)
+		if resp.NextToken == "" {
+			break
+		}
+		in = map[string]any{"InstanceArn": inst.InstanceArn, "PermissionSetArn": arn, "NextToken": resp.NextToken}
+	}
+	sort.Strings(accounts)
+
+	users, err := p.principalNames(ctx, identityCenterUsersDir)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := p.principalNames(ctx, identityCenterGroupsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := []identityCenterAssignment{}
+	for _, account := range accounts {
+		in := map[string]any{"InstanceArn": inst.InstanceArn, "PermissionSetArn": arn, "AccountId": account}
+		for {
+			var resp struct {
+				AccountAssignments []identityCenterAssignment
+				NextToken          string
+			}
+			if err := p.admin.call(ctx, "ListAccountAssignments", in, &resp); err != nil {
+				return nil, err
+			}
+			for _, a := range resp.AccountAssignments {
+				if a.PrincipalType == "GROUP" {
+					a.PrincipalName = groups[a.PrincipalID]
+				} else {
+					a.PrincipalName = users[a.PrincipalID]
+				}
+				assignments = append(assignments, a)
+			}
+			if resp.NextToken == "" {
+				break
+			}
+			in["NextToken"] = resp.NextToken
+		}
+	}
+	return assignments, nil
+}
+
+// UnmarshalJSON reads an assignment as the API names its fields
+func (a *identityCenterAssignment) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		AccountId     string
+		PrincipalType string
+		PrincipalId   string
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = identityCenterAssignment{AccountID: raw.AccountId, PrincipalType: raw.PrincipalType, PrincipalID: raw.PrincipalId}
+	return nil
+}
+
+// user returns users/<name>.json: the user with the names of their groups
+func (p *IdentityCenterProvider) user(ctx context.Context, name string) (map[string]any, error) {
+	u, err := p.lookup(ctx, identityCenterUsersDir, name)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := p.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := p.principalNames(ctx, identityCenterGroupsDir)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := u["UserId"].(string)
+	memberships, err := p.memberships(ctx, "ListGroupMembershipsForMember",
+		map[string]any{"IdentityStoreId": inst.IdentityStoreId, "MemberId": map[string]string{"UserId": id}})
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, m := range memberships {
+		if g, ok := groups[m.GroupId]; ok {
+			names = append(names, g)
+		}
+	}
+	sort.Strings(names)
+	doc := make(map[string]any, len(u)+1)
+	for k, v := range u {
+		doc[k] = v
+	}
+	doc["Groups"] = names
+	return doc, nil
+}
+
+// group returns groups/<name>.json: the group with its members' user names
+func (p *IdentityCenterProvider) group(ctx context.Context, name string) (map[string]any, error) {
+	g, err := p.lookup(ctx, identityCenterGroupsDir, name)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := p.getInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := p.principalNames(ctx, identityCenterUsersDir)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := g["GroupId"].(string)
+	memberships, err := p.memberships(ctx, "ListGroupMemberships",
+		map[string]any{"IdentityStoreId": inst.IdentityStoreId, "GroupId": id})
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, m := range memberships {
+		if u, ok := users[m.MemberId.UserId]; ok {
+			names = append(names, u)
+		}
+	}
+	sort.Strings(names)
+	doc := make(map[string]any, len(g)+1)
+	for k, v := range g {
+		doc[k] = v
+	}
+	doc["Members"] = names
+	return doc, nil
+}
+
+// identityCenterMembership is a group membership of a user
+type identityCenterMembership struct {
+	GroupId  string
+	MemberId struct {
+		UserId string
+	}
+}
+
+// memberships lists group memberships with op, all pages of them
+func (p *IdentityCenterProvider) memberships(ctx context.Context, op string, in map[string]any) ([]identityCenterMembership, error) {
+	var memberships []identityCenterMembership
+	for {
+		var resp struct {
+			GroupMemberships []identityCenterMembership
+			NextToken        string
+		}
+		if err := p.store.call(ctx, op, in, &resp); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, resp.GroupMemberships...)
+		if resp.NextToken == "" || len(memberships) >= MaxEntries {
+			return memberships, nil
+		}
+		in["NextToken"] = resp.NextToken
+	}
+}
+
+func (p *IdentityCenterProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	var names []string
+	var more bool
+	switch path {
+	case "":
+		return []Entry{
+			{Name: identityCenterGroupsDir, IsDir: true},
+			{Name: identityCenterPermissionSetsDir, IsDir: true},
+			{Name: identityCenterUsersDir, IsDir: true},
+		}, nil
+	case identityCenterPermissionSetsDir:
+		arns, err := p.listPermissionSets(ctx)
+		if err != nil {
+			return nil, partialListing(nil, identityCenterListHint(path), err)
+		}
+		for name := range arns.items {
+			names = append(names, name)
+		}
+		more = arns.more
+	case identityCenterUsersDir, identityCenterGroupsDir:
+		principals, err := p.listPrincipals(ctx, path)
+		if err != nil {
+			return nil, partialListing(nil, identityCenterListHint(path), err)
+		}
+		for name := range principals.items {
+			names = append(names, name)
+		}
+		more = principals.more
+	default:
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	sort.Strings(names)
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, Entry{Name: escapeSlash(name) + ".json", IsDir: false, Size: 4096})
+	}
+	return capEntries(entries, more, identityCenterListHint(path)), nil
+}
+
+// identityCenterListHint returns the AWS CLI command listing dir in full
+func identityCenterListHint(dir string) string {
+	if dir == identityCenterPermissionSetsDir {
+		return "aws sso-admin list-permission-sets"
+	}
+	return "aws identitystore list-" + dir
+}
+
+func (p *IdentityCenterProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	dir, file, ok := strings.Cut(path, "/")
+	name, isJSON := strings.CutSuffix(file, ".json")
+	if !ok || !isJSON || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	name = unescapeSlash(name)
+	var doc map[string]any
+	var err error
+	switch dir {
+	case identityCenterPermissionSetsDir:
+		doc, err = p.permissionSet(ctx, name)
+	case identityCenterUsersDir:
+		doc, err = p.user(ctx, name)
+	case identityCenterGroupsDir:
+		doc, err = p.group(ctx, name)
+	default:
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (p *IdentityCenterProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "identity-center", IsDir: true}, nil
+	}
+	dir, file, nested := strings.Cut(path, "/")
+	switch dir {
+	case identityCenterPermissionSetsDir, identityCenterUsersDir, identityCenterGroupsDir:
+	default:
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	if !nested {
+		return &Entry{Name: dir, IsDir: true}, nil
+	}
+	name, ok := strings.CutSuffix(file, ".json")
+	if !ok || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("path not found: %s: %w", path, os.ErrNotExist)
+	}
+	name = unescapeSlash(name)
+	var err error
+	if dir == identityCenterPermissionSetsDir {
+		_, err = p.permissionSetARN(ctx, name)
+	} else {
+		_, err = p.lookup(ctx, dir, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+}
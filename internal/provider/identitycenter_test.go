@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIdentityCenterPermissionSets(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "identitycenter")
+	p := newIdentityCenterProvider(cfg)
+	ctx := context.Background()
+
+	sets, err := p.ReadDir(ctx, "permission-sets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(sets); !reflect.DeepEqual(names, []string{"AdministratorAccess.json", "ReadOnly.json"}) {
+		t.Fatalf("permission sets = %v", names)
+	}
+	data, err := p.Read(ctx, "permission-sets/AdministratorAccess.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "identitycenter/permission-set.json", data)
+
+	data, err = p.Read(ctx, "groups/platform.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var group struct{ Members []string }
+	if err := json.Unmarshal(data, &group); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice@example.com", "bob@example.com"}; !reflect.DeepEqual(group.Members, want) {
+		t.Errorf("members = %v, want %v", group.Members, want)
+	}
+	data, err = p.Read(ctx, "users/alice@example.com.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user struct{ Groups []string }
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"platform"}; !reflect.DeepEqual(user.Groups, want) {
+		t.Errorf("groups = %v, want %v", user.Groups, want)
+	}
+
+	if _, err := p.Stat(ctx, "users/missing.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing user = %v, want ErrNotExist", err)
+	}
+}
+
+func TestIdentityCenterGroups(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "identitycenter")
+	p := newIdentityCenterProvider(cfg)
+	ctx := context.Background()
+
+	groups, err := p.ReadDir(ctx, "groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(groups); !reflect.DeepEqual(names, []string{"eng／web.json", "platform.json", "security.json"}) {
+		t.Fatalf("groups = %v", names)
+	}
+	if _, err := p.Stat(ctx, "groups/eng／web.json"); err != nil {
+		t.Errorf("Stat of a group with a slash: %v", err)
+	}
+}
+
+func TestIdentityCenterGroupsTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+	cfg, client := fixtureConfig(t, "identitycenter")
+	p := Chain(newIdentityCenterProvider(cfg), AccessDenied(nil))
+	ctx := context.Background()
+
+	groups, err := p.ReadDir(ctx, "groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(groups); !reflect.DeepEqual(names, []string{"eng／web.json", "platform.json", MoreResultsFile}) {
+		t.Fatalf("groups = %v", names)
+	}
+	data, err := p.Read(ctx, "groups/"+MoreResultsFile)
+	if err != nil || !strings.Contains(string(data), "aws identitystore list-groups") {
+		t.Errorf("Read marker = %q, %v", data, err)
+	}
+	if calls := client.Calls(); strings.Count(strings.Join(calls, " "), "ListGroups") != 1 {
+		t.Errorf("calls = %v, want one page of groups", calls)
+	}
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// InstanceIdentity identifies the EC2 instance sisu runs on
+type InstanceIdentity struct {
+	InstanceID string
+	Region     string
+	AccountID  string
+}
+
+// CurrentInstance asks the instance metadata service (IMDS) which instance
+// sisu runs on. Off EC2 it fails once ctx expires, since nothing answers;
+// AWS_EC2_METADATA_DISABLED=true skips the lookup.
+func CurrentInstance(ctx context.Context) (*InstanceIdentity, error) {
+	client := imds.New(imds.Options{Retryer: aws.NopRetryer{}})
+	doc, err := client.GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
+	if err != nil {
+		return nil, err
+	}
+	return &InstanceIdentity{
+		InstanceID: doc.InstanceID,
+		Region:     doc.Region,
+		AccountID:  doc.AccountID,
+	}, nil
+}
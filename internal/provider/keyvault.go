@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// keyVaultVersion is the Key Vault data plane API version
+const keyVaultVersion = "7.4"
+
+// KeyVaultProvider provides read access to the secrets of every Key Vault
+// in an Azure subscription, as <vault>/<secret>
+type KeyVaultProvider struct {
+	ReadOnlyProvider
+	arm          *restClient
+	vault        *restClient
+	subscription string
+	armURL       string
+	vaultURL     func(vault string) string
+}
+
+// NewKeyVaultProvider creates a Key Vault provider for a subscription
+func NewKeyVaultProvider(subscription string) *KeyVaultProvider {
+	return newKeyVaultProvider(subscription,
+		newRESTClient(http.DefaultClient, AzureToken("https://management.azure.com/")),
+		newRESTClient(http.DefaultClient, AzureToken("https://vault.azure.net")),
+		"https://management.azure.com",
+		func(vault string) string { return "https://" + vault + ".vault.azure.net" },
+	)
+}
+
+func newKeyVaultProvider(subscription string, arm, vault *restClient, armURL string, vaultURL func(string) string) *KeyVaultProvider {
+	return &KeyVaultProvider{
+		arm:          arm,
+		vault:        vault,
+		subscription: subscription,
+		armURL:       armURL,
+		vaultURL:     vaultURL,
+	}
+}
+
+func (p *KeyVaultProvider) Name() string {
+	return "keyvault"
+}
+
+// keyVaultSecret is a secret as returned by the data plane
+type keyVaultSecret struct {
+	ID         string `json:"id"`
+	Value      string `json:"value"`
+	Attributes struct {
+		Updated int64 `json:"updated"` // Unix seconds
+	} `json:"attributes"`
+}
+
+func (s keyVaultSecret) modTime() time.Time {
+	if s.Attributes.Updated == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s.Attributes.Updated, 0)
+}
+
+func (p *KeyVaultProvider) ReadDir(ctx context.Context, dir string) ([]Entry, error) {
+	if dir == "" {
+		names, err := armList(ctx, p.arm, p.armURL+"/subscriptions/"+url.PathEscape(p.subscription)+
+			"/providers/Microsoft.KeyVault/vaults?api-version=2022-07-01")
+		if err != nil {
+			return nil, partialListing(dirEntries(names), "az keyvault list --subscription "+p.subscription, err)
+		}
+		return dirEntries(names), nil
+	}
+	if strings.Contains(dir, "/") {
+		return nil, fmt.Errorf("not a directory: %s", dir)
+	}
+
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, next string) ([]Entry, string, error) {
+		if next == "" {
+			next = p.vaultURL(dir) + "/secrets?api-version=" + keyVaultVersion
+		}
+		var page struct {
+			Value    []keyVaultSecret `json:"value"`
+			NextLink string           `json:"nextLink"`
+		}
+		if err := p.vault.getJSON(ctx, next, &page); err != nil {
+			return nil, "", err
+		}
+		entries := make([]Entry, 0, len(page.Value))
+		for _, s := range page.Value {
+			entries = append(entries, Entry{Name: path.Base(s.ID), ModTime: s.modTime()})
+		}
+		return entries, page.NextLink, nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, keyVaultListHint(dir), err)
+	}
+	return entries, nil
+}
+
+// keyVaultListHint returns the CLI command listing a vault's secrets in full
+func keyVaultListHint(vault string) string {
+	return "az keyvault secret list --vault-name " + vault
+}
+
+func (p *KeyVaultProvider) secret(ctx context.Context, vault, name string) (*keyVaultSecret, error) {
+	var s keyVaultSecret
+	u := p.vaultURL(vault) + "/secrets/" + url.PathEscape(name) + "?api-version=" + keyVaultVersion
+	if err := p.vault.getJSON(ctx, u, &s); err != nil {
+		return nil, err
+	}
+	// Add newline for better cat output
+	if !strings.HasSuffix(s.Value, "\n") {
+		s.Value += "\n"
+	}
+	return &s, nil
+}
+
+func (p *KeyVaultProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	vault, name, ok := strings.Cut(path, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	s, err := p.secret(ctx, vault, name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s.Value), nil
+}
+
+// IsSecret reports whether path is a secret of a vault, which every file is
+func (p *KeyVaultProvider) IsSecret(ctx context.Context, path string) (bool, error) {
+	return strings.Contains(path, "/"), nil
+}
+
+func (p *KeyVaultProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "keyvault", IsDir: true}, nil
+	}
+
+	vault, name, ok := strings.Cut(path, "/")
+	if !ok {
+		if _, err := p.vault.get(ctx, p.vaultURL(vault)+"/secrets?maxresults=1&api-version="+keyVaultVersion); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: vault, IsDir: true}, nil
+	}
+
+	s, err := p.secret(ctx, vault, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Name: name, Size: int64(len(s.Value)), ModTime: s.modTime()}, nil
+}
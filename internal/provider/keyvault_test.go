@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyVaultReadSecret(t *testing.T) {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/myvault/secrets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value": [{"id": "` + srv.URL + `/myvault/secrets/api-key", "attributes": {"updated": 1714557600}}]}`))
+	})
+	mux.HandleFunc("/myvault/secrets/api-key", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value": "s3cret", "attributes": {"updated": 1714557600}}`))
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	rest := newRESTClient(srv.Client(), staticToken("token"))
+	p := newKeyVaultProvider("sub-1", rest, rest, srv.URL, func(vault string) string { return srv.URL + "/" + vault })
+
+	entries, err := p.ReadDir(context.Background(), "myvault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "api-key" || entries[0].ModTime.Unix() != 1714557600 {
+		t.Errorf("ReadDir = %+v", entries)
+	}
+
+	entry, err := p.Stat(context.Background(), "myvault/api-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size != int64(len("s3cret\n")) {
+		t.Errorf("Stat size = %d", entry.Size)
+	}
+}
@@ -0,0 +1,358 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// KinesisProvider provides Kinesis data streams as directories:
+//
+//	<stream>/info.json    the stream's summary: status, mode, retention, encryption
+//	<stream>/shards.json  its shards with their hash key and sequence number ranges
+//	<stream>/tail         its most recent records, one per line
+type KinesisProvider struct {
+	ReadOnlyProvider
+	client  *restJSONClient
+	streams *documents[cappedList[[]string]] // stream names, under ""
+	now     func() time.Time
+}
+
+// Files of a stream directory
+const (
+	kinesisInfoFile   = "info.json"
+	kinesisShardsFile = "shards.json"
+	kinesisTailFile   = "tail"
+)
+
+// KinesisTailRecords caps the records tail shows, the latest across shards
+const KinesisTailRecords = 100
+
+// kinesisTailWindow is how far back tail starts reading each open shard.
+// kinesisTailCalls caps the GetRecords calls per shard to catch up from
+// there, since a shard can return empty pages before reaching its records.
+const (
+	kinesisTailWindow = 5 * time.Minute
+	kinesisTailCalls  = 5
+)
+
+// NewKinesisProvider creates a new Kinesis provider
+func NewKinesisProvider(profile, region string) (*KinesisProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newKinesisProvider(cfg), nil
+}
+
+func newKinesisProvider(cfg aws.Config) *KinesisProvider {
+	client := newJSONRPCClient(cfg, "Kinesis", "kinesis", "Kinesis_20131202")
+	client.jsonVersion = "1.1"
+	return &KinesisProvider{
+		client:  client,
+		streams: newDocuments[cappedList[[]string]](),
+		now:     time.Now,
+	}
+}
+
+func (p *KinesisProvider) Name() string {
+	return "kinesis"
+}
+
+// Invalidate forgets the memoized stream names, so a refresh describes them anew
+func (p *KinesisProvider) Invalidate(string) {
+	forgetDocuments(p.streams)
+}
+
+func (p *KinesisProvider) Close() {
+	closeDocuments(p.streams)
+}
+
+func (p *KinesisProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		streams, err := p.listStreams(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws kinesis list-streams", err)
+		}
+		entries := make([]Entry, 0, len(streams.items))
+		for _, name := range streams.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		return capEntries(entries, streams.more, "aws kinesis list-streams"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if err := p.checkStream(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: kinesisInfoFile, IsDir: false, Size: 4096},
+		{Name: kinesisShardsFile, IsDir: false, Size: 4096},
+		{Name: kinesisTailFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+// listStreams returns the sorted names of the region's streams, up to
+// MaxEntries of them
+func (p *KinesisProvider) listStreams(ctx context.Context) (cappedList[[]string], error) {
+	return p.streams.get("", func() (cappedList[[]string], error) {
+		var names []string
+		in := map[string]any{"Limit": 100}
+		more := false
+		for {
+			var resp struct {
+				StreamNames    []string
+				HasMoreStreams bool
+				NextToken      string
+			}
+			if err := p.client.call(ctx, "ListStreams", in, &resp); err != nil {
+				return cappedList[[]string]{}, err
+			}
+			names = append(names, resp.StreamNames...)
+			if !resp.HasMoreStreams || resp.NextToken == "" {
+				break
+			}
+			if len(names) >= MaxEntries {
+				more = true
+				break
+			}
+			in = map[string]any{"Limit": 100, "NextToken": resp.NextToken}
+		}
+		sort.Strings(names)
+		return cappedList[[]string]{items: names, more: more}, nil
+	})
+}
+
+// checkStream returns an error wrapping os.ErrNotExist unless the stream
+// exists, asking DescribeStreamSummary if it isn't listed
+func (p *KinesisProvider) checkStream(ctx context.Context, name string) error {
+	streams, err := p.listStreams(ctx)
+	if err != nil {
+		return err
+	}
+	if i := sort.SearchStrings(streams.items, name); i < len(streams.items) && streams.items[i] == name {
+		return nil
+	}
+	_, err = p.summary(ctx, name)
+	return err
+}
+
+func (p *KinesisProvider) summary(ctx context.Context, name string) (map[string]any, error) {
+	var resp struct {
+		StreamDescriptionSummary map[string]any
+	}
+	if err := p.client.call(ctx, "DescribeStreamSummary", map[string]any{"StreamName": name}, &resp); err != nil {
+		return nil, kinesisError(name, err)
+	}
+	return jsonTimes(resp.StreamDescriptionSummary), nil
+}
+
+// kinesisError maps a missing stream to os.ErrNotExist
+func kinesisError(stream string, err error) error {
+	if isAPIError(err, "ResourceNotFoundException") {
+		return fmt.Errorf("stream not found: %s: %w", stream, os.ErrNotExist)
+	}
+	return err
+}
+
+// kinesisShard is a shard as listed by ListShards
+type kinesisShard struct {
+	ShardID               string `json:"ShardId"`
+	ParentShardID         string `json:"ParentShardId,omitempty"`
+	AdjacentParentShardID string `json:"AdjacentParentShardId,omitempty"`
+	HashKeyRange          map[string]string
+	SequenceNumberRange   map[string]string
+}
+
+// open reports whether the shard still takes records; closed shards have
+// an ending sequence number
+func (s kinesisShard) open() bool {
+	return s.SequenceNumberRange["EndingSequenceNumber"] == ""
+}
+
+// shards returns all of the stream's shards, which its shard quota bounds,
+// so shards.json and tail leave none out
+func (p *KinesisProvider) shards(ctx context.Context, name string) ([]kinesisShard, error) {
+	shards := []kinesisShard{}
+	in := map[string]any{"StreamName": name, "MaxResults": 1000}
+	for {
+		var resp struct {
+			Shards    []kinesisShard
+			NextToken string
+		}
+		if err := p.client.call(ctx, "ListShards", in, &resp); err != nil {
+			return nil, kinesisError(name, err)
+		}
+		shards = append(shards, resp.Shards...)
+		if resp.NextToken == "" {
+			return shards, nil
+		}
+		// Later pages are named by the token alone
+		in = map[string]any{"NextToken": resp.NextToken, "MaxResults": 1000}
+	}
+}
+
+// kinesisRecord is a record returned by GetRecords
+type kinesisRecord struct {
+	SequenceNumber              string
+	ApproximateArrivalTimestamp float64
+	Data                        []byte
+	PartitionKey                string
+	shard                       string
+}
+
+// shardRecords returns the records of a shard from since on, reading
+// until it's caught up or kinesisTailCalls pages were read
+func (p *KinesisProvider) shardRecords(ctx context.Context, name, shard string, since time.Time) ([]kinesisRecord, error) {
+	var it struct{ ShardIterator string }
+	in := map[string]any{
+		"StreamName":        name,
+		"ShardId":           shard,
+		"ShardIteratorType": "AT_TIMESTAMP",
+		"Timestamp":         since.Unix(),
+	}
+	if err := p.client.call(ctx, "GetShardIterator", in, &it); err != nil {
+		return nil, kinesisError(name, err)
+	}
+
+	var records []kinesisRecord
+	iterator := it.ShardIterator
+	for call := 0; call < kinesisTailCalls && iterator != ""; call++ {
+		var resp struct {
+			Records            []kinesisRecord
+			NextShardIterator  string
+			MillisBehindLatest int64
+		}
+		if err := p.client.call(ctx, "GetRecords", map[string]any{"ShardIterator": iterator, "Limit": 1000}, &resp); err != nil {
+			return nil, kinesisError(name, err)
+		}
+		for _, r := range resp.Records {
+			r.shard = shard
+			records = append(records, r)
+		}
+		if resp.MillisBehindLatest == 0 {
+			break
+		}
+		iterator = resp.NextShardIterator
+	}
+	return records, nil
+}
+
+// tail renders the latest records of the stream's open shards, oldest
+// first, as tab-separated arrival time, shard, partition key and data.
+// Data that isn't printable text is shown as base64.
+func (p *KinesisProvider) tail(ctx context.Context, name string) ([]byte, error) {
+	shards, err := p.shards(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var open []string
+	for _, s := range shards {
+		if s.open() {
+			open = append(open, s.ShardID)
+		}
+	}
+
+	since := p.now().Add(-kinesisTailWindow)
+	var mu sync.Mutex
+	var records []kinesisRecord
+	err = FanOut(ctx, FanOutLimit, len(open), func(ctx context.Context, i int) error {
+		rs, err := p.shardRecords(ctx, name, open[i], since)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		records = append(records, rs...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.ApproximateArrivalTimestamp != b.ApproximateArrivalTimestamp {
+			return a.ApproximateArrivalTimestamp < b.ApproximateArrivalTimestamp
+		}
+		// Sequence numbers are decimal strings that grow in length
+		if len(a.SequenceNumber) != len(b.SequenceNumber) {
+			return len(a.SequenceNumber) < len(b.SequenceNumber)
+		}
+		return a.SequenceNumber < b.SequenceNumber
+	})
+	if len(records) > KinesisTailRecords {
+		records = records[len(records)-KinesisTailRecords:]
+	}
+	var b strings.Builder
+	for _, r := range records {
+		arrived := epochTime(r.ApproximateArrivalTimestamp).Round(time.Millisecond)
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", arrived.Format(time.RFC3339Nano), r.shard, r.PartitionKey, kinesisData(r.Data))
+	}
+	return []byte(b.String()), nil
+}
+
+// kinesisData shows a record's data on one line: as is if it's printable
+// text, with line breaks escaped, and as base64 otherwise
+func kinesisData(data []byte) string {
+	s := strings.TrimRight(string(data), "\n")
+	printable := utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) && r != '\n' && r != '\t'
+	}) < 0
+	if !printable {
+		return "base64:" + base64.StdEncoding.EncodeToString(data)
+	}
+	return strings.NewReplacer("\n", `\n`, "\t", `\t`).Replace(s)
+}
+
+func (p *KinesisProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	stream, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	switch file {
+	case kinesisInfoFile:
+		summary, err := p.summary(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(summary, "", "  ")
+	case kinesisShardsFile:
+		shards, err := p.shards(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(shards, "", "  ")
+	case kinesisTailFile:
+		return p.tail(ctx, stream)
+	}
+	return nil, fmt.Errorf("unknown file: %s", file)
+}
+
+func (p *KinesisProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "kinesis", IsDir: true}, nil
+	}
+	stream, file, _ := strings.Cut(path, "/")
+	if err := p.checkStream(ctx, stream); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: stream, IsDir: true}, nil
+	case kinesisInfoFile, kinesisShardsFile, kinesisTailFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKinesisStreams(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "kinesis")
+	p := newKinesisProvider(cfg)
+	ctx := context.Background()
+
+	files, err := p.ReadDir(ctx, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(files); !reflect.DeepEqual(names, []string{"info.json", "shards.json", "tail"}) {
+		t.Errorf("files = %v", names)
+	}
+
+	for _, file := range []string{"info.json", "shards.json"} {
+		data, err := p.Read(ctx, "orders/"+file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertGolden(t, "kinesis/"+file, data)
+	}
+}
+
+func TestKinesisTail(t *testing.T) {
+	cfg, client := fixtureConfig(t, "kinesis")
+	p := newKinesisProvider(cfg)
+	p.now = func() time.Time { return time.Date(2024, 5, 1, 8, 5, 0, 0, time.UTC) }
+
+	data, err := p.Read(context.Background(), "orders/tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "kinesis/tail", data)
+
+	// Only the open shard is read, from five minutes ago until caught up
+	want := []string{"ListShards", "GetShardIterator", "GetRecords", "GetRecords"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestKinesisData(t *testing.T) {
+	tests := map[string]string{
+		"hello\n":          "hello",
+		"two\nlines":       `two\nlines`,
+		"\x00\x01\x02\x03": "base64:AAECAw==",
+	}
+	for in, want := range tests {
+		if got := kinesisData([]byte(in)); got != want {
+			t.Errorf("kinesisData(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -3,62 +3,64 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
-	"github.com/semonte/sisu/internal/cache"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"github.com/semonte/sisu/internal/paging"
 )
 
 // LambdaProvider provides access to AWS Lambda functions
 type LambdaProvider struct {
 	ReadOnlyProvider
-	client *lambda.Client
-	cache  *cache.Cache
+	client     *lambda.Client
+	httpClient aws.HTTPClient // downloads deployment packages from presigned URLs
+	functions  *documents[*lambda.GetFunctionOutput]
 }
 
 // NewLambdaProvider creates a new Lambda provider
 func NewLambdaProvider(profile, region string) (*LambdaProvider, error) {
-	var opts []func(*config.LoadOptions) error
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
 		return nil, err
 	}
+	return newLambdaProvider(cfg), nil
+}
 
+func newLambdaProvider(cfg aws.Config) *LambdaProvider {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 	return &LambdaProvider{
-		client: lambda.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+		client:     lambda.NewFromConfig(cfg),
+		httpClient: httpClient,
+		functions:  newDocuments[*lambda.GetFunctionOutput](),
+	}
 }
 
 func (p *LambdaProvider) Name() string {
 	return "lambda"
 }
 
-func (p *LambdaProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
+// Invalidate forgets the memoized function descriptions, so a refresh describes them anew
+func (p *LambdaProvider) Invalidate(string) {
+	forgetDocuments(p.functions)
+}
 
-	entries, err := p.readDirUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entries)
-	}
-	return entries, err
+func (p *LambdaProvider) Close() {
+	closeDocuments(p.functions)
 }
 
-func (p *LambdaProvider) readDirUncached(ctx context.Context, path string) ([]Entry, error) {
+func (p *LambdaProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
 	// Root: list all functions
 	if path == "" {
 		return p.listFunctions(ctx)
@@ -71,6 +73,11 @@ func (p *LambdaProvider) readDirUncached(ctx context.Context, path string) ([]En
 			{Name: "config.json", IsDir: false},
 			{Name: "policy.json", IsDir: false},
 			{Name: "env.json", IsDir: false},
+			{Name: "tags.json", IsDir: false},
+			{Name: "layers.json", IsDir: false},
+			{Name: "concurrency.json", IsDir: false},
+			{Name: "url-config.json", IsDir: false},
+			{Name: "code.zip", IsDir: false},
 		}, nil
 	}
 
@@ -78,47 +85,32 @@ func (p *LambdaProvider) readDirUncached(ctx context.Context, path string) ([]En
 }
 
 func (p *LambdaProvider) listFunctions(ctx context.Context) ([]Entry, error) {
-	var entries []Entry
-	var marker *string
-
-	for {
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, marker string) ([]Entry, string, error) {
 		resp, err := p.client.ListFunctions(ctx, &lambda.ListFunctionsInput{
-			Marker: marker,
+			Marker: optionalString(marker),
 		})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
+		entries := make([]Entry, 0, len(resp.Functions))
 		for _, fn := range resp.Functions {
 			entries = append(entries, Entry{
 				Name:  aws.ToString(fn.FunctionName),
 				IsDir: true,
 			})
 		}
-
-		if resp.NextMarker == nil {
-			break
-		}
-		marker = resp.NextMarker
+		return entries, aws.ToString(resp.NextMarker), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, lambdaListHint, err)
 	}
-
 	return entries, nil
 }
 
-func (p *LambdaProvider) Read(ctx context.Context, path string) ([]byte, error) {
-	cacheKey := "read:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]byte), nil
-	}
-
-	data, err := p.readUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, data)
-	}
-	return data, err
-}
+const lambdaListHint = "aws lambda list-functions"
 
-func (p *LambdaProvider) readUncached(ctx context.Context, path string) ([]byte, error) {
+func (p *LambdaProvider) Read(ctx context.Context, path string) ([]byte, error) {
 	parts := strings.Split(path, "/")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid path: %s", path)
@@ -128,26 +120,81 @@ func (p *LambdaProvider) readUncached(ctx context.Context, path string) ([]byte,
 	file := parts[1]
 
 	switch file {
-	case "config.json":
-		return p.getFunctionConfig(ctx, functionName)
+	case "config.json", "env.json", "layers.json":
+		return p.readFunctionFile(ctx, functionName, file)
 	case "policy.json":
 		return p.getFunctionPolicy(ctx, functionName)
-	case "env.json":
-		return p.getFunctionEnv(ctx, functionName)
+	case "concurrency.json":
+		return p.getFunctionConcurrency(ctx, functionName)
+	case "url-config.json":
+		return p.getFunctionURLConfig(ctx, functionName)
+	case "code.zip":
+		return p.getFunctionCode(ctx, functionName, "")
+	case TagsFile:
+		resp, err := p.getFunction(ctx, functionName)
+		if err != nil {
+			return nil, err
+		}
+		return renderTags(resp.Tags)
 	}
 
 	return nil, fmt.Errorf("unknown file: %s", file)
 }
 
-func (p *LambdaProvider) getFunctionConfig(ctx context.Context, functionName string) ([]byte, error) {
-	resp, err := p.client.GetFunction(ctx, &lambda.GetFunctionInput{
-		FunctionName: aws.String(functionName),
+// ReadRange fetches part of a function's deployment package, so tools like
+// `unzip -l` or `head -c` don't download the whole zip. Other files are
+// small documents and are sliced from a full read.
+func (p *LambdaProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "code.zip" || length <= 0 {
+		data, err := p.Read(ctx, path)
+		return sliceRange(data, off, length), err
+	}
+	return p.getFunctionCode(ctx, parts[0], fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+}
+
+// getFunction returns the function's configuration and code location,
+// shared by its directory stat, config.json, env.json, tags.json and code.zip
+func (p *LambdaProvider) getFunction(ctx context.Context, functionName string) (*lambda.GetFunctionOutput, error) {
+	return p.functions.get(functionName, func() (*lambda.GetFunctionOutput, error) {
+		return p.client.GetFunction(ctx, &lambda.GetFunctionInput{
+			FunctionName: aws.String(functionName),
+		})
 	})
+}
+
+// getFunctionCode downloads the deployment package from its presigned URL,
+// limited to byteRange if set
+func (p *LambdaProvider) getFunctionCode(ctx context.Context, functionName, byteRange string) ([]byte, error) {
+	resp, err := p.getFunction(ctx, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code == nil || resp.Code.Location == nil {
+		return nil, fmt.Errorf("no downloadable code for %s (container image functions have none)", functionName)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aws.ToString(resp.Code.Location), nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	httpResp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer httpResp.Body.Close()
 
-	return json.MarshalIndent(resp.Configuration, "", "  ")
+	switch httpResp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return io.ReadAll(httpResp.Body)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("failed to download code for %s: %s", functionName, httpResp.Status)
 }
 
 func (p *LambdaProvider) getFunctionPolicy(ctx context.Context, functionName string) ([]byte, error) {
@@ -171,36 +218,129 @@ func (p *LambdaProvider) getFunctionPolicy(ctx context.Context, functionName str
 	return json.MarshalIndent(policy, "", "  ")
 }
 
-func (p *LambdaProvider) getFunctionEnv(ctx context.Context, functionName string) ([]byte, error) {
-	resp, err := p.client.GetFunction(ctx, &lambda.GetFunctionInput{
+// lambdaConcurrency is the content of concurrency.json
+type lambdaConcurrency struct {
+	// ReservedConcurrentExecutions is unset when the function uses the
+	// account's unreserved pool
+	ReservedConcurrentExecutions *int32
+	Provisioned                  []types.ProvisionedConcurrencyConfigListItem
+}
+
+// getFunctionConcurrency combines the reserved concurrency from
+// GetFunction with the provisioned concurrency of every alias and version
+func (p *LambdaProvider) getFunctionConcurrency(ctx context.Context, functionName string) ([]byte, error) {
+	fn, err := p.getFunction(ctx, functionName)
+	if err != nil {
+		return nil, err
+	}
+	c := lambdaConcurrency{Provisioned: []types.ProvisionedConcurrencyConfigListItem{}}
+	if fn.Concurrency != nil {
+		c.ReservedConcurrentExecutions = fn.Concurrency.ReservedConcurrentExecutions
+	}
+
+	paginator := lambda.NewListProvisionedConcurrencyConfigsPaginator(p.client, &lambda.ListProvisionedConcurrencyConfigsInput{
+		FunctionName: aws.String(functionName),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.Provisioned = append(c.Provisioned, resp.ProvisionedConcurrencyConfigs...)
+	}
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// lambdaURLConfig is the content of url-config.json
+type lambdaURLConfig struct {
+	FunctionUrl      string
+	AuthType         types.FunctionUrlAuthType
+	InvokeMode       types.InvokeMode
+	Cors             *types.Cors `json:",omitempty"`
+	CreationTime     string
+	LastModifiedTime string
+}
+
+// getFunctionURLConfig returns the function URL's settings, or {} for
+// functions without a URL
+func (p *LambdaProvider) getFunctionURLConfig(ctx context.Context, functionName string) ([]byte, error) {
+	resp, err := p.client.GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{
 		FunctionName: aws.String(functionName),
 	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return []byte("{}"), nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	return json.MarshalIndent(lambdaURLConfig{
+		FunctionUrl:      aws.ToString(resp.FunctionUrl),
+		AuthType:         resp.AuthType,
+		InvokeMode:       resp.InvokeMode,
+		Cors:             resp.Cors,
+		CreationTime:     aws.ToString(resp.CreationTime),
+		LastModifiedTime: aws.ToString(resp.LastModifiedTime),
+	}, "", "  ")
+}
 
-	env := make(map[string]string)
-	if resp.Configuration.Environment != nil && resp.Configuration.Environment.Variables != nil {
-		env = resp.Configuration.Environment.Variables
+// Prefetch returns every file derived from GetFunction when one is read
+func (p *LambdaProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || !lambdaFunctionFiles[parts[1]] {
+		return nil, nil
+	}
+
+	resp, err := p.getFunction(ctx, parts[0])
+	if err != nil {
+		return nil, err
 	}
+	files := make(map[string][]byte, len(lambdaFunctionFiles))
+	for file := range lambdaFunctionFiles {
+		data, err := renderFunctionFile(resp.Configuration, file)
+		if err != nil {
+			return nil, err
+		}
+		files[parts[0]+"/"+file] = data
+	}
+	return files, nil
+}
 
-	return json.MarshalIndent(env, "", "  ")
+// lambdaFunctionFiles are the files derived from a function's configuration
+var lambdaFunctionFiles = map[string]bool{
+	"config.json": true,
+	"env.json":    true,
+	"layers.json": true,
 }
 
-func (p *LambdaProvider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
+func (p *LambdaProvider) readFunctionFile(ctx context.Context, functionName, file string) ([]byte, error) {
+	resp, err := p.getFunction(ctx, functionName)
+	if err != nil {
+		return nil, err
 	}
+	return renderFunctionFile(resp.Configuration, file)
+}
 
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
+// renderFunctionFile derives one of lambdaFunctionFiles from the configuration
+func renderFunctionFile(config *types.FunctionConfiguration, file string) ([]byte, error) {
+	switch file {
+	case "env.json":
+		env := make(map[string]string)
+		if config.Environment != nil && config.Environment.Variables != nil {
+			env = config.Environment.Variables
+		}
+		return json.MarshalIndent(env, "", "  ")
+	case "layers.json":
+		layers := config.Layers
+		if layers == nil {
+			layers = []types.Layer{}
+		}
+		return json.MarshalIndent(layers, "", "  ")
 	}
-	return entry, err
+	return json.MarshalIndent(config, "", "  ")
 }
 
-func (p *LambdaProvider) statUncached(ctx context.Context, path string) (*Entry, error) {
+func (p *LambdaProvider) Stat(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return &Entry{Name: "lambda", IsDir: true}, nil
 	}
@@ -209,9 +349,7 @@ func (p *LambdaProvider) statUncached(ctx context.Context, path string) (*Entry,
 
 	// Function directory
 	if len(parts) == 1 {
-		_, err := p.client.GetFunction(ctx, &lambda.GetFunctionInput{
-			FunctionName: aws.String(parts[0]),
-		})
+		_, err := p.getFunction(ctx, parts[0])
 		if err != nil {
 			return nil, fmt.Errorf("function not found: %s", parts[0])
 		}
@@ -221,10 +359,120 @@ func (p *LambdaProvider) statUncached(ctx context.Context, path string) (*Entry,
 	// Files
 	if len(parts) == 2 {
 		switch parts[1] {
-		case "config.json", "policy.json", "env.json":
+		case "config.json", "policy.json", "env.json", "tags.json", "layers.json", "concurrency.json", "url-config.json":
 			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
+		case "code.zip":
+			return p.statFunctionCode(ctx, parts[0])
 		}
 	}
 
 	return nil, fmt.Errorf("path not found: %s", path)
 }
+
+// statFunctionCode reports the real package size, so partial reads and
+// offsets within the zip line up
+func (p *LambdaProvider) statFunctionCode(ctx context.Context, functionName string) (*Entry, error) {
+	resp, err := p.getFunction(ctx, functionName)
+	if err != nil {
+		return nil, err
+	}
+
+	var modTime time.Time
+	if t, err := time.Parse(lambdaTimeFormat, aws.ToString(resp.Configuration.LastModified)); err == nil {
+		modTime = t
+	}
+	return &Entry{Name: "code.zip", Size: resp.Configuration.CodeSize, ModTime: modTime}, nil
+}
+
+// Writable reports whether path is a function's env.json or tags.json,
+// the files sisu can update
+func (p *LambdaProvider) Writable(path string) bool {
+	return isLambdaEnvFile(path) || isTagsFile(path)
+}
+
+// Write replaces a function's environment variables with the JSON object
+// written to its env.json, or applies the tags written to its tags.json
+func (p *LambdaProvider) Write(ctx context.Context, path string, data []byte) error {
+	if isTagsFile(path) {
+		return p.writeTags(ctx, path, data)
+	}
+	if !isLambdaEnvFile(path) {
+		return fs.ErrPermission
+	}
+	env, err := parseLambdaEnv(data)
+	if err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+
+	functionName := strings.Split(path, "/")[0]
+	_, err = p.client.UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+		Environment:  &types.Environment{Variables: env},
+	})
+	p.functions.forget(functionName)
+	return err
+}
+
+// writeTags tags and untags a function so its tags match tags.json
+func (p *LambdaProvider) writeTags(ctx context.Context, path string, data []byte) error {
+	desired, err := parseTags(data)
+	if err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+
+	functionName := strings.Split(path, "/")[0]
+	resp, err := p.getFunction(ctx, functionName)
+	if err != nil {
+		return err
+	}
+	arn := resp.Configuration.FunctionArn
+	defer p.functions.forget(functionName)
+
+	set, remove := diffTags(resp.Tags, desired)
+	if len(remove) > 0 {
+		if _, err := p.client.UntagResource(ctx, &lambda.UntagResourceInput{
+			Resource: arn,
+			TagKeys:  remove,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(set) > 0 {
+		if _, err := p.client.TagResource(ctx, &lambda.TagResourceInput{
+			Resource: arn,
+			Tags:     set,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isLambdaEnvFile reports whether a Lambda path holds environment variables
+func isLambdaEnvFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 2 && parts[1] == "env.json"
+}
+
+// parseLambdaEnv decodes env.json, which must be an object of strings
+func parseLambdaEnv(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of string values: %w", err)
+	}
+	return env, nil
+}
+
+// validateLambdaEnv rejects env.json writes that aren't an object of strings
+func validateLambdaEnv(path string, data []byte) error {
+	if !isLambdaEnvFile(path) {
+		return nil
+	}
+	if _, err := parseLambdaEnv(data); err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// lambdaTimeFormat is the layout of LastModified in function configurations
+const lambdaTimeFormat = "2006-01-02T15:04:05.000-0700"
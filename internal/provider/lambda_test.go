@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestLambdaDocuments(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "lambda")
+	p := newLambdaProvider(cfg)
+	ctx := context.Background()
+
+	for _, file := range []string{"config.json", "env.json", "tags.json", "policy.json", "layers.json", "concurrency.json", "url-config.json"} {
+		data, err := p.Read(ctx, "api/"+file)
+		if err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+		assertGolden(t, "lambda/"+file, data)
+	}
+}
+
+func TestLambdaPrefetchSharesGetFunction(t *testing.T) {
+	cfg, client := fixtureConfig(t, "lambda")
+	p := Cached(newLambdaProvider(cfg), DefaultCachePolicy)
+	ctx := context.Background()
+
+	for _, file := range []string{"env.json", "config.json"} {
+		data, err := p.Read(ctx, "api/"+file)
+		if err != nil {
+			t.Fatalf("Read %s: %v", file, err)
+		}
+		assertGolden(t, "lambda/"+file, data)
+	}
+	if calls := client.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single GetFunction", calls)
+	}
+}
+
+func TestLambdaFilesShareGetFunction(t *testing.T) {
+	cfg, client := fixtureConfig(t, "lambda")
+	p := newLambdaProvider(cfg)
+	ctx := context.Background()
+
+	for _, path := range []string{"api", "api/code.zip"} {
+		if _, err := p.Stat(ctx, path); err != nil {
+			t.Fatalf("Stat %s: %v", path, err)
+		}
+	}
+	if _, err := p.Read(ctx, "api/env.json"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := client.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single GetFunction", calls)
+	}
+}
+
+func TestLambdaEnvWrite(t *testing.T) {
+	cfg, client := fixtureConfig(t, "lambda")
+	p := newLambdaProvider(cfg)
+	ctx := context.Background()
+
+	for _, path := range []string{"api/config.json", "api/policy.json", "api/layers.json", "api/concurrency.json", "api/url-config.json", "api/code.zip", "api"} {
+		if p.Writable(path) {
+			t.Errorf("Writable(%s) = true, want only env.json writable", path)
+		}
+	}
+	if err := validateLambdaEnv("api/env.json", []byte(`{"PORT": 8080}`)); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("non-string value: err = %v, want ErrInvalid", err)
+	}
+
+	if _, err := p.Read(ctx, "api/env.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Write(ctx, "api/env.json", []byte(`{"STAGE": "prod", "LOG_LEVEL": "debug"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Read(ctx, "api/env.json"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GetFunction", "UpdateFunctionConfiguration", "GetFunction"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestLambdaTagsWrite(t *testing.T) {
+	cfg, client := fixtureConfig(t, "lambda")
+	p := newLambdaProvider(cfg)
+	ctx := context.Background()
+
+	if !p.Writable("api/tags.json") {
+		t.Error("tags.json is not writable")
+	}
+	if err := p.Write(ctx, "api/tags.json", []byte(`{"team": "platform"}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GetFunction", "UntagResource", "TagResource"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
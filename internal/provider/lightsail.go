@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// LightsailProvider provides Lightsail instances and managed databases:
+//
+//	instances/<name>/info.json  the instance: blueprint, bundle, addresses, networking
+//	instances/<name>/state      its state, e.g. running or stopped
+//	databases/<name>/info.json  the database: engine, bundle, endpoint, backups
+//	databases/<name>/state      its state, e.g. available or backing-up
+type LightsailProvider struct {
+	ReadOnlyProvider
+	client *restJSONClient
+	lists  *documents[cappedList[map[string]map[string]any]] // resources by name, under the kind
+}
+
+// lightsailKind is a kind of Lightsail resource listed as a directory
+type lightsailKind struct {
+	listOp   string // e.g. GetInstances
+	listKey  string // the array of resources in its response
+	getOp    string // e.g. GetInstance
+	nameKey  string // the name parameter of getOp
+	getKey   string // the resource in its response
+	listHint string
+}
+
+var lightsailKinds = map[string]lightsailKind{
+	"instances": {
+		listOp: "GetInstances", listKey: "instances",
+		getOp: "GetInstance", nameKey: "instanceName", getKey: "instance",
+		listHint: "aws lightsail get-instances",
+	},
+	"databases": {
+		listOp: "GetRelationalDatabases", listKey: "relationalDatabases",
+		getOp: "GetRelationalDatabase", nameKey: "relationalDatabaseName", getKey: "relationalDatabase",
+		listHint: "aws lightsail get-relational-databases",
+	},
+}
+
+// Files of a Lightsail resource directory
+const (
+	lightsailInfoFile  = "info.json"
+	lightsailStateFile = "state"
+)
+
+// NewLightsailProvider creates a new Lightsail provider
+func NewLightsailProvider(profile, region string) (*LightsailProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newLightsailProvider(cfg), nil
+}
+
+func newLightsailProvider(cfg aws.Config) *LightsailProvider {
+	client := newJSONRPCClient(cfg, "Lightsail", "lightsail", "Lightsail_20161128")
+	client.jsonVersion = "1.1"
+	return &LightsailProvider{
+		client: client,
+		lists:  newDocuments[cappedList[map[string]map[string]any]](),
+	}
+}
+
+func (p *LightsailProvider) Name() string {
+	return "lightsail"
+}
+
+// Invalidate forgets the memoized resource lists, so a refresh describes them anew
+func (p *LightsailProvider) Invalidate(string) {
+	forgetDocuments(p.lists)
+}
+
+func (p *LightsailProvider) Close() {
+	closeDocuments(p.lists)
+}
+
+// list returns the region's resources of a kind by name, up to
+// MaxEntries of them
+func (p *LightsailProvider) list(ctx context.Context, kind string) (cappedList[map[string]map[string]any], error) {
+	k := lightsailKinds[kind]
+	return p.lists.get(kind, func() (cappedList[map[string]map[string]any], error) {
+		resources := make(map[string]map[string]any)
+		in := map[string]any{}
+		for {
+			var resp map[string]json.RawMessage
+			if err := p.client.call(ctx, k.listOp, in, &resp); err != nil {
+				return cappedList[map[string]map[string]any]{}, err
+			}
+			var page []map[string]any
+			if raw, ok := resp[k.listKey]; ok {
+				if err := json.Unmarshal(raw, &page); err != nil {
+					return cappedList[map[string]map[string]any]{}, err
+				}
+			}
+			for _, r := range page {
+				if name, ok := r["name"].(string); ok {
+					resources[name] = r
+				}
+			}
+			var token string
+			if raw, ok := resp["nextPageToken"]; ok {
+				json.Unmarshal(raw, &token)
+			}
+			if token == "" || len(resources) >= MaxEntries {
+				return cappedList[map[string]map[string]any]{items: resources, more: token != ""}, nil
+			}
+			in = map[string]any{"pageToken": token}
+		}
+	})
+}
+
+// resource returns the named resource of a kind as just described
+func (p *LightsailProvider) resource(ctx context.Context, kind, name string) (map[string]any, error) {
+	k := lightsailKinds[kind]
+	var resp map[string]map[string]any
+	if err := p.client.call(ctx, k.getOp, map[string]any{k.nameKey: name}, &resp); err != nil {
+		if isAPIError(err, "NotFoundException") {
+			return nil, fmt.Errorf("%s not found: %s: %w", kind, name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	return jsonTimes(resp[k.getKey]), nil
+}
+
+// checkResource returns an error wrapping os.ErrNotExist unless the named
+// resource of a kind is listed, or exists past a truncated listing
+func (p *LightsailProvider) checkResource(ctx context.Context, kind, name string) error {
+	if _, ok := lightsailKinds[kind]; !ok {
+		return fmt.Errorf("unknown path: %s: %w", kind, os.ErrNotExist)
+	}
+	if name == "" {
+		return nil
+	}
+	resources, err := p.list(ctx, kind)
+	if err != nil {
+		return err
+	}
+	if _, ok := resources.items[name]; ok {
+		return nil
+	}
+	if resources.more {
+		_, err := p.resource(ctx, kind, name)
+		return err
+	}
+	return fmt.Errorf("%s not found: %s: %w", kind, name, os.ErrNotExist)
+}
+
+// lightsailState returns the state of a resource: instances have a code
+// and name, databases just a name
+func lightsailState(r map[string]any) []byte {
+	switch state := r["state"].(type) {
+	case map[string]any:
+		return fmt.Appendf(nil, "%v\n", state["name"])
+	case string:
+		return []byte(state + "\n")
+	}
+	return []byte("unknown\n")
+}
+
+func (p *LightsailProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		return []Entry{
+			{Name: "databases", IsDir: true},
+			{Name: "instances", IsDir: true},
+		}, nil
+	}
+	parts := strings.Split(path, "/")
+	if err := p.checkResource(ctx, parts[0], ""); err != nil {
+		return nil, err
+	}
+	switch len(parts) {
+	case 1:
+		resources, err := p.list(ctx, parts[0])
+		if err != nil {
+			return nil, partialListing(nil, lightsailKinds[parts[0]].listHint, err)
+		}
+		entries := make([]Entry, 0, len(resources.items))
+		for name := range resources.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, resources.more, lightsailKinds[parts[0]].listHint), nil
+	case 2:
+		if err := p.checkResource(ctx, parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+		return []Entry{
+			{Name: lightsailInfoFile, IsDir: false, Size: 4096},
+			{Name: lightsailStateFile, IsDir: false, Size: 4096},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown path: %s", path)
+}
+
+func (p *LightsailProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	files, err := p.Prefetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := files[path]
+	if !ok {
+		return nil, fmt.Errorf("unknown file: %s", path)
+	}
+	return data, nil
+}
+
+// Prefetch returns both files of a resource from its one description
+func (p *LightsailProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if _, ok := lightsailKinds[parts[0]]; !ok {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	r, err := p.resource(ctx, parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	info, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	dir := parts[0] + "/" + parts[1] + "/"
+	return map[string][]byte{
+		dir + lightsailInfoFile:  info,
+		dir + lightsailStateFile: lightsailState(r),
+	}, nil
+}
+
+func (p *LightsailProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "lightsail", IsDir: true}, nil
+	}
+	parts := strings.Split(path, "/")
+	name := ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if err := p.checkResource(ctx, parts[0], name); err != nil {
+		return nil, err
+	}
+	switch len(parts) {
+	case 1, 2:
+		return &Entry{Name: parts[len(parts)-1], IsDir: true}, nil
+	case 3:
+		if parts[2] == lightsailInfoFile || parts[2] == lightsailStateFile {
+			return &Entry{Name: parts[2], IsDir: false, Size: 4096}, nil
+		}
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
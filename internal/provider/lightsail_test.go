@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLightsailInstances(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "lightsail")
+	p := newLightsailProvider(cfg)
+	ctx := context.Background()
+
+	data, err := p.Read(ctx, "instances/blog/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "lightsail/instance.json", data)
+	if state, _ := p.Read(ctx, "instances/blog/state"); string(state) != "running\n" {
+		t.Errorf("state = %q", state)
+	}
+}
+
+func TestLightsailInstancesTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 1
+	cfg, _ := fixtureConfig(t, "lightsail")
+	p := newLightsailProvider(cfg)
+	ctx := context.Background()
+
+	if _, err := p.ReadDir(ctx, "instances"); err != nil {
+		t.Fatal(err)
+	}
+	// Instances of the first page are still described
+	if _, err := p.ReadDir(ctx, "instances/blog"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLightsailDatabases(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "lightsail")
+	p := newLightsailProvider(cfg)
+	ctx := context.Background()
+
+	databases, err := p.ReadDir(ctx, "databases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(databases); !reflect.DeepEqual(names, []string{"blog-db"}) {
+		t.Fatalf("databases = %v", names)
+	}
+	data, err := p.Read(ctx, "databases/blog-db/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "lightsail/database.json", data)
+	if state, _ := p.Read(ctx, "databases/blog-db/state"); string(state) != "available\n" {
+		t.Errorf("state = %q", state)
+	}
+
+	if _, err := p.Read(ctx, "databases/gone/state"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Read of a deleted database = %v, want ErrNotExist", err)
+	}
+	if _, err := p.Stat(ctx, "databases/gone"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of an unlisted database = %v, want ErrNotExist", err)
+	}
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxEntries caps the number of entries returned by a single directory
+// listing. Listings collected with paging.Collect continue in a page
+// directory (see Paged); listings that can't be resumed, such as ones
+// served from an index or cached for lookups, end with a MoreResultsFile
+// marker instead, which the AccessDenied middleware serves.
+var MaxEntries = 1000
+
+// MoreResultsFile is the virtual file appended to truncated listings
+const MoreResultsFile = "_more_results.txt"
+
+// isMoreResults reports whether path refers to a truncation marker
+func isMoreResults(path string) bool {
+	return path == MoreResultsFile || strings.HasSuffix(path, "/"+MoreResultsFile)
+}
+
+// IsMarker reports whether name is a virtual file sisu adds to a listing
+// to describe it, e.g. a truncation or access-denied notice, rather than
+// one of its resources
+func IsMarker(name string) bool {
+	switch name {
+	case MoreResultsFile, WarningFile, AccessDeniedFile, ErrorFile:
+		return true
+	}
+	return false
+}
+
+// moreResultsMessage explains a truncated listing; hint is the AWS CLI
+// command that produces the full listing
+func moreResultsMessage(hint string) string {
+	return fmt.Sprintf("Showing first %d entries. There are more results not displayed.\n"+
+		"Use AWS CLI for full listing: %s\n", MaxEntries, hint)
+}
+
+// moreResultsEntry returns the directory entry for a truncation marker
+func moreResultsEntry(hint string) Entry {
+	return Entry{
+		Name:     MoreResultsFile,
+		Size:     int64(len(moreResultsMessage(hint))),
+		moreHint: hint,
+	}
+}
+
+// cappedList is a listing fetched for lookups and stopped at MaxEntries,
+// with whether pages were left unfetched, so the directory it is shown as
+// can end with a MoreResultsFile (see capEntries)
+type cappedList[T any] struct {
+	items T
+	more  bool
+}
+
+// capEntries trims entries to MaxEntries and appends the truncation marker
+// if anything was cut off or more pages were left unfetched
+func capEntries(entries []Entry, more bool, hint string) []Entry {
+	if len(entries) > MaxEntries {
+		entries = entries[:MaxEntries]
+		more = true
+	}
+	if more {
+		entries = append(entries, moreResultsEntry(hint))
+	}
+	return entries
+}
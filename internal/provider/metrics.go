@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OpStats holds counters for a single operation type
+type OpStats struct {
+	Calls    int64         `json:"calls"`
+	Errors   int64         `json:"errors"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Metrics collects per-operation call counts and latencies
+type Metrics struct {
+	mu  sync.Mutex
+	ops map[Op]*OpStats
+}
+
+// NewMetrics creates an empty metrics collector
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[Op]*OpStats)}
+}
+
+// Middleware returns a middleware that records every call into m
+func (m *Metrics) Middleware() Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		m.record(op, time.Since(start), err)
+		return err
+	})
+}
+
+func (m *Metrics) record(op Op, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.ops[op]
+	if !ok {
+		s = &OpStats{}
+		m.ops[op] = s
+	}
+	s.Calls++
+	s.Duration += d
+	if err != nil {
+		s.Errors++
+	}
+}
+
+// Snapshot returns a copy of the current counters
+func (m *Metrics) Snapshot() map[Op]OpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[Op]OpStats, len(m.ops))
+	for op, s := range m.ops {
+		out[op] = *s
+	}
+	return out
+}
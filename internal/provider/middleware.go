@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Op identifies a provider operation
+type Op string
+
+const (
+	OpReadDir Op = "readdir"
+	OpRead    Op = "read"
+	OpStat    Op = "stat"
+	OpWrite   Op = "write"
+	OpDelete  Op = "delete"
+)
+
+// IsMutation reports whether the operation changes remote state
+func (op Op) IsMutation() bool {
+	return op == OpWrite || op == OpDelete
+}
+
+// Middleware decorates a provider with cross-cutting behavior
+type Middleware func(Provider) Provider
+
+// Chain wraps p with the given middleware. The first middleware is the
+// outermost, so Chain(p, a, b) handles a call as a -> b -> p.
+func Chain(p Provider, mws ...Middleware) Provider {
+	for i := len(mws) - 1; i >= 0; i-- {
+		p = mws[i](p)
+	}
+	return p
+}
+
+// Interceptor runs around a single provider call. It must invoke call
+// (possibly several times, or not at all) and return its error.
+type Interceptor func(ctx context.Context, op Op, path string, call func(context.Context) error) error
+
+// Intercept returns a middleware that routes every provider call through fn
+func Intercept(fn Interceptor) Middleware {
+	return func(p Provider) Provider {
+		return &interceptProvider{Provider: p, fn: fn}
+	}
+}
+
+// interceptProvider adapts an Interceptor to the Provider interface
+type interceptProvider struct {
+	Provider
+	fn Interceptor
+}
+
+func (p *interceptProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	var entries []Entry
+	err := p.fn(ctx, OpReadDir, path, func(ctx context.Context) error {
+		var err error
+		entries, err = p.Provider.ReadDir(ctx, path)
+		return err
+	})
+	return entries, err
+}
+
+func (p *interceptProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	var data []byte
+	err := p.fn(ctx, OpRead, path, func(ctx context.Context) error {
+		var err error
+		data, err = p.Provider.Read(ctx, path)
+		return err
+	})
+	return data, err
+}
+
+// ReadRange is intercepted as a read, so partial reads get read timeouts and metrics
+func (p *interceptProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	var data []byte
+	err := p.fn(ctx, OpRead, path, func(ctx context.Context) error {
+		var err error
+		data, err = ReadRange(ctx, p.Provider, path, off, length)
+		return err
+	})
+	return data, err
+}
+
+// Prefetch is intercepted as a read of path, since it costs a single call
+func (p *interceptProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	var files map[string][]byte
+	err := p.fn(ctx, OpRead, path, func(ctx context.Context) error {
+		var err error
+		files, err = Prefetch(ctx, p.Provider, path)
+		return err
+	})
+	return files, err
+}
+
+func (p *interceptProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	var entry *Entry
+	err := p.fn(ctx, OpStat, path, func(ctx context.Context) error {
+		var err error
+		entry, err = p.Provider.Stat(ctx, path)
+		return err
+	})
+	return entry, err
+}
+
+// StatBatch is intercepted as a stat of the first path
+func (p *interceptProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	var entries map[string]*Entry
+	err := p.fn(ctx, OpStat, paths[0], func(ctx context.Context) error {
+		var err error
+		entries, err = StatBatch(ctx, p.Provider, paths)
+		return err
+	})
+	return entries, err
+}
+
+func (p *interceptProvider) Write(ctx context.Context, path string, data []byte) error {
+	return p.fn(ctx, OpWrite, path, func(ctx context.Context) error {
+		return p.Provider.Write(ctx, path, data)
+	})
+}
+
+// Invalidate and Close aren't intercepted, as they make no call
+func (p *interceptProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *interceptProvider) Close() {
+	Close(p.Provider)
+}
+
+// Written isn't intercepted, as it makes no call
+func (p *interceptProvider) Written(path string, data []byte) ([]byte, bool) {
+	return Written(p.Provider, path, data)
+}
+
+func (p *interceptProvider) Delete(ctx context.Context, path string) error {
+	return p.fn(ctx, OpDelete, path, func(ctx context.Context) error {
+		return p.Provider.Delete(ctx, path)
+	})
+}
+
+// Logging logs every provider call with its duration and error when Debug is set
+func Logging() Middleware {
+	return func(p Provider) Provider {
+		name := p.Name()
+		return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+			if !Debug {
+				return call(ctx)
+			}
+			start := time.Now()
+			err := call(ctx)
+			if err != nil {
+				log.Printf("[%s] %s %q failed after %s: %v", name, op, path, time.Since(start), err)
+			} else {
+				log.Printf("[%s] %s %q took %s", name, op, path, time.Since(start))
+			}
+			return err
+		})(p)
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+			order = append(order, name)
+			return call(ctx)
+		})
+	}
+
+	p := Chain(newFakeProvider(map[string][]byte{"x": nil}), mark("outer"), mark("inner"))
+	if _, err := p.Stat(context.Background(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("order = %v, want [outer inner]", order)
+	}
+}
+
+func TestTimeoutSetsDeadline(t *testing.T) {
+	var deadline time.Time
+	probe := Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		deadline, _ = ctx.Deadline()
+		return call(ctx)
+	})
+
+	p := Chain(newFakeProvider(map[string][]byte{"x": nil}), Timeout(Timeouts{Stat: time.Minute}), probe)
+	p.Stat(context.Background(), "x")
+	if deadline.IsZero() || time.Until(deadline) > time.Minute {
+		t.Errorf("deadline = %v, want within one minute", deadline)
+	}
+}
+
+func TestMetricsCountsCalls(t *testing.T) {
+	m := NewMetrics()
+	p := Chain(newFakeProvider(map[string][]byte{"x": nil}), m.Middleware())
+	ctx := context.Background()
+
+	p.Read(ctx, "x")
+	p.Read(ctx, "missing")
+
+	s := m.Snapshot()[OpRead]
+	if s.Calls != 2 || s.Errors != 1 {
+		t.Errorf("read stats = %+v, want 2 calls and 1 error", s)
+	}
+}
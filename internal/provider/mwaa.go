@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// MWAAProvider provides Managed Workflows for Apache Airflow environments
+// as directories:
+//
+//	<environment>/info.json         the environment: Airflow version, class, configuration options, network
+//	<environment>/last-update.json  the status of its last update and any error
+type MWAAProvider struct {
+	ReadOnlyProvider
+	client       *restJSONClient
+	environments *documents[cappedList[[]string]] // environment names, under ""
+	describe     *documents[map[string]any]       // environments by name
+}
+
+// Files of an MWAA environment directory
+const (
+	mwaaInfoFile       = "info.json"
+	mwaaLastUpdateFile = "last-update.json"
+)
+
+// NewMWAAProvider creates a new MWAA provider
+func NewMWAAProvider(profile, region string) (*MWAAProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newMWAAProvider(cfg), nil
+}
+
+func newMWAAProvider(cfg aws.Config) *MWAAProvider {
+	return &MWAAProvider{
+		client:       newRESTJSONClient(cfg, "MWAA", "airflow"),
+		environments: newDocuments[cappedList[[]string]](),
+		describe:     newDocuments[map[string]any](),
+	}
+}
+
+func (p *MWAAProvider) Name() string {
+	return "mwaa"
+}
+
+// Invalidate forgets the memoized environments, so a refresh describes them anew
+func (p *MWAAProvider) Invalidate(string) {
+	forgetDocuments(p.environments, p.describe)
+}
+
+func (p *MWAAProvider) Close() {
+	closeDocuments(p.environments, p.describe)
+}
+
+// listEnvironments returns the names of the region's environments, up to
+// MaxEntries of them
+func (p *MWAAProvider) listEnvironments(ctx context.Context) (cappedList[[]string], error) {
+	return p.environments.get("", func() (cappedList[[]string], error) {
+		var names []string
+		query := url.Values{}
+		for {
+			var resp struct {
+				Environments []string
+				NextToken    string
+			}
+			if err := p.client.do(ctx, "ListEnvironments", "GET", "/environments", query, nil, &resp); err != nil {
+				return cappedList[[]string]{}, err
+			}
+			names = append(names, resp.Environments...)
+			if resp.NextToken == "" || len(names) >= MaxEntries {
+				return cappedList[[]string]{items: names, more: resp.NextToken != ""}, nil
+			}
+			query.Set("NextToken", resp.NextToken)
+		}
+	})
+}
+
+// environment returns the description of the named environment
+func (p *MWAAProvider) environment(ctx context.Context, name string) (map[string]any, error) {
+	return p.describe.get(name, func() (map[string]any, error) {
+		var resp struct {
+			Environment map[string]any
+		}
+		if err := p.client.do(ctx, "GetEnvironment", "GET", "/environments/"+url.PathEscape(name), nil, nil, &resp); err != nil {
+			if isAPIError(err, "ResourceNotFoundException") {
+				return nil, fmt.Errorf("environment not found: %s: %w", name, os.ErrNotExist)
+			}
+			return nil, err
+		}
+		if update, ok := resp.Environment["LastUpdate"].(map[string]any); ok {
+			jsonTimes(update)
+		}
+		return jsonTimes(resp.Environment), nil
+	})
+}
+
+// checkEnvironment returns an error wrapping os.ErrNotExist unless the
+// environment is listed, or found past a truncated listing
+func (p *MWAAProvider) checkEnvironment(ctx context.Context, name string) error {
+	names, err := p.listEnvironments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range names.items {
+		if n == name {
+			return nil
+		}
+	}
+	if names.more {
+		_, err := p.environment(ctx, name)
+		return err
+	}
+	return fmt.Errorf("environment not found: %s: %w", name, os.ErrNotExist)
+}
+
+func (p *MWAAProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		names, err := p.listEnvironments(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws mwaa list-environments", err)
+		}
+		entries := make([]Entry, 0, len(names.items))
+		for _, name := range names.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, names.more, "aws mwaa list-environments"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if err := p.checkEnvironment(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: mwaaInfoFile, IsDir: false, Size: 4096},
+		{Name: mwaaLastUpdateFile, IsDir: false, Size: 4096},
+	}, nil
+}
+
+func (p *MWAAProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	files, err := p.Prefetch(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := files[path]
+	if !ok {
+		return nil, fmt.Errorf("unknown file: %s", path)
+	}
+	return data, nil
+}
+
+// Prefetch returns both files of an environment from its one description
+func (p *MWAAProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	name, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	if err := p.checkEnvironment(ctx, name); err != nil {
+		return nil, err
+	}
+	env, err := p.environment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	update, ok := env["LastUpdate"]
+	if !ok {
+		update = map[string]any{}
+	}
+	lastUpdate, err := json.MarshalIndent(update, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		name + "/" + mwaaInfoFile:       info,
+		name + "/" + mwaaLastUpdateFile: lastUpdate,
+	}, nil
+}
+
+func (p *MWAAProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "mwaa", IsDir: true}, nil
+	}
+	name, file, _ := strings.Cut(path, "/")
+	if err := p.checkEnvironment(ctx, name); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: name, IsDir: true}, nil
+	case mwaaInfoFile, mwaaLastUpdateFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
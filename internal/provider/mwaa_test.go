@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMWAAEnvironments(t *testing.T) {
+	cfg, client := fixtureConfig(t, "mwaa")
+	p := newMWAAProvider(cfg)
+	ctx := context.Background()
+
+	envs, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(envs); !reflect.DeepEqual(names, []string{"prod-airflow", "staging-airflow"}) {
+		t.Fatalf("environments = %v", names)
+	}
+
+	files, err := Prefetch(ctx, p, "prod-airflow/last-update.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "mwaa/info.json", files["prod-airflow/info.json"])
+	assertGolden(t, "mwaa/last-update.json", files["prod-airflow/last-update.json"])
+	// Both files come from one description
+	if want := []string{"ListEnvironments", "GetEnvironment"}; !reflect.DeepEqual(client.Calls(), want) {
+		t.Errorf("calls = %v, want %v", client.Calls(), want)
+	}
+}
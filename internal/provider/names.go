@@ -0,0 +1,20 @@
+package provider
+
+import "strings"
+
+// slashSubstitute stands in for '/' in resource names used as a single
+// filename. It is the fullwidth solidus, which looks like a slash but
+// isn't a path separator.
+const slashSubstitute = "／"
+
+// escapeSlash returns a free-form resource name (an alarm, saved query or
+// group name) usable as one path segment, with each '/' replaced by
+// slashSubstitute. unescapeSlash reverses it.
+func escapeSlash(name string) string {
+	return strings.ReplaceAll(name, "/", slashSubstitute)
+}
+
+// unescapeSlash returns the resource name of a filename made by escapeSlash
+func unescapeSlash(filename string) string {
+	return strings.ReplaceAll(filename, slashSubstitute, "/")
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// Paged returns a middleware serving the page directories of listings cut
+// off at MaxEntries by paging.Collect: a listing that stopped early ends
+// with a "_page2" directory, listed by resuming where it stopped. Paths
+// inside page directories address the same resources as without them, so
+// "bucket/_page2/key" reads "bucket/key". Where each page starts is kept
+// in cursors.
+func Paged(cursors *paging.Cursors) Middleware {
+	return func(p Provider) Provider {
+		return &pagedProvider{Provider: p, cursors: cursors}
+	}
+}
+
+type pagedProvider struct {
+	Provider
+	cursors *paging.Cursors
+}
+
+func (p *pagedProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	dir, n := paging.Split(path)
+	start, ok := p.cursors.Start(dir, n)
+	if !ok {
+		// Listing the page before finds where this one starts
+		if _, err := p.ReadDir(ctx, paging.Join(dir, n-1)); err != nil {
+			return nil, err
+		}
+		if start, ok = p.cursors.Start(dir, n); !ok {
+			return nil, fs.ErrNotExist
+		}
+	}
+
+	cursor := &paging.Cursor{Start: start}
+	entries, err := p.Provider.ReadDir(paging.WithCursor(ctx, cursor), dir)
+	if err != nil {
+		return nil, err
+	}
+	p.cursors.Record(dir, n, cursor.Next)
+	if cursor.Next != "" {
+		entries = append(entries, Entry{Name: paging.Name(n + 1), IsDir: true})
+	}
+	return entries, nil
+}
+
+func (p *pagedProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	return p.Provider.Read(ctx, paging.Strip(path))
+}
+
+func (p *pagedProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	return ReadRange(ctx, p.Provider, paging.Strip(path), off, length)
+}
+
+// Prefetch returns the siblings of a file in a page directory under their
+// paths in that directory
+func (p *pagedProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	stripped := paging.Strip(path)
+	files, err := Prefetch(ctx, p.Provider, stripped)
+	if err != nil || stripped == path {
+		return files, err
+	}
+	dir, _ := splitParent(path)
+	strippedDir, _ := splitParent(stripped)
+	out := make(map[string][]byte, len(files))
+	for name, data := range files {
+		if rest, ok := strings.CutPrefix(name, strippedDir+"/"); ok && !strings.Contains(rest, "/") {
+			name = joinPath(dir, rest)
+		}
+		out[name] = data
+	}
+	return out, nil
+}
+
+// Stat reports page directories as directories if the listing before them
+// shows them
+func (p *pagedProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if _, name := splitParent(path); paging.Page(name) > 0 {
+		dir, n := paging.Split(path)
+		if _, ok := p.cursors.Start(dir, n); !ok {
+			if _, err := p.ReadDir(ctx, paging.Join(dir, n-1)); err != nil {
+				return nil, err
+			}
+			if _, ok := p.cursors.Start(dir, n); !ok {
+				return nil, fs.ErrNotExist
+			}
+		}
+		return &Entry{Name: name, IsDir: true}, nil
+	}
+	return p.Provider.Stat(ctx, paging.Strip(path))
+}
+
+func (p *pagedProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	out := make(map[string]*Entry, len(paths))
+	byStripped := make(map[string]string, len(paths))
+	var rest []string
+	for _, path := range paths {
+		if _, name := splitParent(path); paging.Page(name) > 0 {
+			if entry, err := p.Stat(ctx, path); err == nil {
+				out[path] = entry
+			}
+			continue
+		}
+		stripped := paging.Strip(path)
+		byStripped[stripped] = path
+		rest = append(rest, stripped)
+	}
+	if len(rest) == 0 {
+		return out, nil
+	}
+	entries, err := StatBatch(ctx, p.Provider, rest)
+	if err != nil {
+		return nil, err
+	}
+	for stripped, entry := range entries {
+		if path, ok := byStripped[stripped]; ok {
+			out[path] = entry
+		} else {
+			out[stripped] = entry
+		}
+	}
+	return out, nil
+}
+
+func (p *pagedProvider) Write(ctx context.Context, path string, data []byte) error {
+	return p.Provider.Write(ctx, paging.Strip(path), data)
+}
+
+func (p *pagedProvider) Invalidate(path string) {
+	Invalidate(p.Provider, paging.Strip(path))
+}
+
+func (p *pagedProvider) Close() {
+	Close(p.Provider)
+}
+
+func (p *pagedProvider) Written(path string, data []byte) ([]byte, bool) {
+	return Written(p.Provider, paging.Strip(path), data)
+}
+
+func (p *pagedProvider) Delete(ctx context.Context, path string) error {
+	return p.Provider.Delete(ctx, paging.Strip(path))
+}
+
+func (p *pagedProvider) Writable(path string) bool {
+	return p.Provider.Writable(paging.Strip(path))
+}
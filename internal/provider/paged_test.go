@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// pagingFake lists the fake's files one per API page
+type pagingFake struct {
+	*fakeProvider
+}
+
+func (p pagingFake) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	all, err := p.fakeProvider.ReadDir(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		i, _ := strconv.Atoi(token)
+		if i+1 >= len(all) {
+			return all[i:], "", nil
+		}
+		return all[i : i+1], strconv.Itoa(i + 1), nil
+	})
+	return entries, err
+}
+
+func entryNames(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+func TestPagedListsPageDirectories(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+
+	fake := newFakeProvider(map[string][]byte{
+		"b/1": []byte("one"), "b/2": nil, "b/3": nil, "b/4": nil, "b/5": []byte("five"),
+	})
+	p := Chain(pagingFake{fake}, Paged(paging.NewCursors()))
+	ctx := context.Background()
+
+	// Page 3 is found by listing the pages before it
+	entries, err := p.ReadDir(ctx, "b/_page3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryNames(entries); len(got) != 1 || got[0] != "5" {
+		t.Fatalf("ReadDir(b/_page3) = %v, want [5]", got)
+	}
+	entries, err = p.ReadDir(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryNames(entries); len(got) != 3 || got[2] != "_page2" {
+		t.Fatalf("ReadDir(b) = %v, want [1 2 _page2]", got)
+	}
+
+	if entry, err := p.Stat(ctx, "b/_page2"); err != nil || !entry.IsDir {
+		t.Errorf("Stat(b/_page2) = %+v, %v", entry, err)
+	}
+	if _, err := p.Stat(ctx, "b/_page4"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(b/_page4) error = %v, want not exist", err)
+	}
+	if data, err := p.Read(ctx, "b/_page3/5"); err != nil || string(data) != "five" {
+		t.Errorf("Read(b/_page3/5) = %q, %v", data, err)
+	}
+}
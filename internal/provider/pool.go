@@ -0,0 +1,41 @@
+package provider
+
+import "context"
+
+// ProfilePoolSize is the default number of calls a profile's providers
+// can have in flight at once
+const ProfilePoolSize = 16
+
+// Pool bounds the calls in flight across every provider sharing it. Giving
+// each profile its own pool keeps a profile whose calls hang (e.g. broken
+// SSO) from tying up more than its own slots.
+type Pool struct {
+	slots chan struct{}
+}
+
+// NewPool returns a pool running at most size calls at once
+func NewPool(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Middleware returns a middleware that runs each call in a slot of the
+// pool, waiting for one to free up until ctx is done
+func (p *Pool) Middleware() Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-p.slots }()
+		return call(ctx)
+	})
+}
+
+// InFlight returns the number of calls currently holding a slot
+func (p *Pool) InFlight() int {
+	return len(p.slots)
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolWaitsForSlot(t *testing.T) {
+	pool := NewPool(1)
+	release := make(chan struct{})
+	slow := pool.Middleware()(&blockingProvider{fakeProvider: newFakeProvider(map[string][]byte{"a": []byte("1")}), release: release})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := slow.Read(context.Background(), "a")
+		done <- err
+	}()
+	for pool.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Read(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Read with the pool full = %v, want deadline exceeded", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if pool.InFlight() != 0 {
+		t.Errorf("%d slots held after calls returned", pool.InFlight())
+	}
+}
+
+func TestPoolsAreIndependent(t *testing.T) {
+	stuck, other := NewPool(1), NewPool(1)
+	release := make(chan struct{})
+	defer close(release)
+	blocked := stuck.Middleware()(&blockingProvider{fakeProvider: newFakeProvider(map[string][]byte{"a": []byte("1")}), release: release})
+	go blocked.Read(context.Background(), "a")
+	for stuck.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p := other.Middleware()(newFakeProvider(map[string][]byte{"a": []byte("1")}))
+	if data, err := p.Read(ctx, "a"); err != nil || string(data) != "1" {
+		t.Errorf("Read in another pool = %q, %v", data, err)
+	}
+}
+
+// blockingProvider serves reads only once release is closed
+type blockingProvider struct {
+	*fakeProvider
+	release chan struct{}
+}
+
+func (p *blockingProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.fakeProvider.Read(ctx, path)
+}
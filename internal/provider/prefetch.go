@@ -0,0 +1,31 @@
+package provider
+
+import "context"
+
+// Prefetcher is implemented by providers whose files come in groups from a
+// single API call, e.g. every file of an EC2 instance from one
+// DescribeInstances. Prefetch returns the content of path together with
+// the siblings fetched alongside it, keyed by path.
+type Prefetcher interface {
+	Prefetch(ctx context.Context, path string) (map[string][]byte, error)
+}
+
+// Prefetch reads path and, when p is a Prefetcher, its siblings from the
+// same call. The result always contains path. Decorators that don't
+// implement Prefetcher (e.g. session recording) therefore see an ordinary Read.
+func Prefetch(ctx context.Context, p Provider, path string) (map[string][]byte, error) {
+	if pf, ok := p.(Prefetcher); ok {
+		files, err := pf.Prefetch(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := files[path]; ok {
+			return files, nil
+		}
+	}
+	data, err := p.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{path: data}, nil
+}
@@ -12,6 +12,17 @@ type Entry struct {
 	IsDir   bool
 	Size    int64
 	ModTime time.Time
+	// Checksums are content digests reported by the service, keyed by
+	// algorithm ("etag", "md5", "sha256"); only set where Stat gets them
+	// for free
+	Checksums map[string]string `json:",omitempty"`
+	// Link makes the entry a symlink to this target, relative to the
+	// entry's directory
+	Link string `json:",omitempty"`
+
+	// moreHint is the full listing command of a MoreResultsFile marker
+	// made by moreResultsEntry
+	moreHint string
 }
 
 // Provider defines the interface for AWS resource providers
@@ -33,6 +44,10 @@ type Provider interface {
 
 	// Delete removes a file (optional, can return fs.ErrPermission)
 	Delete(ctx context.Context, path string) error
+
+	// Writable reports whether the provider supports writes at path.
+	// For a directory this means files can be created inside it.
+	Writable(path string) bool
 }
 
 // ReadOnlyProvider provides a base implementation that returns permission errors for writes
@@ -45,3 +60,7 @@ func (p *ReadOnlyProvider) Write(ctx context.Context, path string, data []byte)
 func (p *ReadOnlyProvider) Delete(ctx context.Context, path string) error {
 	return fs.ErrPermission
 }
+
+func (p *ReadOnlyProvider) Writable(path string) bool {
+	return false
+}
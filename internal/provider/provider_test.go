@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/semonte/sisu/internal/provider/fixture"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with current output")
+
+// fixtureConfig returns an AWS config that replays testdata/fixtures/<name>.yaml
+func fixtureConfig(t *testing.T, name string) (aws.Config, *fixture.Client) {
+	t.Helper()
+	c, err := fixture.Load(filepath.Join("testdata", "fixtures", name+".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fixture.NewClient(c)
+	return fixture.Config(client), client
+}
+
+// assertGolden compares got against testdata/golden/<name>, rewriting it with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run go test -update): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s mismatch\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+	}
+}
+
+// assertGoldenEntries compares a listing against a golden JSON file
+func assertGoldenEntries(t *testing.T, name string, entries []Entry) {
+	t.Helper()
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, name, append(data, '\n'))
+}
+
+// fakeProvider is an in-memory provider that counts calls
+type fakeProvider struct {
+	ReadOnlyProvider
+	files map[string][]byte
+	calls map[Op]int
+	fail  error
+}
+
+func newFakeProvider(files map[string][]byte) *fakeProvider {
+	return &fakeProvider{files: files, calls: make(map[Op]int)}
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	p.calls[OpReadDir]++
+	if p.fail != nil {
+		return nil, p.fail
+	}
+	var entries []Entry
+	for name, data := range p.files {
+		if filepath.Dir(name) == path || (path == "" && filepath.Dir(name) == ".") {
+			entries = append(entries, Entry{Name: filepath.Base(name), Size: int64(len(data))})
+		}
+	}
+	return entries, nil
+}
+
+func (p *fakeProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	p.calls[OpRead]++
+	if p.fail != nil {
+		return nil, p.fail
+	}
+	data, ok := p.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (p *fakeProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	p.calls[OpStat]++
+	data, ok := p.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &Entry{Name: filepath.Base(path), Size: int64(len(data))}, nil
+}
+
+func (p *fakeProvider) Write(ctx context.Context, path string, data []byte) error {
+	p.calls[OpWrite]++
+	p.files[path] = data
+	return nil
+}
@@ -0,0 +1,36 @@
+package provider
+
+import "context"
+
+// RangeReader is implemented by providers that can fetch part of a file
+// without downloading all of it (e.g. S3 ranged GETs). length may extend
+// past the end of the file; reads at or past the end return no data.
+type RangeReader interface {
+	ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error)
+}
+
+// ReadRange reads up to length bytes at off from path, using p's
+// RangeReader when available and otherwise slicing a full Read.
+// Decorators that don't implement RangeReader (e.g. session recording)
+// therefore see an ordinary Read.
+func ReadRange(ctx context.Context, p Provider, path string, off, length int64) ([]byte, error) {
+	if rr, ok := p.(RangeReader); ok {
+		return rr.ReadRange(ctx, path, off, length)
+	}
+	data, err := p.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, off, length), nil
+}
+
+func sliceRange(data []byte, off, length int64) []byte {
+	if off >= int64(len(data)) {
+		return nil
+	}
+	end := off + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return data[off:end]
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter caps the calls of every provider sharing it at a rate, so heavy
+// browsing (e.g. `grep -r` over a tree) can't exhaust API quotas shared
+// with other tooling in the account. AWS applies quotas per account and
+// service, so a profile's providers of one service share a limiter across
+// regions.
+type Limiter struct {
+	limiter *rate.Limiter
+}
+
+// NewLimiter returns a limiter allowing perSecond calls with the given
+// burst
+func NewLimiter(perSecond float64, burst int) *Limiter {
+	return &Limiter{limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+}
+
+// Middleware returns a middleware that waits for the limiter before each
+// call, until ctx is done
+func (l *Limiter) Middleware() Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		if err := l.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return call(ctx)
+	})
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrorFile is the virtual file listed at the top of a service once one of
+// its calls panicked, holding the last panic and its stack trace
+const ErrorFile = ".sisu-error"
+
+// ErrPanic is wrapped by the error returned from a call that panicked
+var ErrPanic = errors.New("provider panicked")
+
+// goroutinePanic carries a panic out of a goroutine a call started, such as
+// FanOut's, with the stack of the goroutine that panicked
+type goroutinePanic struct {
+	value any
+	stack []byte
+}
+
+// Recover returns a middleware that turns a panic in any provider call into
+// an error wrapping ErrPanic, which the mount reports as EIO, instead of
+// taking down the FUSE serve loop with it. The panic is logged with its
+// stack trace and the last one is kept in ErrorFile at the service's top.
+func Recover() Middleware {
+	return func(p Provider) Provider {
+		r := &recoverProvider{name: p.Name()}
+		r.Provider = Intercept(r.recover)(p)
+		return r
+	}
+}
+
+type recoverProvider struct {
+	Provider // every call recovered
+	name     string
+
+	mu   sync.Mutex
+	last *panicReport
+}
+
+type panicReport struct {
+	message string
+	at      time.Time
+}
+
+func (r *panicReport) entry() *Entry {
+	return &Entry{Name: ErrorFile, Size: int64(len(r.message)), ModTime: r.at}
+}
+
+func (r *recoverProvider) recover(ctx context.Context, op Op, path string, call func(context.Context) error) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		stack := debug.Stack()
+		if g, ok := v.(goroutinePanic); ok {
+			v, stack = g.value, g.stack
+		}
+		log.Printf("[%s] panic in %s %q: %v\n%s", r.name, op, path, v, stack)
+		now := time.Now()
+		r.mu.Lock()
+		r.last = &panicReport{
+			message: fmt.Sprintf("%s %q panicked at %s: %v\n\n%s", op, path, now.Format(time.RFC3339), v, stack),
+			at:      now,
+		}
+		r.mu.Unlock()
+		err = fmt.Errorf("%s %s %q: %w: %v", r.name, op, path, ErrPanic, v)
+	}()
+	return call(ctx)
+}
+
+// report returns the last panic if path is ErrorFile
+func (r *recoverProvider) report(path string) (*panicReport, bool) {
+	if path != ErrorFile {
+		return nil, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last, r.last != nil
+}
+
+func (r *recoverProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := r.Provider.ReadDir(ctx, path)
+	if path != "" {
+		return entries, err
+	}
+	rep, ok := r.report(ErrorFile)
+	switch {
+	case !ok:
+	case errors.Is(err, ErrPanic):
+		// A top listing that panicked still lists what went wrong
+		return []Entry{*rep.entry()}, nil
+	case err == nil:
+		entries = append(entries, *rep.entry())
+	}
+	return entries, err
+}
+
+func (r *recoverProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	if rep, ok := r.report(path); ok {
+		return []byte(rep.message), nil
+	}
+	return r.Provider.Read(ctx, path)
+}
+
+func (r *recoverProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	if rep, ok := r.report(path); ok {
+		return sliceRange([]byte(rep.message), off, length), nil
+	}
+	return ReadRange(ctx, r.Provider, path, off, length)
+}
+
+func (r *recoverProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	if rep, ok := r.report(path); ok {
+		return map[string][]byte{path: []byte(rep.message)}, nil
+	}
+	return Prefetch(ctx, r.Provider, path)
+}
+
+func (r *recoverProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if rep, ok := r.report(path); ok {
+		return rep.entry(), nil
+	}
+	return r.Provider.Stat(ctx, path)
+}
+
+func (r *recoverProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	rep, ok := r.report(ErrorFile)
+	rest := paths
+	if ok {
+		rest = make([]string, 0, len(paths))
+		for _, path := range paths {
+			if path != ErrorFile {
+				rest = append(rest, path)
+			}
+		}
+	}
+	entries := make(map[string]*Entry)
+	if len(rest) > 0 {
+		var err error
+		if entries, err = StatBatch(ctx, r.Provider, rest); err != nil {
+			return nil, err
+		}
+	}
+	if ok && len(rest) < len(paths) {
+		if entries == nil {
+			entries = make(map[string]*Entry)
+		}
+		entries[ErrorFile] = rep.entry()
+	}
+	return entries, nil
+}
+
+// Writable recovers too, as the mount asks it outside any call
+func (r *recoverProvider) Writable(path string) (ok bool) {
+	if _, isReport := r.report(path); isReport {
+		return false
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("[%s] panic in writable %q: %v\n%s", r.name, path, v, debug.Stack())
+			ok = false
+		}
+	}()
+	return r.Provider.Writable(path)
+}
+
+// Written recovers too, as the cache asks it outside any call
+func (r *recoverProvider) Written(path string, data []byte) (content []byte, ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("[%s] panic in written %q: %v\n%s", r.name, path, v, debug.Stack())
+			content, ok = nil, false
+		}
+	}()
+	return Written(r.Provider, path, data)
+}
+
+// Invalidate recovers too, as the cache calls it outside any call
+func (r *recoverProvider) Invalidate(path string) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("[%s] panic in invalidate %q: %v\n%s", r.name, path, v, debug.Stack())
+		}
+	}()
+	Invalidate(r.Provider, path)
+}
+
+func (r *recoverProvider) Close() {
+	Close(r.Provider)
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// panickingProvider panics reading "boom" and in every goroutine of a
+// fanned out read of "fan"
+type panickingProvider struct {
+	*fakeProvider
+}
+
+func (p *panickingProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	switch path {
+	case "boom":
+		var m map[string]int
+		m["x"]++
+	case "fan":
+		err := FanOut(ctx, 2, 4, func(ctx context.Context, i int) error {
+			panic("fanned out")
+		})
+		return nil, err
+	}
+	return p.fakeProvider.Read(ctx, path)
+}
+
+func TestRecoverTurnsPanicIntoError(t *testing.T) {
+	p := Chain(&panickingProvider{newFakeProvider(map[string][]byte{"a": []byte("1")})}, Recover())
+	ctx := context.Background()
+
+	if _, err := p.Read(ctx, "boom"); !errors.Is(err, ErrPanic) {
+		t.Fatalf("Read = %v, want ErrPanic", err)
+	}
+	if data, err := p.Read(ctx, "a"); err != nil || string(data) != "1" {
+		t.Errorf("Read after the panic = %q, %v", data, err)
+	}
+
+	entries, err := p.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed bool
+	for _, e := range entries {
+		listed = listed || e.Name == ErrorFile
+	}
+	if !listed {
+		t.Errorf("ReadDir = %+v, want %s listed", entries, ErrorFile)
+	}
+	data, err := p.Read(ctx, ErrorFile)
+	if err != nil || !strings.Contains(string(data), `read "boom"`) || !strings.Contains(string(data), "panickingProvider") {
+		t.Errorf("Read(%s) = %q, %v, want the panic and its stack", ErrorFile, data, err)
+	}
+	if entry, err := p.Stat(ctx, ErrorFile); err != nil || entry.Size != int64(len(data)) {
+		t.Errorf("Stat = %+v, %v", entry, err)
+	}
+}
+
+func TestRecoverCatchesFanOutPanics(t *testing.T) {
+	p := Chain(&panickingProvider{newFakeProvider(nil)}, Recover())
+
+	if _, err := p.Read(context.Background(), "fan"); !errors.Is(err, ErrPanic) || !strings.Contains(err.Error(), "fanned out") {
+		t.Errorf("Read = %v, want the goroutine's panic", err)
+	}
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+)
+
+// SecretReader is implemented by providers some of whose files are secret
+// values as a whole, such as SecureString parameters
+type SecretReader interface {
+	IsSecret(ctx context.Context, path string) (bool, error)
+}
+
+// redactWindow is how far a redacted range read reaches past each end of
+// the range, enough to see every pattern that overlaps it in full
+const redactWindow = 128
+
+// redactPatterns match values that look sensitive wherever they appear.
+// The last submatch of each is masked.
+var redactPatterns = []*regexp.Regexp{
+	// Access key IDs, keeping the prefix that tells long-term from temporary keys
+	regexp.MustCompile(`\b(?:AKIA|ASIA)([A-Z0-9]{16})\b`),
+	// Values of keys that name a secret, in JSON, YAML, ini and env files
+	regexp.MustCompile(`(?i)"?(?:aws_?)?(?:secret_?access_?key|session_?token|secret_?string|password|client_?secret)"?\s*[:=]\s*"?([^"\s,}]+)`),
+}
+
+// Redact returns a middleware that masks sensitive values in what is read:
+// the whole content of files secrets reports as secret, and access key IDs
+// and the values of secret-looking keys everywhere else. Masked bytes are
+// replaced one for one by '*', so sizes and offsets don't change. A
+// redacted mount is read-only, as saving a redacted file would write the
+// masks back. secrets may be nil; when it fails, the file is masked.
+func Redact(secrets SecretReader) Middleware {
+	return func(p Provider) Provider {
+		return &redactProvider{Provider: p, secrets: secrets}
+	}
+}
+
+type redactProvider struct {
+	Provider
+	secrets SecretReader
+}
+
+// secret reports whether the whole of path is to be masked
+func (p *redactProvider) secret(ctx context.Context, path string) bool {
+	if p.secrets == nil {
+		return false
+	}
+	secret, err := p.secrets.IsSecret(ctx, path)
+	return secret || err != nil
+}
+
+func (p *redactProvider) redact(ctx context.Context, path string, data []byte) []byte {
+	if p.secret(ctx, path) {
+		return maskSecret(data)
+	}
+	return RedactText(data)
+}
+
+func (p *redactProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := p.Provider.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return p.redact(ctx, path, data), nil
+}
+
+// ReadRange reads a little past both ends of the range so patterns cut by
+// it are still found
+func (p *redactProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	start := max(off-redactWindow, 0)
+	data, err := ReadRange(ctx, p.Provider, path, start, off-start+length+redactWindow)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(p.redact(ctx, path, data), off-start, length), nil
+}
+
+func (p *redactProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	files, err := Prefetch(ctx, p.Provider, path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(files))
+	for name, data := range files {
+		out[name] = p.redact(ctx, name, data)
+	}
+	return out, nil
+}
+
+func (p *redactProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	return StatBatch(ctx, p.Provider, paths)
+}
+
+func (p *redactProvider) Writable(path string) bool {
+	return false
+}
+
+func (p *redactProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *redactProvider) Close() {
+	Close(p.Provider)
+}
+
+// RedactText masks access key IDs and the values of secret-looking keys in data
+func RedactText(data []byte) []byte {
+	var out []byte
+	for _, re := range redactPatterns {
+		for _, m := range re.FindAllSubmatchIndex(data, -1) {
+			if out == nil {
+				out = bytes.Clone(data)
+			}
+			start, end := m[len(m)-2], m[len(m)-1]
+			for i := start; i < end; i++ {
+				out[i] = '*'
+			}
+		}
+		if out != nil {
+			data = out
+		}
+	}
+	return data
+}
+
+// maskSecret masks every byte of a secret value but its line breaks
+func maskSecret(data []byte) []byte {
+	out := bytes.Clone(data)
+	for i, b := range out {
+		if b != '\n' {
+			out[i] = '*'
+		}
+	}
+	return out
+}
@@ -0,0 +1,508 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format names a Renderer, e.g. in the render config
+type Format string
+
+const (
+	// FormatJSON shows documents as generated
+	FormatJSON Format = "json"
+	// FormatCompact shows documents on one line, arrays one element per
+	// line, for grep and line-oriented tools
+	FormatCompact Format = "compact"
+	// FormatYAML shows documents as YAML; writes are converted back to JSON
+	FormatYAML Format = "yaml"
+	// FormatMarkdown shows documents as read-only Markdown summaries
+	FormatMarkdown Format = "markdown"
+	// FormatTable shows documents as read-only tab-separated rows
+	FormatTable Format = "table"
+)
+
+// Renderer presents the JSON documents services generate in another
+// format, with its own file extension so editors and highlighters pick
+// the right mode
+type Renderer interface {
+	// Ext returns the file extension of rendered documents, e.g. ".yaml"
+	Ext() string
+	// Render converts a JSON document; name is its file name without
+	// the extension, for formats with a title
+	Render(name string, data []byte) ([]byte, error)
+	// Parse converts a rendered document written back to JSON, failing
+	// with fs.ErrPermission for formats that can't be written
+	Parse(data []byte) ([]byte, error)
+}
+
+// Renderers are the formats documents can be shown in
+var Renderers = map[Format]Renderer{
+	FormatJSON:     jsonRenderer{},
+	FormatCompact:  compactRenderer{},
+	FormatYAML:     yamlRenderer{},
+	FormatMarkdown: markdownRenderer{},
+	FormatTable:    tableRenderer{},
+}
+
+// ParseFormat returns the format named s
+func ParseFormat(s string) (Format, error) {
+	f := Format(s)
+	if _, ok := Renderers[f]; !ok {
+		var names []string
+		for name := range Renderers {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown format %q (use %s)", s, strings.Join(names, ", "))
+	}
+	return f, nil
+}
+
+// Ext returns the file extension of the format, e.g. ".yaml"
+func (f Format) Ext() string {
+	if r, ok := Renderers[f]; ok {
+		return r.Ext()
+	}
+	return ".json"
+}
+
+// formatOf returns the format whose extension path has, other than .json,
+// and the .json document it would be rendered from
+func formatOf(p string) (Format, string, bool) {
+	for f, r := range Renderers {
+		if r.Ext() == ".json" {
+			continue
+		}
+		if base, ok := strings.CutSuffix(p, r.Ext()); ok && base != "" && !strings.HasSuffix(base, "/") {
+			return f, base + ".json", true
+		}
+	}
+	return "", p, false
+}
+
+// RenderRules choose the format a service lists its .json documents in,
+// by directory: "" for the whole service or a directory like "roles",
+// which covers everything below it. The longest matching directory wins.
+type RenderRules map[string]Format
+
+// For returns the format documents in dir are listed in
+func (r RenderRules) For(dir string) Format {
+	for {
+		if f, ok := r[dir]; ok {
+			return f
+		}
+		if dir == "" {
+			return FormatJSON
+		}
+		dir, _ = splitParent(dir)
+	}
+}
+
+// Source returns the .json path of a document p is listed as, or p
+// itself. Write scopes and patterns name documents by their .json path.
+func (r RenderRules) Source(p string) string {
+	f, source, ok := formatOf(p)
+	dir, _ := splitParent(source)
+	if !ok || r.For(dir) != f {
+		return p
+	}
+	return source
+}
+
+// Render returns a middleware that lists .json files in the formats rules
+// choose, e.g. policy.json as policy.yaml. Only the format listed in a
+// directory maps to the document; a real file of that name, e.g. an SSM
+// parameter named config.yaml next to config.json, is listed and served
+// in its place, and every other name is passed through as is.
+func Render(rules RenderRules) Middleware {
+	return func(p Provider) Provider {
+		return &renderProvider{Provider: p, rules: rules}
+	}
+}
+
+type renderProvider struct {
+	Provider
+	rules RenderRules
+}
+
+// listed returns the format and .json source of a path in the format
+// listed in its directory
+func (p *renderProvider) listed(path string) (Format, string, bool) {
+	f, source, ok := formatOf(path)
+	if !ok || p.rules.Source(path) != source {
+		return "", path, false
+	}
+	return f, source, true
+}
+
+// renamed returns the name a .json file in dir is listed as
+func (p *renderProvider) renamed(dir, name string) string {
+	if base, ok := strings.CutSuffix(name, ".json"); ok {
+		return base + p.rules.For(dir).Ext()
+	}
+	return name
+}
+
+func (p *renderProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.Provider.ReadDir(ctx, path)
+	if err != nil || p.rules.For(path) == FormatJSON {
+		return entries, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		// Files of their own keep the name, and the document its .json.
+		// The source's size and checksums don't describe the rendered
+		// document, which is only sized by Stat.
+		if name := p.renamed(path, e.Name); !e.IsDir && e.Link == "" && !names[name] && name != e.Name {
+			e.Name = name
+			e.Size = 0
+			e.Checksums = nil
+		}
+		out[i] = e
+	}
+	return out, nil
+}
+
+func (p *renderProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	f, source, ok := p.listed(path)
+	if !ok {
+		return p.Provider.Read(ctx, path)
+	}
+	if data, err := p.Provider.Read(ctx, path); err == nil {
+		return data, nil
+	}
+	data, err := p.Provider.Read(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return render(f, path, data), nil
+}
+
+// ReadRange slices the rendered document, which has no ranges of its own
+func (p *renderProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	if _, _, ok := p.listed(path); !ok {
+		return ReadRange(ctx, p.Provider, path, off, length)
+	}
+	data, err := p.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, off, length), nil
+}
+
+// Prefetch renders the documents fetched along with path in the format
+// listed for them
+func (p *renderProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	f, source, ok := p.listed(path)
+	if !ok {
+		return Prefetch(ctx, p.Provider, path)
+	}
+	files, err := Prefetch(ctx, p.Provider, source)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(files))
+	for name, data := range files {
+		if base, ok := strings.CutSuffix(name, ".json"); ok {
+			out[base+f.Ext()] = render(f, base+f.Ext(), data)
+		} else {
+			out[name] = data
+		}
+	}
+	return out, nil
+}
+
+func (p *renderProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	f, source, ok := p.listed(path)
+	if !ok {
+		return p.Provider.Stat(ctx, path)
+	}
+	if entry, err := p.Provider.Stat(ctx, path); err == nil {
+		return entry, nil
+	}
+	entry, err := p.Provider.Stat(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return p.renderedEntry(ctx, f, path, source, entry)
+}
+
+// renderedEntry returns the entry of the document at source listed as
+// path, sized as rendered. The document is read to render it, since its
+// size in another format can't be told from its size as JSON.
+func (p *renderProvider) renderedEntry(ctx context.Context, f Format, path, source string, entry *Entry) (*Entry, error) {
+	data, err := p.Provider.Read(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	_, name := splitParent(path)
+	renamed := *entry
+	renamed.Name = name
+	renamed.Size = int64(len(render(f, path, data)))
+	renamed.Checksums = nil
+	return &renamed, nil
+}
+
+func (p *renderProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	sources := make(map[string]string)
+	formats := make(map[string]Format)
+	batch := slices.Clone(paths)
+	for _, path := range paths {
+		if f, source, ok := p.listed(path); ok {
+			sources[path] = source
+			formats[path] = f
+			batch = append(batch, source)
+		}
+	}
+	entries, err := StatBatch(ctx, p.Provider, batch)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*Entry, len(paths))
+	for _, path := range paths {
+		if entry, ok := entries[path]; ok {
+			out[path] = entry
+			continue
+		}
+		source, ok := sources[path]
+		if !ok {
+			continue
+		}
+		if entry, ok := entries[source]; ok {
+			renamed, err := p.renderedEntry(ctx, formats[path], path, source, entry)
+			if err != nil {
+				return nil, err
+			}
+			out[path] = renamed
+		}
+	}
+	return out, nil
+}
+
+// Writable allows writes to documents listed in a writable format whose
+// JSON is writable; other paths are files of their own
+func (p *renderProvider) Writable(path string) bool {
+	f, source, ok := p.listed(path)
+	if !ok {
+		return p.Provider.Writable(path)
+	}
+	if _, err := Renderers[f].Parse(nil); errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	return p.Provider.Writable(source)
+}
+
+func (p *renderProvider) Write(ctx context.Context, path string, data []byte) error {
+	f, source, ok := p.listed(path)
+	if !ok {
+		return p.Provider.Write(ctx, path, data)
+	}
+	converted, err := Renderers[f].Parse(data)
+	if errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	if err != nil {
+		return invalidf("%s: %v", path, err)
+	}
+	return p.Provider.Write(ctx, source, converted)
+}
+
+func (p *renderProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *renderProvider) Close() {
+	Close(p.Provider)
+}
+
+// Written is unknown for listed documents, which are written to their
+// JSON source and rendered anew
+func (p *renderProvider) Written(path string, data []byte) ([]byte, bool) {
+	if _, _, ok := p.listed(path); ok {
+		return nil, false
+	}
+	return Written(p.Provider, path, data)
+}
+
+func (p *renderProvider) Delete(ctx context.Context, path string) error {
+	_, source, _ := p.listed(path)
+	return p.Provider.Delete(ctx, source)
+}
+
+// render converts a JSON document to format f, leaving content that isn't
+// JSON or can't be converted as is
+func render(f Format, name string, data []byte) []byte {
+	if !json.Valid(data) {
+		return data
+	}
+	out, err := Renderers[f].Render(strings.TrimSuffix(path.Base(name), f.Ext()), data)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// parseJSON parses a JSON document as a YAML node, keeping its key order
+func parseJSON(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0], nil
+	}
+	return &doc, nil
+}
+
+// jsonRenderer shows documents as generated
+type jsonRenderer struct{}
+
+func (jsonRenderer) Ext() string                                     { return ".json" }
+func (jsonRenderer) Render(name string, data []byte) ([]byte, error) { return data, nil }
+func (jsonRenderer) Parse(data []byte) ([]byte, error)               { return data, nil }
+
+// compactRenderer writes documents on one line, and arrays as one line
+// per element like JSON Lines
+type compactRenderer struct{}
+
+func (compactRenderer) Ext() string { return ".jsonl" }
+
+func (compactRenderer) Render(name string, data []byte) ([]byte, error) {
+	var items []json.RawMessage
+	if json.Unmarshal(data, &items) != nil {
+		items = []json.RawMessage{data}
+	}
+	var buf bytes.Buffer
+	for _, item := range items {
+		if err := json.Compact(&buf, item); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (compactRenderer) Parse(data []byte) ([]byte, error) { return nil, fs.ErrPermission }
+
+type yamlRenderer struct{}
+
+func (yamlRenderer) Ext() string { return ".yaml" }
+
+func (yamlRenderer) Render(name string, data []byte) ([]byte, error) {
+	doc, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return renderYAML(doc)
+}
+
+func (yamlRenderer) Parse(data []byte) ([]byte, error) { return yamlToJSON(data) }
+
+type markdownRenderer struct{}
+
+func (markdownRenderer) Ext() string { return ".md" }
+
+func (markdownRenderer) Render(name string, data []byte) ([]byte, error) {
+	doc, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(name, doc), nil
+}
+
+func (markdownRenderer) Parse(data []byte) ([]byte, error) { return nil, fs.ErrPermission }
+
+// renderYAML encodes a document parsed from JSON in block style, keeping
+// its key order
+func renderYAML(doc *yaml.Node) ([]byte, error) {
+	blockStyle(doc)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	err := enc.Close()
+	return buf.Bytes(), err
+}
+
+// blockStyle clears the flow and quoting styles JSON syntax parses as;
+// the encoder still quotes strings that would read as other types
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// yamlToJSON converts a written YAML document to the JSON providers expect
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// renderMarkdown summarizes a document as a heading and nested bullets
+func renderMarkdown(title string, doc *yaml.Node) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	root := doc
+	if root.Kind == yaml.ScalarNode {
+		b.WriteString(root.Value + "\n")
+		return []byte(b.String())
+	}
+	markdownItems(&b, root, 0)
+	return []byte(b.String())
+}
+
+func markdownItems(b *strings.Builder, n *yaml.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			if value.Kind == yaml.ScalarNode {
+				fmt.Fprintf(b, "%s- **%s**: %s\n", indent, key, markdownScalar(value))
+				continue
+			}
+			fmt.Fprintf(b, "%s- **%s**\n", indent, key)
+			markdownItems(b, value, depth+1)
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if item.Kind == yaml.ScalarNode {
+				fmt.Fprintf(b, "%s- %s\n", indent, markdownScalar(item))
+				continue
+			}
+			fmt.Fprintf(b, "%s-\n", indent)
+			markdownItems(b, item, depth+1)
+		}
+	}
+}
+
+func markdownScalar(n *yaml.Node) string {
+	if n.Tag == "!!null" {
+		return "_none_"
+	}
+	if n.Value == "" {
+		return `""`
+	}
+	return "`" + strings.ReplaceAll(n.Value, "`", "'") + "`"
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"encoding/json"
+	"io/fs"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tableRenderer lays documents out as tab-separated rows for cut, sort and
+// spreadsheets: an array of objects gets a header row of their keys and a
+// row per object, an object a row per key, and an array of other values a
+// row per value. Nested values are written as compact JSON.
+type tableRenderer struct{}
+
+func (tableRenderer) Ext() string { return ".tsv" }
+
+func (tableRenderer) Render(name string, data []byte) ([]byte, error) {
+	doc, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	switch {
+	case doc.Kind == yaml.SequenceNode && allMappings(doc.Content):
+		var columns []string
+		index := make(map[string]int)
+		for _, item := range doc.Content {
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				key := item.Content[i].Value
+				if _, ok := index[key]; !ok {
+					index[key] = len(columns)
+					columns = append(columns, tableEscaper.Replace(key))
+				}
+			}
+		}
+		rows = append(rows, columns)
+		for _, item := range doc.Content {
+			row := make([]string, len(columns))
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				row[index[item.Content[i].Value]] = tableCell(item.Content[i+1])
+			}
+			rows = append(rows, row)
+		}
+	case doc.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			rows = append(rows, []string{tableCell(doc.Content[i]), tableCell(doc.Content[i+1])})
+		}
+	case doc.Kind == yaml.SequenceNode:
+		for _, item := range doc.Content {
+			rows = append(rows, []string{tableCell(item)})
+		}
+	default:
+		rows = append(rows, []string{tableCell(doc)})
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(strings.Join(row, "\t"))
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+func (tableRenderer) Parse(data []byte) ([]byte, error) { return nil, fs.ErrPermission }
+
+// allMappings reports whether nodes are all objects, and there is one
+func allMappings(nodes []*yaml.Node) bool {
+	for _, n := range nodes {
+		if n.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return len(nodes) > 0
+}
+
+// tableEscaper keeps a cell on its line and in its column
+var tableEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tableCell returns a value as a cell: scalars as is, null as empty and
+// arrays and objects as compact JSON
+func tableCell(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag == "!!null" {
+			return ""
+		}
+		return tableEscaper.Replace(n.Value)
+	}
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return tableEscaper.Replace(string(data))
+}
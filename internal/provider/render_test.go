@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+// writableFake accepts writes everywhere
+type writableFake struct {
+	*fakeProvider
+}
+
+func (p writableFake) Writable(path string) bool { return true }
+
+func TestRenderYAML(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"role/policy.json": []byte(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":"*"}],"Sid":"123"}`),
+		"role/notes.txt":   []byte("plain"),
+	})
+	p := Render(RenderRules{"": FormatYAML})(writableFake{fake})
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	if !names["policy.yaml"] || !names["notes.txt"] || names["policy.json"] {
+		t.Errorf("entries = %v", entries)
+	}
+
+	data, err := p.Read(ctx, "role/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Version: "2012-10-17"
+Statement:
+  - Effect: Allow
+    Action:
+      - s3:GetObject
+    Resource: '*'
+Sid: "123"
+`
+	if string(data) != want {
+		t.Errorf("policy.yaml =\n%s\nwant\n%s", data, want)
+	}
+	if data, _ := p.Read(ctx, "role/policy.json"); data[0] != '{' {
+		t.Errorf("policy.json should stay JSON, got %s", data)
+	}
+
+	// Sized as rendered, not as the JSON it's rendered from
+	if entry, err := p.Stat(ctx, "role/policy.yaml"); err != nil || entry.Name != "policy.yaml" || entry.Size != int64(len(want)) {
+		t.Errorf("Stat = %+v, %v, want %d bytes", entry, err, len(want))
+	}
+	stats, err := StatBatch(ctx, p, []string{"role/policy.yaml"})
+	if err != nil || stats["role/policy.yaml"] == nil || stats["role/policy.yaml"].Size != int64(len(want)) {
+		t.Errorf("StatBatch = %+v, %v, want %d bytes", stats["role/policy.yaml"], err, len(want))
+	}
+
+	if err := p.Write(ctx, "role/policy.yaml", []byte("Version: \"2012-10-17\"\nStatement: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]any
+	if err := json.Unmarshal(fake.files["role/policy.json"], &written); err != nil {
+		t.Fatalf("written policy isn't JSON: %v\n%s", err, fake.files["role/policy.json"])
+	}
+	if written["Version"] != "2012-10-17" {
+		t.Errorf("written = %v", written)
+	}
+	if err := p.Write(ctx, "role/policy.yaml", []byte("a: [")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("invalid YAML: %v, want ErrInvalid", err)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"i-0abc/info.json": []byte(`{"InstanceId":"i-0abc","State":{"Name":"running"},"Tags":[{"Key":"Name","Value":"web"}],"KeyName":null}`),
+	})
+	p := Render(RenderRules{"": FormatMarkdown})(writableFake{fake})
+
+	data, err := p.Read(context.Background(), "i-0abc/info.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# info\n\n" +
+		"- **InstanceId**: `i-0abc`\n" +
+		"- **State**\n" +
+		"  - **Name**: `running`\n" +
+		"- **Tags**\n" +
+		"  -\n" +
+		"    - **Key**: `Name`\n" +
+		"    - **Value**: `web`\n" +
+		"- **KeyName**: _none_\n"
+	if string(data) != want {
+		t.Errorf("info.md =\n%s\nwant\n%s", data, want)
+	}
+	if p.Writable("i-0abc/info.md") {
+		t.Error("markdown summaries should be read-only")
+	}
+	if err := p.Write(context.Background(), "i-0abc/info.md", data); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Write = %v, want ErrPermission", err)
+	}
+}
+
+func TestRenderRules(t *testing.T) {
+	rules := RenderRules{"": FormatYAML, "roles": FormatMarkdown, "roles/admin": FormatJSON}
+	for dir, want := range map[string]Format{
+		"":                 FormatYAML,
+		"users/alice":      FormatYAML,
+		"roles":            FormatMarkdown,
+		"roles/web":        FormatMarkdown,
+		"roles/admin":      FormatJSON,
+		"roles/admin/more": FormatJSON,
+		"rolesx":           FormatYAML,
+	} {
+		if got := rules.For(dir); got != want {
+			t.Errorf("For(%q) = %s, want %s", dir, got, want)
+		}
+	}
+	if got := (RenderRules{}).For("roles"); got != FormatJSON {
+		t.Errorf("no rules: %s, want json", got)
+	}
+
+	for path, want := range map[string]string{
+		"users/alice/info.yaml":   "users/alice/info.json",
+		"users/alice/info.md":     "users/alice/info.md", // not listed there
+		"roles/web/info.md":       "roles/web/info.json",
+		"roles/admin/info.json":   "roles/admin/info.json",
+		"roles/admin/info.yaml":   "roles/admin/info.yaml",
+		"users/alice/.yaml":       "users/alice/.yaml",
+		"users/alice/groups.json": "users/alice/groups.json",
+	} {
+		if got := rules.Source(path); got != want {
+			t.Errorf("Source(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestRenderRealFilesFirst(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"fn/config.json": []byte(`{"FunctionName":"fn","Timeout":3}`),
+		"fn/notes.md":    []byte("# a file of its own"),
+		"app/db.json":    []byte(`{"a":1}`),
+		"app/db.yaml":    []byte("real: true\n"),
+	})
+	p := Render(RenderRules{"fn": FormatYAML, "app": FormatYAML})(writableFake{fake})
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "fn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(entries); !slices.Equal(slices.Sorted(slices.Values(names)), []string{"config.yaml", "notes.md"}) {
+		t.Errorf("entries = %v", names)
+	}
+	if data, err := p.Read(ctx, "fn/config.yaml"); err != nil || string(data) != "FunctionName: fn\nTimeout: 3\n" {
+		t.Errorf("config.yaml = %q, %v", data, err)
+	}
+
+	// Formats not listed in the directory aren't documents
+	if _, err := p.Read(ctx, "fn/config.tsv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("config.tsv = %v, want ErrNotExist", err)
+	}
+	if data, err := p.Read(ctx, "fn/notes.md"); err != nil || string(data) != "# a file of its own" {
+		t.Errorf("notes.md = %q, %v", data, err)
+	}
+	if err := p.Write(ctx, "fn/config.jsonl", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.files["fn/config.json"]) != `{"FunctionName":"fn","Timeout":3}` || string(fake.files["fn/config.jsonl"]) != "{}" {
+		t.Errorf("unlisted write changed the document: %s", fake.files["fn/config.json"])
+	}
+
+	// A real file of the listed name wins over the rendered document,
+	// which keeps its .json name
+	entries, err = p.ReadDir(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(entries); !slices.Equal(slices.Sorted(slices.Values(names)), []string{"db.json", "db.yaml"}) {
+		t.Errorf("entries = %v", names)
+	}
+	if data, err := p.Read(ctx, "app/db.yaml"); err != nil || string(data) != "real: true\n" {
+		t.Errorf("db.yaml = %q, %v", data, err)
+	}
+	if entry, err := p.Stat(ctx, "app/db.yaml"); err != nil || entry.Size != int64(len("real: true\n")) {
+		t.Errorf("Stat(db.yaml) = %+v, %v", entry, err)
+	}
+	batch, err := StatBatch(ctx, p, []string{"app/db.yaml", "fn/config.yaml"})
+	if err != nil || batch["app/db.yaml"].Size != int64(len("real: true\n")) || batch["fn/config.yaml"].Name != "config.yaml" {
+		t.Errorf("StatBatch = %v, %v", batch, err)
+	}
+}
+
+func TestRenderCompact(t *testing.T) {
+	data, err := compactRenderer{}.Render("targets", []byte(`[
+  {"Id": "i-0abc", "State": "healthy"},
+  {"Id": "i-0def", "State": "unhealthy"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Id":"i-0abc","State":"healthy"}` + "\n" + `{"Id":"i-0def","State":"unhealthy"}` + "\n"
+	if string(data) != want {
+		t.Errorf("compact =\n%s\nwant\n%s", data, want)
+	}
+	if data, _ := (compactRenderer{}).Render("info", []byte("{\n  \"a\": [1, 2]\n}")); string(data) != "{\"a\":[1,2]}\n" {
+		t.Errorf("compact object = %q", data)
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	data, err := tableRenderer{}.Render("targets", []byte(`[
+  {"Id": "i-0abc", "State": "healthy", "Port": 80},
+  {"Id": "i-0def", "State": "unhealthy", "Reason": "Target.Timeout\tfailed", "Tags": {"a": "b"}},
+  {"Id": "i-0123", "Port": null}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Id\tState\tPort\tReason\tTags\n" +
+		"i-0abc\thealthy\t80\t\t\n" +
+		"i-0def\tunhealthy\t\tTarget.Timeout\\tfailed\t{\"a\":\"b\"}\n" +
+		"i-0123\t\t\t\t\n"
+	if string(data) != want {
+		t.Errorf("table =\n%s\nwant\n%s", data, want)
+	}
+	if data, _ := (tableRenderer{}).Render("names", []byte(`["a", "b"]`)); string(data) != "a\nb\n" {
+		t.Errorf("table of strings = %q", data)
+	}
+	if _, err := (tableRenderer{}).Parse(data); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Parse = %v, want ErrPermission", err)
+	}
+}
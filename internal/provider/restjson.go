@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// restJSONClient calls an AWS REST-JSON, JSON 1.0 or Query API with SigV4-signed
+// requests, for services whose SDK module sisu doesn't depend on. Errors are smithy API
+// errors carrying the service's error code and the HTTP status, so
+// throttling and access denied are recognised as for SDK clients, and
+// requests are retried by the SDK's retryer as theirs are.
+type restJSONClient struct {
+	cfg          aws.Config
+	serviceID    string // e.g. "AccessAnalyzer", as reported in usage
+	signingName  string // e.g. "access-analyzer"
+	endpoint     string
+	retryer      aws.Retryer
+	target       string // X-Amz-Target prefix of AWS JSON services, see call
+	jsonVersion  string // AWS JSON protocol version of target, "1.0" unless set
+	queryVersion string // API version of AWS Query services, see query
+}
+
+// newRESTJSONClient returns a client for a service whose hostnames start
+// with its signing name, as those of every service using it do
+func newRESTJSONClient(cfg aws.Config, serviceID, signingName string) *restJSONClient {
+	return &restJSONClient{
+		cfg:         cfg,
+		serviceID:   serviceID,
+		signingName: signingName,
+		endpoint:    resolveEndpoint(cfg, signingName),
+		retryer:     newRetryer(cfg),
+	}
+}
+
+// newRetryer returns the retryer SDK clients build from cfg: its own if
+// set, otherwise the standard or adaptive one with cfg's max attempts
+func newRetryer(cfg aws.Config) aws.Retryer {
+	if cfg.Retryer != nil {
+		return cfg.Retryer()
+	}
+	var r aws.Retryer = retry.NewStandard()
+	if cfg.RetryMode == aws.RetryModeAdaptive {
+		r = retry.NewAdaptiveMode()
+	}
+	if cfg.RetryMaxAttempts != 0 {
+		r = retry.AddWithMaxAttempts(r, cfg.RetryMaxAttempts)
+	}
+	return r
+}
+
+// newQueryClient returns a client for an AWS Query service such as SNS,
+// whose operations are form-encoded POSTs answered in XML; see query
+func newQueryClient(cfg aws.Config, serviceID, signingName, version string) *restJSONClient {
+	c := newRESTJSONClient(cfg, serviceID, signingName)
+	c.queryVersion = version
+	return c
+}
+
+// newJSONRPCClient returns a client for an AWS JSON 1.0 service, whose
+// operations are all POSTs to / named by the X-Amz-Target header, e.g.
+// "GraniteServiceVersion20100801.DescribeAlarms" for CloudWatch. Set
+// jsonVersion for JSON 1.1 services, which differ only in content type.
+func newJSONRPCClient(cfg aws.Config, serviceID, signingName, target string) *restJSONClient {
+	c := newRESTJSONClient(cfg, serviceID, signingName)
+	c.target = target
+	return c
+}
+
+// call sends an AWS JSON request for operation op
+func (c *restJSONClient) call(ctx context.Context, op string, in, out any) error {
+	if in == nil {
+		in = struct{}{}
+	}
+	return c.do(ctx, op, "POST", "/", nil, in, out)
+}
+
+// do sends in as the JSON body of a request for operation op and decodes
+// the response into out. in and out may be nil.
+func (c *restJSONClient) do(ctx context.Context, op, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	header := http.Header{}
+	switch {
+	case c.target != "":
+		version := c.jsonVersion
+		if version == "" {
+			version = "1.0"
+		}
+		header.Set("Content-Type", "application/x-amz-json-"+version)
+		header.Set("X-Amz-Target", c.target+"."+op)
+	case in != nil:
+		header.Set("Content-Type", "application/json")
+	}
+
+	data, err := c.send(ctx, op, method, u, header, body, restJSONError)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// query sends an AWS Query request for operation op with params, for
+// clients made by newQueryClient, and decodes the XML response into out.
+// Field tags of out are relative to the response element, e.g.
+// `xml:"ListTopicsResult>Topics>member"`.
+func (c *restJSONClient) query(ctx context.Context, op string, params url.Values, out any) error {
+	form := url.Values{"Action": {op}, "Version": {c.queryVersion}}
+	for k, v := range params {
+		form[k] = v
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	data, err := c.send(ctx, op, "POST", c.endpoint+"/", header, []byte(form.Encode()), queryError)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
+
+// send sends a request for operation op, retrying it as the retryer
+// allows, and returns the response body. Error responses are decoded by
+// decodeError.
+func (c *restJSONClient) send(ctx context.Context, op, method, u string, header http.Header, body []byte, decodeError func(*http.Response, []byte) error) ([]byte, error) {
+	ctx = withOperation(ctx, awsmiddleware.RegisterServiceMetadata{
+		ServiceID:     c.serviceID,
+		SigningName:   c.signingName,
+		Region:        c.cfg.Region,
+		OperationName: op,
+	})
+	if c.cfg.Credentials == nil {
+		return nil, fmt.Errorf("%s: no AWS credentials", op)
+	}
+
+	releaseRetry := func(error) error { return nil }
+	for attempt := 1; ; attempt++ {
+		data, err := c.attempt(ctx, op, method, u, header, body, decodeError)
+		releaseRetry(err)
+		if err == nil || attempt >= c.retryer.MaxAttempts() || !c.retryer.IsErrorRetryable(err) || ctx.Err() != nil {
+			return data, err
+		}
+
+		// Out of retry quota, as after many throttled attempts
+		release, tokenErr := c.retryer.GetRetryToken(ctx, err)
+		if tokenErr != nil {
+			return nil, err
+		}
+		releaseRetry = release
+		delay, delayErr := c.retryer.RetryDelay(attempt, err)
+		if delayErr != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt signs and sends one request for operation op, returning the
+// response body
+func (c *restJSONClient) attempt(ctx context.Context, op, method, u string, header http.Header, body []byte, decodeError func(*http.Response, []byte) error) (data []byte, err error) {
+	// The adaptive retryer limits the rate of attempts through its tokens
+	if r, ok := c.retryer.(aws.RetryerV2); ok {
+		release, err := r.GetAttemptToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { release(err) }()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	creds, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), c.signingName, c.cfg.Region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	client := c.cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	Usage.Record(strings.ToLower(c.serviceID), op)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if tr := traceFrom(ctx); tr != nil {
+		tr.add(restJSONTraceCall(strings.ToLower(c.serviceID), op, time.Since(start), resp, err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		// Wrapped as SDK clients' errors are, so the retryer sees the status
+		return nil, &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: resp},
+				Err:      decodeError(resp, data),
+			},
+			RequestID: resp.Header.Get("X-Amzn-Requestid"),
+		}
+	}
+	return data, nil
+}
+
+// jsonTimes turns the epoch seconds the JSON protocols use for timestamps,
+// e.g. CloudWatch's StateUpdatedTimestamp, App Runner's CreatedAt or DMS's
+// StartDate, into RFC 3339 times
+func jsonTimes(doc map[string]any) map[string]any {
+	for k, v := range doc {
+		if secs, ok := v.(float64); ok && isJSONTimeKey(k) {
+			doc[k] = epochTime(secs)
+		}
+	}
+	return doc
+}
+
+func isJSONTimeKey(k string) bool {
+	for _, suffix := range []string{"Timestamp", "At", "Date", "Time"} {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func epochTime(secs float64) time.Time {
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// restJSONTraceCall describes a request for a trace; resp is nil if it
+// wasn't answered
+func restJSONTraceCall(service, op string, d time.Duration, resp *http.Response, err error) TraceCall {
+	c := TraceCall{Service: service, Operation: op, Duration: d}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	if resp != nil {
+		c.Status = resp.StatusCode
+		c.RequestID = resp.Header.Get("X-Amzn-Requestid")
+		if resp.StatusCode >= 300 {
+			c.Error = resp.Status
+		}
+	}
+	return c
+}
+
+// restJSONError decodes an error response. The code comes from the
+// X-Amzn-ErrorType header or the body's __type, minus any namespace or
+// trailing ":<url>".
+func restJSONError(resp *http.Response, data []byte) error {
+	var body struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	json.Unmarshal(data, &body)
+
+	code := resp.Header.Get("X-Amzn-ErrorType")
+	if code == "" {
+		code = body.Type
+	}
+	if i := strings.Index(code, ":"); i >= 0 {
+		code = code[:i]
+	}
+	if i := strings.LastIndex(code, "#"); i >= 0 {
+		code = code[i+1:]
+	}
+	if code == "" {
+		code = http.StatusText(resp.StatusCode)
+	}
+	msg := body.Message
+	if msg == "" {
+		msg = body.MessageUpper
+	}
+	return &smithy.GenericAPIError{Code: code, Message: msg}
+}
+
+// queryError decodes an AWS Query error response, whose code and message
+// are in <ErrorResponse><Error>
+func queryError(resp *http.Response, data []byte) error {
+	var body struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	xml.Unmarshal(data, &body)
+	if body.Code == "" {
+		body.Code = http.StatusText(resp.StatusCode)
+	}
+	return &smithy.GenericAPIError{Code: body.Code, Message: body.Message}
+}
+
+// withOperation returns ctx carrying the service and operation metadata SDK
+// clients attach to their requests
+func withOperation(ctx context.Context, meta awsmiddleware.RegisterServiceMetadata) context.Context {
+	meta.HandleInitialize(ctx, middleware.InitializeInput{}, middleware.InitializeHandlerFunc(
+		func(opCtx context.Context, _ middleware.InitializeInput) (middleware.InitializeOutput, middleware.Metadata, error) {
+			ctx = opCtx
+			return middleware.InitializeOutput{}, middleware.Metadata{}, nil
+		}))
+	return ctx
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// awsPartition is how the hostnames of a group of regions are formed
+type awsPartition struct {
+	regionPrefix    string // of the partition's region names; "" matches any
+	dnsSuffix       string
+	dualStackSuffix string // "" if the partition has no dual-stack endpoints
+}
+
+// awsPartitions are matched in order, the commercial partition last
+var awsPartitions = []awsPartition{
+	{regionPrefix: "cn-", dnsSuffix: "amazonaws.com.cn", dualStackSuffix: "api.amazonwebservices.com.cn"},
+	{regionPrefix: "us-gov-", dnsSuffix: "amazonaws.com", dualStackSuffix: "api.aws"},
+	{regionPrefix: "us-iso-", dnsSuffix: "c2s.ic.gov"},
+	{regionPrefix: "us-isob-", dnsSuffix: "sc2s.sgov.gov"},
+	{regionPrefix: "us-isof-", dnsSuffix: "csp.hci.ic.gov"},
+	{regionPrefix: "eu-isoe-", dnsSuffix: "cloud.adc-e.uk"},
+	{regionPrefix: "eusc-", dnsSuffix: "amazonaws.eu"},
+	{dnsSuffix: "amazonaws.com", dualStackSuffix: "api.aws"},
+}
+
+func partitionOf(region string) awsPartition {
+	for _, p := range awsPartitions {
+		if strings.HasPrefix(region, p.regionPrefix) {
+			return p
+		}
+	}
+	return awsPartitions[len(awsPartitions)-1]
+}
+
+// resolveEndpoint returns the endpoint of the service whose hostnames
+// start with prefix, e.g. "monitoring" for CloudWatch, in cfg's region. As
+// for SDK clients, a configured base endpoint wins, the region's partition
+// picks the domain, and use_fips_endpoint and use_dualstack_endpoint from
+// the environment or shared config pick the variant.
+func resolveEndpoint(cfg aws.Config, prefix string) string {
+	if endpoint := aws.ToString(cfg.BaseEndpoint); endpoint != "" {
+		return endpoint
+	}
+	partition := partitionOf(cfg.Region)
+	fips, dualStack := endpointVariants(cfg.ConfigSources)
+
+	host := prefix
+	if fips {
+		host += "-fips"
+	}
+	suffix := partition.dnsSuffix
+	if dualStack && partition.dualStackSuffix != "" {
+		suffix = partition.dualStackSuffix
+	}
+	return "https://" + host + "." + cfg.Region + "." + suffix
+}
+
+// endpointVariants reports whether the config sources enable FIPS and
+// dual-stack endpoints, the first source setting each deciding it
+func endpointVariants(sources []interface{}) (fips, dualStack bool) {
+	ctx := context.Background()
+	var fipsFound, dualStackFound bool
+	for _, source := range sources {
+		if s, ok := source.(interface {
+			GetUseFIPSEndpoint(context.Context) (aws.FIPSEndpointState, bool, error)
+		}); ok && !fipsFound {
+			if state, found, err := s.GetUseFIPSEndpoint(ctx); err == nil && found {
+				fips, fipsFound = state == aws.FIPSEndpointStateEnabled, true
+			}
+		}
+		if s, ok := source.(interface {
+			GetUseDualStackEndpoint(context.Context) (aws.DualStackEndpointState, bool, error)
+		}); ok && !dualStackFound {
+			if state, found, err := s.GetUseDualStackEndpoint(ctx); err == nil && found {
+				dualStack, dualStackFound = state == aws.DualStackEndpointStateEnabled, true
+			}
+		}
+	}
+	return fips, dualStack
+}
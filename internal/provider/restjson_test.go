@@ -0,0 +1,262 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/smithy-go"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	fips := config.EnvConfig{UseFIPSEndpoint: aws.FIPSEndpointStateEnabled}
+	dualStack := config.EnvConfig{UseDualStackEndpoint: aws.DualStackEndpointStateEnabled}
+
+	tests := []struct {
+		name string
+		cfg  aws.Config
+		want string
+	}{
+		{"commercial", aws.Config{Region: "eu-west-1"}, "https://monitoring.eu-west-1.amazonaws.com"},
+		{"china", aws.Config{Region: "cn-north-1"}, "https://monitoring.cn-north-1.amazonaws.com.cn"},
+		{"govcloud", aws.Config{Region: "us-gov-west-1"}, "https://monitoring.us-gov-west-1.amazonaws.com"},
+		{"iso", aws.Config{Region: "us-iso-east-1"}, "https://monitoring.us-iso-east-1.c2s.ic.gov"},
+		{"isob", aws.Config{Region: "us-isob-east-1"}, "https://monitoring.us-isob-east-1.sc2s.sgov.gov"},
+		{"fips", aws.Config{Region: "us-gov-west-1", ConfigSources: []interface{}{fips}}, "https://monitoring-fips.us-gov-west-1.amazonaws.com"},
+		{"dualstack", aws.Config{Region: "us-east-1", ConfigSources: []interface{}{dualStack}}, "https://monitoring.us-east-1.api.aws"},
+		{"dualstack china", aws.Config{Region: "cn-north-1", ConfigSources: []interface{}{dualStack}}, "https://monitoring.cn-north-1.api.amazonwebservices.com.cn"},
+		{"dualstack iso", aws.Config{Region: "us-iso-east-1", ConfigSources: []interface{}{dualStack}}, "https://monitoring.us-iso-east-1.c2s.ic.gov"},
+		{"first source wins", aws.Config{Region: "us-east-1", ConfigSources: []interface{}{config.EnvConfig{UseFIPSEndpoint: aws.FIPSEndpointStateDisabled}, fips}}, "https://monitoring.us-east-1.amazonaws.com"},
+		{"base endpoint", aws.Config{Region: "cn-north-1", BaseEndpoint: aws.String("http://localhost:4566"), ConfigSources: []interface{}{fips}}, "http://localhost:4566"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveEndpoint(tt.cfg, "monitoring"); got != tt.want {
+				t.Errorf("resolveEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// scriptedClient answers requests with statuses in turn, the last repeated
+type scriptedClient struct {
+	statuses []int
+	calls    int
+}
+
+func (c *scriptedClient) Do(req *http.Request) (*http.Response, error) {
+	status := c.statuses[min(c.calls, len(c.statuses)-1)]
+	c.calls++
+	body := `{}`
+	header := http.Header{}
+	switch {
+	case status == 400:
+		body = `{"message":"slow down"}`
+		header.Set("X-Amzn-ErrorType", "ThrottlingException")
+	case status >= 500:
+		body = `{"message":"try again"}`
+		header.Set("X-Amzn-ErrorType", "ServiceUnavailableException")
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func scriptedConfig(client *scriptedClient, maxAttempts int) aws.Config {
+	return aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDSCRIPT", "script-secret", ""),
+		HTTPClient:  client,
+		Retryer: func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = maxAttempts
+				o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+			})
+		},
+	}
+}
+
+func TestRESTJSONRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantCode  string
+	}{
+		{"throttled then ok", []int{400, 200}, 2, ""},
+		{"unavailable then ok", []int{503, 503, 200}, 3, ""},
+		{"gives up", []int{503}, 3, "ServiceUnavailableException"},
+		{"not retryable", []int{404}, 1, "Not Found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedClient{statuses: tt.statuses}
+			c := newRESTJSONClient(scriptedConfig(client, 3), "CloudWatch", "monitoring")
+			c.target = "GraniteServiceVersion20100801"
+
+			err := c.call(context.Background(), "ListDashboards", struct{}{}, nil)
+			if client.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", client.calls, tt.wantCalls)
+			}
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var apiErr smithy.APIError
+			if !errors.As(err, &apiErr) || apiErr.ErrorCode() != tt.wantCode {
+				t.Errorf("err = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestRESTJSONListings covers what every provider built on restJSONClient
+// does alike: list a directory from its fixture, cut the listing off at
+// MaxEntries with a marker, and report a missing resource as not found.
+// Service-specific behaviour is tested beside each provider.
+func TestRESTJSONListings(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		provider   func(aws.Config) Provider
+		dir        string
+		want       []string
+		maxEntries int
+		truncated  []string
+		missing    string
+	}{
+		{
+			name: "apprunner", fixture: "apprunner",
+			provider: func(cfg aws.Config) Provider { return newAppRunnerProvider(cfg) },
+			want:     []string{"api", "web"}, maxEntries: 1, truncated: []string{"api", MoreResultsFile},
+			missing: "missing/status",
+		},
+		{
+			name: "athena workgroups", fixture: "athena",
+			provider: func(cfg aws.Config) Provider { return newAthenaProvider(cfg) },
+			dir:      "workgroups", maxEntries: 1, truncated: []string{"analysts.json", MoreResultsFile},
+		},
+		{
+			name: "athena named queries", fixture: "athena",
+			provider: func(cfg aws.Config) Provider { return newAthenaProvider(cfg) },
+			dir:      "named-queries", maxEntries: 1, truncated: []string{"daily-orders.b1.sql", MoreResultsFile},
+		},
+		{
+			name: "batch", fixture: "batch",
+			provider: func(cfg aws.Config) Provider { return newBatchProvider(cfg) },
+			dir:      "job-queues", want: []string{"adhoc", "nightly"}, maxEntries: 1, truncated: []string{"adhoc", MoreResultsFile},
+			missing: "job-queues/missing/info.json",
+		},
+		{
+			name: "cloudwatch alarms", fixture: "cloudwatch",
+			provider: func(cfg aws.Config) Provider { return newCloudWatchProvider(cfg) },
+			dir:      "alarms", maxEntries: 1, truncated: []string{"api-degraded.json", MoreResultsFile},
+		},
+		{
+			name: "cloudwatch dashboards", fixture: "cloudwatch",
+			provider: func(cfg aws.Config) Provider { return newCloudWatchProvider(cfg) },
+			dir:      "dashboards", maxEntries: 1, truncated: []string{"billing.json", MoreResultsFile},
+		},
+		{
+			name: "cloudwatch metrics", fixture: "cloudwatch",
+			provider: func(cfg aws.Config) Provider { return newCloudWatchProvider(cfg) },
+			dir:      "metrics/AWS", maxEntries: 1, truncated: []string{"EC2", MoreResultsFile},
+			missing: "metrics/AWS/EC2/Nope",
+		},
+		{
+			name: "datasync", fixture: "datasync",
+			provider:   func(cfg aws.Config) Provider { return newDataSyncProvider(cfg) },
+			want:       []string{"task-0a1b2c3d4e5f60718", "task-0f1e2d3c4b5a69788"},
+			maxEntries: 1, truncated: []string{"task-0a1b2c3d4e5f60718", MoreResultsFile},
+			missing: "task-missing/info.json",
+		},
+		{
+			name: "dms", fixture: "dms",
+			provider: func(cfg aws.Config) Provider { return newDMSProvider(cfg) },
+			want:     []string{"archive-copy", "orders-to-aurora"}, maxEntries: 1, truncated: []string{"archive-copy", MoreResultsFile},
+			missing: "missing/info.json",
+		},
+		{
+			name: "elb", fixture: "elb",
+			provider: func(cfg aws.Config) Provider { return newELBProvider(cfg) },
+			want:     []string{"ingest", "web"}, maxEntries: 1, truncated: []string{"ingest", MoreResultsFile},
+			missing: "web/target-groups/missing/health.json",
+		},
+		{
+			name: "emr", fixture: "emr",
+			provider: func(cfg aws.Config) Provider { return newEMRProvider(cfg) },
+			want:     []string{"j-2AXXXXXXGAPLF", "j-3BYYYYYYHBQMG"}, maxEntries: 1, truncated: []string{"j-2AXXXXXXGAPLF", MoreResultsFile},
+			missing: "j-MISSING/info.json",
+		},
+		{
+			name: "kinesis", fixture: "kinesis",
+			provider: func(cfg aws.Config) Provider { return newKinesisProvider(cfg) },
+			want:     []string{"clicks", "orders"}, maxEntries: 1, truncated: []string{"clicks", MoreResultsFile},
+			missing: "missing/info.json",
+		},
+		{
+			name: "lightsail", fixture: "lightsail",
+			provider: func(cfg aws.Config) Provider { return newLightsailProvider(cfg) },
+			dir:      "instances", want: []string{"blog", "vpn"}, maxEntries: 1, truncated: []string{"blog", MoreResultsFile},
+		},
+		{
+			name: "mwaa", fixture: "mwaa",
+			provider: func(cfg aws.Config) Provider { return newMWAAProvider(cfg) },
+			want:     []string{"prod-airflow", "staging-airflow"}, maxEntries: 1, truncated: []string{"prod-airflow", MoreResultsFile},
+			missing: "missing/info.json",
+		},
+		{
+			name: "sqs", fixture: "sqs",
+			provider: func(cfg aws.Config) Provider { return newSQSProvider(cfg) },
+			want:     []string{"events.fifo", "orders", "orders-dlq"}, maxEntries: 2, truncated: []string{"events.fifo", "orders", MoreResultsFile},
+			missing: "missing/peek.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg, _ := fixtureConfig(t, tt.fixture)
+			p := tt.provider(cfg)
+
+			if tt.want != nil {
+				entries, err := p.ReadDir(ctx, tt.dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if names := entryNames(entries); !reflect.DeepEqual(names, tt.want) {
+					t.Errorf("ReadDir(%q) = %v, want %v", tt.dir, names, tt.want)
+				}
+			}
+			if tt.missing != "" {
+				if _, err := p.Stat(ctx, tt.missing); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Stat(%q) = %v, want ErrNotExist", tt.missing, err)
+				}
+			}
+
+			defer func(n int) { MaxEntries = n }(MaxEntries)
+			MaxEntries = tt.maxEntries
+			entries, err := tt.provider(cfg).ReadDir(ctx, tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := entryNames(entries); !reflect.DeepEqual(names, tt.truncated) {
+				t.Errorf("ReadDir(%q) under MaxEntries %d = %v, want %v", tt.dir, tt.maxEntries, names, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// retryables decides which errors are worth another attempt. It reuses the
+// SDK's classification so throttling and transient network errors retry,
+// while access-denied and not-found errors fail fast.
+var retryables = retry.IsErrorRetryables(append(
+	[]retry.IsErrorRetryable{retry.RetryableErrorCode{Codes: retry.DefaultThrottleErrorCodes}},
+	retry.DefaultRetryables...,
+))
+
+// Retry retries failed read operations up to attempts times in total with
+// exponential backoff. Writes and deletes are never retried here; the SDK's
+// own retryer already covers transport-level failures for those.
+func Retry(attempts int) Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		if op.IsMutation() {
+			return call(ctx)
+		}
+
+		backoff := 200 * time.Millisecond
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = call(ctx)
+			if err == nil || attempt >= attempts || !isRetryable(err) {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	})
+}
+
+func isRetryable(err error) bool {
+	// A per-call deadline already expired; retrying would just multiply the wait
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return retryables.IsErrorRetryable(err) == aws.TrueTernary
+}
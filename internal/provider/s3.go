@@ -3,44 +3,46 @@ package provider
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/semonte/sisu/internal/cache"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/semonte/sisu/internal/paging"
 )
 
 // S3Provider provides access to S3 buckets and objects
 type S3Provider struct {
 	ReadOnlyProvider
 	client *s3.Client
-	cache  *cache.Cache
 }
 
 // NewS3Provider creates a new S3 provider
 func NewS3Provider(profile, region string) (*S3Provider, error) {
-	var opts []func(*config.LoadOptions) error
-
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, err
 	}
+	return newS3Provider(cfg), nil
+}
 
+func newS3Provider(cfg aws.Config) *S3Provider {
 	return &S3Provider{
 		client: s3.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+	}
 }
 
 func (p *S3Provider) Name() string {
@@ -48,35 +50,26 @@ func (p *S3Provider) Name() string {
 }
 
 func (p *S3Provider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
-
-	var entries []Entry
-	var err error
-
 	// Root of S3 - list buckets
 	if path == "" {
-		entries, err = p.listBuckets(ctx)
-	} else {
-		// Inside a bucket - list objects
-		parts := strings.SplitN(path, "/", 2)
-		bucket := parts[0]
-		prefix := ""
-		if len(parts) > 1 {
-			prefix = parts[1]
-			if prefix != "" && !strings.HasSuffix(prefix, "/") {
-				prefix += "/"
-			}
-		}
-		entries, err = p.listObjects(ctx, bucket, prefix)
+		return p.listBuckets(ctx)
 	}
 
-	if err == nil {
-		p.cache.Set(cacheKey, entries)
+	if path == AuditDir {
+		return p.auditFiles().entries(), nil
 	}
-	return entries, err
+
+	// Inside a bucket - list objects
+	parts := strings.SplitN(path, "/", 2)
+	bucket := parts[0]
+	prefix := ""
+	if len(parts) > 1 {
+		prefix = parts[1]
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+	}
+	return p.listObjects(ctx, bucket, prefix)
 }
 
 func (p *S3Provider) listBuckets(ctx context.Context) ([]Entry, error) {
@@ -101,73 +94,108 @@ func (p *S3Provider) listBuckets(ctx context.Context) ([]Entry, error) {
 	return entries, nil
 }
 
-const maxS3Entries = 100
-
 func (p *S3Provider) listObjects(ctx context.Context, bucket, prefix string) ([]Entry, error) {
-	var entries []Entry
-	truncated := false
-
-	resp, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket:    aws.String(bucket),
-		Prefix:    aws.String(prefix),
-		Delimiter: aws.String("/"),
-		MaxKeys:   aws.Int32(maxS3Entries),
-	})
-	if err != nil {
-		return nil, err
+	pageSize := int32(MaxEntries)
+	if pageSize > 1000 {
+		pageSize = 1000
 	}
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		resp, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucket),
+			Prefix:            aws.String(prefix),
+			Delimiter:         aws.String("/"),
+			MaxKeys:           aws.Int32(pageSize),
+			ContinuationToken: optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
 
-	// Add "directories" (common prefixes)
-	for _, cp := range resp.CommonPrefixes {
-		name := strings.TrimPrefix(*cp.Prefix, prefix)
-		name = strings.TrimSuffix(name, "/")
-		if name != "" {
-			entries = append(entries, Entry{
-				Name:  name,
-				IsDir: true,
-			})
+		var entries []Entry
+		// Add "directories" (common prefixes)
+		for _, cp := range resp.CommonPrefixes {
+			name := strings.TrimPrefix(*cp.Prefix, prefix)
+			name = strings.TrimSuffix(name, "/")
+			if name != "" {
+				entries = append(entries, Entry{
+					Name:  name,
+					IsDir: true,
+				})
+			}
 		}
-	}
 
-	// Add files (objects)
-	for _, obj := range resp.Contents {
-		name := strings.TrimPrefix(*obj.Key, prefix)
-		if name != "" && name != "/" {
-			modTime := time.Time{}
-			if obj.LastModified != nil {
-				modTime = *obj.LastModified
+		// Add files (objects)
+		for _, obj := range resp.Contents {
+			name := strings.TrimPrefix(*obj.Key, prefix)
+			if name != "" && name != "/" {
+				modTime := time.Time{}
+				if obj.LastModified != nil {
+					modTime = *obj.LastModified
+				}
+				entries = append(entries, Entry{
+					Name:    name,
+					IsDir:   false,
+					Size:    *obj.Size,
+					ModTime: modTime,
+				})
 			}
-			entries = append(entries, Entry{
-				Name:    name,
-				IsDir:   false,
-				Size:    *obj.Size,
-				ModTime: modTime,
-			})
 		}
+		if !aws.ToBool(resp.IsTruncated) {
+			return entries, "", nil
+		}
+		return entries, aws.ToString(resp.NextContinuationToken), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, s3ListHint(bucket, prefix), err)
 	}
+	return entries, nil
+}
 
-	if resp.IsTruncated != nil && *resp.IsTruncated {
-		truncated = true
-	}
+// S3Object is an object found by Objects
+type S3Object struct {
+	Key     string
+	Size    int64
+	ETag    string // unquoted; the content's MD5 unless uploaded in parts or encrypted with SSE-KMS or SSE-C
+	ModTime time.Time
+}
 
-	// Add indicator file if there are more results
-	if truncated {
-		entries = append(entries, Entry{
-			Name:  "_more_results.txt",
-			IsDir: false,
-			Size:  int64(len(moreResultsMessage(len(entries)))),
-		})
+// Objects lists every object below prefix, recursing into "directories",
+// for tools that compare whole trees
+func (p *S3Provider) Objects(ctx context.Context, bucket, prefix string) ([]S3Object, error) {
+	var objects []S3Object
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range resp.Contents {
+			objects = append(objects, S3Object{
+				Key:     aws.ToString(obj.Key),
+				Size:    aws.ToInt64(obj.Size),
+				ETag:    strings.Trim(aws.ToString(obj.ETag), `"`),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
 	}
-
-	return entries, nil
+	return objects, nil
 }
 
-func moreResultsMessage(shown int) string {
-	return fmt.Sprintf("Showing first %d entries. There are more results not displayed.\n"+
-		"Use AWS CLI for full listing: aws s3 ls s3://bucket/prefix/\n", shown)
+// s3ListHint returns the CLI command listing a bucket prefix in full
+func s3ListHint(bucket, prefix string) string {
+	return "aws s3 ls s3://" + bucket + "/" + prefix
 }
 
 func (p *S3Provider) Read(ctx context.Context, path string) ([]byte, error) {
+	if bucket, ok := cutS3Tags(path); ok {
+		return p.readBucketTags(ctx, bucket)
+	}
+	if file, ok := cutAudit(path); ok {
+		return p.auditFiles().read(ctx, file)
+	}
 	parts := strings.SplitN(path, "/", 2)
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("invalid path: %s", path)
@@ -176,11 +204,6 @@ func (p *S3Provider) Read(ctx context.Context, path string) ([]byte, error) {
 	bucket := parts[0]
 	key := parts[1]
 
-	// Handle virtual _more_results.txt file
-	if strings.HasSuffix(key, "_more_results.txt") {
-		return []byte(moreResultsMessage(maxS3Entries)), nil
-	}
-
 	resp, err := p.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -193,20 +216,49 @@ func (p *S3Provider) Read(ctx context.Context, path string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
-func (p *S3Provider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
+// ReadRange fetches part of an object with a ranged GET
+func (p *S3Provider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) < 2 || length <= 0 {
+		data, err := p.Read(ctx, path)
+		return sliceRange(data, off, length), err
 	}
 
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
+	resp, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(parts[0]),
+		Key:    aws.String(parts[1]),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+length-1)),
+	})
+	if err != nil {
+		// A range starting at or past the end of the object
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return entry, err
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// StatBatch answers lookups of many objects from one listing of their
+// prefix instead of a ListObjectsV2 and HeadObject per object. Entries
+// don't carry checksums; Stat still does.
+func (p *S3Provider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	return statFromListings(ctx, p, paths)
 }
 
-func (p *S3Provider) statUncached(ctx context.Context, path string) (*Entry, error) {
+func (p *S3Provider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if entry, ok, err := p.auditFiles().stat(path); ok {
+		return entry, err
+	}
+	if bucket, ok := cutS3Tags(path); ok {
+		if _, err := p.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			return nil, err
+		}
+		return &Entry{Name: path, IsDir: false, Size: 4096}, nil
+	}
 	parts := strings.SplitN(path, "/", 2)
 	bucket := parts[0]
 
@@ -227,15 +279,6 @@ func (p *S3Provider) statUncached(ctx context.Context, path string) (*Entry, err
 
 	key := parts[1]
 
-	// Handle virtual _more_results.txt file
-	if strings.HasSuffix(key, "_more_results.txt") {
-		return &Entry{
-			Name:  "_more_results.txt",
-			IsDir: false,
-			Size:  int64(len(moreResultsMessage(maxS3Entries))),
-		}, nil
-	}
-
 	// Check if it's a "directory" (prefix with objects under it)
 	listResp, err := p.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket:  aws.String(bucket),
@@ -251,8 +294,9 @@ func (p *S3Provider) statUncached(ctx context.Context, path string) (*Entry, err
 
 	// Try to get object metadata
 	resp, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
 	})
 	if err != nil {
 		return nil, err
@@ -269,14 +313,55 @@ func (p *S3Provider) statUncached(ctx context.Context, path string) (*Entry, err
 	}
 
 	return &Entry{
-		Name:    key,
-		IsDir:   false,
-		Size:    size,
-		ModTime: modTime,
+		Name:      key,
+		IsDir:     false,
+		Size:      size,
+		ModTime:   modTime,
+		Checksums: s3Checksums(resp),
 	}, nil
 }
 
+// s3Checksums returns an object's ETag, the ETag again as its MD5 if it is
+// one and, if it was uploaded with one, its SHA-256 in hex. Multipart
+// uploads have composite values suffixed "-<parts>" that only match a
+// re-upload with the same part size, and the ETag of objects encrypted
+// with SSE-KMS or SSE-C isn't their MD5 at all.
+func s3Checksums(resp *s3.HeadObjectOutput) map[string]string {
+	checksums := make(map[string]string)
+	if etag := strings.Trim(aws.ToString(resp.ETag), `"`); etag != "" {
+		checksums["etag"] = etag
+		encrypted := strings.HasPrefix(string(resp.ServerSideEncryption), "aws:kms") || resp.SSECustomerAlgorithm != nil
+		if !encrypted && !strings.Contains(etag, "-") {
+			checksums["md5"] = etag
+		}
+	}
+	if sum := aws.ToString(resp.ChecksumSHA256); sum != "" {
+		digest, parts, _ := strings.Cut(sum, "-")
+		if raw, err := base64.StdEncoding.DecodeString(digest); err == nil {
+			checksums["sha256"] = hex.EncodeToString(raw)
+			if parts != "" {
+				checksums["sha256"] += "-" + parts
+			}
+		}
+	}
+	return checksums
+}
+
+// Writable reports whether objects can be written at path. The bucket list
+// itself is read-only except for the buckets' tags sidecars; anything
+// inside a bucket is writable. Bucket names can't start with "_", so
+// AuditDir is never a bucket.
+func (p *S3Provider) Writable(path string) bool {
+	return path != "" && !inAudit(path)
+}
+
 func (p *S3Provider) Write(ctx context.Context, path string, data []byte) error {
+	if inAudit(path) {
+		return fs.ErrPermission
+	}
+	if bucket, ok := cutS3Tags(path); ok {
+		return p.writeBucketTags(ctx, bucket, path, data)
+	}
 	parts := strings.SplitN(path, "/", 2)
 	if len(parts) < 2 {
 		return fmt.Errorf("invalid path: %s", path)
@@ -286,21 +371,35 @@ func (p *S3Provider) Write(ctx context.Context, path string, data []byte) error
 	key := parts[1]
 
 	_, err := p.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType(key, data)),
 	})
-	if err != nil {
-		return err
-	}
+	return err
+}
 
-	// Invalidate cache for parent directory
-	p.invalidateCache(path, parts[0])
+// Written returns objects as written; tags and audit files are generated
+func (p *S3Provider) Written(path string, data []byte) ([]byte, bool) {
+	if _, ok := cutS3Tags(path); ok || inAudit(path) {
+		return nil, false
+	}
+	return data, true
+}
 
-	return nil
+// contentType infers an object's Content-Type from its extension, falling
+// back to sniffing the content, so objects served from S3 render correctly
+func contentType(key string, data []byte) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
 }
 
 func (p *S3Provider) Delete(ctx context.Context, path string) error {
+	if inAudit(path) {
+		return fs.ErrPermission
+	}
 	parts := strings.SplitN(path, "/", 2)
 	if len(parts) < 2 {
 		return fmt.Errorf("invalid path: %s", path)
@@ -313,23 +412,80 @@ func (p *S3Provider) Delete(ctx context.Context, path string) error {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
-	if err != nil {
-		return err
+	return err
+}
+
+// S3TagsSuffix marks the sidecar next to a bucket holding its tags, e.g.
+// "my-bucket.tags.json" for "my-bucket". Sidecars aren't listed but can be
+// read and written like regular files.
+const S3TagsSuffix = ".tags.json"
+
+// cutS3Tags returns the bucket whose tags sidecar path is
+func cutS3Tags(path string) (string, bool) {
+	if strings.Contains(path, "/") {
+		return "", false
 	}
+	bucket, ok := strings.CutSuffix(path, S3TagsSuffix)
+	return bucket, ok && bucket != ""
+}
 
-	// Invalidate cache
-	p.invalidateCache(path, bucket)
+func isS3TagsFile(path string) bool {
+	_, ok := cutS3Tags(path)
+	return ok
+}
 
-	return nil
+// bucketTags returns a bucket's tags; a bucket without any has no tag set
+func (p *S3Provider) bucketTags(ctx context.Context, bucket string) (map[string]string, error) {
+	resp, err := p.client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucket),
+	})
+	if isAPIError(err, "NoSuchTagSet") {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(resp.TagSet))
+	for _, tag := range resp.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
 }
 
-func (p *S3Provider) invalidateCache(path, bucket string) {
-	parentPath := path
-	if idx := strings.LastIndex(path, "/"); idx > 0 {
-		parentPath = path[:idx]
-	} else {
-		parentPath = bucket
+func (p *S3Provider) readBucketTags(ctx context.Context, bucket string) ([]byte, error) {
+	tags, err := p.bucketTags(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return renderTags(tags)
+}
+
+// writeBucketTags replaces a bucket's tag set. S3 has no per-key calls, so
+// the whole set is put, or deleted when the sidecar is written empty.
+func (p *S3Provider) writeBucketTags(ctx context.Context, bucket, path string, data []byte) error {
+	tags, err := parseTags(data)
+	if err != nil {
+		return invalidf("%s: %v", path, err)
 	}
-	p.cache.Delete("readdir:" + parentPath)
-	p.cache.Delete("stat:" + path)
+	if len(tags) == 0 {
+		_, err := p.client.DeleteBucketTagging(ctx, &s3.DeleteBucketTaggingInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tagSet := make([]types.Tag, len(keys))
+	for i, k := range keys {
+		tagSet[i] = types.Tag{Key: aws.String(k), Value: aws.String(tags[k])}
+	}
+	_, err = p.client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	return err
 }
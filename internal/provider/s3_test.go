@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+func TestS3ListObjectsPaged(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "s3")
+	p := Chain(newS3Provider(cfg), Paged(paging.NewCursors()))
+
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+
+	entries, err := p.ReadDir(context.Background(), "my-bucket/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "s3/logs.json", entries)
+
+	entries, err = p.ReadDir(context.Background(), "my-bucket/logs/_page2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "s3/logs-page2.json", entries)
+}
+
+func TestS3StatChecksums(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "s3")
+	p := newS3Provider(cfg)
+
+	entry, err := p.Stat(context.Background(), "my-bucket/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"etag":   "5d41402abc4b2a76b9719d911017c592",
+		"md5":    "5d41402abc4b2a76b9719d911017c592",
+		"sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}
+	if !reflect.DeepEqual(entry.Checksums, want) {
+		t.Errorf("Checksums = %v, want %v", entry.Checksums, want)
+	}
+
+	// The ETags of encrypted and multipart objects aren't their MD5
+	for _, head := range []*s3.HeadObjectOutput{
+		{ETag: aws.String(`"abcd"`), ServerSideEncryption: types.ServerSideEncryptionAwsKms},
+		{ETag: aws.String(`"abcd"`), SSECustomerAlgorithm: aws.String("AES256")},
+		{ETag: aws.String(`"abcd-2"`)},
+	} {
+		if sum, ok := s3Checksums(head)["md5"]; ok {
+			t.Errorf("md5 of %+v = %q, want none", head, sum)
+		}
+	}
+}
+
+func TestS3StatBatchListsOnce(t *testing.T) {
+	cfg, client := fixtureConfig(t, "s3")
+	p := newS3Provider(cfg)
+
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+
+	entries, err := p.StatBatch(context.Background(), []string{"my-bucket/logs/app.log", "my-bucket/logs/2024"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := entries["my-bucket/logs/app.log"]; e == nil || e.Size != 42 {
+		t.Errorf("app.log = %+v, want 42 bytes", e)
+	}
+	if e := entries["my-bucket/logs/2024"]; e == nil || !e.IsDir {
+		t.Errorf("2024 = %+v, want a directory", e)
+	}
+	if calls := client.Calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single ListObjectsV2", calls)
+	}
+}
+
+func TestS3BucketTags(t *testing.T) {
+	cfg, client := fixtureConfig(t, "s3")
+	p := newS3Provider(cfg)
+	ctx := context.Background()
+
+	data, err := p.Read(ctx, "my-bucket.tags.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"team\": \"data\"\n}"; string(data) != want {
+		t.Errorf("tags = %s, want %s", data, want)
+	}
+	// A bucket without tags has an empty sidecar rather than an error
+	if data, err := p.Read(ctx, "empty-bucket.tags.json"); err != nil || string(data) != "{}" {
+		t.Errorf("untagged bucket = %s, %v", data, err)
+	}
+
+	if err := p.Write(ctx, "my-bucket.tags.json", []byte(`{"team": "data", "owner": "me"}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GetBucketTagging", "GetBucketTagging", "PutBucketTagging"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
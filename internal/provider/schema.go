@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Schema describes a kind of document a service generates, for the
+// schemas under help/schemas/<service>/
+type Schema struct {
+	Name string       // the schema's file is <Name>.schema.json
+	Path string       // where the documents appear in the service, e.g. "<instance-id>/info.json"
+	Type reflect.Type // what the documents are marshalled from
+}
+
+func schemaOf[T any](name, path string) Schema {
+	return Schema{Name: name, Path: path, Type: reflect.TypeFor[T]()}
+}
+
+// schemas are the documents whose content has a Go type, by service.
+// Documents passed through from APIs without an SDK module here, e.g.
+// EMR's info.json, have none.
+var schemas = map[string][]Schema{
+	"ec2": {
+		schemaOf[ec2types.Instance]("instance", "<instance-id>/info.json"),
+		schemaOf[[]ec2types.GroupIdentifier]("security-groups", "<instance-id>/security-groups.json"),
+		schemaOf[map[string]string]("tags", "<instance-id>/tags.json"),
+	},
+	"vpc": {
+		schemaOf[ec2types.Vpc]("vpc", "<vpc-id>/info.json"),
+		schemaOf[vpcSummary]("summary", "<vpc-id>/summary.json"),
+		schemaOf[ec2types.Subnet]("subnet", "<vpc-id>/subnets/<subnet-id>.json"),
+		schemaOf[ec2types.RouteTable]("route-table", "<vpc-id>/route-tables/<route-table-id>.json"),
+		schemaOf[ec2types.SecurityGroup]("security-group", "<vpc-id>/security-groups/<group-id>.json"),
+		schemaOf[sgReferences]("referenced-by", "<vpc-id>/security-groups/<group-id>"+sgReferencesSuffix),
+	},
+	"iam": {
+		schemaOf[iamtypes.User]("user", "users/<name>/info.json"),
+		schemaOf[iamRoleDocument]("role", "roles/<name>/info.json"),
+		schemaOf[iamtypes.Group]("group", "groups/<name>/info.json"),
+	},
+	"lambda": {
+		schemaOf[lambdatypes.FunctionConfiguration]("config", "<function>/config.json"),
+		schemaOf[map[string]string]("env", "<function>/env.json"),
+		schemaOf[[]lambdatypes.Layer]("layers", "<function>/layers.json"),
+		schemaOf[lambdaConcurrency]("concurrency", "<function>/concurrency.json"),
+		schemaOf[lambdaURLConfig]("url-config", "<function>/url-config.json"),
+	},
+	"dynamodb": {
+		schemaOf[dynamodbtypes.TableDescription]("table", "<table>/info.json"),
+	},
+	"ssm": {
+		schemaOf[SSMMetadata]("meta", "<path>/<name>@meta.json"),
+	},
+	"dms": {
+		schemaOf[dmsStatistics]("statistics", "<task>/statistics.json"),
+	},
+	"datasync": {
+		schemaOf[dataSyncStatistics]("statistics", "<task-id>/statistics.json"),
+	},
+	"elb": {
+		schemaOf[elbLoadBalancer]("load-balancer", "<lb>/info.json"),
+		schemaOf[elbListener]("listener", "<lb>/listeners/<protocol>-<port>.json"),
+		schemaOf[elbTargetGroup]("target-group", "<lb>/target-groups/<group>/info.json"),
+		schemaOf[elbHealth]("health", "<lb>/target-groups/<group>/health.json"),
+	},
+	"topology": {
+		schemaOf[topology]("edges", "edges.json"),
+	},
+}
+
+// iamRoleDocument is a role's info.json, whose trust policy is decoded
+// into the document instead of the URL-encoded string IAM returns
+type iamRoleDocument struct {
+	iamtypes.Role
+	AssumeRolePolicyDocument any
+}
+
+// Schemas returns the schemas of the documents service generates, by name
+func Schemas(service string) []Schema {
+	return schemas[service]
+}
+
+// JSON returns the JSON Schema (draft 2020-12) of the documents as
+// encoding/json writes them. SDK structs become $defs named after their
+// type, and SDK enums list their known values as examples, as services
+// add values without notice.
+func (s Schema) JSON(service string) ([]byte, error) {
+	b := &schemaBuilder{defs: make(map[string]any), names: make(map[reflect.Type]string)}
+	root := b.schema(s.Type)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = service + "/" + s.Path
+	if len(b.defs) > 0 {
+		root["$defs"] = b.defs
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // paths have placeholders like <instance-id>
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// schemaBuilder collects the $defs of a schema
+type schemaBuilder struct {
+	defs  map[string]any
+	names map[reflect.Type]string // def names of the structs seen
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schema returns the schema of values of t. Pointers, slices, maps and
+// interfaces may also be null, as encoding/json writes them when nil.
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		if t.Kind() == reflect.Interface || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+			return b.value(t)
+		}
+		return map[string]any{"anyOf": []any{b.value(t), map[string]any{"type": "null"}}}
+	}
+	return b.value(t)
+}
+
+// value returns the schema of non-null values of t
+func (b *schemaBuilder) value(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.value(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		s := map[string]any{"type": "string"}
+		if values := enumValues(t); len(values) > 0 {
+			s["examples"] = values
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.object(t)
+	}
+	return map[string]any{} // interfaces: any value
+}
+
+// enumValues returns the known values of an SDK enum, whose types have a
+// Values method listing them
+func enumValues(t reflect.Type) []string {
+	m, ok := t.MethodByName("Values")
+	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || m.Type.Out(0).Kind() != reflect.Slice {
+		return nil
+	}
+	out := m.Func.Call([]reflect.Value{reflect.Zero(t)})[0]
+	values := make([]string, out.Len())
+	for i := range values {
+		values[i] = out.Index(i).String()
+	}
+	return values
+}
+
+// object returns a reference to the def of struct t, adding it first
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	if t.Name() == "" {
+		return b.properties(t)
+	}
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := b.defs[name]; taken {
+			// e.g. ec2 and iam both have a Tag
+			pkg := t.PkgPath()
+			pkg = strings.TrimSuffix(pkg, "/types")
+			name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+		}
+		b.names[t] = name
+		b.defs[name] = nil // placeholder for recursive types
+		b.defs[name] = b.properties(t)
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+// properties returns the object schema of struct t, with the fields
+// encoding/json writes
+func (b *schemaBuilder) properties(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	required := make(map[string]bool)
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				add(f.Type) // promoted fields
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			// Fields of t come after those promoted from its embedded
+			// structs, so they replace them as in encoding/json
+			props[name] = b.schema(f.Type)
+			required[name] = !strings.Contains(opts, "omitempty")
+		}
+	}
+	add(t)
+	s := map[string]any{"type": "object", "properties": props}
+	var names []string
+	for name, ok := range required {
+		if ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		s["required"] = names
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemas(t *testing.T) {
+	for service, list := range schemas {
+		for _, s := range list {
+			data, err := s.JSON(service)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", service, s.Name, err)
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Errorf("%s/%s: %v", service, s.Name, err)
+			}
+		}
+	}
+
+	data, err := schemaOf[iamRoleDocument]("role", "roles/<name>/info.json").JSON("iam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var role struct {
+		Title string
+		Ref   string `json:"$ref"`
+		Defs  map[string]struct {
+			Properties map[string]map[string]any
+			Required   []string
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &role); err != nil {
+		t.Fatal(err)
+	}
+	if role.Title != "iam/roles/<name>/info.json" || role.Ref != "#/$defs/iamRoleDocument" {
+		t.Errorf("title %q, $ref %q", role.Title, role.Ref)
+	}
+	doc := role.Defs["iamRoleDocument"]
+	if trust := doc.Properties["AssumeRolePolicyDocument"]; len(trust) != 0 {
+		t.Errorf("trust policy = %v, want any value", trust)
+	}
+	if created := doc.Properties["CreateDate"]; created["anyOf"] == nil {
+		t.Errorf("CreateDate = %v, want a nullable date-time", created)
+	}
+	if len(doc.Required) != len(doc.Properties) {
+		t.Errorf("required = %v, want each property once", doc.Required)
+	}
+	if tags := doc.Properties["Tags"]; tags == nil {
+		t.Error("Tags missing")
+	}
+}
+
+func TestSchemaEnums(t *testing.T) {
+	data, err := Schemas("ec2")[0].JSON("ec2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Examples []string
+			}
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	states := doc.Defs["InstanceState"].Properties["Name"].Examples
+	found := false
+	for _, s := range states {
+		found = found || s == "running"
+	}
+	if !found {
+		t.Errorf("InstanceState.Name examples = %v, want running among them", states)
+	}
+}
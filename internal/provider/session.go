@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SessionCall is a single provider call captured by a SessionRecorder.
+// Sessions are stored as JSON lines, one call per line, so a recording
+// stays usable even if sisu exits without closing it.
+type SessionCall struct {
+	// Provider identifies the provider as "profile/region/service"
+	Provider string  `json:"provider"`
+	Op       Op      `json:"op"`
+	Path     string  `json:"path"`
+	Entries  []Entry `json:"entries,omitempty"`
+	Data     []byte  `json:"data,omitempty"`
+	Entry    *Entry  `json:"entry,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// ErrorKind preserves errors the filesystem maps to specific statuses
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+const (
+	errKindNotExist   = "not_exist"
+	errKindPermission = "permission"
+	errKindDeadline   = "deadline"
+)
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return errKindNotExist
+	case errors.Is(err, fs.ErrPermission):
+		return errKindPermission
+	case errors.Is(err, context.DeadlineExceeded):
+		return errKindDeadline
+	}
+	return ""
+}
+
+// err rebuilds the recorded error, wrapping the matching sentinel
+func (c *SessionCall) err() error {
+	if c.Error == "" {
+		return nil
+	}
+	switch c.ErrorKind {
+	case errKindNotExist:
+		return fmt.Errorf("%s: %w", c.Error, fs.ErrNotExist)
+	case errKindPermission:
+		return fmt.Errorf("%s: %w", c.Error, fs.ErrPermission)
+	case errKindDeadline:
+		return fmt.Errorf("%s: %w", c.Error, context.DeadlineExceeded)
+	}
+	return errors.New(c.Error)
+}
+
+// SessionRecorder writes every provider call and its result to a file
+type SessionRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// NewSessionRecorder creates (or truncates) the recording at path
+func NewSessionRecorder(path string) (*SessionRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	return &SessionRecorder{file: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Middleware records calls made to the provider identified by key
+// ("profile/region/service")
+func (r *SessionRecorder) Middleware(key string) Middleware {
+	return func(p Provider) Provider {
+		return &recordingProvider{Provider: p, key: key, rec: r}
+	}
+}
+
+func (r *SessionRecorder) record(call SessionCall, err error) {
+	if err != nil {
+		call.Error = err.Error()
+		call.ErrorKind = errorKind(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(call); err != nil {
+		if Debug {
+			log.Printf("[record] failed to write %s %q: %v", call.Op, call.Path, err)
+		}
+		return
+	}
+	r.w.Flush()
+}
+
+// Close flushes and closes the recording
+func (r *SessionRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// recordingProvider captures results as well as errors, so it can't be
+// built on Intercept which only sees the error
+type recordingProvider struct {
+	Provider
+	key string
+	rec *SessionRecorder
+}
+
+func (p *recordingProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.Provider.ReadDir(ctx, path)
+	p.rec.record(SessionCall{Provider: p.key, Op: OpReadDir, Path: path, Entries: entries}, err)
+	return entries, err
+}
+
+func (p *recordingProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := p.Provider.Read(ctx, path)
+	p.rec.record(SessionCall{Provider: p.key, Op: OpRead, Path: path, Data: data}, err)
+	return data, err
+}
+
+func (p *recordingProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	entry, err := p.Provider.Stat(ctx, path)
+	p.rec.record(SessionCall{Provider: p.key, Op: OpStat, Path: path, Entry: entry}, err)
+	return entry, err
+}
+
+func (p *recordingProvider) Write(ctx context.Context, path string, data []byte) error {
+	err := p.Provider.Write(ctx, path, data)
+	p.rec.record(SessionCall{Provider: p.key, Op: OpWrite, Path: path, Data: data}, err)
+	return err
+}
+
+func (p *recordingProvider) Delete(ctx context.Context, path string) error {
+	err := p.Provider.Delete(ctx, path)
+	p.rec.record(SessionCall{Provider: p.key, Op: OpDelete, Path: path}, err)
+	return err
+}
+
+func (p *recordingProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *recordingProvider) Close() {
+	Close(p.Provider)
+}
+
+// Session is a loaded recording that can serve providers without AWS access.
+// Calls are answered in the order they were recorded; once a call's
+// responses are used up the last one is repeated, so replaying a session
+// shows the same before/after states as the original.
+type Session struct {
+	mu    sync.Mutex
+	calls map[string][]*SessionCall // "provider op path" -> responses in order
+	next  map[string]int
+	keys  map[string]bool
+}
+
+// LoadSession reads a recording created by SessionRecorder
+func LoadSession(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	s := &Session{
+		calls: make(map[string][]*SessionCall),
+		next:  make(map[string]int),
+		keys:  make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var call SessionCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s line %d: %w", path, line, err)
+		}
+		s.keys[call.Provider] = true
+		if call.Op.IsMutation() {
+			continue
+		}
+		k := sessionKey(call.Provider, call.Op, call.Path)
+		s.calls[k] = append(s.calls[k], &call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return s, nil
+}
+
+func sessionKey(provider string, op Op, path string) string {
+	return provider + " " + string(op) + " " + path
+}
+
+// Profiles returns the profiles that appear in the recording
+func (s *Session) Profiles() []string {
+	return s.keyParts(0)
+}
+
+// Regions returns the regions that appear in the recording
+func (s *Session) Regions() []string {
+	return s.keyParts(1)
+}
+
+func (s *Session) keyParts(i int) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for k := range s.keys {
+		parts := strings.SplitN(k, "/", 3)
+		if len(parts) == 3 && !seen[parts[i]] {
+			seen[parts[i]] = true
+			result = append(result, parts[i])
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Provider returns a read-only provider serving the recorded calls of key
+// ("profile/region/service"). Calls that were never recorded fail with
+// fs.ErrNotExist.
+func (s *Session) Provider(key, name string) Provider {
+	return &replayProvider{session: s, key: key, name: name}
+}
+
+func (s *Session) lookup(provider string, op Op, path string) (*SessionCall, error) {
+	k := sessionKey(provider, op, path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := s.calls[k]
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("not in recording: %s %s %q: %w", provider, op, path, fs.ErrNotExist)
+	}
+	i := s.next[k]
+	if i < len(calls)-1 {
+		s.next[k] = i + 1
+	}
+	return calls[i], nil
+}
+
+type replayProvider struct {
+	ReadOnlyProvider
+	session *Session
+	key     string
+	name    string
+}
+
+func (p *replayProvider) Name() string { return p.name }
+
+func (p *replayProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	call, err := p.session.lookup(p.key, OpReadDir, path)
+	if err != nil {
+		return nil, err
+	}
+	return call.Entries, call.err()
+}
+
+func (p *replayProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	call, err := p.session.lookup(p.key, OpRead, path)
+	if err != nil {
+		return nil, err
+	}
+	return call.Data, call.err()
+}
+
+func (p *replayProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	call, err := p.session.lookup(p.key, OpStat, path)
+	if err != nil {
+		return nil, err
+	}
+	return call.Entry, call.err()
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSessionReplaysRecordedCalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	rec, err := NewSessionRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := newFakeProvider(map[string][]byte{"app/db": []byte("old")})
+	p := Chain(fake, rec.Middleware("default/eu-west-1/ssm"))
+	ctx := context.Background()
+
+	p.Read(ctx, "app/db")
+	p.Write(ctx, "app/db", []byte("new"))
+	p.Read(ctx, "app/db")
+	p.Read(ctx, "app/missing")
+	entries, _ := p.ReadDir(ctx, "app")
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := session.Profiles(); !reflect.DeepEqual(got, []string{"default"}) {
+		t.Errorf("Profiles = %v", got)
+	}
+	if got := session.Regions(); !reflect.DeepEqual(got, []string{"eu-west-1"}) {
+		t.Errorf("Regions = %v", got)
+	}
+
+	replay := session.Provider("default/eu-west-1/ssm", "ssm")
+	for _, want := range []string{"old", "new", "new"} {
+		data, err := replay.Read(ctx, "app/db")
+		if err != nil || string(data) != want {
+			t.Errorf("Read = %q, %v; want %q", data, err, want)
+		}
+	}
+	if _, err := replay.Read(ctx, "app/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("recorded error = %v, want ErrNotExist", err)
+	}
+	if _, err := replay.Read(ctx, "app/never-read"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unrecorded call = %v, want ErrNotExist", err)
+	}
+	got, err := replay.ReadDir(ctx, "app")
+	if err != nil || len(got) != len(entries) || got[0].Name != entries[0].Name {
+		t.Errorf("ReadDir = %v, %v; want %v", got, err, entries)
+	}
+	if replay.Writable("app/db") {
+		t.Error("replayed provider should be read-only")
+	}
+}
@@ -0,0 +1,58 @@
+package provider
+
+import "strings"
+
+// readSideEffects recognize, by service, the files whose reads change the
+// service or compete with its workloads, e.g. sqs peek.json, which
+// receives messages and so moves them towards the dead-letter queue, or
+// kinesis tail, whose GetRecords calls share each shard's read limit with
+// the stream's consumers.
+// Tools reading whole subtrees (mirror, audit compare) leave them out;
+// they are only read when asked for by name.
+var readSideEffects = map[string]func(path string) bool{
+	"sqs":     isSQSPeekFile,
+	"kinesis": isKinesisTailFile,
+}
+
+// HasReadSideEffects reports whether reading path, relative to the
+// directory of service, changes the service or competes with its
+// workloads
+func HasReadSideEffects(service, path string) bool {
+	is, ok := readSideEffects[service]
+	return ok && is(path)
+}
+
+// volatileFiles recognize, by service, the files whose content changes
+// from one read to the next while the resource stays the same, e.g. iam
+// last-accessed.json, a report generated on each read, or cloudwatch
+// recent-datapoints.json. Comparing or mirroring them only reports noise.
+var volatileFiles = map[string]func(path string) bool{
+	"iam":        fileNamed("last-accessed.json"),
+	"cloudwatch": fileNamed("recent-datapoints.json"),
+}
+
+// IsVolatile reports whether path, relative to the directory of service,
+// is a file whose content changes on its own between reads
+func IsVolatile(service, path string) bool {
+	is, ok := volatileFiles[service]
+	return ok && is(path)
+}
+
+// fileNamed matches paths whose last segment is name
+func fileNamed(name string) func(path string) bool {
+	return func(path string) bool {
+		return path == name || strings.HasSuffix(path, "/"+name)
+	}
+}
+
+// isSQSPeekFile reports whether path is the peek file of a queue
+func isSQSPeekFile(path string) bool {
+	queue, name, ok := strings.Cut(path, "/")
+	return ok && queue != "" && name == sqsPeekFile
+}
+
+// isKinesisTailFile reports whether path is the tail file of a stream
+func isKinesisTailFile(path string) bool {
+	stream, name, ok := strings.Cut(path, "/")
+	return ok && stream != "" && name == kinesisTailFile
+}
@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot limits: files bigger than snapshotMaxFile are listed but not
+// kept, and subtrees with more than snapshotMaxFiles files can't be pinned
+const (
+	snapshotMaxFile  = 1 << 20
+	snapshotMaxFiles = 10000
+)
+
+// Snapshot is a persistent copy of one subtree of a provider, served in
+// its place when AWS can't be reached
+type Snapshot struct {
+	Root  string             `json:"root"` // provider path of the subtree
+	Taken time.Time          `json:"taken"`
+	Dirs  map[string][]Entry `json:"dirs"`
+	Files map[string][]byte  `json:"files"`
+}
+
+// TakeSnapshot walks root in p, listing every directory and reading every
+// file up to 1 MB. Files that fail to read are left out, unless the failure
+// means AWS is unreachable.
+func TakeSnapshot(ctx context.Context, p Provider, root string) (*Snapshot, error) {
+	s := &Snapshot{
+		Root:  root,
+		Taken: time.Now(),
+		Dirs:  make(map[string][]Entry),
+		Files: make(map[string][]byte),
+	}
+	ctx = withoutSnapshots(ctx)
+
+	entry, err := p.Stat(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir {
+		data, err := p.Read(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		s.Files[root] = data
+		return s, nil
+	}
+
+	var mu sync.Mutex
+	var walk func(ctx context.Context, dir string) error
+	walk = func(ctx context.Context, dir string) error {
+		entries, err := p.ReadDir(ctx, dir)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		s.Dirs[dir] = entries
+		tooMany := len(s.Files) > snapshotMaxFiles
+		mu.Unlock()
+		if tooMany {
+			return fmt.Errorf("%s has more than %d files, pin a smaller subtree", root, snapshotMaxFiles)
+		}
+
+		return FanOut(ctx, FanOutLimit, len(entries), func(ctx context.Context, i int) error {
+			child := joinPath(dir, entries[i].Name)
+			if entries[i].IsDir {
+				return walk(ctx, child)
+			}
+			if entries[i].Size > snapshotMaxFile {
+				return nil
+			}
+			data, err := p.Read(ctx, child)
+			if err != nil {
+				if IsUnreachable(err) {
+					return err
+				}
+				return nil
+			}
+			mu.Lock()
+			s.Files[child] = data
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := walk(ctx, root); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// LoadSnapshot reads a snapshot saved with Save
+func LoadSnapshot(file string) (*Snapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return &s, nil
+}
+
+// Save writes the snapshot to file, replacing it atomically
+func (s *Snapshot) Save(file string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// stat returns the entry for path, preferring the one from its parent's listing
+func (s *Snapshot) stat(path string) (*Entry, bool) {
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i], path[i+1:]
+	}
+	for _, e := range s.Dirs[dir] {
+		if e.Name == name {
+			return &e, true
+		}
+	}
+	if _, ok := s.Dirs[path]; ok {
+		return &Entry{Name: name, IsDir: true, ModTime: s.Taken}, true
+	}
+	if data, ok := s.Files[path]; ok {
+		return &Entry{Name: name, Size: int64(len(data)), ModTime: s.Taken}, true
+	}
+	return nil, false
+}
+
+// IsUnreachable reports whether err means AWS couldn't be reached at all,
+// rather than that it answered with an error
+func IsUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Snapshots holds the snapshots of one provider's pinned subtrees. It is
+// safe for concurrent use, so snapshots can be refreshed while mounted.
+type Snapshots struct {
+	mu     sync.RWMutex
+	byRoot map[string]*Snapshot
+}
+
+// Put adds s, replacing any earlier snapshot of the same subtree
+func (ss *Snapshots) Put(s *Snapshot) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.byRoot == nil {
+		ss.byRoot = make(map[string]*Snapshot)
+	}
+	ss.byRoot[s.Root] = s
+}
+
+// Remove drops the snapshot of the subtree at root
+func (ss *Snapshots) Remove(root string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.byRoot, root)
+}
+
+// covering returns the snapshot whose subtree contains path
+func (ss *Snapshots) covering(path string) *Snapshot {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	for root, s := range ss.byRoot {
+		if root == "" || path == root || strings.HasPrefix(path, root+"/") {
+			return s
+		}
+	}
+	return nil
+}
+
+type snapshotsOffKey struct{}
+
+// withoutSnapshots marks ctx so Offline passes errors through, so taking a
+// snapshot while AWS is unreachable fails instead of copying the old one
+func withoutSnapshots(ctx context.Context) context.Context {
+	return context.WithValue(ctx, snapshotsOffKey{}, true)
+}
+
+// Offline returns a middleware that serves listings, reads and stats from
+// snapshots when AWS can't be reached. Calls that get an answer from AWS,
+// including errors, are passed through unchanged.
+func Offline(snapshots *Snapshots) Middleware {
+	return func(p Provider) Provider {
+		return &offlineProvider{Provider: p, snapshots: snapshots}
+	}
+}
+
+type offlineProvider struct {
+	Provider
+	snapshots *Snapshots
+}
+
+// fallback returns the snapshot to serve path from after err, if any
+func (p *offlineProvider) fallback(ctx context.Context, path string, err error) *Snapshot {
+	if err == nil || !IsUnreachable(err) || ctx.Value(snapshotsOffKey{}) != nil {
+		return nil
+	}
+	s := p.snapshots.covering(path)
+	if s != nil && Debug {
+		log.Printf("[offline] serving %s from snapshot taken %s: %v", path, s.Taken.Format(time.RFC3339), err)
+	}
+	return s
+}
+
+func (p *offlineProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.Provider.ReadDir(ctx, path)
+	if s := p.fallback(ctx, path, err); s != nil {
+		if cached, ok := s.Dirs[path]; ok {
+			return cached, nil
+		}
+	}
+	return entries, err
+}
+
+func (p *offlineProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := p.Provider.Read(ctx, path)
+	if s := p.fallback(ctx, path, err); s != nil {
+		if cached, ok := s.Files[path]; ok {
+			return cached, nil
+		}
+	}
+	return data, err
+}
+
+func (p *offlineProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	data, err := ReadRange(ctx, p.Provider, path, off, length)
+	if s := p.fallback(ctx, path, err); s != nil {
+		if cached, ok := s.Files[path]; ok {
+			return sliceRange(cached, off, length), nil
+		}
+	}
+	return data, err
+}
+
+func (p *offlineProvider) Prefetch(ctx context.Context, path string) (map[string][]byte, error) {
+	files, err := Prefetch(ctx, p.Provider, path)
+	if s := p.fallback(ctx, path, err); s != nil {
+		if cached, ok := s.Files[path]; ok {
+			return map[string][]byte{path: cached}, nil
+		}
+	}
+	return files, err
+}
+
+func (p *offlineProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	entry, err := p.Provider.Stat(ctx, path)
+	if s := p.fallback(ctx, path, err); s != nil {
+		if cached, ok := s.stat(path); ok {
+			return cached, nil
+		}
+	}
+	return entry, err
+}
+
+func (p *offlineProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	entries, err := StatBatch(ctx, p.Provider, paths)
+	if len(paths) == 0 || err == nil || !IsUnreachable(err) {
+		return entries, err
+	}
+	cached := make(map[string]*Entry, len(paths))
+	for _, path := range paths {
+		if s := p.fallback(ctx, path, err); s != nil {
+			if entry, ok := s.stat(path); ok {
+				cached[path] = entry
+			}
+		}
+	}
+	if len(cached) == 0 {
+		return entries, err
+	}
+	return cached, nil
+}
+
+func (p *offlineProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *offlineProvider) Close() {
+	Close(p.Provider)
+}
+
+func (p *offlineProvider) Written(path string, data []byte) ([]byte, bool) {
+	return Written(p.Provider, path, data)
+}
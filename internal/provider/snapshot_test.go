@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+// treeProvider extends fakeProvider with directories implied by file paths
+type treeProvider struct {
+	*fakeProvider
+}
+
+func (p treeProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.fakeProvider.ReadDir(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for name := range p.files {
+		rel, ok := strings.CutPrefix(name, path+"/")
+		if sub, _, nested := strings.Cut(rel, "/"); ok && nested && !seen[sub] {
+			seen[sub] = true
+			entries = append(entries, Entry{Name: sub, IsDir: true})
+		}
+	}
+	return entries, nil
+}
+
+func (p treeProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	for name := range p.files {
+		if strings.HasPrefix(name, path+"/") {
+			return &Entry{Name: filepath.Base(path), IsDir: true}, nil
+		}
+	}
+	return p.fakeProvider.Stat(ctx, path)
+}
+
+func TestSnapshotServedWhenUnreachable(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{
+		"app/db-url":     []byte("postgres://db"),
+		"app/feature/on": []byte("true"),
+		"other/unpinned": []byte("x"),
+	})
+	live := treeProvider{fake}
+	ctx := context.Background()
+
+	s, err := TakeSnapshot(ctx, live, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 2 || len(s.Dirs) != 2 {
+		t.Fatalf("snapshot has %d files and %d dirs, want 2 and 2", len(s.Files), len(s.Dirs))
+	}
+
+	file := filepath.Join(t.TempDir(), "app.json")
+	if err := s.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshots Snapshots
+	snapshots.Put(loaded)
+	p := Chain(live, Offline(&snapshots))
+
+	fake.fail = &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}
+	data, err := p.Read(ctx, "app/feature/on")
+	if err != nil || string(data) != "true" {
+		t.Errorf("Read = %q, %v; want the snapshot", data, err)
+	}
+	entries, err := p.ReadDir(ctx, "app")
+	if err != nil || !reflect.DeepEqual(entries, s.Dirs["app"]) {
+		t.Errorf("ReadDir = %v, %v; want the snapshot", entries, err)
+	}
+	if _, err := p.Read(ctx, "other/unpinned"); err == nil {
+		t.Error("unpinned path served while unreachable")
+	}
+
+	fake.fail = &smithy.GenericAPIError{Code: "ParameterNotFound"}
+	if _, err := p.Read(ctx, "app/feature/on"); err == nil {
+		t.Error("snapshot served although AWS answered")
+	}
+}
+
+func TestTakeSnapshotFailsWhenUnreachable(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"app/db-url": []byte("postgres://db")})
+	var snapshots Snapshots
+	snapshots.Put(&Snapshot{Root: "app", Files: map[string][]byte{"app/db-url": []byte("old")}})
+	p := Chain(treeProvider{fake}, Offline(&snapshots))
+
+	fake.fail = &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host"}}
+	if _, err := TakeSnapshot(context.Background(), p, "app"); err == nil {
+		t.Error("snapshot taken from the previous snapshot while unreachable")
+	}
+}
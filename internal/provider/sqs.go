@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// SQSProvider provides SQS queues as directories:
+//
+//	<queue>/attributes.json  the queue's attributes
+//	<queue>/peek.json        up to 10 messages, received without hiding them
+//	<queue>/send             writing a message body sends it
+type SQSProvider struct {
+	client *restJSONClient
+	queues *documents[cappedList[map[string]string]] // queue URLs by name, under ""
+}
+
+// Files of a queue directory
+const (
+	sqsAttributesFile = "attributes.json"
+	sqsPeekFile       = "peek.json"
+	sqsSendFile       = "send"
+)
+
+// sqsPeekMessages is how many messages peek.json receives, the most a
+// single ReceiveMessage returns
+const sqsPeekMessages = 10
+
+// NewSQSProvider creates a new SQS provider
+func NewSQSProvider(profile, region string) (*SQSProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newSQSProvider(cfg), nil
+}
+
+func newSQSProvider(cfg aws.Config) *SQSProvider {
+	return &SQSProvider{
+		client: newJSONRPCClient(cfg, "SQS", "sqs", "AmazonSQS"),
+		queues: newDocuments[cappedList[map[string]string]](),
+	}
+}
+
+func (p *SQSProvider) Name() string {
+	return "sqs"
+}
+
+// Invalidate forgets the memoized queue URLs, so a refresh describes them anew
+func (p *SQSProvider) Invalidate(string) {
+	forgetDocuments(p.queues)
+}
+
+func (p *SQSProvider) Close() {
+	closeDocuments(p.queues)
+}
+
+// isSQSSendFile reports whether path is the send file of a queue
+func isSQSSendFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 2 && parts[1] == sqsSendFile
+}
+
+func (p *SQSProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	if path == "" {
+		queues, err := p.listQueues(ctx)
+		if err != nil {
+			return nil, partialListing(nil, "aws sqs list-queues", err)
+		}
+		entries := make([]Entry, 0, len(queues.items))
+		for name := range queues.items {
+			entries = append(entries, Entry{Name: name, IsDir: true})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		return capEntries(entries, queues.more, "aws sqs list-queues"), nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	if _, err := p.queueURL(ctx, path); err != nil {
+		return nil, err
+	}
+	return []Entry{
+		{Name: sqsAttributesFile, IsDir: false, Size: 4096},
+		{Name: sqsPeekFile, IsDir: false, Size: 4096},
+		{Name: sqsSendFile, IsDir: false},
+	}, nil
+}
+
+// listQueues returns the URLs of the region's queues by name, up to
+// MaxEntries of them
+func (p *SQSProvider) listQueues(ctx context.Context) (cappedList[map[string]string], error) {
+	return p.queues.get("", func() (cappedList[map[string]string], error) {
+		queues := make(map[string]string)
+		var token string
+		for {
+			in := map[string]any{"MaxResults": 1000}
+			if token != "" {
+				in["NextToken"] = token
+			}
+			var resp struct {
+				QueueUrls []string
+				NextToken string
+			}
+			if err := p.client.call(ctx, "ListQueues", in, &resp); err != nil {
+				return cappedList[map[string]string]{}, err
+			}
+			for _, u := range resp.QueueUrls {
+				queues[u[strings.LastIndex(u, "/")+1:]] = u
+			}
+			if resp.NextToken == "" || len(queues) >= MaxEntries {
+				return cappedList[map[string]string]{items: queues, more: resp.NextToken != ""}, nil
+			}
+			token = resp.NextToken
+		}
+	})
+}
+
+// queueURL returns the URL of the queue named name, from the listing if
+// there's one and from GetQueueUrl otherwise
+func (p *SQSProvider) queueURL(ctx context.Context, name string) (string, error) {
+	queues, err := p.listQueues(ctx)
+	if err != nil {
+		return "", err
+	}
+	if u, ok := queues.items[name]; ok {
+		return u, nil
+	}
+	var resp struct{ QueueUrl string }
+	if err := p.client.call(ctx, "GetQueueUrl", map[string]any{"QueueName": name}, &resp); err != nil {
+		if isAPIError(err, "QueueDoesNotExist") || isAPIError(err, "AWS.SimpleQueueService.NonExistentQueue") {
+			return "", fmt.Errorf("queue not found: %s: %w", name, os.ErrNotExist)
+		}
+		return "", err
+	}
+	return resp.QueueUrl, nil
+}
+
+// sqsJSONAttributes are the attributes holding JSON documents, decoded
+// in attributes.json so they read like the rest of it
+var sqsJSONAttributes = map[string]bool{
+	"Policy":             true,
+	"RedrivePolicy":      true,
+	"RedriveAllowPolicy": true,
+}
+
+func (p *SQSProvider) attributes(ctx context.Context, url string) ([]byte, error) {
+	var resp struct {
+		Attributes map[string]string
+	}
+	in := map[string]any{"QueueUrl": url, "AttributeNames": []string{"All"}}
+	if err := p.client.call(ctx, "GetQueueAttributes", in, &resp); err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]any, len(resp.Attributes))
+	for k, v := range resp.Attributes {
+		attrs[k] = v
+		if sqsJSONAttributes[k] && json.Valid([]byte(v)) {
+			attrs[k] = json.RawMessage(v)
+		}
+	}
+	return json.MarshalIndent(attrs, "", "  ")
+}
+
+// sqsMessage is a message as shown in peek.json. The receipt handle is
+// left out: deleting messages isn't what peeking is for.
+type sqsMessage struct {
+	MessageID         string         `json:"MessageId"`
+	Body              string         `json:"Body"`
+	Attributes        map[string]any `json:"Attributes,omitempty"`
+	MessageAttributes map[string]any `json:"MessageAttributes,omitempty"`
+}
+
+// peek receives messages with a visibility timeout of 0, so they stay
+// available to consumers. Receiving still counts towards each message's
+// maxReceiveCount, and a queue with many messages returns a sample.
+func (p *SQSProvider) peek(ctx context.Context, url string) ([]byte, error) {
+	in := map[string]any{
+		"QueueUrl":                    url,
+		"MaxNumberOfMessages":         sqsPeekMessages,
+		"VisibilityTimeout":           0,
+		"WaitTimeSeconds":             0,
+		"MessageSystemAttributeNames": []string{"All"},
+		"MessageAttributeNames":       []string{"All"},
+	}
+	var resp struct {
+		Messages []sqsMessage
+	}
+	if err := p.client.call(ctx, "ReceiveMessage", in, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Messages == nil {
+		resp.Messages = []sqsMessage{}
+	}
+	return json.MarshalIndent(resp.Messages, "", "  ")
+}
+
+func (p *SQSProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	queue, file, ok := strings.Cut(path, "/")
+	if !ok || strings.Contains(file, "/") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	url, err := p.queueURL(ctx, queue)
+	if err != nil {
+		return nil, err
+	}
+	switch file {
+	case sqsAttributesFile:
+		return p.attributes(ctx, url)
+	case sqsPeekFile:
+		return p.peek(ctx, url)
+	case sqsSendFile:
+		return []byte{}, nil
+	}
+	return nil, fmt.Errorf("unknown file: %s", file)
+}
+
+func (p *SQSProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "sqs", IsDir: true}, nil
+	}
+	queue, file, _ := strings.Cut(path, "/")
+	if _, err := p.queueURL(ctx, queue); err != nil {
+		return nil, err
+	}
+	switch file {
+	case "":
+		return &Entry{Name: queue, IsDir: true}, nil
+	case sqsAttributesFile, sqsPeekFile:
+		return &Entry{Name: file, IsDir: false, Size: 4096}, nil
+	case sqsSendFile:
+		return &Entry{Name: file, IsDir: false}, nil
+	}
+	return nil, fmt.Errorf("path not found: %s", path)
+}
+
+// Writable reports whether path is a queue's send file, the only file
+// that can be written
+func (p *SQSProvider) Writable(path string) bool {
+	return isSQSSendFile(path)
+}
+
+// Write sends data as a message to the queue of a send file. A trailing
+// newline, as left by echo, is dropped. FIFO queues get the message in
+// group "sisu", deduplicated by its body for SQS's 5-minute window.
+func (p *SQSProvider) Write(ctx context.Context, path string, data []byte) error {
+	if !isSQSSendFile(path) {
+		return fs.ErrPermission
+	}
+	queue, _, _ := strings.Cut(path, "/")
+	url, err := p.queueURL(ctx, queue)
+	if err != nil {
+		return err
+	}
+	body := string(bytes.TrimSuffix(data, []byte("\n")))
+	in := map[string]any{"QueueUrl": url, "MessageBody": body}
+	if strings.HasSuffix(queue, ".fifo") {
+		sum := sha256.Sum256([]byte(body))
+		in["MessageGroupId"] = "sisu"
+		in["MessageDeduplicationId"] = hex.EncodeToString(sum[:])
+	}
+	return p.client.call(ctx, "SendMessage", in, nil)
+}
+
+// Delete is not supported: queues and messages can't be removed
+func (p *SQSProvider) Delete(ctx context.Context, path string) error {
+	return fs.ErrPermission
+}
+
+// validateSQSSend rejects empty messages, which SQS doesn't accept, e.g.
+// from an editor saving the empty send file
+func validateSQSSend(path string, data []byte) error {
+	if isSQSSendFile(path) && len(bytes.TrimSuffix(data, []byte("\n"))) == 0 {
+		return invalidf("%s: message body is empty", path)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestSQSQueues(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "sqs")
+	p := newSQSProvider(cfg)
+	ctx := context.Background()
+
+	files, err := p.ReadDir(ctx, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := entryNames(files); !reflect.DeepEqual(names, []string{"attributes.json", "peek.json", "send"}) {
+		t.Errorf("files = %v", names)
+	}
+
+	data, err := p.Read(ctx, "orders/attributes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "sqs/attributes.json", data)
+}
+
+func TestSQSQueuesTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+	cfg, _ := fixtureConfig(t, "sqs")
+	p := newSQSProvider(cfg)
+	ctx := context.Background()
+
+	if _, err := p.ReadDir(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Queues past the listing are still found by name
+	if _, err := p.ReadDir(ctx, "orders-dlq"); err != nil {
+		t.Errorf("ReadDir of a queue past the listing: %v", err)
+	}
+}
+
+func TestSQSPeek(t *testing.T) {
+	cfg, client := fixtureConfig(t, "sqs")
+	p := newSQSProvider(cfg)
+
+	data, err := p.Read(context.Background(), "orders/peek.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "sqs/peek.json", data)
+
+	// Messages are received with VisibilityTimeout 0 (the fixture only
+	// answers those) and never deleted
+	want := []string{"ListQueues", "ReceiveMessage"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestSQSSend(t *testing.T) {
+	cfg, client := fixtureConfig(t, "sqs")
+	p := newSQSProvider(cfg)
+	ctx := context.Background()
+
+	if !p.Writable("orders/send") || p.Writable("orders/peek.json") {
+		t.Error("only send should be writable")
+	}
+	if err := p.Write(ctx, "orders/send", []byte(`{"order":43}`+"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Write(ctx, "events.fifo/send", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ListQueues", "SendMessage", "SendMessage"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	if err := validateSQSSend("orders/send", []byte("\n")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("empty message = %v, want ErrInvalid", err)
+	}
+}
@@ -3,286 +3,283 @@ package provider
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
-	"github.com/semonte/sisu/internal/cache"
 )
 
 // SSMProvider provides access to SSM Parameter Store
 type SSMProvider struct {
 	client *ssm.Client
-	cache  *cache.Cache
+	index  ssmIndex
+
+	mu     sync.Mutex
+	secure map[string]bool // whether each parameter read or statted is a SecureString, by path
 }
 
 // NewSSMProvider creates a new SSM provider
 func NewSSMProvider(profile, region string) (*SSMProvider, error) {
-	var opts []func(*config.LoadOptions) error
-
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, err
 	}
+	return newSSMProvider(cfg), nil
+}
 
+func newSSMProvider(cfg aws.Config) *SSMProvider {
 	return &SSMProvider{
 		client: ssm.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+	}
 }
 
 func (p *SSMProvider) Name() string {
 	return "ssm"
 }
 
+// ReadDir lists path from the cached parameter tree rather than querying
+// SSM recursively per directory, which is slow in accounts with many
+// parameters. DescribeParameters has no values, so a parameter is listed
+// with its size once a Stat, Read or Write learned it, and 0 until then.
 func (p *SSMProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
-
-	// SSM paths must start with /
-	ssmPath := "/" + path
-	if ssmPath == "/" {
-		ssmPath = "/"
-	}
-	if !strings.HasSuffix(ssmPath, "/") {
-		ssmPath += "/"
-	}
-
-	entries, err := p.listParameters(ctx, ssmPath)
+	entries, err := p.index.list(ctx, p.client, path)
 	if err != nil {
 		return nil, err
 	}
-
-	p.cache.Set(cacheKey, entries)
-	return entries, nil
+	return capEntries(entries, false, ssmListHint(ssmDirPath(path))), nil
 }
 
-func (p *SSMProvider) listParameters(ctx context.Context, path string) ([]Entry, error) {
-	var entries []Entry
-	seen := make(map[string]bool)
-
-	// Use GetParametersByPath to list parameters under this path
-	paginator := ssm.NewGetParametersByPathPaginator(p.client, &ssm.GetParametersByPathInput{
-		Path:           aws.String(path),
-		Recursive:      aws.Bool(false),
-		WithDecryption: aws.Bool(false),
-	})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			// If path doesn't exist, return empty
-			return entries, nil
-		}
-
-		for _, param := range page.Parameters {
-			name := strings.TrimPrefix(*param.Name, path)
-			name = strings.TrimPrefix(name, "/")
-
-			// Skip if empty
-			if name == "" {
-				continue
-			}
-
-			// Check if this is a "directory" (has more path components)
-			if idx := strings.Index(name, "/"); idx >= 0 {
-				dirName := name[:idx]
-				if !seen[dirName] {
-					seen[dirName] = true
-					entries = append(entries, Entry{
-						Name:  dirName,
-						IsDir: true,
-					})
-				}
-			} else {
-				// It's a parameter (file)
-				modTime := time.Time{}
-				if param.LastModifiedDate != nil {
-					modTime = *param.LastModifiedDate
-				}
-				entries = append(entries, Entry{
-					Name:    name,
-					IsDir:   false,
-					Size:    int64(len(aws.ToString(param.Value))),
-					ModTime: modTime,
-				})
-			}
-		}
+// ssmDirPath returns the SSM path of a mount directory, e.g. "/app/" for "app"
+func ssmDirPath(path string) string {
+	if path == "" {
+		return "/"
 	}
+	return "/" + strings.TrimSuffix(path, "/") + "/"
+}
 
-	// Also check for "subdirectories" by looking for parameters with this prefix
-	// Use DescribeParameters to find paths that might be directories
-	descPaginator := ssm.NewDescribeParametersPaginator(p.client, &ssm.DescribeParametersInput{
-		ParameterFilters: []types.ParameterStringFilter{
-			{
-				Key:    aws.String("Path"),
-				Option: aws.String("Recursive"),
-				Values: []string{path},
-			},
-		},
-	})
-
-	for descPaginator.HasMorePages() {
-		page, err := descPaginator.NextPage(ctx)
-		if err != nil {
-			break
-		}
-
-		for _, param := range page.Parameters {
-			name := strings.TrimPrefix(*param.Name, path)
-			name = strings.TrimPrefix(name, "/")
-
-			if name == "" {
-				continue
-			}
-
-			// Check if this creates a directory entry
-			if idx := strings.Index(name, "/"); idx >= 0 {
-				dirName := name[:idx]
-				if !seen[dirName] {
-					seen[dirName] = true
-					entries = append(entries, Entry{
-						Name:  dirName,
-						IsDir: true,
-					})
-				}
-			}
-		}
-	}
+// ssmListHint returns the CLI command listing a parameter path in full
+func ssmListHint(path string) string {
+	return "aws ssm get-parameters-by-path --path " + path
+}
 
-	return entries, nil
+// ssmMoreResultsHint returns the listing hint for a marker file at path
+func ssmMoreResultsHint(path string) string {
+	return ssmListHint("/" + strings.TrimSuffix(path, MoreResultsFile))
 }
 
 func (p *SSMProvider) Read(ctx context.Context, path string) ([]byte, error) {
-	ssmPath := "/" + path
+	if isMoreResults(path) {
+		return []byte(moreResultsMessage(ssmMoreResultsHint(path))), nil
+	}
+	if isSSMMeta(path) {
+		data, _, err := p.metaFile(ctx, path)
+		return data, err
+	}
 
 	resp, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           aws.String(ssmPath),
+		Name:           aws.String(ssmParameterName(path)),
 		WithDecryption: aws.Bool(true),
 	})
 	if err != nil {
 		return nil, err
 	}
+	p.rememberType(path, resp.Parameter.Type)
+	content := ssmContent(path, aws.ToString(resp.Parameter.Value))
+	p.index.sized(path, int64(len(content)))
+	return content, nil
+}
 
-	value := aws.ToString(resp.Parameter.Value)
-	// Add newline for better cat output
-	if !strings.HasSuffix(value, "\n") {
-		value += "\n"
+// rememberType records whether the parameter at path is a SecureString,
+// for IsSecret
+func (p *SSMProvider) rememberType(path string, typ types.ParameterType) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.secure == nil {
+		p.secure = make(map[string]bool)
 	}
-
-	return []byte(value), nil
+	p.secure[path] = typ == types.ParameterTypeSecureString
 }
 
-func (p *SSMProvider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
+// IsSecret reports whether path is a SecureString parameter, as found by
+// the Read or Stat of it that came through the middleware chain, so the
+// check makes no calls of its own. Sidecars and listing hints are not
+// secret; a parameter not read yet is reported unknown, so Redact masks it.
+func (p *SSMProvider) IsSecret(ctx context.Context, path string) (bool, error) {
+	if isMoreResults(path) || isSSMMeta(path) {
+		return false, nil
 	}
-
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
+	p.mu.Lock()
+	secure, ok := p.secure[path]
+	p.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("type of %s unknown until it is read", path)
 	}
-	return entry, err
+	return secure, nil
 }
 
-func (p *SSMProvider) statUncached(ctx context.Context, path string) (*Entry, error) {
+func (p *SSMProvider) Stat(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return &Entry{Name: "ssm", IsDir: true}, nil
 	}
 
-	ssmPath := "/" + path
+	if isMoreResults(path) {
+		entry := moreResultsEntry(ssmMoreResultsHint(path))
+		return &entry, nil
+	}
+	if isSSMMeta(path) {
+		data, modTime, err := p.metaFile(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return &Entry{Name: path, Size: int64(len(data)), ModTime: modTime}, nil
+	}
 
 	// First, try to get it as a parameter
 	resp, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           aws.String(ssmPath),
+		Name:           aws.String(ssmParameterName(path)),
 		WithDecryption: aws.Bool(false),
 	})
 	if err == nil {
+		p.rememberType(path, resp.Parameter.Type)
 		modTime := time.Time{}
 		if resp.Parameter.LastModifiedDate != nil {
 			modTime = *resp.Parameter.LastModifiedDate
 		}
+		size := int64(len(ssmContent(path, aws.ToString(resp.Parameter.Value))))
+		p.index.sized(path, size)
 		return &Entry{
 			Name:    path,
 			IsDir:   false,
-			Size:    int64(len(aws.ToString(resp.Parameter.Value))),
+			Size:    size,
 			ModTime: modTime,
 		}, nil
 	}
 
 	// Check if it's a "directory" (path prefix with children)
-	checkPath := ssmPath
-	if !strings.HasSuffix(checkPath, "/") {
-		checkPath += "/"
+	if isSSMBase64(path) {
+		return nil, err
 	}
-
-	listResp, err := p.client.GetParametersByPath(ctx, &ssm.GetParametersByPathInput{
-		Path:       aws.String(checkPath),
-		MaxResults: aws.Int32(1),
-	})
-	if err == nil && len(listResp.Parameters) > 0 {
+	if isDir, err := p.index.isDir(ctx, p.client, path); err == nil && isDir {
 		return &Entry{
 			Name:  path,
 			IsDir: true,
 		}, nil
 	}
 
-	return nil, fmt.Errorf("parameter not found: %s", path)
+	return nil, fmt.Errorf("parameter not found: %s: %w", path, os.ErrNotExist)
 }
 
-func (p *SSMProvider) Write(ctx context.Context, path string, data []byte) error {
-	ssmPath := "/" + path
-	value := strings.TrimSuffix(string(data), "\n")
+// Writable reports whether parameters can be written at path; any path
+// other than the virtual truncation marker is a valid parameter name or hierarchy
+func (p *SSMProvider) Writable(path string) bool {
+	return !isMoreResults(path)
+}
 
-	_, err := p.client.PutParameter(ctx, &ssm.PutParameterInput{
-		Name:      aws.String(ssmPath),
-		Value:     aws.String(value),
-		Type:      types.ParameterTypeString,
-		Overwrite: aws.Bool(true),
-	})
+// Parameter value size limits per tier
+const (
+	ssmStandardMaxSize = 4 << 10
+	ssmAdvancedMaxSize = 8 << 10
+)
+
+// SSMAutoAdvancedTier stores values too large for the standard tier as
+// advanced parameters (which are billed) instead of rejecting them
+var SSMAutoAdvancedTier bool
+
+// validateSSMSize rejects values over the tier limit before calling PutParameter.
+// Standard-tier parameters hold 4 KB unless a rule selects another tier or
+// SSMAutoAdvancedTier is set.
+func validateSSMSize(path string, data []byte) error {
+	if isSSMMeta(path) {
+		return nil
+	}
+	value, err := ssmValue(path, data)
 	if err != nil {
 		return err
 	}
-
-	p.invalidateCache(path)
+	size := len(value)
+	tier, err := ParseSSMTier(ssmMetadataFor(path).Tier)
+	if err != nil {
+		return err
+	}
+	switch {
+	case size > ssmAdvancedMaxSize:
+		return invalidf("%s: value is %d bytes, SSM allows at most %d", path, size, ssmAdvancedMaxSize)
+	case size > ssmStandardMaxSize && tier == types.ParameterTierStandard:
+		return invalidf("%s: value is %d bytes, but the configured Standard tier allows %d", path, size, ssmStandardMaxSize)
+	case size > ssmStandardMaxSize && tier == "" && !SSMAutoAdvancedTier:
+		return invalidf("%s: value is %d bytes, standard tier allows %d (set ssm_auto_advanced_tier to upgrade)", path, size, ssmStandardMaxSize)
+	}
 	return nil
 }
 
-func (p *SSMProvider) Delete(ctx context.Context, path string) error {
-	ssmPath := "/" + path
+func (p *SSMProvider) Write(ctx context.Context, path string, data []byte) error {
+	if isSSMMeta(path) {
+		return p.writeMetaFile(ctx, path, data)
+	}
 
-	_, err := p.client.DeleteParameter(ctx, &ssm.DeleteParameterInput{
-		Name: aws.String(ssmPath),
+	name := ssmParameterName(path)
+	value, err := ssmValue(path, data)
+	if err != nil {
+		return err
+	}
+	path = strings.TrimPrefix(name, "/")
+	meta := ssmMetadataFor(path)
+	tier, err := ParseSSMTier(meta.Tier)
+	if err != nil {
+		return err
+	}
+	if tier == "" && len(value) > ssmStandardMaxSize && SSMAutoAdvancedTier {
+		tier = types.ParameterTierAdvanced
+	}
+
+	_, err = p.client.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:        aws.String(name),
+		Value:       aws.String(value),
+		Type:        types.ParameterTypeString,
+		Overwrite:   aws.Bool(true),
+		Tier:        tier,
+		Description: optionalString(meta.Description),
 	})
 	if err != nil {
 		return err
 	}
+	p.index.added(path)
+	p.index.sized(path, int64(len(ssmContent(path, value))))
+	return p.tag(ctx, path, meta.Tags)
+}
 
-	p.invalidateCache(path)
-	return nil
+// Written returns a value file as it reads back once its parameter is
+// put. Sidecars are left to the next read, as writing them also changes
+// the file they describe.
+func (p *SSMProvider) Written(path string, data []byte) ([]byte, bool) {
+	if isSSMMeta(path) || isSSMBase64(path) {
+		return nil, false
+	}
+	value, err := ssmValue(path, data)
+	if err != nil {
+		return nil, false
+	}
+	return ssmContent(path, value), true
 }
 
-func (p *SSMProvider) invalidateCache(path string) {
-	// Invalidate the parameter itself
-	p.cache.Delete("stat:" + path)
+func (p *SSMProvider) Delete(ctx context.Context, path string) error {
+	if isSSMMeta(path) || isSSMBase64(path) {
+		return invalidf("%s: sidecars are removed with their parameter", path)
+	}
+	ssmPath := "/" + path
 
-	// Invalidate parent directory
-	if idx := strings.LastIndex(path, "/"); idx > 0 {
-		p.cache.Delete("readdir:" + path[:idx])
-	} else {
-		p.cache.Delete("readdir:")
+	_, err := p.client.DeleteParameter(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String(ssmPath),
+	})
+	if err == nil {
+		p.index.removed(path)
+		p.mu.Lock()
+		delete(p.secure, path)
+		p.mu.Unlock()
 	}
+	return err
 }
@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SSMExport is a parameter tree as written by `sisu ssm export`
+type SSMExport struct {
+	Path       string               `json:"path"`
+	Parameters []SSMExportParameter `json:"parameters"`
+}
+
+// SSMExportParameter is one exported parameter
+type SSMExportParameter struct {
+	// Name is relative to the exported path, e.g. "db/url"
+	Name string `json:"name"`
+	// Type is String, StringList or SecureString
+	Type string `json:"type"`
+	// Value is empty for SecureString parameters exported without decryption
+	Value string `json:"value,omitempty"`
+}
+
+// Import actions
+const (
+	SSMImportCreate    = "create"
+	SSMImportUpdate    = "update"
+	SSMImportUnchanged = "unchanged"
+	SSMImportExists    = "exists"   // differs, but overwriting wasn't asked for
+	SSMImportNoValue   = "no value" // SecureString exported without decryption
+)
+
+// SSMImportStep is what importing one parameter would do
+type SSMImportStep struct {
+	Name   string // full parameter name, e.g. "/app/staging/db/url"
+	Type   types.ParameterType
+	Value  string
+	Action string
+}
+
+// Changes reports whether the step writes the parameter
+func (s SSMImportStep) Changes() bool {
+	return s.Action == SSMImportCreate || s.Action == SSMImportUpdate
+}
+
+// Export returns every parameter below path. SecureString values are only
+// included if decrypt is set.
+func (p *SSMProvider) Export(ctx context.Context, path string, decrypt bool) (*SSMExport, error) {
+	path = ssmTreePath(path)
+	params, err := p.parametersByPath(ctx, path, decrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &SSMExport{Path: path, Parameters: []SSMExportParameter{}}
+	for _, param := range params {
+		exported := SSMExportParameter{
+			Name:  strings.TrimPrefix(aws.ToString(param.Name), strings.TrimSuffix(path, "/")+"/"),
+			Type:  string(param.Type),
+			Value: aws.ToString(param.Value),
+		}
+		if param.Type == types.ParameterTypeSecureString && !decrypt {
+			exported.Value = ""
+		}
+		export.Parameters = append(export.Parameters, exported)
+	}
+	sort.Slice(export.Parameters, func(i, j int) bool {
+		return export.Parameters[i].Name < export.Parameters[j].Name
+	})
+	return export, nil
+}
+
+// Redact leaves SecureString values out of the export, as when exported
+// without decryption, and masks access keys and secret-looking values in
+// the others. A redacted export is for sharing, not importing.
+func (e *SSMExport) Redact() {
+	for i, param := range e.Parameters {
+		if param.Type == string(types.ParameterTypeSecureString) {
+			e.Parameters[i].Value = ""
+		} else {
+			e.Parameters[i].Value = string(RedactText([]byte(param.Value)))
+		}
+	}
+}
+
+// PlanImport compares export with the parameters below prefix and returns
+// what importing it would do, without changing anything
+func (p *SSMProvider) PlanImport(ctx context.Context, export *SSMExport, prefix string, overwrite bool) ([]SSMImportStep, error) {
+	prefix = ssmTreePath(prefix)
+	existing, err := p.parametersByPath(ctx, prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]types.Parameter, len(existing))
+	for _, param := range existing {
+		current[aws.ToString(param.Name)] = param
+	}
+
+	var steps []SSMImportStep
+	for _, param := range export.Parameters {
+		step := SSMImportStep{
+			Name:  strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(param.Name, "/"),
+			Type:  types.ParameterType(param.Type),
+			Value: param.Value,
+		}
+		if step.Type == "" {
+			step.Type = types.ParameterTypeString
+		}
+
+		old, exists := current[step.Name]
+		switch {
+		case step.Value == "":
+			step.Action = SSMImportNoValue
+		case !exists:
+			step.Action = SSMImportCreate
+		case aws.ToString(old.Value) == step.Value && old.Type == step.Type:
+			step.Action = SSMImportUnchanged
+		case !overwrite:
+			step.Action = SSMImportExists
+		default:
+			step.Action = SSMImportUpdate
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// ApplyImport writes the parameter of a step that Changes, keeping its type
+// and applying the tier, description and tags of matching SSMRules
+func (p *SSMProvider) ApplyImport(ctx context.Context, step SSMImportStep) error {
+	if !step.Changes() {
+		return nil
+	}
+	path := strings.TrimPrefix(step.Name, "/")
+	if err := validateSSMSize(path, []byte(step.Value)); err != nil {
+		return err
+	}
+
+	meta := ssmMetadataFor(path)
+	tier, err := ParseSSMTier(meta.Tier)
+	if err != nil {
+		return err
+	}
+	if tier == "" && len(step.Value) > ssmStandardMaxSize && SSMAutoAdvancedTier {
+		tier = types.ParameterTierAdvanced
+	}
+
+	_, err = p.client.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:        aws.String(step.Name),
+		Value:       aws.String(step.Value),
+		Type:        step.Type,
+		Overwrite:   aws.Bool(step.Action == SSMImportUpdate),
+		Tier:        tier,
+		Description: optionalString(meta.Description),
+	})
+	if err != nil {
+		return err
+	}
+	p.index.added(path)
+	return p.tag(ctx, path, meta.Tags)
+}
+
+// parametersByPath returns every parameter below path, which must end in "/"
+func (p *SSMProvider) parametersByPath(ctx context.Context, path string, decrypt bool) ([]types.Parameter, error) {
+	name := strings.TrimSuffix(path, "/")
+	if name == "" {
+		name = "/"
+	}
+
+	var params []types.Parameter
+	pages := ssm.NewGetParametersByPathPaginator(p.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(name),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(decrypt),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", path, err)
+		}
+		params = append(params, page.Parameters...)
+	}
+	return params, nil
+}
+
+// ssmTreePath normalizes a parameter path like "app/prod" to "/app/prod/"
+func ssmTreePath(path string) string {
+	return ssmDirPath(strings.Trim(path, "/"))
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSSMExport(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	export, err := p.Export(context.Background(), "app/prod", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SSMExport{
+		Path: "/app/prod/",
+		Parameters: []SSMExportParameter{
+			{Name: "db/password", Type: "SecureString"},
+			{Name: "db/url", Type: "String", Value: "postgres://prod:5432/app"},
+			{Name: "region", Type: "String", Value: "us-east-1"},
+		},
+	}
+	if !reflect.DeepEqual(export, want) {
+		t.Errorf("export = %+v, want %+v", export, want)
+	}
+}
+
+func TestSSMImport(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+	ctx := context.Background()
+
+	export, err := p.Export(ctx, "/app/prod", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := p.PlanImport(ctx, export, "/app/staging", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make(map[string]string)
+	for _, step := range steps {
+		actions[step.Name] = step.Action
+	}
+	wantActions := map[string]string{
+		"/app/staging/db/password": SSMImportCreate,
+		"/app/staging/db/url":      SSMImportExists,
+		"/app/staging/region":      SSMImportUnchanged,
+	}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("actions = %v, want %v", actions, wantActions)
+	}
+
+	for _, step := range steps {
+		if err := p.ApplyImport(ctx, step); err != nil {
+			t.Fatalf("ApplyImport %s: %v", step.Name, err)
+		}
+	}
+	want := []string{"GetParametersByPath", "GetParametersByPath", "PutParameter"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
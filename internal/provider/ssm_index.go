@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// ssmIndexTTL is how long the parameter tree is reused before a listing
+// enumerates the account again
+const ssmIndexTTL = time.Minute
+
+// ssmIndexLoadTimeout bounds an enumeration of the account. It runs apart
+// from the listing that started it, so a listing giving up doesn't fail the
+// others waiting for it, and the next listing finds it done.
+var ssmIndexLoadTimeout = 5 * time.Minute
+
+// ssmIndex is the parameter hierarchy of an account, built from a single
+// recursive DescribeParameters enumeration and served to every directory
+// listing until it expires. Writes and deletes through sisu update it in place.
+type ssmIndex struct {
+	mu      sync.Mutex
+	root    *ssmNode
+	loaded  time.Time
+	loading *ssmLoad // the enumeration in progress, if any
+}
+
+// ssmLoad is an enumeration of the account that concurrent listings wait
+// for instead of starting their own
+type ssmLoad struct {
+	done chan struct{}
+	root *ssmNode
+	err  error
+}
+
+// ssmNode is a path segment: a parameter, a directory, or both, since SSM
+// allows "/a" and "/a/b" to coexist
+type ssmNode struct {
+	children map[string]*ssmNode
+	param    bool
+	modTime  time.Time // of the parameter
+	size     int64     // of the parameter as read, once a Stat, Read or Write learned it
+	newest   time.Time // of the newest parameter below, for directories
+}
+
+func newSSMNode() *ssmNode {
+	return &ssmNode{children: make(map[string]*ssmNode)}
+}
+
+// get returns the tree, enumerating the account if it expired. The
+// enumeration runs without holding idx.mu, so updates and listings of a
+// fresh tree don't wait for it, and concurrent callers share one. Each
+// caller waits for it only as long as its own ctx allows.
+func (idx *ssmIndex) get(ctx context.Context, client *ssm.Client) (*ssmNode, error) {
+	idx.mu.Lock()
+	if idx.root != nil && time.Since(idx.loaded) < ssmIndexTTL {
+		root := idx.root
+		idx.mu.Unlock()
+		return root, nil
+	}
+	load := idx.loading
+	if load == nil {
+		load = &ssmLoad{done: make(chan struct{})}
+		idx.loading = load
+		go idx.load(context.WithoutCancel(ctx), client, load)
+	}
+	idx.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.root, load.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load runs an enumeration bounded by ssmIndexLoadTimeout, ctx carrying
+// only the values of the listing that started it
+func (idx *ssmIndex) load(ctx context.Context, client *ssm.Client, load *ssmLoad) {
+	ctx, cancel := context.WithTimeout(ctx, ssmIndexLoadTimeout)
+	defer cancel()
+	root, err := enumerateSSM(ctx, client)
+
+	idx.mu.Lock()
+	if err == nil {
+		if idx.root != nil {
+			root.keepSizes(idx.root)
+		}
+		idx.root, idx.loaded = root, time.Now()
+	}
+	idx.loading = nil
+	load.root, load.err = root, err
+	idx.mu.Unlock()
+	close(load.done)
+}
+
+// enumerateSSM builds the parameter tree of the account
+func enumerateSSM(ctx context.Context, client *ssm.Client) (*ssmNode, error) {
+	root := newSSMNode()
+	paginator := ssm.NewDescribeParametersPaginator(client, &ssm.DescribeParametersInput{
+		MaxResults: aws.Int32(50),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range page.Parameters {
+			root.insert(aws.ToString(param.Name), aws.ToTime(param.LastModifiedDate))
+		}
+	}
+	return root, nil
+}
+
+// list returns the entries below path ("" is the root). A path with no
+// parameters below it lists as empty, like GetParametersByPath.
+func (idx *ssmIndex) list(ctx context.Context, client *ssm.Client, path string) ([]Entry, error) {
+	root, err := idx.get(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	node := root.lookup(path)
+	if node == nil {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var entries []Entry
+	for _, name := range names {
+		child := node.children[name]
+		if child.param {
+			entries = append(entries, Entry{Name: name, Size: child.size, ModTime: child.modTime})
+		}
+		if len(child.children) > 0 {
+			entries = append(entries, Entry{Name: name, IsDir: true, ModTime: child.newest})
+		}
+	}
+	return entries, nil
+}
+
+// isDir reports whether any parameter exists below path
+func (idx *ssmIndex) isDir(ctx context.Context, client *ssm.Client, path string) (bool, error) {
+	root, err := idx.get(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	node := root.lookup(path)
+	return node != nil && len(node.children) > 0, nil
+}
+
+// added records a parameter written through sisu
+func (idx *ssmIndex) added(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.root != nil {
+		idx.root.insert(path, time.Now())
+	}
+}
+
+// sized records the size of the parameter at path as read through the
+// mount, for listings
+func (idx *ssmIndex) sized(path string, size int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.root == nil {
+		return
+	}
+	if node := idx.root.lookup(path); node != nil && node.param {
+		node.size = size
+	}
+}
+
+// removed records a parameter deleted through sisu
+func (idx *ssmIndex) removed(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.root != nil {
+		idx.root.remove(ssmSegments(path))
+	}
+}
+
+// ssmSegments splits a parameter name or mount path into its segments
+func ssmSegments(name string) []string {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+func (n *ssmNode) insert(name string, modTime time.Time) {
+	for _, segment := range ssmSegments(name) {
+		if modTime.After(n.newest) {
+			n.newest = modTime
+		}
+		child, ok := n.children[segment]
+		if !ok {
+			child = newSSMNode()
+			n.children[segment] = child
+		}
+		n = child
+	}
+	n.param = true
+	n.modTime = modTime
+}
+
+// remove drops the parameter at segments, pruning directories left empty.
+// It reports whether n itself is now empty.
+func (n *ssmNode) remove(segments []string) bool {
+	if len(segments) == 0 {
+		n.param = false
+		return len(n.children) == 0
+	}
+	child, ok := n.children[segments[0]]
+	if ok && child.remove(segments[1:]) {
+		delete(n.children, segments[0])
+	}
+	return !n.param && len(n.children) == 0
+}
+
+// keepSizes copies the sizes known in old to the parameters of n that
+// weren't modified since
+func (n *ssmNode) keepSizes(old *ssmNode) {
+	if n.param && old.param && n.modTime.Equal(old.modTime) {
+		n.size = old.size
+	}
+	for name, child := range n.children {
+		if oldChild, ok := old.children[name]; ok {
+			child.keepSizes(oldChild)
+		}
+	}
+}
+
+func (n *ssmNode) lookup(path string) *ssmNode {
+	for _, segment := range ssmSegments(path) {
+		n = n.children[segment]
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// SSMMetaSuffix marks the sidecar file holding a parameter's tier,
+// description and tags, e.g. "app/db-url@meta.json" for "app/db-url".
+// Sidecars aren't listed but can be read and written like regular files.
+// Like SSMBase64Suffix it starts with '@', which parameter names can't
+// hold, so a parameter named e.g. "app/db.meta.json" stays reachable.
+const SSMMetaSuffix = "@meta.json"
+
+// SSMMetadata holds the settings applied to a parameter alongside its value
+type SSMMetadata struct {
+	Tier        string            `json:"tier,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// SSMRule applies metadata to every parameter written under matching paths
+type SSMRule struct {
+	Match    func(path string) bool
+	Metadata SSMMetadata
+}
+
+// SSMRules are applied in order to parameters created or updated through
+// sisu; later rules override earlier tier and description, and tags merge
+var SSMRules []SSMRule
+
+// ssmMetadataFor returns the metadata configured for path by SSMRules
+func ssmMetadataFor(path string) SSMMetadata {
+	var meta SSMMetadata
+	for _, rule := range SSMRules {
+		if rule.Match(path) {
+			meta = meta.merge(rule.Metadata)
+		}
+	}
+	return meta
+}
+
+// merge returns m overlaid with the non-empty fields of other
+func (m SSMMetadata) merge(other SSMMetadata) SSMMetadata {
+	if other.Tier != "" {
+		m.Tier = other.Tier
+	}
+	if other.Description != "" {
+		m.Description = other.Description
+	}
+	if len(other.Tags) > 0 {
+		tags := make(map[string]string, len(m.Tags)+len(other.Tags))
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		for k, v := range other.Tags {
+			tags[k] = v
+		}
+		m.Tags = tags
+	}
+	return m
+}
+
+// ParseSSMTier normalizes a tier name, accepting any case and "intelligent"
+// as shorthand for Intelligent-Tiering
+func ParseSSMTier(tier string) (types.ParameterTier, error) {
+	switch strings.ToLower(tier) {
+	case "":
+		return "", nil
+	case "standard":
+		return types.ParameterTierStandard, nil
+	case "advanced":
+		return types.ParameterTierAdvanced, nil
+	case "intelligent", "intelligent-tiering":
+		return types.ParameterTierIntelligentTiering, nil
+	}
+	return "", invalidf("unknown SSM tier %q: use Standard, Advanced or Intelligent-Tiering", tier)
+}
+
+func isSSMMeta(path string) bool {
+	return strings.HasSuffix(path, SSMMetaSuffix)
+}
+
+// parseSSMMeta decodes sidecar content
+func parseSSMMeta(path string, data []byte) (SSMMetadata, types.ParameterTier, error) {
+	var meta SSMMetadata
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&meta); err != nil {
+		return meta, "", invalidf("%s: %v", path, err)
+	}
+	tier, err := ParseSSMTier(meta.Tier)
+	if err != nil {
+		return meta, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return meta, tier, nil
+}
+
+// validateSSMMeta checks sidecar writes are well-formed before any API call
+func validateSSMMeta(path string, data []byte) error {
+	if !isSSMMeta(path) {
+		return nil
+	}
+	_, _, err := parseSSMMeta(path, data)
+	return err
+}
+
+// readMeta returns the current tier, description and tags of a parameter
+func (p *SSMProvider) readMeta(ctx context.Context, name string) (*SSMMetadata, time.Time, error) {
+	resp, err := p.client.DescribeParameters(ctx, &ssm.DescribeParametersInput{
+		ParameterFilters: []types.ParameterStringFilter{
+			{
+				Key:    aws.String("Name"),
+				Option: aws.String("Equals"),
+				Values: []string{"/" + name},
+			},
+		},
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(resp.Parameters) == 0 {
+		return nil, time.Time{}, fmt.Errorf("parameter not found: %s", name)
+	}
+	param := resp.Parameters[0]
+
+	tags, err := p.client.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String("/" + name),
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	meta := &SSMMetadata{
+		Tier:        string(param.Tier),
+		Description: aws.ToString(param.Description),
+	}
+	if len(tags.TagList) > 0 {
+		meta.Tags = make(map[string]string, len(tags.TagList))
+		for _, tag := range tags.TagList {
+			meta.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return meta, aws.ToTime(param.LastModifiedDate), nil
+}
+
+// metaFile renders a parameter's sidecar content
+func (p *SSMProvider) metaFile(ctx context.Context, path string) ([]byte, time.Time, error) {
+	meta, modTime, err := p.readMeta(ctx, strings.TrimSuffix(path, SSMMetaSuffix))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return append(data, '\n'), modTime, nil
+}
+
+// writeMetaFile applies a sidecar to an existing parameter. Tier and
+// description can only be changed by putting the value again, so the
+// current value and type are kept.
+func (p *SSMProvider) writeMetaFile(ctx context.Context, path string, data []byte) error {
+	name := strings.TrimSuffix(path, SSMMetaSuffix)
+
+	meta, tier, err := parseSSMMeta(path, data)
+	if err != nil {
+		return err
+	}
+
+	current, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String("/" + name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return invalidf("%s: write the parameter before its metadata (%v)", path, err)
+	}
+
+	if tier != "" || meta.Description != "" {
+		_, err = p.client.PutParameter(ctx, &ssm.PutParameterInput{
+			Name:        aws.String("/" + name),
+			Value:       current.Parameter.Value,
+			Type:        current.Parameter.Type,
+			Overwrite:   aws.Bool(true),
+			Tier:        tier,
+			Description: optionalString(meta.Description),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return p.tag(ctx, name, meta.Tags)
+}
+
+// tag adds or updates tags on a parameter
+func (p *SSMProvider) tag(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagList := make([]types.Tag, len(keys))
+	for i, k := range keys {
+		tagList[i] = types.Tag{Key: aws.String(k), Value: aws.String(tags[k])}
+	}
+	_, err := p.client.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String("/" + name),
+		Tags:         tagList,
+	})
+	return err
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSMReadDir(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+	ctx := context.Background()
+
+	// Listed with the size its Stat found; feature/ has no parameter to size
+	if _, err := p.ReadDir(ctx, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Stat(ctx, "app/database-url"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := p.ReadDir(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "ssm/app.json", entries)
+}
+
+func TestSSMReadAppendsNewline(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	data, err := p.Read(context.Background(), "app/database-url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "postgres://db:5432/app\n" {
+		t.Errorf("Read = %q", data)
+	}
+}
+
+func TestSSMIsSecretFromRead(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+	ctx := context.Background()
+
+	// Unknown until read, so Redact masks it
+	if _, err := p.IsSecret(ctx, "app/database-url"); err == nil {
+		t.Error("IsSecret before the read succeeded")
+	}
+	if _, err := p.Read(ctx, "app/database-url"); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := p.IsSecret(ctx, "app/database-url"); secret || err != nil {
+		t.Errorf("IsSecret = %v, %v, want a plain String", secret, err)
+	}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, []string{"GetParameter"}) {
+		t.Errorf("calls = %v, want only the read's", calls)
+	}
+}
+
+func TestSSMExactValues(t *testing.T) {
+	defer func(exact bool) { SSMExactValues = exact }(SSMExactValues)
+	SSMExactValues = true
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	data, err := p.Read(context.Background(), "app/database-url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "postgres://db:5432/app" {
+		t.Errorf("Read = %q", data)
+	}
+	if value, _ := ssmValue("app/key", []byte("line\n")); value != "line\n" {
+		t.Errorf("exact value = %q, want trailing newline kept", value)
+	}
+}
+
+func TestSSMWritten(t *testing.T) {
+	p := &SSMProvider{}
+	if data, ok := p.Written("app/key", []byte("value")); !ok || string(data) != "value\n" {
+		t.Errorf("Written = %q, %v; want the value as read, with a newline", data, ok)
+	}
+	for _, path := range []string{"app/key" + SSMBase64Suffix, "app/key" + SSMMetaSuffix} {
+		if _, ok := p.Written(path, []byte("x")); ok {
+			t.Errorf("Written(%q) known, want left to the next read", path)
+		}
+	}
+}
+
+func TestSSMBase64Sidecar(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	data, err := p.Read(context.Background(), "app/database-url"+SSMBase64Suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "cG9zdGdyZXM6Ly9kYjo1NDMyL2FwcA==\n" {
+		t.Errorf("Read = %q", data)
+	}
+
+	value, err := ssmValue("app/key"+SSMBase64Suffix, []byte("bGluZQo=\n"))
+	if err != nil || value != "line\n" {
+		t.Errorf("decoded = %q, %v; want exact value", value, err)
+	}
+	for _, content := range []string{"not base64!", "//79"} {
+		if _, err := ssmValue("app/key"+SSMBase64Suffix, []byte(content)); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("ssmValue(%q) = %v, want ErrInvalid", content, err)
+		}
+	}
+	if err := p.Delete(context.Background(), "app/database-url"+SSMBase64Suffix); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Delete sidecar = %v, want ErrInvalid", err)
+	}
+
+	// Parameters may end in .b64 themselves
+	if isSSMBase64("app/cert.b64") || ssmParameterName("app/cert.b64") != "/app/cert.b64" {
+		t.Error("app/cert.b64 taken for a sidecar")
+	}
+	if isSSMMeta("app/cert.meta.json") {
+		t.Error("app/cert.meta.json taken for a sidecar")
+	}
+	related := RelatedFiles("ssm", "app/cert"+SSMBase64Suffix)
+	if want := []string{"app/cert", "app/cert" + SSMMetaSuffix}; !reflect.DeepEqual(related, want) {
+		t.Errorf("RelatedFiles = %v, want %v", related, want)
+	}
+}
+
+func TestSSMMetadataRules(t *testing.T) {
+	defer func(r []SSMRule) { SSMRules = r }(SSMRules)
+	SSMRules = []SSMRule{
+		{
+			Match:    func(path string) bool { return strings.HasPrefix(path, "app/") },
+			Metadata: SSMMetadata{Tier: "Standard", Tags: map[string]string{"team": "platform", "env": "dev"}},
+		},
+		{
+			Match:    func(path string) bool { return strings.HasPrefix(path, "app/prod/") },
+			Metadata: SSMMetadata{Tier: "Advanced", Description: "prod config", Tags: map[string]string{"env": "prod"}},
+		},
+	}
+
+	got := ssmMetadataFor("app/prod/db")
+	want := SSMMetadata{
+		Tier:        "Advanced",
+		Description: "prod config",
+		Tags:        map[string]string{"team": "platform", "env": "prod"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ssmMetadataFor = %+v, want %+v", got, want)
+	}
+
+	// A rule pinning the standard tier rejects oversized values even with auto-upgrade
+	large := []byte(strings.Repeat("x", ssmStandardMaxSize+1))
+	if err := validateSSMSize("app/dev/big", large); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("standard rule: got %v, want ErrInvalid", err)
+	}
+	if err := validateSSMSize("app/prod/big", large); err != nil {
+		t.Errorf("advanced rule: unexpected error %v", err)
+	}
+}
+
+func TestSSMMetaSidecarValidation(t *testing.T) {
+	valid := `{"tier": "intelligent-tiering", "tags": {"team": "platform"}}`
+	if err := validateSSMMeta("app/db@meta.json", []byte(valid)); err != nil {
+		t.Errorf("valid sidecar: %v", err)
+	}
+	for _, doc := range []string{`{"tier": "premium"}`, `{"tagz": {}}`, `not json`} {
+		if err := validateSSMMeta("app/db@meta.json", []byte(doc)); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("sidecar %s: got %v, want ErrInvalid", doc, err)
+		}
+	}
+	if err := validateSSMMeta("app/db", []byte("not json")); err != nil {
+		t.Errorf("plain parameter checked as sidecar: %v", err)
+	}
+}
+
+func TestSSMListingsShareOneEnumeration(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+	ctx := context.Background()
+
+	for _, path := range []string{"", "app", "app/feature"} {
+		if _, err := p.ReadDir(ctx, path); err != nil {
+			t.Fatalf("ReadDir %q: %v", path, err)
+		}
+	}
+	if entry, err := p.Stat(ctx, "app/feature"); err != nil || !entry.IsDir {
+		t.Fatalf("Stat app/feature = %+v, %v", entry, err)
+	}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, []string{"DescribeParameters", "GetParameter"}) {
+		t.Errorf("calls = %v, want one DescribeParameters and the GetParameter of Stat", calls)
+	}
+}
+
+func TestSSMIndexUpdatesInPlace(t *testing.T) {
+	var idx ssmIndex
+	idx.root = newSSMNode()
+	idx.loaded = time.Now()
+	idx.root.insert("/app/db", time.Time{})
+
+	idx.added("app/feature/flags")
+	entries, _ := idx.list(context.Background(), nil, "app")
+	if len(entries) != 2 || entries[1].Name != "feature" || !entries[1].IsDir {
+		t.Fatalf("after write: %+v", entries)
+	}
+
+	idx.removed("app/feature/flags")
+	entries, _ = idx.list(context.Background(), nil, "app")
+	if len(entries) != 1 || entries[0].Name != "db" {
+		t.Errorf("after delete: %+v", entries)
+	}
+}
+
+func TestSSMIndexWaitsForEnumerationInProgress(t *testing.T) {
+	var idx ssmIndex
+	load := &ssmLoad{done: make(chan struct{})}
+	idx.loading = load
+
+	listed := make(chan []Entry)
+	go func() {
+		entries, _ := idx.list(context.Background(), nil, "app")
+		listed <- entries
+	}()
+	// Updates don't wait for the enumeration, and a waiter giving up
+	// leaves it to the others
+	idx.added("app/other")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := idx.list(ctx, nil, "app"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled waiter: %v, want context.Canceled", err)
+	}
+
+	load.root = newSSMNode()
+	load.root.insert("/app/db", time.Time{})
+	close(load.done)
+	if entries := <-listed; len(entries) != 1 || entries[0].Name != "db" {
+		t.Errorf("entries = %+v, want the enumeration's", entries)
+	}
+}
+
+func TestSSMEnumerationOutlivesCancelledListing(t *testing.T) {
+	cfg, client := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	// The listing that starts the enumeration gives up at once
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.ReadDir(ctx, "app")
+
+	entries, err := p.ReadDir(context.Background(), "app")
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("ReadDir = %+v, %v; want the enumeration's entries", entries, err)
+	}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, []string{"DescribeParameters"}) {
+		t.Errorf("calls = %v, want the one enumeration", calls)
+	}
+}
+
+func TestSSMStatMissing(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "ssm")
+	p := newSSMProvider(cfg)
+
+	if _, err := p.Stat(context.Background(), "app/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat = %v, want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+)
+
+// SSMExactValues makes parameter files hold values byte for byte. By
+// default a newline is appended to values when read, for cat, and one
+// trailing newline is dropped from values written, for editors, so a value
+// that ends in a newline doesn't survive a round trip.
+var SSMExactValues bool
+
+// SSMBase64Suffix marks the sidecar showing a parameter's exact value in
+// base64, e.g. "app/cert@b64" for "app/cert", for values with control
+// characters or significant trailing whitespace. Writing base64 to it sets
+// the value. Sidecars aren't listed. '@' can't appear in parameter names,
+// so no parameter's file is mistaken for a sidecar.
+const SSMBase64Suffix = "@b64"
+
+func isSSMBase64(path string) bool {
+	return strings.HasSuffix(path, SSMBase64Suffix)
+}
+
+// ssmParameterName returns the name of the parameter a value file shows
+func ssmParameterName(path string) string {
+	return "/" + strings.TrimSuffix(path, SSMBase64Suffix)
+}
+
+// ssmContent returns the file content shown for a parameter's value
+func ssmContent(path, value string) []byte {
+	if isSSMBase64(path) {
+		return []byte(base64.StdEncoding.EncodeToString([]byte(value)) + "\n")
+	}
+	if !SSMExactValues && !strings.HasSuffix(value, "\n") {
+		value += "\n"
+	}
+	return []byte(value)
+}
+
+// ssmValue returns the parameter value stored for content written to path.
+// SSM stores text, so values must be valid UTF-8.
+func ssmValue(path string, data []byte) (string, error) {
+	switch {
+	case isSSMBase64(path):
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+		if err != nil {
+			return "", invalidf("%s: not base64: %v", path, err)
+		}
+		data = decoded
+	case !SSMExactValues:
+		data = bytes.TrimSuffix(data, []byte("\n"))
+	}
+	if !utf8.Valid(data) {
+		return "", invalidf("%s: SSM values must be UTF-8 text; store binary data base64-encoded", path)
+	}
+	return string(data), nil
+}
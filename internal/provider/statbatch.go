@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/semonte/sisu/internal/paging"
+)
+
+// StatBatcher is implemented by providers that can stat many paths with
+// fewer calls than a Stat each, typically from one listing of their parent
+// directory. The result is keyed by path and may include siblings found by
+// the same calls; paths that don't exist are left out.
+type StatBatcher interface {
+	StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error)
+}
+
+// StatBatch stats paths, using p's StatBatcher when available and
+// otherwise a Stat per path. Without a StatBatcher, paths that fail to stat
+// are left out unless every one fails, in which case the first error is
+// returned. Decorators that don't implement StatBatcher (e.g. session
+// recording) therefore see ordinary Stats.
+func StatBatch(ctx context.Context, p Provider, paths []string) (map[string]*Entry, error) {
+	if sb, ok := p.(StatBatcher); ok {
+		return sb.StatBatch(ctx, paths)
+	}
+	entries := make(map[string]*Entry, len(paths))
+	var firstErr error
+	for _, path := range paths {
+		entry, err := p.Stat(ctx, path)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		entries[path] = entry
+	}
+	if len(entries) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return entries, nil
+}
+
+// ListingStats reports whether p's listings carry the same details as its
+// Stat, which providers declare by answering StatBatch from them with
+// statFromListings. p is the provider itself, not a middleware chain.
+func ListingStats(p Provider) bool {
+	_, ok := p.(StatBatcher)
+	return ok
+}
+
+// splitParent splits path into its parent directory and name
+func splitParent(path string) (dir, name string) {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// listedEntry finds name in a listing. complete reports whether the listing
+// wasn't truncated or cut short by an error, so a name missing from it
+// doesn't exist.
+func listedEntry(entries []Entry, name string) (entry *Entry, complete bool) {
+	complete = true
+	for i := range entries {
+		switch entries[i].Name {
+		case name:
+			e := entries[i]
+			return &e, true
+		case MoreResultsFile, WarningFile:
+			complete = false
+		default:
+			if paging.Page(entries[i].Name) > 0 {
+				complete = false
+			}
+		}
+	}
+	return nil, complete
+}
+
+// statFromListings implements StatBatch for providers whose listings carry
+// the same details as Stat: it lists each parent once and returns every
+// entry found. Paths missing from a truncated listing are stat'ed singly.
+func statFromListings(ctx context.Context, p Provider, paths []string) (map[string]*Entry, error) {
+	byDir := make(map[string][]string)
+	for _, path := range paths {
+		dir, name := splitParent(path)
+		byDir[dir] = append(byDir[dir], name)
+	}
+
+	found := make(map[string]*Entry, len(paths))
+	for dir, names := range byDir {
+		entries, err := p.ReadDir(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			path := joinPath(dir, entries[i].Name)
+			// A name listed as both a file and a directory stats as the
+			// directory, as with Stat
+			if prev, ok := found[path]; (ok && prev.IsDir) || entries[i].Name == MoreResultsFile || entries[i].Name == WarningFile {
+				continue
+			}
+			found[path] = &entries[i]
+		}
+		for _, name := range names {
+			if _, complete := listedEntry(entries, name); complete {
+				continue
+			}
+			if entry, err := p.Stat(ctx, joinPath(dir, name)); err == nil {
+				found[joinPath(dir, name)] = entry
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+// listingProvider answers batch stats from listings, like S3
+type listingProvider struct {
+	*fakeProvider
+}
+
+func (p listingProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	return statFromListings(ctx, p, paths)
+}
+
+func TestStatBatchFallsBackToStat(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("x"), "a/y": []byte("yy")})
+	ctx := context.Background()
+
+	entries, err := StatBatch(ctx, Chain(fake, Logging()), []string{"a/x", "a/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries["a/x"] == nil {
+		t.Errorf("StatBatch = %v, want only a/x", entries)
+	}
+	if fake.calls[OpStat] != 2 {
+		t.Errorf("underlying Stat called %d times, want 2", fake.calls[OpStat])
+	}
+
+	if _, err := StatBatch(ctx, fake, []string{"a/missing"}); err == nil {
+		t.Error("expected an error when every path fails")
+	}
+}
+
+func TestStatBatchFromListings(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("x"), "a/y": []byte("yy"), "b/z": nil})
+	p := Cached(Chain(listingProvider{fake}, Logging()), DefaultCachePolicy)
+	ctx := context.Background()
+
+	entries, err := p.StatBatch(ctx, []string{"a/x", "a/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries["a/x"] == nil || entries["a/y"] == nil || entries["a/missing"] != nil {
+		t.Errorf("StatBatch = %v, want a/x and its sibling a/y", entries)
+	}
+
+	// The sibling found by the same listing is served from the cache
+	entries, err = p.StatBatch(ctx, []string{"a/y"})
+	if err != nil || entries["a/y"].Size != 2 {
+		t.Fatalf("StatBatch(a/y) = %v, %v", entries, err)
+	}
+	if fake.calls[OpReadDir] != 1 || fake.calls[OpStat] != 0 {
+		t.Errorf("underlying calls = %v, want a single ReadDir", fake.calls)
+	}
+
+	// Stat results can carry more than listings, so Stat doesn't use them
+	if _, err := p.Stat(ctx, "a/y"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls[OpStat] != 1 {
+		t.Errorf("underlying Stat called %d times, want 1", fake.calls[OpStat])
+	}
+}
+
+func TestCachedStatBatchUsesCachedListing(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("x"), "a/y": []byte("yy")})
+	p := Cached(listingProvider{fake}, DefaultCachePolicy).StatFromListings()
+	ctx := context.Background()
+
+	if _, err := p.ReadDir(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := p.StatBatch(ctx, []string{"a/x", "a/y", "a/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries["a/y"].Size != 2 {
+		t.Errorf("StatBatch = %v, want a/x and a/y", entries)
+	}
+	if fake.calls[OpStat] != 0 || fake.calls[OpReadDir] != 1 {
+		t.Errorf("underlying calls = %v, want only the first ReadDir", fake.calls)
+	}
+}
+
+// sizelessProvider lists files at 0 bytes, like IAM and SSM
+type sizelessProvider struct {
+	*fakeProvider
+}
+
+func (p sizelessProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	entries, err := p.fakeProvider.ReadDir(ctx, path)
+	for i := range entries {
+		entries[i].Size = 0
+	}
+	return entries, err
+}
+
+func TestCachedStatBatchIgnoresListingsWithoutStats(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("23 bytes of a document.")})
+	p := Cached(sizelessProvider{fake}, DefaultCachePolicy)
+	ctx := context.Background()
+	if ListingStats(sizelessProvider{fake}) {
+		t.Fatal("a provider without StatBatch declares listing stats")
+	}
+
+	if _, err := p.ReadDir(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := p.StatBatch(ctx, []string{"a/x"})
+	if err != nil || entries["a/x"] == nil || entries["a/x"].Size != 23 {
+		t.Errorf("StatBatch = %v, %v, want the size Stat reports", entries, err)
+	}
+}
+
+// partialProvider lists a/x followed by a warning, as when a later page
+// of the listing failed
+type partialProvider struct {
+	*fakeProvider
+}
+
+func (p partialProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	return []Entry{{Name: "x", Size: 1}, {Name: WarningFile}}, nil
+}
+
+func (p partialProvider) StatBatch(ctx context.Context, paths []string) (map[string]*Entry, error) {
+	return statFromListings(ctx, p, paths)
+}
+
+func TestStatBatchPartialListing(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{"a/x": []byte("x"), "a/b": []byte("bb")})
+	p := Cached(partialProvider{fake}, DefaultCachePolicy).StatFromListings()
+	ctx := context.Background()
+
+	// Missing from a listing with a warning isn't missing
+	entries, err := p.StatBatch(ctx, []string{"a/b"})
+	if err != nil || entries["a/b"] == nil || entries["a/b"].Size != 2 {
+		t.Errorf("StatBatch = %v, %v, want a/b from Stat", entries, err)
+	}
+	if entries[joinPath("a", WarningFile)] != nil {
+		t.Error("the warning was returned as an entry")
+	}
+	if _, err := p.ReadDir(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = p.StatBatch(ctx, []string{"a/b"})
+	if err != nil || entries["a/b"] == nil {
+		t.Errorf("StatBatch after the listing was cached = %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagsFile is the file holding a resource's tags as a JSON object, e.g.
+// "i-0abc/tags.json". Writing an edited object applies the difference.
+const TagsFile = "tags.json"
+
+// isTagsFile reports whether path is the tags file of a resource
+// directory, e.g. an EC2 instance or a Lambda function
+func isTagsFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 2 && parts[1] == TagsFile
+}
+
+// parseTags decodes a tags file, which must be an object of strings
+func parseTags(data []byte) (map[string]string, error) {
+	tags := make(map[string]string)
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of string values: %w", err)
+	}
+	return tags, nil
+}
+
+// renderTags encodes tags the way every tags file reads
+func renderTags(tags map[string]string) ([]byte, error) {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return json.MarshalIndent(tags, "", "  ")
+}
+
+// awsTagPrefix starts the keys of tags AWS manages, e.g.
+// aws:cloudformation:stack-name, which can't be set or removed
+const awsTagPrefix = "aws:"
+
+// diffTags returns the tags to create or overwrite and the keys to delete
+// to turn current into desired. AWS-managed tags are left alone, whether
+// the edited file keeps, changes or drops them.
+func diffTags(current, desired map[string]string) (map[string]string, []string) {
+	set := make(map[string]string)
+	for k, v := range desired {
+		if strings.HasPrefix(k, awsTagPrefix) {
+			continue
+		}
+		if old, ok := current[k]; !ok || old != v {
+			set[k] = v
+		}
+	}
+	var remove []string
+	for k := range current {
+		if strings.HasPrefix(k, awsTagPrefix) {
+			continue
+		}
+		if _, ok := desired[k]; !ok {
+			remove = append(remove, k)
+		}
+	}
+	sort.Strings(remove)
+	return set, remove
+}
+
+// TagFiles returns a validator that checks writes to paths accepted by
+// match are objects of strings
+func TagFiles(match func(path string) bool) WriteValidator {
+	return func(path string, data []byte) error {
+		if !match(path) {
+			return nil
+		}
+		if _, err := parseTags(data); err != nil {
+			return invalidf("%s: %v", path, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTags(t *testing.T) {
+	current := map[string]string{"Name": "web-1", "Environment": "prod", "team": "core", "aws:cloudformation:stack-name": "web"}
+	desired := map[string]string{"Name": "web-1", "Environment": "staging", "owner": "me", "aws:autoscaling:groupName": "web"}
+
+	set, remove := diffTags(current, desired)
+	if want := map[string]string{"Environment": "staging", "owner": "me"}; !reflect.DeepEqual(set, want) {
+		t.Errorf("set = %v, want %v", set, want)
+	}
+	if want := []string{"team"}; !reflect.DeepEqual(remove, want) {
+		t.Errorf("remove = %v, want %v", remove, want)
+	}
+}
+
+func TestTagFilesValidator(t *testing.T) {
+	v := TagFiles(isTagsFile)
+	if err := v("api/tags.json", []byte(`{"team": "core"}`)); err != nil {
+		t.Errorf("valid tags: %v", err)
+	}
+	if err := v("api/tags.json", []byte(`{"count": 3}`)); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("non-string value: err = %v, want ErrInvalid", err)
+	}
+	if err := v("api/config.json", []byte(`[]`)); err != nil {
+		t.Errorf("other files are not checked: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeouts holds the deadline applied to each provider operation.
+// A zero value means no deadline for that operation.
+type Timeouts struct {
+	ReadDir time.Duration
+	Read    time.Duration
+	Stat    time.Duration
+	Write   time.Duration
+	Delete  time.Duration
+}
+
+// DefaultTimeouts are used for any operation without an explicit override
+var DefaultTimeouts = Timeouts{
+	ReadDir: 10 * time.Second,
+	Read:    60 * time.Second,
+	Stat:    10 * time.Second,
+	Write:   60 * time.Second,
+	Delete:  30 * time.Second,
+}
+
+// Merge returns t with zero fields filled in from base
+func (t Timeouts) Merge(base Timeouts) Timeouts {
+	if t.ReadDir == 0 {
+		t.ReadDir = base.ReadDir
+	}
+	if t.Read == 0 {
+		t.Read = base.Read
+	}
+	if t.Stat == 0 {
+		t.Stat = base.Stat
+	}
+	if t.Write == 0 {
+		t.Write = base.Write
+	}
+	if t.Delete == 0 {
+		t.Delete = base.Delete
+	}
+	return t
+}
+
+// Set assigns the timeout for a named operation (readdir, read, stat, write, delete)
+func (t *Timeouts) Set(op string, d time.Duration) error {
+	switch strings.ToLower(op) {
+	case "readdir":
+		t.ReadDir = d
+	case "read":
+		t.Read = d
+	case "stat":
+		t.Stat = d
+	case "write":
+		t.Write = d
+	case "delete":
+		t.Delete = d
+	default:
+		return fmt.Errorf("unknown operation: %s", op)
+	}
+	return nil
+}
+
+// For returns the timeout configured for op
+func (t Timeouts) For(op Op) time.Duration {
+	switch op {
+	case OpReadDir:
+		return t.ReadDir
+	case OpRead:
+		return t.Read
+	case OpStat:
+		return t.Stat
+	case OpWrite:
+		return t.Write
+	case OpDelete:
+		return t.Delete
+	}
+	return 0
+}
+
+// Timeout runs every call under a context deadline taken from t.
+// A slow service (e.g. a huge ListObjects) then fails with
+// context.DeadlineExceeded instead of hanging the caller indefinitely.
+func Timeout(t Timeouts) Middleware {
+	return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+		d := t.For(op)
+		if d <= 0 {
+			return call(ctx)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return call(ctx)
+	})
+}
@@ -0,0 +1,611 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TopologyProvider maps how the region's queues, topics, functions and
+// EventBridge rules feed each other, answering "what consumes this
+// queue?". Each node is a directory of symlinks to its neighbours:
+//
+//	edges.json                             every edge, including those leaving the region,
+//	                                       and the listings cut off at MaxEntries
+//	<kind>/<name>/consumers/<kind>/<name>  nodes it delivers to, e.g. a queue's Lambda triggers
+//	<kind>/<name>/sources/<kind>/<name>    nodes delivering to it
+//
+// where kind is queues, topics, functions or rules. Rules on buses other
+// than the default one are named <bus>.<rule>. Edges come from Lambda
+// event source mappings, SNS subscriptions, SQS redrive policies and
+// EventBridge rule targets.
+type TopologyProvider struct {
+	ReadOnlyProvider
+	region string
+	sqs    *restJSONClient
+	sns    *restJSONClient
+	lambda *restJSONClient
+	events *restJSONClient
+	graphs *documents[*topology] // under ""
+}
+
+// Directories and files of the topology
+const (
+	topologyEdgesFile    = "edges.json"
+	topologyConsumersDir = "consumers"
+	topologySourcesDir   = "sources"
+)
+
+// topologyKinds are the node directories, by the ARN service of their nodes
+var topologyKinds = map[string]string{
+	"sqs":    "queues",
+	"sns":    "topics",
+	"lambda": "functions",
+	"events": "rules",
+}
+
+// Edge kinds
+const (
+	topologyEventSource  = "event-source-mapping" // queue or stream to function
+	topologySubscription = "subscription"         // topic to subscriber
+	topologyRedrive      = "redrive"              // queue to its dead-letter queue
+	topologyRuleTarget   = "rule-target"          // rule to target
+)
+
+// topologyEdge is an edge of edges.json, from the node delivering to the
+// one receiving
+type topologyEdge struct {
+	From     string `json:"from"` // ARN
+	To       string `json:"to"`   // ARN, or the endpoint of non-AWS subscribers
+	Kind     string `json:"kind"`
+	Protocol string `json:"protocol,omitempty"` // of subscriptions
+	State    string `json:"state,omitempty"`    // of event source mappings, subscriptions and rules
+}
+
+// topologyNode is a node directory
+type topologyNode struct {
+	kind, name string
+}
+
+// topology is the region's graph
+type topology struct {
+	Edges       []topologyEdge          `json:"edges"`
+	Unavailable map[string]string       `json:"unavailable,omitempty"` // services that denied access, with the error
+	Truncated   map[string]string       `json:"truncated,omitempty"`   // listings cut off at MaxEntries, with the command listing them all
+	nodes       map[topologyNode]string // ARNs
+}
+
+// Listings of the topology that can be cut off, named after the node
+// directory they fill where there is one
+const (
+	topologyQueuesListing        = "queues"
+	topologyTopicsListing        = "topics"
+	topologyRulesListing         = "rules"
+	topologySubscriptionsListing = "subscriptions"
+	topologyEventSourcesListing  = "event-source-mappings"
+)
+
+// topologyListingHints are the AWS CLI commands listing all of each listing
+var topologyListingHints = map[string]string{
+	topologyQueuesListing:        "aws sqs list-queues",
+	topologyTopicsListing:        "aws sns list-topics",
+	topologyRulesListing:         "aws events list-rules",
+	topologySubscriptionsListing: "aws sns list-subscriptions",
+	topologyEventSourcesListing:  "aws lambda list-event-source-mappings",
+}
+
+// truncate notes that a listing was cut off at MaxEntries
+func (t *topology) truncate(listing string) {
+	if t.Truncated == nil {
+		t.Truncated = make(map[string]string)
+	}
+	t.Truncated[listing] = topologyListingHints[listing]
+}
+
+// NewTopologyProvider creates a new topology provider
+func NewTopologyProvider(profile, region string) (*TopologyProvider, error) {
+	cfg, err := LoadAWSConfig(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return newTopologyProvider(cfg), nil
+}
+
+func newTopologyProvider(cfg aws.Config) *TopologyProvider {
+	events := newJSONRPCClient(cfg, "EventBridge", "events", "AWSEvents")
+	events.jsonVersion = "1.1"
+	return &TopologyProvider{
+		region: cfg.Region,
+		sqs:    newJSONRPCClient(cfg, "SQS", "sqs", "AmazonSQS"),
+		sns:    newQueryClient(cfg, "SNS", "sns", "2010-03-31"),
+		lambda: newRESTJSONClient(cfg, "Lambda", "lambda"),
+		events: events,
+		graphs: newDocuments[*topology](),
+	}
+}
+
+func (p *TopologyProvider) Name() string {
+	return "topology"
+}
+
+// Invalidate forgets the memoized graphs, so a refresh describes them anew
+func (p *TopologyProvider) Invalidate(string) {
+	forgetDocuments(p.graphs)
+}
+
+func (p *TopologyProvider) Close() {
+	closeDocuments(p.graphs)
+}
+
+// graph returns the region's topology. Services denying access are left
+// out and noted, so a role without EventBridge access still sees queues.
+func (p *TopologyProvider) graph(ctx context.Context) (*topology, error) {
+	return p.graphs.get("", func() (*topology, error) {
+		t := &topology{Edges: []topologyEdge{}, nodes: make(map[topologyNode]string)}
+		for _, source := range []struct {
+			service string
+			collect func(context.Context, *topology) error
+		}{
+			{"sqs", p.collectQueues},
+			{"sns", p.collectSubscriptions},
+			{"lambda", p.collectEventSources},
+			{"events", p.collectRuleTargets},
+		} {
+			if err := source.collect(ctx, t); err != nil {
+				if !IsAccessDenied(err) {
+					return nil, err
+				}
+				if t.Unavailable == nil {
+					t.Unavailable = make(map[string]string)
+				}
+				t.Unavailable[source.service] = err.Error()
+			}
+		}
+		for _, e := range t.Edges {
+			t.addNode(p.region, e.From)
+			t.addNode(p.region, e.To)
+		}
+		sort.SliceStable(t.Edges, func(i, j int) bool {
+			if t.Edges[i].From != t.Edges[j].From {
+				return t.Edges[i].From < t.Edges[j].From
+			}
+			return t.Edges[i].To < t.Edges[j].To
+		})
+		return t, nil
+	})
+}
+
+// topologyNodeOf returns the node of an ARN in region, or false for ARNs
+// of other regions or services
+func topologyNodeOf(region, arn string) (topologyNode, bool) {
+	// arn:partition:service:region:account:resource
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[3] != region {
+		return topologyNode{}, false
+	}
+	kind, ok := topologyKinds[parts[2]]
+	if !ok {
+		return topologyNode{}, false
+	}
+	name := parts[5]
+	switch kind {
+	case "functions":
+		// function:<name>[:<alias or version>]
+		name = strings.TrimPrefix(name, "function:")
+		name, _, _ = strings.Cut(name, ":")
+	case "rules":
+		// rule/<name> on the default bus, rule/<bus>/<name> otherwise
+		name = strings.ReplaceAll(strings.TrimPrefix(name, "rule/"), "/", ".")
+	}
+	if name == "" {
+		return topologyNode{}, false
+	}
+	return topologyNode{kind, name}, true
+}
+
+// addNode adds the node of arn if it's one in region
+func (t *topology) addNode(region, arn string) {
+	if n, ok := topologyNodeOf(region, arn); ok {
+		t.nodes[n] = arn
+	}
+}
+
+// collectQueues adds every queue as a node, with its redrive edge
+func (p *TopologyProvider) collectQueues(ctx context.Context, t *topology) error {
+	in := map[string]any{"MaxResults": 1000}
+	var urls []string
+	for {
+		var resp struct {
+			QueueUrls []string
+			NextToken string
+		}
+		if err := p.sqs.call(ctx, "ListQueues", in, &resp); err != nil {
+			return err
+		}
+		urls = append(urls, resp.QueueUrls...)
+		if resp.NextToken == "" {
+			break
+		}
+		if len(urls) >= MaxEntries {
+			t.truncate(topologyQueuesListing)
+			break
+		}
+		in = map[string]any{"MaxResults": 1000, "NextToken": resp.NextToken}
+	}
+	for _, u := range urls {
+		var resp struct {
+			Attributes map[string]string
+		}
+		in := map[string]any{"QueueUrl": u, "AttributeNames": []string{"QueueArn", "RedrivePolicy"}}
+		if err := p.sqs.call(ctx, "GetQueueAttributes", in, &resp); err != nil {
+			if isAPIError(err, "QueueDoesNotExist") || isAPIError(err, "AWS.SimpleQueueService.NonExistentQueue") {
+				continue // deleted since listed
+			}
+			return err
+		}
+		arn := resp.Attributes["QueueArn"]
+		t.addNode(p.region, arn)
+		var redrive struct {
+			DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+		}
+		if policy := resp.Attributes["RedrivePolicy"]; policy != "" && json.Unmarshal([]byte(policy), &redrive) == nil && redrive.DeadLetterTargetArn != "" {
+			t.Edges = append(t.Edges, topologyEdge{From: arn, To: redrive.DeadLetterTargetArn, Kind: topologyRedrive})
+		}
+	}
+	return nil
+}
+
+// collectSubscriptions adds every topic as a node, with an edge to each of
+// its subscribers
+func (p *TopologyProvider) collectSubscriptions(ctx context.Context, t *topology) error {
+	params := url.Values{}
+	for n := 0; ; {
+		var resp struct {
+			Topics    []string `xml:"ListTopicsResult>Topics>member>TopicArn"`
+			NextToken string   `xml:"ListTopicsResult>NextToken"`
+		}
+		if err := p.sns.query(ctx, "ListTopics", params, &resp); err != nil {
+			return err
+		}
+		for _, arn := range resp.Topics {
+			t.addNode(p.region, arn)
+		}
+		n += len(resp.Topics)
+		if resp.NextToken == "" {
+			break
+		}
+		if n >= MaxEntries {
+			t.truncate(topologyTopicsListing)
+			break
+		}
+		params = url.Values{"NextToken": {resp.NextToken}}
+	}
+
+	params = url.Values{}
+	for n := 0; ; {
+		var resp struct {
+			Subscriptions []struct {
+				SubscriptionArn string
+				Protocol        string
+				Endpoint        string
+				TopicArn        string
+			} `xml:"ListSubscriptionsResult>Subscriptions>member"`
+			NextToken string `xml:"ListSubscriptionsResult>NextToken"`
+		}
+		if err := p.sns.query(ctx, "ListSubscriptions", params, &resp); err != nil {
+			return err
+		}
+		for _, s := range resp.Subscriptions {
+			e := topologyEdge{From: s.TopicArn, To: s.Endpoint, Kind: topologySubscription, Protocol: s.Protocol}
+			if !strings.HasPrefix(s.SubscriptionArn, "arn:") {
+				e.State = "pending-confirmation"
+			}
+			t.Edges = append(t.Edges, e)
+		}
+		n += len(resp.Subscriptions)
+		if resp.NextToken == "" {
+			return nil
+		}
+		if n >= MaxEntries {
+			t.truncate(topologySubscriptionsListing)
+			return nil
+		}
+		params = url.Values{"NextToken": {resp.NextToken}}
+	}
+}
+
+// collectEventSources adds an edge for each Lambda event source mapping
+func (p *TopologyProvider) collectEventSources(ctx context.Context, t *topology) error {
+	query := url.Values{"MaxItems": {"100"}}
+	for n := 0; ; {
+		var resp struct {
+			EventSourceMappings []struct {
+				EventSourceArn string
+				FunctionArn    string
+				State          string
+			}
+			NextMarker string
+		}
+		if err := p.lambda.do(ctx, "ListEventSourceMappings", "GET", "/2015-03-31/event-source-mappings/", query, nil, &resp); err != nil {
+			return err
+		}
+		for _, m := range resp.EventSourceMappings {
+			if m.EventSourceArn == "" {
+				continue // self-managed Kafka or Amazon MQ, which aren't nodes
+			}
+			t.Edges = append(t.Edges, topologyEdge{From: m.EventSourceArn, To: m.FunctionArn, Kind: topologyEventSource, State: m.State})
+		}
+		n += len(resp.EventSourceMappings)
+		if resp.NextMarker == "" {
+			return nil
+		}
+		if n >= MaxEntries {
+			t.truncate(topologyEventSourcesListing)
+			return nil
+		}
+		query = url.Values{"MaxItems": {"100"}, "Marker": {resp.NextMarker}}
+	}
+}
+
+// collectRuleTargets adds an edge for each target of the rules on every
+// event bus
+func (p *TopologyProvider) collectRuleTargets(ctx context.Context, t *topology) error {
+	var buses []string
+	in := map[string]any{}
+	for {
+		var resp struct {
+			EventBuses []struct{ Name string }
+			NextToken  string
+		}
+		if err := p.events.call(ctx, "ListEventBuses", in, &resp); err != nil {
+			return err
+		}
+		for _, b := range resp.EventBuses {
+			buses = append(buses, b.Name)
+		}
+		if resp.NextToken == "" {
+			break
+		}
+		in = map[string]any{"NextToken": resp.NextToken}
+	}
+
+	n := 0
+	for _, bus := range buses {
+		if n >= MaxEntries {
+			t.truncate(topologyRulesListing)
+			return nil
+		}
+		in := map[string]any{"EventBusName": bus}
+		for {
+			var resp struct {
+				Rules []struct {
+					Name  string
+					Arn   string
+					State string
+				}
+				NextToken string
+			}
+			if err := p.events.call(ctx, "ListRules", in, &resp); err != nil {
+				return err
+			}
+			for _, r := range resp.Rules {
+				targets, err := p.ruleTargets(ctx, bus, r.Name)
+				if err != nil {
+					return err
+				}
+				for _, target := range targets {
+					t.Edges = append(t.Edges, topologyEdge{From: r.Arn, To: target, Kind: topologyRuleTarget, State: r.State})
+				}
+				n++
+			}
+			if resp.NextToken == "" {
+				break
+			}
+			if n >= MaxEntries {
+				t.truncate(topologyRulesListing)
+				return nil
+			}
+			in = map[string]any{"EventBusName": bus, "NextToken": resp.NextToken}
+		}
+	}
+	return nil
+}
+
+// ruleTargets returns the ARNs of a rule's targets
+func (p *TopologyProvider) ruleTargets(ctx context.Context, bus, rule string) ([]string, error) {
+	var arns []string
+	in := map[string]any{"EventBusName": bus, "Rule": rule}
+	for {
+		var resp struct {
+			Targets   []struct{ Arn string }
+			NextToken string
+		}
+		if err := p.events.call(ctx, "ListTargetsByRule", in, &resp); err != nil {
+			return nil, err
+		}
+		for _, target := range resp.Targets {
+			arns = append(arns, target.Arn)
+		}
+		if resp.NextToken == "" {
+			return arns, nil
+		}
+		in = map[string]any{"EventBusName": bus, "Rule": rule, "NextToken": resp.NextToken}
+	}
+}
+
+// neighbours returns the nodes a node delivers to (consumers) or receives
+// from (sources), by kind and name
+func (t *topology) neighbours(region string, n topologyNode, dir string) map[topologyNode]bool {
+	arn := t.nodes[n]
+	found := make(map[topologyNode]bool)
+	for _, e := range t.Edges {
+		near, far := e.From, e.To
+		if dir == topologySourcesDir {
+			near, far = e.To, e.From
+		}
+		if near != arn {
+			continue
+		}
+		if m, ok := topologyNodeOf(region, far); ok {
+			found[m] = true
+		}
+	}
+	return found
+}
+
+// node returns the node of a <kind>/<name> path, or an error wrapping
+// os.ErrNotExist
+func (p *TopologyProvider) node(t *topology, kind, name string) (topologyNode, error) {
+	n := topologyNode{kind, name}
+	if _, ok := t.nodes[n]; !ok {
+		return n, fmt.Errorf("node not found: %s/%s: %w", kind, name, os.ErrNotExist)
+	}
+	return n, nil
+}
+
+// topologyLink returns the symlink from a node's consumers/<kind> or
+// sources/<kind> directory to node n
+func topologyLink(n topologyNode) Entry {
+	target := "../../../../" + n.kind + "/" + n.name
+	return Entry{Name: n.name, Size: int64(len(target)), Link: target}
+}
+
+func (p *TopologyProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
+	t, err := p.graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return []Entry{
+			{Name: topologyEdgesFile, IsDir: false, Size: 4096},
+			{Name: "functions", IsDir: true},
+			{Name: "queues", IsDir: true},
+			{Name: "rules", IsDir: true},
+			{Name: "topics", IsDir: true},
+		}, nil
+	}
+
+	parts := strings.Split(path, "/")
+	if !isTopologyKind(parts[0]) {
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	var entries []Entry
+	switch len(parts) {
+	case 1:
+		entries = []Entry{}
+		for n := range t.nodes {
+			if n.kind == parts[0] {
+				entries = append(entries, Entry{Name: n.name, IsDir: true})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		// Functions are only known from the event source mappings and
+		// rule targets pointing at them
+		listing := parts[0]
+		if listing == "functions" {
+			listing = topologyEventSourcesListing
+		}
+		_, more := t.Truncated[listing]
+		return capEntries(entries, more, topologyListingHints[listing]), nil
+	case 2:
+		if _, err := p.node(t, parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+		return []Entry{
+			{Name: topologyConsumersDir, IsDir: true},
+			{Name: topologySourcesDir, IsDir: true},
+		}, nil
+	case 3, 4:
+		n, err := p.node(t, parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if parts[2] != topologyConsumersDir && parts[2] != topologySourcesDir {
+			return nil, fmt.Errorf("unknown path: %s", path)
+		}
+		entries = []Entry{}
+		seen := make(map[string]bool)
+		for m := range t.neighbours(p.region, n, parts[2]) {
+			switch {
+			case len(parts) == 3 && !seen[m.kind]:
+				seen[m.kind] = true
+				entries = append(entries, Entry{Name: m.kind, IsDir: true})
+			case len(parts) == 4 && m.kind == parts[3]:
+				entries = append(entries, topologyLink(m))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown path: %s", path)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func isTopologyKind(kind string) bool {
+	for _, k := range topologyKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TopologyProvider) Read(ctx context.Context, path string) ([]byte, error) {
+	if path != topologyEdgesFile {
+		return nil, fmt.Errorf("unknown file: %s", path)
+	}
+	t, err := p.graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(t, "", "  ")
+}
+
+func (p *TopologyProvider) Stat(ctx context.Context, path string) (*Entry, error) {
+	if path == "" {
+		return &Entry{Name: "topology", IsDir: true}, nil
+	}
+	if path == topologyEdgesFile {
+		return &Entry{Name: path, IsDir: false, Size: 4096}, nil
+	}
+	parts := strings.Split(path, "/")
+	if !isTopologyKind(parts[0]) || len(parts) > 5 {
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	if len(parts) == 1 {
+		return &Entry{Name: parts[0], IsDir: true}, nil
+	}
+	t, err := p.graph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := p.node(t, parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 2 {
+		return &Entry{Name: n.name, IsDir: true}, nil
+	}
+	if parts[2] != topologyConsumersDir && parts[2] != topologySourcesDir {
+		return nil, fmt.Errorf("path not found: %s", path)
+	}
+	if len(parts) == 3 {
+		return &Entry{Name: parts[2], IsDir: true}, nil
+	}
+	for m := range t.neighbours(p.region, n, parts[2]) {
+		switch {
+		case len(parts) == 4 && m.kind == parts[3]:
+			return &Entry{Name: m.kind, IsDir: true}, nil
+		case len(parts) == 5 && m.kind == parts[3] && m.name == parts[4]:
+			link := topologyLink(m)
+			return &link, nil
+		}
+	}
+	return nil, fmt.Errorf("path not found: %s: %w", path, os.ErrNotExist)
+}
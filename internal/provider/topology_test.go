@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTopology(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "topology")
+	p := newTopologyProvider(cfg)
+	ctx := context.Background()
+
+	for dir, want := range map[string][]string{
+		"queues":    {"orders", "orders-dlq"},
+		"topics":    {"order-events"},
+		"functions": {"process-orders", "replay-orders"},
+		"rules":     {"checkout.order-placed", "nightly-report"},
+
+		// What consumes the queue, and what feeds it
+		"queues/orders/consumers":           {"functions", "queues"},
+		"queues/orders/consumers/functions": {"process-orders"},
+		"queues/orders/consumers/queues":    {"orders-dlq"},
+		"queues/orders/sources/topics":      {"order-events"},
+		"topics/order-events/sources/rules": {"checkout.order-placed"},
+
+		// The report function is in another region, so only in edges.json
+		"rules/nightly-report/consumers": {},
+	} {
+		entries, err := p.ReadDir(ctx, dir)
+		if err != nil {
+			t.Fatalf("ReadDir(%s): %v", dir, err)
+		}
+		if names := entryNames(entries); len(names)+len(want) > 0 && !reflect.DeepEqual(names, want) {
+			t.Errorf("ReadDir(%s) = %v, want %v", dir, names, want)
+		}
+	}
+
+	entry, err := p.Stat(ctx, "queues/orders/consumers/functions/process-orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Link != "../../../../functions/process-orders" {
+		t.Errorf("link = %q", entry.Link)
+	}
+	if _, err := p.Stat(ctx, "queues/missing/consumers"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat of a missing queue = %v, want ErrNotExist", err)
+	}
+
+	data, err := p.Read(ctx, "edges.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "topology/edges.json", data)
+}
+
+func TestTopologyTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 1
+	cfg, _ := fixtureConfig(t, "topology")
+	p := newTopologyProvider(cfg)
+	ctx := context.Background()
+
+	// The checkout bus's rules are never listed once the default bus has
+	// filled MaxEntries
+	for dir, want := range map[string][]string{
+		"queues": {"orders", MoreResultsFile},
+		"rules":  {"nightly-report", MoreResultsFile},
+	} {
+		entries, err := p.ReadDir(ctx, dir)
+		if err != nil {
+			t.Fatalf("ReadDir(%s): %v", dir, err)
+		}
+		if names := entryNames(entries); !reflect.DeepEqual(names, want) {
+			t.Errorf("ReadDir(%s) = %v, want %v", dir, names, want)
+		}
+	}
+	data, err := p.Read(ctx, "edges.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph struct{ Truncated map[string]string }
+	if err := json.Unmarshal(data, &graph); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Truncated[topologyRulesListing] != "aws events list-rules" {
+		t.Errorf("truncated = %v", graph.Truncated)
+	}
+}
+
+func TestTopologyNodeOf(t *testing.T) {
+	for arn, want := range map[string]topologyNode{
+		"arn:aws:sqs:us-east-1:123456789012:orders.fifo":                {"queues", "orders.fifo"},
+		"arn:aws:lambda:us-east-1:123456789012:function:process:live":   {"functions", "process"},
+		"arn:aws:events:us-east-1:123456789012:rule/aws.partner/x/rule": {"rules", "aws.partner.x.rule"},
+		"arn:aws:sqs:eu-west-1:123456789012:orders":                     {},
+		"arn:aws:kinesis:us-east-1:123456789012:stream/clicks":          {},
+		"ops@example.com": {},
+	} {
+		got, ok := topologyNodeOf("us-east-1", arn)
+		if got != want || ok != (want != topologyNode{}) {
+			t.Errorf("topologyNodeOf(%s) = %v, %v", arn, got, ok)
+		}
+	}
+}
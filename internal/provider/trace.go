@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Trace records one provider call and the AWS requests it sent
+type Trace struct {
+	Time      time.Time     `json:"time"`
+	Provider  string        `json:"provider"`
+	Operation Op            `json:"operation"`
+	Path      string        `json:"path"`
+	Duration  time.Duration `json:"duration_ns"`
+	Error     string        `json:"error,omitempty"`
+	Calls     []TraceCall   `json:"calls"`
+
+	mu sync.Mutex
+}
+
+// TraceCall is one AWS request attempt; retries show up as separate calls
+type TraceCall struct {
+	Service   string        `json:"service"`
+	Operation string        `json:"operation"`
+	Duration  time.Duration `json:"duration_ns"`
+	Status    int           `json:"status,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+func (t *Trace) add(c TraceCall) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Calls = append(t.Calls, c)
+}
+
+type traceKey struct{}
+
+// traceFrom returns the trace being recorded for ctx, if any
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// KeepTraces is how many trace files a Tracer leaves in its directory
+const KeepTraces = 200
+
+// Tracer writes a trace file for every provider call into a directory,
+// keeping the newest KeepTraces
+type Tracer struct {
+	dir string
+	seq atomic.Int64
+}
+
+// NewTracer returns a tracer writing into dir, which is created if needed
+func NewTracer(dir string) (*Tracer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Tracer{dir: dir}, nil
+}
+
+// Middleware returns a middleware tracing every call of the provider it
+// wraps. Calls made while another is traced, like a retry's attempts,
+// belong to the outer call's trace.
+func (t *Tracer) Middleware() Middleware {
+	return func(p Provider) Provider {
+		name := p.Name()
+		return Intercept(func(ctx context.Context, op Op, path string, call func(context.Context) error) error {
+			if traceFrom(ctx) != nil {
+				return call(ctx)
+			}
+			tr := &Trace{Time: time.Now(), Provider: name, Operation: op, Path: path}
+			err := call(context.WithValue(ctx, traceKey{}, tr))
+			tr.Duration = time.Since(tr.Time)
+			if err != nil {
+				tr.Error = err.Error()
+			}
+			if werr := t.write(tr); werr != nil {
+				log.Printf("[trace] %v", werr)
+			}
+			return err
+		})(p)
+	}
+}
+
+// write saves tr as <time>-<n>.json, named so that files sort by when
+// their call started, and removes the oldest files beyond KeepTraces
+func (t *Tracer) write(tr *Trace) error {
+	tr.mu.Lock()
+	data, err := json.MarshalIndent(tr, "", "  ")
+	tr.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%06d.json", tr.Time.UTC().Format("20060102T150405.000000000"), t.seq.Add(1))
+	if err := os.WriteFile(filepath.Join(t.dir, name), append(data, '\n'), 0600); err != nil {
+		return err
+	}
+
+	files, err := traceFiles(t.dir)
+	if err != nil {
+		return err
+	}
+	for _, old := range files[:max(len(files)-KeepTraces, 0)] {
+		os.Remove(old)
+	}
+	return nil
+}
+
+// traceFiles returns the trace files in dir, oldest first
+func traceFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+	return files, err
+}
+
+// LastTrace reads the most recent trace file in dir
+func LastTrace(dir string) (*Trace, string, error) {
+	files, err := traceFiles(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("no traces in %s; mount with --debug to record them", dir)
+	}
+	file := files[len(files)-1]
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	var tr Trace
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", file, err)
+	}
+	return &tr, file, nil
+}
+
+// traceAPIOption adds every request an SDK client sends to the trace of the
+// provider call it was made for. Like the usage counter it runs after the
+// retry middleware, so each attempt is a separate call.
+func traceAPIOption(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("SisuTrace",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			tr := traceFrom(ctx)
+			if tr == nil {
+				return next.HandleFinalize(ctx, in)
+			}
+			start := time.Now()
+			out, meta, err := next.HandleFinalize(ctx, in)
+			c := TraceCall{
+				Service:   strings.ToLower(awsmiddleware.GetServiceID(ctx)),
+				Operation: awsmiddleware.GetOperationName(ctx),
+				Duration:  time.Since(start),
+			}
+			c.RequestID, _ = awsmiddleware.GetRequestIDMetadata(meta)
+			if resp, ok := awsmiddleware.GetRawResponse(meta).(*smithyhttp.Response); ok {
+				c.Status = resp.StatusCode
+			}
+			if err != nil {
+				c.Error = err.Error()
+			}
+			tr.add(c)
+			return out, meta, err
+		}), middleware.After)
+}
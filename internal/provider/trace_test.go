@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTracerRecordsAWSRequests(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "iam")
+	cfg.APIOptions = append(cfg.APIOptions, traceAPIOption)
+	dir := t.TempDir()
+	tracer, err := NewTracer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Chain(newIAMProvider(cfg), tracer.Middleware())
+
+	// The second page of users is denied
+	if _, err := p.ReadDir(context.Background(), "users"); err == nil {
+		t.Fatal("ReadDir succeeded")
+	}
+
+	tr, _, err := LastTrace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Provider != "iam" || tr.Operation != OpReadDir || tr.Path != "users" || tr.Error == "" {
+		t.Errorf("trace = %+v", tr)
+	}
+	if len(tr.Calls) != 2 {
+		t.Fatalf("calls = %+v, want both ListUsers pages", tr.Calls)
+	}
+	for i, want := range []int{200, 403} {
+		c := tr.Calls[i]
+		if c.Service != "iam" || c.Operation != "ListUsers" || c.Status != want {
+			t.Errorf("call %d = %+v, want iam ListUsers %d", i, c, want)
+		}
+	}
+	if tr.Calls[1].Error == "" {
+		t.Error("denied call has no error")
+	}
+}
+
+func TestLastTraceWithoutTraces(t *testing.T) {
+	if _, _, err := LastTrace(t.TempDir()); err == nil {
+		t.Error("LastTrace of an empty directory succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// APIUsage counts the AWS API requests actually sent, per service and
+// operation. Unlike Metrics, which counts provider calls, this sees every
+// request a provider call fans out into, including SDK retries.
+type APIUsage struct {
+	mu    sync.Mutex
+	calls map[string]map[string]int64 // service -> operation -> requests
+}
+
+// NewAPIUsage creates an empty usage counter
+func NewAPIUsage() *APIUsage {
+	return &APIUsage{calls: make(map[string]map[string]int64)}
+}
+
+// Usage counts requests made by every client created through LoadAWSConfig
+var Usage = NewAPIUsage()
+
+// Record counts a single request
+func (u *APIUsage) Record(service, operation string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ops, ok := u.calls[service]
+	if !ok {
+		ops = make(map[string]int64)
+		u.calls[service] = ops
+	}
+	ops[operation]++
+}
+
+// Snapshot returns a copy of the current counts
+func (u *APIUsage) Snapshot() map[string]map[string]int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	out := make(map[string]map[string]int64, len(u.calls))
+	for service, ops := range u.calls {
+		out[service] = make(map[string]int64, len(ops))
+		for op, n := range ops {
+			out[service][op] = n
+		}
+	}
+	return out
+}
+
+// apiOption installs the counting middleware on an SDK client. It runs
+// after the retry middleware, so every attempt sent to AWS is counted.
+func (u *APIUsage) apiOption(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("SisuAPIUsage",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			service := strings.ToLower(awsmiddleware.GetServiceID(ctx))
+			u.Record(service, awsmiddleware.GetOperationName(ctx))
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+}
+
+// requestPrices are us-east-1 list prices in USD per request for APIs that
+// bill per call. Control-plane APIs (IAM, EC2 Describe*, Lambda Get*) and
+// SSM standard-throughput calls are free and aren't listed.
+var requestPrices = map[string]map[string]float64{
+	"s3": {
+		"ListBuckets":   0.005 / 1000,
+		"ListObjectsV2": 0.005 / 1000,
+		"PutObject":     0.005 / 1000,
+		"CopyObject":    0.005 / 1000,
+		"GetObject":     0.0004 / 1000,
+		"HeadObject":    0.0004 / 1000,
+		"HeadBucket":    0.0004 / 1000,
+	},
+}
+
+// CostNote explains what EstimateCost covers
+const CostNote = "Estimate from us-east-1 list prices for per-request charges only; excludes data transfer, KMS decryption and higher-throughput SSM"
+
+// EstimateCost returns the estimated request charges in USD for calls,
+// a snapshot from APIUsage
+func EstimateCost(calls map[string]map[string]int64) float64 {
+	var total float64
+	for service, ops := range calls {
+		for op, n := range ops {
+			total += requestPrices[service][op] * float64(n)
+		}
+	}
+	return total
+}
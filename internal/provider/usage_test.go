@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestAPIUsageCountsRequests(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "s3")
+	usage := NewAPIUsage()
+	cfg.APIOptions = append(cfg.APIOptions, usage.apiOption)
+	p := newS3Provider(cfg)
+
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+
+	if _, err := p.ReadDir(context.Background(), "my-bucket/logs"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := usage.Snapshot()
+	if n := calls["s3"]["ListObjectsV2"]; n == 0 {
+		t.Fatalf("ListObjectsV2 not counted: %v", calls)
+	}
+
+	want := float64(calls["s3"]["ListObjectsV2"]) * 0.005 / 1000
+	if got := EstimateCost(calls); math.Abs(got-want) > 1e-12 {
+		t.Errorf("EstimateCost = %g, want %g", got, want)
+	}
+}
+
+func TestEstimateCostIgnoresFreeAPIs(t *testing.T) {
+	calls := map[string]map[string]int64{
+		"iam": {"ListRoles": 1000},
+		"ec2": {"DescribeInstances": 1000},
+		"s3":  {"GetObject": 1000},
+	}
+	if got := EstimateCost(calls); math.Abs(got-0.0004) > 1e-12 {
+		t.Errorf("EstimateCost = %g, want 0.0004", got)
+	}
+}
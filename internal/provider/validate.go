@@ -0,0 +1,257 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+)
+
+// WriteValidator checks content before it is written to path. Rejections
+// wrap fs.ErrInvalid so the filesystem can report EINVAL with the reason
+// instead of an opaque API failure.
+type WriteValidator func(path string, data []byte) error
+
+// Validate runs every write through the given validators before it
+// reaches the wrapped provider. Rejections are always logged, since the
+// EINVAL seen by the writing program doesn't say what was wrong.
+func Validate(validators ...WriteValidator) Middleware {
+	return func(p Provider) Provider {
+		return &validatingProvider{Provider: p, validators: validators}
+	}
+}
+
+type validatingProvider struct {
+	Provider
+	validators []WriteValidator
+}
+
+func (p *validatingProvider) Write(ctx context.Context, path string, data []byte) error {
+	for _, v := range p.validators {
+		if err := v(path, data); err != nil {
+			log.Printf("[%s] rejected write: %v", p.Name(), err)
+			return err
+		}
+	}
+	return p.Provider.Write(ctx, path, data)
+}
+
+func (p *validatingProvider) Invalidate(path string) {
+	Invalidate(p.Provider, path)
+}
+
+func (p *validatingProvider) Close() {
+	Close(p.Provider)
+}
+
+func (p *validatingProvider) Written(path string, data []byte) ([]byte, bool) {
+	return Written(p.Provider, path, data)
+}
+
+func (p *validatingProvider) ReadRange(ctx context.Context, path string, off, length int64) ([]byte, error) {
+	return ReadRange(ctx, p.Provider, path, off, length)
+}
+
+// invalidf returns a validation error wrapping fs.ErrInvalid
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), fs.ErrInvalid)
+}
+
+// PolicyFiles returns a validator that checks writes to paths accepted by
+// match are well-formed IAM policy documents
+func PolicyFiles(match func(path string) bool) WriteValidator {
+	return func(path string, data []byte) error {
+		if !match(path) {
+			return nil
+		}
+		if err := ValidatePolicyDocument(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+}
+
+var (
+	policyKeys    = map[string]bool{"Version": true, "Id": true, "Statement": true}
+	statementKeys = map[string]bool{
+		"Sid": true, "Effect": true,
+		"Principal": true, "NotPrincipal": true,
+		"Action": true, "NotAction": true,
+		"Resource": true, "NotResource": true,
+		"Condition": true,
+	}
+)
+
+// ValidatePolicyDocument checks the structure of an IAM policy document:
+// known top-level and statement keys, a supported Version, an Effect of
+// Allow or Deny, exactly one of Action/NotAction, and a Resource unless the
+// statement names a Principal (resource and trust policies)
+func ValidatePolicyDocument(data []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return invalidf("policy is not a JSON object: %v", err)
+	}
+	for k := range doc {
+		if !policyKeys[k] {
+			return invalidf("unknown policy element %q", k)
+		}
+	}
+
+	if raw, ok := doc["Version"]; ok {
+		var version string
+		if json.Unmarshal(raw, &version) != nil || (version != "2012-10-17" && version != "2008-10-17") {
+			return invalidf("Version must be \"2012-10-17\" or \"2008-10-17\"")
+		}
+	}
+
+	raw, ok := doc["Statement"]
+	if !ok {
+		return invalidf("policy has no Statement")
+	}
+	statements, err := policyStatements(raw)
+	if err != nil {
+		return err
+	}
+	if len(statements) == 0 {
+		return invalidf("policy has no Statement")
+	}
+
+	for i, st := range statements {
+		if err := validateStatement(st); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// policyStatements decodes a Statement element, which is either one
+// statement or a list of them
+func policyStatements(raw json.RawMessage) ([]map[string]json.RawMessage, error) {
+	var statements []map[string]json.RawMessage
+	if json.Unmarshal(raw, &statements) != nil {
+		var single map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return nil, invalidf("Statement must be an object or a list of objects")
+		}
+		statements = []map[string]json.RawMessage{single}
+	}
+	return statements, nil
+}
+
+func validateStatement(st map[string]json.RawMessage) error {
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !statementKeys[k] {
+			return invalidf("unknown statement element %q", k)
+		}
+	}
+
+	var effect string
+	if json.Unmarshal(st["Effect"], &effect) != nil || (effect != "Allow" && effect != "Deny") {
+		return invalidf("Effect must be \"Allow\" or \"Deny\"")
+	}
+
+	if err := exactlyOne(st, "Action", "NotAction"); err != nil {
+		return err
+	}
+	_, hasPrincipal := st["Principal"]
+	_, hasNotPrincipal := st["NotPrincipal"]
+	if hasPrincipal || hasNotPrincipal {
+		if hasPrincipal && hasNotPrincipal {
+			return invalidf("Principal and NotPrincipal are mutually exclusive")
+		}
+	} else if err := exactlyOne(st, "Resource", "NotResource"); err != nil {
+		return err
+	}
+
+	for _, k := range []string{"Action", "NotAction", "Resource", "NotResource"} {
+		if raw, ok := st[k]; ok && !isStringOrList(raw) {
+			return invalidf("%s must be a string or a list of strings", k)
+		}
+	}
+	return nil
+}
+
+func exactlyOne(st map[string]json.RawMessage, a, b string) error {
+	_, hasA := st[a]
+	_, hasB := st[b]
+	if hasA == hasB {
+		return invalidf("statement needs exactly one of %s or %s", a, b)
+	}
+	return nil
+}
+
+func isStringOrList(raw json.RawMessage) bool {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return true
+	}
+	var list []string
+	return json.Unmarshal(raw, &list) == nil && len(list) > 0
+}
+
+// isIAMPolicyFile reports whether an IAM path holds a policy document
+func isIAMPolicyFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 2 && parts[0] == "policies" && strings.HasSuffix(parts[1], ".json") && !isMoreResults(path)
+}
+
+// isLambdaPolicyFile reports whether a Lambda path holds a resource policy
+func isLambdaPolicyFile(path string) bool {
+	parts := strings.Split(path, "/")
+	return len(parts) == 2 && parts[1] == "policy.json"
+}
+
+// validateTrustPolicy checks a role's trust-policy.json is a policy
+// document whose every statement names who may assume the role
+func validateTrustPolicy(path string, data []byte) error {
+	if !isIAMTrustPolicyFile(path) {
+		return nil
+	}
+	if err := ValidatePolicyDocument(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var doc struct {
+		Statement json.RawMessage
+	}
+	json.Unmarshal(data, &doc)
+	statements, _ := policyStatements(doc.Statement)
+	for i, st := range statements {
+		_, hasPrincipal := st["Principal"]
+		_, hasNotPrincipal := st["NotPrincipal"]
+		if !hasPrincipal && !hasNotPrincipal {
+			return invalidf("%s: statement %d: trust policy statements need a Principal", path, i+1)
+		}
+	}
+	return nil
+}
+
+// Validators returns the write validators for a service
+func Validators(service string) []WriteValidator {
+	switch service {
+	case "iam":
+		return []WriteValidator{PolicyFiles(isIAMPolicyFile), validateTrustPolicy}
+	case "lambda":
+		return []WriteValidator{PolicyFiles(isLambdaPolicyFile), validateLambdaEnv, TagFiles(isTagsFile)}
+	case "ssm":
+		return []WriteValidator{validateSSMMeta, validateSSMSize}
+	case "ec2":
+		return []WriteValidator{TagFiles(isEC2TagsFile)}
+	case "dynamodb":
+		return []WriteValidator{TagFiles(isTagsFile)}
+	case "s3":
+		return []WriteValidator{TagFiles(isS3TagsFile)}
+	case "sqs":
+		return []WriteValidator{validateSQSSend}
+	case "athena":
+		return []WriteValidator{validateAthenaQuery}
+	}
+	return nil
+}
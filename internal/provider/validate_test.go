@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestValidatePolicyDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		valid bool
+	}{
+		{"identity policy", `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`, true},
+		{"single statement object", `{"Statement":{"Effect":"Deny","NotAction":["iam:*"],"Resource":"*"}}`, true},
+		{"trust policy without resource", `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"lambda.amazonaws.com"},"Action":"sts:AssumeRole"}]}`, true},
+		{"not json", `Statement: []`, false},
+		{"bad version", `{"Version":"2020-01-01","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`, false},
+		{"no statement", `{"Version":"2012-10-17"}`, false},
+		{"bad effect", `{"Statement":[{"Effect":"allow","Action":"*","Resource":"*"}]}`, false},
+		{"action and notaction", `{"Statement":[{"Effect":"Allow","Action":"*","NotAction":"s3:*","Resource":"*"}]}`, false},
+		{"missing resource", `{"Statement":[{"Effect":"Allow","Action":"*"}]}`, false},
+		{"typo in key", `{"Statement":[{"Effect":"Allow","Actions":"*","Resource":"*"}]}`, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePolicyDocument([]byte(tt.doc))
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: got %v, want ErrInvalid", tt.name, err)
+		}
+	}
+}
+
+func TestValidateSSMSize(t *testing.T) {
+	defer func(v bool) { SSMAutoAdvancedTier = v }(SSMAutoAdvancedTier)
+
+	large := []byte(strings.Repeat("x", ssmStandardMaxSize+1))
+	SSMAutoAdvancedTier = false
+	if err := validateSSMSize("app/big", large); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("standard tier: got %v, want ErrInvalid", err)
+	}
+	SSMAutoAdvancedTier = true
+	if err := validateSSMSize("app/big", large); err != nil {
+		t.Errorf("auto advanced tier: unexpected error %v", err)
+	}
+	if err := validateSSMSize("app/huge", []byte(strings.Repeat("x", ssmAdvancedMaxSize+1))); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("over advanced limit: got %v, want ErrInvalid", err)
+	}
+	// The trailing newline added by editors doesn't count
+	if err := validateSSMSize("app/exact", []byte(strings.Repeat("x", ssmStandardMaxSize)+"\n")); err != nil {
+		t.Errorf("exact size: unexpected error %v", err)
+	}
+}
+
+func TestValidateRejectsBeforeWrite(t *testing.T) {
+	fake := newFakeProvider(map[string][]byte{})
+	p := Chain(fake, Validate(PolicyFiles(isIAMPolicyFile)))
+
+	err := p.Write(context.Background(), "policies/broken.json", []byte(`{"Statement":[]}`))
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Write = %v, want ErrInvalid", err)
+	}
+	if fake.calls[OpWrite] != 0 {
+		t.Errorf("invalid write reached the provider")
+	}
+}
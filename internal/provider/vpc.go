@@ -6,13 +6,12 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/semonte/sisu/internal/cache"
+
+	"github.com/semonte/sisu/internal/paging"
 )
 
 // Debug controls whether VPC provider operations are logged
@@ -21,53 +20,49 @@ var Debug bool
 // VPCProvider provides access to AWS VPCs
 type VPCProvider struct {
 	ReadOnlyProvider
-	client *ec2.Client
-	cache  *cache.Cache
+	client     *ec2.Client
+	groups     *documents[[]types.SecurityGroup]    // by VPC ID
+	interfaces *documents[[]types.NetworkInterface] // by VPC ID
 }
 
 // NewVPCProvider creates a new VPC provider
 func NewVPCProvider(profile, region string) (*VPCProvider, error) {
-	var opts []func(*config.LoadOptions) error
-	if profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
-	}
-	if region != "" {
-		opts = append(opts, config.WithRegion(region))
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	cfg, err := LoadAWSConfig(profile, region)
 	if err != nil {
 		return nil, err
 	}
+	return newVPCProvider(cfg), nil
+}
 
+func newVPCProvider(cfg aws.Config) *VPCProvider {
 	return &VPCProvider{
-		client: ec2.NewFromConfig(cfg),
-		cache:  cache.New(5 * time.Minute),
-	}, nil
+		client:     ec2.NewFromConfig(cfg),
+		groups:     newDocuments[[]types.SecurityGroup](),
+		interfaces: newDocuments[[]types.NetworkInterface](),
+	}
 }
 
 func (p *VPCProvider) Name() string {
 	return "vpc"
 }
 
-func (p *VPCProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
-	cacheKey := "readdir:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]Entry), nil
-	}
+// Invalidate forgets the memoized security groups and network interfaces, so a refresh describes them anew
+func (p *VPCProvider) Invalidate(string) {
+	forgetDocuments(p.groups, p.interfaces)
+}
 
-	entries, err := p.readDirUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entries)
-	}
-	return entries, err
+func (p *VPCProvider) Close() {
+	closeDocuments(p.groups, p.interfaces)
 }
 
-func (p *VPCProvider) readDirUncached(ctx context.Context, path string) ([]Entry, error) {
+func (p *VPCProvider) ReadDir(ctx context.Context, path string) ([]Entry, error) {
 	// Root: list all VPCs
 	if path == "" {
 		return p.listVPCs(ctx)
 	}
+	if path == AuditDir {
+		return p.auditFiles().entries(), nil
+	}
 
 	parts := strings.SplitN(path, "/", 2)
 	vpcID := parts[0]
@@ -76,6 +71,7 @@ func (p *VPCProvider) readDirUncached(ctx context.Context, path string) ([]Entry
 	if len(parts) == 1 {
 		return []Entry{
 			{Name: "info.json", IsDir: false},
+			{Name: "summary.json", IsDir: false},
 			{Name: "subnets", IsDir: true},
 			{Name: "route-tables", IsDir: true},
 			{Name: "security-groups", IsDir: true},
@@ -97,103 +93,118 @@ func (p *VPCProvider) readDirUncached(ctx context.Context, path string) ([]Entry
 }
 
 func (p *VPCProvider) listVPCs(ctx context.Context) ([]Entry, error) {
-	resp, err := p.client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{})
-	if err != nil {
-		return nil, err
-	}
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		resp, err := p.client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+			NextToken: optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
 
-	entries := make([]Entry, len(resp.Vpcs))
-	for i, vpc := range resp.Vpcs {
-		entries[i] = Entry{
-			Name:  aws.ToString(vpc.VpcId),
-			IsDir: true,
+		entries := make([]Entry, len(resp.Vpcs))
+		for i, vpc := range resp.Vpcs {
+			entries[i] = Entry{
+				Name:  aws.ToString(vpc.VpcId),
+				IsDir: true,
+			}
 		}
+		return entries, aws.ToString(resp.NextToken), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, "aws ec2 describe-vpcs", err)
 	}
-
 	return entries, nil
 }
 
 func (p *VPCProvider) listSubnets(ctx context.Context, vpcID string) ([]Entry, error) {
-	resp, err := p.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
-		Filters: []types.Filter{
-			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		resp, err := p.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+			NextToken: optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
 
-	entries := make([]Entry, len(resp.Subnets))
-	for i, subnet := range resp.Subnets {
-		entries[i] = Entry{
-			Name:  aws.ToString(subnet.SubnetId) + ".json",
-			IsDir: false,
+		entries := make([]Entry, len(resp.Subnets))
+		for i, subnet := range resp.Subnets {
+			entries[i] = Entry{
+				Name:  aws.ToString(subnet.SubnetId) + ".json",
+				IsDir: false,
+			}
 		}
+		return entries, aws.ToString(resp.NextToken), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, vpcListHint("describe-subnets", vpcID), err)
 	}
-
 	return entries, nil
 }
 
 func (p *VPCProvider) listRouteTables(ctx context.Context, vpcID string) ([]Entry, error) {
-	resp, err := p.client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
-		Filters: []types.Filter{
-			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
+	entries, _, err := paging.Collect(ctx, MaxEntries, func(ctx context.Context, token string) ([]Entry, string, error) {
+		resp, err := p.client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+			NextToken: optionalString(token),
+		})
+		if err != nil {
+			return nil, "", err
+		}
 
-	entries := make([]Entry, len(resp.RouteTables))
-	for i, rt := range resp.RouteTables {
-		entries[i] = Entry{
-			Name:  aws.ToString(rt.RouteTableId) + ".json",
-			IsDir: false,
+		entries := make([]Entry, len(resp.RouteTables))
+		for i, rt := range resp.RouteTables {
+			entries[i] = Entry{
+				Name:  aws.ToString(rt.RouteTableId) + ".json",
+				IsDir: false,
+			}
 		}
+		return entries, aws.ToString(resp.NextToken), nil
+	})
+	if err != nil {
+		return nil, partialListing(entries, vpcListHint("describe-route-tables", vpcID), err)
 	}
-
 	return entries, nil
 }
 
+// vpcListHint is the AWS CLI command listing a VPC's resources of a kind
+func vpcListHint(command, vpcID string) string {
+	return fmt.Sprintf("aws ec2 %s --filters Name=vpc-id,Values=%s", command, vpcID)
+}
+
+// listSecurityGroups lists each group's description and, next to it, what
+// references the group
 func (p *VPCProvider) listSecurityGroups(ctx context.Context, vpcID string) ([]Entry, error) {
-	resp, err := p.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
-		Filters: []types.Filter{
-			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
-		},
-	})
+	groups, err := p.securityGroups(ctx, vpcID)
 	if err != nil {
 		return nil, err
 	}
 
-	entries := make([]Entry, len(resp.SecurityGroups))
-	for i, sg := range resp.SecurityGroups {
-		entries[i] = Entry{
-			Name:  aws.ToString(sg.GroupId) + ".json",
-			IsDir: false,
-		}
+	entries := make([]Entry, 0, 2*len(groups))
+	for _, sg := range groups {
+		entries = append(entries,
+			Entry{Name: aws.ToString(sg.GroupId) + ".json", IsDir: false},
+			Entry{Name: aws.ToString(sg.GroupId) + sgReferencesSuffix, IsDir: false},
+		)
 	}
 
-	return entries, nil
+	// The groups are all fetched for the referenced-by reports, but the
+	// listing is capped like the others
+	return capEntries(entries, false, vpcListHint("describe-security-groups", vpcID)), nil
 }
 
 func (p *VPCProvider) Read(ctx context.Context, path string) ([]byte, error) {
-	cacheKey := "read:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.([]byte), nil
-	}
-
-	data, err := p.readUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, data)
-	}
-	return data, err
-}
-
-func (p *VPCProvider) readUncached(ctx context.Context, path string) ([]byte, error) {
 	if Debug {
 		log.Printf("[vpc] Read: path=%q", path)
 	}
 
+	if file, ok := cutAudit(path); ok {
+		return p.auditFiles().read(ctx, file)
+	}
+
 	parts := strings.Split(path, "/")
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("invalid path: %s", path)
@@ -208,6 +219,9 @@ func (p *VPCProvider) readUncached(ctx context.Context, path string) ([]byte, er
 	if len(parts) == 2 && parts[1] == "info.json" {
 		return p.getVPCInfo(ctx, vpcID)
 	}
+	if len(parts) == 2 && parts[1] == "summary.json" {
+		return p.getVPCSummary(ctx, vpcID)
+	}
 
 	// Subnets, route tables, security groups
 	if len(parts) == 3 {
@@ -224,6 +238,9 @@ func (p *VPCProvider) readUncached(ctx context.Context, path string) ([]byte, er
 		case "route-tables":
 			return p.getRouteTableInfo(ctx, resourceFile)
 		case "security-groups":
+			if strings.HasSuffix(resourceFile, sgReferencesSuffix) {
+				return p.getSecurityGroupReferences(ctx, vpcID, strings.TrimSuffix(resourceFile, sgReferencesSuffix))
+			}
 			return p.getSecurityGroupInfo(ctx, resourceFile)
 		}
 	}
@@ -294,22 +311,12 @@ func (p *VPCProvider) getSecurityGroupInfo(ctx context.Context, filename string)
 }
 
 func (p *VPCProvider) Stat(ctx context.Context, path string) (*Entry, error) {
-	cacheKey := "stat:" + path
-	if cached, ok := p.cache.Get(cacheKey); ok {
-		return cached.(*Entry), nil
-	}
-
-	entry, err := p.statUncached(ctx, path)
-	if err == nil {
-		p.cache.Set(cacheKey, entry)
-	}
-	return entry, err
-}
-
-func (p *VPCProvider) statUncached(ctx context.Context, path string) (*Entry, error) {
 	if path == "" {
 		return &Entry{Name: "vpc", IsDir: true}, nil
 	}
+	if entry, ok, err := p.auditFiles().stat(path); ok {
+		return entry, err
+	}
 
 	parts := strings.Split(path, "/")
 	vpcID := parts[0]
@@ -328,9 +335,9 @@ func (p *VPCProvider) statUncached(ctx context.Context, path string) (*Entry, er
 	// Subdirectories
 	if len(parts) == 2 {
 		switch parts[1] {
-		case "info.json":
+		case "info.json", "summary.json":
 			// Size unknown until read, use placeholder that will be corrected by sisuFile.GetAttr
-			return &Entry{Name: "info.json", IsDir: false, Size: 4096}, nil
+			return &Entry{Name: parts[1], IsDir: false, Size: 4096}, nil
 		case "subnets", "route-tables", "security-groups":
 			return &Entry{Name: parts[1], IsDir: true}, nil
 		}
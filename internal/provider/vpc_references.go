@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// sgReferencesSuffix names the file listing what references a security
+// group, e.g. security-groups/sg-0123.referenced-by.json
+const sgReferencesSuffix = ".referenced-by.json"
+
+// sgReferences is the content of a group's referenced-by.json. A group
+// with no references (and not named "default") can be deleted.
+type sgReferences struct {
+	GroupId           string
+	InUse             bool
+	SecurityGroups    []sgRuleReference
+	NetworkInterfaces []sgInterfaceReference
+	// LoadBalancers are named as in their ARNs, e.g. "app/web/50dc6c495c0c9188",
+	// found through the network interfaces they own
+	LoadBalancers []string
+}
+
+// sgRuleReference is another group with a rule naming the group
+type sgRuleReference struct {
+	GroupId   string
+	GroupName string
+	Direction string // "ingress" or "egress"
+}
+
+// sgInterfaceReference is a network interface the group is attached to
+type sgInterfaceReference struct {
+	NetworkInterfaceId string
+	InterfaceType      types.NetworkInterfaceType
+	Description        string
+	InstanceId         string `json:",omitempty"`
+	RequesterId        string `json:",omitempty"`
+}
+
+// securityGroups returns the VPC's security groups, shared by the listing
+// and every referenced-by.json
+func (p *VPCProvider) securityGroups(ctx context.Context, vpcID string) ([]types.SecurityGroup, error) {
+	return p.groups.get(vpcID, func() ([]types.SecurityGroup, error) {
+		var groups []types.SecurityGroup
+		paginator := ec2.NewDescribeSecurityGroupsPaginator(p.client, &ec2.DescribeSecurityGroupsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, page.SecurityGroups...)
+		}
+		return groups, nil
+	})
+}
+
+// networkInterfaces returns the VPC's network interfaces, shared by every
+// referenced-by.json
+func (p *VPCProvider) networkInterfaces(ctx context.Context, vpcID string) ([]types.NetworkInterface, error) {
+	return p.interfaces.get(vpcID, func() ([]types.NetworkInterface, error) {
+		var enis []types.NetworkInterface
+		paginator := ec2.NewDescribeNetworkInterfacesPaginator(p.client, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			enis = append(enis, page.NetworkInterfaces...)
+		}
+		return enis, nil
+	})
+}
+
+func (p *VPCProvider) getSecurityGroupReferences(ctx context.Context, vpcID, sgID string) ([]byte, error) {
+	groups, err := p.securityGroups(ctx, vpcID)
+	if err != nil {
+		return nil, err
+	}
+	enis, err := p.networkInterfaces(ctx, vpcID)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(findSecurityGroupReferences(sgID, groups, enis), "", "  ")
+}
+
+// findSecurityGroupReferences collects the rules of other groups and the
+// network interfaces that reference sgID
+func findSecurityGroupReferences(sgID string, groups []types.SecurityGroup, enis []types.NetworkInterface) sgReferences {
+	refs := sgReferences{
+		GroupId:           sgID,
+		SecurityGroups:    []sgRuleReference{},
+		NetworkInterfaces: []sgInterfaceReference{},
+		LoadBalancers:     []string{},
+	}
+
+	for _, sg := range groups {
+		if aws.ToString(sg.GroupId) == sgID {
+			continue
+		}
+		for _, rules := range []struct {
+			direction string
+			perms     []types.IpPermission
+		}{{"ingress", sg.IpPermissions}, {"egress", sg.IpPermissionsEgress}} {
+			if referencesGroup(rules.perms, sgID) {
+				refs.SecurityGroups = append(refs.SecurityGroups, sgRuleReference{
+					GroupId:   aws.ToString(sg.GroupId),
+					GroupName: aws.ToString(sg.GroupName),
+					Direction: rules.direction,
+				})
+			}
+		}
+	}
+
+	lbs := make(map[string]bool)
+	for _, eni := range enis {
+		if !attachedTo(eni, sgID) {
+			continue
+		}
+		ref := sgInterfaceReference{
+			NetworkInterfaceId: aws.ToString(eni.NetworkInterfaceId),
+			InterfaceType:      eni.InterfaceType,
+			Description:        aws.ToString(eni.Description),
+			RequesterId:        aws.ToString(eni.RequesterId),
+		}
+		if eni.Attachment != nil {
+			ref.InstanceId = aws.ToString(eni.Attachment.InstanceId)
+		}
+		refs.NetworkInterfaces = append(refs.NetworkInterfaces, ref)
+
+		// Load balancer interfaces are described as "ELB <name>"
+		if name, ok := strings.CutPrefix(ref.Description, "ELB "); ok {
+			lbs[name] = true
+		}
+	}
+	for name := range lbs {
+		refs.LoadBalancers = append(refs.LoadBalancers, name)
+	}
+	sort.Strings(refs.LoadBalancers)
+
+	refs.InUse = len(refs.SecurityGroups) > 0 || len(refs.NetworkInterfaces) > 0
+	return refs
+}
+
+// referencesGroup reports whether any rule grants access to or from sgID
+func referencesGroup(perms []types.IpPermission, sgID string) bool {
+	for _, perm := range perms {
+		for _, pair := range perm.UserIdGroupPairs {
+			if aws.ToString(pair.GroupId) == sgID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attachedTo reports whether sgID is one of the interface's groups
+func attachedTo(eni types.NetworkInterface, sgID string) bool {
+	for _, g := range eni.Groups {
+		if aws.ToString(g.GroupId) == sgID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/netip"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// awsReservedIPs are the addresses AWS keeps in every subnet: the network
+// address, the router, DNS, one for future use and the broadcast address
+const awsReservedIPs = 5
+
+// vpcSummary is the content of a VPC's summary.json
+type vpcSummary struct {
+	VpcId        string
+	TotalIps     int64
+	AvailableIps int64
+	Subnets      []subnetSummary
+	RouteTables  []routeTableSummary
+}
+
+// subnetSummary is a subnet's IPv4 utilization and where its traffic to
+// the internet goes
+type subnetSummary struct {
+	SubnetId         string
+	Name             string `json:",omitempty"`
+	AvailabilityZone string
+	CidrBlock        string
+	TotalIps         int64 // usable addresses, after AWS's reserved ones
+	AvailableIps     int64
+	Utilization      float64 // percent of usable addresses in use
+	RouteTableId     string
+	// DefaultRoute is the target of 0.0.0.0/0, e.g. an internet gateway for
+	// public subnets or a NAT gateway, or empty if there is none
+	DefaultRoute string
+}
+
+type routeTableSummary struct {
+	RouteTableId string
+	Main         bool
+	Subnets      []string
+	Routes       []routeSummary
+}
+
+type routeSummary struct {
+	Destination string
+	Target      string
+	State       types.RouteState
+}
+
+func (p *VPCProvider) getVPCSummary(ctx context.Context, vpcID string) ([]byte, error) {
+	filter := []types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+
+	var subnets []types.Subnet
+	subnetPages := ec2.NewDescribeSubnetsPaginator(p.client, &ec2.DescribeSubnetsInput{Filters: filter})
+	for subnetPages.HasMorePages() {
+		page, err := subnetPages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, page.Subnets...)
+	}
+
+	var tables []types.RouteTable
+	tablePages := ec2.NewDescribeRouteTablesPaginator(p.client, &ec2.DescribeRouteTablesInput{Filters: filter})
+	for tablePages.HasMorePages() {
+		page, err := tablePages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, page.RouteTables...)
+	}
+
+	return json.MarshalIndent(summarizeVPC(vpcID, subnets, tables), "", "  ")
+}
+
+// summarizeVPC totals subnet utilization and resolves each subnet's route
+// table, which is the VPC's main table unless one is associated explicitly
+func summarizeVPC(vpcID string, subnets []types.Subnet, tables []types.RouteTable) vpcSummary {
+	summary := vpcSummary{
+		VpcId:       vpcID,
+		Subnets:     []subnetSummary{},
+		RouteTables: []routeTableSummary{},
+	}
+
+	var mainTable *types.RouteTable
+	tableFor := make(map[string]*types.RouteTable)
+	for i := range tables {
+		rt := &tables[i]
+		s := routeTableSummary{RouteTableId: aws.ToString(rt.RouteTableId), Subnets: []string{}, Routes: []routeSummary{}}
+		for _, assoc := range rt.Associations {
+			if aws.ToBool(assoc.Main) {
+				mainTable = rt
+				s.Main = true
+			}
+			if assoc.SubnetId != nil {
+				tableFor[aws.ToString(assoc.SubnetId)] = rt
+				s.Subnets = append(s.Subnets, aws.ToString(assoc.SubnetId))
+			}
+		}
+		for _, r := range rt.Routes {
+			s.Routes = append(s.Routes, routeSummary{Destination: routeDestination(r), Target: routeTarget(r), State: r.State})
+		}
+		summary.RouteTables = append(summary.RouteTables, s)
+	}
+
+	for _, subnet := range subnets {
+		s := subnetSummary{
+			SubnetId:         aws.ToString(subnet.SubnetId),
+			AvailabilityZone: aws.ToString(subnet.AvailabilityZone),
+			CidrBlock:        aws.ToString(subnet.CidrBlock),
+			AvailableIps:     int64(aws.ToInt32(subnet.AvailableIpAddressCount)),
+		}
+		for _, tag := range subnet.Tags {
+			if aws.ToString(tag.Key) == "Name" {
+				s.Name = aws.ToString(tag.Value)
+			}
+		}
+		if prefix, err := netip.ParsePrefix(s.CidrBlock); err == nil && prefix.Addr().Is4() {
+			s.TotalIps = int64(1)<<(32-prefix.Bits()) - awsReservedIPs
+		}
+		if s.TotalIps > 0 {
+			used := float64(s.TotalIps-s.AvailableIps) / float64(s.TotalIps) * 100
+			s.Utilization = math.Round(used*10) / 10
+		}
+
+		rt := tableFor[s.SubnetId]
+		if rt == nil {
+			rt = mainTable
+		}
+		if rt != nil {
+			s.RouteTableId = aws.ToString(rt.RouteTableId)
+			for _, r := range rt.Routes {
+				if aws.ToString(r.DestinationCidrBlock) == "0.0.0.0/0" {
+					s.DefaultRoute = routeTarget(r)
+				}
+			}
+		}
+
+		summary.TotalIps += s.TotalIps
+		summary.AvailableIps += s.AvailableIps
+		summary.Subnets = append(summary.Subnets, s)
+	}
+	sort.Slice(summary.Subnets, func(i, j int) bool { return summary.Subnets[i].SubnetId < summary.Subnets[j].SubnetId })
+	return summary
+}
+
+// routeDestination returns a route's destination CIDR or prefix list
+func routeDestination(r types.Route) string {
+	for _, d := range []*string{r.DestinationCidrBlock, r.DestinationIpv6CidrBlock, r.DestinationPrefixListId} {
+		if d != nil {
+			return *d
+		}
+	}
+	return ""
+}
+
+// routeTarget returns the ID of whatever a route sends traffic to
+func routeTarget(r types.Route) string {
+	for _, t := range []*string{
+		r.GatewayId, r.NatGatewayId, r.TransitGatewayId, r.VpcPeeringConnectionId,
+		r.NetworkInterfaceId, r.InstanceId, r.EgressOnlyInternetGatewayId,
+		r.LocalGatewayId, r.CarrierGatewayId, r.CoreNetworkArn,
+	} {
+		if t != nil {
+			return *t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestVPCSecurityGroupReferences(t *testing.T) {
+	cfg, client := fixtureConfig(t, "vpc")
+	p := newVPCProvider(cfg)
+	ctx := context.Background()
+
+	entries, err := p.ReadDir(ctx, "vpc-0a1b2c3d/security-groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGoldenEntries(t, "vpc/security-groups.json", entries)
+
+	data, err := p.Read(ctx, "vpc-0a1b2c3d/security-groups/sg-0web.referenced-by.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "vpc/sg-web-referenced-by.json", data)
+
+	data, err = p.Read(ctx, "vpc-0a1b2c3d/security-groups/sg-0old.referenced-by.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refs sgReferences
+	if err := json.Unmarshal(data, &refs); err != nil {
+		t.Fatal(err)
+	}
+	if refs.InUse || len(refs.SecurityGroups)+len(refs.NetworkInterfaces)+len(refs.LoadBalancers) != 0 {
+		t.Errorf("unused group: %s", data)
+	}
+
+	// The listing and both reports share one describe of each kind
+	want := []string{"DescribeSecurityGroups", "DescribeNetworkInterfaces"}
+	if calls := client.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestVPCSummary(t *testing.T) {
+	cfg, _ := fixtureConfig(t, "vpc")
+	p := newVPCProvider(cfg)
+
+	data, err := p.Read(context.Background(), "vpc-0a1b2c3d/summary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "vpc/summary.json", data)
+}
+
+func TestVPCSecurityGroupsTruncated(t *testing.T) {
+	defer func(n int) { MaxEntries = n }(MaxEntries)
+	MaxEntries = 2
+	cfg, _ := fixtureConfig(t, "vpc")
+	p := newVPCProvider(cfg)
+
+	entries, err := p.ReadDir(context.Background(), "vpc-0a1b2c3d/security-groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sg-0web.json", "sg-0web" + sgReferencesSuffix, MoreResultsFile}
+	if names := entryNames(entries); !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/smithy-go"
+)
+
+// WarningFile is the virtual file appended to a listing that stopped early
+// because a later page was denied, throttled or over a quota, explaining
+// what is missing
+const WarningFile = "_warning.txt"
+
+// PartialListingError is returned by ReadDir when a listing failed after
+// some of its pages. The AccessDenied middleware lists Entries with a
+// WarningFile instead of failing the whole directory.
+type PartialListingError struct {
+	Entries []Entry
+	Hint    string // CLI command producing the full listing
+	Err     error
+}
+
+func (e *PartialListingError) Error() string {
+	return fmt.Sprintf("listing stopped after %d entries: %v", len(e.Entries), e.Err)
+}
+
+func (e *PartialListingError) Unwrap() error { return e.Err }
+
+// partialListing returns the error for a listing that failed after
+// collecting entries. Failures other than permission, throttling and
+// quota errors, and failures of the first page, fail the listing as is.
+func partialListing(entries []Entry, hint string, err error) error {
+	if len(entries) == 0 || !(IsAccessDenied(err) || IsThrottled(err) || isQuotaExceeded(err)) {
+		return err
+	}
+	return &PartialListingError{Entries: entries, Hint: hint, Err: err}
+}
+
+// isQuotaExceeded reports whether err is a service quota or limit error
+func isQuotaExceeded(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return strings.Contains(code, "LimitExceeded") || strings.Contains(code, "QuotaExceeded")
+}
+
+// warningMessage explains what a partial listing left out and why
+func warningMessage(service, path string, partial *PartialListingError) string {
+	where := service
+	if path != "" {
+		where += "/" + path
+	}
+	var cause string
+	switch {
+	case IsAccessDenied(partial.Err):
+		cause = "The credentials for this profile aren't allowed to fetch the next page."
+	case IsThrottled(partial.Err):
+		cause = "AWS throttled the request for the next page. The listing is fetched\n" +
+			"again when its cache expires."
+	default:
+		cause = "A service quota or limit stopped the request for the next page."
+	}
+	return fmt.Sprintf("Listing of %s is incomplete: only the first %d entries are shown\n"+
+		"and the rest were omitted.\n\n%s\n\n%v\n\nFull listing: %s\n",
+		where, len(partial.Entries), cause, partial.Err, partial.Hint)
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WriteScopes are named subsets of a service's writable files that the
+// write config can enable instead of the whole service, e.g.
+// `write: { lambda: env-only }`
+var WriteScopes = map[string]map[string]func(path string) bool{
+	"lambda":   {"env-only": isLambdaEnvFile, "tags-only": isTagsFile},
+	"ec2":      {"tags-only": isEC2TagsFile},
+	"dynamodb": {"tags-only": isTagsFile},
+	"s3":       {"tags-only": isS3TagsFile},
+}
+
+// optInWrites are services that stay read-only unless the write config
+// enables them, since their writes change running workloads or access
+var optInWrites = map[string]bool{
+	"athena":   true, // queries run arbitrary SQL, scanned and billed
+	"dynamodb": true, // tags drive access (ABAC) and cost allocation
+	"ec2":      true, // tags drive access (ABAC) and cost allocation
+	"iam":      true,
+	"lambda":   true,
+	"sqs":      true, // send reaches the queue's consumers
+}
+
+// optInTags are the tags files of services otherwise writable by default,
+// which stay read-only unless the write config enables the whole service
+// or its tags-only scope
+var optInTags = map[string]func(path string) bool{
+	"s3": isS3TagsFile,
+}
+
+// OptInTags reports whether the tags of service, writable by default,
+// need the write config to enable them
+func OptInTags(service string) bool {
+	_, ok := optInTags[service]
+	return ok
+}
+
+// WriteScope returns which paths of service the write config mode allows
+// writing: "true" every path the provider supports, "false" none, and any
+// other mode names one of WriteScopes. An empty mode is the service default.
+func WriteScope(service, mode string) (func(path string) bool, error) {
+	switch mode {
+	case "":
+		if optInWrites[service] {
+			return noPaths, nil
+		}
+		if tags, ok := optInTags[service]; ok {
+			return func(path string) bool { return !tags(path) }, nil
+		}
+		return allPaths, nil
+	case "true":
+		return allPaths, nil
+	case "false":
+		return noPaths, nil
+	}
+	if scope, ok := WriteScopes[service][mode]; ok {
+		return scope, nil
+	}
+	return nil, fmt.Errorf("unknown write mode %q for %s (use true, false%s)", mode, service, scopeNames(service))
+}
+
+func allPaths(string) bool { return true }
+func noPaths(string) bool  { return false }
+
+// scopeNames lists the named scopes of service for error messages
+func scopeNames(service string) string {
+	var names []string
+	for name := range WriteScopes[service] {
+		names = append(names, " or "+name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "")
+}
@@ -0,0 +1,47 @@
+package provider
+
+import "testing"
+
+func TestWriteScope(t *testing.T) {
+	tests := []struct {
+		service, mode, path string
+		want                bool
+	}{
+		{"s3", "", "bucket/key", true},
+		{"s3", "true", "bucket/key", true},
+		{"ssm", "false", "app/db-url", false},
+		{"lambda", "", "api/env.json", false},
+		{"lambda", "true", "api/env.json", true},
+		{"lambda", "env-only", "api/env.json", true},
+		{"lambda", "env-only", "api/policy.json", false},
+		{"lambda", "tags-only", "api/tags.json", true},
+		{"lambda", "tags-only", "api/env.json", false},
+		{"iam", "", "roles/api/trust-policy.json", false},
+		{"iam", "true", "roles/api/trust-policy.json", true},
+		{"sqs", "", "orders/send", false},
+		{"sqs", "true", "orders/send", true},
+		{"athena", "", "queries/primary/status.sql", false},
+		{"athena", "true", "queries/primary/status.sql", true},
+		{"ec2", "", "i-0abc/tags.json", false},
+		{"ec2", "tags-only", "i-0abc/tags.json", true},
+		{"dynamodb", "", "orders/tags.json", false},
+		{"dynamodb", "true", "orders/tags.json", true},
+		{"s3", "", "bucket.tags.json", false},
+		{"s3", "true", "bucket.tags.json", true},
+		{"s3", "tags-only", "bucket.tags.json", true},
+		{"s3", "tags-only", "bucket/key", false},
+	}
+	for _, tt := range tests {
+		allowed, err := WriteScope(tt.service, tt.mode)
+		if err != nil {
+			t.Fatalf("WriteScope(%s, %q): %v", tt.service, tt.mode, err)
+		}
+		if got := allowed(tt.path); got != tt.want {
+			t.Errorf("WriteScope(%s, %q)(%s) = %v, want %v", tt.service, tt.mode, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := WriteScope("ec2", "env-only"); err == nil {
+		t.Error("scope of another service accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package provider
+
+import "strings"
+
+// WriteThrougher is implemented by providers that know what a file reads
+// back as once a write to it succeeded, e.g. an S3 object exactly as
+// written. The cache keeps that content instead of asking the service
+// again, whose listings may not show the change yet.
+type WriteThrougher interface {
+	// Written returns the content path reads back as after data was
+	// written to it, or false if that isn't known
+	Written(path string, data []byte) ([]byte, bool)
+}
+
+// Written returns what path reads back as after data was written to it
+// through p. Decorators that change content or paths implement
+// WriteThrougher to translate; those that don't (e.g. session recording)
+// leave the content unknown.
+func Written(p Provider, path string, data []byte) ([]byte, bool) {
+	if w, ok := p.(WriteThrougher); ok {
+		return w.Written(path, data)
+	}
+	return nil, false
+}
+
+// relatedFiles return, by service, the other files whose content a write
+// to path changes, e.g. the @b64 sidecar of an SSM parameter, which shows
+// the same value
+var relatedFiles = map[string]func(path string) []string{
+	"ssm": ssmRelatedFiles,
+}
+
+// RelatedFiles returns the files of service, other than path, whose
+// content a write to path changes, so their cached state can be dropped
+func RelatedFiles(service, path string) []string {
+	related, ok := relatedFiles[service]
+	if !ok {
+		return nil
+	}
+	return related(path)
+}
+
+// ssmRelatedFiles returns a parameter's value file and sidecars, other
+// than path
+func ssmRelatedFiles(path string) []string {
+	base := strings.TrimSuffix(path, SSMBase64Suffix)
+	base = strings.TrimSuffix(base, SSMMetaSuffix)
+	var related []string
+	for _, name := range []string{base, base + SSMBase64Suffix, base + SSMMetaSuffix} {
+		if name != path {
+			related = append(related, name)
+		}
+	}
+	return related
+}